@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// Memories record when they were last retrieved in the last_accessed_at
+// metadata key and how often in access_count. Accesses are collected in
+// memory and written back together after AccessFlushDelay through
+// UpdateMetadata, so a search costs no writes of its own and recording an
+// access never calls the embedder. A chunk's access is recorded on its parent.
+
+// accessBuffer holds the accesses not written back yet.
+type accessBuffer struct {
+	mu      sync.Mutex
+	pending map[string]pendingAccess // Memory ID -> accesses since the last flush
+	timer   *time.Timer              // Scheduled flush, nil if none
+}
+
+// pendingAccess is the accesses of one memory since the last flush.
+type pendingAccess struct {
+	count int
+	at    string // RFC 3339 time of the latest access
+}
+
+// accessedIDs returns the memories whose access the results count as, in
+// order and without repeats: the results themselves, or the parents of chunks.
+func accessedIDs(results []chromem.Result) []string {
+	var ids []string
+	seen := make(map[string]bool, len(results))
+	for _, res := range results {
+		id := res.ID
+		if isChunk(res.Metadata) {
+			id = res.Metadata["parent_id"]
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// recordAccess counts an access to each memory in ids now and schedules a
+// flush if none is pending.
+func (a *App) recordAccess(ctx context.Context, ids ...string) {
+	if len(ids) == 0 {
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	a.accesses.mu.Lock()
+	defer a.accesses.mu.Unlock()
+	if a.accesses.pending == nil {
+		a.accesses.pending = make(map[string]pendingAccess)
+	}
+	for _, id := range ids {
+		p := a.accesses.pending[id]
+		p.count++
+		p.at = now
+		a.accesses.pending[id] = p
+	}
+	if a.accesses.timer == nil {
+		a.accesses.timer = time.AfterFunc(AccessFlushDelay, func() { a.flushAccess(context.Background()) })
+	}
+}
+
+// flushAccess writes the pending accesses back: last_accessed_at is set to the
+// latest access and access_count increased by the number of accesses. Each
+// memory is written once however often it was retrieved. Failures are logged,
+// since the memories were retrieved regardless.
+func (a *App) flushAccess(ctx context.Context) {
+	a.accessMu.Lock()
+	defer a.accessMu.Unlock()
+
+	a.accesses.mu.Lock()
+	pending := a.accesses.pending
+	a.accesses.pending = nil
+	if a.accesses.timer != nil {
+		a.accesses.timer.Stop()
+		a.accesses.timer = nil
+	}
+	a.accesses.mu.Unlock()
+
+	for id, p := range pending {
+		doc, err := a.vectorStore.GetByID(ctx, id)
+		if err != nil || isSoftDeleted(doc.Metadata) {
+			continue
+		}
+		metadata := make(map[string]string, len(doc.Metadata)+2)
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		metadata["last_accessed_at"] = p.at
+		metadata["access_count"] = strconv.Itoa(accessCount(doc.Metadata) + p.count)
+		if err := a.vectorStore.UpdateMetadata(ctx, id, metadata); err != nil {
+			a.logf(ctx, "Warning: Failed to record access to '%s': %v", id, err)
+		}
+	}
+}
+
+// accessCount returns a memory's access_count, 0 if it was never accessed.
+func accessCount(metadata map[string]string) int {
+	n, _ := strconv.Atoi(metadata["access_count"])
+	return n
+}
+
+// keepAccessStats copies the access statistics of the stored memory id into
+// metadata, so storing a new version of a memory does not reset them.
+func (a *App) keepAccessStats(ctx context.Context, id string, metadata map[string]string) {
+	doc, err := a.vectorStore.GetByID(ctx, id)
+	if err != nil {
+		return
+	}
+	for _, key := range []string{"last_accessed_at", "access_count"} {
+		if v := doc.Metadata[key]; v != "" {
+			metadata[key] = v
+		}
+	}
+}
+
+// listStaleMemoriesHandler handles the list_stale_memories tool - lists the
+// memories not retrieved in the last stale_days days, least recently used
+// first. Memories never accessed count from their creation.
+func (a *App) listStaleMemoriesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+
+	staleDays := DefaultStaleDays
+	if v, ok := args["stale_days"].(float64); ok {
+		if v < 1 {
+			return mcp.NewToolResultError("stale_days must be at least 1"), nil
+		}
+		staleDays = int(v)
+	}
+	var where map[string]string
+	contextID, _ := args["context_id"].(string)
+	if contextID = strings.TrimSpace(contextID); contextID != "" {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+		where = map[string]string{"context": contextID}
+	}
+
+	a.flushAccess(ctx)
+	docs, err := a.vectorStore.ListDocuments(ctx, where, 0, 0)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list memories: %v", err)), nil
+	}
+
+	now := time.Now()
+	cutoff := now.AddDate(0, 0, -staleDays)
+	var stale []chromem.Document
+	for _, doc := range a.accessibleDocuments(ctx, docs) {
+		if isChunk(doc.Metadata) || isSoftDeleted(doc.Metadata) || isExpired(doc.Metadata, now) {
+			continue
+		}
+		if lastActivity(doc.Metadata).Before(cutoff) {
+			stale = append(stale, doc)
+		}
+	}
+	if len(stale) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories unused for %d days.", staleDays)), nil
+	}
+	sort.SliceStable(stale, func(i, j int) bool {
+		return lastActivity(stale[i].Metadata).Before(lastActivity(stale[j].Metadata))
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%d memories unused for %d days, least recently used first:\n", len(stale), staleDays))
+	for _, doc := range stale {
+		accessed := "never accessed"
+		if t, _, err := parseStoredTime(doc.Metadata["last_accessed_at"]); err == nil {
+			accessed = fmt.Sprintf("last accessed %s, %d accesses", a.formatTime(t), accessCount(doc.Metadata))
+		}
+		created := "unknown"
+		if t, _, err := parseStoredTime(doc.Metadata["created_at"]); err == nil {
+			created = a.formatTime(t)
+		}
+		sb.WriteString(fmt.Sprintf("- %s (%s, created %s, context: %s)\n", doc.ID, accessed, created, doc.Metadata["context"]))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
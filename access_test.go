@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRecordAccessBatchesWrites(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ctx := context.Background()
+	ta.remember(t, "a", "alpha notes about the garden", nil)
+	ta.remember(t, "b", "bravo notes about the kitchen", nil)
+
+	before := ta.backend.MutationStamp()
+	for range 3 {
+		ta.recordAccess(ctx, "a", "b")
+	}
+	ta.recordAccess(ctx, "a")
+	if stamp := ta.backend.MutationStamp(); stamp != before {
+		t.Fatalf("recording accesses wrote to the store %d times before the flush", stamp-before)
+	}
+
+	ta.flushAccess(ctx)
+	if writes := ta.backend.MutationStamp() - before; writes != 2 {
+		t.Errorf("flush wrote %d times, want one write per memory (2)", writes)
+	}
+	for id, want := range map[string]int{"a": 4, "b": 3} {
+		doc, err := ta.vectorStore.GetByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := accessCount(doc.Metadata); got != want {
+			t.Errorf("access_count of %s = %d, want %d", id, got, want)
+		}
+		if doc.Metadata["last_accessed_at"] == "" {
+			t.Errorf("last_accessed_at of %s not set", id)
+		}
+		if len(doc.Embedding) != testDimension {
+			t.Errorf("embedding of %s lost in the metadata update", id)
+		}
+	}
+	if ta.embedder.Count() != 2 {
+		t.Errorf("embedded %d texts, want only the 2 stored memories", ta.embedder.Count())
+	}
+
+	// Nothing pending, nothing written
+	stamp := ta.backend.MutationStamp()
+	ta.flushAccess(ctx)
+	if ta.backend.MutationStamp() != stamp {
+		t.Error("an empty flush wrote to the store")
+	}
+}
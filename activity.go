@@ -0,0 +1,360 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ActivityCounts counts memory and query activity.
+type ActivityCounts struct {
+	Created  int `json:"created"`
+	Updated  int `json:"updated"`
+	Deleted  int `json:"deleted"`
+	Searches int `json:"searches"`
+	Asks     int `json:"asks"`
+}
+
+// add adds other to c.
+func (c *ActivityCounts) add(other ActivityCounts) {
+	c.Created += other.Created
+	c.Updated += other.Updated
+	c.Deleted += other.Deleted
+	c.Searches += other.Searches
+	c.Asks += other.Asks
+}
+
+// total returns the sum of all counters.
+func (c ActivityCounts) total() int {
+	return c.Created + c.Updated + c.Deleted + c.Searches + c.Asks
+}
+
+// ActivityLog keeps per-day, per-context activity counters persisted in the
+// data directory, so reports never scan the stored memories. Days are
+// calendar days in the configured timezone. A nil *ActivityLog discards all records.
+type ActivityLog struct {
+	mu        sync.Mutex
+	days      map[string]map[string]*ActivityCounts // day (YYYY-MM-DD) -> context -> counts
+	path      string
+	cipher    *FileCipher // Encrypts the counters file when set
+	location  *time.Location
+	logger    *log.Logger
+	saveTimer *time.Timer
+}
+
+// NewActivityLog creates an activity log persisted at path and loads any existing counters.
+func NewActivityLog(path string, cipher *FileCipher, location *time.Location, logger *log.Logger) *ActivityLog {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	al := &ActivityLog{
+		days:     make(map[string]map[string]*ActivityCounts),
+		path:     path,
+		cipher:   cipher,
+		location: location,
+		logger:   logger,
+	}
+
+	if err := al.load(); err != nil && !os.IsNotExist(err) {
+		logger.Printf("Warning: Failed to load activity log: %v. Starting fresh.", err)
+		al.days = make(map[string]map[string]*ActivityCounts)
+	}
+
+	return al
+}
+
+// Record adds delta to the counters of contextID for the current day.
+func (al *ActivityLog) Record(contextID string, delta ActivityCounts) {
+	if al == nil {
+		return
+	}
+	al.recordAt(time.Now(), contextID, delta)
+}
+
+// recordAt adds delta to the counters of contextID for the day of t.
+func (al *ActivityLog) recordAt(t time.Time, contextID string, delta ActivityCounts) {
+	if contextID == "" {
+		contextID = DefaultContextID
+	}
+	day := t.In(al.location).Format(time.DateOnly)
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	contexts, ok := al.days[day]
+	if !ok {
+		contexts = make(map[string]*ActivityCounts)
+		al.days[day] = contexts
+	}
+	counts, ok := contexts[contextID]
+	if !ok {
+		counts = &ActivityCounts{}
+		contexts[contextID] = counts
+	}
+	counts.add(delta)
+	al.scheduleSaveLocked()
+}
+
+// Empty reports whether nothing has been recorded yet.
+func (al *ActivityLog) Empty() bool {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	return len(al.days) == 0
+}
+
+// Backfill records a creation for every stored memory on the day of its
+// created_at timestamp. It is run once, when the log is first created, so
+// memories stored before activity was tracked appear in reports.
+func (al *ActivityLog) Backfill(ctx context.Context, backend VectorBackend) error {
+	docs, err := backend.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	backfilled := 0
+	for _, doc := range docs {
+		t, _, err := parseStoredTime(doc.Metadata["created_at"])
+		if err != nil || isSoftDeleted(doc.Metadata) {
+			continue
+		}
+		al.recordAt(t, doc.Metadata["context"], ActivityCounts{Created: 1})
+		backfilled++
+	}
+
+	al.logger.Printf("Backfilled activity log with %d memory creations", backfilled)
+	return al.Flush()
+}
+
+// Flush writes pending changes to disk immediately.
+func (al *ActivityLog) Flush() error {
+	if al == nil {
+		return nil
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if al.saveTimer != nil {
+		al.saveTimer.Stop()
+		al.saveTimer = nil
+	}
+	return al.saveLocked()
+}
+
+// scheduleSaveLocked writes the counters once ActivitySaveDelay has passed since
+// the first unsaved change (caller must hold the lock).
+func (al *ActivityLog) scheduleSaveLocked() {
+	if al.saveTimer != nil {
+		return
+	}
+	al.saveTimer = time.AfterFunc(ActivitySaveDelay, func() {
+		al.mu.Lock()
+		defer al.mu.Unlock()
+		al.saveTimer = nil
+		if err := al.saveLocked(); err != nil {
+			al.logger.Printf("Warning: Failed to persist activity log: %v", err)
+		}
+	})
+}
+
+// load reads the persisted counters (internal, called before the log is shared).
+func (al *ActivityLog) load() error {
+	data, err := al.cipher.ReadFile(al.path)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, &al.days)
+}
+
+// saveLocked writes the counters atomically (caller must hold the lock).
+func (al *ActivityLog) saveLocked() error {
+	data, err := json.Marshal(al.days)
+	if err != nil {
+		return fmt.Errorf("failed to marshal activity log: %w", err)
+	}
+
+	tmpPath := al.path + ".tmp"
+	if err := al.cipher.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write activity log: %w", err)
+	}
+	if err := os.Rename(tmpPath, al.path); err != nil {
+		return fmt.Errorf("failed to finalize activity log: %w", err)
+	}
+	return nil
+}
+
+// ActivityBucket is one day or week of an activity report.
+type ActivityBucket struct {
+	Start    string                    `json:"start"` // First day of the bucket (YYYY-MM-DD)
+	Counts   ActivityCounts            `json:"counts"`
+	Contexts map[string]ActivityCounts `json:"contexts,omitempty"`
+}
+
+// ActivityReport aggregates the activity log over a window of buckets.
+type ActivityReport struct {
+	Granularity string                    `json:"granularity"`
+	From        string                    `json:"from"`
+	To          string                    `json:"to"`
+	Timezone    string                    `json:"timezone"`
+	Buckets     []ActivityBucket          `json:"buckets"`
+	Totals      ActivityCounts            `json:"totals"`
+	Contexts    map[string]ActivityCounts `json:"contexts,omitempty"` // Window totals per context
+}
+
+// Report aggregates the last window days or weeks up to and including now.
+// Weeks start on Monday. Per-context counts are included when byContext is set.
+func (al *ActivityLog) Report(granularity string, window int, byContext bool, now time.Time) *ActivityReport {
+	today := now.In(al.location)
+	today = time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, al.location)
+
+	bucketDays := 1
+	if granularity == ActivityWeekly {
+		bucketDays = 7
+		today = today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	}
+	first := today.AddDate(0, 0, -(window-1)*bucketDays)
+
+	report := &ActivityReport{
+		Granularity: granularity,
+		From:        first.Format(time.DateOnly),
+		To:          today.AddDate(0, 0, bucketDays-1).Format(time.DateOnly),
+		Timezone:    al.location.String(),
+		Buckets:     make([]ActivityBucket, 0, window),
+	}
+	if byContext {
+		report.Contexts = make(map[string]ActivityCounts)
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	for i := 0; i < window; i++ {
+		start := first.AddDate(0, 0, i*bucketDays)
+		bucket := ActivityBucket{Start: start.Format(time.DateOnly)}
+		if byContext {
+			bucket.Contexts = make(map[string]ActivityCounts)
+		}
+		for d := 0; d < bucketDays; d++ {
+			for contextID, counts := range al.days[start.AddDate(0, 0, d).Format(time.DateOnly)] {
+				bucket.Counts.add(*counts)
+				if byContext {
+					perBucket := bucket.Contexts[contextID]
+					perBucket.add(*counts)
+					bucket.Contexts[contextID] = perBucket
+					perWindow := report.Contexts[contextID]
+					perWindow.add(*counts)
+					report.Contexts[contextID] = perWindow
+				}
+			}
+		}
+		report.Totals.add(bucket.Counts)
+		report.Buckets = append(report.Buckets, bucket)
+	}
+	return report
+}
+
+// sparkline renders one bar per value, scaled to the largest value.
+func sparkline(values []int) string {
+	bars := []rune("▁▂▃▄▅▆▇█")
+	peak := 0
+	for _, v := range values {
+		peak = max(peak, v)
+	}
+
+	var sb strings.Builder
+	for _, v := range values {
+		level := 0
+		if peak > 0 && v > 0 {
+			level = 1 + (v*(len(bars)-2)+peak-1)/peak
+		}
+		sb.WriteRune(bars[min(level, len(bars)-1)])
+	}
+	return sb.String()
+}
+
+// activityRow formats one aligned row of the activity table.
+func activityRow(label string, c ActivityCounts) string {
+	return fmt.Sprintf("%-12s %8d %8d %8d %9d %6d\n", label, c.Created, c.Updated, c.Deleted, c.Searches, c.Asks)
+}
+
+// String renders the report as a sparkline and aligned tables.
+func (r *ActivityReport) String() string {
+	unit, label := "day", "Day"
+	if r.Granularity == ActivityWeekly {
+		unit, label = "week", "Week of"
+	}
+
+	totals := make([]int, len(r.Buckets))
+	for i, bucket := range r.Buckets {
+		totals[i] = bucket.Counts.total()
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Activity per %s, %s to %s (%s)\n", unit, r.From, r.To, r.Timezone))
+	sb.WriteString(fmt.Sprintf("Activity: %s\n", sparkline(totals)))
+	if r.Totals.total() == 0 {
+		sb.WriteString("No activity recorded in this window.\n")
+	}
+
+	sb.WriteString(fmt.Sprintf("\n%-12s %8s %8s %8s %9s %6s\n", label, "Created", "Updated", "Deleted", "Searches", "Asks"))
+	for _, bucket := range r.Buckets {
+		sb.WriteString(activityRow(bucket.Start, bucket.Counts))
+	}
+	sb.WriteString(activityRow("Total", r.Totals))
+
+	if len(r.Contexts) > 0 {
+		contextIDs := make([]string, 0, len(r.Contexts))
+		for contextID := range r.Contexts {
+			contextIDs = append(contextIDs, contextID)
+		}
+		sort.Strings(contextIDs)
+
+		sb.WriteString(fmt.Sprintf("\n%-12s %8s %8s %8s %9s %6s\n", "Context", "Created", "Updated", "Deleted", "Searches", "Asks"))
+		for _, contextID := range contextIDs {
+			sb.WriteString(activityRow(contextID, r.Contexts[contextID]))
+		}
+	}
+	return sb.String()
+}
+
+// activityReportHandler handles the activity_report tool - shows memory and
+// query activity per day or week from the persisted activity log.
+func (a *App) activityReportHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	byContext, _ := args["by_context"].(bool)
+
+	granularity, _ := args["granularity"].(string)
+	window := DefaultActivityDays
+	switch granularity = strings.ToLower(strings.TrimSpace(granularity)); granularity {
+	case "", ActivityDaily:
+		granularity = ActivityDaily
+	case ActivityWeekly:
+		window = DefaultActivityWeeks
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid granularity %q: use %q or %q", granularity, ActivityDaily, ActivityWeekly)), nil
+	}
+	if v, ok := args["window"].(float64); ok {
+		window = max(1, min(int(v), MaxActivityBuckets))
+	}
+
+	report := a.activity.Report(granularity, window, byContext, time.Now())
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to encode activity report: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(report.String() + "\n" + string(data)), nil
+}
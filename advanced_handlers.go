@@ -1,170 +1,1117 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
 )
 
 
-// exportMemoriesHandler handles memory export requests.
+// exportMemoriesHandler handles memory export requests. The export is returned
+// as JSON suitable for import_memories or as Markdown; it is written to
+// file_path when given, or to a file in the exports directory when it exceeds
+// the inline response limit.
 func (a *App) exportMemoriesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args := request.Params.Arguments.(map[string]interface{})
+	args, _ := request.Params.Arguments.(map[string]interface{})
+	includeVersions, _ := args["include_versions"].(bool)
+	filePath, _ := args["file_path"].(string)
 
-	memoryIDsRaw, _ := args["memory_ids"]
-	includeVersions, _ := args["include_versions"]
+	format, _ := args["format"].(string)
+	switch format = strings.ToLower(strings.TrimSpace(format)); format {
+	case "":
+		format = ExportFormatJSON
+	case ExportFormatJSON, ExportFormatMarkdown:
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid format %q: use %q or %q", format, ExportFormatJSON, ExportFormatMarkdown)), nil
+	}
 
-	var memoryIds []string
-	if memoryIDsRaw != nil {
-		if ids, ok := memoryIDsRaw.([]interface{}); ok {
-			for _, id := range ids {
-				if idStr, ok := id.(string); ok {
-					memoryIds = append(memoryIds, idStr)
-				}
+	var memoryIDs []string
+	if ids, ok := args["memory_ids"].([]interface{}); ok {
+		for _, id := range ids {
+			if idStr, ok := id.(string); ok {
+				memoryIDs = append(memoryIDs, idStr)
 			}
 		}
 	}
 
-	incVers := false
-	if incVal, ok := includeVersions.(bool); ok {
-		incVers = incVal
+	export, err := a.buildExport(ctx, memoryIDs, includeVersions)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to export memories: %v", err)), nil
+	}
+
+	if filePath = strings.TrimSpace(filePath); filePath != "" {
+		// Clients may only write into the exports folder, never over other files
+		exportDir := filepath.Join(a.dataDir, "exports")
+		if err := os.MkdirAll(exportDir, 0755); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to create %s: %v", exportDir, err)), nil
+		}
+		filePath, err = confinePath(exportDir, filePath)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid file_path: %v", err)), nil
+		}
+		size, err := writeExport(filePath, export, format, a.exportMarkdown)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to write export: %v", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Exported %d memories to %s (%d bytes).", len(export.Memories), filePath, size)), nil
+	}
+
+	if format == ExportFormatMarkdown {
+		return a.sizedResult("export", "md", len(export.Memories), []byte(a.exportMarkdown(export))), nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to encode export: %v", err)), nil
+	}
+
+	return a.sizedResult("export", "json", len(export.Memories), buf.Bytes()), nil
+}
+
+// buildExport collects the live memories in the vector store, or only those
+// in memoryIDs, with their version history and the contexts and tags. Only
+// the contexts the client may use, and their memories, are exported.
+// Memories stored before versions were recorded are exported as a single
+// version built from the stored document.
+func (a *App) buildExport(ctx context.Context, memoryIDs []string, includeVersions bool) (*ExportData, error) {
+	docs, err := a.vectorStore.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &ExportData{
+		ExportedAt:        time.Now().UTC(),
+		ExportedBy:        a.clientIDFrom(ctx),
+		Memories:          []MemoryWithHistory{},
+		Contexts:          make(map[string]*Context),
+		Tags:              make(map[string]*Tag),
+		Version:           ExportFormatVersion,
+		EmbeddingProvider: a.embeddingProvider,
 	}
 
-	// Get export data - TODO: implement proper export method
-	// For now, return success message
-	return mcp.NewToolResultText(fmt.Sprintf("Export prepared for %d memories (versioning: %v)", len(memoryIds), incVers)), nil
+	for _, doc := range a.accessibleDocuments(ctx, docs) {
+		if isSoftDeleted(doc.Metadata) || (len(memoryIDs) > 0 && !slices.Contains(memoryIDs, doc.ID)) {
+			continue
+		}
+
+		memory, ok := a.versionMgr.ExportMemory(doc.ID, includeVersions)
+		if !ok {
+			created, _, _ := parseStoredTime(doc.Metadata["created_at"])
+			memory = MemoryWithHistory{
+				ID:             doc.ID,
+				CurrentVersion: 1,
+				Versions: []MemoryVersion{{
+					VersionNumber: 1,
+					Content:       doc.Content,
+					CreatedAt:     created,
+					CreatedBy:     doc.Metadata["client"],
+				}},
+				CreatedAt: created,
+				UpdatedAt: created,
+				Metadata:  map[string]string{},
+			}
+		}
+
+		// The vector store is authoritative for context, tags and metadata
+		memory.Context = doc.Metadata["context"]
+		if memory.Context == "" {
+			memory.Context = DefaultContextID
+		}
+		memory.Tags = ParseTags(doc.Metadata["tags"])
+		for k, v := range doc.Metadata {
+			memory.Metadata[k] = v
+		}
+		export.Memories = append(export.Memories, memory)
+	}
+	sort.Slice(export.Memories, func(i, j int) bool {
+		return export.Memories[i].ID < export.Memories[j].ID
+	})
+
+	clientID := a.clientIDFrom(ctx)
+	for _, c := range a.ctx.ListContexts() {
+		if a.contextAccessDenied(clientID, c.ID) == "" {
+			export.Contexts[c.ID] = c
+		}
+	}
+	for _, tag := range a.ctx.ListTags() {
+		export.Tags[tag.Name] = tag
+	}
+	return export, nil
+}
+
+// writeExport streams an export to path in the given format and returns the
+// number of bytes written.
+func writeExport(path string, export *ExportData, format string, markdown func(*ExportData) string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return 0, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+
+	w := bufio.NewWriter(file)
+	if format == ExportFormatMarkdown {
+		_, err = w.WriteString(markdown(export))
+	} else {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(export)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		file.Close()
+		return 0, err
+	}
+
+	info, err := file.Stat()
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
 }
 
 // importMemoriesHandler handles memory import requests.
+// With preview=true it only classifies incoming memories against local history
+// (new, identical, fast_forward, stale, conflict). Otherwise it commits the
+// import using the strategy chosen for each class and writes changed memories
+// to the vector store.
 func (a *App) importMemoriesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args := request.Params.Arguments.(map[string]interface{})
+	args, _ := request.Params.Arguments.(map[string]interface{})
+
+	// Parse the export from json_data or, for large exports, from file_path
+	var data []byte
+	if filePath, _ := args["file_path"].(string); strings.TrimSpace(filePath) != "" {
+		var err error
+		if data, err = a.readExportFile(filePath); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	} else {
+		jsonDataRaw, ok := args["json_data"]
+		if !ok {
+			return mcp.NewToolResultError("Missing json_data or file_path parameter"), nil
+		}
+
+		jsonData, ok := jsonDataRaw.(string)
+		if !ok {
+			return mcp.NewToolResultError("json_data must be a string"), nil
+		}
+
+		data = []byte(jsonData)
+	}
+	return a.importExport(ctx, request, args, data)
+}
+
+// importExport imports the export in data with the preview and strategy
+// options in args, for import_memories and import_from_s3.
+func (a *App) importExport(ctx context.Context, request mcp.CallToolRequest, args map[string]interface{}, data []byte) (*mcp.CallToolResult, error) {
+	decoded, sourceVersion, err := decodeExport(data)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	export := *decoded
+	invalid := normalizeImport(&export)
+
+	conflictStrategy, _ := args["conflict_strategy"].(string)
+	switch conflictStrategy = strings.ToLower(strings.TrimSpace(conflictStrategy)); conflictStrategy {
+	case "", ConflictSkip, ConflictOverwrite, ConflictRename:
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("conflict_strategy must be '%s', '%s' or '%s'", ConflictSkip, ConflictOverwrite, ConflictRename)), nil
+	}
+
+	if msg := a.importAccessDenied(ctx, export.Memories); msg != "" {
+		return mcp.NewToolResultError(fmt.Sprintf("Import failed: %s", msg)), nil
+	}
+
+	if preview, _ := args["preview"].(bool); preview {
+		result := a.versionMgr.PreviewImport(&export)
+		result.SourceVersion = sourceVersion
+		return importResultText("Import preview", result)
+	}
+
+	duplicateStrategy, err := parseDuplicateStrategy(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	strategy := ImportStrategy{New: "import", FastForward: "apply", Conflict: MergeKeepLocal}
+	if v, ok := args["new_strategy"].(string); ok && v != "" {
+		strategy.New = v
+	}
+	if v, ok := args["fast_forward_strategy"].(string); ok && v != "" {
+		strategy.FastForward = v
+	}
+	if v, ok := args["merge_strategy"].(string); ok && v != "" {
+		strategy.Conflict = v
+	}
+	if strategy.New != "import" && strategy.New != "skip" {
+		return mcp.NewToolResultError("new_strategy must be 'import' or 'skip'"), nil
+	}
+	if strategy.FastForward != "apply" && strategy.FastForward != "skip" {
+		return mcp.NewToolResultError("fast_forward_strategy must be 'apply' or 'skip'"), nil
+	}
+	switch strategy.Conflict {
+	case MergeKeepLocal, MergeKeepIncoming, MergeAppend:
+	default:
+		return mcp.NewToolResultError("merge_strategy must be 'keep_local', 'keep_incoming', or 'merge'"), nil
+	}
+
+	// Memories whose content exactly matches another memory are screened out
+	// before their history is imported, unless they overwrite the existing copy
+	var dups duplicateSet
+	memories := export.Memories[:0:0]
+	for _, m := range export.Memories {
+		if dups.admit(ctx, a, duplicateStrategy, m.ID, m.CurrentContent(), m.Tags) {
+			memories = append(memories, m)
+		}
+	}
+	export.Memories = memories
+
+	skipped, overwritten, renamed, err := a.applyConflictStrategy(ctx, &export, conflictStrategy)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Import failed: %v", err)), nil
+	}
+
+	if msg := a.importCapExceeded(ctx, export.Memories, strategy.New == "skip"); msg != "" {
+		return mcp.NewToolResultError(fmt.Sprintf("Import failed: %s", msg)), nil
+	}
+
+	tool := request.Params.Name
+	if tool == "" {
+		tool = "import_memories"
+	}
+	result, changed, err := a.versionMgr.CommitImport(&export, strategy)
+	if err != nil {
+		a.recordAudit(ctx, AuditEntry{Tool: tool, ClientID: a.clientIDFrom(ctx), Status: "error", Details: err.Error()})
+		return mcp.NewToolResultError(fmt.Sprintf("Import failed: %v", err)), nil
+	}
+
+	embedFailures, err := a.storeHistoryDocuments(ctx, changed, a.newProgress(ctx, request, len(changed), "Imported"))
+	if err != nil {
+		a.recordAudit(ctx, AuditEntry{Tool: tool, MemoryIDs: changed, ClientID: a.clientIDFrom(ctx), Status: "error", Details: err.Error()})
+		return mcp.NewToolResultError(fmt.Sprintf("Version history imported but storing memories failed: %v", err)), nil
+	}
+	dups.apply(ctx, a)
+	a.importContextsAndTags(ctx, &export)
+
+	for i, item := range result.Items {
+		result.Items[i].RenamedFrom = renamed[item.ID]
+		if item.Action == "imported" && slices.Contains(overwritten, item.ID) {
+			result.Items[i].Action = "overwritten"
+		}
+		if _, ok := embedFailures[item.ID]; ok {
+			result.Items[i].Action = "failed"
+		}
+	}
+	for _, id := range skipped {
+		result.Items = append(result.Items, ImportClassification{ID: id, Action: "skipped_existing"})
+	}
+	result.Summary = importSummary(result, invalid, invalidEmbeddingNotes(embedFailures))
+	result.SourceVersion = sourceVersion
+
+	entry := AuditEntry{Tool: tool, MemoryIDs: changed, ClientID: a.clientIDFrom(ctx), Status: "ok",
+		Details: fmt.Sprintf("%d of %d succeeded", result.Summary.Successful, result.Summary.Total)}
+	if result.Summary.Failed > 0 {
+		entry.Status = "error"
+	}
+	a.recordAudit(ctx, entry)
+
+	title := "Import completed"
+	if dups.count() > 0 {
+		title = fmt.Sprintf("Import completed (exact duplicates: %s)", dups.summary())
+	}
+	return importResultText(title, result)
+}
+
+// readExportFile reads an export file from the data directory's exports
+// folder, decrypting files written for large responses. Relative paths are
+// resolved there and paths outside it are refused, so clients cannot read
+// other files on the server.
+func (a *App) readExportFile(path string) ([]byte, error) {
+	path, err := confinePath(filepath.Join(a.dataDir, "exports"), strings.TrimSpace(path))
+	if err != nil {
+		return nil, fmt.Errorf("invalid file_path: %v", err)
+	}
+	data, err := a.cipher.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read export file: %v", err)
+	}
+	return data, nil
+}
+
+// normalizeImport fills in the context and metadata of memories that have
+// none. Memories without any content are removed and returned as errors.
+func normalizeImport(export *ExportData) []string {
+	var invalid []string
+	memories := export.Memories[:0]
+	for _, m := range export.Memories {
+		if strings.TrimSpace(m.ID) == "" || len(m.Versions) == 0 {
+			invalid = append(invalid, fmt.Sprintf("memory %q: no ID or no versions", m.ID))
+			continue
+		}
+		if m.Context == "" {
+			m.Context = DefaultContextID
+		}
+		if m.Metadata == nil {
+			m.Metadata = map[string]string{}
+		}
+		memories = append(memories, m)
+	}
+	export.Memories = memories
+	return invalid
+}
+
+// applyConflictStrategy handles incoming memories whose ID already exists in
+// the vector store or version history. With no strategy they go through the
+// history-aware classification; otherwise they are skipped, overwritten (the
+// local history is dropped so the incoming one is imported as new) or renamed.
+// It returns the skipped and overwritten IDs and a map from new to original IDs.
+func (a *App) applyConflictStrategy(ctx context.Context, export *ExportData, strategy string) (skipped, overwritten []string, renamed map[string]string, err error) {
+	renamed = make(map[string]string)
+	if strategy == "" {
+		return nil, nil, renamed, nil
+	}
+
+	exists := func(id string) bool {
+		if _, err := a.versionMgr.GetHistory(id); err == nil {
+			return true
+		}
+		_, err := a.vectorStore.GetByID(ctx, id)
+		return err == nil
+	}
+
+	memories := export.Memories[:0]
+	for _, m := range export.Memories {
+		if !exists(m.ID) {
+			memories = append(memories, m)
+			continue
+		}
+		switch strategy {
+		case ConflictSkip:
+			skipped = append(skipped, m.ID)
+			continue
+		case ConflictOverwrite:
+			if _, err := a.versionMgr.GetHistory(m.ID); err == nil {
+				if err := a.versionMgr.DeleteMemoryHistory(m.ID); err != nil {
+					return nil, nil, nil, fmt.Errorf("failed to replace history of %q: %w", m.ID, err)
+				}
+			}
+			overwritten = append(overwritten, m.ID)
+		case ConflictRename:
+			newID := m.ID + "-imported"
+			for n := 2; exists(newID) || renamed[newID] != ""; n++ {
+				newID = fmt.Sprintf("%s-imported-%d", m.ID, n)
+			}
+			renamed[newID] = m.ID
+			m.ID = newID
+		}
+		memories = append(memories, m)
+	}
+	export.Memories = memories
+	return skipped, overwritten, renamed, nil
+}
+
+// importAccessDenied returns an error message if an import would write to a
+// context the client may not use, as the context of an incoming memory or as
+// the one a stored memory with the same ID is in, or "" if it would not.
+func (a *App) importAccessDenied(ctx context.Context, memories []MemoryWithHistory) string {
+	clientID := a.clientIDFrom(ctx)
+	for _, m := range memories {
+		if msg := a.contextAccessDenied(clientID, m.Context); msg != "" {
+			return fmt.Sprintf("memory '%s': %s", m.ID, msg)
+		}
+		if existing, err := a.vectorStore.GetByID(ctx, m.ID); err == nil {
+			if msg := a.memoryAccessDenied(ctx, existing); msg != "" {
+				return msg
+			}
+		}
+	}
+	return ""
+}
+
+// importContextsAndTags creates the contexts and tags of an export, and those
+// referenced by its memories, that do not exist locally yet.
+func (a *App) importContextsAndTags(ctx context.Context, export *ExportData) {
+	contexts := make(map[string]*Context)
+	for id, c := range export.Contexts {
+		if c != nil {
+			contexts[id] = c
+		}
+	}
+	tags := make(map[string]*Tag)
+	for name, tag := range export.Tags {
+		if tag != nil {
+			tags[name] = tag
+		}
+	}
+	for _, m := range export.Memories {
+		if _, ok := contexts[m.Context]; !ok {
+			contexts[m.Context] = &Context{ID: m.Context, Name: m.Context}
+		}
+		for _, tag := range m.Tags {
+			if _, ok := tags[tag]; !ok {
+				tags[tag] = &Tag{Name: tag}
+			}
+		}
+	}
+
+	for id, c := range contexts {
+		if _, err := a.ctx.GetContext(id); err == nil {
+			continue
+		}
+		name := c.Name
+		if name == "" {
+			name = id
+		}
+		// Client IDs of the exporting server mean nothing here, so the
+		// importing client owns the contexts it creates
+		if err := a.ctx.CreateContext(id, name, c.Description, a.contextOwner(ctx)); err != nil {
+			a.logf(ctx, "Warning: Failed to create imported context '%s': %v", id, err)
+		}
+	}
+	// Parents may come after their children, so the hierarchy is linked once
+	// every tag exists; tags that already existed keep their place
+	var created []string
+	for name, tag := range tags {
+		if _, err := a.ctx.GetTag(name); err == nil {
+			continue
+		}
+		if err := a.ctx.CreateTag(name, tag.Description, tag.Color, ""); err != nil {
+			a.logf(ctx, "Warning: Failed to create imported tag '%s': %v", name, err)
+			continue
+		}
+		created = append(created, name)
+	}
+	for _, name := range created {
+		if parent := tags[name].Parent; parent != "" {
+			if err := a.ctx.SetTagParent(name, parent); err != nil {
+				a.logf(ctx, "Warning: Failed to set parent of imported tag '%s': %v", name, err)
+			}
+		}
+	}
+}
 
-	jsonDataRaw, ok := args["json_data"]
-	if !ok {
-		return mcp.NewToolResultError("Missing json_data parameter"), nil
+// importCapExceeded returns a message when importing memories would take a
+// context past its memory cap, or "" if they fit. With skipNew, memories not
+// stored yet are not imported and not counted.
+func (a *App) importCapExceeded(ctx context.Context, memories []MemoryWithHistory, skipNew bool) string {
+	if a.settings().MaxMemoriesPerContext <= 0 {
+		return ""
 	}
+	byContext := make(map[string][]string)
+	for _, m := range memories {
+		if skipNew {
+			if _, err := a.vectorStore.GetByID(ctx, m.ID); err != nil {
+				continue
+			}
+		}
+		contextID := m.Context
+		if contextID == "" {
+			contextID = DefaultContextID
+		}
+		byContext[contextID] = append(byContext[contextID], m.ID)
+	}
+	contextIDs := make([]string, 0, len(byContext))
+	for contextID := range byContext {
+		contextIDs = append(contextIDs, contextID)
+	}
+	sort.Strings(contextIDs)
+	for _, contextID := range contextIDs {
+		if msg := a.contextCapExceeded(ctx, contextID, byContext[contextID]...); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// importSummary totals a committed import. Memories rejected before the
+// import and imported memories that could not be stored count as failed.
+func importSummary(result *ImportPreview, invalid, storeFailures []string) *BatchOperationResult {
+	summary := &BatchOperationResult{
+		OperationType: "import",
+		Total:         len(result.Items) + len(invalid),
+		Failed:        len(invalid) + len(storeFailures),
+		Errors:        append(invalid, storeFailures...),
+		OperationID:   newRequestID("import"),
+	}
+	summary.Successful = summary.Total - summary.Failed
+	return summary
+}
+
+// storeHistoryDocuments writes the current version of each given memory history
+// into the vector store in batches of ImportBatchSize, counting memories that
+// did not exist there before and reporting progress after each batch. Memories
+// whose embedding is invalid are skipped and returned by ID; their version
+// history stays imported.
+func (a *App) storeHistoryDocuments(ctx context.Context, memoryIDs []string, progress *progressReporter) (map[string]error, error) {
+	if len(memoryIDs) == 0 {
+		return nil, nil
+	}
+
+	documents := make([]chromem.Document, 0, len(memoryIDs))
+	newContexts := make([]string, 0, len(memoryIDs)) // Context of each new document, "" for existing ones
+	for _, id := range memoryIDs {
+		history, err := a.versionMgr.GetHistory(id)
+		if err != nil {
+			return nil, err
+		}
+
+		contextID := history.Context
+		if contextID == "" {
+			contextID = DefaultContextID
+		}
+		client := ""
+		if len(history.Versions) > 0 {
+			client = history.Versions[len(history.Versions)-1].CreatedBy
+		}
 
-	jsonData, ok := jsonDataRaw.(string)
-	if !ok {
-		return mcp.NewToolResultError("json_data must be a string"), nil
+		// Keep exported metadata such as created_at; bookkeeping keys are recomputed
+		metadata := make(map[string]string, len(history.Metadata)+4)
+		for k, v := range history.Metadata {
+			switch k {
+			case "pruned_versions", "content_hash", "deleted_at":
+			default:
+				metadata[k] = v
+			}
+		}
+		if existing, err := a.vectorStore.GetByID(ctx, id); err != nil {
+			newContexts = append(newContexts, contextID)
+		} else {
+			newContexts = append(newContexts, "")
+			if created := existing.Metadata["created_at"]; created != "" {
+				metadata["created_at"] = created
+			}
+		}
+		if metadata["created_at"] == "" && !history.CreatedAt.IsZero() {
+			metadata["created_at"] = history.CreatedAt.UTC().Format(time.RFC3339)
+		}
+		metadata["context"] = contextID
+		metadata["client"] = client
+		delete(metadata, "tags")
+		if len(history.Tags) > 0 {
+			metadata["tags"] = EncodeTags(history.Tags)
+		}
+
+		documents = append(documents, chromem.Document{
+			ID:       id,
+			Content:  history.CurrentContent(),
+			Metadata: metadata,
+		})
 	}
 
-	// Parse and import
-	var export ExportData
-	if err := json.Unmarshal([]byte(jsonData), &export); err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Invalid JSON: %v", err)), nil
+	var storeErr error
+	failed := make(map[string]error)
+	for start := 0; start < len(documents); start += ImportBatchSize {
+		end := min(start+ImportBatchSize, len(documents))
+		batch, invalid, err := a.embedDocuments(ctx, documents[start:end])
+		if err == nil && len(batch) > 0 {
+			err = a.vectorStore.AddDocuments(ctx, batch, 4)
+		}
+		if err != nil {
+			storeErr = fmt.Errorf("stored %d of %d memories: %w", start, len(documents), err)
+			break
+		}
+		for id, err := range invalid {
+			failed[id] = err
+		}
+		for i, contextID := range newContexts[start:end] {
+			if _, skipped := invalid[documents[start+i].ID]; contextID == "" || skipped {
+				continue
+			}
+			if err := a.ctx.IncrementMemoryCount(contextID); err != nil {
+				a.logf(ctx, "Warning: Failed to update context count: %v", err)
+			}
+		}
+		progress.report(end)
+	}
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
 	}
 
-	summary := fmt.Sprintf("Import completed. Data parsed for %d memories.", len(export.Memories))
-	return mcp.NewToolResultText(summary), nil
+	return failed, storeErr
 }
 
-// getMemoryHistoryHandler handles memory history requests.
+// importResultText renders an import preview/result as a summary line followed by JSON.
+func importResultText(title string, result *ImportPreview) (*mcp.CallToolResult, error) {
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to encode import result: %v", err)), nil
+	}
+
+	summary := fmt.Sprintf("%s: %d memories (new: %d, identical: %d, fast_forward: %d, stale: %d, conflict: %d)",
+		title, len(result.Items),
+		result.Counts[ImportClassNew], result.Counts[ImportClassIdentical], result.Counts[ImportClassFastForward],
+		result.Counts[ImportClassStale], result.Counts[ImportClassConflict])
+	summary += fmt.Sprintf("\nSource format: %s", exportVersionName(result.SourceVersion))
+	if result.SourceVersion != ExportFormatVersion {
+		summary += fmt.Sprintf(" (upgraded to %s)", ExportFormatVersion)
+	}
+	if result.Summary != nil {
+		actions := make(map[string]int)
+		for _, item := range result.Items {
+			actions[item.Action]++
+		}
+		summary += fmt.Sprintf("\nCreated: %d, updated: %d, skipped: %d, failed: %d",
+			actions["imported"], actions["fast_forwarded"]+actions["replaced_with_incoming"]+actions["merged"]+actions["overwritten"],
+			actions["skipped"]+actions["skipped_existing"]+actions["kept_local"]+actions["unchanged"], result.Summary.Failed)
+	}
+
+	return mcp.NewToolResultText(summary + "\n\n" + string(data)), nil
+}
+
+// getMemoryHistoryHandler handles memory history requests. Versions are listed
+// oldest first with a content preview; only the newest MaxHistoryVersionsShown
+// are shown, and a single version can be fetched in full.
 func (a *App) getMemoryHistoryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args := request.Params.Arguments.(map[string]interface{})
+	args, _ := request.Params.Arguments.(map[string]interface{})
+	includeContent, _ := args["include_content"].(bool)
 
 	memoryID, ok := args["memory_id"].(string)
-	if !ok {
+	if memoryID = strings.TrimSpace(memoryID); !ok || memoryID == "" {
 		return mcp.NewToolResultError("memory_id is required and must be a string"), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("History for memory %s retrieved", memoryID)), nil
+	history, err := a.versionMgr.GetHistory(memoryID)
+	if err != nil || len(history.Versions) == 0 {
+		if _, err := a.vectorStore.GetByID(ctx, memoryID); err == nil {
+			return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' has no version history (it was stored before versions were recorded).", memoryID)), nil
+		}
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' not found", memoryID)), nil
+	}
+	if msg := a.historyAccessDenied(ctx, memoryID, history); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	versions := history.Versions
+	if v, ok := args["version"].(float64); ok {
+		n := int(v)
+		if n < 1 || n > len(versions) {
+			return mcp.NewToolResultError(fmt.Sprintf("Version %d is out of range: memory '%s' has versions 1-%d", n, memoryID, len(versions))), nil
+		}
+		versions = versions[n-1 : n]
+		includeContent = true
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("History for memory '%s' (%d versions, current: %d, context: %s)\n", memoryID, len(history.Versions), history.CurrentVersion, history.Context))
+	if pruned := history.Metadata["pruned_versions"]; pruned != "" {
+		sb.WriteString(fmt.Sprintf("%s older versions were pruned\n", pruned))
+	}
+	if hidden := len(versions) - MaxHistoryVersionsShown; hidden > 0 {
+		versions = versions[hidden:]
+		sb.WriteString(fmt.Sprintf("Showing the latest %d versions; %d older versions can be fetched with the version argument\n", MaxHistoryVersionsShown, hidden))
+	}
+	sb.WriteString("\n")
+
+	for _, v := range versions {
+		current := ""
+		if v.VersionNumber == history.CurrentVersion {
+			current = " (current)"
+		}
+		sb.WriteString(fmt.Sprintf("Version %d%s - %s by %s", v.VersionNumber, current, a.formatTime(v.CreatedAt), v.CreatedBy))
+		if v.ChangeNote != "" {
+			sb.WriteString(": " + v.ChangeNote)
+		}
+		sb.WriteString("\n")
+
+		content := v.Content
+		if !includeContent && len(content) > MaxHistoryPreviewLength {
+			content = content[:MaxHistoryPreviewLength-3] + "..."
+		}
+		sb.WriteString(content + "\n---\n")
+	}
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// historyAccessDenied returns an error message if the client making the
+// request in ctx may not use a memory with version history, judged by the
+// context it is stored in or, once deleted, the one its history records.
+func (a *App) historyAccessDenied(ctx context.Context, memoryID string, history *MemoryWithHistory) string {
+	if doc, err := a.vectorStore.GetByID(ctx, memoryID); err == nil {
+		return a.memoryAccessDenied(ctx, doc)
+	}
+	return a.contextAccessDenied(a.clientIDFrom(ctx), history.Context)
 }
 
-// restoreVersionHandler handles version restoration.
+// restoreVersionHandler handles version restoration. The memory's content is
+// replaced with the historical version and re-embedded, and the restoration is
+// recorded as a new version so it can itself be undone.
 func (a *App) restoreVersionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args := request.Params.Arguments.(map[string]interface{})
+	args, _ := request.Params.Arguments.(map[string]interface{})
 
 	memoryID, ok := args["memory_id"].(string)
-	if !ok {
+	if memoryID = strings.TrimSpace(memoryID); !ok || memoryID == "" {
 		return mcp.NewToolResultError("memory_id is required"), nil
 	}
 
 	versionNum, ok := args["version_number"].(float64)
-	if !ok {
+	if !ok || versionNum != float64(int(versionNum)) {
 		return mcp.NewToolResultError("version_number is required and must be an integer"), nil
 	}
 
-	reason := "Manual restoration"
-	if r, ok := args["restore_reason"].(string); ok && r != "" {
-		reason = r
+	history, err := a.versionMgr.GetHistory(memoryID)
+	if err != nil || len(history.Versions) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' has no version history", memoryID)), nil
+	}
+	if msg := a.historyAccessDenied(ctx, memoryID, history); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+	version, err := a.versionMgr.GetVersion(memoryID, int(versionNum))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Version %d is out of range: memory '%s' has versions 1-%d", int(versionNum), memoryID, len(history.Versions))), nil
+	}
+	content := version.Content
+	entry := AuditEntry{Tool: "restore_version", MemoryIDs: []string{memoryID}, ClientID: a.clientIDFrom(ctx), ContextID: history.Context, Status: "ok",
+		Details: fmt.Sprintf("restored version %d", int(versionNum))}
+
+	// Embed before touching the store, so a failed embedding leaves the memory
+	// and its history unchanged
+	embeddings, err := a.vectorStore.BatchEmbed(ctx, []string{content})
+	if err != nil {
+		entry.Status, entry.Details = "error", err.Error()
+		a.recordAudit(ctx, entry)
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to embed restored content: %v", err)), nil
+	}
+
+	// Keep the current metadata; a memory deleted from the store since is recreated
+	metadata := map[string]string{}
+	existing, err := a.vectorStore.GetByID(ctx, memoryID)
+	recreated := err != nil
+	if recreated {
+		contextID := history.Context
+		if contextID == "" {
+			contextID = DefaultContextID
+		}
+		metadata["context"] = contextID
+		metadata["client"] = a.clientIDFrom(ctx)
+		metadata["created_at"] = a.createdAt(ctx, memoryID)
+		if len(history.Tags) > 0 {
+			metadata["tags"] = EncodeTags(history.Tags)
+		}
+	} else {
+		for k, v := range existing.Metadata {
+			metadata[k] = v
+		}
+	}
+	entry.ContextID = metadata["context"]
+
+	// Delete the current document and re-add it with the historical content
+	if !recreated {
+		if err := a.vectorStore.Delete(ctx, nil, nil, memoryID); err != nil {
+			entry.Status, entry.Details = "error", err.Error()
+			a.recordAudit(ctx, entry)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to replace memory: %v", err)), nil
+		}
+	}
+	if err := a.vectorStore.AddDocument(ctx, chromem.Document{
+		ID:        memoryID,
+		Content:   content,
+		Metadata:  metadata,
+		Embedding: embeddings[0],
+	}); err != nil {
+		entry.Status, entry.Details = "error", err.Error()
+		a.recordAudit(ctx, entry)
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to restore memory: %v", err)), nil
+	}
+
+	changeNote := fmt.Sprintf("Restored from version %d", int(versionNum))
+	if reason, _ := args["restore_reason"].(string); strings.TrimSpace(reason) != "" {
+		changeNote += ": " + strings.TrimSpace(reason)
+	}
+	if err := a.versionMgr.AddVersion(memoryID, content, a.clientIDFrom(ctx), changeNote, metadata["context"], history.Tags); err != nil {
+		a.logf(ctx, "Warning: Failed to record restored version: %v", err)
+	}
+
+	if recreated {
+		if err := a.ctx.IncrementMemoryCount(metadata["context"]); err != nil {
+			a.logf(ctx, "Warning: Failed to update context count: %v", err)
+		}
+		a.activity.Record(metadata["context"], ActivityCounts{Created: 1})
+	} else {
+		a.activity.Record(metadata["context"], ActivityCounts{Updated: 1})
 	}
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	if recreated {
+		entry.Details += ", memory recreated"
+	}
+	a.recordAudit(ctx, entry)
 
-	return mcp.NewToolResultText(fmt.Sprintf("Restored memory %s to version %.0f: %s", memoryID, versionNum, reason)), nil
+	preview := content
+	if len(preview) > MaxSnippetLength {
+		preview = preview[:MaxSnippetLength-3] + "..."
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Restored memory '%s' to version %d: %s", memoryID, int(versionNum), preview)), nil
 }
 
-// searchAdvancedHandler handles advanced search with filters.
-func (a *App) searchAdvancedHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args := request.Params.Arguments.(map[string]interface{})
+// pruneVersionsHandler handles the prune_versions tool - trims the version
+// history of one memory, or of every memory, to the newest versions.
+func (a *App) pruneVersionsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]interface{})
+	memoryID, _ := args["memory_id"].(string)
+	memoryID = strings.TrimSpace(memoryID)
+	pruneAll, _ := args["prune_all_versions"].(bool)
 
-	filter := SearchFilter{}
+	keep := DefaultKeepVersions
+	if v, ok := args["keep_latest"].(float64); ok {
+		if v < 1 {
+			return mcp.NewToolResultError("keep_latest must be at least 1"), nil
+		}
+		keep = int(v)
+	}
 
-	// Parse context_id
-	if contextID, ok := args["context_id"].(string); ok && contextID != "" {
-		filter.ContextID = contextID
+	if pruneAll {
+		if a.hidesContexts(ctx) {
+			return mcp.NewToolResultError("Cannot prune every memory's versions: some contexts belong to other clients and are not shared with you. Prune your memories one by one with memory_id."), nil
+		}
+		memories, removed, err := a.versionMgr.PruneAllVersions(keep)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to prune versions: %v", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Pruned %d versions across %d memories (keeping the latest %d of each)", removed, memories, keep)), nil
 	}
 
-	// Parse tags
-	if tagsRaw, ok := args["tags"]; ok {
-		if tagsArr, ok := tagsRaw.([]interface{}); ok {
-			for _, tag := range tagsArr {
-				if tagStr, ok := tag.(string); ok {
-					filter.Tags = append(filter.Tags, tagStr)
-				}
-			}
+	if memoryID == "" {
+		return mcp.NewToolResultError("memory_id is required unless prune_all_versions is set"), nil
+	}
+	if history, err := a.versionMgr.GetHistory(memoryID); err == nil {
+		if msg := a.historyAccessDenied(ctx, memoryID, history); msg != "" {
+			return mcp.NewToolResultError(msg), nil
 		}
 	}
+	removed, err := a.versionMgr.PruneVersions(memoryID, keep)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to prune versions: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Pruned %d versions of memory '%s' (keeping the latest %d)", removed, memoryID, keep)), nil
+}
 
-	// Parse tag filter mode
-	if mode, ok := args["tag_filter_mode"].(string); ok {
+// searchAdvancedHandler handles advanced search with filters. With a query the
+// matches of the search mode (scoped to the context) are intersected with the
+// filter results and sorted by score, then recency; without one the filtered
+// memories are returned most recently updated first.
+func (a *App) searchAdvancedHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]interface{})
+
+	filter := SearchFilter{TagFilterMode: "any"}
+	if query, ok := args["query"].(string); ok {
+		filter.Query = strings.TrimSpace(query)
+	}
+	if contextID, ok := args["context_id"].(string); ok {
+		filter.ContextID = strings.TrimSpace(contextID)
+	}
+	if filter.ContextID != "" {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), filter.ContextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+	}
+	if createdBy, ok := args["created_by"].(string); ok {
+		filter.CreatedBy = strings.TrimSpace(createdBy)
+	}
+	if tagsArr, ok := args["tags"].([]interface{}); ok {
+		for _, tag := range tagsArr {
+			if tagStr, ok := tag.(string); ok && strings.TrimSpace(tagStr) != "" {
+				filter.Tags = append(filter.Tags, strings.TrimSpace(tagStr))
+			}
+		}
+	}
+	if mode, ok := args["tag_filter_mode"].(string); ok && mode != "" {
 		filter.TagFilterMode = mode
-	} else {
-		filter.TagFilterMode = "any"
 	}
+	if mode, ok := args["mode"].(string); ok {
+		filter.Mode = strings.TrimSpace(mode)
+	}
+
+	after, before, err := a.parseDateRange(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	filter.StartDate, filter.EndDate = after, before
 
-	// Parse max_results
-	filter.MaxResults = 50
+	filter.MaxResults = a.settings().DefaultSearchResults
 	if maxRaw, ok := args["max_results"].(float64); ok {
-		filter.MaxResults = int(maxRaw)
+		filter.MaxResults = max(1, min(int(maxRaw), MaxSearchResultsCap))
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Advanced search configured with %d tags, context: %s", len(filter.Tags), filter.ContextID)), nil
-}
+	if err := a.filterEngine.ValidateFilter(filter); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if filter.Mode == "" {
+		filter.Mode = SearchModeSemantic
+	}
 
-// batchOperationsHandler handles batch operations.
-func (a *App) batchOperationsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args := request.Params.Arguments.(map[string]interface{})
+	// With a query, or without a context, every match is collected first so
+	// the query ranking and the access check cannot leave too few
+	limit := filter.MaxResults
+	if filter.Query != "" || filter.ContextID == "" {
+		filter.MaxResults = 0
+	}
+	matches, err := a.filterEngine.FilterMemories(ctx, filter)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+	if filter.ContextID == "" {
+		clientID := a.clientIDFrom(ctx)
+		matches = slices.DeleteFunc(matches, func(m SearchResult) bool {
+			return a.contextAccessDenied(clientID, m.Context) != ""
+		})
+	}
 
-	operation, ok := args["operation"].(string)
-	if !ok {
-		return mcp.NewToolResultError("operation is required"), nil
+	if filter.Query != "" && len(matches) > 0 {
+		matches, err = a.rankByMode(ctx, filter, matches)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+		}
+		a.activity.Record(a.activityContext(ctx, filter.ContextID), ActivityCounts{Searches: 1})
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
 	}
 
-	_, ok = args["memories"]
-	if !ok {
-		return mcp.NewToolResultError("memories is required"), nil
+	if len(matches) == 0 {
+		return mcp.NewToolResultText("No memories match the search filters."), nil
 	}
 
-	switch operation {
-	case "create", "delete", "add_tags", "remove_tags":
-		return mcp.NewToolResultText(fmt.Sprintf("Batch %s operation prepared", operation)), nil
-	default:
-		return mcp.NewToolResultError(fmt.Sprintf("Unknown operation: %s", operation)), nil
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Found %d memories:\n\n", len(matches)))
+	for _, res := range matches {
+		sb.WriteString(fmt.Sprintf("[%s] (", res.ID))
+		if filter.Query != "" {
+			sb.WriteString(formatSearchScore(filter.Mode, res.Similarity) + ", ")
+		}
+		sb.WriteString("Context: " + res.Context)
+		if len(res.Tags) > 0 {
+			sb.WriteString(", Tags: " + strings.Join(res.Tags, ", "))
+		}
+		if !res.CreatedAt.IsZero() {
+			sb.WriteString(", Created: " + a.formatTime(res.CreatedAt))
+		}
+		if res.CurrentVersion > 1 {
+			sb.WriteString(", Updated: " + a.formatTime(res.UpdatedAt))
+		}
+		sb.WriteString(fmt.Sprintf(")\n%s\n---\n", res.Content))
 	}
+
+	return mcp.NewToolResultText(sb.String()), nil
 }
 
+// rankBySimilarity runs the semantic query, scoped to the filter's context,
+// and keeps the candidates it matched, ordered by similarity and then recency.
+func (a *App) rankBySimilarity(ctx context.Context, filter SearchFilter, candidates []SearchResult) ([]SearchResult, error) {
+	var where map[string]string
+	if filter.ContextID != "" {
+		where = map[string]string{"context": filter.ContextID}
+	}
 
+	// Every document in scope is scored so that no candidate is cut off by
+	// documents the filters exclude
+	results, err := a.vectorStore.Query(ctx, filter.Query, a.vectorStore.Count(), where, nil)
+	if err != nil {
+		return nil, err
+	}
+	similarity := make(map[string]float32, len(results))
+	for _, res := range results {
+		similarity[res.ID] = res.Similarity
+	}
 
-// getContextStatsHandler handles context statistics requests.
+	ranked := candidates[:0]
+	for _, c := range candidates {
+		if sim, ok := similarity[c.ID]; ok {
+			c.Similarity = sim
+			ranked = append(ranked, c)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Similarity != ranked[j].Similarity {
+			return ranked[i].Similarity > ranked[j].Similarity
+		}
+		return ranked[i].UpdatedAt.After(ranked[j].UpdatedAt)
+	})
+	return ranked, nil
+}
+
+// getContextStatsHandler handles context statistics requests. Statistics come
+// from the memories in the vector store; the memory count the context manager
+// keeps is reported next to them as registered_memory_count. With context_id
+// "all" it returns the statistics of every context keyed by ID.
 func (a *App) getContextStatsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args := request.Params.Arguments.(map[string]interface{})
+	args, _ := request.Params.Arguments.(map[string]interface{})
 
 	contextID, ok := args["context_id"].(string)
-	if !ok {
+	if contextID = strings.TrimSpace(contextID); !ok || contextID == "" {
 		return mcp.NewToolResultError("context_id is required"), nil
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Statistics retrieved for context: %s", contextID)), nil
+	clientID := a.clientIDFrom(ctx)
+	if contextID != "all" {
+		c, err := a.ctx.GetContext(contextID)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Context '%s' not found", contextID)), nil
+		}
+		if msg := a.contextAccessDenied(clientID, contextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+		stats, err := a.contextStats(ctx, c)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to collect statistics: %v", err)), nil
+		}
+		data, err := json.MarshalIndent(stats, "", "  ")
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to encode statistics: %v", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Context '%s': %d memories, %d tags, %d bytes\n\n%s",
+			contextID, stats["memory_count"], len(stats["unique_tags"].([]string)), stats["total_bytes"], string(data))), nil
+	}
+
+	all := make(map[string]map[string]interface{})
+	memories, totalBytes := 0, 0
+	for _, c := range a.ctx.ListContexts() {
+		if a.contextAccessDenied(clientID, c.ID) != "" {
+			continue
+		}
+		stats, err := a.contextStats(ctx, c)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to collect statistics for '%s': %v", c.ID, err)), nil
+		}
+		all[c.ID] = stats
+		memories += stats["memory_count"].(int)
+		totalBytes += stats["total_bytes"].(int)
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to encode statistics: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("%d contexts: %d memories, %d bytes\n\n%s",
+		len(all), memories, totalBytes, string(data))), nil
+}
+
+// contextStats returns the statistics of one context for get_context_stats.
+func (a *App) contextStats(ctx context.Context, c *Context) (map[string]interface{}, error) {
+	stats, err := a.filterEngine.GetContextStats(ctx, c.ID)
+	if err != nil {
+		return nil, err
+	}
+	stats["name"] = c.Name
+	stats["registered_memory_count"] = c.MemoryCount
+	return stats, nil
 }
@@ -33,6 +33,7 @@ type ExportData struct {
 	Contexts    map[string]*Context    `json:"contexts"`
 	Tags        map[string]*Tag        `json:"tags"`
 	Version     string                 `json:"version"` // Export format version
+	EmbeddingProvider string           `json:"embedding_provider,omitempty"` // Provider of the exporting brain's embeddings (since 1.1)
 }
 
 // BatchOperation represents a batch operation on memories.
@@ -54,6 +55,14 @@ type BatchOperationResult struct {
 	Failed        int    `json:"failed"`       // Failed items
 	Errors        []string `json:"errors"`    // Error messages
 	OperationID   string `json:"operation_id"` // Unique operation ID
+	Items         []BatchItemResult `json:"items,omitempty"` // Outcome per item
+}
+
+// BatchItemResult is the outcome of one item of a batch operation.
+type BatchItemResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`          // "ok" or "failed"
+	Error  string `json:"error,omitempty"` // Why the item failed
 }
 
 // SearchFilter represents filtering criteria for searching memories.
@@ -66,6 +75,7 @@ type SearchFilter struct {
 	CreatedBy       string    `json:"created_by"`      // Filter by client ID
 	MaxResults      int       `json:"max_results"`     // Limit results
 	TagFilterMode   string    `json:"tag_filter_mode"` // "all" (AND) or "any" (OR)
+	Mode            string    `json:"mode"`            // How the query ranks: "semantic", "keyword" or "hybrid"
 }
 
 // SearchResult represents a search result with metadata.
@@ -78,5 +88,59 @@ type SearchResult struct {
 	CurrentVersion int              `json:"current_version"`
 	CreatedAt     time.Time        `json:"created_at"`
 	UpdatedAt     time.Time        `json:"updated_at"`
+	CreatedBy     string            `json:"created_by"` // Client that created the memory
 	Metadata      map[string]string `json:"metadata"`
 }
+
+// Import classifications for incoming memories relative to local history.
+const (
+	ImportClassNew         = "new"          // No local history for this ID
+	ImportClassIdentical   = "identical"    // Same history on both sides
+	ImportClassFastForward = "fast_forward" // Incoming strictly extends local history
+	ImportClassStale       = "stale"        // Local strictly extends incoming history
+	ImportClassConflict    = "conflict"     // Histories diverged after a common prefix
+)
+
+// Conflict resolution strategies for diverged histories.
+const (
+	MergeKeepLocal    = "keep_local"
+	MergeKeepIncoming = "keep_incoming"
+	MergeAppend       = "merge"
+)
+
+// Strategies for incoming memories whose ID already exists locally.
+const (
+	ConflictSkip      = "skip"      // Leave the local memory untouched
+	ConflictOverwrite = "overwrite" // Replace the local memory and its history
+	ConflictRename    = "rename"    // Import under a new ID with an "-imported" suffix
+)
+
+// ExportFormatVersion is the version written to exports. Older versions are
+// upgraded on import, see exportFormats.
+const ExportFormatVersion = "1.1"
+
+// ImportClassification describes how one incoming memory relates to local history.
+type ImportClassification struct {
+	ID               string `json:"id"`
+	Class            string `json:"class"`             // One of the ImportClass* values
+	LocalVersions    int    `json:"local_versions"`    // Versions in local history
+	IncomingVersions int    `json:"incoming_versions"` // Versions in incoming history
+	CommonVersions   int    `json:"common_versions"`   // Length of the shared prefix
+	Action           string `json:"action,omitempty"`  // What commit did ("imported", "skipped", ...)
+	RenamedFrom      string `json:"renamed_from,omitempty"` // Original ID when imported under a new one
+}
+
+// ImportPreview summarizes an import before (or after) it is applied.
+type ImportPreview struct {
+	Counts map[string]int         `json:"counts"` // Number of memories per class
+	Items  []ImportClassification `json:"items"`  // Per-memory classification
+	Summary *BatchOperationResult `json:"summary,omitempty"` // Created/skipped/failed totals of a committed import
+	SourceVersion string         `json:"source_version"` // Format version the export was written in, "" if unversioned
+}
+
+// ImportStrategy selects what to do with each class of incoming memory.
+type ImportStrategy struct {
+	New         string `json:"new"`          // "import" (default) or "skip"
+	FastForward string `json:"fast_forward"` // "apply" (default) or "skip"
+	Conflict    string `json:"conflict"`     // keep_local (default), keep_incoming, or merge
+}
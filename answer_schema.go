@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// AnswerSchema is the JSON schema subset accepted by ask_brain's answer_schema
+// argument: an object whose properties are strings, numbers, integers,
+// booleans, arrays or nested objects.
+type AnswerSchema struct {
+	Type        string                   `json:"type"`
+	Description string                   `json:"description,omitempty"`
+	Properties  map[string]*AnswerSchema `json:"properties,omitempty"`
+	Required    []string                 `json:"required,omitempty"`
+	Items       *AnswerSchema            `json:"items,omitempty"`
+	Enum        []string                 `json:"enum,omitempty"`
+}
+
+// ParseAnswerSchema accepts the schema either as a JSON object or as a JSON
+// string and checks that it stays within the supported subset.
+func ParseAnswerSchema(raw any) (*AnswerSchema, error) {
+	var data []byte
+	switch v := raw.(type) {
+	case string:
+		data = []byte(v)
+	default:
+		var err error
+		if data, err = json.Marshal(v); err != nil {
+			return nil, fmt.Errorf("invalid answer_schema: %w", err)
+		}
+	}
+
+	var schema AnswerSchema
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return nil, fmt.Errorf("invalid answer_schema: %w", err)
+	}
+	if schema.Type != "object" {
+		return nil, fmt.Errorf("invalid answer_schema: top-level type must be \"object\"")
+	}
+	if err := schema.check("$"); err != nil {
+		return nil, fmt.Errorf("invalid answer_schema: %w", err)
+	}
+	return &schema, nil
+}
+
+// check verifies that the schema only uses supported keywords and types.
+func (s *AnswerSchema) check(path string) error {
+	switch s.Type {
+	case "string", "number", "integer", "boolean":
+		if len(s.Enum) > 0 && s.Type != "string" {
+			return fmt.Errorf("%s: enum is only supported for strings", path)
+		}
+	case "array":
+		if s.Items == nil {
+			return fmt.Errorf("%s: array requires items", path)
+		}
+		return s.Items.check(path + "[]")
+	case "object":
+		if len(s.Properties) == 0 {
+			return fmt.Errorf("%s: object requires properties", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := s.Properties[name]; !ok {
+				return fmt.Errorf("%s: required property %q is not defined", path, name)
+			}
+		}
+		for name, prop := range s.Properties {
+			if prop == nil {
+				return fmt.Errorf("%s.%s: missing schema", path, name)
+			}
+			if err := prop.check(path + "." + name); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%s: unsupported type %q", path, s.Type)
+	}
+	return nil
+}
+
+// GenaiSchema converts the schema to Gemini's structured output schema.
+func (s *AnswerSchema) GenaiSchema() *genai.Schema {
+	out := &genai.Schema{
+		Type:        genai.Type(strings.ToUpper(s.Type)),
+		Description: s.Description,
+		Required:    s.Required,
+		Enum:        s.Enum,
+	}
+	if s.Items != nil {
+		out.Items = s.Items.GenaiSchema()
+	}
+	if len(s.Properties) > 0 {
+		out.Properties = make(map[string]*genai.Schema, len(s.Properties))
+		for name, prop := range s.Properties {
+			out.Properties[name] = prop.GenaiSchema()
+		}
+	}
+	return out
+}
+
+// String returns the schema as indented JSON for inclusion in prompts.
+func (s *AnswerSchema) String() string {
+	data, _ := json.MarshalIndent(s, "", "  ")
+	return string(data)
+}
+
+// ValidateAnswer parses a model response as JSON and validates it against the
+// schema. Markdown code fences around the JSON are tolerated. It returns the
+// decoded value and the list of violations (empty when the answer conforms).
+func (s *AnswerSchema) ValidateAnswer(text string) (any, []string) {
+	text = strings.TrimSpace(text)
+	if strings.HasPrefix(text, "```") {
+		text = strings.TrimPrefix(text, "```json")
+		text = strings.TrimPrefix(text, "```")
+		text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	}
+
+	var value any
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, []string{fmt.Sprintf("response is not valid JSON: %v", err)}
+	}
+
+	var violations []string
+	s.validate(value, "$", &violations)
+	return value, violations
+}
+
+// validate appends a violation for every place where value does not match the schema.
+func (s *AnswerSchema) validate(value any, path string, violations *[]string) {
+	fail := func(format string, args ...any) {
+		*violations = append(*violations, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	switch s.Type {
+	case "string":
+		str, ok := value.(string)
+		if !ok {
+			fail("expected string, got %s", jsonTypeName(value))
+			return
+		}
+		if len(s.Enum) > 0 && !slices.Contains(s.Enum, str) {
+			fail("value %q is not one of %s", str, strings.Join(s.Enum, ", "))
+		}
+	case "number":
+		if _, ok := value.(float64); !ok {
+			fail("expected number, got %s", jsonTypeName(value))
+		}
+	case "integer":
+		n, ok := value.(float64)
+		if !ok || n != math.Trunc(n) {
+			fail("expected integer, got %s", jsonTypeName(value))
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			fail("expected boolean, got %s", jsonTypeName(value))
+		}
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			fail("expected array, got %s", jsonTypeName(value))
+			return
+		}
+		for i, item := range items {
+			s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i), violations)
+		}
+	case "object":
+		obj, ok := value.(map[string]any)
+		if !ok {
+			fail("expected object, got %s", jsonTypeName(value))
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				fail("unexpected property %q", name)
+				continue
+			}
+			// null is accepted for optional properties
+			if obj[name] == nil && !slices.Contains(s.Required, name) {
+				continue
+			}
+			prop.validate(obj[name], path+"."+name, violations)
+		}
+	}
+}
+
+// jsonTypeName names the JSON type of a decoded value for error messages.
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
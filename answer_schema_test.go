@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// addressSchema is the answer_schema used by the tests.
+var addressSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"address":    map[string]any{"type": "string"},
+		"confidence": map[string]any{"type": "number"},
+		"floors":     map[string]any{"type": "integer"},
+		"kind":       map[string]any{"type": "string", "enum": []any{"office", "home"}},
+		"tags":       map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+	},
+	"required": []any{"address", "confidence"},
+}
+
+func TestParseAnswerSchema(t *testing.T) {
+	if _, err := ParseAnswerSchema(addressSchema); err != nil {
+		t.Fatalf("ParseAnswerSchema(object): %v", err)
+	}
+	if _, err := ParseAnswerSchema(`{"type": "object", "properties": {"ok": {"type": "boolean"}}}`); err != nil {
+		t.Fatalf("ParseAnswerSchema(string): %v", err)
+	}
+
+	for _, tc := range []struct {
+		name, schema, want string
+	}{
+		{"not JSON", `{"type":`, "invalid answer_schema"},
+		{"top level array", `{"type": "array", "items": {"type": "string"}}`, "top-level type must be"},
+		{"no properties", `{"type": "object"}`, "object requires properties"},
+		{"array without items", `{"type": "object", "properties": {"a": {"type": "array"}}}`, "$.a: array requires items"},
+		{"undefined required", `{"type": "object", "properties": {"a": {"type": "string"}}, "required": ["b"]}`, `required property "b" is not defined`},
+		{"unsupported type", `{"type": "object", "properties": {"a": {"type": "date"}}}`, `unsupported type "date"`},
+		{"enum on number", `{"type": "object", "properties": {"a": {"type": "number", "enum": ["1"]}}}`, "enum is only supported for strings"},
+		{"nested", `{"type": "object", "properties": {"a": {"type": "array", "items": {"type": "object", "properties": {"b": {"type": "null"}}}}}}`, "$.a[].b: unsupported type"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseAnswerSchema(tc.schema)
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateAnswer(t *testing.T) {
+	schema, err := ParseAnswerSchema(addressSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, answer string
+		violations   []string
+	}{
+		{"conforming", `{"address": "1 Main St", "confidence": 0.9, "floors": 3, "kind": "office", "tags": ["hq"]}`, nil},
+		{"code fence", "```json\n{\"address\": \"1 Main St\", \"confidence\": 1}\n```", nil},
+		{"optional null", `{"address": "1 Main St", "confidence": 1, "kind": null}`, nil},
+		{"not JSON", `The address is 1 Main St.`, []string{"response is not valid JSON"}},
+		{"missing required", `{"address": "1 Main St"}`, []string{`$: missing required property "confidence"`}},
+		{"wrong type", `{"address": 1, "confidence": "high"}`, []string{"$.address: expected string, got number", "$.confidence: expected number, got string"}},
+		{"fraction for integer", `{"address": "a", "confidence": 1, "floors": 2.5}`, []string{"$.floors: expected integer"}},
+		{"enum", `{"address": "a", "confidence": 1, "kind": "castle"}`, []string{`$.kind: value "castle" is not one of office, home`}},
+		{"array item", `{"address": "a", "confidence": 1, "tags": ["ok", 2]}`, []string{"$.tags[1]: expected string, got number"}},
+		{"unexpected property", `{"address": "a", "confidence": 1, "zip": "123"}`, []string{`$: unexpected property "zip"`}},
+		{"not an object", `["a"]`, []string{"$: expected object, got array"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, violations := schema.ValidateAnswer(tc.answer)
+			if len(violations) != len(tc.violations) {
+				t.Fatalf("violations = %q, want %q", violations, tc.violations)
+			}
+			for i, want := range tc.violations {
+				if !strings.HasPrefix(violations[i], want) {
+					t.Errorf("violation %d = %q, want prefix %q", i, violations[i], want)
+				}
+			}
+		})
+	}
+}
+
+// askWithSchema stores a memory and asks ask_brain with addressSchema, the
+// fake LLM giving the replies in turn and then repeating the last one.
+func askWithSchema(t *testing.T, replies ...string) (*mcp.CallToolResult, *fakeLLM) {
+	t.Helper()
+	ta := newTestApp(t, nil)
+	ta.remember(t, "office", "The office is at 1 Main St", nil)
+	var n atomic.Int32
+	llm := &fakeLLM{reply: func(string) (string, error) {
+		i := int(n.Add(1)) - 1
+		return replies[min(i, len(replies)-1)], nil
+	}}
+	ta.llm = llm
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = map[string]any{"question": "Where is the office?", "answer_schema": addressSchema}
+	result, err := ta.askBrainHandler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("ask_brain: %v", err)
+	}
+	return result, llm
+}
+
+func TestAskBrainConformingAnswer(t *testing.T) {
+	result, llm := askWithSchema(t, `{"address": "1 Main St", "confidence": 0.95}`)
+	if result.IsError {
+		t.Fatalf("ask_brain failed: %s", resultText(result))
+	}
+	if n := len(llm.Prompts()); n != 1 {
+		t.Errorf("LLM called %d times, want 1", n)
+	}
+	value, ok := result.StructuredContent.(map[string]any)
+	if !ok || value["address"] != "1 Main St" || value["confidence"] != 0.95 {
+		t.Errorf("structured content = %#v", result.StructuredContent)
+	}
+	if !strings.Contains(llm.Prompts()[0], `"confidence"`) {
+		t.Error("the prompt does not include the schema")
+	}
+}
+
+func TestAskBrainFixableAnswer(t *testing.T) {
+	result, llm := askWithSchema(t, `{"address": "1 Main St", "confidence": "very"}`, `{"address": "1 Main St", "confidence": 0.8}`)
+	if result.IsError {
+		t.Fatalf("ask_brain failed: %s", resultText(result))
+	}
+	prompts := llm.Prompts()
+	if len(prompts) != 2 {
+		t.Fatalf("LLM called %d times, want 2", len(prompts))
+	}
+	if !strings.Contains(prompts[1], "$.confidence: expected number, got string") || !strings.Contains(prompts[1], `"confidence": "very"`) {
+		t.Errorf("the retry prompt does not name the violation and the previous response:\n%s", prompts[1])
+	}
+	if value, _ := result.StructuredContent.(map[string]any); value["confidence"] != 0.8 {
+		t.Errorf("structured content = %#v, want the corrected answer", result.StructuredContent)
+	}
+}
+
+func TestAskBrainUnfixableAnswer(t *testing.T) {
+	result, llm := askWithSchema(t, "I think it is on Main St.", "Main St, probably.")
+	if !result.IsError {
+		t.Fatalf("ask_brain succeeded with garbage output: %s", resultText(result))
+	}
+	text := resultText(result)
+	if !strings.HasPrefix(text, "Schema violation:") || !strings.Contains(text, "Raw model output:\nMain St, probably.") {
+		t.Errorf("error = %q, want a schema violation with the raw output", text)
+	}
+	if n := len(llm.Prompts()); n != 2 {
+		t.Errorf("LLM called %d times, want 2 (one retry)", n)
+	}
+}
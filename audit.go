@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// AuditEntry is a single line of the audit log.
+type AuditEntry struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"` // Correlates the entry with logs and traces
+	Tool      string    `json:"tool"`                 // Tool or subsystem that performed the mutation
+	MemoryIDs []string  `json:"memory_ids,omitempty"` // Affected memories
+	ClientID  string    `json:"client_id,omitempty"`
+	ContextID string    `json:"context_id,omitempty"`
+	Status    string    `json:"status"`            // "ok" or "error"
+	Details   string    `json:"details,omitempty"` // Free-form description or error message
+}
+
+// AuditLogger appends mutation records as JSON lines to a file.
+// A nil *AuditLogger is valid and discards all entries.
+type AuditLogger struct {
+	mu     sync.Mutex
+	path   string
+	file   *os.File    // Open for appending, nil when the log is encrypted
+	cipher *FileCipher // Encrypts the log when set
+}
+
+// NewAuditLogger opens (or creates) the audit log at path for appending. With
+// a cipher the log is encrypted as a whole, so every entry rewrites the file
+// through cipher instead of appending to an open file.
+func NewAuditLogger(path string, cipher *FileCipher) (*AuditLogger, error) {
+	if cipher != nil {
+		if _, err := cipher.ReadFile(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		return &AuditLogger{path: path, cipher: cipher}, nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &AuditLogger{path: path, file: file}, nil
+}
+
+// auditLogPath returns the configured audit log, audit.log in dataDir by default.
+func auditLogPath(cfg *Config, dataDir string) string {
+	if cfg != nil && cfg.AuditLogPath != "" {
+		return cfg.AuditLogPath
+	}
+	return filepath.Join(dataDir, "audit.log")
+}
+
+// Record appends an entry, filling in the timestamp if it is unset.
+func (al *AuditLogger) Record(entry AuditEntry) error {
+	if al == nil {
+		return nil
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now().UTC()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %w", err)
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	if al.cipher != nil {
+		err = al.cipher.AppendFile(al.path, append(data, '\n'), 0600)
+	} else {
+		_, err = al.file.Write(append(data, '\n'))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// Rotate renames the current log to <path>.<timestamp> and continues in a
+// fresh file at path. It returns the name of the rotated file.
+func (al *AuditLogger) Rotate(now time.Time) (string, error) {
+	if al == nil {
+		return "", fmt.Errorf("audit log is not enabled")
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	rotated := fmt.Sprintf("%s.%s", al.path, now.UTC().Format("20060102T150405Z"))
+	if _, err := os.Stat(rotated); err == nil {
+		return "", fmt.Errorf("%s already exists", rotated)
+	}
+	if al.cipher != nil {
+		if err := os.Rename(al.path, rotated); err != nil {
+			return "", fmt.Errorf("failed to rename audit log: %w", err)
+		}
+		return rotated, nil
+	}
+	if err := al.file.Close(); err != nil {
+		return "", fmt.Errorf("failed to close audit log: %w", err)
+	}
+	renameErr := os.Rename(al.path, rotated)
+
+	// Reopen even if the rename failed, so later entries are not lost
+	file, err := os.OpenFile(al.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", fmt.Errorf("failed to reopen audit log: %w", err)
+	}
+	al.file = file
+	if renameErr != nil {
+		return "", fmt.Errorf("failed to rename audit log: %w", renameErr)
+	}
+	return rotated, nil
+}
+
+// recordAudit writes an audit entry tagged with the request ID of ctx and
+// adds it to the request's trace.
+func (a *App) recordAudit(ctx context.Context, entry AuditEntry) {
+	entry.RequestID = RequestIDFrom(ctx)
+	traceLog(ctx, "audit", "Audit %s %s: %d memories (%s)", entry.Tool, entry.Status, len(entry.MemoryIDs), entry.Details)
+	if err := a.audit.Record(entry); err != nil {
+		a.logf(ctx, "Warning: Failed to write audit log: %v", err)
+	}
+}
+
+// Close closes the underlying file.
+func (al *AuditLogger) Close() error {
+	if al == nil || al.file == nil {
+		return nil
+	}
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	return al.file.Close()
+}
+
+// rotateAuditLogHandler handles the rotate_audit_log tool.
+func (a *App) rotateAuditLogHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	rotated, err := a.audit.Rotate(time.Now())
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to rotate audit log: %v", err)), nil
+	}
+	a.recordAudit(ctx, AuditEntry{Tool: "rotate_audit_log", ClientID: a.clientIDFrom(ctx), Status: "ok", Details: "previous log: " + rotated})
+	return mcp.NewToolResultText(fmt.Sprintf("Audit log rotated. Previous entries are in %s.", rotated)), nil
+}
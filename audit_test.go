@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// withAuditLog gives ta an audit log in its data directory.
+func (ta *testApp) withAuditLog(t *testing.T) {
+	t.Helper()
+	audit, err := NewAuditLogger(filepath.Join(ta.dataDir, "audit.log"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { audit.Close() })
+	ta.audit = audit
+}
+
+// auditEntries returns the entries of ta's audit log written by tool.
+func auditEntries(t *testing.T, ta *testApp, tool string) []AuditEntry {
+	t.Helper()
+	file, err := os.Open(filepath.Join(ta.dataDir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	var entries []AuditEntry
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("audit line %q: %v", scanner.Text(), err)
+		}
+		if entry.Tool == tool {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// failingWrites is a VectorBackend whose document writes fail.
+type failingWrites struct {
+	VectorBackend
+}
+
+func (failingWrites) AddDocument(context.Context, chromem.Document) error {
+	return errors.New("disk full")
+}
+
+func (failingWrites) AddDocuments(context.Context, []chromem.Document, int) error {
+	return errors.New("disk full")
+}
+
+func TestMemoryMutationsAreAudited(t *testing.T) {
+	tests := []struct {
+		tool string
+		run  func(ta *testApp) (string, bool)
+		id   string
+	}{
+		{"clone_memory", func(ta *testApp) (string, bool) {
+			return call(t, ta.cloneMemoryHandler, map[string]any{"source_id": "boiler", "new_id": "boiler-copy"})
+		}, "boiler-copy"},
+		{"move_memory", func(ta *testApp) (string, bool) {
+			return call(t, ta.moveMemoryHandler, map[string]any{"id": "boiler", "target_context_id": "home"})
+		}, "boiler"},
+		{"restore_memory", func(ta *testApp) (string, bool) {
+			return call(t, ta.restoreMemoryHandler, map[string]any{"id": "trashed"})
+		}, "trashed"},
+		{"restore_version", func(ta *testApp) (string, bool) {
+			return call(t, ta.restoreVersionHandler, map[string]any{"memory_id": "boiler", "version_number": float64(1)})
+		}, "boiler"},
+	}
+	for _, tt := range tests {
+		for _, failing := range []bool{false, true} {
+			name := tt.tool
+			if failing {
+				name += "/failing"
+			}
+			t.Run(name, func(t *testing.T) {
+				ta := newTestApp(t, func(cfg *Config) { cfg.SoftDelete = true })
+				ta.withAuditLog(t)
+				if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "home", "name": "Home"}); isErr {
+					t.Fatalf("create_context: %s", text)
+				}
+				ta.remember(t, "boiler", "the boiler is serviced every autumn", nil)
+				ta.remember(t, "boiler", "the boiler is serviced every spring", nil)
+				ta.remember(t, "trashed", "the old fridge went to the tip", nil)
+				if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "trashed"}); isErr {
+					t.Fatalf("delete_memory: %s", text)
+				}
+				if failing {
+					ta.vectorStore = failingWrites{ta.vectorStore}
+				}
+
+				text, isErr := tt.run(ta)
+				if isErr != failing {
+					t.Fatalf("%s failed = %v: %s", tt.tool, isErr, text)
+				}
+				entries := auditEntries(t, ta, tt.tool)
+				want := "ok"
+				if failing {
+					want = "error"
+				}
+				if len(entries) != 1 || entries[0].Status != want || len(entries[0].MemoryIDs) != 1 || entries[0].MemoryIDs[0] != tt.id || entries[0].ClientID == "" {
+					t.Errorf("audit entries = %+v, want one %s entry for %s", entries, want, tt.id)
+				}
+			})
+		}
+	}
+}
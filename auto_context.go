@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// contextClassificationSchema is the structured reply requested from the LLM
+// when choosing a context for a memory.
+var contextClassificationSchema = &AnswerSchema{
+	Type: "object",
+	Properties: map[string]*AnswerSchema{
+		"context_id": {Type: "string", Description: "ID of the best fitting context, as shown in brackets"},
+		"confidence": {Type: "number", Description: "How well the memory fits that context, from 0 (not at all) to 1 (certainly)"},
+	},
+	Required: []string{"context_id", "confidence"},
+}
+
+// contextClassificationPrompt asks the LLM to pick the context among
+// contexts that fits content best.
+func contextClassificationPrompt(content string, contexts []*Context) string {
+	var sb strings.Builder
+	sb.WriteString("Choose the context the memory below belongs to. Pick exactly one of the contexts listed, using the ID shown in brackets, and rate your confidence from 0 to 1.\n\nContexts:\n")
+	for _, c := range contexts {
+		if c.Description != "" {
+			sb.WriteString(fmt.Sprintf("- [%s] %s: %s\n", c.ID, c.Name, c.Description))
+		} else {
+			sb.WriteString(fmt.Sprintf("- [%s] %s\n", c.ID, c.Name))
+		}
+	}
+	sb.WriteString("\nMemory:\n" + content + "\n")
+	sb.WriteString(fmt.Sprintf("\nRespond ONLY with a JSON object conforming to this JSON schema:\n%s", contextClassificationSchema))
+	return sb.String()
+}
+
+// parseContextClassification reads the context and confidence the LLM chose.
+// A reply naming a context that is not among contexts is an error.
+func parseContextClassification(raw string, contexts []*Context) (string, float64, error) {
+	value, violations := contextClassificationSchema.ValidateAnswer(raw)
+	if len(violations) > 0 {
+		return "", 0, fmt.Errorf("reply does not match the schema: %s", strings.Join(violations, "; "))
+	}
+	reply := value.(map[string]any)
+	contextID := strings.Trim(strings.TrimSpace(reply["context_id"].(string)), "[]")
+	confidence := reply["confidence"].(float64)
+	for _, c := range contexts {
+		if c.ID == contextID {
+			return contextID, min(max(confidence, 0), 1), nil
+		}
+	}
+	return "", 0, fmt.Errorf("reply names unknown context %q", contextID)
+}
+
+// classifyContext asks the LLM which of the contexts the calling client may
+// use fits content best. It returns fallback, and a note explaining why, when
+// there is nothing to choose from, the call or its reply fails, or the LLM is
+// less than AutoContextMinConfidence sure. chosen reports whether the LLM's
+// choice was taken.
+func (a *App) classifyContext(ctx context.Context, content, fallback string) (contextID string, chosen bool, note string) {
+	var contexts []*Context
+	for _, c := range a.ctx.ListContexts() {
+		if a.contextAccessDenied(a.clientIDFrom(ctx), c.ID) == "" {
+			contexts = append(contexts, c)
+		}
+	}
+	if len(contexts) < 2 {
+		return fallback, false, "only one context is available"
+	}
+
+	raw, err := a.generate(ctx, contextClassificationPrompt(content, contexts), &GenerateOptions{Schema: contextClassificationSchema})
+	if err != nil {
+		a.logf(ctx, "Warning: Context classification failed: %v", err)
+		return fallback, false, "the LLM call failed"
+	}
+	contextID, confidence, err := parseContextClassification(raw, contexts)
+	if err != nil {
+		a.logf(ctx, "Warning: Context classification failed: %v", err)
+		return fallback, false, "the LLM reply could not be used"
+	}
+	if confidence < AutoContextMinConfidence {
+		return fallback, false, fmt.Sprintf("the LLM was not confident enough (best guess '%s', confidence %.2f)", contextID, confidence)
+	}
+	return contextID, true, fmt.Sprintf("confidence %.2f", confidence)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// newAutoContextApp returns a testApp with the contexts "garden" and "work"
+// besides the default one, answering prompts with llm.
+func newAutoContextApp(t *testing.T, configure func(cfg *Config), llm *fakeLLM) *testApp {
+	t.Helper()
+	ta := newTestApp(t, configure)
+	ta.llm = llm
+	for _, args := range []map[string]any{
+		{"id": "garden", "name": "Garden", "description": "plants, beds and the compost heap"},
+		{"id": "work", "name": "Work"},
+	} {
+		if text, isErr := call(t, ta.createContextHandler, args); isErr {
+			t.Fatalf("create_context: %s", text)
+		}
+	}
+	return ta
+}
+
+func TestRememberAutoContext(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		reply      string
+		err        error
+		want       string
+		classified bool
+		note       string
+	}{
+		{"confident", `{"context_id": "garden", "confidence": 0.9}`, nil, "garden", true, "The context was chosen automatically (confidence 0.90)."},
+		{"bracketed ID in a code fence", "```json\n{\"context_id\": \"[garden]\", \"confidence\": 0.75}\n```", nil, "garden", true, "(confidence 0.75)"},
+		{"confidence above 1", `{"context_id": "garden", "confidence": 7}`, nil, "garden", true, "(confidence 1.00)"},
+		{"unsure", `{"context_id": "garden", "confidence": 0.3}`, nil, "work", false, "kept the current context: the LLM was not confident enough (best guess 'garden', confidence 0.30)."},
+		{"unknown context", `{"context_id": "kitchen", "confidence": 0.95}`, nil, "work", false, "kept the current context: the LLM reply could not be used."},
+		{"prose", "That sounds like gardening to me!", nil, "work", false, "the LLM reply could not be used"},
+		{"empty", "", nil, "work", false, "the LLM reply could not be used"},
+		{"confidence as text", `{"context_id": "garden", "confidence": "high"}`, nil, "work", false, "the LLM reply could not be used"},
+		{"missing confidence", `{"context_id": "garden"}`, nil, "work", false, "the LLM reply could not be used"},
+		{"array", `["garden", 0.9]`, nil, "work", false, "the LLM reply could not be used"},
+		{"call fails", "", errors.New("quota exceeded"), "work", false, "kept the current context: the LLM call failed."},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			llm := &fakeLLM{reply: func(string) (string, error) { return tc.reply, tc.err }}
+			ta := newAutoContextApp(t, nil, llm)
+			ta.switchContext(t, "work")
+
+			text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "compost", "content": "turn the compost every two weeks", "context": "auto"})
+			if isErr {
+				t.Fatalf("remember: %s", text)
+			}
+			if !strings.HasPrefix(text, "Memory 'compost' saved in context '"+tc.want+"'.") || !strings.Contains(text, tc.note) {
+				t.Errorf("remember = %q, want it saved in %s with %q", text, tc.want, tc.note)
+			}
+			doc, err := ta.vectorStore.GetByID(t.Context(), "compost")
+			if err != nil {
+				t.Fatalf("GetByID: %v", err)
+			}
+			if doc.Metadata["context"] != tc.want || (doc.Metadata["auto_classified"] == "true") != tc.classified {
+				t.Errorf("metadata = %v, want context %s, auto_classified %v", doc.Metadata, tc.want, tc.classified)
+			}
+			if got := memoryCounts(t, ta.ctx, tc.want); got[tc.want] != 1 {
+				t.Errorf("%s counts %d memories, want 1", tc.want, got[tc.want])
+			}
+		})
+	}
+}
+
+func TestRememberAutoContextPrompt(t *testing.T) {
+	llm := newFakeLLM(`{"context_id": "garden", "confidence": 0.9}`)
+	ta := newAutoContextApp(t, nil, llm)
+	// A context the client has no access to is not offered
+	if text, isErr := callAs(t, WithClientID(context.Background(), newClientID("Other App")), ta.createContextHandler, map[string]any{"id": "secret", "name": "Secret"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+
+	guest := WithClientID(context.Background(), newClientID("Guest App"))
+	if text, isErr := callAs(t, guest, ta.rememberHandler, map[string]any{"id": "compost", "content": "turn the compost every two weeks", "context": "AUTO"}); isErr {
+		t.Fatalf("remember: %s", text)
+	}
+	prompts := llm.Prompts()
+	if len(prompts) != 1 {
+		t.Fatalf("%d prompts, want 1", len(prompts))
+	}
+	for _, want := range []string{"- [garden] Garden: plants, beds and the compost heap\n", "- [work] Work\n", "- [" + DefaultContextID + "]", "Memory:\nturn the compost every two weeks\n", `"confidence"`} {
+		if !strings.Contains(prompts[0], want) {
+			t.Errorf("prompt lacks %q:\n%s", want, prompts[0])
+		}
+	}
+	if strings.Contains(prompts[0], "[secret]") {
+		t.Errorf("prompt offers a context the client cannot use:\n%s", prompts[0])
+	}
+}
+
+func TestRememberAutoContextIsOptIn(t *testing.T) {
+	llm := newFakeLLM(`{"context_id": "garden", "confidence": 0.9}`)
+	ta := newAutoContextApp(t, nil, llm)
+
+	text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "compost", "content": "turn the compost every two weeks"})
+	if isErr || text != "Memory 'compost' saved in context '"+DefaultContextID+"'." {
+		t.Errorf("remember without auto context = %q", text)
+	}
+	if n := len(llm.Prompts()); n != 0 {
+		t.Errorf("remember without auto context made %d LLM calls", n)
+	}
+	if text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "beds", "content": "the raised beds need edging", "context": "garden"}); !isErr || !strings.Contains(text, `context must be "auto"`) {
+		t.Errorf("remember with a context ID = %q, want an error", text)
+	}
+
+	// auto_context turns it on for every remember
+	ta = newAutoContextApp(t, func(cfg *Config) { cfg.AutoContext = true }, llm)
+	if text, _ := call(t, ta.rememberHandler, map[string]any{"id": "compost", "content": "turn the compost every two weeks"}); !strings.Contains(text, "saved in context 'garden'") {
+		t.Errorf("remember with auto_context = %q", text)
+	}
+
+	// With a single context there is nothing to ask
+	single := newTestApp(t, func(cfg *Config) { cfg.AutoContext = true })
+	lonely := newFakeLLM(`{"context_id": "garden", "confidence": 0.9}`)
+	single.llm = lonely
+	text, _ = call(t, single.rememberHandler, map[string]any{"id": "compost", "content": "turn the compost every two weeks"})
+	if !strings.Contains(text, "kept the current context: only one context is available.") || len(lonely.Prompts()) != 0 {
+		t.Errorf("remember with one context = %q after %d LLM calls", text, len(lonely.Prompts()))
+	}
+}
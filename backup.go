@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// backupTimeFormat names backup directories; it sorts chronologically.
+const backupTimeFormat = "20060102-150405"
+
+// startBackups runs performBackup every interval until ctx is cancelled.
+func (a *App) startBackups(ctx context.Context, interval time.Duration, dir string, maxBackups int) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			path, err := a.performBackup(dir, maxBackups, time.Now())
+			if err != nil {
+				a.logger.Printf("Warning: Backup failed: %v", err)
+				continue
+			}
+			a.logger.Printf("Backup written to %s", path)
+		}
+	}()
+}
+
+// performBackup saves the vector store and copies the database, context state
+// and version histories to dir/<timestamp>/, then deletes the oldest backups
+// beyond maxBackups. The database is read from localDataDir, where the local
+// backend keeps it, and the rest from the data directory. Files that do not
+// exist (e.g. the local database when a remote vector store is used) are
+// skipped. It returns the new backup's path.
+func (a *App) performBackup(dir string, maxBackups int, now time.Time) (string, error) {
+	dbDir, err := localDataDir()
+	if err != nil {
+		return "", err
+	}
+	if err := a.vectorStore.SaveToDisk(); err != nil {
+		return "", fmt.Errorf("failed to save vector database: %w", err)
+	}
+	if err := a.ctx.Save(); err != nil {
+		return "", fmt.Errorf("failed to save context state: %w", err)
+	}
+
+	target := filepath.Join(dir, now.UTC().Format(backupTimeFormat))
+	if err := os.MkdirAll(target, 0755); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	sources := map[string]string{
+		DefaultDBPath:                  dbDir,
+		encryptedDBPath(DefaultDBPath): dbDir,
+		ContextsDataPath:               a.dataDir,
+		"memory_versions":              a.dataDir,
+	}
+	for name, srcDir := range sources {
+		src := filepath.Join(srcDir, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyPath(src, filepath.Join(target, name)); err != nil {
+			return "", fmt.Errorf("failed to back up %s: %w", name, err)
+		}
+	}
+
+	if err := pruneBackups(dir, maxBackups); err != nil {
+		a.logger.Printf("Warning: Failed to remove old backups: %v", err)
+	}
+	return target, nil
+}
+
+// pruneBackups deletes the oldest backup directories in dir beyond keep.
+// Entries not named like a backup are left alone.
+func pruneBackups(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		if _, err := time.Parse(backupTimeFormat, entry.Name()); err == nil && entry.IsDir() {
+			backups = append(backups, entry.Name())
+		}
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+
+	sort.Strings(backups)
+	for _, name := range backups[:len(backups)-keep] {
+		if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyPath copies a file, or a directory tree, from src to dst.
+func copyPath(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		return copyFile(path, target)
+	})
+}
+
+// copyFile copies the regular file src to dst.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
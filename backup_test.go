@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBackupReadsDatabaseFromDataDirEnv(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "boiler", "the boiler is serviced every autumn", nil)
+
+	// The database stays where BRAINMCP_DATA_DIR points while the context
+	// state lives in the data directory
+	t.Setenv("BRAINMCP_DATA_DIR", ta.dataDir)
+	ta.dataDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(ta.dataDir, ContextsDataPath), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	backupDir := filepath.Join(t.TempDir(), "backups")
+	path, err := ta.performBackup(backupDir, 3, time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("performBackup: %v", err)
+	}
+	if want := filepath.Join(backupDir, "20260501-120000"); path != want {
+		t.Errorf("backup path = %s, want %s", path, want)
+	}
+	for _, name := range []string{DefaultDBPath, ContextsDataPath} {
+		if _, err := os.Stat(filepath.Join(path, name)); err != nil {
+			t.Errorf("backup misses %s: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// batchCreateItem is one memory of a batch create, as taken by
+// MemoryVersionManager.BatchCreateMemories.
+type batchCreateItem = struct {
+	ID       string
+	Content  string
+	Context  string
+	Tags     []string
+	ClientID string
+}
+
+// batchItems collects per-item outcomes into a BatchOperationResult.
+type batchItems struct {
+	result *BatchOperationResult
+}
+
+func newBatchItems(operation string) *batchItems {
+	return &batchItems{result: &BatchOperationResult{
+		OperationType: operation,
+		Errors:        []string{},
+		OperationID:   newRequestID("batch"),
+	}}
+}
+
+func (b *batchItems) ok(id string) {
+	b.result.Items = append(b.result.Items, BatchItemResult{ID: id, Status: "ok"})
+}
+
+func (b *batchItems) fail(id string, err error) {
+	b.result.Items = append(b.result.Items, BatchItemResult{ID: id, Status: "failed", Error: err.Error()})
+	b.result.Errors = append(b.result.Errors, fmt.Sprintf("%s: %v", id, err))
+}
+
+// finish fills in the totals from the item outcomes.
+func (b *batchItems) finish() *BatchOperationResult {
+	b.result.Total = len(b.result.Items)
+	b.result.Failed = len(b.result.Errors)
+	b.result.Successful = b.result.Total - b.result.Failed
+	return b.result
+}
+
+// batchItemIDs reads memory IDs from a batch payload given either as strings
+// or as objects with an "id" field.
+func batchItemIDs(items []any) []string {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		var id string
+		switch v := item.(type) {
+		case string:
+			id = v
+		case map[string]any:
+			id, _ = v["id"].(string)
+		}
+		ids = append(ids, strings.TrimSpace(id))
+	}
+	return ids
+}
+
+// batchTags reads a list of tags given as an array or a comma-separated string.
+func batchTags(raw any) []string {
+	var tags []string
+	switch v := raw.(type) {
+	case string:
+		tags = ParseTags(v)
+	case []any:
+		for _, t := range v {
+			if s, ok := t.(string); ok {
+				tags = append(tags, s)
+			}
+		}
+	}
+	var cleaned []string
+	for _, tag := range tags {
+		if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" && !slices.Contains(cleaned, tag) {
+			cleaned = append(cleaned, tag)
+		}
+	}
+	return cleaned
+}
+
+// batchOperationsHandler handles the batch_operations tool - creates or deletes
+// memories, or adds or removes tags, for many memories at once. The vector store
+// and the version history are updated together and every item's outcome is
+// reported in the JSON result.
+func (a *App) batchOperationsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+
+	operation, _ := args["operation"].(string)
+	items, _ := args["memories"].([]any)
+	if len(items) == 0 {
+		return mcp.NewToolResultError("memories is required and must be a non-empty array"), nil
+	}
+
+	var result *BatchOperationResult
+	switch operation {
+	case "create":
+		result = a.batchCreate(ctx, items)
+	case "delete":
+		result = a.batchDelete(ctx, batchItemIDs(items))
+	case "add_tags", "remove_tags":
+		tags := batchTags(args["tags"])
+		if len(tags) == 0 {
+			return mcp.NewToolResultError("tags is required for add_tags and remove_tags"), nil
+		}
+		result = a.batchTag(ctx, operation, batchItemIDs(items), tags)
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown operation: %s (use create, delete, add_tags or remove_tags)", operation)), nil
+	}
+
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+
+	var succeeded []string
+	for _, item := range result.Items {
+		if item.Status == "ok" {
+			succeeded = append(succeeded, item.ID)
+		}
+	}
+	entry := AuditEntry{Tool: "batch_operations", MemoryIDs: succeeded, ClientID: a.clientIDFrom(ctx), Status: "ok",
+		Details: fmt.Sprintf("%s: %d of %d succeeded", operation, result.Successful, result.Total)}
+	if result.Failed > 0 {
+		entry.Status = "error"
+	}
+	a.recordAudit(ctx, entry)
+
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to encode batch result: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Batch %s: %d of %d succeeded, %d failed\n\n%s",
+		operation, result.Successful, result.Total, result.Failed, string(data))), nil
+}
+
+// batchCreate embeds and stores new memories and records their first version.
+// IDs that already exist are rejected, since a batch create would otherwise
+// replace their version history.
+func (a *App) batchCreate(ctx context.Context, items []any) *BatchOperationResult {
+	batch := newBatchItems("batch_create")
+
+	currentContext, err := a.ctx.GetClientContext(a.clientIDFrom(ctx))
+	if err != nil {
+		a.logf(ctx, "Warning: %v, using default context", err)
+		currentContext = DefaultContextID
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	var documents []chromem.Document
+	creates := make(map[string]batchCreateItem)
+	for i, raw := range items {
+		mem, _ := raw.(map[string]any)
+		id, _ := mem["id"].(string)
+		content, _ := mem["content"].(string)
+		contextID, _ := mem["context"].(string)
+		id, content, contextID = strings.TrimSpace(id), strings.TrimSpace(content), strings.TrimSpace(contextID)
+
+		switch {
+		case id == "":
+			batch.fail(fmt.Sprintf("#%d", i), fmt.Errorf("memory ID cannot be empty"))
+			continue
+		case content == "":
+			batch.fail(id, fmt.Errorf("memory content cannot be empty"))
+			continue
+		case creates[id].ID != "":
+			batch.fail(id, fmt.Errorf("duplicate ID in batch"))
+			continue
+		}
+		if _, err := a.vectorStore.GetByID(ctx, id); err == nil {
+			batch.fail(id, fmt.Errorf("memory already exists, use remember to update it"))
+			continue
+		}
+		if contextID == "" {
+			contextID = currentContext
+		} else if _, err := a.ctx.GetContext(contextID); err != nil {
+			batch.fail(id, fmt.Errorf("context %q not found", contextID))
+			continue
+		}
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+			batch.fail(id, errors.New(msg))
+			continue
+		}
+
+		item := batchCreateItem{ID: id, Content: content, Context: contextID, Tags: batchTags(mem["tags"]), ClientID: a.clientIDFrom(ctx)}
+		creates[id] = item
+		metadata := map[string]string{
+			"extra":      "",
+			"context":    contextID,
+			"client":     a.clientIDFrom(ctx),
+			"created_at": now,
+		}
+		if len(item.Tags) > 0 {
+			metadata["tags"] = EncodeTags(item.Tags)
+		}
+		documents = append(documents, chromem.Document{ID: id, Content: content, Metadata: metadata})
+	}
+	documents = a.dropOverCap(ctx, batch, documents)
+	if len(documents) == 0 {
+		return batch.finish()
+	}
+
+	documents, invalid, err := a.embedDocuments(ctx, documents)
+	if err == nil && len(documents) > 0 {
+		err = a.vectorStore.AddDocuments(ctx, documents, 4)
+	}
+	if err != nil {
+		for id := range creates {
+			batch.fail(id, fmt.Errorf("failed to store: %w", err))
+		}
+		return batch.finish()
+	}
+	for id, err := range invalid {
+		batch.fail(id, err)
+	}
+
+	stored := make([]batchCreateItem, len(documents))
+	for i, doc := range documents {
+		stored[i] = creates[doc.ID]
+	}
+	if _, err := a.versionMgr.BatchCreateMemories(stored); err != nil {
+		a.logf(ctx, "Warning: Memories stored but version history not saved: %v", err)
+	}
+
+	for _, item := range stored {
+		for _, tag := range item.Tags {
+			if _, err := a.ctx.GetTag(tag); err != nil {
+				if err := a.ctx.CreateTag(tag, "", "", ""); err != nil {
+					a.logf(ctx, "Warning: Failed to create tag %q: %v", tag, err)
+				}
+			}
+			if err := a.ctx.IncrementTagCount(tag); err != nil {
+				a.logf(ctx, "Warning: Failed to increment tag count: %v", err)
+			}
+		}
+		if err := a.ctx.IncrementMemoryCount(item.Context); err != nil {
+			a.logf(ctx, "Warning: Failed to update context count: %v", err)
+		}
+		a.activity.Record(item.Context, ActivityCounts{Created: 1})
+		batch.ok(item.ID)
+	}
+	return batch.finish()
+}
+
+// dropOverCap fails the documents of every context they would take past its
+// memory cap and returns the rest.
+func (a *App) dropOverCap(ctx context.Context, batch *batchItems, documents []chromem.Document) []chromem.Document {
+	byContext := make(map[string][]string)
+	for _, doc := range documents {
+		byContext[doc.Metadata["context"]] = append(byContext[doc.Metadata["context"]], doc.ID)
+	}
+	refused := make(map[string]bool)
+	for contextID, ids := range byContext {
+		if msg := a.contextCapExceeded(ctx, contextID, ids...); msg != "" {
+			refused[contextID] = true
+			for _, id := range ids {
+				batch.fail(id, errors.New(msg))
+			}
+		}
+	}
+	return slices.DeleteFunc(documents, func(doc chromem.Document) bool { return refused[doc.Metadata["context"]] })
+}
+
+// batchDelete deletes memories from the vector store together with their
+// version history. With soft delete enabled memories are moved to the trash
+// instead, as delete_memory does.
+func (a *App) batchDelete(ctx context.Context, ids []string) *BatchOperationResult {
+	batch := newBatchItems("batch_delete")
+	softDelete := a.settings().SoftDelete
+
+	var hardDeletes []chromem.Document
+	for _, id := range ids {
+		if id == "" {
+			batch.fail("(empty)", fmt.Errorf("memory ID cannot be empty"))
+			continue
+		}
+		doc, err := a.vectorStore.GetByID(ctx, id)
+		if err != nil {
+			batch.fail(id, fmt.Errorf("memory not found"))
+			continue
+		}
+		if msg := a.memoryAccessDenied(ctx, doc); msg != "" {
+			batch.fail(id, errors.New(msg))
+			continue
+		}
+		if softDelete && !isSoftDeleted(doc.Metadata) {
+			if err := a.moveToTrash(ctx, doc, time.Now()); err != nil {
+				batch.fail(id, err)
+				continue
+			}
+			a.deleteChunksOf(ctx, doc, true)
+			a.uncountDeleted(ctx, doc)
+			batch.ok(id)
+			continue
+		}
+		hardDeletes = append(hardDeletes, doc)
+	}
+	if len(hardDeletes) == 0 {
+		return batch.finish()
+	}
+
+	deleteIDs := make([]string, len(hardDeletes))
+	var withHistory []string
+	for i, doc := range hardDeletes {
+		deleteIDs[i] = doc.ID
+		if _, err := a.versionMgr.GetHistory(doc.ID); err == nil {
+			withHistory = append(withHistory, doc.ID)
+		}
+	}
+	if err := a.vectorStore.Delete(ctx, nil, nil, deleteIDs...); err != nil {
+		for _, id := range deleteIDs {
+			batch.fail(id, fmt.Errorf("delete failed: %w", err))
+		}
+		return batch.finish()
+	}
+	if len(withHistory) > 0 {
+		if _, err := a.versionMgr.BatchDeleteMemories(withHistory); err != nil {
+			a.logf(ctx, "Warning: Failed to delete version history: %v", err)
+		}
+	}
+	for _, doc := range hardDeletes {
+		a.deleteChunksOf(ctx, doc, false)
+		if !isSoftDeleted(doc.Metadata) {
+			a.uncountDeleted(ctx, doc)
+		}
+		batch.ok(doc.ID)
+	}
+	return batch.finish()
+}
+
+// uncountDeleted updates the context count and activity log for a memory that
+// left its context.
+func (a *App) uncountDeleted(ctx context.Context, doc chromem.Document) {
+	if isChunk(doc.Metadata) {
+		return
+	}
+	contextID := doc.Metadata["context"]
+	if contextID == "" {
+		contextID = DefaultContextID
+	}
+	if err := a.ctx.DecrementMemoryCount(contextID); err != nil {
+		a.logf(ctx, "Warning: Failed to update context count: %v", err)
+	}
+	a.activity.Record(contextID, ActivityCounts{Deleted: 1})
+}
+
+// batchTag adds or removes tags on memories in the vector store and in their
+// version history.
+func (a *App) batchTag(ctx context.Context, operation string, ids []string, tags []string) *BatchOperationResult {
+	batch := newBatchItems("batch_" + operation)
+
+	var withHistory []string
+	for _, id := range ids {
+		if id == "" {
+			batch.fail("(empty)", fmt.Errorf("memory ID cannot be empty"))
+			continue
+		}
+		var err error
+		if operation == "add_tags" {
+			_, err = a.addTags(ctx, id, tags)
+		} else {
+			_, err = a.removeTags(ctx, id, tags)
+		}
+		if err != nil {
+			batch.fail(id, err)
+			continue
+		}
+		if _, err := a.versionMgr.GetHistory(id); err == nil {
+			withHistory = append(withHistory, id)
+		}
+		batch.ok(id)
+	}
+
+	if len(withHistory) > 0 {
+		var err error
+		if operation == "add_tags" {
+			_, err = a.versionMgr.BatchAddTags(withHistory, tags)
+		} else {
+			_, err = a.versionMgr.BatchRemoveTags(withHistory, tags)
+		}
+		if err != nil {
+			a.logf(ctx, "Warning: Tags updated but version history not saved: %v", err)
+		}
+	}
+	return batch.finish()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// batchResult runs batch_operations and decodes the JSON result after its summary line.
+func (ta *testApp) batchResult(t *testing.T, args map[string]any) (string, *BatchOperationResult) {
+	t.Helper()
+	text, isErr := call(t, ta.batchOperationsHandler, args)
+	if isErr {
+		t.Fatalf("batch_operations: %s", text)
+	}
+	summary, data, ok := strings.Cut(text, "\n\n")
+	if !ok {
+		t.Fatalf("batch_operations result has no JSON:\n%s", text)
+	}
+	var result BatchOperationResult
+	if err := json.Unmarshal([]byte(data), &result); err != nil {
+		t.Fatalf("decoding batch result: %v\n%s", err, data)
+	}
+	return summary, &result
+}
+
+func TestBatchDeleteMixedSuccess(t *testing.T) {
+	for _, softDelete := range []bool{false, true} {
+		name := "hard"
+		if softDelete {
+			name = "soft"
+		}
+		t.Run(name, func(t *testing.T) {
+			ta := newTwoContextApp(t, func(cfg *Config) { cfg.SoftDelete = softDelete })
+
+			summary, result := ta.batchResult(t, map[string]any{"operation": "delete", "memories": []any{
+				"d1", "missing", "w2", " ", map[string]any{"id": "w3"},
+			}})
+			if summary != "Batch delete: 3 of 5 succeeded, 2 failed" {
+				t.Errorf("summary = %q", summary)
+			}
+			if result.Total != 5 || result.Successful != 3 || result.Failed != 2 || len(result.Errors) != 2 {
+				t.Errorf("totals = %d total, %d ok, %d failed, errors %q", result.Total, result.Successful, result.Failed, result.Errors)
+			}
+			status := map[string]string{}
+			for _, item := range result.Items {
+				status[item.ID] = item.Status + " " + item.Error
+			}
+			want := map[string]string{
+				"d1": "ok ", "w2": "ok ", "w3": "ok ",
+				"missing": "failed memory not found",
+				"(empty)": "failed memory ID cannot be empty",
+			}
+			for id, s := range want {
+				if status[id] != s {
+					t.Errorf("item %s = %q, want %q", id, status[id], s)
+				}
+			}
+
+			// The failures change nothing; the successes reach the store, the history and the counts
+			for id, kept := range map[string]bool{"d1": false, "d2": true, "w1": true, "w2": false, "w3": false} {
+				doc, err := ta.vectorStore.GetByID(t.Context(), id)
+				visible := err == nil && !isSoftDeleted(doc.Metadata)
+				if visible != kept {
+					t.Errorf("%s visible = %v, want %v", id, visible, kept)
+				}
+				if _, err := ta.versionMgr.GetHistory(id); !softDelete && (err == nil) != kept {
+					t.Errorf("%s history kept = %v, want %v", id, err == nil, kept)
+				}
+			}
+			if got := memoryCounts(t, ta.ctx, DefaultContextID, "work"); got[DefaultContextID] != 1 || got["work"] != 1 {
+				t.Errorf("counts = %v, want default 1, work 1", got)
+			}
+
+			// Deleting again fails only for what is gone; a trashed memory is
+			// purged, as delete_memory does
+			_, result = ta.batchResult(t, map[string]any{"operation": "delete", "memories": []any{"d2", "d1"}})
+			status = map[string]string{}
+			for _, item := range result.Items {
+				status[item.ID] = item.Status + " " + item.Error
+			}
+			wantD1 := "failed memory not found"
+			if softDelete {
+				wantD1 = "ok "
+			}
+			if status["d2"] != "ok " || status["d1"] != wantD1 {
+				t.Errorf("second delete = %v, want d2 ok and d1 %q", status, wantD1)
+			}
+			if got := memoryCounts(t, ta.ctx, DefaultContextID); got[DefaultContextID] != 0 {
+				t.Errorf("default count = %d, want 0", got[DefaultContextID])
+			}
+		})
+	}
+}
+
+func TestBatchOperationsRejectsBadRequests(t *testing.T) {
+	ta := newTestApp(t, nil)
+	for _, tc := range []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"operation": "delete"}, "memories is required"},
+		{map[string]any{"operation": "purge", "memories": []any{"a"}}, "Unknown operation: purge"},
+		{map[string]any{"operation": "add_tags", "memories": []any{"a"}}, "tags is required"},
+	} {
+		if text, isErr := call(t, ta.batchOperationsHandler, tc.args); !isErr || !strings.Contains(text, tc.want) {
+			t.Errorf("batch_operations %v = %q, want %q", tc.args, text, tc.want)
+		}
+	}
+}
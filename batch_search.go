@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// batchSearchHit is one result of a batch_search query.
+type batchSearchHit struct {
+	ID         string  `json:"id"`
+	Similarity float32 `json:"similarity"`
+	Context    string  `json:"context,omitempty"`
+	Content    string  `json:"content"`
+}
+
+// batchSearchHandler handles the batch_search tool - runs several semantic
+// searches in one call, up to concurrency at a time, and returns the results
+// as a JSON object keyed by query.
+func (a *App) batchSearchHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	raw, _ := args["queries"].([]any)
+	var queries []string
+	seen := make(map[string]bool)
+	for _, v := range raw {
+		query, _ := v.(string)
+		if query = strings.TrimSpace(query); query != "" && !seen[query] {
+			seen[query] = true
+			queries = append(queries, query)
+		}
+	}
+	if len(queries) == 0 {
+		return mcp.NewToolResultError("queries must contain at least one search query"), nil
+	}
+	if len(queries) > MaxBatchQueries {
+		return mcp.NewToolResultError(fmt.Sprintf("Too many queries: %d (max %d)", len(queries), MaxBatchQueries)), nil
+	}
+	concurrency := DefaultBatchSearchConcurrency
+	if v, ok := args["concurrency"].(float64); ok && v >= 1 {
+		concurrency = min(int(v), MaxBatchQueries)
+	}
+
+	totalDocs := a.vectorStore.Count()
+	if totalDocs == 0 {
+		return mcp.NewToolResultText(NoMemoriesMsg), nil
+	}
+	nResults := a.resultLimit(args, totalDocs)
+
+	var where map[string]string
+	contextID, _ := args["context_id"].(string)
+	if contextID = strings.TrimSpace(contextID); contextID != "" {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+		where = map[string]string{"context": contextID}
+	}
+
+	results := make([][]chromem.Result, len(queries))
+	errs := make([]error, len(queries))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, query := range queries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			found, err := a.searchMemories(ctx, SearchModeSemantic, query, nResults, where)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			found = boostByImportance(a.parentResults(ctx, found))
+			results[i] = found[:min(len(found), nResults)]
+		}()
+	}
+	wg.Wait()
+
+	hits := make(map[string][]batchSearchHit, len(queries))
+	var retrieved []chromem.Result
+	for i, query := range queries {
+		if errs[i] != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Search for '%s' failed: %v", query, errs[i])), nil
+		}
+		hits[query] = []batchSearchHit{}
+		for _, res := range results[i] {
+			hits[query] = append(hits[query], batchSearchHit{
+				ID:         res.ID,
+				Similarity: res.Similarity,
+				Context:    res.Metadata["context"],
+				Content:    res.Content,
+			})
+		}
+		retrieved = append(retrieved, results[i]...)
+	}
+	a.activity.Record(a.activityContext(ctx, contextID), ActivityCounts{Searches: len(queries)})
+	a.recordAccess(ctx, accessedIDs(retrieved)...)
+
+	out, err := json.MarshalIndent(hits, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to format results: %v", err)), nil
+	}
+	return mcp.NewToolResultText(string(out)), nil
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+)
+
+// Long memories are stored as a parent record plus chunks. The parent keeps
+// the full content, its version history and a chunk_count metadata key; its
+// embedding is the mean of its chunks' embeddings, so the full text is never
+// sent to the embedder. Each chunk is stored as "<id>#chunk-<n>" with the
+// parent's metadata and parent_id, chunk_index (1-based) and chunk_count.
+// Searches return the chunks, not the parent, unless search_memory's
+// return_parent collapses them into their parent.
+
+// chunkID returns the ID of the n-th chunk of parentID.
+func chunkID(parentID string, n int) string {
+	return fmt.Sprintf("%s#chunk-%d", parentID, n)
+}
+
+// isChunk reports whether a memory is a chunk of a longer memory.
+func isChunk(metadata map[string]string) bool {
+	return metadata["parent_id"] != ""
+}
+
+// isChunkedParent reports whether a memory is the parent record of chunks.
+func isChunkedParent(metadata map[string]string) bool {
+	return metadata["chunk_count"] != "" && !isChunk(metadata)
+}
+
+// searchableResults drops chunked parents from search results, since their
+// chunks are returned instead.
+func searchableResults(results []chromem.Result) []chromem.Result {
+	searchable := results[:0]
+	for _, res := range results {
+		if !isChunkedParent(res.Metadata) {
+			searchable = append(searchable, res)
+		}
+	}
+	return searchable
+}
+
+// chunkLabel describes a chunk's position for search output, or returns "" for
+// memories that are not chunks.
+func chunkLabel(metadata map[string]string) string {
+	if !isChunk(metadata) {
+		return ""
+	}
+	return fmt.Sprintf("chunk %s/%s of '%s'", metadata["chunk_index"], metadata["chunk_count"], metadata["parent_id"])
+}
+
+// parentResults replaces chunk results with their parent memory, returning
+// one result per parent with the full content and the similarity of its best
+// chunk. The matching chunks are counted in the parent's "matched_chunks"
+// metadata. Results stay sorted by similarity. A chunk whose parent cannot be
+// read is kept as it is.
+func (a *App) parentResults(ctx context.Context, results []chromem.Result) []chromem.Result {
+	out := make([]chromem.Result, 0, len(results))
+	parents := make(map[string]int) // Parent ID -> index in out
+	for _, res := range results {
+		parentID := res.Metadata["parent_id"]
+		if parentID == "" {
+			out = append(out, res)
+			continue
+		}
+		if i, ok := parents[parentID]; ok {
+			matched, _ := strconv.Atoi(out[i].Metadata["matched_chunks"])
+			out[i].Metadata["matched_chunks"] = strconv.Itoa(matched + 1)
+			out[i].Similarity = max(out[i].Similarity, res.Similarity)
+			continue
+		}
+		parent, err := a.vectorStore.GetByID(ctx, parentID)
+		if err != nil {
+			out = append(out, res)
+			continue
+		}
+		metadata := make(map[string]string, len(parent.Metadata)+1)
+		for k, v := range parent.Metadata {
+			metadata[k] = v
+		}
+		metadata["matched_chunks"] = "1"
+		parents[parentID] = len(out)
+		// The best matching chunk's embedding stands in for the parent, e.g. in MMR
+		out = append(out, chromem.Result{ID: parent.ID, Content: parent.Content, Metadata: metadata, Embedding: res.Embedding, Similarity: res.Similarity})
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Similarity > out[j].Similarity })
+	return out
+}
+
+// splitChunks splits content into chunks of at most size characters (runes).
+// Content that fits is returned as a single chunk. Chunks end at the last
+// paragraph break before the limit, falling back to a line break, the end of a
+// sentence, a space and finally a hard cut. Each chunk after the first starts
+// with up to overlap characters from the end of the previous one, beginning at
+// a word boundary.
+func splitChunks(content string, size, overlap int) []string {
+	runes := []rune(content)
+	if size <= 0 || len(runes) <= size {
+		return []string{content}
+	}
+	overlap = max(0, min(overlap, size/2))
+
+	var chunks []string
+	for pos := 0; pos < len(runes); {
+		end := pos + size
+		if end >= len(runes) {
+			if chunk := strings.TrimSpace(string(runes[pos:])); chunk != "" {
+				chunks = append(chunks, chunk)
+			}
+			break
+		}
+		cut := chunkBoundary(runes, pos+size/2, end)
+		if chunk := strings.TrimSpace(string(runes[pos:cut])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+
+		next := cut - overlap
+		if next <= pos {
+			next = cut
+		}
+		for next < cut && !unicode.IsSpace(runes[next-1]) {
+			next++
+		}
+		pos = next
+	}
+	return chunks
+}
+
+// chunkBoundary returns the position in runes[from:end] at which to cut a
+// chunk that must end by end.
+func chunkBoundary(runes []rune, from, end int) int {
+	isBreak := []func(i int) bool{
+		func(i int) bool { return runes[i-1] == '\n' && runes[i-2] == '\n' },
+		func(i int) bool { return runes[i-1] == '\n' },
+		func(i int) bool { return unicode.IsSpace(runes[i-1]) && strings.ContainsRune(".!?", runes[i-2]) },
+		func(i int) bool { return unicode.IsSpace(runes[i-1]) },
+	}
+	for _, matches := range isBreak {
+		for i := end; i > from && i >= 2; i-- {
+			if matches(i) {
+				return i
+			}
+		}
+	}
+	return end
+}
+
+// chunkDocument splits doc into chunk documents if its content is longer than
+// chunk_size and returns nil otherwise. Relations stay on the parent.
+func (a *App) chunkDocument(doc chromem.Document) []chromem.Document {
+	s := a.settings()
+	texts := splitChunks(doc.Content, s.ChunkSize, s.ChunkOverlap)
+	if len(texts) < 2 {
+		return nil
+	}
+
+	chunks := make([]chromem.Document, len(texts))
+	for i, text := range texts {
+		metadata := make(map[string]string, len(doc.Metadata)+3)
+		for k, v := range doc.Metadata {
+			if k != RelationSupersedes && k != RelationPartOf {
+				metadata[k] = v
+			}
+		}
+		metadata["parent_id"] = doc.ID
+		metadata["chunk_index"] = strconv.Itoa(i + 1)
+		metadata["chunk_count"] = strconv.Itoa(len(texts))
+		chunks[i] = chromem.Document{ID: chunkID(doc.ID, i+1), Content: text, Metadata: metadata}
+	}
+	return chunks
+}
+
+// embedChunked embeds documents like embedDocuments, first splitting long
+// ones into chunks. Each chunked document is returned as its parent record
+// followed by its chunks; if any chunk's embedding is invalid the whole
+// document is reported in the invalid map.
+func (a *App) embedChunked(ctx context.Context, documents []chromem.Document) ([]chromem.Document, map[string]error, error) {
+	var toEmbed []chromem.Document
+	parents := make(map[string]chromem.Document)
+	for _, doc := range documents {
+		chunks := a.chunkDocument(doc)
+		if chunks == nil {
+			toEmbed = append(toEmbed, doc)
+			continue
+		}
+		metadata := make(map[string]string, len(doc.Metadata)+1)
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		metadata["chunk_count"] = strconv.Itoa(len(chunks))
+		doc.Metadata = metadata
+		parents[doc.ID] = doc
+		toEmbed = append(toEmbed, chunks...)
+	}
+	if len(parents) == 0 {
+		return a.embedDocuments(ctx, documents)
+	}
+
+	embedded, invalid, err := a.embedDocuments(ctx, toEmbed)
+	if err != nil {
+		return nil, nil, err
+	}
+	if invalid == nil {
+		invalid = make(map[string]error)
+	}
+	for id, chunkErr := range invalid {
+		if parentID, _, ok := strings.Cut(id, "#chunk-"); ok && parents[parentID].ID != "" {
+			delete(invalid, id)
+			invalid[parentID] = fmt.Errorf("chunk %s: %w", id, chunkErr)
+		}
+	}
+
+	chunkEmbeddings := make(map[string][][]float32)
+	for _, doc := range embedded {
+		if parentID := doc.Metadata["parent_id"]; parentID != "" {
+			chunkEmbeddings[parentID] = append(chunkEmbeddings[parentID], doc.Embedding)
+		}
+	}
+
+	result := make([]chromem.Document, 0, len(embedded)+len(parents))
+	for _, doc := range embedded {
+		parentID := doc.Metadata["parent_id"]
+		if parentID == "" {
+			result = append(result, doc)
+			continue
+		}
+		if invalid[parentID] != nil {
+			continue
+		}
+		if doc.Metadata["chunk_index"] == "1" {
+			parent := parents[parentID]
+			mean, err := vecmath.Mean(chunkEmbeddings[parentID])
+			if err != nil {
+				invalid[parentID] = fmt.Errorf("failed to combine chunk embeddings: %w", err)
+				continue
+			}
+			parent.Embedding = mean
+			result = append(result, parent)
+		}
+		result = append(result, doc)
+	}
+	return result, invalid, nil
+}
+
+// storedChunkCount returns the number of chunks the stored memory id has, 0 if
+// it is not chunked or does not exist.
+func (a *App) storedChunkCount(ctx context.Context, id string) int {
+	doc, err := a.vectorStore.GetByID(ctx, id)
+	if err != nil || !isChunkedParent(doc.Metadata) {
+		return 0
+	}
+	return chunkCount(doc.Metadata)
+}
+
+// chunkCount returns the chunk_count metadata of a chunk or chunked parent.
+func chunkCount(metadata map[string]string) int {
+	n, _ := strconv.Atoi(metadata["chunk_count"])
+	return n
+}
+
+// removeStaleChunks deletes the chunks of parentID beyond the first keep, left
+// over when a memory that had previous chunks is stored with fewer.
+func (a *App) removeStaleChunks(ctx context.Context, parentID string, previous, keep int) {
+	if previous <= keep {
+		return
+	}
+	var stale []string
+	for n := keep + 1; n <= previous; n++ {
+		stale = append(stale, chunkID(parentID, n))
+	}
+	if err := a.vectorStore.Delete(ctx, nil, nil, stale...); err != nil {
+		a.logf(ctx, "Warning: Failed to delete stale chunks of '%s': %v", parentID, err)
+	}
+}
+
+// chunksOf returns the stored chunks of parentID, including trashed ones.
+func (a *App) chunksOf(ctx context.Context, parentID string) ([]chromem.Document, error) {
+	return a.vectorStore.ListDocuments(ctx, map[string]string{"parent_id": parentID}, 0, 0)
+}
+
+// deleteChunksOf deletes the chunks of doc, or with trash moves them to the
+// trash, if doc is a chunked parent. Failures are logged.
+func (a *App) deleteChunksOf(ctx context.Context, doc chromem.Document, trash bool) {
+	if !isChunkedParent(doc.Metadata) {
+		return
+	}
+	if err := a.deleteChunks(ctx, doc.ID, trash); err != nil {
+		a.logf(ctx, "Warning: Failed to delete chunks of '%s': %v", doc.ID, err)
+	}
+}
+
+// deleteChunks deletes the chunks of a deleted parent, or with trash moves
+// them to the trash along with it.
+func (a *App) deleteChunks(ctx context.Context, parentID string, trash bool) error {
+	chunks, err := a.chunksOf(ctx, parentID)
+	if err != nil || len(chunks) == 0 {
+		return err
+	}
+	if !trash {
+		ids := make([]string, len(chunks))
+		for i, chunk := range chunks {
+			ids[i] = chunk.ID
+		}
+		return a.vectorStore.Delete(ctx, nil, nil, ids...)
+	}
+	now := time.Now()
+	for _, chunk := range chunks {
+		if isSoftDeleted(chunk.Metadata) {
+			continue
+		}
+		if err := a.moveToTrash(ctx, chunk, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreChunks moves the chunks of a restored parent back out of the trash.
+func (a *App) restoreChunks(ctx context.Context, parentID string) error {
+	chunks, err := a.chunksOf(ctx, parentID)
+	if err != nil {
+		return err
+	}
+	var restored []chromem.Document
+	for _, chunk := range chunks {
+		if !isSoftDeleted(chunk.Metadata) {
+			continue
+		}
+		metadata := make(map[string]string, len(chunk.Metadata))
+		for k, v := range chunk.Metadata {
+			if k != "deleted_at" {
+				metadata[k] = v
+			}
+		}
+		chunk.Metadata = metadata
+		restored = append(restored, chunk)
+	}
+	if len(restored) == 0 {
+		return nil
+	}
+	return a.vectorStore.AddDocuments(ctx, restored, 4)
+}
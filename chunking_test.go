@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// checkChunks fails the test unless every chunk is valid UTF-8, at most size
+// runes long and a piece of content, and every word of content is in a chunk.
+// Words longer than size are cut, so they only have to be in consecutive chunks.
+func checkChunks(t *testing.T, content string, size int, chunks []string) {
+	t.Helper()
+	for i, chunk := range chunks {
+		if !utf8.ValidString(chunk) || strings.ContainsRune(chunk, utf8.RuneError) {
+			t.Errorf("chunk %d splits a character: %q", i, chunk)
+		}
+		if n := utf8.RuneCountInString(chunk); n > size || n == 0 {
+			t.Errorf("chunk %d has %d characters, want 1 to %d: %q", i, n, size, chunk)
+		}
+		if !strings.Contains(content, chunk) {
+			t.Errorf("chunk %d is not a piece of the content: %q", i, chunk)
+		}
+	}
+	joined := strings.Join(chunks, " ")
+	unspaced := strings.Join(strings.Fields(strings.Join(chunks, "")), "")
+	for _, word := range strings.Fields(content) {
+		if !strings.Contains(joined, word) && (utf8.RuneCountInString(word) <= size || !strings.Contains(unspaced, word)) {
+			t.Errorf("%q is in no chunk", word)
+		}
+	}
+}
+
+func TestSplitChunksAtTheBoundary(t *testing.T) {
+	// The size counts characters, not bytes
+	exact := strings.Repeat("ä", 10)
+	if chunks := splitChunks(exact, 10, 2); len(chunks) != 1 || chunks[0] != exact {
+		t.Errorf("10 characters with size 10 = %q, want one chunk", chunks)
+	}
+	if chunks := splitChunks(exact+"ö", 10, 2); len(chunks) != 2 || chunks[0] != exact || chunks[1] != "ö" {
+		t.Errorf("11 characters with size 10 = %q, want a hard cut after 10", chunks)
+	}
+	if chunks := splitChunks("word word", 9, 0); len(chunks) != 1 {
+		t.Errorf("content of exactly size = %q", chunks)
+	}
+	if chunks := splitChunks("word words", 9, 0); len(chunks) != 2 || chunks[0] != "word" || chunks[1] != "words" {
+		t.Errorf("content one past size = %q, want a cut at the space", chunks)
+	}
+	for _, size := range []int{0, -1} {
+		if chunks := splitChunks("any length at all", size, 4); len(chunks) != 1 {
+			t.Errorf("size %d split the content: %q", size, chunks)
+		}
+	}
+}
+
+func TestSplitChunksPrefersParagraphs(t *testing.T) {
+	content := "First paragraph. It has two sentences.\n\nSecond paragraph, one sentence.\nA line of its own."
+	chunks := splitChunks(content, 60, 0)
+	if len(chunks) != 2 || chunks[0] != "First paragraph. It has two sentences." {
+		t.Errorf("chunks = %q, want the first cut at the paragraph break", chunks)
+	}
+	checkChunks(t, content, 60, chunks)
+}
+
+func TestSplitChunksWithoutParagraphBreaks(t *testing.T) {
+	var sb strings.Builder
+	for i := range 12 {
+		fmt.Fprintf(&sb, "Sentence number %d ends here. ", i)
+	}
+	content := strings.TrimSpace(sb.String())
+	chunks := splitChunks(content, 80, 20)
+	if len(chunks) < 5 {
+		t.Fatalf("got %d chunks of %d characters", len(chunks), len(content))
+	}
+	checkChunks(t, content, 80, chunks)
+	for i, chunk := range chunks[:len(chunks)-1] {
+		if !strings.HasSuffix(chunk, ".") {
+			t.Errorf("chunk %d does not end at a sentence: %q", i, chunk)
+		}
+	}
+	// Each chunk after the first repeats whole words from the end of the previous one
+	for i := 1; i < len(chunks); i++ {
+		first := strings.Fields(chunks[i])[0]
+		if !strings.Contains(chunks[i-1], first) {
+			t.Errorf("chunk %d starts with %q, which is not from chunk %d", i, first, i-1)
+		}
+	}
+
+	// Without any whitespace the content is cut hard and nothing overlaps
+	solid := strings.Repeat("abcdefghij", 5)
+	chunks = splitChunks(solid, 20, 5)
+	if len(chunks) != 3 || strings.Join(chunks, "") != solid {
+		t.Errorf("chunks of text without spaces = %q", chunks)
+	}
+}
+
+func TestSplitChunksUnicode(t *testing.T) {
+	content := strings.Repeat("naïve café 日本語のテキスト 🧠🧠 Ünïcödé ", 8)
+	for _, size := range []int{7, 12, 30} {
+		chunks := splitChunks(content, size, size/3)
+		if len(chunks) < 2 {
+			t.Errorf("size %d: %d chunks", size, len(chunks))
+		}
+		checkChunks(t, content, size, chunks)
+	}
+}
+
+func TestRememberStoresLongContentInChunks(t *testing.T) {
+	ta := newTestApp(t, func(cfg *Config) {
+		cfg.ChunkSize = 60
+		cfg.ChunkOverlap = 10
+	})
+	content := "The boiler is serviced every autumn.\n\nThe garage door code changed in May.\n\nThe gutters need clearing twice a year."
+	text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "house", "content": content})
+	if isErr {
+		t.Fatalf("remember: %s", text)
+	}
+
+	for n := 1; n <= 3; n++ {
+		doc, err := ta.vectorStore.GetByID(t.Context(), chunkID("house", n))
+		if err != nil {
+			t.Fatalf("chunk %d: %v", n, err)
+		}
+		if doc.Metadata["parent_id"] != "house" || doc.Metadata["chunk_index"] != fmt.Sprint(n) || doc.Metadata["chunk_count"] != "3" {
+			t.Errorf("chunk %d metadata = %v", n, doc.Metadata)
+		}
+	}
+	if got := ta.storedContent(t, "house"); got != content {
+		t.Errorf("the parent keeps %q, want the full content", got)
+	}
+
+	text, _ = call(t, ta.searchHandler, map[string]any{"query": "garage door code", "return_parent": false})
+	if !strings.Contains(text, "chunk 2/3 of 'house'") {
+		t.Errorf("search does not show the chunk position:\n%s", text)
+	}
+
+	if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "house"}); isErr {
+		t.Fatalf("delete_memory: %s", text)
+	}
+	if n := ta.vectorStore.Count(); n != 0 {
+		t.Errorf("%d documents left after deleting the parent, want its chunks gone too", n)
+	}
+}
+
+func TestMoveMemoryMovesChunks(t *testing.T) {
+	ta := newTestApp(t, func(cfg *Config) {
+		cfg.ChunkSize = 60
+		cfg.ChunkOverlap = 10
+	})
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "home", "name": "Home"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	content := "The boiler is serviced every autumn.\n\nThe garage door code changed in May.\n\nThe gutters need clearing twice a year."
+	ta.remember(t, "house", content, nil)
+
+	if text, isErr := call(t, ta.moveMemoryHandler, map[string]any{"id": chunkID("house", 2), "target_context_id": "home"}); !isErr {
+		t.Errorf("moving a chunk = %q, want an error", text)
+	}
+	if text, isErr := call(t, ta.moveMemoryHandler, map[string]any{"id": "house", "target_context_id": "home"}); isErr {
+		t.Fatalf("move_memory: %s", text)
+	}
+	for _, id := range []string{"house", chunkID("house", 1), chunkID("house", 2), chunkID("house", 3)} {
+		if doc, err := ta.vectorStore.GetByID(t.Context(), id); err != nil || doc.Metadata["context"] != "home" {
+			t.Errorf("%s is in context %q after the move (%v), want home", id, doc.Metadata["context"], err)
+		}
+	}
+
+	text, _ := call(t, ta.searchHandler, map[string]any{"query": "garage door code", "context_id": "home", "return_parent": false})
+	if !strings.Contains(text, "chunk 2/3 of 'house'") {
+		t.Errorf("search in the target context misses the chunk:\n%s", text)
+	}
+}
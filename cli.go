@@ -4,8 +4,12 @@ import (
 	"bufio"
 	"context"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/mark3labs/mcp-go/mcp"
 )
@@ -13,10 +17,16 @@ import (
 // runInteractiveCLI starts an interactive command-line interface for testing the memory system.
 // Users can manually test all available operations without needing an MCP client.
 func (a *App) runInteractiveCLI(ctx context.Context) {
+	a.runCLI(ctx, os.Stdin)
+}
+
+// runCLI reads CLI commands from in until exit or the end of input.
+func (a *App) runCLI(ctx context.Context, in io.Reader) {
 	fmt.Println(WelcomeMsg)
 	fmt.Println(HelpMsg)
 
-	scanner := bufio.NewScanner(os.Stdin)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 	for {
 		fmt.Print("\n" + PrompStr)
 		if !scanner.Scan() {
@@ -49,11 +59,30 @@ func (a *App) runInteractiveCLI(ctx context.Context) {
 			a.cliAsk(ctx, strings.Join(parts[1:], " "))
 
 		case "remember":
+			// A trailing "--tags a,b,c" tags the memory
+			tags := ""
+			if n := len(parts); n >= 5 && parts[n-2] == "--tags" {
+				tags, parts = parts[n-1], parts[:n-2]
+			}
 			if len(parts) < 3 {
-				fmt.Println("Usage: remember <id> <content>")
+				fmt.Println("Usage: remember <id> <content> | remember <id> <<EOF | remember <id> @file, optionally followed by --tags a,b,c")
+				continue
+			}
+			switch arg := parts[2]; {
+			case len(parts) == 3 && strings.HasPrefix(arg, "<<") && len(arg) > 2:
+				a.cliPaste(ctx, scanner, parts[1], arg[2:], tags)
+			case len(parts) == 3 && strings.HasPrefix(arg, "@") && len(arg) > 1:
+				a.cliRememberFile(ctx, scanner, parts[1], arg[1:], tags)
+			default:
+				a.cliRemember(ctx, parts[1], strings.Join(parts[2:], " "), tags)
+			}
+
+		case "paste":
+			if len(parts) != 2 {
+				fmt.Println("Usage: paste <id>, then the content, ended by a line with a single " + PasteTerminator)
 				continue
 			}
-			a.cliRemember(ctx, parts[1], strings.Join(parts[2:], " "))
+			a.cliPaste(ctx, scanner, parts[1], PasteTerminator, "")
 
 		case "search":
 			if len(parts) < 2 {
@@ -62,6 +91,13 @@ func (a *App) runInteractiveCLI(ctx context.Context) {
 			}
 			a.cliSearch(ctx, strings.Join(parts[1:], " "))
 
+		case "get":
+			if len(parts) < 2 {
+				fmt.Println("Usage: get <id>")
+				continue
+			}
+			a.cliGet(ctx, parts[1])
+
 		case "delete":
 			if len(parts) < 2 {
 				fmt.Println("Usage: delete <id>")
@@ -69,6 +105,13 @@ func (a *App) runInteractiveCLI(ctx context.Context) {
 			}
 			a.cliDelete(ctx, parts[1])
 
+		case "forget":
+			if len(parts) < 2 {
+				fmt.Println("Usage: forget <query>")
+				continue
+			}
+			a.cliForget(ctx, scanner, strings.Join(parts[1:], " "))
+
 		case "tag":
 			if len(parts) < 3 {
 				fmt.Println("Usage: tag <memory_id> <tag>")
@@ -81,7 +124,7 @@ func (a *App) runInteractiveCLI(ctx context.Context) {
 
 		case "context":
 			if len(parts) < 2 {
-				fmt.Println("Usage: context <list|create|switch>")
+				fmt.Println("Usage: context <list|create|rename|switch|delete>")
 				continue
 			}
 			subCmd := strings.ToLower(parts[1])
@@ -94,16 +137,59 @@ func (a *App) runInteractiveCLI(ctx context.Context) {
 					continue
 				}
 				a.cliCreateContext(ctx, parts[2], parts[3])
+			case "rename":
+				if len(parts) < 4 {
+					fmt.Println("Usage: context rename <id> <new name>")
+					continue
+				}
+				a.cliRenameContext(ctx, parts[2], strings.Join(parts[3:], " "))
 			case "switch":
 				if len(parts) < 3 {
 					fmt.Println("Usage: context switch <id>")
 					continue
 				}
 				a.cliSwitchContext(ctx, parts[2])
+			case "delete":
+				if len(parts) < 3 {
+					fmt.Println("Usage: context delete <id> [abort_if_nonempty|reassign|cascade]")
+					continue
+				}
+				strategy := ""
+				if len(parts) > 3 {
+					strategy = parts[3]
+				}
+				a.cliDeleteContext(ctx, parts[2], strategy)
 			default:
-				fmt.Println("Unknown context command. Try: context list|create|switch")
+				fmt.Println("Unknown context command. Try: context list|create|rename|switch|delete")
 			}
 
+		case "compare":
+			textA, textB, ok := strings.Cut(strings.Join(parts[1:], " "), "|")
+			if !ok || strings.TrimSpace(textA) == "" || strings.TrimSpace(textB) == "" {
+				fmt.Println("Usage: compare <text a> | <text b>")
+				continue
+			}
+			a.cliCompare(ctx, strings.TrimSpace(textA), strings.TrimSpace(textB))
+
+		case "history":
+			if len(parts) < 2 {
+				fmt.Println("Usage: history <id>")
+				continue
+			}
+			a.cliHistory(ctx, parts[1])
+
+		case "restore":
+			if len(parts) < 3 {
+				fmt.Println("Usage: restore <id> <version>")
+				continue
+			}
+			version, err := strconv.Atoi(parts[2])
+			if err != nil {
+				fmt.Println("Usage: restore <id> <version>")
+				continue
+			}
+			a.cliRestore(ctx, parts[1], version)
+
 		case "save":
 			a.cliSaveToDisk(ctx)
 
@@ -113,14 +199,121 @@ func (a *App) runInteractiveCLI(ctx context.Context) {
 	}
 }
 
-// cliRemember executes the remember operation from CLI.
-func (a *App) cliRemember(ctx context.Context, id, content string) {
+// cliRemember executes the remember operation from CLI. tags is a
+// comma-separated list of tags to apply, or empty.
+func (a *App) cliRemember(ctx context.Context, id, content, tags string) {
 	req := mcp.CallToolRequest{}
-	req.Params.Arguments = map[string]any{"id": id, "content": content}
+	req.Params.Arguments = map[string]any{"id": id, "content": content, "tags": tags}
 	res, _ := a.rememberHandler(ctx, req)
 	fmt.Println(res.Content[0].(mcp.TextContent).Text)
 }
 
+// cliPaste reads lines until one equal to terminator and stores them, with
+// their newlines, as a memory. Input that ends before the terminator stores
+// nothing.
+func (a *App) cliPaste(ctx context.Context, scanner *bufio.Scanner, id, terminator, tags string) {
+	fmt.Printf("Enter content, end with a line containing only %s\n", terminator)
+	var lines []string
+	for {
+		if !scanner.Scan() {
+			fmt.Println("\nPaste interrupted, nothing stored.")
+			return
+		}
+		line := scanner.Text()
+		if strings.TrimRight(line, "\r") == terminator {
+			break
+		}
+		lines = append(lines, line)
+	}
+	a.cliRememberLong(ctx, scanner, id, strings.Join(lines, "\n"), tags)
+}
+
+// cliRememberFile stores the content of a file as a memory. The path is
+// resolved against the working directory and may not leave it.
+func (a *App) cliRememberFile(ctx context.Context, scanner *bufio.Scanner, id, path, tags string) {
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Printf("Cannot resolve %s: %v\n", path, err)
+		return
+	}
+	resolved, err := confinePath(wd, path)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		fmt.Printf("Cannot read %s: %v\n", path, err)
+		return
+	}
+	if !utf8.Valid(data) {
+		fmt.Printf("%s is not a text file\n", path)
+		return
+	}
+	a.cliRememberLong(ctx, scanner, id, strings.TrimRight(string(data), "\r\n"), tags)
+}
+
+// cliRememberLong stores multi-line content, asking for confirmation first
+// when it is longer than CLIConfirmChars.
+func (a *App) cliRememberLong(ctx context.Context, scanner *bufio.Scanner, id, content, tags string) {
+	if strings.TrimSpace(content) == "" {
+		fmt.Println("Nothing to store.")
+		return
+	}
+	if n := utf8.RuneCountInString(content); n > CLIConfirmChars {
+		fmt.Printf("Store %d characters as '%s'? [y/N] ", n, id)
+		if !scanner.Scan() {
+			fmt.Println("\nNothing stored.")
+			return
+		}
+		if answer := strings.ToLower(strings.TrimSpace(scanner.Text())); answer != "y" && answer != "yes" {
+			fmt.Println("Nothing stored.")
+			return
+		}
+	}
+	a.cliRemember(ctx, id, content, tags)
+}
+
+// confinePath resolves path against root and returns an error if the result,
+// after following symlinks, lies outside root or is root itself. The path
+// need not exist yet.
+func confinePath(root, path string) (string, error) {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s: %v", root, err)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	resolved, err := resolvePath(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s: %v", path, err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside %s", path, root)
+	}
+	return resolved, nil
+}
+
+// resolvePath follows the symlinks in path. A path that does not exist yet
+// resolves through its nearest existing ancestor.
+func resolvePath(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err == nil || !os.IsNotExist(err) {
+		return resolved, err
+	}
+	parent := filepath.Dir(path)
+	if parent == path {
+		return "", err
+	}
+	dir, err := resolvePath(parent)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, filepath.Base(path)), nil
+}
+
 // cliSearch executes the search operation from CLI.
 func (a *App) cliSearch(ctx context.Context, query string) {
 	req := mcp.CallToolRequest{}
@@ -129,20 +322,35 @@ func (a *App) cliSearch(ctx context.Context, query string) {
 	fmt.Println(res.Content[0].(mcp.TextContent).Text)
 }
 
-// cliAsk executes the ask_brain operation from CLI.
+// cliAsk executes the ask_brain operation from CLI, printing the answer as it
+// is generated and the sources once it is complete.
 func (a *App) cliAsk(ctx context.Context, question string) {
 	req := mcp.CallToolRequest{}
 	req.Params.Arguments = map[string]any{"question": question}
+	var streamed strings.Builder
+	ctx = withAnswerStream(ctx, func(text string) {
+		streamed.WriteString(text)
+		fmt.Print(text)
+	})
 	res, err := a.askBrainHandler(ctx, req)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 	} else if res.IsError {
 		fmt.Printf("Error: %v\n", res.Content[0].(mcp.TextContent).Text)
 	} else {
-		fmt.Println(res.Content[0].(mcp.TextContent).Text)
+		text := res.Content[0].(mcp.TextContent).Text
+		fmt.Println(strings.TrimPrefix(text, streamed.String()))
 	}
 }
 
+// cliGet retrieves a single memory by ID from CLI.
+func (a *App) cliGet(ctx context.Context, id string) {
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"id": id}
+	res, _ := a.getMemoryHandler(ctx, req)
+	fmt.Println(res.Content[0].(mcp.TextContent).Text)
+}
+
 // cliDelete executes the delete operation from CLI.
 func (a *App) cliDelete(ctx context.Context, id string) {
 	req := mcp.CallToolRequest{}
@@ -151,6 +359,32 @@ func (a *App) cliDelete(ctx context.Context, id string) {
 	fmt.Println(res.Content[0].(mcp.TextContent).Text)
 }
 
+// cliForget lists the memories forget_topic would delete for query and
+// deletes them once confirmed.
+func (a *App) cliForget(ctx context.Context, scanner *bufio.Scanner, query string) {
+	matches, err := a.topicMatches(ctx, query, DefaultForgetThreshold)
+	if err != nil {
+		fmt.Printf("Error: Search failed: %v\n", err)
+		return
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No memories are at least %.2f similar to '%s'.\n", DefaultForgetThreshold, query)
+		return
+	}
+	fmt.Print(formatTopicMatches(matches))
+	fmt.Printf("Delete these %d memories? [y/N] ", len(matches))
+	if !scanner.Scan() {
+		fmt.Println("\nNothing deleted.")
+		return
+	}
+	if answer := strings.ToLower(strings.TrimSpace(scanner.Text())); answer != "y" && answer != "yes" {
+		fmt.Println("Nothing deleted.")
+		return
+	}
+	result := a.forgetMemories(ctx, query, matches)
+	fmt.Print(formatForgetResult(matches, result, a.settings().SoftDelete))
+}
+
 // cliList executes the list operation from CLI.
 func (a *App) cliList(ctx context.Context) {
 	req := mcp.CallToolRequest{}
@@ -195,6 +429,14 @@ func (a *App) cliCreateContext(ctx context.Context, id, name string) {
 	fmt.Println(res.Content[0].(mcp.TextContent).Text)
 }
 
+// cliRenameContext renames a context from CLI.
+func (a *App) cliRenameContext(ctx context.Context, id, name string) {
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"context_id": id, "name": name}
+	res, _ := a.updateContextHandler(ctx, req)
+	fmt.Println(res.Content[0].(mcp.TextContent).Text)
+}
+
 // cliSwitchContext switches to a different context from CLI.
 func (a *App) cliSwitchContext(ctx context.Context, contextID string) {
 	req := mcp.CallToolRequest{}
@@ -203,9 +445,41 @@ func (a *App) cliSwitchContext(ctx context.Context, contextID string) {
 	fmt.Println(res.Content[0].(mcp.TextContent).Text)
 }
 
+// cliDeleteContext deletes a context from CLI.
+func (a *App) cliDeleteContext(ctx context.Context, contextID, strategy string) {
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"context_id": contextID, "strategy": strategy}
+	res, _ := a.deleteContextHandler(ctx, req)
+	fmt.Println(res.Content[0].(mcp.TextContent).Text)
+}
+
 // cliSaveToDisk saves the database and context state from CLI.
 func (a *App) cliSaveToDisk(ctx context.Context) {
 	req := mcp.CallToolRequest{}
 	res, _ := a.saveToDiskHandler(ctx, req)
 	fmt.Println(res.Content[0].(mcp.TextContent).Text)
 }
+
+// cliCompare executes the embed_compare operation from CLI.
+func (a *App) cliCompare(ctx context.Context, textA, textB string) {
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"text_a": textA, "text_b": textB}
+	res, _ := a.embedCompareHandler(ctx, req)
+	fmt.Println(res.Content[0].(mcp.TextContent).Text)
+}
+
+// cliHistory executes the get_memory_history operation from CLI.
+func (a *App) cliHistory(ctx context.Context, id string) {
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"memory_id": id}
+	res, _ := a.getMemoryHistoryHandler(ctx, req)
+	fmt.Println(res.Content[0].(mcp.TextContent).Text)
+}
+
+// cliRestore executes the restore_version operation from CLI.
+func (a *App) cliRestore(ctx context.Context, id string, version int) {
+	req := mcp.CallToolRequest{}
+	req.Params.Arguments = map[string]any{"memory_id": id, "version_number": float64(version)}
+	res, _ := a.restoreVersionHandler(ctx, req)
+	fmt.Println(res.Content[0].(mcp.TextContent).Text)
+}
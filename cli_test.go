@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// runScript feeds script to the CLI line by line and returns what it printed.
+func (ta *testApp) runScript(t *testing.T, script string) string {
+	t.Helper()
+	return captureStdout(t, func() { ta.runCLI(t.Context(), strings.NewReader(script)) })
+}
+
+// storedContent returns the content of a stored memory, or "" if there is none.
+func (ta *testApp) storedContent(t *testing.T, id string) string {
+	t.Helper()
+	doc, err := ta.vectorStore.GetByID(t.Context(), id)
+	if err != nil {
+		return ""
+	}
+	return doc.Content
+}
+
+func TestCLIHeredoc(t *testing.T) {
+	ta := newTestApp(t, nil)
+	out := ta.runScript(t, "remember poem <<END --tags verse,draft\n"+
+		"Roses are red\n"+
+		"\n"+
+		"  violets are blue\n"+
+		"END is not the end\n"+
+		"END\r\n"+
+		"search roses\n")
+
+	if got, want := ta.storedContent(t, "poem"), "Roses are red\n\n  violets are blue\nEND is not the end"; got != want {
+		t.Errorf("stored %q, want %q", got, want)
+	}
+	if doc, _ := ta.vectorStore.GetByID(t.Context(), "poem"); doc.Metadata["tags"] != EncodeTags([]string{"verse", "draft"}) {
+		t.Errorf("tags = %q", doc.Metadata["tags"])
+	}
+	if !strings.Contains(out, "end with a line containing only END") || !strings.Contains(out, "[poem]") {
+		t.Errorf("CLI output:\n%s", out)
+	}
+}
+
+func TestCLIPaste(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.runScript(t, "paste note\nfirst line\n. not the end\n.\nremember after one line\n")
+	if got := ta.storedContent(t, "note"); got != "first line\n. not the end" {
+		t.Errorf("pasted %q", got)
+	}
+	// The REPL goes on reading commands after the paste
+	if got := ta.storedContent(t, "after"); got != "one line" {
+		t.Errorf("command after the paste stored %q", got)
+	}
+
+	out := ta.runScript(t, "paste empty\n.\npaste\n")
+	if ta.storedContent(t, "empty") != "" || !strings.Contains(out, "Nothing to store.") || !strings.Contains(out, "Usage: paste <id>") {
+		t.Errorf("CLI output:\n%s", out)
+	}
+}
+
+func TestCLIInterruptedPaste(t *testing.T) {
+	ta := newTestApp(t, nil)
+	for _, script := range []string{
+		"paste cut\nfirst line\nsecond line",
+		"remember cut <<EOF\nfirst line\n.\n",
+	} {
+		out := ta.runScript(t, script)
+		if !strings.Contains(out, "Paste interrupted, nothing stored.") {
+			t.Errorf("CLI output for %q:\n%s", script, out)
+		}
+		if got := ta.storedContent(t, "cut"); got != "" {
+			t.Errorf("an interrupted paste stored %q", got)
+		}
+	}
+}
+
+func TestCLIConfirmsLongContent(t *testing.T) {
+	ta := newTestApp(t, nil)
+	long := strings.Repeat("ä", CLIConfirmChars+1)
+
+	out := ta.runScript(t, "paste long\n"+long+"\n.\nno\n")
+	if ta.storedContent(t, "long") != "" || !strings.Contains(out, "Store 1001 characters as 'long'? [y/N]") {
+		t.Errorf("declined content was stored, or the count is wrong:\n%s", out)
+	}
+	ta.runScript(t, "paste long\n"+long+"\n.\n")
+	if ta.storedContent(t, "long") != "" {
+		t.Error("content was stored without an answer")
+	}
+	ta.runScript(t, "paste long\n"+long+"\n.\nY\n")
+	if ta.storedContent(t, "long") != long {
+		t.Error("confirmed content was not stored")
+	}
+
+	// Content at the threshold is stored without asking
+	out = ta.runScript(t, "paste short\n"+long[:len(long)-len("ä")]+"\n.\n")
+	if strings.Contains(out, "[y/N]") || ta.storedContent(t, "short") == "" {
+		t.Errorf("content of exactly %d characters:\n%s", CLIConfirmChars, out)
+	}
+}
+
+func TestCLIRememberFile(t *testing.T) {
+	ta := newTestApp(t, nil)
+	root := t.TempDir()
+	outside := t.TempDir()
+	for path, data := range map[string]string{
+		filepath.Join(root, "notes.txt"):       "line one\nline two\n\n",
+		filepath.Join(root, "image.bin"):       "\xff\xfe\x00",
+		filepath.Join(outside, "secret.txt"):   "not for the brain",
+		filepath.Join(root, "sub", "deep.txt"): "deep",
+	} {
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	out := ta.runScript(t, "remember notes @notes.txt --tags file\n"+
+		"remember deep @sub/deep.txt\n"+
+		"remember secret @"+filepath.Join(outside, "secret.txt")+"\n"+
+		"remember escape @../"+filepath.Base(outside)+"/secret.txt\n"+
+		"remember link @link.txt\n"+
+		"remember missing @missing.txt\n"+
+		"remember image @image.bin\n")
+
+	if got := ta.storedContent(t, "notes"); got != "line one\nline two" {
+		t.Errorf("notes = %q, want the file without trailing newlines", got)
+	}
+	if got := ta.storedContent(t, "deep"); got != "deep" {
+		t.Errorf("deep = %q", got)
+	}
+	for _, id := range []string{"secret", "escape", "link", "missing", "image"} {
+		if got := ta.storedContent(t, id); got != "" {
+			t.Errorf("%s stored %q", id, got)
+		}
+	}
+	if strings.Count(out, "is outside") != 3 || !strings.Contains(out, "Cannot read missing.txt") || !strings.Contains(out, "image.bin is not a text file") {
+		t.Errorf("CLI output:\n%s", out)
+	}
+}
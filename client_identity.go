@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// clientIDKey is the context key of the client ID of a tool call.
+type clientIDKey struct{}
+
+// WithClientID attaches the calling client's ID to ctx.
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDKey{}, clientID)
+}
+
+// ClientIdentities maps MCP session IDs to the client IDs sessions, current
+// contexts and the "client" metadata of memories are recorded under. A nil
+// *ClientIdentities knows no sessions.
+type ClientIdentities struct {
+	mu  sync.Mutex
+	ids map[string]string // MCP session ID -> client ID
+}
+
+// NewClientIdentities creates an empty mapping.
+func NewClientIdentities() *ClientIdentities {
+	return &ClientIdentities{ids: make(map[string]string)}
+}
+
+// Assign returns the client ID of an MCP session, assigning one derived from
+// the client's name if the session has none yet. created reports whether the
+// ID was assigned by this call.
+func (ci *ClientIdentities) Assign(sessionID, clientName string) (clientID string, created bool) {
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	if clientID, ok := ci.ids[sessionID]; ok {
+		return clientID, false
+	}
+	clientID = newClientID(clientName)
+	ci.ids[sessionID] = clientID
+	return clientID, true
+}
+
+// Remove forgets an MCP session and returns its client ID.
+func (ci *ClientIdentities) Remove(sessionID string) (string, bool) {
+	if ci == nil {
+		return "", false
+	}
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	clientID, ok := ci.ids[sessionID]
+	delete(ci.ids, sessionID)
+	return clientID, ok
+}
+
+// Connected reports whether an MCP session with clientID is connected.
+func (ci *ClientIdentities) Connected(clientID string) bool {
+	if ci == nil {
+		return false
+	}
+	ci.mu.Lock()
+	defer ci.mu.Unlock()
+	for _, id := range ci.ids {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// newClientID builds a client ID from the name a client reports on
+// initialize, e.g. "claude-desktop-3f9a1c2b7d4e". The random suffix tells
+// apart several connections of the same client, which transports such as
+// stdio give the same session ID.
+func newClientID(clientName string) string {
+	slug := strings.Trim(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		}
+		return '-'
+	}, clientName), "-")
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	if slug == "" {
+		slug = "client"
+	}
+	return newRequestID(slug)
+}
+
+// clientIDFrom returns the ID of the client making the request in ctx: the
+// one the tool middleware attached, else the one of the MCP session, else
+// a.clientID, which the CLI and background tasks act as.
+func (a *App) clientIDFrom(ctx context.Context) string {
+	if clientID, ok := ctx.Value(clientIDKey{}).(string); ok && clientID != "" {
+		return clientID
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return a.sessionClientID(ctx, session)
+	}
+	return a.clientID
+}
+
+// sessionClientID returns the client ID of an MCP session. The client's name
+// is read from the session when the transport keeps it.
+func (a *App) sessionClientID(ctx context.Context, session server.ClientSession) string {
+	if a.clients == nil {
+		return a.clientID
+	}
+	name := ""
+	if info, ok := session.(server.SessionWithClientInfo); ok {
+		name = info.GetClientInfo().Name
+	}
+	return a.connectClient(ctx, session.SessionID(), name)
+}
+
+// connectClient returns the client ID of an MCP session, assigning one and
+// registering a context session for it on first use.
+func (a *App) connectClient(ctx context.Context, sessionID, clientName string) string {
+	clientID, created := a.clients.Assign(sessionID, clientName)
+	if !created {
+		return clientID
+	}
+	if err := a.ctx.RegisterSession(clientID); err != nil {
+		a.logf(ctx, "Warning: Failed to register session for client %s: %v", clientID, err)
+	} else {
+		a.logf(ctx, "Client %s connected (MCP session %s)", clientID, sessionID)
+	}
+	return clientID
+}
+
+// clientHooks registers a context session for every MCP session once the
+// client has introduced itself and removes it when the client disconnects.
+func (a *App) clientHooks() *server.Hooks {
+	hooks := &server.Hooks{}
+	hooks.AddAfterInitialize(func(ctx context.Context, id any, request *mcp.InitializeRequest, result *mcp.InitializeResult) {
+		if session := server.ClientSessionFromContext(ctx); session != nil && a.clients != nil {
+			a.connectClient(ctx, session.SessionID(), request.Params.ClientInfo.Name)
+		}
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, session server.ClientSession) {
+		clientID, ok := a.clients.Remove(session.SessionID())
+		if !ok {
+			return
+		}
+		if err := a.ctx.UnregisterSession(clientID); err != nil {
+			a.logger.Printf("Warning: Failed to unregister session for client %s: %v", clientID, err)
+			return
+		}
+		a.logger.Printf("Client %s disconnected", clientID)
+	})
+	return hooks
+}
+
+// clientIdentityMiddleware attaches the calling client's ID to every tool
+// call and records the call as activity of its session.
+func (a *App) clientIdentityMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		clientID := a.clientIDFrom(ctx)
+		a.ctx.UpdateActivity(clientID)
+		return next(WithClientID(ctx, clientID), request)
+	}
+}
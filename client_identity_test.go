@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newSessionServer returns an MCP server wired to ta like the one main
+// starts, serving remember and switch_context.
+func newSessionServer(ta *testApp) *server.MCPServer {
+	ta.clients = NewClientIdentities()
+	srv := server.NewMCPServer("brainmcp-test", "0",
+		server.WithToolHandlerMiddleware(ta.clientIdentityMiddleware),
+		server.WithHooks(ta.clientHooks()),
+	)
+	srv.AddTool(mcp.NewTool("remember"), ta.rememberHandler)
+	srv.AddTool(mcp.NewTool("switch_context"), ta.switchContextHandler)
+	return srv
+}
+
+// sendMCP sends a JSON-RPC request to srv on the session in ctx and returns
+// its result.
+func sendMCP(t *testing.T, srv *server.MCPServer, ctx context.Context, method string, params any) any {
+	t.Helper()
+	message, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	reply := srv.HandleMessage(ctx, message)
+	response, ok := reply.(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("%s replied %#v", method, reply)
+	}
+	return response.Result
+}
+
+// connectSession registers an MCP session with srv and initializes it as
+// clientName, returning the context its requests run in.
+func connectSession(t *testing.T, srv *server.MCPServer, sessionID, clientName string) context.Context {
+	t.Helper()
+	session := &notifyingSession{id: sessionID, notifications: make(chan mcp.JSONRPCNotification, 16)}
+	if err := srv.RegisterSession(t.Context(), session); err != nil {
+		t.Fatalf("RegisterSession %s: %v", sessionID, err)
+	}
+	ctx := srv.WithContext(t.Context(), session)
+	sendMCP(t, srv, ctx, "initialize", map[string]any{
+		"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+		"clientInfo":      map[string]any{"name": clientName, "version": "1.0"},
+		"capabilities":    map[string]any{},
+	})
+	return ctx
+}
+
+// callTool calls a tool on srv from the session in ctx.
+func callTool(t *testing.T, srv *server.MCPServer, ctx context.Context, name string, args map[string]any) (string, bool) {
+	t.Helper()
+	result := sendMCP(t, srv, ctx, "tools/call", map[string]any{"name": name, "arguments": args}).(*mcp.CallToolResult)
+	return result.Content[0].(mcp.TextContent).Text, result.IsError
+}
+
+func TestSessionsHaveTheirOwnClientIdentity(t *testing.T) {
+	ta := newTestApp(t, nil)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	srv := newSessionServer(ta)
+	desktop := connectSession(t, srv, "session-1", "Claude Desktop")
+	editor := connectSession(t, srv, "session-2", "Code Editor")
+
+	if text, isErr := callTool(t, srv, desktop, "switch_context", map[string]any{"context_id": "work"}); isErr {
+		t.Fatalf("switch_context: %s", text)
+	}
+	for ctx, id := range map[context.Context]string{desktop: "from-desktop", editor: "from-editor"} {
+		if text, isErr := callTool(t, srv, ctx, "remember", map[string]any{"id": id, "content": "noted by " + id}); isErr {
+			t.Fatalf("remember %s: %s", id, text)
+		}
+	}
+
+	metadata := func(id string) map[string]string {
+		doc, err := ta.vectorStore.GetByID(t.Context(), id)
+		if err != nil {
+			t.Fatalf("GetByID %s: %v", id, err)
+		}
+		return doc.Metadata
+	}
+	desktopID, editorID := metadata("from-desktop")["client"], metadata("from-editor")["client"]
+	if !strings.HasPrefix(desktopID, "claude-desktop-") || !strings.HasPrefix(editorID, "code-editor-") {
+		t.Fatalf("memories were recorded for clients %q and %q", desktopID, editorID)
+	}
+	// Switching context in one session leaves the other where it was
+	if got := metadata("from-desktop")["context"]; got != "work" {
+		t.Errorf("desktop memory stored in %q, want work", got)
+	}
+	if got := metadata("from-editor")["context"]; got != DefaultContextID {
+		t.Errorf("editor memory stored in %q, want %s", got, DefaultContextID)
+	}
+	for clientID, want := range map[string]string{desktopID: "work", editorID: DefaultContextID, ta.clientID: DefaultContextID} {
+		if got, err := ta.ctx.GetClientContext(clientID); err != nil || got != want {
+			t.Errorf("current context of %s = %q, %v; want %s", clientID, got, err, want)
+		}
+	}
+
+	// A second connection of the same client gets an ID of its own
+	again := connectSession(t, srv, "session-3", "Claude Desktop")
+	callTool(t, srv, again, "remember", map[string]any{"id": "from-desktop-again", "content": "noted again"})
+	if againID := metadata("from-desktop-again")["client"]; againID == desktopID || !strings.HasPrefix(againID, "claude-desktop-") {
+		t.Errorf("second desktop connection has client ID %q, first %q", againID, desktopID)
+	}
+	if got := metadata("from-desktop-again")["context"]; got != DefaultContextID {
+		t.Errorf("second desktop connection starts in %q, want %s", got, DefaultContextID)
+	}
+
+	// Disconnecting removes only that session
+	srv.UnregisterSession(t.Context(), "session-1")
+	if _, err := ta.ctx.GetSession(desktopID); err == nil || ta.clients.Connected(desktopID) {
+		t.Error("the disconnected session is still registered")
+	}
+	if _, err := ta.ctx.GetSession(editorID); err != nil || !ta.clients.Connected(editorID) {
+		t.Errorf("the other session was removed too: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// cloneMemoryHandler handles the clone_memory tool - copies a memory to a new
+// ID. The copy keeps the content, context, tags and stored embedding, so
+// nothing is embedded again, and records the original in cloned_from. Chunks
+// of a long memory are copied along with it.
+func (a *App) cloneMemoryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	sourceID, _ := args["source_id"].(string)
+	newID, _ := args["new_id"].(string)
+
+	sourceID = strings.TrimSpace(sourceID)
+	newID = strings.TrimSpace(newID)
+
+	if sourceID == "" {
+		return mcp.NewToolResultError("Source ID cannot be empty"), nil
+	}
+	if newID == "" {
+		return mcp.NewToolResultError("New ID cannot be empty"), nil
+	}
+	if newID == sourceID {
+		return mcp.NewToolResultError("New ID must differ from the source ID"), nil
+	}
+
+	source, err := a.vectorStore.GetByID(ctx, sourceID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory not found: %v", err)), nil
+	}
+	if msg := a.memoryAccessDenied(ctx, source); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+	if isChunk(source.Metadata) {
+		return mcp.NewToolResultError(fmt.Sprintf("'%s' is a chunk; clone its memory '%s' instead", sourceID, source.Metadata["parent_id"])), nil
+	}
+	if isSoftDeleted(source.Metadata) {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' is in the trash; restore it before cloning", sourceID)), nil
+	}
+	if _, err := a.vectorStore.GetByID(ctx, newID); err == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' already exists", newID)), nil
+	}
+
+	contextID := source.Metadata["context"]
+	if contextID == "" {
+		contextID = DefaultContextID
+	}
+	if msg := a.contextCapExceeded(ctx, contextID, newID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	entry := AuditEntry{Tool: "clone_memory", MemoryIDs: []string{newID}, ClientID: a.clientIDFrom(ctx), ContextID: contextID, Status: "ok",
+		Details: fmt.Sprintf("cloned from %q", sourceID)}
+	documents := []chromem.Document{cloneDocument(source, newID, sourceID, a.clientIDFrom(ctx))}
+	if isChunkedParent(source.Metadata) {
+		chunks, err := a.chunksOf(ctx, sourceID)
+		if err != nil {
+			entry.Status, entry.Details = "error", err.Error()
+			a.recordAudit(ctx, entry)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to read chunks of '%s': %v", sourceID, err)), nil
+		}
+		for _, chunk := range chunks {
+			clone := cloneDocument(chunk, newID+strings.TrimPrefix(chunk.ID, sourceID), sourceID, a.clientIDFrom(ctx))
+			clone.Metadata["parent_id"] = newID
+			documents = append(documents, clone)
+		}
+	}
+	if err := a.vectorStore.AddDocuments(ctx, documents, 1); err != nil {
+		entry.Status, entry.Details = "error", err.Error()
+		a.recordAudit(ctx, entry)
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to store clone: %v", err)), nil
+	}
+
+	clone := documents[0]
+	a.recordVersion(ctx, newID, clone.Content, contextID, ParseTags(clone.Metadata["tags"]), fmt.Sprintf("Cloned from '%s'", sourceID))
+	a.countTags(ctx, ParseTags(clone.Metadata["tags"]))
+	if err := a.ctx.IncrementMemoryCount(contextID); err != nil {
+		a.logf(ctx, "Warning: Failed to update context count: %v", err)
+	}
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	a.recordAudit(ctx, entry)
+
+	return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' cloned to '%s' in context '%s'.", sourceID, newID, contextID)), nil
+}
+
+// cloneDocument copies doc under newID with a fresh creation time, author and
+// cloned_from. Access statistics and merge records belong to the original and
+// are not copied.
+func cloneDocument(doc chromem.Document, newID, sourceID, clientID string) chromem.Document {
+	metadata := make(map[string]string, len(doc.Metadata)+1)
+	for k, v := range doc.Metadata {
+		switch k {
+		case "last_accessed_at", "access_count", "merged_ids":
+		default:
+			metadata[k] = v
+		}
+	}
+	metadata["cloned_from"] = sourceID
+	metadata["client"] = clientID
+	metadata["created_at"] = time.Now().UTC().Format(time.RFC3339)
+	return chromem.Document{ID: newID, Content: doc.Content, Metadata: metadata, Embedding: doc.Embedding}
+}
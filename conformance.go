@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DatanoiseTV/brainmcp/internal/backendtest"
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// BackendFactory creates an empty, throwaway backend that embeds with embed.
+// The returned cleanup function releases everything the backend created.
+// It returns backendtest.ErrSkipped when no instance is available.
+type BackendFactory func(embed chromem.EmbeddingFunc) (VectorBackend, func(), error)
+
+// suite adapts f to the conformance suite.
+func (f BackendFactory) suite() backendtest.Factory {
+	return func(embed chromem.EmbeddingFunc) (backendtest.Backend, func(), error) {
+		backend, cleanup, err := f(embed)
+		if err != nil {
+			return nil, nil, err
+		}
+		return backend, cleanup, nil
+	}
+}
+
+// migrateScenario checks migrateDocuments, which the shared suite cannot
+// reach, against every backend.
+var migrateScenario = backendtest.Scenario{Name: "migrate", Run: conformMigrate}
+
+// conformMigrate copies documents from a scratch local store into the backend
+// and back into another one. Each embedding is of a text other than the
+// content, so a copy that embeds the content again is caught.
+func conformMigrate(ctx context.Context, b backendtest.Backend) error {
+	dst := b.(VectorBackend)
+	src, cleanupSrc, err := localScratchBackend(backendtest.Embedding)
+	if err != nil {
+		return fmt.Errorf("source store: %w", err)
+	}
+	defer cleanupSrc()
+	want := make(map[string][]float32)
+	var docs []chromem.Document
+	for i, text := range []string{"alpha bravo", "charlie delta", "echo foxtrot"} {
+		embedding, _ := backendtest.Embedding(ctx, "unrelated "+text)
+		id := "g-" + strconv.Itoa(i)
+		want[id] = embedding
+		docs = append(docs, chromem.Document{ID: id, Content: text, Embedding: embedding, Metadata: map[string]string{"context": "a"}})
+	}
+	if err := src.AddDocuments(ctx, docs, 1); err != nil {
+		return fmt.Errorf("AddDocuments: %w", err)
+	}
+
+	back, cleanupBack, err := localScratchBackend(backendtest.Embedding)
+	if err != nil {
+		return fmt.Errorf("return store: %w", err)
+	}
+	defer cleanupBack()
+	for _, hop := range []struct{ from, to VectorBackend }{{src, dst}, {dst, back}} {
+		if n, err := migrateDocuments(ctx, hop.from, hop.to, backendtest.Dimension, io.Discard); err != nil || n != len(docs) {
+			return fmt.Errorf("migrateDocuments = %d (err %v), want %d", n, err, len(docs))
+		}
+		listed, err := hop.to.ListDocuments(ctx, nil, 0, 0)
+		if err != nil {
+			return fmt.Errorf("ListDocuments: %w", err)
+		}
+		for _, doc := range listed {
+			if sim := vecmath.Cosine(doc.Embedding, want[doc.ID]); sim < 0.9999 {
+				return fmt.Errorf("embedding of %s was not copied (similarity %.4f)", doc.ID, sim)
+			}
+		}
+	}
+
+	if _, err := migrateDocuments(ctx, src, dst, backendtest.Dimension+1, io.Discard); err == nil {
+		return fmt.Errorf("migrateDocuments into a store of another dimension succeeded")
+	}
+	return nil
+}
+
+// localScratchBackend creates a chromem store in a temporary directory.
+func localScratchBackend(embed chromem.EmbeddingFunc) (VectorBackend, func(), error) {
+	dir, err := os.MkdirTemp("", "brainmcp-conformance-")
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := NewLocalVectorStore(filepath.Join(dir, "brain_memory.bin"), nil, embed, nil, 0, nil)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	return store, func() { os.RemoveAll(dir) }, nil
+}
+
+// qdrantScratchBackend creates a uniquely named collection on the Qdrant
+// instance given by BRAINMCP_CONFORMANCE_QDRANT (host:port). The suite is
+// skipped when the variable is unset, since it needs a live server.
+func qdrantScratchBackend(embed chromem.EmbeddingFunc) (VectorBackend, func(), error) {
+	addr := os.Getenv("BRAINMCP_CONFORMANCE_QDRANT")
+	if addr == "" {
+		return nil, nil, fmt.Errorf("%w: set BRAINMCP_CONFORMANCE_QDRANT=host:port to run against a live instance", backendtest.ErrSkipped)
+	}
+	host, portStr, ok := strings.Cut(addr, ":")
+	if !ok {
+		portStr = "6334"
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid BRAINMCP_CONFORMANCE_QDRANT port %q: %w", portStr, err)
+	}
+	collName := fmt.Sprintf("brainmcp-conformance-%d", time.Now().UnixNano())
+	store, err := newQdrantVectorStore(host, port, "", false, collName, backendtest.Dimension, embed, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		store.client.DeleteCollection(context.Background(), collName)
+		store.Close()
+	}
+	return store, cleanup, nil
+}
+
+// pgvectorScratchBackend creates a uniquely named table in the PostgreSQL
+// database given by BRAINMCP_CONFORMANCE_PGVECTOR (a DSN). The suite is
+// skipped when the variable is unset, since it needs a live server.
+func pgvectorScratchBackend(embed chromem.EmbeddingFunc) (VectorBackend, func(), error) {
+	dsn := os.Getenv("BRAINMCP_CONFORMANCE_PGVECTOR")
+	if dsn == "" {
+		return nil, nil, fmt.Errorf("%w: set BRAINMCP_CONFORMANCE_PGVECTOR to a PostgreSQL DSN to run against a live instance", backendtest.ErrSkipped)
+	}
+	tableName := fmt.Sprintf("brainmcp_conformance_%d", time.Now().UnixNano())
+	store, err := NewPgvectorVectorStore(context.Background(), dsn, tableName, backendtest.Dimension, embed, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		store.pool.Exec(context.Background(), "DROP TABLE IF EXISTS "+store.table)
+		store.Close()
+	}
+	return store, cleanup, nil
+}
+
+// redisScratchBackend creates a uniquely named index on the Redis instance
+// (with RediSearch) given by BRAINMCP_CONFORMANCE_REDIS (host:port). The
+// suite is skipped when the variable is unset, since it needs a live server.
+func redisScratchBackend(embed chromem.EmbeddingFunc) (VectorBackend, func(), error) {
+	addr := os.Getenv("BRAINMCP_CONFORMANCE_REDIS")
+	if addr == "" {
+		return nil, nil, fmt.Errorf("%w: set BRAINMCP_CONFORMANCE_REDIS=host:port to run against a live instance", backendtest.ErrSkipped)
+	}
+	indexName := fmt.Sprintf("brainmcp-conformance-%d", time.Now().UnixNano())
+	store, err := NewRedisVectorStore(context.Background(), addr, "", 0, indexName, backendtest.Dimension, embed, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() {
+		store.client.FTDropIndexWithArgs(context.Background(), indexName, &redis.FTDropIndexOptions{DeleteDocs: true})
+		store.Close()
+	}
+	return store, cleanup, nil
+}
+
+// backendRegistration describes a selectable vector backend.
+type backendRegistration struct {
+	name    string
+	scratch BackendFactory // Throwaway instances for the conformance suite
+}
+
+// backendRegistry holds the selectable backends and the conformance reports
+// recorded for them during this process.
+type backendRegistry struct {
+	mu       sync.Mutex
+	backends map[string]backendRegistration
+	reports  map[string]backendtest.Report
+}
+
+// newBackendRegistry registers backends. A backend without a scratch factory
+// cannot be tested and is rejected.
+func newBackendRegistry(regs ...backendRegistration) *backendRegistry {
+	r := &backendRegistry{
+		backends: make(map[string]backendRegistration),
+		reports:  make(map[string]backendtest.Report),
+	}
+	for _, reg := range regs {
+		if reg.scratch == nil {
+			panic(fmt.Sprintf("vector backend %q registered without a conformance factory", reg.name))
+		}
+		r.backends[reg.name] = reg
+	}
+	return r
+}
+
+// vectorBackends lists the backends NewVectorBackend can select.
+var vectorBackends = newBackendRegistry(
+	backendRegistration{name: "chromem", scratch: localScratchBackend},
+	backendRegistration{name: "qdrant", scratch: qdrantScratchBackend},
+	backendRegistration{name: "pgvector", scratch: pgvectorScratchBackend},
+	backendRegistration{name: "redis", scratch: redisScratchBackend},
+)
+
+// names returns the registered backend names in order.
+func (r *backendRegistry) names() []string {
+	names := make([]string, 0, len(r.backends))
+	for name := range r.backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// run runs the suite for the named backend and records the report.
+func (r *backendRegistry) run(ctx context.Context, name string) (backendtest.Report, error) {
+	reg, ok := r.backends[name]
+	if !ok {
+		return backendtest.Report{}, fmt.Errorf("unknown vector backend %q", name)
+	}
+	report := backendtest.Check(ctx, name, reg.scratch.suite(), migrateScenario)
+
+	r.mu.Lock()
+	r.reports[name] = report
+	r.mu.Unlock()
+	return report, nil
+}
+
+// verify runs the suite for the named backend once per process and returns an
+// error if any scenario failed.
+func (r *backendRegistry) verify(ctx context.Context, name string, logger *log.Logger) error {
+	r.mu.Lock()
+	report, done := r.reports[name]
+	r.mu.Unlock()
+
+	if !done {
+		var err error
+		if report, err = r.run(ctx, name); err != nil {
+			return err
+		}
+		logger.Printf("Backend conformance: %s", report.Summary())
+	}
+	if !report.Passed() {
+		return fmt.Errorf("vector backend %q failed the conformance suite:\n%s", name, report)
+	}
+	return nil
+}
+
+// completed returns the recorded reports ordered by backend name.
+func (r *backendRegistry) completed() []backendtest.Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reports := make([]backendtest.Report, 0, len(r.reports))
+	for _, name := range r.names() {
+		if report, ok := r.reports[name]; ok {
+			reports = append(reports, report)
+		}
+	}
+	return reports
+}
+
+// runConformance runs the suite against every registered backend, writes the
+// reports to w and returns the process exit code.
+func runConformance(ctx context.Context, w io.Writer) int {
+	code := 0
+	for _, name := range vectorBackends.names() {
+		report, err := vectorBackends.run(ctx, name)
+		if err != nil {
+			fmt.Fprintln(w, err)
+			code = 1
+			continue
+		}
+		fmt.Fprint(w, report)
+		if !report.Passed() {
+			code = 1
+		}
+	}
+	return code
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/DatanoiseTV/brainmcp/internal/backendtest"
+)
+
+// Qdrant, pgvector and Redis need live servers and are skipped unless
+// BRAINMCP_CONFORMANCE_QDRANT, BRAINMCP_CONFORMANCE_PGVECTOR or
+// BRAINMCP_CONFORMANCE_REDIS is set. Run with -race to check the concurrency
+// scenario for data races.
+
+func TestConformanceChromem(t *testing.T) {
+	backendtest.Run(t, BackendFactory(localScratchBackend).suite(), migrateScenario)
+}
+
+func TestConformanceQdrant(t *testing.T) {
+	backendtest.Run(t, BackendFactory(qdrantScratchBackend).suite(), migrateScenario)
+}
+
+func TestConformancePgvector(t *testing.T) {
+	backendtest.Run(t, BackendFactory(pgvectorScratchBackend).suite(), migrateScenario)
+}
+
+func TestConformanceRedis(t *testing.T) {
+	backendtest.Run(t, BackendFactory(redisScratchBackend).suite(), migrateScenario)
+}
+
+func TestBackendRegistryRequiresFactory(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a backend without a conformance factory did not panic")
+		}
+	}()
+	newBackendRegistry(backendRegistration{name: "untested"})
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// consolidationCluster is a group of memories in one context that are all at
+// least the threshold similar to each other. The oldest memory comes first
+// and is the one kept.
+type consolidationCluster struct {
+	Context       string
+	Docs          []chromem.Document
+	MinSimilarity float64 // Lowest pairwise similarity within the cluster
+}
+
+// ids returns the IDs of the cluster's memories, oldest first.
+func (c consolidationCluster) ids() []string {
+	ids := make([]string, len(c.Docs))
+	for i, doc := range c.Docs {
+		ids[i] = doc.ID
+	}
+	return ids
+}
+
+// clusterNearDuplicates groups docs whose pairwise similarity is at least
+// threshold. Memories are only clustered with others in the same context.
+// Working oldest first, each memory not yet clustered starts a cluster that
+// takes every later memory similar enough to all of its members, so a chain
+// of gradually drifting memories does not end up in one cluster. Clusters of
+// a single memory are dropped.
+func clusterNearDuplicates(docs []chromem.Document, threshold float64) []consolidationCluster {
+	sorted := make([]chromem.Document, 0, len(docs))
+	for _, doc := range docs {
+		if len(doc.Embedding) > 0 {
+			sorted = append(sorted, doc)
+		}
+	}
+	// Memories without created_at predate timestamps and count as oldest
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, tj := createdTime(sorted[i]), createdTime(sorted[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	var clusters []consolidationCluster
+	clustered := make([]bool, len(sorted))
+	for i, seed := range sorted {
+		if clustered[i] {
+			continue
+		}
+		members := []int{i}
+		minSim := 1.0
+		for j := i + 1; j < len(sorted); j++ {
+			if clustered[j] || sorted[j].Metadata["context"] != seed.Metadata["context"] {
+				continue
+			}
+			lowest := 1.0
+			for _, m := range members {
+				lowest = min(lowest, vecmath.Cosine(sorted[m].Embedding, sorted[j].Embedding))
+			}
+			if lowest >= threshold {
+				members = append(members, j)
+				minSim = min(minSim, lowest)
+			}
+		}
+		if len(members) < 2 {
+			continue
+		}
+
+		cluster := consolidationCluster{Context: seed.Metadata["context"], MinSimilarity: minSim}
+		for _, m := range members {
+			clustered[m] = true
+			cluster.Docs = append(cluster.Docs, sorted[m])
+		}
+		if cluster.Context == "" {
+			cluster.Context = DefaultContextID
+		}
+		clusters = append(clusters, cluster)
+	}
+	return clusters
+}
+
+// consolidationPrompt asks the LLM to merge a cluster into one statement.
+func consolidationPrompt(cluster consolidationCluster) string {
+	var sb strings.Builder
+	sb.WriteString("The following memories state the same information in different words. Merge them into a single canonical statement that keeps every fact any of them contains and adds nothing. Where they disagree, prefer the later memory. Reply with only the merged statement.\n\nMemories, oldest first:\n")
+	for _, doc := range cluster.Docs {
+		sb.WriteString(fmt.Sprintf("- %s\n", doc.Content))
+	}
+	return sb.String()
+}
+
+// mergeCluster asks the LLM for the cluster's canonical statement.
+func (a *App) mergeCluster(ctx context.Context, cluster consolidationCluster) (string, error) {
+	merged, err := a.generate(ctx, consolidationPrompt(cluster), nil)
+	if err != nil {
+		return "", fmt.Errorf("LLM merge failed: %w", err)
+	}
+	if merged = strings.TrimSpace(merged); merged == "" {
+		return "", fmt.Errorf("LLM returned an empty merge")
+	}
+	return merged, nil
+}
+
+// applyConsolidation stores merged under the cluster's oldest memory with the
+// others' tags and IDs folded in, records a version noting the consolidation
+// and deletes the other memories.
+func (a *App) applyConsolidation(ctx context.Context, cluster consolidationCluster, merged string) error {
+	keep, dups := cluster.Docs[0], cluster.Docs[1:]
+
+	metadata := make(map[string]string, len(keep.Metadata)+2)
+	for k, v := range keep.Metadata {
+		if k != "chunk_count" {
+			metadata[k] = v
+		}
+	}
+	dupIDs := make([]string, len(dups))
+	for i, dup := range dups {
+		dupIDs[i] = dup.ID
+		if tags := mergeTags(metadata["tags"], dup.Metadata["tags"]); tags != "" {
+			metadata["tags"] = tags
+		}
+		metadata["merged_ids"] = mergeList(metadata["merged_ids"], mergeList(dup.ID, dup.Metadata["merged_ids"]))
+	}
+
+	previousChunks := a.storedChunkCount(ctx, keep.ID)
+	embedded, invalid, err := a.embedChunked(ctx, []chromem.Document{{ID: keep.ID, Content: merged, Metadata: metadata}})
+	if err == nil && invalid[keep.ID] != nil {
+		err = invalid[keep.ID]
+	}
+	if err != nil {
+		return fmt.Errorf("failed to embed merged memory: %w", err)
+	}
+	if err := a.vectorStore.AddDocuments(ctx, embedded, 1); err != nil {
+		return fmt.Errorf("failed to store merged memory: %w", err)
+	}
+	a.removeStaleChunks(ctx, keep.ID, previousChunks, len(embedded)-1)
+	a.recordVersion(ctx, keep.ID, merged, cluster.Context, ParseTags(metadata["tags"]),
+		fmt.Sprintf("Consolidated with %s", strings.Join(dupIDs, ", ")))
+
+	return a.removeDuplicates(ctx, dupIDs)
+}
+
+// consolidationCandidates returns the memories consolidate_memories considers:
+// whole memories, not in the trash and not expired, in contextID if given and
+// otherwise in every context the client may use.
+func (a *App) consolidationCandidates(ctx context.Context, contextID string) ([]chromem.Document, error) {
+	var where map[string]string
+	if contextID != "" {
+		where = map[string]string{"context": contextID}
+	}
+	docs, err := a.vectorStore.ListDocuments(ctx, where, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	now := a.clock()
+	candidates := docs[:0]
+	for _, doc := range a.accessibleDocuments(ctx, docs) {
+		if isChunk(doc.Metadata) || isChunkedParent(doc.Metadata) || isSoftDeleted(doc.Metadata) || isExpired(doc.Metadata, now) {
+			continue
+		}
+		candidates = append(candidates, doc)
+	}
+	return candidates, nil
+}
+
+// consolidateMemoriesHandler handles the consolidate_memories tool - clusters
+// near-duplicate memories and has the LLM merge each cluster into its oldest
+// memory. With dry_run (the default) the clusters and proposed merges are
+// only listed.
+func (a *App) consolidateMemoriesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+
+	threshold := a.settings().NearDuplicateThreshold
+	if v, ok := args["threshold"].(float64); ok {
+		if v <= 0 || v > 1 {
+			return mcp.NewToolResultError("threshold must be greater than 0 and at most 1"), nil
+		}
+		threshold = v
+	}
+	maxClusterSize := DefaultMaxClusterSize
+	if v, ok := args["max_cluster_size"].(float64); ok {
+		if v < 2 {
+			return mcp.NewToolResultError("max_cluster_size must be at least 2"), nil
+		}
+		maxClusterSize = int(v)
+	}
+	contextID, _ := args["context_id"].(string)
+	if contextID = strings.TrimSpace(contextID); contextID != "" {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+	}
+	dryRun := true
+	if v, ok := args["dry_run"].(bool); ok {
+		dryRun = v
+	}
+
+	docs, err := a.consolidationCandidates(ctx, contextID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list memories: %v", err)), nil
+	}
+	clusters := clusterNearDuplicates(docs, threshold)
+	if len(clusters) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories are at least %.2f similar to each other.", threshold)), nil
+	}
+
+	var oversized []consolidationCluster
+	var eligible []consolidationCluster
+	for _, cluster := range clusters {
+		if len(cluster.Docs) > maxClusterSize {
+			oversized = append(oversized, cluster)
+		} else {
+			eligible = append(eligible, cluster)
+		}
+	}
+
+	// Every cluster costs an LLM call, so progress is reported per cluster
+	progress := a.newProgress(ctx, request, len(eligible), "")
+	var sb strings.Builder
+	merged, failed := 0, 0
+	for i, cluster := range eligible {
+		if i > 0 {
+			progress.notify(i, fmt.Sprintf("Processed %d/%d clusters", i, len(eligible)))
+		}
+		ids := cluster.ids()
+		sb.WriteString(fmt.Sprintf("- keep %s, merge %s (context: %s, min similarity %.2f)\n",
+			ids[0], strings.Join(ids[1:], ", "), cluster.Context, cluster.MinSimilarity))
+
+		statement, err := a.mergeCluster(ctx, cluster)
+		if err == nil && !dryRun {
+			err = a.applyConsolidation(ctx, cluster, statement)
+		}
+		if err != nil {
+			sb.WriteString(fmt.Sprintf("  Failed: %v\n", err))
+			failed++
+			if !dryRun {
+				a.recordAudit(ctx, AuditEntry{Tool: "consolidate_memories", MemoryIDs: ids, ContextID: cluster.Context, ClientID: a.clientIDFrom(ctx), Status: "error", Details: err.Error()})
+			}
+			continue
+		}
+		if dryRun {
+			sb.WriteString(fmt.Sprintf("  Proposed: %s\n", statement))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("  Merged: %s\n", statement))
+		a.recordAudit(ctx, AuditEntry{Tool: "consolidate_memories", MemoryIDs: ids, ContextID: cluster.Context, ClientID: a.clientIDFrom(ctx), Status: "ok", Details: "kept " + ids[0]})
+		merged += len(ids) - 1
+	}
+	for _, cluster := range oversized {
+		sb.WriteString(fmt.Sprintf("- skipped %d memories over max_cluster_size %d: %s\n", len(cluster.Docs), maxClusterSize, strings.Join(cluster.ids(), ", ")))
+	}
+
+	if dryRun {
+		return mcp.NewToolResultText(fmt.Sprintf("Found %d clusters of memories at least %.2f similar (dry run, nothing changed; run again with dry_run=false to merge them):\n%s",
+			len(clusters), threshold, sb.String())), nil
+	}
+
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	summary := fmt.Sprintf("Consolidated %d memories into %d clusters", merged, len(eligible)-failed)
+	if failed > 0 {
+		summary += fmt.Sprintf(", %d clusters failed", failed)
+	}
+	return mcp.NewToolResultText(summary + ":\n" + sb.String()), nil
+}
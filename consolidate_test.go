@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+)
+
+func TestClusterNearDuplicates(t *testing.T) {
+	doc := func(id, contextID, created string, embedding []float32) chromem.Document {
+		metadata := map[string]string{"context": contextID}
+		if created != "" {
+			metadata["created_at"] = created
+		}
+		return chromem.Document{ID: id, Metadata: metadata, Embedding: embedding}
+	}
+	docs := []chromem.Document{
+		// A chain: d2 is 0.96 similar to d1 and d3 0.99 to d2, but d3 only 0.92 to d1
+		doc("d3", "home", "2025-03-01T00:00:00Z", angled(0.92, 1)),
+		doc("d2", "home", "2025-02-01T00:00:00Z", angled(0.96, 1)),
+		doc("d1", "home", "2025-01-01T00:00:00Z", angled(1, 1)),
+		// As similar as d1, but in another context
+		doc("w1", "work", "2025-01-01T00:00:00Z", angled(1, 1)),
+		// Without created_at a memory counts as oldest
+		doc("w0", "work", "", angled(0.99, 2)),
+		doc("unembedded", "home", "2024-01-01T00:00:00Z", nil),
+	}
+
+	clusters := clusterNearDuplicates(docs, 0.95)
+	if len(clusters) != 2 {
+		t.Fatalf("got %d clusters, want 2: %+v", len(clusters), clusters)
+	}
+	work, home := clusters[0], clusters[1]
+	if home.Context != "home" || !slices.Equal(home.ids(), []string{"d1", "d2"}) {
+		t.Errorf("home cluster = %s %v, want d1, d2 without the drifted d3", home.Context, home.ids())
+	}
+	if home.MinSimilarity < 0.959 || home.MinSimilarity > 0.961 {
+		t.Errorf("home min similarity = %.3f, want 0.96", home.MinSimilarity)
+	}
+	if work.Context != "work" || !slices.Equal(work.ids(), []string{"w0", "w1"}) {
+		t.Errorf("first cluster = %s %v, want work w0, w1, which holds the oldest memory", work.Context, work.ids())
+	}
+
+	if clusters := clusterNearDuplicates(docs, 0.999); len(clusters) != 0 {
+		t.Errorf("threshold 0.999 found %d clusters", len(clusters))
+	}
+}
+
+// The deploy facts a1 to a3 are all at least 0.95 similar to each other, and
+// so are w1 and w2 in the work context. other is unrelated.
+var consolidationVectors = syntheticEmbedder{
+	"deploys happen on Friday":           angled(1, 1),
+	"we deploy on Fridays":               angled(0.98, 1),
+	"Friday is deploy day, at 10:00 UTC": angled(0.97, 2),
+	"the office wifi is guest-net":       angled(0.99, 3),
+	"guest-net is the office wifi":       angled(0.985, 4),
+	"the printer is on floor two":        angled(0.1, 5),
+}
+
+// newConsolidationApp returns a testApp holding the consolidationVectors
+// memories, created a day apart in the order a1, a2, a3, other, w1, w2, and
+// an LLM merging each cluster into a canned statement.
+func newConsolidationApp(t *testing.T) (*testApp, *fakeLLM) {
+	t.Helper()
+	ta := newSyntheticApp(t, nil, consolidationVectors)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.remember(t, "a1", "deploys happen on Friday", map[string]any{"tags": []any{"ops"}})
+	ta.remember(t, "a2", "we deploy on Fridays", map[string]any{"tags": []any{"release"}})
+	ta.remember(t, "a3", "Friday is deploy day, at 10:00 UTC", nil)
+	ta.remember(t, "other", "the printer is on floor two", nil)
+	ta.switchContext(t, "work")
+	ta.remember(t, "w1", "the office wifi is guest-net", nil)
+	ta.remember(t, "w2", "guest-net is the office wifi", nil)
+	ta.switchContext(t, DefaultContextID)
+	for i, id := range []string{"a1", "a2", "a3", "other", "w1", "w2"} {
+		ta.setCreatedAt(t, id, fmt.Sprintf("2025-01-%02dT00:00:00Z", i+1))
+	}
+
+	llm := &fakeLLM{reply: func(prompt string) (string, error) {
+		if strings.Contains(prompt, "deploy") {
+			return "Deploys happen on Fridays at 10:00 UTC.", nil
+		}
+		return "The office wifi is guest-net.", nil
+	}}
+	ta.llm = llm
+	return ta, llm
+}
+
+func TestConsolidateMemoriesDryRun(t *testing.T) {
+	ta, llm := newConsolidationApp(t)
+	text, isErr := call(t, ta.consolidateMemoriesHandler, nil)
+	if isErr {
+		t.Fatalf("consolidate_memories: %s", text)
+	}
+	for _, want := range []string{
+		"Found 2 clusters of memories at least 0.95 similar (dry run",
+		"- keep a1, merge a2, a3 (context: " + DefaultContextID + ", min similarity 0.95)\n  Proposed: Deploys happen on Fridays at 10:00 UTC.",
+		"- keep w1, merge w2 (context: work, min similarity 0.98)\n  Proposed: The office wifi is guest-net.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("dry run does not contain %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "other") {
+		t.Errorf("an unrelated memory was clustered:\n%s", text)
+	}
+
+	prompt := llm.Prompts()[0]
+	if !strings.Contains(prompt, "Memories, oldest first:\n- deploys happen on Friday\n- we deploy on Fridays\n- Friday is deploy day, at 10:00 UTC\n") {
+		t.Errorf("merge prompt:\n%s", prompt)
+	}
+	if n := ta.vectorStore.Count(); n != 6 || ta.storedContent(t, "a1") != "deploys happen on Friday" {
+		t.Errorf("a dry run changed the store: %d memories, a1 %q", n, ta.storedContent(t, "a1"))
+	}
+}
+
+func TestConsolidateMemoriesMergesIntoOldest(t *testing.T) {
+	ta, _ := newConsolidationApp(t)
+	text, isErr := call(t, ta.consolidateMemoriesHandler, map[string]any{"dry_run": false, "context_id": DefaultContextID})
+	if isErr || !strings.HasPrefix(text, "Consolidated 2 memories into 1 clusters:") {
+		t.Fatalf("consolidate_memories = %q", text)
+	}
+
+	doc, err := ta.vectorStore.GetByID(t.Context(), "a1")
+	if err != nil || doc.Content != "Deploys happen on Fridays at 10:00 UTC." {
+		t.Fatalf("a1 = %q, %v; want the merged statement", doc.Content, err)
+	}
+	if doc.Metadata["merged_ids"] != "a2,a3" || !slices.Equal(ParseTags(doc.Metadata["tags"]), []string{"ops", "release"}) {
+		t.Errorf("a1 metadata = %v, want merged_ids a2,a3 and both tags", doc.Metadata)
+	}
+	if want, _ := consolidationVectors.Embed(t.Context(), doc.Content); vecmath.Cosine(doc.Embedding, want) < 0.999 {
+		t.Error("a1 keeps the embedding of its old content")
+	}
+	history := ta.history(t, "a1")
+	if last := history.Versions[len(history.Versions)-1]; last.ChangeNote != "Consolidated with a2, a3" || last.Content != doc.Content {
+		t.Errorf("last version = %+v", last)
+	}
+	for _, id := range []string{"a2", "a3"} {
+		if ta.storedContent(t, id) != "" {
+			t.Errorf("%s was not deleted", id)
+		}
+	}
+	// The work cluster is out of scope
+	if ta.storedContent(t, "w2") == "" || ta.storedContent(t, "other") == "" {
+		t.Error("a memory outside the consolidated context was deleted")
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID, "work"); got[DefaultContextID] != 2 || got["work"] != 2 {
+		t.Errorf("counts = %v, want default 2 and work 2", got)
+	}
+}
+
+func TestConsolidateMemoriesMaxClusterSize(t *testing.T) {
+	ta, _ := newConsolidationApp(t)
+	text, _ := call(t, ta.consolidateMemoriesHandler, map[string]any{"dry_run": false, "max_cluster_size": 2.0})
+	if !strings.HasPrefix(text, "Consolidated 1 memories into 1 clusters:") || !strings.Contains(text, "- skipped 3 memories over max_cluster_size 2: a1, a2, a3") {
+		t.Errorf("consolidate_memories = %q", text)
+	}
+	if ta.storedContent(t, "a2") == "" || ta.storedContent(t, "w2") != "" {
+		t.Error("the oversized cluster was merged or the small one was not")
+	}
+}
+
+func TestConsolidateMemoriesLLMFailure(t *testing.T) {
+	for name, reply := range map[string]func(string) (string, error){
+		"error": func(string) (string, error) { return "", errors.New("quota exceeded") },
+		"empty": func(string) (string, error) { return "  \n", nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ta, llm := newConsolidationApp(t)
+			llm.reply = reply
+			text, isErr := call(t, ta.consolidateMemoriesHandler, map[string]any{"dry_run": false})
+			if isErr || !strings.Contains(text, "Consolidated 0 memories into 0 clusters, 2 clusters failed") || !strings.Contains(text, "  Failed: LLM ") {
+				t.Errorf("consolidate_memories = %q", text)
+			}
+			if n := ta.vectorStore.Count(); n != 6 || ta.storedContent(t, "a1") != "deploys happen on Friday" {
+				t.Errorf("a failed merge changed the store: %d memories", n)
+			}
+		})
+	}
+}
+
+func TestConsolidateMemoriesArguments(t *testing.T) {
+	ta, _ := newConsolidationApp(t)
+	for _, args := range []map[string]any{{"threshold": 0.0}, {"threshold": 1.2}, {"max_cluster_size": 1.0}} {
+		if text, isErr := call(t, ta.consolidateMemoriesHandler, args); !isErr {
+			t.Errorf("consolidate_memories %v = %q, want an error", args, text)
+		}
+	}
+	text, _ := call(t, ta.consolidateMemoriesHandler, map[string]any{"threshold": 0.999})
+	if text != "No memories are at least 1.00 similar to each other." {
+		t.Errorf("consolidate_memories at 0.999 = %q", text)
+	}
+}
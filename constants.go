@@ -1,13 +1,35 @@
 package main
 
+import "time"
+
 // Embedding and model configuration constants
 const (
 	// Embedding model for generating vector representations
 	DefaultEmbeddingModel = "gemini-embedding-001"
 	// LLM model for assisted search and synthesis
 	DefaultLLMModel = "gemini-flash-lite-latest"
-	// Output dimensionality for embeddings (MRL optimized)
+	// Default output dimensionality for Gemini embeddings (MRL optimized)
 	EmbeddingDimension = 768
+	// Largest output dimensionality Gemini embedding models return
+	MaxGeminiEmbeddingDimension = 3072
+	// Maximum number of contents sent in a single batch embedding request
+	MaxEmbedBatchSize = 100
+	// Embeddings kept in the embedding cache when cache_max_entries is not configured
+	DefaultCacheMaxEntries = 1000
+	// Vector components shown by embed_inspect by default
+	DefaultInspectComponents = 8
+	// Upper bound for the components argument of embed_inspect
+	MaxInspectComponents = 64
+)
+
+// Provider retry constants
+const (
+	// Default number of retries for transient provider errors
+	DefaultMaxRetries = 3
+	// Default delay before the first retry
+	DefaultInitialBackoff = 500 * time.Millisecond
+	// Upper bound for a single retry delay
+	MaxRetryBackoff = 30 * time.Second
 )
 
 // Memory storage constants
@@ -32,8 +54,224 @@ const (
 const (
 	// Default number of results to return from semantic search
 	DefaultSearchResults = 5
+	// Server-side cap for the max_results argument of search tools
+	MaxSearchResultsCap = 50
+	// Default and maximum page size of search_by_tag
+	DefaultTagPageSize = 20
+	MaxTagPageSize     = 100
 	// Maximum snippet length in list output
 	MaxSnippetLength = 50
+	// Delay before pending keyword index changes are written to disk
+	KeywordIndexSaveDelay = 2 * time.Second
+	// Delay before pending changes to an encrypted local database are exported
+	EncryptedExportDelay = 2 * time.Second
+	// Delay before recorded memory accesses are written back to the store
+	AccessFlushDelay = 5 * time.Second
+	// Responses above this size are written to a file instead of returned inline
+	DefaultMaxInlineResponseBytes = 512 * 1024
+)
+
+// Auto-tagging constants
+const (
+	// Default number of tags suggested by auto_tag
+	DefaultAutoTagCount = 5
+	// Upper bound for the max_tags argument of auto_tag
+	MaxAutoTagCount = 10
+	// Prompt asking the LLM for tags; takes the tag count and the memory content
+	AutoTagPrompt = `Suggest up to %d short tags (one or two lowercase words each) that categorize the following memory.
+Respond ONLY with a JSON array of strings, for example ["project", "meeting-notes"].
+
+Memory:
+%s`
+)
+
+// Default ask_brain prompt, a text/template over askPromptData. The trailing
+// space after "question." is kept from the original hard-coded prompt.
+const DefaultAskPromptTemplate = `You are a personal memory assistant. Based ONLY on the retrieved memories provided below, answer the user's question. 
+If the answer is not contained within the memories, politely state that you don't recall that information.{{.Citation}}
+
+Retrieved Memories:
+{{.Memories}}
+{{if .History}}Earlier in this conversation:
+{{.History}}{{end}}
+User Question: {{.Question}}`
+
+// Strategies for memories whose content exactly matches an existing memory
+const (
+	// Do not store the new memory
+	DuplicateSkip = "skip"
+	// Do not store the new memory; record its ID and tags on the existing one
+	DuplicateLink = "link"
+	// Store the new memory and delete the existing one
+	DuplicateOverwrite = "overwrite"
+)
+
+// Handling of the memories of a context removed by delete_context
+const (
+	// Refuse to delete a context that still holds memories
+	DeleteContextAbortIfNonEmpty = "abort_if_nonempty"
+	// Move the memories to the default context
+	DeleteContextReassign = "reassign"
+	// Delete the memories and their version histories
+	DeleteContextCascade = "cascade"
+)
+
+// Handling of memories nearly identical to an existing memory (dedupe argument)
+const (
+	// Store the new memory and mention the similar one in the result
+	DedupeWarn = "warn"
+	// Do not store the new memory
+	DedupeSkip = "skip"
+	// Store the new content under the existing memory's ID instead
+	DedupeMerge = "merge"
+	// Similarity at which remember treats a memory as a near duplicate when not configured
+	DefaultNearDuplicateThreshold = 0.95
+	// Largest cluster consolidate_memories merges when max_cluster_size is not given
+	DefaultMaxClusterSize = 5
+	// Nearest neighbours find_duplicates compares each memory with
+	DuplicateNeighbours = 5
+)
+
+// Search modes of search_memory and search_advanced
+const (
+	// Rank by embedding similarity
+	SearchModeSemantic = "semantic"
+	// Rank by keyword matches, memories containing the whole query first
+	SearchModeKeyword = "keyword"
+	// Merge the semantic and keyword rankings with reciprocal-rank fusion
+	SearchModeHybrid = "hybrid"
+	// k in the reciprocal-rank fusion score 1/(k+rank); 60 is the usual choice
+	RRFRankConstant = 60
+	// Hybrid search ranks this many times max_results candidates in each mode
+	HybridCandidateFactor = 3
+	// search_memory with a date range filters this many times max_results candidates
+	DateRangeCandidateFactor = 3
+	// search_memory with MMR picks max_results from this many times as many candidates
+	MMRCandidateFactor = 3
+	// Default MMR trade-off between relevance (1) and diversity (0)
+	DefaultMMRLambda = 0.5
+)
+
+// Chunking of long memories when not configured, in characters
+const (
+	DefaultChunkSize    = 4000
+	DefaultChunkOverlap = 400
+)
+
+// Similarity labels used by compare_texts when not configured
+const (
+	DefaultVerySimilarThreshold     = 0.8
+	DefaultSomewhatSimilarThreshold = 0.5
+)
+
+// Context routing constants
+const (
+	// Weight of the context description similarity in find_context scores; the
+	// rest is the best similarity of a memory in the context
+	ContextDescriptionWeight = 0.5
+	// Memories searched for the content part of find_context scores
+	ContextRoutingProbeResults = 20
+	// Contexts returned by find_context when max_results is not given
+	DefaultFindContextResults = 3
+)
+
+// Memories written to the vector store per batch by import_memories
+const ImportBatchSize = 50
+
+// Memories written to the destination per batch by the migrate subcommand
+const MigrateBatchSize = 100
+
+// Backup constants
+const (
+	// Time between backups when backup.interval is not configured
+	DefaultBackupInterval = "1h"
+	// Backups kept when backup.max_backups is not configured
+	DefaultMaxBackups = 7
+)
+
+// Time between expiry sweeps when expiry_interval is not configured
+const DefaultExpiryInterval = "10m"
+
+// ask_brain conversations (conversation_id)
+const (
+	// Exchanges kept per conversation when max_conversation_turns is not configured
+	DefaultMaxConversationTurns = 5
+	// Idle time after which a conversation is forgotten when conversation_ttl is not configured
+	DefaultConversationTTL = "30m"
+	// Time between sweeps for idle conversations
+	ConversationSweepInterval = time.Minute
+)
+
+// Memories ask_brain retrieves for the LLM to rerank when not configured
+const DefaultRerankCandidates = 15
+
+// Importance from which list_memories marks a memory as pinned
+const PinnedImportance = 0.9
+
+// batch_search constants
+const (
+	// Queries accepted in one batch_search call
+	MaxBatchQueries = 20
+	// Queries batch_search runs at once when concurrency is not given
+	DefaultBatchSearchConcurrency = 4
+)
+
+// Replaces personal data in memories when pii_detection is on and pii_reject off
+const PIIRedaction = "[REDACTED]"
+
+// Example IDs verify_storage lists per kind of problem
+const MaxStorageProblemIDs = 10
+
+// HTTP transport, see -transport http
+const (
+	DefaultHTTPPort = 8080
+	DefaultHTTPHost = "127.0.0.1"
+	// Time given to open SSE connections and in-flight requests on shutdown
+	HTTPShutdownTimeout = 10 * time.Second
+)
+
+// Lowest LLM confidence at which remember takes an automatically chosen context
+const AutoContextMinConfidence = 0.6
+
+// Sampling settings of ask_brain answers when not configured
+const (
+	DefaultLLMTemperature     = 0.7
+	DefaultLLMMaxOutputTokens = 1024
+	DefaultLLMTopP            = 0.9
+)
+
+// Similarity at which forget_topic deletes a memory when no threshold is given
+const DefaultForgetThreshold = 0.75
+
+// Days without retrieval after which list_stale_memories lists a memory
+const DefaultStaleDays = 30
+
+// Activity report constants
+const (
+	// Granularity of one bucket per calendar day
+	ActivityDaily = "day"
+	// Granularity of one bucket per week starting on Monday
+	ActivityWeekly = "week"
+	// Buckets shown by activity_report for daily granularity when window is not given
+	DefaultActivityDays = 14
+	// Buckets shown by activity_report for weekly granularity when window is not given
+	DefaultActivityWeeks = 8
+	// Upper bound for the window argument of activity_report
+	MaxActivityBuckets = 366
+	// Delay before recorded activity is written to disk
+	ActivitySaveDelay = 5 * time.Second
+)
+
+// Version history constants
+const (
+	// Versions kept per memory by prune_versions when keep_latest is not given
+	DefaultKeepVersions = 5
+	// Versions kept per memory; AddVersion drops the oldest beyond this
+	MaxVersionsPerMemory = 50
+	// Versions listed by get_memory_history; older ones are fetched by number
+	MaxHistoryVersionsShown = 10
+	// Content preview length in get_memory_history without include_content
+	MaxHistoryPreviewLength = 120
 )
 
 // Server configuration constants
@@ -56,12 +294,20 @@ const (
 	MaxConcurrentClients = 100
 )
 
+// ContextDataVersion is the format of brain_contexts.json. Version 1.1 added
+// context owners.
+const ContextDataVersion = "1.1"
+
 // UI/CLI messages
 const (
 	PrompStr = "brain> "
 	WelcomeMsg = "=== BrainMCP Test Mode ==="
-	HelpMsg = "Commands: remember <id> <msg> | search <q> | ask <q> | delete <id> | list | tag <id> <tag> | context <create|switch|list> | wipe | exit"
-	UnknownCmdMsg = "Unknown command. Try: remember, search, ask, delete, list, tag, context, wipe, exit"
+	HelpMsg = "Commands: remember <id> <msg> | remember <id> <<EOF | remember <id> @file [--tags a,b] | paste <id> | search <q> | ask <q> | get <id> | delete <id> | forget <q> | list | tag <id> <tag> | context <create|switch|list|delete> | compare <a> | <b> | history <id> | restore <id> <version> | wipe | exit"
+	UnknownCmdMsg = "Unknown command. Try: remember, paste, search, ask, get, delete, forget, list, tag, context, compare, history, restore, wipe, exit"
+	// Pasted or file content longer than this many characters is confirmed before storing
+	CLIConfirmChars = 1000
+	// Line that ends a paste command
+	PasteTerminator = "."
 )
 
 // Error and status messages
@@ -71,3 +317,25 @@ const (
 	NoMemoriesStoredMsg = "No memories stored."
 	BrainWipedMsg = "Brain completely wiped and reset."
 )
+
+// Retention and maintenance constants
+const (
+	// Default number of events kept for get_request_trace
+	DefaultTraceBufferSize = 1000
+	// Interval between maintenance sweeps
+	MaintenanceInterval = time.Hour
+	// Retention action that permanently deletes evicted memories
+	RetentionDelete = "delete"
+	// Retention action that writes evicted memories to the archive directory, then deletes them
+	RetentionArchive = "archive"
+	// Retention action that marks evicted memories as deleted but keeps them in the store
+	RetentionSoftDelete = "soft-delete"
+)
+
+// MCP prompt constants
+const (
+	// Memories whose content the daily_summary prompt includes
+	MaxDailySummaryMemories = 100
+	// Most used tags save_conversation suggests reusing
+	MaxPromptTags = 20
+)
@@ -2,30 +2,39 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 )
 
+// errAccessDenied is returned when a client uses a context that is neither
+// its own nor shared with it.
+var errAccessDenied = errors.New("access denied")
+
 // ContextManager handles persistent context, tags, and client sessions.
 type ContextManager struct {
 	mu       sync.RWMutex
 	data     *ContextData
 	dataPath string
+	cipher   *FileCipher // Encrypts the state file when set
 	logger   interface{} // Will be assigned from App
 }
 
 // NewContextManager creates a new context manager and loads persisted state.
-func NewContextManager(dataPath string) *ContextManager {
+func NewContextManager(dataPath string, cipher *FileCipher) *ContextManager {
 	cm := &ContextManager{
 		dataPath: dataPath,
+		cipher:   cipher,
 		data: &ContextData{
 			Contexts: make(map[string]*Context),
 			Tags:     make(map[string]*Tag),
 			Sessions: make(map[string]*ClientSession),
-			Version:  "1.0",
+			Version:  ContextDataVersion,
 		},
 	}
 
@@ -48,16 +57,17 @@ func (cm *ContextManager) initializeDefaults() {
 			ID:          DefaultContextID,
 			Name:        DefaultContextName,
 			Description: "Default context for memories",
-			CreatedAt:   time.Now(),
-			UpdatedAt:   time.Now(),
+			CreatedAt:   time.Now().UTC(),
+			UpdatedAt:   time.Now().UTC(),
 			MemoryCount: 0,
 			Tags:        []string{},
 		}
 	}
 }
 
-// CreateContext creates a new named context.
-func (cm *ContextManager) CreateContext(id, name, description string) error {
+// CreateContext creates a new named context owned by ownerClientID. An empty
+// owner leaves the context open to every client.
+func (cm *ContextManager) CreateContext(id, name, description, ownerClientID string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -66,18 +76,54 @@ func (cm *ContextManager) CreateContext(id, name, description string) error {
 	}
 
 	cm.data.Contexts[id] = &Context{
-		ID:          id,
-		Name:        name,
-		Description: description,
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
-		MemoryCount: 0,
-		Tags:        []string{},
+		ID:            id,
+		Name:          name,
+		Description:   description,
+		CreatedAt:     time.Now().UTC(),
+		UpdatedAt:     time.Now().UTC(),
+		MemoryCount:   0,
+		Tags:          []string{},
+		OwnerClientID: ownerClientID,
 	}
 
 	return cm.Save()
 }
 
+// CheckAccess returns an error unless clientID may use a context. The default
+// context and contexts without an owner are open to every client; any other
+// context only to its owner and the clients it is shared with. Clients are
+// compared by their assigned ID: the name a client reports is not checked and
+// grants nothing, so another connection must be shared the context.
+func (cm *ContextManager) CheckAccess(clientID, contextID string) error {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	ctx, exists := cm.data.Contexts[contextID]
+	if !exists {
+		return fmt.Errorf("context %q not found", contextID)
+	}
+	if contextID == DefaultContextID || ctx.OwnerClientID == "" || ctx.OwnerClientID == clientID || slices.Contains(ctx.SharedWith, clientID) {
+		return nil
+	}
+	return fmt.Errorf("%w: context %q belongs to client %q and is not shared with %q", errAccessDenied, contextID, ctx.OwnerClientID, clientID)
+}
+
+// CheckOwner returns an error unless clientID owns a context. Contexts without
+// an owner, including the default one, count as owned by everyone.
+func (cm *ContextManager) CheckOwner(clientID, contextID string) error {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	ctx, exists := cm.data.Contexts[contextID]
+	if !exists {
+		return fmt.Errorf("context %q not found", contextID)
+	}
+	if ctx.OwnerClientID == "" || ctx.OwnerClientID == clientID {
+		return nil
+	}
+	return fmt.Errorf("%w: only its owner %q can change who context %q is shared with", errAccessDenied, ctx.OwnerClientID, contextID)
+}
+
 // GetContext retrieves a context by ID.
 func (cm *ContextManager) GetContext(id string) (*Context, error) {
 	cm.mu.RLock()
@@ -91,6 +137,26 @@ func (cm *ContextManager) GetContext(id string) (*Context, error) {
 	return ctx, nil
 }
 
+// UpdateContext renames a context and replaces its description. The ID is
+// kept, since memories refer to their context by ID.
+func (cm *ContextManager) UpdateContext(id, name, description string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if name = strings.TrimSpace(name); name == "" {
+		return fmt.Errorf("context name cannot be empty")
+	}
+	ctx, exists := cm.data.Contexts[id]
+	if !exists {
+		return fmt.Errorf("context %q not found", id)
+	}
+
+	ctx.Name = name
+	ctx.Description = description
+	ctx.UpdatedAt = time.Now().UTC()
+	return cm.Save()
+}
+
 // ListContexts returns all contexts.
 func (cm *ContextManager) ListContexts() []*Context {
 	cm.mu.RLock()
@@ -104,7 +170,8 @@ func (cm *ContextManager) ListContexts() []*Context {
 	return contexts
 }
 
-// DeleteContext removes a context.
+// DeleteContext removes a context. Sessions working in it switch to the
+// default context and shares of it are dropped.
 func (cm *ContextManager) DeleteContext(id string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
@@ -118,11 +185,47 @@ func (cm *ContextManager) DeleteContext(id string) error {
 	}
 
 	delete(cm.data.Contexts, id)
+	for _, session := range cm.data.Sessions {
+		if session.CurrentContext == id {
+			session.CurrentContext = DefaultContextID
+		}
+		session.SharedWith = slices.DeleteFunc(session.SharedWith, func(shared string) bool { return shared == id })
+	}
+	return cm.Save()
+}
+
+// SetRetention sets or, when policy is nil, clears the retention policy of a context.
+func (cm *ContextManager) SetRetention(id string, policy *RetentionPolicy) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	ctx, exists := cm.data.Contexts[id]
+	if !exists {
+		return fmt.Errorf("context %q not found", id)
+	}
+
+	ctx.Retention = policy
+	ctx.UpdatedAt = time.Now().UTC()
 	return cm.Save()
 }
 
-// CreateTag creates a new tag for categorization.
-func (cm *ContextManager) CreateTag(name, description, color string) error {
+// RetentionPolicies returns a copy of every configured retention policy keyed by context ID.
+func (cm *ContextManager) RetentionPolicies() map[string]RetentionPolicy {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	policies := make(map[string]RetentionPolicy)
+	for id, ctx := range cm.data.Contexts {
+		if ctx.Retention != nil {
+			policies[id] = *ctx.Retention
+		}
+	}
+	return policies
+}
+
+// CreateTag creates a new tag for categorization, as a child of parent if it
+// is not empty.
+func (cm *ContextManager) CreateTag(name, description, color, parent string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
@@ -134,6 +237,12 @@ func (cm *ContextManager) CreateTag(name, description, color string) error {
 	if _, exists := cm.data.Tags[name]; exists {
 		return fmt.Errorf("tag %q already exists", name)
 	}
+	parent = strings.ToLower(strings.TrimSpace(parent))
+	if parent != "" {
+		if _, exists := cm.data.Tags[parent]; !exists {
+			return fmt.Errorf("parent tag %q not found", parent)
+		}
+	}
 
 	cm.data.Tags[name] = &Tag{
 		Name:        name,
@@ -141,10 +250,89 @@ func (cm *ContextManager) CreateTag(name, description, color string) error {
 		Color:       color,
 		MemoryCount: 0,
 	}
+	cm.attachTagLocked(name, parent)
+
+	return cm.Save()
+}
+
+// SetTagParent moves a tag under parent, or to the top level if parent is
+// empty. A tag cannot become a descendant of itself.
+func (cm *ContextManager) SetTagParent(name, parent string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	name = strings.ToLower(strings.TrimSpace(name))
+	parent = strings.ToLower(strings.TrimSpace(parent))
+	tag, exists := cm.data.Tags[name]
+	if !exists {
+		return fmt.Errorf("tag %q not found", name)
+	}
+	if parent != "" {
+		if _, exists := cm.data.Tags[parent]; !exists {
+			return fmt.Errorf("parent tag %q not found", parent)
+		}
+		if parent == name || slices.Contains(cm.tagDescendantsLocked(name), parent) {
+			return fmt.Errorf("tag %q cannot be its own ancestor", name)
+		}
+	}
 
+	cm.detachTagLocked(tag)
+	cm.attachTagLocked(name, parent)
 	return cm.Save()
 }
 
+// GetTagDescendants returns the children of a tag, their children and so on,
+// breadth first. Unknown tags have none.
+func (cm *ContextManager) GetTagDescendants(name string) []string {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	return cm.tagDescendantsLocked(strings.ToLower(strings.TrimSpace(name)))
+}
+
+// tagDescendantsLocked walks the hierarchy below name breadth first. Tags
+// already seen are skipped, so a corrupted hierarchy cannot loop forever
+// (caller must hold the lock).
+func (cm *ContextManager) tagDescendantsLocked(name string) []string {
+	var descendants []string
+	seen := map[string]bool{name: true}
+	queue := []string{name}
+	for len(queue) > 0 {
+		tag, ok := cm.data.Tags[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+		for _, child := range tag.Children {
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			descendants = append(descendants, child)
+			queue = append(queue, child)
+		}
+	}
+	return descendants
+}
+
+// attachTagLocked makes name a child of parent (caller must hold the lock).
+func (cm *ContextManager) attachTagLocked(name, parent string) {
+	cm.data.Tags[name].Parent = parent
+	if p, ok := cm.data.Tags[parent]; ok && !slices.Contains(p.Children, name) {
+		p.Children = append(p.Children, name)
+		slices.Sort(p.Children)
+	}
+}
+
+// detachTagLocked removes tag from its parent's children (caller must hold
+// the lock).
+func (cm *ContextManager) detachTagLocked(tag *Tag) {
+	if p, ok := cm.data.Tags[tag.Parent]; ok {
+		p.Children = slices.DeleteFunc(p.Children, func(child string) bool { return child == tag.Name })
+	}
+	tag.Parent = ""
+}
+
 // GetTag retrieves a tag by name.
 func (cm *ContextManager) GetTag(name string) (*Tag, error) {
 	cm.mu.RLock()
@@ -159,29 +347,77 @@ func (cm *ContextManager) GetTag(name string) (*Tag, error) {
 	return tag, nil
 }
 
-// ListTags returns all tags.
+// ListTags returns all tags in hierarchy order: top-level tags by name, each
+// followed by its descendants, depth first.
 func (cm *ContextManager) ListTags() []*Tag {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
 
+	var roots []string
+	for name, tag := range cm.data.Tags {
+		if _, ok := cm.data.Tags[tag.Parent]; !ok {
+			roots = append(roots, name)
+		}
+	}
+	slices.Sort(roots)
+
 	tags := make([]*Tag, 0, len(cm.data.Tags))
-	for _, tag := range cm.data.Tags {
+	seen := make(map[string]bool, len(cm.data.Tags))
+	var walk func(name string)
+	walk = func(name string) {
+		tag, ok := cm.data.Tags[name]
+		if !ok || seen[name] {
+			return
+		}
+		seen[name] = true
 		tags = append(tags, tag)
+		for _, child := range tag.Children {
+			walk(child)
+		}
+	}
+	for _, name := range roots {
+		walk(name)
 	}
-
 	return tags
 }
 
-// DeleteTag removes a tag.
+// TagDepth returns how many ancestors a tag has; top-level tags are at depth 0.
+func (cm *ContextManager) TagDepth(name string) int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	name = strings.ToLower(name)
+	depth := 0
+	seen := map[string]bool{name: true}
+	tag, ok := cm.data.Tags[name]
+	for ok && tag.Parent != "" && !seen[tag.Parent] {
+		seen[tag.Parent] = true
+		if tag, ok = cm.data.Tags[tag.Parent]; ok {
+			depth++
+		}
+	}
+	return depth
+}
+
+// DeleteTag removes a tag. Its children move up to its parent.
 func (cm *ContextManager) DeleteTag(name string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	name = strings.ToLower(name)
-	if _, exists := cm.data.Tags[name]; !exists {
+	tag, exists := cm.data.Tags[name]
+	if !exists {
 		return fmt.Errorf("tag %q not found", name)
 	}
 
+	parent := tag.Parent
+	cm.detachTagLocked(tag)
+	for _, child := range tag.Children {
+		if c, ok := cm.data.Tags[child]; ok {
+			c.Parent = ""
+			cm.attachTagLocked(child, parent)
+		}
+	}
 	delete(cm.data.Tags, name)
 	return cm.Save()
 }
@@ -191,19 +427,26 @@ func (cm *ContextManager) RegisterSession(clientID string) error {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
+	_, err := cm.registerSessionLocked(clientID)
+	return err
+}
+
+// registerSessionLocked creates and persists a client session (caller must hold the write lock).
+func (cm *ContextManager) registerSessionLocked(clientID string) (*ClientSession, error) {
 	if len(cm.data.Sessions) >= MaxConcurrentClients {
-		return fmt.Errorf("maximum concurrent clients reached")
+		return nil, fmt.Errorf("maximum concurrent clients reached")
 	}
 
-	cm.data.Sessions[clientID] = &ClientSession{
+	session := &ClientSession{
 		ClientID:       clientID,
 		CurrentContext: DefaultContextID,
-		CreatedAt:      time.Now(),
-		LastActivity:   time.Now(),
+		CreatedAt:      time.Now().UTC(),
+		LastActivity:   time.Now().UTC(),
 		SharedWith:     []string{},
 	}
+	cm.data.Sessions[clientID] = session
 
-	return cm.Save()
+	return session, cm.Save()
 }
 
 // UnregisterSession removes a client session.
@@ -216,6 +459,9 @@ func (cm *ContextManager) UnregisterSession(clientID string) error {
 	}
 
 	delete(cm.data.Sessions, clientID)
+	for _, c := range cm.data.Contexts {
+		c.SharedWith = slices.DeleteFunc(c.SharedWith, func(id string) bool { return id == clientID })
+	}
 	return cm.Save()
 }
 
@@ -232,6 +478,34 @@ func (cm *ContextManager) GetSession(clientID string) (*ClientSession, error) {
 	return session, nil
 }
 
+// ListSessions returns copies of all client sessions, most recently active
+// first.
+func (cm *ContextManager) ListSessions() []ClientSession {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	sessions := make([]ClientSession, 0, len(cm.data.Sessions))
+	for _, session := range cm.data.Sessions {
+		copied := *session
+		copied.SharedWith = slices.Clone(session.SharedWith)
+		sessions = append(sessions, copied)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].LastActivity.Equal(sessions[j].LastActivity) {
+			return sessions[i].LastActivity.After(sessions[j].LastActivity)
+		}
+		return sessions[i].ClientID < sessions[j].ClientID
+	})
+	return sessions
+}
+
+// SessionCount returns the number of registered client sessions.
+func (cm *ContextManager) SessionCount() int {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return len(cm.data.Sessions)
+}
+
 // SwitchContext changes the current context for a client.
 func (cm *ContextManager) SwitchContext(clientID, contextID string) error {
 	cm.mu.Lock()
@@ -247,23 +521,37 @@ func (cm *ContextManager) SwitchContext(clientID, contextID string) error {
 	}
 
 	session.CurrentContext = contextID
-	session.LastActivity = time.Now()
+	session.LastActivity = time.Now().UTC()
 
 	return cm.Save()
 }
 
-// GetClientContext returns the current context for a client.
+// GetClientContext returns the current context for a client, registering a
+// session for clients that have none yet.
 func (cm *ContextManager) GetClientContext(clientID string) (string, error) {
 	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
 	session, exists := cm.data.Sessions[clientID]
-	if !exists {
-		// Auto-register session if not found
+	if exists {
+		contextID := session.CurrentContext
 		cm.mu.RUnlock()
-		cm.RegisterSession(clientID)
-		cm.mu.RLock()
-		return DefaultContextID, nil
+		return contextID, nil
+	}
+	cm.mu.RUnlock()
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	// Another goroutine may have registered the client while the lock was released
+	session, exists = cm.data.Sessions[clientID]
+	if !exists {
+		var err error
+		if session, err = cm.registerSessionLocked(clientID); err != nil {
+			if session == nil {
+				return "", fmt.Errorf("failed to register session %q: %w", clientID, err)
+			}
+			// The session exists in memory even if persisting it failed
+			return session.CurrentContext, fmt.Errorf("failed to save session %q: %w", clientID, err)
+		}
 	}
 
 	return session.CurrentContext, nil
@@ -275,7 +563,8 @@ func (cm *ContextManager) ShareContext(ownerClientID, targetClientID, contextID
 	defer cm.mu.Unlock()
 
 	// Verify context exists
-	if _, exists := cm.data.Contexts[contextID]; !exists {
+	ctx, exists := cm.data.Contexts[contextID]
+	if !exists {
 		return fmt.Errorf("context %q not found", contextID)
 	}
 
@@ -293,9 +582,74 @@ func (cm *ContextManager) ShareContext(ownerClientID, targetClientID, contextID
 	}
 
 	session.SharedWith = append(session.SharedWith, contextID)
+	if !slices.Contains(ctx.SharedWith, targetClientID) {
+		ctx.SharedWith = append(ctx.SharedWith, targetClientID)
+	}
 	return cm.Save()
 }
 
+// UnshareContext revokes a client's access to a context. A client working in
+// the context is switched back to the default context; the returned bool
+// reports whether that happened.
+func (cm *ContextManager) UnshareContext(ownerClientID, targetClientID, contextID string) (bool, error) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	ctx, exists := cm.data.Contexts[contextID]
+	if !exists {
+		return false, fmt.Errorf("context %q not found", contextID)
+	}
+	session, exists := cm.data.Sessions[targetClientID]
+	if !exists {
+		return false, fmt.Errorf("target session %q not found", targetClientID)
+	}
+	i := slices.Index(session.SharedWith, contextID)
+	if i < 0 {
+		return false, fmt.Errorf("context %q is not shared with client %q", contextID, targetClientID)
+	}
+
+	session.SharedWith = slices.Delete(session.SharedWith, i, i+1)
+	ctx.SharedWith = slices.DeleteFunc(ctx.SharedWith, func(id string) bool { return id == targetClientID })
+	switched := session.CurrentContext == contextID
+	if switched {
+		session.CurrentContext = DefaultContextID
+	}
+	return switched, cm.Save()
+}
+
+// GetContextShares returns the IDs of the clients a context is shared with, sorted.
+func (cm *ContextManager) GetContextShares(contextID string) ([]string, error) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	ctx, exists := cm.data.Contexts[contextID]
+	if !exists {
+		return nil, fmt.Errorf("context %q not found", contextID)
+	}
+	shares := slices.Clone(ctx.SharedWith)
+	slices.Sort(shares)
+	return shares, nil
+}
+
+// rebuildShareIndex recomputes every context's SharedWith from the sessions,
+// for state saved before contexts recorded their shares (caller must hold the
+// write lock).
+func (cm *ContextManager) rebuildShareIndex() {
+	for _, c := range cm.data.Contexts {
+		c.SharedWith = nil
+	}
+	for clientID, session := range cm.data.Sessions {
+		for _, contextID := range session.SharedWith {
+			if c, exists := cm.data.Contexts[contextID]; exists && !slices.Contains(c.SharedWith, clientID) {
+				c.SharedWith = append(c.SharedWith, clientID)
+			}
+		}
+	}
+	for _, c := range cm.data.Contexts {
+		slices.Sort(c.SharedWith)
+	}
+}
+
 // IncrementMemoryCount increments the memory count for a context.
 func (cm *ContextManager) IncrementMemoryCount(contextID string) error {
 	cm.mu.Lock()
@@ -307,7 +661,7 @@ func (cm *ContextManager) IncrementMemoryCount(contextID string) error {
 	}
 
 	ctx.MemoryCount++
-	ctx.UpdatedAt = time.Now()
+	ctx.UpdatedAt = time.Now().UTC()
 
 	return nil // Don't save on every increment, batched save
 }
@@ -327,6 +681,23 @@ func (cm *ContextManager) IncrementTagCount(tagName string) error {
 	return nil // Don't save on every increment, batched save
 }
 
+// DecrementTagCount decrements the memory count for a tag.
+func (cm *ContextManager) DecrementTagCount(tagName string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	tagName = strings.ToLower(tagName)
+	tag, exists := cm.data.Tags[tagName]
+	if !exists {
+		return fmt.Errorf("tag %q not found", tagName)
+	}
+
+	if tag.MemoryCount > 0 {
+		tag.MemoryCount--
+	}
+	return nil // Don't save on every decrement, batched save
+}
+
 // DecrementMemoryCount decrements the memory count for a context.
 func (cm *ContextManager) DecrementMemoryCount(contextID string) error {
 	cm.mu.Lock()
@@ -340,18 +711,104 @@ func (cm *ContextManager) DecrementMemoryCount(contextID string) error {
 	if ctx.MemoryCount > 0 {
 		ctx.MemoryCount--
 	}
-	ctx.UpdatedAt = time.Now()
+	ctx.UpdatedAt = time.Now().UTC()
 
 	return nil // Don't save on every decrement, batched save
 }
 
+// ResetMemoryCounts sets the memory count of the given contexts to zero.
+// Contexts whose count is stale (non-zero without memories) are reset as well.
+func (cm *ContextManager) ResetMemoryCounts(contextIDs []string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	now := time.Now().UTC()
+	for id, ctx := range cm.data.Contexts {
+		if ctx.MemoryCount > 0 || slices.Contains(contextIDs, id) {
+			ctx.MemoryCount = 0
+			ctx.UpdatedAt = now
+		}
+	}
+}
+
+// MoveMemoryCount moves one memory's count from one context to another under
+// a single lock, so concurrent updates cannot leave the counts out of step.
+func (cm *ContextManager) MoveMemoryCount(fromID, toID string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	to, exists := cm.data.Contexts[toID]
+	if !exists {
+		return fmt.Errorf("context %q not found", toID)
+	}
+
+	now := time.Now().UTC()
+	if from, exists := cm.data.Contexts[fromID]; exists && from.MemoryCount > 0 {
+		from.MemoryCount--
+		from.UpdatedAt = now
+	}
+	to.MemoryCount++
+	to.UpdatedAt = now
+
+	return nil // Don't save on every move, batched save
+}
+
+// MergeContexts adds the memory count of context fromID to toID under a single
+// lock. With deleteFrom, fromID is removed as well and the sessions working in
+// or sharing it are pointed at toID.
+func (cm *ContextManager) MergeContexts(fromID, toID string, deleteFrom bool) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	from, exists := cm.data.Contexts[fromID]
+	if !exists {
+		return fmt.Errorf("context %q not found", fromID)
+	}
+	to, exists := cm.data.Contexts[toID]
+	if !exists {
+		return fmt.Errorf("context %q not found", toID)
+	}
+	if deleteFrom && fromID == DefaultContextID {
+		return fmt.Errorf("cannot delete default context")
+	}
+
+	now := time.Now().UTC()
+	to.MemoryCount += from.MemoryCount
+	to.UpdatedAt = now
+	from.MemoryCount = 0
+	from.UpdatedAt = now
+	if !deleteFrom {
+		return nil // Batched save
+	}
+
+	delete(cm.data.Contexts, fromID)
+	for _, clientID := range from.SharedWith {
+		if !slices.Contains(to.SharedWith, clientID) {
+			to.SharedWith = append(to.SharedWith, clientID)
+		}
+	}
+	for _, session := range cm.data.Sessions {
+		if session.CurrentContext == fromID {
+			session.CurrentContext = toID
+		}
+		if i := slices.Index(session.SharedWith, fromID); i >= 0 {
+			if slices.Contains(session.SharedWith, toID) {
+				session.SharedWith = slices.Delete(session.SharedWith, i, i+1)
+			} else {
+				session.SharedWith[i] = toID
+			}
+		}
+	}
+	return cm.Save()
+}
+
 // UpdateActivity updates the last activity time for a session.
 func (cm *ContextManager) UpdateActivity(clientID string) {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
 
 	if session, exists := cm.data.Sessions[clientID]; exists {
-		session.LastActivity = time.Now()
+		session.LastActivity = time.Now().UTC()
 	}
 }
 
@@ -362,7 +819,7 @@ func (cm *ContextManager) Save() error {
 		return fmt.Errorf("failed to marshal context data: %w", err)
 	}
 
-	if err := os.WriteFile(cm.dataPath, data, 0644); err != nil {
+	if err := cm.cipher.WriteFile(cm.dataPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write context data: %w", err)
 	}
 
@@ -371,7 +828,7 @@ func (cm *ContextManager) Save() error {
 
 // Load restores the context data from disk.
 func (cm *ContextManager) Load() error {
-	data, err := os.ReadFile(cm.dataPath)
+	data, err := cm.cipher.ReadFile(cm.dataPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			cm.initializeDefaults()
@@ -384,11 +841,30 @@ func (cm *ContextManager) Load() error {
 		return fmt.Errorf("failed to unmarshal context data: %w", err)
 	}
 
+	// Contexts created before owners were recorded keep an empty owner and
+	// stay open to every client, as they were
+	if cm.data.Version < ContextDataVersion {
+		cm.data.Version = ContextDataVersion
+	}
+
+	// Older versions stored server-local times; the recorded offset makes the
+	// conversion to UTC exact
+	for _, c := range cm.data.Contexts {
+		c.CreatedAt, c.UpdatedAt = c.CreatedAt.UTC(), c.UpdatedAt.UTC()
+	}
+	for _, session := range cm.data.Sessions {
+		session.CreatedAt, session.LastActivity = session.CreatedAt.UTC(), session.LastActivity.UTC()
+	}
+
 	// Ensure defaults exist
 	if _, exists := cm.data.Contexts[DefaultContextID]; !exists {
 		cm.initializeDefaults()
 	}
 
+	cm.mu.Lock()
+	cm.rebuildShareIndex()
+	cm.mu.Unlock()
+
 	return nil
 }
 
@@ -402,8 +878,8 @@ func (cm *ContextManager) GetMemoryMetadata(clientID, contextID string, tags []s
 	return &MemoryMetadata{
 		Context:    contextID,
 		Tags:       normalizedTags,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+		CreatedAt:  time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
 		ClientID:   clientID,
 		SharedWith: []string{},
 	}
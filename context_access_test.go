@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestContextAccessMatchesClientID(t *testing.T) {
+	ta := newTestApp(t, nil)
+	owner, shared, stranger := newClientID("Owner App"), newClientID("Shared App"), newClientID("Stranger App")
+	as := func(clientID string) context.Context { return WithClientID(context.Background(), clientID) }
+
+	if text, isErr := callAs(t, as(owner), ta.createContextHandler, map[string]any{"id": "private", "name": "Private"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	if text, isErr := callAs(t, as(owner), ta.shareContextHandler, map[string]any{"context_id": "private", "target_client_id": shared}); isErr {
+		t.Fatalf("share_context: %s", text)
+	}
+
+	// Another connection reporting the same name gets a new client ID and is
+	// not the owner, so a client cannot claim a context by its name
+	tests := []struct {
+		name     string
+		clientID string
+		allowed  bool
+	}{
+		{"owner", owner, true},
+		{"owner name on another connection", newClientID("Owner App"), false},
+		{"shared", shared, true},
+		{"shared name on another connection", newClientID("Shared App"), false},
+		{"stranger", stranger, false},
+		{"owner name prefix", newClientID("Owner"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isErr := callAs(t, as(tt.clientID), ta.switchContextHandler, map[string]any{"context_id": "private"})
+			if isErr == tt.allowed {
+				t.Errorf("switch_context as %s: allowed = %v, want %v (%s)", tt.clientID, !isErr, tt.allowed, text)
+			}
+			if !tt.allowed && !strings.Contains(text, owner) {
+				t.Errorf("denial does not name the owner: %s", text)
+			}
+		})
+	}
+
+	// Only the owner can change the shares
+	args := map[string]any{"context_id": "private", "target_client_id": shared}
+	if _, isErr := callAs(t, as(shared), ta.unshareContextHandler, args); !isErr {
+		t.Error("a shared client could unshare the context")
+	}
+	if _, isErr := callAs(t, as(newClientID("Owner App")), ta.unshareContextHandler, args); !isErr {
+		t.Error("another connection with the owner's name could unshare the context")
+	}
+	if text, isErr := callAs(t, as(owner), ta.unshareContextHandler, args); isErr {
+		t.Fatalf("owner cannot unshare: %s", text)
+	}
+	if _, isErr := callAs(t, as(shared), ta.switchContextHandler, map[string]any{"context_id": "private"}); !isErr {
+		t.Error("client still has access after unshare_context")
+	}
+}
+
+func TestMemoryAccessChecks(t *testing.T) {
+	ta := newTestApp(t, nil)
+	owner, stranger := newClientID("Owner App"), newClientID("Stranger App")
+	asOwner, asStranger := WithClientID(t.Context(), owner), WithClientID(t.Context(), stranger)
+
+	if text, isErr := callAs(t, asOwner, ta.createContextHandler, map[string]any{"id": "private", "name": "Private"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	if text, isErr := callAs(t, asOwner, ta.switchContextHandler, map[string]any{"context_id": "private"}); isErr {
+		t.Fatalf("switch_context: %s", text)
+	}
+	if text, isErr := callAs(t, asOwner, ta.rememberHandler, map[string]any{"id": "secret", "content": "the launch codes are in the safe"}); isErr {
+		t.Fatalf("remember: %s", text)
+	}
+	ta.remember(t, "public", "the launch party is on friday", nil)
+
+	denied := []struct {
+		name    string
+		handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)
+		args    map[string]any
+	}{
+		{"get_memory", ta.getMemoryHandler, map[string]any{"id": "secret"}},
+		{"delete_memory", ta.deleteHandler, map[string]any{"id": "secret"}},
+		{"find_similar", ta.findSimilarHandler, map[string]any{"memory_id": "secret"}},
+		{"add_tag", ta.addTagHandler, map[string]any{"memory_id": "secret", "tag": "mine"}},
+		{"move_memory", ta.moveMemoryHandler, map[string]any{"id": "secret", "target_context_id": DefaultContextID}},
+		{"move_memory into private", ta.moveMemoryHandler, map[string]any{"id": "public", "target_context_id": "private"}},
+		{"merge_contexts", ta.mergeContextsHandler, map[string]any{"source_context_id": "private", "target_context_id": DefaultContextID}},
+		{"delete_context", ta.deleteContextHandler, map[string]any{"context_id": "private", "strategy": DeleteContextCascade}},
+		{"update_context", ta.updateContextHandler, map[string]any{"context_id": "private", "name": "Mine"}},
+		{"remember over the memory", ta.rememberHandler, map[string]any{"id": "secret", "content": "overwritten"}},
+	}
+	for _, tt := range denied {
+		if text, isErr := callAs(t, asStranger, tt.handler, tt.args); !isErr || !strings.Contains(text, "access denied") {
+			t.Errorf("%s as a stranger = %q, want access denied", tt.name, text)
+		}
+	}
+	if doc, err := ta.vectorStore.GetByID(t.Context(), "secret"); err != nil || doc.Content != "the launch codes are in the safe" || doc.Metadata["context"] != "private" {
+		t.Errorf("secret after the denied calls = %+v, %v", doc, err)
+	}
+
+	// Searches, listings and exports without a context leave the private memory out
+	unscoped := []struct {
+		name    string
+		handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)
+		args    map[string]any
+	}{
+		{"search_memory", ta.searchHandler, map[string]any{"query": "launch codes"}},
+		{"search_memory keyword", ta.searchHandler, map[string]any{"query": "launch", "mode": "keyword"}},
+		{"list_memories", ta.listHandler, map[string]any{}},
+		{"export_memories", ta.exportMemoriesHandler, map[string]any{}},
+	}
+	for _, tt := range unscoped {
+		text, isErr := callAs(t, asStranger, tt.handler, tt.args)
+		if isErr || strings.Contains(text, "secret") || !strings.Contains(text, "public") {
+			t.Errorf("%s as a stranger = %q, want only the public memory", tt.name, text)
+		}
+		if text, _ := callAs(t, asOwner, tt.handler, tt.args); !strings.Contains(text, "secret") {
+			t.Errorf("%s as the owner leaves out its memory: %q", tt.name, text)
+		}
+	}
+}
+
+func TestRememberOverwriteMovesCount(t *testing.T) {
+	ta := newTestApp(t, nil)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.remember(t, "note", "first version", nil)
+	ta.switchContext(t, "work")
+	ta.remember(t, "note", "second version", nil)
+
+	for contextID, want := range map[string]int{DefaultContextID: 0, "work": 1} {
+		c, err := ta.ctx.GetContext(contextID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.MemoryCount != want {
+			t.Errorf("context %s counts %d memories, want %d", contextID, c.MemoryCount, want)
+		}
+	}
+}
+
+func TestShareListUnshareList(t *testing.T) {
+	ta := newTestApp(t, nil)
+	alice, bob := newClientID("Alice App"), newClientID("Bob App")
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "team", "name": "Team"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	list := func() string {
+		t.Helper()
+		text, isErr := call(t, ta.listContextSharesHandler, map[string]any{"context_id": "team"})
+		if isErr {
+			t.Fatalf("list_context_shares: %s", text)
+		}
+		return text
+	}
+	if got := list(); got != "Context 'team' is not shared with any client." {
+		t.Errorf("shares before sharing = %q", got)
+	}
+
+	for _, clientID := range []string{bob, alice} {
+		if text, isErr := call(t, ta.shareContextHandler, map[string]any{"context_id": "team", "target_client_id": clientID}); isErr {
+			t.Fatalf("share_context %s: %s", clientID, text)
+		}
+	}
+	if _, isErr := call(t, ta.shareContextHandler, map[string]any{"context_id": "team", "target_client_id": bob}); !isErr {
+		t.Error("sharing a context twice with a client succeeded")
+	}
+	want := "Context 'team' is shared with 2 clients:\n- " + min(alice, bob) + "\n- " + max(alice, bob) + "\n"
+	if got := list(); got != want {
+		t.Errorf("shares = %q, want %q", got, want)
+	}
+	if text, isErr := callAs(t, WithClientID(t.Context(), bob), ta.switchContextHandler, map[string]any{"context_id": "team"}); isErr {
+		t.Fatalf("switch_context as bob: %s", text)
+	}
+
+	// Revoking bob's share moves bob out of the context; alice keeps access
+	text, isErr := call(t, ta.unshareContextHandler, map[string]any{"context_id": "team", "target_client_id": bob})
+	if isErr || !strings.Contains(text, "has been switched to '"+DefaultContextID+"'") {
+		t.Fatalf("unshare_context bob = %q", text)
+	}
+	if current, _ := ta.ctx.GetClientContext(bob); current != DefaultContextID {
+		t.Errorf("bob works in %q after losing access, want %s", current, DefaultContextID)
+	}
+	if got := list(); got != "Context 'team' is shared with 1 clients:\n- "+alice+"\n" {
+		t.Errorf("shares after unsharing bob = %q", got)
+	}
+	text, _ = call(t, ta.unshareContextHandler, map[string]any{"context_id": "team", "target_client_id": alice})
+	if strings.Contains(text, "switched") {
+		t.Errorf("unsharing from a client working elsewhere = %q", text)
+	}
+	if got := list(); got != "Context 'team' is not shared with any client." {
+		t.Errorf("shares after unsharing everyone = %q", got)
+	}
+	if text, isErr := call(t, ta.unshareContextHandler, map[string]any{"context_id": "team", "target_client_id": alice}); !isErr || !strings.Contains(text, "is not shared with client") {
+		t.Errorf("unsharing twice = %q", text)
+	}
+
+	// The shares persist
+	if _, isErr := call(t, ta.shareContextHandler, map[string]any{"context_id": "team", "target_client_id": bob}); isErr {
+		t.Fatal("sharing again with bob failed")
+	}
+	reloaded := NewContextManager(ta.ctx.dataPath, nil)
+	if shares, err := reloaded.GetContextShares("team"); err != nil || len(shares) != 1 || shares[0] != bob {
+		t.Errorf("reloaded shares = %v, %v; want only bob", shares, err)
+	}
+	if text, isErr := call(t, ta.listContextSharesHandler, map[string]any{"context_id": "missing"}); !isErr || !strings.Contains(text, "Context not found") {
+		t.Errorf("list_context_shares of a missing context = %q", text)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newCappedApp returns a testApp whose contexts hold at most two memories,
+// with a and b already stored in the default context.
+func newCappedApp(t *testing.T) *testApp {
+	t.Helper()
+	ta := newTestApp(t, func(cfg *Config) {
+		cfg.MaxMemoriesPerContext = 2
+		cfg.SoftDelete = true
+	})
+	ta.remember(t, "a", "alpha notes about the garden", nil)
+	ta.remember(t, "b", "bravo notes about the kitchen", nil)
+	return ta
+}
+
+func TestContextCapAllowsUpdates(t *testing.T) {
+	ta := newCappedApp(t)
+
+	ta.remember(t, "a", "alpha notes about the garden, revised", nil)
+	if text, isErr := call(t, ta.rememberBatchHandler, map[string]any{"memories": []any{
+		map[string]any{"id": "b", "content": "bravo notes about the kitchen, revised"},
+	}}); isErr {
+		t.Fatalf("remember_batch update: %s", text)
+	}
+	c, err := ta.ctx.GetContext(DefaultContextID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MemoryCount != 2 {
+		t.Errorf("MemoryCount after two updates = %d, want 2", c.MemoryCount)
+	}
+}
+
+func TestContextCapRefusesEveryInsertPath(t *testing.T) {
+	newMemory := map[string]any{"id": "c", "content": "charlie notes about the attic"}
+	export, err := json.Marshal(ExportData{
+		ExportedAt: time.Now(),
+		Version:    ExportFormatVersion,
+		Memories: []MemoryWithHistory{{
+			ID:             "c",
+			CurrentVersion: 1,
+			Context:        DefaultContextID,
+			Versions:       []MemoryVersion{{VersionNumber: 1, Content: "charlie notes about the attic", CreatedAt: time.Now()}},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		run  func(ta *testApp) (string, bool)
+	}{
+		{"remember", func(ta *testApp) (string, bool) {
+			return call(t, ta.rememberHandler, newMemory)
+		}},
+		{"remember_batch", func(ta *testApp) (string, bool) {
+			return call(t, ta.rememberBatchHandler, map[string]any{"memories": []any{newMemory}})
+		}},
+		{"batch_operations create", func(ta *testApp) (string, bool) {
+			text, _ := call(t, ta.batchOperationsHandler, map[string]any{"operation": "create", "memories": []any{newMemory}})
+			return text, !strings.Contains(text, "1 of 1 succeeded")
+		}},
+		{"clone_memory", func(ta *testApp) (string, bool) {
+			return call(t, ta.cloneMemoryHandler, map[string]any{"source_id": "a", "new_id": "c"})
+		}},
+		{"import_memories", func(ta *testApp) (string, bool) {
+			return call(t, ta.importMemoriesHandler, map[string]any{"json_data": string(export)})
+		}},
+		{"merge_contexts", func(ta *testApp) (string, bool) {
+			if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "other", "name": "Other"}); isErr {
+				t.Fatalf("create_context: %s", text)
+			}
+			if text, isErr := call(t, ta.switchContextHandler, map[string]any{"context_id": "other"}); isErr {
+				t.Fatalf("switch_context: %s", text)
+			}
+			ta.remember(t, "c", "charlie notes about the attic", nil)
+			return call(t, ta.mergeContextsHandler, map[string]any{"source_context_id": "other", "target_context_id": DefaultContextID})
+		}},
+		{"restore_memory", func(ta *testApp) (string, bool) {
+			if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "a"}); isErr {
+				t.Fatalf("delete_memory: %s", text)
+			}
+			ta.remember(t, "c", "charlie notes about the attic", nil)
+			return call(t, ta.restoreMemoryHandler, map[string]any{"id": "a"})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ta := newCappedApp(t)
+			text, refused := tt.run(ta)
+			if !refused {
+				t.Fatalf("stored past the cap: %s", text)
+			}
+			if !strings.Contains(text, "limit of 2") && !strings.Contains(text, "limit is 2") {
+				t.Errorf("refusal does not mention the cap: %s", text)
+			}
+			docs, err := ta.vectorStore.ListDocuments(t.Context(), map[string]string{"context": DefaultContextID}, 0, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			live := 0
+			for _, doc := range docs {
+				if !isSoftDeleted(doc.Metadata) {
+					live++
+				}
+			}
+			if live != 2 {
+				t.Errorf("default context holds %d memories, want 2", live)
+			}
+		})
+	}
+}
+
+func TestContextCapCountsStoredMemories(t *testing.T) {
+	ta := newCappedApp(t)
+
+	// A drifted counter must not block saving once a memory is gone
+	if err := ta.ctx.IncrementMemoryCount(DefaultContextID); err != nil {
+		t.Fatal(err)
+	}
+	if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "b"}); isErr {
+		t.Fatalf("delete_memory: %s", text)
+	}
+	ta.remember(t, "c", "charlie notes about the attic", nil)
+}
@@ -2,12 +2,101 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
 )
 
+// contextAccessDenied returns an error message if clientID may not use
+// contextID, or "" if it may. The CLI and background tasks act as the server
+// itself and reach every context. Unknown contexts are left for the caller
+// to report.
+func (a *App) contextAccessDenied(clientID, contextID string) string {
+	if clientID == a.clientID {
+		return ""
+	}
+	if err := a.ctx.CheckAccess(clientID, contextID); errors.Is(err, errAccessDenied) {
+		return fmt.Sprintf("Cannot use context '%s': %v. Ask its owner to share it with share_context.", contextID, err)
+	}
+	return ""
+}
+
+// memoryAccessDenied returns an error message if the client making the
+// request in ctx may not use the context memory is stored in, or "" if it may.
+func (a *App) memoryAccessDenied(ctx context.Context, memory chromem.Document) string {
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), memory.Metadata["context"]); msg != "" {
+		return fmt.Sprintf("Cannot use memory '%s': %s", memory.ID, msg)
+	}
+	return ""
+}
+
+// hidesContexts reports whether some context is off limits to the client
+// making the request in ctx. Searches not scoped to a context then rank every
+// memory, so the results the client may see still fill the requested count.
+func (a *App) hidesContexts(ctx context.Context) bool {
+	clientID := a.clientIDFrom(ctx)
+	for _, c := range a.ctx.ListContexts() {
+		if a.contextAccessDenied(clientID, c.ID) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// accessibleResults drops the results stored in contexts the client making
+// the request in ctx may not use.
+func (a *App) accessibleResults(ctx context.Context, results []chromem.Result) []chromem.Result {
+	clientID := a.clientIDFrom(ctx)
+	accessible := results[:0]
+	for _, res := range results {
+		if a.contextAccessDenied(clientID, res.Metadata["context"]) == "" {
+			accessible = append(accessible, res)
+		}
+	}
+	return accessible
+}
+
+// accessibleDocuments drops the documents stored in contexts the client making
+// the request in ctx may not use.
+func (a *App) accessibleDocuments(ctx context.Context, docs []chromem.Document) []chromem.Document {
+	clientID := a.clientIDFrom(ctx)
+	accessible := docs[:0]
+	for _, doc := range docs {
+		if a.contextAccessDenied(clientID, doc.Metadata["context"]) == "" {
+			accessible = append(accessible, doc)
+		}
+	}
+	return accessible
+}
+
+// contextOwner returns the owner of a context the client making the request
+// in ctx creates. Contexts created from the CLI have no owner, since the CLI's
+// client ID changes with every run.
+func (a *App) contextOwner(ctx context.Context) string {
+	if owner := a.clientIDFrom(ctx); owner != a.clientID {
+		return owner
+	}
+	return ""
+}
+
+// contextOwnerDenied returns an error message if clientID may not change who
+// contextID is shared with, or "" if it may.
+func (a *App) contextOwnerDenied(clientID, contextID string) string {
+	if clientID == a.clientID {
+		return ""
+	}
+	if err := a.ctx.CheckOwner(clientID, contextID); errors.Is(err, errAccessDenied) {
+		return fmt.Sprintf("Cannot share context '%s': %v.", contextID, err)
+	}
+	return ""
+}
+
 // createContextHandler creates a new named context.
 func (a *App) createContextHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args, _ := request.Params.Arguments.(map[string]any)
@@ -25,9 +114,12 @@ func (a *App) createContextHandler(ctx context.Context, request mcp.CallToolRequ
 		return mcp.NewToolResultError("Context name cannot be empty"), nil
 	}
 
-	if err := a.ctx.CreateContext(id, name, description); err != nil {
+	if err := a.ctx.CreateContext(id, name, description, a.contextOwner(ctx)); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to create context: %v", err)), nil
 	}
+	if err := a.refreshContextVectors(ctx); err != nil {
+		a.logf(ctx, "Warning: %v", err)
+	}
 
 	return mcp.NewToolResultText(fmt.Sprintf("Context '%s' (%s) created successfully.", name, id)), nil
 }
@@ -47,12 +139,119 @@ func (a *App) listContextsHandler(ctx context.Context, request mcp.CallToolReque
 			sb.WriteString(fmt.Sprintf("  Description: %s\n", c.Description))
 		}
 		sb.WriteString(fmt.Sprintf("  Memories: %d\n", c.MemoryCount))
+		sb.WriteString(fmt.Sprintf("  Created: %s\n", a.formatTime(c.CreatedAt)))
+		if c.Retention != nil {
+			sb.WriteString(fmt.Sprintf("  Retention: %s\n", c.Retention))
+		}
+		if c.OwnerClientID != "" {
+			sb.WriteString(fmt.Sprintf("  Owner: %s\n", c.OwnerClientID))
+		}
+		if len(c.SharedWith) > 0 {
+			sb.WriteString(fmt.Sprintf("  Shared with: %s\n", strings.Join(c.SharedWith, ", ")))
+		}
 		sb.WriteString("\n")
 	}
 
 	return mcp.NewToolResultText(sb.String()), nil
 }
 
+// updateContextHandler renames a context and/or changes its description.
+func (a *App) updateContextHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	contextID, _ := args["context_id"].(string)
+	name, hasName := args["name"].(string)
+	description, hasDescription := args["description"].(string)
+
+	contextID = strings.TrimSpace(contextID)
+	if contextID == "" {
+		return mcp.NewToolResultError("Context ID cannot be empty"), nil
+	}
+	if !hasName && !hasDescription {
+		return mcp.NewToolResultError("Provide a new name, a new description or both"), nil
+	}
+	c, err := a.ctx.GetContext(contextID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Context not found: %v", err)), nil
+	}
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+	oldName, oldDescription := c.Name, c.Description
+	if !hasName {
+		name = oldName
+	}
+	if !hasDescription {
+		description = oldDescription
+	}
+	if strings.TrimSpace(name) == "" {
+		return mcp.NewToolResultError("Context name cannot be empty"), nil
+	}
+
+	if err := a.ctx.UpdateContext(contextID, name, description); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to update context: %v", err)), nil
+	}
+	if err := a.refreshContextVectors(ctx); err != nil {
+		a.logf(ctx, "Warning: %v", err)
+	}
+
+	var changes []string
+	if name = strings.TrimSpace(name); name != oldName {
+		changes = append(changes, fmt.Sprintf("renamed from '%s' to '%s'", oldName, name))
+	}
+	if description != oldDescription {
+		changes = append(changes, "description updated")
+	}
+	if len(changes) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("Context '%s' unchanged.", contextID)), nil
+	}
+	a.recordAudit(ctx, AuditEntry{Tool: "update_context", ClientID: a.clientIDFrom(ctx), ContextID: contextID, Status: "ok",
+		Details: strings.Join(changes, ", ")})
+	return mcp.NewToolResultText(fmt.Sprintf("Context '%s' %s.", contextID, strings.Join(changes, ", "))), nil
+}
+
+// setContextRetentionHandler sets or clears the retention policy of a context.
+func (a *App) setContextRetentionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	contextID, _ := args["context_id"].(string)
+	maxAge, _ := args["max_age"].(string)
+	maxMemories, _ := args["max_memories"].(float64)
+	action, _ := args["action"].(string)
+	clearPolicy, _ := args["clear"].(bool)
+
+	contextID = strings.TrimSpace(contextID)
+	if contextID == "" {
+		return mcp.NewToolResultError("Context ID cannot be empty"), nil
+	}
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	if clearPolicy {
+		if err := a.ctx.SetRetention(contextID, nil); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to clear retention policy: %v", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Retention policy removed from context '%s'.", contextID)), nil
+	}
+
+	if action = strings.TrimSpace(action); action == "" {
+		action = RetentionDelete
+	}
+	policy := &RetentionPolicy{
+		MaxAge:      strings.TrimSpace(maxAge),
+		MaxMemories: int(maxMemories),
+		Action:      action,
+	}
+	if err := policy.Validate(); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid retention policy: %v", err)), nil
+	}
+
+	if err := a.ctx.SetRetention(contextID, policy); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to set retention policy: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Retention policy for context '%s': %s.", contextID, policy)), nil
+}
+
 // switchContextHandler switches the current context for a client.
 func (a *App) switchContextHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args, _ := request.Params.Arguments.(map[string]any)
@@ -66,7 +265,7 @@ func (a *App) switchContextHandler(ctx context.Context, request mcp.CallToolRequ
 
 	// Use provided client ID or default
 	if clientID = strings.TrimSpace(clientID); clientID == "" {
-		clientID = a.clientID
+		clientID = a.clientIDFrom(ctx)
 	}
 
 	// Register session if needed
@@ -76,6 +275,10 @@ func (a *App) switchContextHandler(ctx context.Context, request mcp.CallToolRequ
 		}
 	}
 
+	if msg := a.contextAccessDenied(clientID, contextID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
 	if err := a.ctx.SwitchContext(clientID, contextID); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to switch context: %v", err)), nil
 	}
@@ -106,29 +309,99 @@ func (a *App) shareContextHandler(ctx context.Context, request mcp.CallToolReque
 		}
 	}
 
-	if err := a.ctx.ShareContext(a.clientID, targetClientID, contextID); err != nil {
+	if msg := a.contextOwnerDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	if err := a.ctx.ShareContext(a.clientIDFrom(ctx), targetClientID, contextID); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to share context: %v", err)), nil
 	}
 
 	return mcp.NewToolResultText(fmt.Sprintf("Context '%s' shared with client '%s'.", contextID, targetClientID)), nil
 }
 
+// unshareContextHandler revokes a client's access to a context.
+func (a *App) unshareContextHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	contextID, _ := args["context_id"].(string)
+	targetClientID, _ := args["target_client_id"].(string)
+
+	contextID = strings.TrimSpace(contextID)
+	targetClientID = strings.TrimSpace(targetClientID)
+
+	if contextID == "" {
+		return mcp.NewToolResultError("Context ID cannot be empty"), nil
+	}
+	if targetClientID == "" {
+		return mcp.NewToolResultError("Target client ID cannot be empty"), nil
+	}
+
+	if msg := a.contextOwnerDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	switched, err := a.ctx.UnshareContext(a.clientIDFrom(ctx), targetClientID, contextID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to unshare context: %v", err)), nil
+	}
+	a.recordAudit(ctx, AuditEntry{Tool: "unshare_context", ClientID: a.clientIDFrom(ctx), ContextID: contextID, Status: "ok",
+		Details: "revoked access of " + targetClientID})
+
+	msg := fmt.Sprintf("Context '%s' is no longer shared with client '%s'.", contextID, targetClientID)
+	if switched {
+		msg += fmt.Sprintf(" The client was working in it and has been switched to '%s'.", DefaultContextID)
+	}
+	return mcp.NewToolResultText(msg), nil
+}
+
+// listContextSharesHandler lists the clients a context is shared with.
+func (a *App) listContextSharesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	contextID, _ := args["context_id"].(string)
+
+	if contextID = strings.TrimSpace(contextID); contextID == "" {
+		return mcp.NewToolResultError("Context ID cannot be empty"), nil
+	}
+
+	shares, err := a.ctx.GetContextShares(contextID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Context not found: %v", err)), nil
+	}
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+	if len(shares) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("Context '%s' is not shared with any client.", contextID)), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Context '%s' is shared with %d clients:\n", contextID, len(shares)))
+	for _, clientID := range shares {
+		sb.WriteString(fmt.Sprintf("- %s\n", clientID))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
 // createTagHandler creates a new tag for categorization.
 func (a *App) createTagHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args, _ := request.Params.Arguments.(map[string]any)
 	name, _ := args["name"].(string)
 	description, _ := args["description"].(string)
 	color, _ := args["color"].(string)
+	parent, _ := args["parent_tag"].(string)
 
 	name = strings.TrimSpace(name)
 	if name == "" {
 		return mcp.NewToolResultError("Tag name cannot be empty"), nil
 	}
 
-	if err := a.ctx.CreateTag(name, description, color); err != nil {
+	if err := a.ctx.CreateTag(name, description, color, parent); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to create tag: %v", err)), nil
 	}
 
+	if parent = strings.ToLower(strings.TrimSpace(parent)); parent != "" {
+		return mcp.NewToolResultText(fmt.Sprintf("Tag '%s' created under '%s'.", name, parent)), nil
+	}
 	return mcp.NewToolResultText(fmt.Sprintf("Tag '%s' created successfully.", name)), nil
 }
 
@@ -141,16 +414,18 @@ func (a *App) listTagsHandler(ctx context.Context, request mcp.CallToolRequest)
 
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("Available tags (%d total):\n\n", len(tags)))
+	// Child tags are indented below their parent
 	for _, tag := range tags {
-		sb.WriteString(fmt.Sprintf("- %s", tag.Name))
+		indent := strings.Repeat("  ", a.ctx.TagDepth(tag.Name))
+		sb.WriteString(fmt.Sprintf("%s- %s", indent, tag.Name))
 		if tag.Color != "" {
 			sb.WriteString(fmt.Sprintf(" (color: %s)", tag.Color))
 		}
 		sb.WriteString("\n")
 		if tag.Description != "" {
-			sb.WriteString(fmt.Sprintf("  %s\n", tag.Description))
+			sb.WriteString(fmt.Sprintf("%s  %s\n", indent, tag.Description))
 		}
-		sb.WriteString(fmt.Sprintf("  Memories: %d\n\n", tag.MemoryCount))
+		sb.WriteString(fmt.Sprintf("%s  Memories: %d\n\n", indent, tag.MemoryCount))
 	}
 
 	return mcp.NewToolResultText(sb.String()), nil
@@ -174,66 +449,578 @@ func (a *App) addTagHandler(ctx context.Context, request mcp.CallToolRequest) (*
 
 	tag = strings.ToLower(tag)
 
-	// Verify tag exists or create it
-	if _, err := a.ctx.GetTag(tag); err != nil {
-		if err := a.ctx.CreateTag(tag, "", ""); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create tag: %v", err)), nil
-		}
+	if _, err := a.addTags(ctx, memoryID, []string{tag}); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to add tag: %v", err)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Tag '%s' added to memory '%s'.", tag, memoryID)), nil
+}
+
+// removeTagHandler removes a tag from an existing memory.
+func (a *App) removeTagHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	memoryID, _ := args["memory_id"].(string)
+	tag, _ := args["tag"].(string)
+
+	memoryID = strings.TrimSpace(memoryID)
+	tag = strings.TrimSpace(tag)
+
+	if memoryID == "" {
+		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
+	}
+	if tag == "" {
+		return mcp.NewToolResultError("Tag cannot be empty"), nil
+	}
+
+	tag = strings.ToLower(tag)
+
+	removed, err := a.removeTags(ctx, memoryID, []string{tag})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to remove tag: %v", err)), nil
 	}
+	if len(removed) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' does not have tag '%s'.", memoryID, tag)), nil
+	}
+
+	return mcp.NewToolResultText(fmt.Sprintf("Tag '%s' removed from memory '%s'.", tag, memoryID)), nil
+}
 
+// addTags adds tags to a memory, creating tag definitions as needed, and
+// returns the tags that were not already present.
+func (a *App) addTags(ctx context.Context, memoryID string, newTags []string) ([]string, error) {
 	// Retrieve the existing memory to update its metadata
 	memory, err := a.vectorStore.GetByID(ctx, memoryID)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Memory not found: %v", err)), nil
+		return nil, fmt.Errorf("memory not found: %w", err)
+	}
+	if msg := a.memoryAccessDenied(ctx, memory); msg != "" {
+		return nil, errors.New(msg)
+	}
+
+	// Verify tags exist or create them
+	for _, tag := range newTags {
+		if _, err := a.ctx.GetTag(tag); err != nil {
+			if err := a.ctx.CreateTag(tag, "", "", ""); err != nil {
+				return nil, fmt.Errorf("failed to create tag %q: %w", tag, err)
+			}
+		}
 	}
 
-	// Update the tags field in metadata (comma-separated)
+	// Update the tags field in metadata
 	if memory.Metadata == nil {
 		memory.Metadata = make(map[string]string)
 	}
 
-	currentTags := memory.Metadata["tags"]
-	var tags []string
-	if currentTags != "" {
-		tags = strings.Split(currentTags, ",")
+	// Skip tags the memory already has
+	tags := ParseTags(memory.Metadata["tags"])
+	var added []string
+	for _, tag := range newTags {
+		if !hasTag(tags, tag) {
+			tags = append(tags, tag)
+			added = append(added, tag)
+		}
 	}
 
-	// Check if tag already exists
-	tagExists := false
-	for _, t := range tags {
-		if strings.TrimSpace(t) == tag {
-			tagExists = true
-			break
+	if len(added) > 0 {
+		memory.Metadata["tags"] = EncodeTags(tags)
+
+		// Only the metadata changes; the stored embedding is kept
+		if err := a.vectorStore.UpdateMetadata(ctx, memoryID, memory.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to update memory: %w", err)
+		}
+
+		// Memory updated (vector store persists automatically)
+		for _, tag := range added {
+			if err := a.ctx.IncrementTagCount(tag); err != nil {
+				a.logf(ctx, "Warning: Failed to increment tag count: %v", err)
+			}
 		}
 	}
 
-	if !tagExists {
-		tags = append(tags, tag)
-		memory.Metadata["tags"] = strings.Join(tags, ",")
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	return added, nil
+}
 
-		// Delete the old memory and re-add with updated metadata
-		if err := a.vectorStore.Delete(ctx, nil, nil, memoryID); err != nil {
-			a.logger.Printf("Warning: Failed to delete old memory during tag update: %v", err)
-		}
+// withTags adds tags to the tags metadata of a memory about to be stored,
+// keeping the tags it is stored with now.
+func withTags(metadata map[string]string, stored, tags []string) {
+	if len(tags) > 0 {
+		metadata["tags"] = EncodeTags(append(slices.Clone(stored), tags...))
+	}
+}
 
-		if err := a.vectorStore.AddDocument(ctx, memory); err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to update memory with tag: %v", err)), nil
+// rememberTags counts a memory that remember or remember_batch has just
+// stored under the tags it was not stored with before. It runs only once the
+// store succeeded, so a failed store leaves the tag counts alone.
+func (a *App) rememberTags(ctx context.Context, stored, tags []string) {
+	var added []string
+	for _, tag := range tags {
+		if !hasTag(stored, tag) {
+			added = append(added, tag)
 		}
+	}
+	a.countTags(ctx, added)
+}
 
-		// Memory updated (vector store persists automatically)
+// countTags counts a newly tagged memory under each of tags, creating the
+// tag definitions that do not exist yet.
+func (a *App) countTags(ctx context.Context, tags []string) {
+	for _, tag := range tags {
+		if _, err := a.ctx.GetTag(tag); err != nil {
+			if err := a.ctx.CreateTag(tag, "", "", ""); err != nil {
+				a.logf(ctx, "Warning: Failed to create tag '%s': %v", tag, err)
+				continue
+			}
+		}
 		if err := a.ctx.IncrementTagCount(tag); err != nil {
-			a.logger.Printf("Warning: Failed to increment tag count: %v", err)
+			a.logf(ctx, "Warning: Failed to increment tag count: %v", err)
+		}
+	}
+}
+
+// removeTags removes tags from a memory and returns the tags it actually had.
+func (a *App) removeTags(ctx context.Context, memoryID string, oldTags []string) ([]string, error) {
+	memory, err := a.vectorStore.GetByID(ctx, memoryID)
+	if err != nil {
+		return nil, fmt.Errorf("memory not found: %w", err)
+	}
+	if msg := a.memoryAccessDenied(ctx, memory); msg != "" {
+		return nil, errors.New(msg)
+	}
+
+	var kept, removed []string
+	for _, tag := range ParseTags(memory.Metadata["tags"]) {
+		if hasTag(oldTags, tag) {
+			removed = append(removed, tag)
+		} else {
+			kept = append(kept, tag)
+		}
+	}
+
+	if len(removed) > 0 {
+		memory.Metadata["tags"] = EncodeTags(kept)
+
+		// Only the metadata changes; the stored embedding is kept
+		if err := a.vectorStore.UpdateMetadata(ctx, memoryID, memory.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to update memory: %w", err)
+		}
+
+		for _, tag := range removed {
+			if err := a.ctx.DecrementTagCount(tag); err != nil {
+				a.logf(ctx, "Warning: Failed to decrement tag count: %v", err)
+			}
 		}
 	}
 
 	if err := a.ctx.Save(); err != nil {
-		a.logger.Printf("Warning: Failed to save context state: %v", err)
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
 	}
+	return removed, nil
+}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Tag '%s' added to memory '%s'.", tag, memoryID)), nil
+// moveMemoryHandler moves a memory, with its chunks, to another context.
+func (a *App) moveMemoryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	memoryID, _ := args["id"].(string)
+	targetID, _ := args["target_context_id"].(string)
+
+	memoryID = strings.TrimSpace(memoryID)
+	targetID = strings.TrimSpace(targetID)
+
+	if memoryID == "" {
+		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
+	}
+	if targetID == "" {
+		return mcp.NewToolResultError("Target context ID cannot be empty"), nil
+	}
+
+	if _, err := a.ctx.GetContext(targetID); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Target context not found: %v", err)), nil
+	}
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), targetID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	// Concurrent moves of one memory would each read the same source context
+	// and both decrement its count, so the memory is read under its lock
+	unlock := a.moveLocks.Lock(memoryID)
+	defer unlock()
+
+	memory, err := a.vectorStore.GetByID(ctx, memoryID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory not found: %v", err)), nil
+	}
+	if msg := a.memoryAccessDenied(ctx, memory); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+	if isChunk(memory.Metadata) {
+		return mcp.NewToolResultError(fmt.Sprintf("'%s' is a chunk; move its memory '%s' instead", memoryID, memory.Metadata["parent_id"])), nil
+	}
+
+	sourceID := memory.Metadata["context"]
+	if sourceID == "" {
+		sourceID = DefaultContextID
+	}
+	if sourceID == targetID {
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' is already in context '%s'.", memoryID, targetID)), nil
+	}
+
+	// The chunks of a long memory move along with it
+	documents := []chromem.Document{memory}
+	if isChunkedParent(memory.Metadata) {
+		chunks, err := a.chunksOf(ctx, memoryID)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to read chunks of '%s': %v", memoryID, err)), nil
+		}
+		documents = append(documents, chunks...)
+	}
+	for i, doc := range documents {
+		metadata := make(map[string]string, len(doc.Metadata)+1)
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		metadata["context"] = targetID
+		documents[i].Metadata = metadata
+	}
+
+	entry := AuditEntry{Tool: "move_memory", MemoryIDs: []string{memoryID}, ClientID: a.clientIDFrom(ctx), ContextID: targetID, Status: "ok",
+		Details: fmt.Sprintf("moved from context %q", sourceID)}
+
+	// Re-inserting under the same ID replaces the original, and the stored
+	// embeddings are reused so the content is not embedded again
+	if err := a.vectorStore.AddDocuments(ctx, documents, 1); err != nil {
+		entry.Status, entry.Details = "error", err.Error()
+		a.recordAudit(ctx, entry)
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to move memory: %v", err)), nil
+	}
+
+	if err := a.ctx.MoveMemoryCount(sourceID, targetID); err != nil {
+		a.logf(ctx, "Warning: Failed to update context counts: %v", err)
+	}
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	a.recordAudit(ctx, entry)
+
+	return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' moved from context '%s' to '%s'.", memoryID, sourceID, targetID)), nil
+}
+
+// mergeContextsHandler handles the merge_contexts tool - moves every memory of
+// the source context, including its chunks and trashed memories, into the
+// target context and adds the source's memory count to the target's. With
+// delete_source the emptied source context is removed.
+func (a *App) mergeContextsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	sourceID, _ := args["source_context_id"].(string)
+	targetID, _ := args["target_context_id"].(string)
+	deleteSource, _ := args["delete_source"].(bool)
+
+	sourceID = strings.TrimSpace(sourceID)
+	targetID = strings.TrimSpace(targetID)
+
+	if sourceID == "" {
+		return mcp.NewToolResultError("Source context ID cannot be empty"), nil
+	}
+	if targetID == "" {
+		return mcp.NewToolResultError("Target context ID cannot be empty"), nil
+	}
+	if sourceID == targetID {
+		return mcp.NewToolResultError("Source and target context must differ"), nil
+	}
+	if _, err := a.ctx.GetContext(sourceID); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Source context not found: %v", err)), nil
+	}
+	if _, err := a.ctx.GetContext(targetID); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Target context not found: %v", err)), nil
+	}
+	for _, id := range []string{sourceID, targetID} {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), id); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+	}
+	if deleteSource && sourceID == DefaultContextID {
+		return mcp.NewToolResultError("The default context cannot be deleted"), nil
+	}
+
+	if msg := a.mergeCapExceeded(ctx, sourceID, targetID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	entry := AuditEntry{Tool: "merge_contexts", ClientID: a.clientIDFrom(ctx), ContextID: targetID, Status: "ok",
+		Details: fmt.Sprintf("merged context %q into %q", sourceID, targetID)}
+	moved, err := a.moveContextMemories(ctx, sourceID, targetID)
+	entry.MemoryIDs = moved
+	if err != nil {
+		entry.Status, entry.Details = "error", err.Error()
+		a.recordAudit(ctx, entry)
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to move memories: %v", err)), nil
+	}
+
+	if err := a.ctx.MergeContexts(sourceID, targetID, deleteSource); err != nil {
+		a.logf(ctx, "Warning: Failed to update contexts: %v", err)
+	}
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	if deleteSource {
+		entry.Details += ", source deleted"
+		if err := a.refreshContextVectors(ctx); err != nil {
+			a.logf(ctx, "Warning: %v", err)
+		}
+	}
+	a.recordAudit(ctx, entry)
+
+	msg := fmt.Sprintf("Moved %d memories from context '%s' to '%s'.", len(moved), sourceID, targetID)
+	if deleteSource {
+		msg += fmt.Sprintf(" Context '%s' was deleted.", sourceID)
+	}
+	return mcp.NewToolResultText(msg), nil
+}
+
+// mergeCapExceeded returns a message when moving the memories of sourceID
+// would take targetID past its memory cap, or "" if they fit.
+func (a *App) mergeCapExceeded(ctx context.Context, sourceID, targetID string) string {
+	if a.settings().MaxMemoriesPerContext <= 0 {
+		return ""
+	}
+	docs, err := a.vectorStore.ListDocuments(ctx, map[string]string{"context": sourceID}, 0, 0)
+	if err != nil {
+		a.logf(ctx, "Warning: Failed to count the memories of context '%s': %v", sourceID, err)
+		return ""
+	}
+	var ids []string
+	for _, doc := range docs {
+		if !isChunk(doc.Metadata) && !isSoftDeleted(doc.Metadata) {
+			ids = append(ids, doc.ID)
+		}
+	}
+	return a.contextCapExceeded(ctx, targetID, ids...)
+}
+
+// moveContextMemories moves every memory of the context sourceID, including
+// its chunks and trashed memories, to targetID and returns the IDs of the
+// moved memories. The stored embeddings are reused, so nothing is embedded
+// again. Context counts are left to the caller.
+func (a *App) moveContextMemories(ctx context.Context, sourceID, targetID string) ([]string, error) {
+	docs, err := a.vectorStore.ListDocuments(ctx, map[string]string{"context": sourceID}, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	var moved []string
+	for i, doc := range docs {
+		metadata := make(map[string]string, len(doc.Metadata))
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		metadata["context"] = targetID
+		docs[i].Metadata = metadata
+		if !isChunk(metadata) {
+			moved = append(moved, doc.ID)
+		}
+	}
+	if len(docs) > 0 {
+		if err := a.vectorStore.AddDocuments(ctx, docs, 4); err != nil {
+			return nil, err
+		}
+	}
+	return moved, nil
+}
+
+// deleteContextHandler handles the delete_context tool - removes a context
+// and, depending on the strategy, refuses while it holds memories, moves its
+// memories to the default context or deletes them with their version
+// histories. Trashed memories and chunks count as memories of the context.
+func (a *App) deleteContextHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	contextID, _ := args["context_id"].(string)
+	strategy, _ := args["strategy"].(string)
+
+	contextID = strings.TrimSpace(contextID)
+	if contextID == "" {
+		return mcp.NewToolResultError("Context ID cannot be empty"), nil
+	}
+	switch strategy = strings.TrimSpace(strategy); strategy {
+	case "":
+		strategy = DeleteContextAbortIfNonEmpty
+	case DeleteContextAbortIfNonEmpty, DeleteContextReassign, DeleteContextCascade:
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("strategy must be '%s', '%s' or '%s'", DeleteContextAbortIfNonEmpty, DeleteContextReassign, DeleteContextCascade)), nil
+	}
+	if contextID == DefaultContextID {
+		return mcp.NewToolResultError("The default context cannot be deleted"), nil
+	}
+	if _, err := a.ctx.GetContext(contextID); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Context not found: %v", err)), nil
+	}
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	docs, err := a.vectorStore.ListDocuments(ctx, map[string]string{"context": contextID}, 0, 0)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list memories: %v", err)), nil
+	}
+	var ids []string
+	active := 0
+	for _, doc := range docs {
+		if isChunk(doc.Metadata) {
+			continue
+		}
+		ids = append(ids, doc.ID)
+		if !isSoftDeleted(doc.Metadata) {
+			active++
+		}
+	}
+
+	entry := AuditEntry{Tool: "delete_context", MemoryIDs: ids, ClientID: a.clientIDFrom(ctx), ContextID: contextID, Status: "ok",
+		Details: fmt.Sprintf("deleted context %q (%s)", contextID, strategy)}
+	var msg string
+	switch {
+	case len(ids) == 0 || strategy == DeleteContextAbortIfNonEmpty:
+		if len(ids) > 0 {
+			held := fmt.Sprintf("%d memories", len(ids))
+			if trashed := len(ids) - active; trashed > 0 {
+				held += fmt.Sprintf(" (%d in the trash)", trashed)
+			}
+			return mcp.NewToolResultError(fmt.Sprintf("Context '%s' still holds %s. Use strategy '%s' to move them to '%s' or '%s' to delete them.",
+				contextID, held, DeleteContextReassign, DefaultContextID, DeleteContextCascade)), nil
+		}
+		if err := a.ctx.DeleteContext(contextID); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to delete context: %v", err)), nil
+		}
+		msg = fmt.Sprintf("Context '%s' deleted.", contextID)
+
+	case strategy == DeleteContextReassign:
+		if _, err := a.moveContextMemories(ctx, contextID, DefaultContextID); err != nil {
+			entry.Status, entry.Details = "error", err.Error()
+			a.recordAudit(ctx, entry)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to move memories: %v", err)), nil
+		}
+		if err := a.ctx.MergeContexts(contextID, DefaultContextID, true); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to delete context: %v", err)), nil
+		}
+		msg = fmt.Sprintf("Context '%s' deleted; %d memories moved to '%s'.", contextID, len(ids), DefaultContextID)
+
+	case strategy == DeleteContextCascade:
+		if err := a.vectorStore.Delete(ctx, map[string]string{"context": contextID}, nil); err != nil {
+			entry.Status, entry.Details = "error", err.Error()
+			a.recordAudit(ctx, entry)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to delete memories: %v", err)), nil
+		}
+		for _, id := range ids {
+			if _, err := a.versionMgr.GetHistory(id); err == nil {
+				if err := a.versionMgr.DeleteMemoryHistory(id); err != nil {
+					a.logf(ctx, "Warning: Failed to delete version history of '%s': %v", id, err)
+				}
+			}
+		}
+		if active > 0 {
+			a.activity.Record(contextID, ActivityCounts{Deleted: active})
+		}
+		if err := a.ctx.DeleteContext(contextID); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to delete context: %v", err)), nil
+		}
+		msg = fmt.Sprintf("Context '%s' deleted with its %d memories.", contextID, len(ids))
+	}
+
+	a.recordAudit(ctx, entry)
+	if err := a.refreshContextVectors(ctx); err != nil {
+		a.logf(ctx, "Warning: %v", err)
+	}
+	return mcp.NewToolResultText(msg), nil
+}
+
+// autoTagHandler asks the LLM to suggest tags for a memory and adds them.
+func (a *App) autoTagHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	memoryID, _ := args["memory_id"].(string)
+	prefix, _ := args["tag_prefix"].(string)
+
+	if memoryID = strings.TrimSpace(memoryID); memoryID == "" {
+		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
+	}
+
+	maxTags := DefaultAutoTagCount
+	if n, ok := args["max_tags"].(float64); ok {
+		maxTags = max(1, min(int(n), MaxAutoTagCount))
+	}
+
+	memory, err := a.vectorStore.GetByID(ctx, memoryID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory not found: %v", err)), nil
+	}
+	if msg := a.memoryAccessDenied(ctx, memory); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	raw, err := a.generate(ctx, fmt.Sprintf(AutoTagPrompt, maxTags, memory.Content), nil)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM error: %v", err)), nil
+	}
+
+	suggested, err := parseTagList(raw)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Could not parse suggested tags: %v\n\nLLM output:\n%s", err, raw)), nil
+	}
+
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	var tags []string
+	for _, tag := range suggested {
+		tag = prefix + tag
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+		if len(tags) == maxTags {
+			break
+		}
+	}
+	if len(tags) == 0 {
+		return mcp.NewToolResultError("The LLM suggested no usable tags"), nil
+	}
+
+	added, err := a.addTags(ctx, memoryID, tags)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to add tags: %v", err)), nil
+	}
+
+	if len(added) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' already has the suggested tags: %s.", memoryID, strings.Join(tags, ", "))), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Added %d tags to memory '%s': %s.", len(added), memoryID, strings.Join(added, ", "))), nil
+}
+
+// parseTagList extracts the JSON array of tags from an LLM response, tolerating
+// surrounding prose or code fences, and normalizes each tag to lowercase words
+// joined by hyphens. Tags containing commas are dropped because tags are
+// stored comma-separated.
+func parseTagList(text string) ([]string, error) {
+	start, end := strings.Index(text, "["), strings.LastIndex(text, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("no JSON array in response")
+	}
+
+	var raw []string
+	if err := json.Unmarshal([]byte(text[start:end+1]), &raw); err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for _, tag := range raw {
+		tag = strings.Join(strings.Fields(strings.ToLower(tag)), "-")
+		if tag == "" || strings.Contains(tag, ",") || slices.Contains(tags, tag) {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
 }
 
-// searchByTagHandler searches for memories by tag.
+// searchByTagHandler searches for memories by tag. Only memories carrying the
+// tag as a whole entry match, listed by ID one page at a time; the backend
+// filters on the tags, so nothing is embedded or ranked.
 func (a *App) searchByTagHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args, _ := request.Params.Arguments.(map[string]any)
 	tagName, _ := args["tag"].(string)
@@ -245,43 +1032,85 @@ func (a *App) searchByTagHandler(ctx context.Context, request mcp.CallToolReques
 
 	tagName = strings.ToLower(tagName)
 
+	offset, limit := 0, DefaultTagPageSize
+	if v, ok := args["offset"].(float64); ok && v > 0 {
+		offset = int(v)
+	}
+	if v, ok := args["limit"].(float64); ok && v >= 1 {
+		limit = min(int(v), MaxTagPageSize)
+	}
+
 	// Verify tag exists
 	if _, err := a.ctx.GetTag(tagName); err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Tag not found: %v", err)), nil
 	}
 
-	// Query all memories and filter by tag
-	totalDocs := a.vectorStore.Count()
-	if totalDocs == 0 {
-		return mcp.NewToolResultText(EmptyBrainMsg), nil
+	searched := []string{tagName}
+	label := fmt.Sprintf("'%s'", tagName)
+	if includeChildren, _ := args["include_children"].(bool); includeChildren {
+		if descendants := a.ctx.GetTagDescendants(tagName); len(descendants) > 0 {
+			searched = append(searched, descendants...)
+			label = fmt.Sprintf("'%s' or its child tags", tagName)
+		}
 	}
 
-	results, err := a.vectorStore.Query(ctx, " ", totalDocs, nil, nil)
+	docs, err := a.listByTags(ctx, searched)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 	}
 
-	var sb strings.Builder
-	matchCount := 0
-
-	for _, res := range results {
-		// Check if memory has the tag in metadata
-		if tags, ok := res.Metadata["tags"]; ok && strings.Contains(tags, tagName) {
-			if matchCount == 0 {
-				sb.WriteString(fmt.Sprintf("Memories tagged with '%s':\n\n", tagName))
-			}
-			matchCount++
-			sb.WriteString(fmt.Sprintf("[%s]\n%s\n---\n", res.ID, res.Content))
+	// Chunks carry their parent's tags; the parent is listed instead
+	now := time.Now()
+	tagged := docs[:0]
+	for _, doc := range a.accessibleDocuments(ctx, docs) {
+		if !isChunk(doc.Metadata) && !isSoftDeleted(doc.Metadata) && !isExpired(doc.Metadata, now) {
+			tagged = append(tagged, doc)
 		}
 	}
 
-	if matchCount == 0 {
-		return mcp.NewToolResultText(fmt.Sprintf("No memories found with tag '%s'.", tagName)), nil
+	if len(tagged) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories found with tag %s.", label)), nil
+	}
+	if offset >= len(tagged) {
+		return mcp.NewToolResultText(fmt.Sprintf("%d memories are tagged with %s; offset %d is past the last one.", len(tagged), label, offset)), nil
+	}
+
+	page := tagged[offset:min(offset+limit, len(tagged))]
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Memories tagged with %s (%d-%d of %d):\n\n", label, offset+1, offset+len(page), len(tagged)))
+	for _, doc := range page {
+		sb.WriteString(fmt.Sprintf("[%s]\n%s\n---\n", doc.ID, doc.Content))
+	}
+	if next := offset + len(page); next < len(tagged) {
+		sb.WriteString(fmt.Sprintf("\nMore memories follow; call again with offset %d.\n", next))
 	}
 
 	return mcp.NewToolResultText(sb.String()), nil
 }
 
+// listByTags returns the memories carrying any of tags, once each, ordered by ID.
+func (a *App) listByTags(ctx context.Context, tags []string) ([]chromem.Document, error) {
+	if len(tags) == 1 {
+		return a.vectorStore.ListByTag(ctx, tags[0])
+	}
+	seen := make(map[string]bool)
+	var docs []chromem.Document
+	for _, tag := range tags {
+		tagged, err := a.vectorStore.ListByTag(ctx, tag)
+		if err != nil {
+			return nil, err
+		}
+		for _, doc := range tagged {
+			if !seen[doc.ID] {
+				seen[doc.ID] = true
+				docs = append(docs, doc)
+			}
+		}
+	}
+	slices.SortFunc(docs, func(x, y chromem.Document) int { return strings.Compare(x.ID, y.ID) })
+	return docs, nil
+}
+
 // saveToDiskHandler persists the database and context state to disk.
 func (a *App) saveToDiskHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	// Save vector database
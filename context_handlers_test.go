@@ -0,0 +1,48 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestConcurrentMovesCountOnce(t *testing.T) {
+	ta := newTestApp(t, nil)
+	for _, id := range []string{"home", "work"} {
+		if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": id, "name": id}); isErr {
+			t.Fatalf("create_context %s: %s", id, text)
+		}
+	}
+	ta.remember(t, "boiler", "the boiler is serviced every autumn", nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		target := "home"
+		if i%2 == 1 {
+			target = "work"
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			call(t, ta.moveMemoryHandler, map[string]any{"id": "boiler", "target_context_id": target})
+		}()
+	}
+	wg.Wait()
+
+	doc, err := ta.vectorStore.GetByID(t.Context(), "boiler")
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	counts := map[string]int{}
+	total := 0
+	for _, id := range []string{DefaultContextID, "home", "work"} {
+		c, err := ta.ctx.GetContext(id)
+		if err != nil {
+			t.Fatalf("GetContext %s: %v", id, err)
+		}
+		counts[id] = c.MemoryCount
+		total += c.MemoryCount
+	}
+	if total != 1 || counts[doc.Metadata["context"]] != 1 {
+		t.Errorf("counts after concurrent moves = %v with the memory in %q, want 1 in its context only", counts, doc.Metadata["context"])
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTestContextManager returns a ContextManager persisting to a temporary directory.
+func newTestContextManager(t *testing.T) *ContextManager {
+	t.Helper()
+	return NewContextManager(filepath.Join(t.TempDir(), ContextsDataPath), nil)
+}
+
+// Run with -race: 50 goroutines ask for the context of a client nobody has
+// seen, while others switch it and list sessions.
+func TestGetClientContextConcurrentRegistration(t *testing.T) {
+	cm := newTestContextManager(t)
+	if err := cm.CreateContext("work", "Work", "", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, goroutines)
+	for i := range goroutines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			contextID, err := cm.GetClientContext("newcomer")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if contextID != DefaultContextID && contextID != "work" {
+				errs <- fmt.Errorf("got context %q", contextID)
+			}
+			switch i % 5 {
+			case 0:
+				// Fails until some goroutine has registered the session
+				cm.SwitchContext("newcomer", "work")
+			case 1:
+				cm.ListSessions()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if n := cm.SessionCount(); n != 1 {
+		t.Errorf("%d sessions registered, want 1", n)
+	}
+	// The switch made by the goroutines is what later calls see
+	if contextID, err := cm.GetClientContext("newcomer"); err != nil || contextID != "work" {
+		t.Errorf("GetClientContext = %q, %v; want work", contextID, err)
+	}
+
+	reloaded := NewContextManager(cm.dataPath, nil)
+	if n := reloaded.SessionCount(); n != 1 {
+		t.Errorf("%d sessions persisted, want 1", n)
+	}
+}
+
+func TestGetClientContextRegistrationLimit(t *testing.T) {
+	cm := newTestContextManager(t)
+	for i := range MaxConcurrentClients {
+		if _, err := cm.GetClientContext(fmt.Sprintf("client-%d", i)); err != nil {
+			t.Fatalf("client %d: %v", i, err)
+		}
+	}
+
+	contextID, err := cm.GetClientContext("one-too-many")
+	if err == nil || !strings.Contains(err.Error(), "maximum concurrent clients") {
+		t.Errorf("GetClientContext past the limit = %q, %v; want the limit error", contextID, err)
+	}
+	if contextID, err := cm.GetClientContext("client-0"); err != nil || contextID != DefaultContextID {
+		t.Errorf("a registered client got %q, %v", contextID, err)
+	}
+}
+
+// memoryCounts returns the MemoryCount of each context in ids.
+func memoryCounts(t *testing.T, cm *ContextManager, ids ...string) map[string]int {
+	t.Helper()
+	counts := map[string]int{}
+	for _, id := range ids {
+		c, err := cm.GetContext(id)
+		if err != nil {
+			t.Fatalf("GetContext %s: %v", id, err)
+		}
+		counts[id] = c.MemoryCount
+	}
+	return counts
+}
+
+// newTwoContextApp returns a testApp with d1 and d2 in the default context and
+// w1 to w3 in "work", switched back to the default context.
+func newTwoContextApp(t *testing.T, configure func(cfg *Config)) *testApp {
+	t.Helper()
+	ta := newTestApp(t, configure)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.remember(t, "d1", "default memory one", nil)
+	ta.remember(t, "d2", "default memory two", nil)
+	ta.switchContext(t, "work")
+	for _, id := range []string{"w1", "w2", "w3"} {
+		ta.remember(t, id, "work memory "+id, nil)
+	}
+	ta.switchContext(t, DefaultContextID)
+	if got := memoryCounts(t, ta.ctx, DefaultContextID, "work"); got[DefaultContextID] != 2 || got["work"] != 3 {
+		t.Fatalf("counts after storing = %v", got)
+	}
+	return ta
+}
+
+func TestDeleteDecrementsTheMemorysContext(t *testing.T) {
+	ta := newTwoContextApp(t, nil)
+
+	if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "w2"}); isErr {
+		t.Fatalf("delete_memory: %s", text)
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID, "work"); got[DefaultContextID] != 2 || got["work"] != 2 {
+		t.Errorf("counts after deleting a work memory from the default context = %v, want default 2, work 2", got)
+	}
+
+	text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "no-such-memory"})
+	if !isErr || !strings.Contains(text, "not found") {
+		t.Errorf("deleting a missing memory = %q, want a not-found error", text)
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID, "work"); got[DefaultContextID] != 2 || got["work"] != 2 {
+		t.Errorf("counts after a failed delete = %v", got)
+	}
+
+	// The counts survive a restart
+	reloaded := NewContextManager(ta.ctx.dataPath, nil)
+	if got := memoryCounts(t, reloaded, DefaultContextID, "work"); got["work"] != 2 {
+		t.Errorf("persisted counts = %v", got)
+	}
+}
+
+// Trashing uncounts a memory once; purging it from the trash does not count it again.
+func TestSoftDeleteCountsOnce(t *testing.T) {
+	ta := newTwoContextApp(t, func(cfg *Config) { cfg.SoftDelete = true })
+
+	for range 2 {
+		if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "w1"}); isErr {
+			t.Fatalf("delete_memory: %s", text)
+		}
+		if got := memoryCounts(t, ta.ctx, "work"); got["work"] != 2 {
+			t.Errorf("work count = %d, want 2", got["work"])
+		}
+	}
+	if _, err := ta.vectorStore.GetByID(t.Context(), "w1"); err == nil {
+		t.Error("deleting a trashed memory did not remove it")
+	}
+}
+
+func TestWipeResetsEveryContextWithMemories(t *testing.T) {
+	ta := newTwoContextApp(t, nil)
+	if text, isErr := call(t, ta.wipeHandler, nil); isErr {
+		t.Fatalf("wipe_all_memories: %s", text)
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID, "work"); got[DefaultContextID] != 0 || got["work"] != 0 {
+		t.Errorf("counts after wipe = %v, want all 0", got)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ContextVectors keeps one embedding per context of its name and description,
+// so contexts can be matched by what they are meant to hold even before they
+// contain memories. Embeddings are persisted in the data directory together
+// with the text they were computed from; a context is re-embedded whenever its
+// name or description changes.
+type ContextVectors struct {
+	mu      sync.Mutex
+	vectors map[string]contextVector // context ID -> embedding
+	path    string
+	cipher  *FileCipher // Encrypts the embeddings file when set
+	logger  *log.Logger
+}
+
+// contextVector is the persisted embedding of one context description.
+type contextVector struct {
+	Text      string    `json:"text"` // Embedded text, compared to detect changes
+	Embedding []float32 `json:"embedding"`
+}
+
+// ContextMatch is one context ranked by find_context.
+type ContextMatch struct {
+	ContextID   string  `json:"context_id"`
+	Name        string  `json:"name"`
+	Score       float64 `json:"score"`       // Blend of description and content similarity
+	Description float64 `json:"description"` // Similarity to the context's name and description
+	Content     float64 `json:"content"`     // Best similarity of a memory in the context
+}
+
+// NewContextVectors creates a context vector store persisted at path and loads any existing embeddings.
+func NewContextVectors(path string, cipher *FileCipher, logger *log.Logger) *ContextVectors {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	cv := &ContextVectors{
+		vectors: make(map[string]contextVector),
+		path:    path,
+		cipher:  cipher,
+		logger:  logger,
+	}
+
+	data, err := cipher.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &cv.vectors)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		logger.Printf("Warning: Failed to load context vectors: %v. They will be recomputed.", err)
+		cv.vectors = make(map[string]contextVector)
+	}
+
+	return cv
+}
+
+// contextDescriptionText is the text embedded for a context.
+func contextDescriptionText(c *Context) string {
+	if c.Description == "" {
+		return c.Name
+	}
+	return c.Name + ": " + c.Description
+}
+
+// Refresh embeds contexts that are new or whose name or description changed,
+// drops contexts that no longer exist and persists any changes.
+func (cv *ContextVectors) Refresh(ctx context.Context, contexts []*Context, embed func(context.Context, []string) ([][]float32, error)) error {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+
+	var stale []string
+	texts := make(map[string]string, len(contexts))
+	for _, c := range contexts {
+		text := contextDescriptionText(c)
+		texts[c.ID] = text
+		if v, ok := cv.vectors[c.ID]; !ok || v.Text != text {
+			stale = append(stale, c.ID)
+		}
+	}
+
+	changed := false
+	for id := range cv.vectors {
+		if _, ok := texts[id]; !ok {
+			delete(cv.vectors, id)
+			changed = true
+		}
+	}
+
+	if len(stale) > 0 {
+		batch := make([]string, len(stale))
+		for i, id := range stale {
+			batch[i] = texts[id]
+		}
+		embeddings, err := embed(ctx, batch)
+		if err != nil {
+			return fmt.Errorf("failed to embed context descriptions: %w", err)
+		}
+		for i, id := range stale {
+			cv.vectors[id] = contextVector{Text: batch[i], Embedding: embeddings[i]}
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+	data, err := json.Marshal(cv.vectors)
+	if err != nil {
+		return fmt.Errorf("failed to marshal context vectors: %w", err)
+	}
+	return cv.cipher.WriteFile(cv.path, data, 0600)
+}
+
+// Similarities returns the cosine similarity of query to every context description.
+func (cv *ContextVectors) Similarities(query []float32) map[string]float64 {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+
+	sims := make(map[string]float64, len(cv.vectors))
+	for id, v := range cv.vectors {
+		if len(v.Embedding) == len(query) {
+			sims[id] = vecmath.Cosine(query, v.Embedding)
+		}
+	}
+	return sims
+}
+
+// refreshContextVectors brings the context description embeddings up to date.
+func (a *App) refreshContextVectors(ctx context.Context) error {
+	if a.contextVectors == nil {
+		return nil
+	}
+	return a.contextVectors.Refresh(ctx, a.ctx.ListContexts(), a.vectorStore.BatchEmbed)
+}
+
+// rankContexts scores every context for text by blending the similarity to
+// its name and description with the best similarity of a memory it holds.
+// The description acts as a prior, so empty or sparse contexts still match.
+func (a *App) rankContexts(ctx context.Context, text string) ([]ContextMatch, error) {
+	if err := a.refreshContextVectors(ctx); err != nil {
+		a.logf(ctx, "Warning: %v", err)
+	}
+
+	embeddings, err := a.vectorStore.BatchEmbed(ctx, []string{QueryTaskPrefix + text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	var descSims map[string]float64
+	if a.contextVectors != nil {
+		descSims = a.contextVectors.Similarities(embeddings[0])
+	}
+
+	contentSims := make(map[string]float64)
+	if n := min(ContextRoutingProbeResults, a.vectorStore.Count()); n > 0 {
+		results, err := a.vectorStore.QueryEmbedding(ctx, embeddings[0], n, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search memories: %w", err)
+		}
+		for _, res := range visibleResults(results, a.clock()) {
+			contextID := res.Metadata["context"]
+			if contextID == "" {
+				contextID = DefaultContextID
+			}
+			if sim, ok := contentSims[contextID]; !ok || float64(res.Similarity) > sim {
+				contentSims[contextID] = float64(res.Similarity)
+			}
+		}
+	}
+
+	var matches []ContextMatch
+	for _, c := range a.ctx.ListContexts() {
+		m := ContextMatch{ContextID: c.ID, Name: c.Name, Description: descSims[c.ID], Content: contentSims[c.ID]}
+		m.Score = ContextDescriptionWeight*m.Description + (1-ContextDescriptionWeight)*m.Content
+		matches = append(matches, m)
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ContextID < matches[j].ContextID
+	})
+	return matches, nil
+}
+
+// findContextHandler handles the find_context tool - suggests the contexts that
+// best fit a piece of text, for deciding where to store a memory.
+func (a *App) findContextHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	query, _ := args["query"].(string)
+	if query = strings.TrimSpace(query); query == "" {
+		return mcp.NewToolResultError("Query cannot be empty"), nil
+	}
+
+	limit := DefaultFindContextResults
+	if v, ok := args["max_results"].(float64); ok {
+		limit = max(1, int(v))
+	}
+
+	matches, err := a.rankContexts(ctx, query)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if len(matches) == 0 {
+		return mcp.NewToolResultText("No contexts found."), nil
+	}
+	matches = matches[:min(limit, len(matches))]
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Best matching contexts for %q:\n", query))
+	for _, m := range matches {
+		sb.WriteString(fmt.Sprintf("- [%s] %s (score %.2f: description %.2f, content %.2f)\n", m.ContextID, m.Name, m.Score, m.Description, m.Content))
+	}
+
+	data, err := json.MarshalIndent(matches, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to encode matches: %v", err)), nil
+	}
+	return mcp.NewToolResultText(sb.String() + "\n" + string(data)), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// topContext returns the best match find_context reports for query.
+func topContext(t *testing.T, ta *testApp, query string) string {
+	t.Helper()
+	matches, err := ta.rankContexts(t.Context(), query)
+	if err != nil {
+		t.Fatalf("rankContexts: %v", err)
+	}
+	return matches[0].ContextID
+}
+
+// newRoutingApp returns a testApp whose contexts are told apart only by their
+// descriptions: no memory shares a word with the routing queries, except a
+// decoy in the default context.
+func newRoutingApp(t *testing.T) *testApp {
+	t.Helper()
+	ta := newTestApp(t, nil)
+	for _, c := range []map[string]any{
+		{"id": "taxes", "name": "Taxes", "description": "quarterly tax filing and deductions"},
+		{"id": "garden", "name": "Garden", "description": "vegetable beds watering and compost"},
+	} {
+		if text, isErr := call(t, ta.createContextHandler, c); isErr {
+			t.Fatalf("create_context: %s", text)
+		}
+	}
+	ta.switchContext(t, "taxes")
+	ta.remember(t, "receipt", "receipt from the accountant", nil)
+	ta.switchContext(t, "garden")
+	ta.remember(t, "tomatoes", "tomatoes need full sun", nil)
+	ta.switchContext(t, DefaultContextID)
+	ta.remember(t, "decoy", "the filing cabinet meeting is quarterly", nil)
+	return ta
+}
+
+func TestDescriptionsImproveRouting(t *testing.T) {
+	ta := newRoutingApp(t)
+	queries := map[string]string{
+		"quarterly tax filing":    "taxes",
+		"tax deductions":          "taxes",
+		"watering the vegetables": "garden",
+		"compost for beds":        "garden",
+	}
+
+	// Without description vectors only contents count, and the queries route badly
+	vectors := ta.contextVectors
+	ta.contextVectors = nil
+	wrong := 0
+	for query, want := range queries {
+		if topContext(t, ta, query) != want {
+			wrong++
+		}
+	}
+	if wrong == 0 {
+		t.Fatal("the fixture routes correctly without descriptions; it does not test them")
+	}
+
+	ta.contextVectors = vectors
+	for query, want := range queries {
+		if got := topContext(t, ta, query); got != want {
+			t.Errorf("%q routed to %s, want %s", query, got, want)
+		}
+	}
+
+	text, isErr := call(t, ta.findContextHandler, map[string]any{"query": "quarterly tax filing", "max_results": 2.0})
+	if isErr || !strings.HasPrefix(strings.SplitN(text, "\n", 3)[1], "- [taxes] Taxes") {
+		t.Errorf("find_context:\n%s", text)
+	}
+	if n := strings.Count(text, "\n- ["); n != 2 {
+		t.Errorf("find_context listed %d contexts, want max_results 2", n)
+	}
+}
+
+func TestUpdatedDescriptionIsReembedded(t *testing.T) {
+	ta := newRoutingApp(t)
+	gardenScore := func() float64 {
+		matches, err := ta.rankContexts(t.Context(), "sourdough starter recipe")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range matches {
+			if m.ContextID == "garden" {
+				return m.Description
+			}
+		}
+		t.Fatal("garden was not ranked")
+		return 0
+	}
+	if score := gardenScore(); score > 0.3 {
+		t.Fatalf("garden already matches the new description (%.2f)", score)
+	}
+
+	// Ranking again embeds only the query
+	before := ta.embedder.Count()
+	topContext(t, ta, "quarterly tax filing")
+	if n := ta.embedder.Count() - before; n != 1 {
+		t.Errorf("ranking with unchanged descriptions embedded %d texts, want 1", n)
+	}
+
+	if text, isErr := call(t, ta.updateContextHandler, map[string]any{"context_id": "garden", "description": "sourdough starter and bread recipe"}); isErr {
+		t.Fatalf("update_context: %s", text)
+	}
+	if score := gardenScore(); score < 0.6 {
+		t.Errorf("garden description similarity %.2f after the description changed, want at least 0.6", score)
+	}
+	if got := topContext(t, ta, "sourdough starter recipe"); got != "garden" {
+		t.Errorf("routed to %s after the description changed, want garden", got)
+	}
+
+	// The embeddings persist with the text they were computed from
+	reloaded := NewContextVectors(ta.contextVectors.path, nil, nil)
+	if v := reloaded.vectors["garden"]; v.Text != "Garden: sourdough starter and bread recipe" || len(v.Embedding) != testDimension {
+		t.Errorf("persisted garden vector = %q, %d dimensions", v.Text, len(v.Embedding))
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exchange is one question of an ask_brain conversation and its answer.
+type Exchange struct {
+	Question string
+	Answer   string
+}
+
+// ConversationStore keeps the latest exchanges of ask_brain conversations in
+// memory, so follow-up questions can refer to earlier ones. Conversations are
+// lost on restart. A nil *ConversationStore is valid and remembers nothing.
+type ConversationStore struct {
+	mu       sync.Mutex
+	turns    map[string][]Exchange // Conversation ID -> exchanges, oldest first
+	lastUsed map[string]time.Time
+}
+
+// NewConversationStore creates an empty store.
+func NewConversationStore() *ConversationStore {
+	return &ConversationStore{turns: make(map[string][]Exchange), lastUsed: make(map[string]time.Time)}
+}
+
+// History returns a copy of the exchanges of a conversation, oldest first.
+func (cs *ConversationStore) History(id string) []Exchange {
+	if cs == nil {
+		return nil
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return append([]Exchange(nil), cs.turns[id]...)
+}
+
+// Append adds an exchange to a conversation, keeping only the last maxTurns.
+func (cs *ConversationStore) Append(id string, exchange Exchange, maxTurns int, now time.Time) {
+	if cs == nil || maxTurns <= 0 {
+		return
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	turns := append(cs.turns[id], exchange)
+	if len(turns) > maxTurns {
+		turns = append([]Exchange(nil), turns[len(turns)-maxTurns:]...)
+	}
+	cs.turns[id] = turns
+	cs.lastUsed[id] = now
+}
+
+// EvictIdle drops the conversations last used more than ttl before now and
+// returns how many were dropped.
+func (cs *ConversationStore) EvictIdle(now time.Time, ttl time.Duration) int {
+	if cs == nil {
+		return 0
+	}
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	evicted := 0
+	for id, used := range cs.lastUsed {
+		if now.Sub(used) > ttl {
+			delete(cs.turns, id)
+			delete(cs.lastUsed, id)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// startConversationCleanup evicts conversations idle for longer than ttl
+// every ConversationSweepInterval until ctx is cancelled.
+func (a *App) startConversationCleanup(ctx context.Context, ttl time.Duration) {
+	go func() {
+		ticker := time.NewTicker(ConversationSweepInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if n := a.conversations.EvictIdle(time.Now(), ttl); n > 0 {
+				a.logger.Printf("Evicted %d ask_brain conversations idle for over %s", n, ttl)
+			}
+		}
+	}()
+}
+
+// formatHistory renders earlier exchanges for the ask_brain prompt.
+func formatHistory(history []Exchange) string {
+	var sb strings.Builder
+	for i, ex := range history {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(fmt.Sprintf("User: %s\nAssistant: %s\n", ex.Question, ex.Answer))
+	}
+	return sb.String()
+}
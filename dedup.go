@@ -0,0 +1,401 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// normalizeContent trims, lowercases and collapses whitespace so that
+// trivially different copies of the same text compare equal.
+func normalizeContent(content string) string {
+	return strings.Join(strings.Fields(strings.ToLower(content)), " ")
+}
+
+// normalizedHash returns the hex SHA-256 of the normalized content. It is
+// stored in the content_hash metadata key of every memory.
+func normalizedHash(content string) string {
+	sum := sha256.Sum256([]byte(normalizeContent(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentHashIndex maps normalized content hashes to memory IDs so exact
+// duplicates can be found without a similarity search. It is kept in memory
+// and rebuilt from the backend at startup. Soft-deleted memories are not indexed.
+type ContentHashIndex struct {
+	mu     sync.RWMutex
+	byHash map[string]map[string]struct{} // hash -> memory IDs
+	byID   map[string]string              // memory ID -> hash
+}
+
+// NewContentHashIndex creates an empty index.
+func NewContentHashIndex() *ContentHashIndex {
+	return &ContentHashIndex{
+		byHash: make(map[string]map[string]struct{}),
+		byID:   make(map[string]string),
+	}
+}
+
+// Add indexes documents, replacing earlier entries for the same IDs.
+func (hi *ContentHashIndex) Add(documents []chromem.Document) {
+	hi.mu.Lock()
+	defer hi.mu.Unlock()
+	for _, doc := range documents {
+		hi.removeLocked(doc.ID)
+		if isSoftDeleted(doc.Metadata) {
+			continue
+		}
+		hash := normalizedHash(doc.Content)
+		if hi.byHash[hash] == nil {
+			hi.byHash[hash] = make(map[string]struct{})
+		}
+		hi.byHash[hash][doc.ID] = struct{}{}
+		hi.byID[doc.ID] = hash
+	}
+}
+
+// Remove drops documents from the index.
+func (hi *ContentHashIndex) Remove(ids []string) {
+	hi.mu.Lock()
+	defer hi.mu.Unlock()
+	for _, id := range ids {
+		hi.removeLocked(id)
+	}
+}
+
+// Reset empties the index.
+func (hi *ContentHashIndex) Reset() {
+	hi.mu.Lock()
+	defer hi.mu.Unlock()
+	hi.byHash = make(map[string]map[string]struct{})
+	hi.byID = make(map[string]string)
+}
+
+// Rebuild replaces the index with the documents currently in the backend.
+func (hi *ContentHashIndex) Rebuild(ctx context.Context, backend VectorBackend) error {
+	docs, err := backend.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list documents: %w", err)
+	}
+	hi.Reset()
+	hi.Add(docs)
+	return nil
+}
+
+// Lookup returns the IDs of memories whose content hashes to hash, except
+// excludeID, in sorted order.
+func (hi *ContentHashIndex) Lookup(hash, excludeID string) []string {
+	hi.mu.RLock()
+	defer hi.mu.RUnlock()
+	var ids []string
+	for id := range hi.byHash[hash] {
+		if id != excludeID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Collisions returns every group of two or more memories sharing a hash.
+func (hi *ContentHashIndex) Collisions() [][]string {
+	hi.mu.RLock()
+	defer hi.mu.RUnlock()
+	var groups [][]string
+	for _, ids := range hi.byHash {
+		if len(ids) < 2 {
+			continue
+		}
+		group := make([]string, 0, len(ids))
+		for id := range ids {
+			group = append(group, id)
+		}
+		sort.Strings(group)
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i][0] < groups[j][0] })
+	return groups
+}
+
+// Len returns the number of indexed memories.
+func (hi *ContentHashIndex) Len() int {
+	hi.mu.RLock()
+	defer hi.mu.RUnlock()
+	return len(hi.byID)
+}
+
+// removeLocked drops one ID (caller must hold the lock).
+func (hi *ContentHashIndex) removeLocked(id string) {
+	hash, ok := hi.byID[id]
+	if !ok {
+		return
+	}
+	delete(hi.byID, id)
+	delete(hi.byHash[hash], id)
+	if len(hi.byHash[hash]) == 0 {
+		delete(hi.byHash, hash)
+	}
+}
+
+// parseDuplicateStrategy reads the duplicate_strategy argument.
+func parseDuplicateStrategy(args map[string]any) (string, error) {
+	strategy, _ := args["duplicate_strategy"].(string)
+	switch strategy = strings.TrimSpace(strategy); strategy {
+	case "":
+		return DuplicateSkip, nil
+	case DuplicateSkip, DuplicateLink, DuplicateOverwrite:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("duplicate_strategy must be '%s', '%s' or '%s'", DuplicateSkip, DuplicateLink, DuplicateOverwrite)
+}
+
+// exactDuplicate returns the ID of a stored memory other than id whose content
+// is identical after normalization, or "" if there is none. Memories in
+// contexts the client may not use are not considered.
+func (a *App) exactDuplicate(ctx context.Context, id, content string) string {
+	for _, dupID := range a.hashIndex.Lookup(normalizedHash(content), id) {
+		if doc, err := a.vectorStore.GetByID(ctx, dupID); err == nil && a.memoryAccessDenied(ctx, doc) == "" {
+			return dupID
+		}
+	}
+	return ""
+}
+
+// linkDuplicate records dupID as merged into the memory keepID and folds the
+// given tags into it.
+func (a *App) linkDuplicate(ctx context.Context, keepID, dupID string, tags []string) error {
+	keep, err := a.vectorStore.GetByID(ctx, keepID)
+	if err != nil {
+		return fmt.Errorf("memory %q not found: %w", keepID, err)
+	}
+
+	metadata := make(map[string]string, len(keep.Metadata)+2)
+	for k, v := range keep.Metadata {
+		metadata[k] = v
+	}
+	if merged := mergeTags(metadata["tags"], EncodeTags(tags)); merged != "" {
+		metadata["tags"] = merged
+	}
+	metadata["merged_ids"] = mergeList(metadata["merged_ids"], dupID)
+	keep.Metadata = metadata
+
+	if err := a.vectorStore.AddDocument(ctx, keep); err != nil {
+		return fmt.Errorf("failed to update memory %q: %w", keepID, err)
+	}
+	return nil
+}
+
+// removeDuplicates deletes the given memories and decrements the memory count
+// of the context each one belonged to.
+func (a *App) removeDuplicates(ctx context.Context, ids []string) error {
+	contexts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		contextID := DefaultContextID
+		if doc, err := a.vectorStore.GetByID(ctx, id); err == nil && doc.Metadata["context"] != "" {
+			contextID = doc.Metadata["context"]
+		}
+		contexts = append(contexts, contextID)
+	}
+
+	if err := a.vectorStore.Delete(ctx, nil, nil, ids...); err != nil {
+		return fmt.Errorf("failed to delete duplicates: %w", err)
+	}
+	for _, contextID := range contexts {
+		if err := a.ctx.DecrementMemoryCount(contextID); err != nil {
+			a.logf(ctx, "Warning: Failed to update context count: %v", err)
+		}
+		a.activity.Record(contextID, ActivityCounts{Deleted: 1})
+	}
+	return nil
+}
+
+// duplicateSet tracks exact duplicates found while storing a batch of memories
+// and the follow-up work their strategy requires once the batch is stored.
+type duplicateSet struct {
+	seen        map[string]string // hash -> ID admitted earlier in the batch
+	skipped     []string
+	links       []duplicateLink
+	overwritten []string // Existing memories replaced by batch members
+}
+
+// duplicateLink records a duplicate that is linked to an existing memory
+// instead of being stored.
+type duplicateLink struct {
+	keepID string
+	dupID  string
+	tags   []string // Tags of the duplicate, folded into keepID
+}
+
+// admit reports whether a batch member should be stored. Duplicates of stored
+// memories follow strategy. Duplicates within the batch are never stored; with
+// the link strategy they are linked to the first copy.
+func (ds *duplicateSet) admit(ctx context.Context, a *App, strategy, id, content string, tags []string) bool {
+	if ds.seen == nil {
+		ds.seen = make(map[string]string)
+	}
+	hash := normalizedHash(content)
+	if first, ok := ds.seen[hash]; ok && first != id {
+		if strategy == DuplicateLink {
+			ds.links = append(ds.links, duplicateLink{keepID: first, dupID: id, tags: tags})
+		} else {
+			ds.skipped = append(ds.skipped, id)
+		}
+		return false
+	}
+
+	if existing := a.exactDuplicate(ctx, id, content); existing != "" {
+		switch strategy {
+		case DuplicateSkip:
+			ds.skipped = append(ds.skipped, id)
+			return false
+		case DuplicateLink:
+			ds.links = append(ds.links, duplicateLink{keepID: existing, dupID: id, tags: tags})
+			return false
+		case DuplicateOverwrite:
+			ds.overwritten = append(ds.overwritten, existing)
+		}
+	}
+	ds.seen[hash] = id
+	return true
+}
+
+// apply links and removes duplicates after the admitted memories were stored.
+func (ds *duplicateSet) apply(ctx context.Context, a *App) {
+	for _, link := range ds.links {
+		if err := a.linkDuplicate(ctx, link.keepID, link.dupID, link.tags); err != nil {
+			a.logf(ctx, "Warning: Failed to link duplicate '%s': %v", link.dupID, err)
+		}
+	}
+	if len(ds.overwritten) > 0 {
+		if err := a.removeDuplicates(ctx, ds.overwritten); err != nil {
+			a.logf(ctx, "Warning: Failed to remove overwritten duplicates: %v", err)
+		}
+	}
+}
+
+// count returns the number of duplicates found.
+func (ds *duplicateSet) count() int {
+	return len(ds.skipped) + len(ds.links) + len(ds.overwritten)
+}
+
+// summary describes the duplicates for tool output.
+func (ds *duplicateSet) summary() string {
+	var parts []string
+	if len(ds.skipped) > 0 {
+		parts = append(parts, fmt.Sprintf("%d skipped (%s)", len(ds.skipped), strings.Join(ds.skipped, ", ")))
+	}
+	if len(ds.links) > 0 {
+		linked := make([]string, len(ds.links))
+		for i, link := range ds.links {
+			linked[i] = link.dupID + " -> " + link.keepID
+		}
+		parts = append(parts, fmt.Sprintf("%d linked (%s)", len(ds.links), strings.Join(linked, ", ")))
+	}
+	if len(ds.overwritten) > 0 {
+		parts = append(parts, fmt.Sprintf("%d overwritten (%s)", len(ds.overwritten), strings.Join(ds.overwritten, ", ")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// mergeList appends the comma-separated items of add to list, skipping items
+// that are already present.
+func mergeList(list, add string) string {
+	var items []string
+	for _, item := range strings.Split(list+","+add, ",") {
+		if item = strings.TrimSpace(item); item != "" && !slices.Contains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// dedupeExactHandler handles the dedupe_exact tool - merges memories with
+// identical normalized content. In each group the oldest memory is kept, the
+// others' tags are folded into it and their IDs are recorded in merged_ids.
+func (a *App) dedupeExactHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	dryRun, _ := args["dry_run"].(bool)
+
+	groups := a.hashIndex.Collisions()
+	if len(groups) == 0 {
+		return mcp.NewToolResultText("No exact duplicates found."), nil
+	}
+
+	var sb strings.Builder
+	merged := 0
+	for _, group := range groups {
+		docs := make([]chromem.Document, 0, len(group))
+		for _, id := range group {
+			if doc, err := a.vectorStore.GetByID(ctx, id); err == nil && a.memoryAccessDenied(ctx, doc) == "" {
+				docs = append(docs, doc)
+			}
+		}
+		if len(docs) < 2 {
+			continue
+		}
+
+		// Memories without created_at predate timestamps and count as oldest
+		sort.SliceStable(docs, func(i, j int) bool {
+			return createdTime(docs[i]).Before(createdTime(docs[j]))
+		})
+		keep, dups := docs[0], docs[1:]
+
+		dupIDs := make([]string, len(dups))
+		for i, dup := range dups {
+			dupIDs[i] = dup.ID
+		}
+		sb.WriteString(fmt.Sprintf("- keep %s, merge %s\n", keep.ID, strings.Join(dupIDs, ", ")))
+		if dryRun {
+			continue
+		}
+
+		metadata := make(map[string]string, len(keep.Metadata)+2)
+		for k, v := range keep.Metadata {
+			metadata[k] = v
+		}
+		for _, dup := range dups {
+			if tags := mergeTags(metadata["tags"], dup.Metadata["tags"]); tags != "" {
+				metadata["tags"] = tags
+			}
+			metadata["merged_ids"] = mergeList(metadata["merged_ids"], mergeList(dup.ID, dup.Metadata["merged_ids"]))
+		}
+		keep.Metadata = metadata
+
+		err := a.vectorStore.AddDocument(ctx, keep)
+		if err == nil {
+			err = a.removeDuplicates(ctx, dupIDs)
+		}
+		entry := AuditEntry{Tool: "dedupe_exact", MemoryIDs: group, ContextID: keep.Metadata["context"], Status: "ok", Details: "kept " + keep.ID}
+		if err != nil {
+			entry.Status, entry.Details = "error", err.Error()
+			a.recordAudit(ctx, entry)
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to merge duplicates of '%s': %v", keep.ID, err)), nil
+		}
+		a.recordAudit(ctx, entry)
+		merged += len(dups)
+	}
+
+	if dryRun {
+		return mcp.NewToolResultText(fmt.Sprintf("Found %d groups of exact duplicates (dry run, nothing changed):\n%s", len(groups), sb.String())), nil
+	}
+
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Merged %d duplicate memories in %d groups:\n%s", merged, len(groups), sb.String())), nil
+}
+
+// createdTime returns a document's created_at time, or the zero time if unknown.
+func createdTime(doc chromem.Document) time.Time {
+	t, _, _ := parseStoredTime(doc.Metadata["created_at"])
+	return t
+}
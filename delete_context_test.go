@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DatanoiseTV/brainmcp/internal/backendtest"
+	"github.com/philippgille/chromem-go"
+)
+
+// deleteContextBackends are the stores delete_context is tested on. The
+// remote ones run only against live servers, as in the conformance suite.
+var deleteContextBackends = map[string]BackendFactory{
+	"local": localScratchBackend,
+	"encrypted": func(embed chromem.EmbeddingFunc) (VectorBackend, func(), error) {
+		dir, err := os.MkdirTemp("", "brainmcp-delete-context-")
+		if err != nil {
+			return nil, nil, err
+		}
+		store, err := NewLocalVectorStore(filepath.Join(dir, DefaultDBPath), NewFileCipher("delete context"), embed, nil, 0, nil)
+		if err != nil {
+			os.RemoveAll(dir)
+			return nil, nil, err
+		}
+		return store, func() { os.RemoveAll(dir) }, nil
+	},
+	"qdrant":   qdrantScratchBackend,
+	"pgvector": pgvectorScratchBackend,
+	"redis":    redisScratchBackend,
+}
+
+// useBackend makes ta store its memories in backend.
+func (ta *testApp) useBackend(backend VectorBackend) {
+	ta.vectorStore = NewIndexedVectorStore(backend, ta.keywordIndex, ta.hashIndex)
+	ta.filterEngine = NewSearchFilterEngine(ta.vectorStore, ta.versionMgr, ta.ctx)
+}
+
+// newDeleteContextApp returns a testApp on a backend from factory with d1 and
+// d2 in the default context and, in "work", w1, the trashed w2 and house,
+// stored in three chunks. Another client, "colleague", works in "work".
+func newDeleteContextApp(t *testing.T, factory BackendFactory) *testApp {
+	t.Helper()
+	ta := newTestApp(t, func(cfg *Config) {
+		cfg.SoftDelete = true
+		cfg.ChunkSize = 60
+		cfg.ChunkOverlap = 10
+	})
+	backend, cleanup, err := factory(testEmbedding)
+	if errors.Is(err, backendtest.ErrSkipped) {
+		t.Skip(err)
+	}
+	if err != nil {
+		t.Fatalf("creating backend: %v", err)
+	}
+	t.Cleanup(cleanup)
+	ta.useBackend(backend)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.remember(t, "d1", "default memory one", nil)
+	ta.remember(t, "d2", "default memory two", nil)
+	ta.switchContext(t, "work")
+	ta.remember(t, "w1", "work memory one", nil)
+	ta.remember(t, "w2", "work memory two", nil)
+	ta.remember(t, "house", "The boiler is serviced every autumn.\n\nThe garage door code changed in May.\n\nThe gutters need clearing twice a year.", nil)
+	if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "w2"}); isErr {
+		t.Fatalf("delete_memory: %s", text)
+	}
+	ta.switchContext(t, DefaultContextID)
+	if text, isErr := callAs(t, WithClientID(t.Context(), "colleague"), ta.switchContextHandler, map[string]any{"context_id": "work"}); isErr {
+		t.Fatalf("switch_context as colleague: %s", text)
+	}
+	return ta
+}
+
+// contextOf returns the context metadata of a stored memory, or "" if it is
+// not stored.
+func (ta *testApp) contextOf(t *testing.T, id string) string {
+	t.Helper()
+	doc, err := ta.vectorStore.GetByID(t.Context(), id)
+	if err != nil {
+		return ""
+	}
+	return doc.Metadata["context"]
+}
+
+var workMemories = []string{"w1", "w2", "house", chunkID("house", 1), chunkID("house", 2), chunkID("house", 3)}
+
+func TestDeleteContextStrategies(t *testing.T) {
+	for name, factory := range deleteContextBackends {
+		t.Run(name, func(t *testing.T) {
+			t.Run("abort_if_nonempty", func(t *testing.T) {
+				ta := newDeleteContextApp(t, factory)
+				for _, strategy := range []string{"", DeleteContextAbortIfNonEmpty} {
+					text, isErr := call(t, ta.deleteContextHandler, map[string]any{"context_id": "work", "strategy": strategy})
+					if !isErr || !strings.Contains(text, "Context 'work' still holds 3 memories (1 in the trash).") {
+						t.Errorf("strategy %q = %q, want a refusal", strategy, text)
+					}
+				}
+				if _, err := ta.ctx.GetContext("work"); err != nil {
+					t.Errorf("the context was deleted: %v", err)
+				}
+				for _, id := range workMemories {
+					if got := ta.contextOf(t, id); got != "work" {
+						t.Errorf("%s is in %q after the refusal", id, got)
+					}
+				}
+			})
+
+			t.Run("reassign", func(t *testing.T) {
+				ta := newDeleteContextApp(t, factory)
+				text, isErr := call(t, ta.deleteContextHandler, map[string]any{"context_id": "work", "strategy": DeleteContextReassign})
+				if isErr || text != "Context 'work' deleted; 3 memories moved to '"+DefaultContextID+"'." {
+					t.Fatalf("delete_context reassign = %q", text)
+				}
+				for _, id := range workMemories {
+					if got := ta.contextOf(t, id); got != DefaultContextID {
+						t.Errorf("%s is in %q, want %s", id, got, DefaultContextID)
+					}
+				}
+				if _, err := ta.versionMgr.GetHistory("w1"); err != nil {
+					t.Errorf("w1 lost its history: %v", err)
+				}
+				if _, err := ta.ctx.GetContext("work"); err == nil {
+					t.Error("the context still exists")
+				}
+				if got := memoryCounts(t, ta.ctx, DefaultContextID); got[DefaultContextID] != 4 {
+					t.Errorf("default counts %d memories, want d1, d2, w1 and house", got[DefaultContextID])
+				}
+				if current, _ := ta.ctx.GetClientContext("colleague"); current != DefaultContextID {
+					t.Errorf("the colleague works in %q, want %s", current, DefaultContextID)
+				}
+			})
+
+			t.Run("cascade", func(t *testing.T) {
+				ta := newDeleteContextApp(t, factory)
+				text, isErr := call(t, ta.deleteContextHandler, map[string]any{"context_id": "work", "strategy": DeleteContextCascade})
+				if isErr || text != "Context 'work' deleted with its 3 memories." {
+					t.Fatalf("delete_context cascade = %q", text)
+				}
+				for _, id := range workMemories {
+					if got := ta.contextOf(t, id); got != "" {
+						t.Errorf("%s is still stored in %q", id, got)
+					}
+				}
+				for _, id := range []string{"w1", "w2", "house"} {
+					if _, err := ta.versionMgr.GetHistory(id); err == nil {
+						t.Errorf("%s kept its history", id)
+					}
+				}
+				if ta.vectorStore.Count() != 2 || ta.contextOf(t, "d1") != DefaultContextID {
+					t.Errorf("cascade left %d memories, want only d1 and d2", ta.vectorStore.Count())
+				}
+				if _, err := ta.ctx.GetContext("work"); err == nil {
+					t.Error("the context still exists")
+				}
+			})
+		})
+	}
+}
+
+func TestDeleteContextRefusals(t *testing.T) {
+	ta := newTestApp(t, nil)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "empty", "name": "Empty"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	for _, tc := range []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"context_id": DefaultContextID, "strategy": DeleteContextCascade}, "The default context cannot be deleted"},
+		{map[string]any{"context_id": "empty", "strategy": "purge"}, "strategy must be"},
+		{map[string]any{"context_id": "missing"}, "Context not found"},
+		{map[string]any{"context_id": " "}, "Context ID cannot be empty"},
+	} {
+		if text, isErr := call(t, ta.deleteContextHandler, tc.args); !isErr || !strings.Contains(text, tc.want) {
+			t.Errorf("delete_context %v = %q, want %q", tc.args, text, tc.want)
+		}
+	}
+
+	// An empty context goes with any strategy
+	if text, _ := call(t, ta.deleteContextHandler, map[string]any{"context_id": "empty"}); text != "Context 'empty' deleted." {
+		t.Errorf("deleting an empty context = %q", text)
+	}
+}
+
+func TestCLIContextDelete(t *testing.T) {
+	ta := newDeleteContextApp(t, localScratchBackend)
+	out := ta.runScript(t, "context delete work\ncontext delete work cascade\n")
+	if !strings.Contains(out, "still holds 3 memories") || !strings.Contains(out, "Context 'work' deleted with its 3 memories.") {
+		t.Errorf("CLI output:\n%s", out)
+	}
+	if ta.vectorStore.Count() != 2 {
+		t.Errorf("%d memories left, want 2", ta.vectorStore.Count())
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// EmbeddingCache holds recently computed embeddings keyed by the SHA-256 of
+// their text, so updating a memory without changing its content, or asking
+// the same question twice, does not call the provider again. Once full, the
+// least recently used entry is evicted.
+type EmbeddingCache struct {
+	entries    sync.Map // [sha256.Size]byte -> *list.Element holding a *cacheEntry
+	mu         sync.Mutex
+	lru        *list.List // Most recently used first, guarded by mu
+	maxEntries int
+}
+
+// cacheEntry is an embedding in the LRU list.
+type cacheEntry struct {
+	key       [sha256.Size]byte
+	embedding []float32
+}
+
+// NewEmbeddingCache creates a cache holding up to maxEntries embeddings.
+func NewEmbeddingCache(maxEntries int) *EmbeddingCache {
+	return &EmbeddingCache{lru: list.New(), maxEntries: maxEntries}
+}
+
+// Get returns a copy of the cached embedding of text.
+func (c *EmbeddingCache) Get(text string) ([]float32, bool) {
+	value, ok := c.entries.Load(sha256.Sum256([]byte(text)))
+	if !ok {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The entry may have been evicted or replaced since the lookup
+	elem := value.(*list.Element)
+	if current, ok := c.entries.Load(elem.Value.(*cacheEntry).key); !ok || current != value {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return slices.Clone(elem.Value.(*cacheEntry).embedding), true
+}
+
+// Put caches a copy of the embedding of text, evicting the least recently
+// used entries beyond the limit.
+func (c *EmbeddingCache) Put(text string, embedding []float32) {
+	key := sha256.Sum256([]byte(text))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if value, ok := c.entries.Load(key); ok {
+		elem := value.(*list.Element)
+		elem.Value.(*cacheEntry).embedding = slices.Clone(embedding)
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries.Store(key, c.lru.PushFront(&cacheEntry{key: key, embedding: slices.Clone(embedding)}))
+	for c.lru.Len() > c.maxEntries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		c.entries.Delete(oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached embeddings.
+func (c *EmbeddingCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// cacheEmbedder wraps the embedding functions of a provider with cache.
+// Batches only send the texts that are not cached to the provider. Failed and
+// invalid embeddings are not cached.
+func cacheEmbedder(cache *EmbeddingCache, embFunc chromem.EmbeddingFunc, batchEmbFunc BatchEmbeddingFunc) (chromem.EmbeddingFunc, BatchEmbeddingFunc) {
+	cached := func(ctx context.Context, text string) ([]float32, error) {
+		if emb, ok := cache.Get(text); ok {
+			return emb, nil
+		}
+		emb, err := embFunc(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		cache.Put(text, emb)
+		return emb, nil
+	}
+	cachedBatch := func(ctx context.Context, texts []string) ([][]float32, error) {
+		embeddings := make([][]float32, len(texts))
+		var missing []string
+		var missingIdx []int
+		for i, text := range texts {
+			if emb, ok := cache.Get(text); ok {
+				embeddings[i] = emb
+				continue
+			}
+			missing = append(missing, text)
+			missingIdx = append(missingIdx, i)
+		}
+		if len(missing) == 0 {
+			return embeddings, nil
+		}
+
+		// Batches with invalid embeddings still return the valid ones
+		embedded, err := batchEmbFunc(ctx, missing)
+		var invalid *InvalidEmbeddingsError
+		if err != nil && !errors.As(err, &invalid) {
+			return nil, err
+		}
+		for j, i := range missingIdx {
+			embeddings[i] = embedded[j]
+			if embedded[j] != nil {
+				cache.Put(missing[j], embedded[j])
+			}
+		}
+		if invalid != nil {
+			remapped := make([]*EmbeddingInvalidError, len(invalid.Invalid))
+			for k, e := range invalid.Invalid {
+				moved := *e
+				moved.Index = missingIdx[e.Index]
+				remapped[k] = &moved
+			}
+			return embeddings, &InvalidEmbeddingsError{Embeddings: embeddings, Invalid: remapped}
+		}
+		return embeddings, nil
+	}
+	return cached, cachedBatch
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// sequenceEmbedder returns a different vector on every call, so a vector
+// that comes back twice must have come from a cache. It fails the texts in
+// fail and returns a nil, invalid embedding in batches for those in invalid.
+type sequenceEmbedder struct {
+	fail    map[string]bool
+	invalid map[string]bool
+
+	mu    sync.Mutex
+	calls int
+	texts []string
+}
+
+func (e *sequenceEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.texts = append(e.texts, text)
+	if e.fail[text] {
+		return nil, errors.New("provider unavailable")
+	}
+	e.calls++
+	return []float32{float32(e.calls), 1, 0}, nil
+}
+
+func (e *sequenceEmbedder) BatchEmbed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	var invalid []*EmbeddingInvalidError
+	for i, text := range texts {
+		if e.invalid[text] {
+			e.mu.Lock()
+			e.texts = append(e.texts, text)
+			e.mu.Unlock()
+			invalid = append(invalid, &EmbeddingInvalidError{Provider: "sequence", Index: i, Err: errors.New("zero vector")})
+			continue
+		}
+		vec, err := e.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = vec
+	}
+	if len(invalid) > 0 {
+		return out, &InvalidEmbeddingsError{Embeddings: out, Invalid: invalid}
+	}
+	return out, nil
+}
+
+// Texts returns the texts sent to the provider so far and forgets them.
+func (e *sequenceEmbedder) Texts() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	texts := e.texts
+	e.texts = nil
+	return texts
+}
+
+func TestCacheEmbedderHitsReturnIdenticalVectors(t *testing.T) {
+	provider := &sequenceEmbedder{}
+	embed, batch := cacheEmbedder(NewEmbeddingCache(10), provider.Embed, provider.BatchEmbed)
+
+	first, err := embed(t.Context(), "the office is at 1 Main St")
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	second, _ := embed(t.Context(), "the office is at 1 Main St")
+	if !slices.Equal(first, second) {
+		t.Errorf("cache hit = %v, want the first embedding %v", second, first)
+	}
+	// Callers may modify what they get without changing the cache
+	second[0] = 99
+	if third, _ := embed(t.Context(), "the office is at 1 Main St"); !slices.Equal(third, first) {
+		t.Errorf("cache hit after modifying a copy = %v, want %v", third, first)
+	}
+	if got := provider.Texts(); len(got) != 1 {
+		t.Errorf("three embeds of one text made %d provider calls, want 1", len(got))
+	}
+
+	// The query form of the text is another text
+	query, _ := embed(t.Context(), QueryTaskPrefix+"the office is at 1 Main St")
+	if slices.Equal(query, first) {
+		t.Error("the query embedding was served from the document's cache entry")
+	}
+	provider.Texts()
+
+	// Batches only send the texts that are not cached
+	embeddings, err := batch(t.Context(), []string{"new text", "the office is at 1 Main St", QueryTaskPrefix + "the office is at 1 Main St"})
+	if err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if got := provider.Texts(); !slices.Equal(got, []string{"new text"}) {
+		t.Errorf("provider got %q, want only the new text", got)
+	}
+	if !slices.Equal(embeddings[1], first) || !slices.Equal(embeddings[2], query) {
+		t.Errorf("batch returned %v, want the cached embeddings in place", embeddings)
+	}
+	if single, _ := embed(t.Context(), "new text"); !slices.Equal(single, embeddings[0]) {
+		t.Errorf("embed after batch = %v, want the batch's %v", single, embeddings[0])
+	}
+	if got := provider.Texts(); len(got) != 0 {
+		t.Errorf("cached texts were sent again: %q", got)
+	}
+}
+
+func TestCacheEmbedderSkipsFailures(t *testing.T) {
+	provider := &sequenceEmbedder{fail: map[string]bool{"flaky": true}, invalid: map[string]bool{"blank": true}}
+	cache := NewEmbeddingCache(10)
+	embed, batch := cacheEmbedder(cache, provider.Embed, provider.BatchEmbed)
+
+	if _, err := embed(t.Context(), "flaky"); err == nil {
+		t.Fatal("embed of a failing text succeeded")
+	}
+	if _, err := embed(t.Context(), "flaky"); err == nil || cache.Len() != 0 {
+		t.Errorf("a failed embedding was cached: %v, %d entries", err, cache.Len())
+	}
+
+	embed(t.Context(), "cached")
+	provider.Texts()
+	embeddings, err := batch(t.Context(), []string{"cached", "blank", "fresh"})
+	var invalid *InvalidEmbeddingsError
+	if !errors.As(err, &invalid) || len(invalid.Invalid) != 1 || invalid.Invalid[0].Index != 1 {
+		t.Fatalf("batch err = %v, want the invalid embedding at its index in the whole batch", err)
+	}
+	if embeddings[1] != nil || embeddings[0] == nil || embeddings[2] == nil {
+		t.Errorf("batch = %v, want only the blank text without an embedding", embeddings)
+	}
+	if _, ok := cache.Get("blank"); ok {
+		t.Error("an invalid embedding was cached")
+	}
+	if vec, ok := cache.Get("fresh"); !ok || !slices.Equal(vec, embeddings[2]) {
+		t.Error("the valid embeddings of a batch with an invalid one were not cached")
+	}
+}
+
+func TestEmbeddingCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewEmbeddingCache(2)
+	cache.Put("a", []float32{1})
+	cache.Put("b", []float32{2})
+	cache.Get("a")
+	cache.Put("c", []float32{3})
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b was kept although it was used least recently")
+	}
+	for text, want := range map[string]float32{"a": 1, "c": 3} {
+		if vec, ok := cache.Get(text); !ok || vec[0] != want {
+			t.Errorf("Get(%q) = %v, %v", text, vec, ok)
+		}
+	}
+
+	cache.Put("a", []float32{4})
+	if vec, _ := cache.Get("a"); cache.Len() != 2 || vec[0] != 4 {
+		t.Errorf("replacing a = %v with %d entries", vec, cache.Len())
+	}
+}
+
+// newEmbedder caches embeddings unless cache_max_entries is negative.
+func TestNewEmbedderCaches(t *testing.T) {
+	fake, baseURL := newFakeOllama(t)
+	cfg := DefaultConfig()
+	cfg.EmbeddingProvider = "ollama"
+	cfg.Ollama.BaseURL = baseURL
+
+	for _, tc := range []struct {
+		maxEntries int
+		requests   int
+	}{{DefaultCacheMaxEntries, 1}, {-1, 2}} {
+		cfg.CacheMaxEntries = tc.maxEntries
+		before := len(fake.Prompts())
+		embed, _, err := newEmbedder(cfg, "ollama", nil, "", nil)
+		if err != nil {
+			t.Fatalf("newEmbedder: %v", err)
+		}
+		first, _ := embed(t.Context(), "the office is at 1 Main St")
+		second, _ := embed(t.Context(), "the office is at 1 Main St")
+		if !slices.Equal(first, second) {
+			t.Errorf("cache_max_entries %d: embeddings differ", tc.maxEntries)
+		}
+		if n := len(fake.Prompts()) - before; n != tc.requests {
+			t.Errorf("cache_max_entries %d: two embeds made %d requests, want %d", tc.maxEntries, n, tc.requests)
+		}
+	}
+}
@@ -4,20 +4,174 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"math"
 	"net/http"
 	"strings"
 
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
 	"github.com/philippgille/chromem-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/genai"
 )
 
-// makeGeminiEmbedder creates an embedding function using Gemini's embedding API.
-func makeGeminiEmbedder(modelName string, client *genai.Client, logger interface{}) chromem.EmbeddingFunc {
+// newEmbedder creates the embedding functions for provider ("gemini",
+// "lmstudio" or "ollama") from the configuration. Embedders for the
+// configured embedding provider also enforce the vector store's dimension.
+func newEmbedder(cfg *Config, provider string, client *genai.Client, geminiModel string, logger *log.Logger) (chromem.EmbeddingFunc, BatchEmbeddingFunc, error) {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	retryPolicy := cfg.Gemini.RetryPolicy()
+
+	// The cache wraps the traced functions, so cache hits create no span
+	wrap := func(model string, embFunc chromem.EmbeddingFunc, batchEmbFunc BatchEmbeddingFunc) (chromem.EmbeddingFunc, BatchEmbeddingFunc, error) {
+		embFunc, batchEmbFunc = traceEmbedder(provider, model, embFunc, batchEmbFunc)
+		if cfg.CacheMaxEntries > 0 {
+			embFunc, batchEmbFunc = cacheEmbedder(NewEmbeddingCache(cfg.CacheMaxEntries), embFunc, batchEmbFunc)
+		}
+		return embFunc, batchEmbFunc, nil
+	}
+
+	switch provider {
+	case "lmstudio":
+		logger.Printf("Using LM Studio embedding provider: %s (model: %s)", cfg.LMStudio.BaseURL, cfg.LMStudio.EmbeddingModel)
+		embFunc := makeLMStudioEmbedder(cfg.LMStudio.BaseURL, cfg.LMStudio.EmbeddingModel, retryPolicy, logger)
+		batchEmbFunc := func(ctx context.Context, texts []string) ([][]float32, error) {
+			return batchEmbedLMStudio(ctx, cfg.LMStudio.BaseURL, cfg.LMStudio.EmbeddingModel, texts, retryPolicy)
+		}
+		return wrap(cfg.LMStudio.EmbeddingModel, embFunc, batchEmbFunc)
+	case "ollama":
+		logger.Printf("Using Ollama embedding provider: %s (model: %s)", cfg.Ollama.BaseURL, cfg.Ollama.Model)
+		// Only the remote stores have a fixed vector size; the local store adapts to the model
+		expectedDim := 0
+		if cfg.Qdrant.Host != "" && provider == cfg.EmbeddingProvider {
+			expectedDim = cfg.Qdrant.VectorDimension
+		}
+		if cfg.Pgvector.DSN != "" && provider == cfg.EmbeddingProvider {
+			expectedDim = cfg.Pgvector.VectorDimension
+		}
+		if cfg.Redis.Addr != "" && provider == cfg.EmbeddingProvider {
+			expectedDim = cfg.Redis.VectorDimension
+		}
+		embFunc := makeOllamaEmbedder(cfg.Ollama.BaseURL, cfg.Ollama.Model, expectedDim, retryPolicy, logger)
+		batchEmbFunc := func(ctx context.Context, texts []string) ([][]float32, error) {
+			return batchEmbedOllama(ctx, embFunc, texts)
+		}
+		return wrap(cfg.Ollama.Model, embFunc, batchEmbFunc)
+	case "gemini":
+		if client == nil {
+			return nil, nil, fmt.Errorf("gemini embeddings need GEMINI_API_KEY")
+		}
+		dim := cfg.Gemini.EmbeddingDimension
+		if dim == 0 {
+			dim = EmbeddingDimension
+		}
+		logger.Printf("Using Gemini embedding provider (model: %s, dimension: %d)", geminiModel, dim)
+		embFunc := makeGeminiEmbedder(geminiModel, client, dim, retryPolicy, logger)
+		batchEmbFunc := func(ctx context.Context, texts []string) ([][]float32, error) {
+			return batchEmbedGemini(ctx, client, geminiModel, dim, texts, retryPolicy)
+		}
+		return wrap(geminiModel, embFunc, batchEmbFunc)
+	}
+	return nil, nil, fmt.Errorf("unknown embedding provider %q", provider)
+}
+
+// traceEmbedder wraps the embedding functions of a provider so each request
+// gets its own OpenTelemetry span, a child of the calling tool's span.
+func traceEmbedder(provider, model string, embFunc chromem.EmbeddingFunc, batchEmbFunc BatchEmbeddingFunc) (chromem.EmbeddingFunc, BatchEmbeddingFunc) {
+	start := func(ctx context.Context, name string, texts int) (context.Context, trace.Span) {
+		return otelTracer().Start(ctx, name, trace.WithAttributes(
+			attribute.String("brainmcp.embedding.provider", provider),
+			attribute.String("brainmcp.embedding.model", model),
+			attribute.Int("brainmcp.embedding.texts", texts),
+		))
+	}
+	end := func(span trace.Span, err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+
+	traced := func(ctx context.Context, text string) ([]float32, error) {
+		ctx, span := start(ctx, "brainmcp.embed", 1)
+		emb, err := embFunc(ctx, text)
+		end(span, err)
+		return emb, err
+	}
+	tracedBatch := func(ctx context.Context, texts []string) ([][]float32, error) {
+		ctx, span := start(ctx, "brainmcp.embed_batch", len(texts))
+		embs, err := batchEmbFunc(ctx, texts)
+		end(span, err)
+		return embs, err
+	}
+	return traced, tracedBatch
+}
+
+// EmbeddingInvalidError reports an embedding that cannot be stored because it
+// is a zero vector, contains NaN or Inf, or cannot be normalized. Local models
+// return such vectors e.g. while they are still loading.
+type EmbeddingInvalidError struct {
+	Provider string
+	Model    string
+	Index    int // Position of the text in the request
+	Err      error
+}
+
+func (e *EmbeddingInvalidError) Error() string {
+	return fmt.Sprintf("EMBEDDING_INVALID: %s model %q returned an invalid embedding for item %d: %v", e.Provider, e.Model, e.Index, e.Err)
+}
+
+func (e *EmbeddingInvalidError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidEmbeddingsError is returned by the batch embedders when some of the
+// embeddings are invalid. Embeddings holds the valid results in input order,
+// with nil for each invalid one, so batch operations can store the rest.
+type InvalidEmbeddingsError struct {
+	Embeddings [][]float32
+	Invalid    []*EmbeddingInvalidError
+}
+
+func (e *InvalidEmbeddingsError) Error() string {
+	if len(e.Invalid) == 1 {
+		return e.Invalid[0].Error()
+	}
+	return fmt.Sprintf("%d of %d embeddings are invalid, first: %v", len(e.Invalid), len(e.Embeddings), e.Invalid[0])
+}
+
+func (e *InvalidEmbeddingsError) Unwrap() error {
+	return e.Invalid[0]
+}
+
+// checkEmbeddings validates and normalizes every embedding in place. Invalid
+// ones are replaced by nil and reported in an *InvalidEmbeddingsError.
+func checkEmbeddings(provider, model string, embeddings [][]float32) ([][]float32, error) {
+	var invalid []*EmbeddingInvalidError
+	for i, emb := range embeddings {
+		if err := vecmath.Validate(emb); err != nil {
+			invalid = append(invalid, &EmbeddingInvalidError{Provider: provider, Model: model, Index: i, Err: err})
+			embeddings[i] = nil
+		}
+	}
+	if len(invalid) > 0 {
+		return embeddings, &InvalidEmbeddingsError{Embeddings: embeddings, Invalid: invalid}
+	}
+	return embeddings, nil
+}
+
+// makeGeminiEmbedder creates an embedding function using Gemini's embedding
+// API that returns dim-dimensional embeddings.
+func makeGeminiEmbedder(modelName string, client *genai.Client, dim int, policy RetryPolicy, logger interface{}) chromem.EmbeddingFunc {
 	return func(ctx context.Context, text string) ([]float32, error) {
-		embs, err := batchEmbedGemini(ctx, client, modelName, []string{text})
+		embs, err := batchEmbedGemini(ctx, client, modelName, dim, []string{text}, policy)
 		if err != nil {
 			return nil, err
 		}
@@ -25,44 +179,69 @@ func makeGeminiEmbedder(modelName string, client *genai.Client, logger interface
 	}
 }
 
-func batchEmbedGemini(ctx context.Context, client *genai.Client, modelName string, texts []string) ([][]float32, error) {
+// batchEmbedGemini embeds texts with as few EmbedContent calls as possible.
+// Texts are grouped by task type (documents vs. QUERY_TASK-prefixed queries) since
+// the task type applies to a whole request, and each group is sent in chunks of
+// MaxEmbedBatchSize contents. Each request is retried according to policy.
+// Results have dim dimensions and are returned in input order.
+func batchEmbedGemini(ctx context.Context, client *genai.Client, modelName string, dim int, texts []string, policy RetryPolicy) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, nil
 	}
 
-	// Batching is currently implemented via parallel calls to EmbedContent
-	// as the SDK's EmbedContent takes one set of contents at a time.
-	
-	results := make([][]float32, len(texts))
+	// Split inputs by task type while remembering their original positions
+	groups := map[string][]int{}
+	cleaned := make([]string, len(texts))
 	for i, text := range texts {
 		taskType := TaskTypeDocument
 		if strings.HasPrefix(text, QueryTaskPrefix) {
 			taskType = TaskTypeQuery
 			text = strings.TrimPrefix(text, QueryTaskPrefix)
 		}
+		cleaned[i] = text
+		groups[taskType] = append(groups[taskType], i)
+	}
 
-		contents := []*genai.Content{{Parts: []*genai.Part{{Text: text}}}}
-		dim := int32(EmbeddingDimension)
-		res, err := client.Models.EmbedContent(ctx, modelName, contents, &genai.EmbedContentConfig{
-			TaskType:             taskType,
-			OutputDimensionality: &dim,
-		})
-		if err != nil {
-			return nil, fmt.Errorf("embedding failed at index %d: %w", i, err)
-		}
-		if len(res.Embeddings) == 0 {
-			return nil, fmt.Errorf("no embeddings returned at index %d", i)
+	results := make([][]float32, len(texts))
+	outputDim := int32(dim)
+	for _, taskType := range []string{TaskTypeDocument, TaskTypeQuery} {
+		indices := groups[taskType]
+		for start := 0; start < len(indices); start += MaxEmbedBatchSize {
+			end := min(start+MaxEmbedBatchSize, len(indices))
+			chunk := indices[start:end]
+
+			contents := make([]*genai.Content, len(chunk))
+			for j, idx := range chunk {
+				contents[j] = &genai.Content{Parts: []*genai.Part{{Text: cleaned[idx]}}}
+			}
+
+			var res *genai.EmbedContentResponse
+			err := withRetry(ctx, policy, func() error {
+				var err error
+				res, err = client.Models.EmbedContent(ctx, modelName, contents, &genai.EmbedContentConfig{
+					TaskType:             taskType,
+					OutputDimensionality: &outputDim,
+				})
+				return err
+			})
+			if err != nil {
+				return nil, fmt.Errorf("embedding failed for items %d-%d: %w", start, end-1, err)
+			}
+			if len(res.Embeddings) != len(chunk) {
+				return nil, fmt.Errorf("returned embedding count mismatch: expected %d, got %d", len(chunk), len(res.Embeddings))
+			}
+			for j, idx := range chunk {
+				results[idx] = res.Embeddings[j].Values
+			}
 		}
-		normalize(res.Embeddings[0].Values)
-		results[i] = res.Embeddings[0].Values
 	}
-	return results, nil
+	return checkEmbeddings("gemini", modelName, results)
 }
 
 // makeLMStudioEmbedder creates an embedding function using LM Studio's OpenAI-compatible API.
-func makeLMStudioEmbedder(baseURL, modelName string, logger *log.Logger) chromem.EmbeddingFunc {
+func makeLMStudioEmbedder(baseURL, modelName string, policy RetryPolicy, logger *log.Logger) chromem.EmbeddingFunc {
 	return func(ctx context.Context, text string) ([]float32, error) {
-		embs, err := batchEmbedLMStudio(ctx, baseURL, modelName, []string{text})
+		embs, err := batchEmbedLMStudio(ctx, baseURL, modelName, []string{text}, policy)
 		if err != nil {
 			return nil, err
 		}
@@ -70,11 +249,27 @@ func makeLMStudioEmbedder(baseURL, modelName string, logger *log.Logger) chromem
 	}
 }
 
-func batchEmbedLMStudio(ctx context.Context, baseURL, modelName string, texts []string) ([][]float32, error) {
+// batchEmbedLMStudio embeds texts with a single request to LM Studio's
+// /embeddings endpoint, retried according to policy.
+func batchEmbedLMStudio(ctx context.Context, baseURL, modelName string, texts []string, policy RetryPolicy) ([][]float32, error) {
 	if len(texts) == 0 {
 		return nil, nil
 	}
 
+	var results [][]float32
+	err := withRetry(ctx, policy, func() error {
+		var err error
+		results, err = requestLMStudioEmbeddings(ctx, baseURL, modelName, texts)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return checkEmbeddings("lmstudio", modelName, results)
+}
+
+// requestLMStudioEmbeddings performs a single embeddings request.
+func requestLMStudioEmbeddings(ctx context.Context, baseURL, modelName string, texts []string) ([][]float32, error) {
 	url := strings.TrimSuffix(baseURL, "/") + "/embeddings"
 	requestBody, err := json.Marshal(map[string]interface{}{
 		"model": modelName,
@@ -98,7 +293,12 @@ func batchEmbedLMStudio(ctx context.Context, baseURL, modelName string, texts []
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       strings.TrimSpace(string(body)),
+		}
 	}
 
 	var result struct {
@@ -116,24 +316,102 @@ func batchEmbedLMStudio(ctx context.Context, baseURL, modelName string, texts []
 
 	results := make([][]float32, len(texts))
 	for i, d := range result.Data {
-		normalize(d.Embedding)
 		results[i] = d.Embedding
 	}
 	return results, nil
 }
 
-// normalize performs L2 normalization on a vector of float32 values.
-// This ensures embeddings are on the unit sphere, which improves similarity search accuracy.
-func normalize(v []float32) {
-	var sum float64
-	for _, val := range v {
-		sum += float64(val * val)
+// makeOllamaEmbedder creates an embedding function using Ollama's /api/embeddings endpoint.
+// When expectedDim is non-zero, embeddings of any other size are rejected.
+func makeOllamaEmbedder(baseURL, modelName string, expectedDim int, policy RetryPolicy, logger *log.Logger) chromem.EmbeddingFunc {
+	return func(ctx context.Context, text string) ([]float32, error) {
+		var embedding []float32
+		err := withRetry(ctx, policy, func() error {
+			var err error
+			embedding, err = requestOllamaEmbedding(ctx, baseURL, modelName, text)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		if expectedDim > 0 && len(embedding) != expectedDim {
+			return nil, fmt.Errorf("ollama model %q returned %d-dimensional embeddings but the vector store expects %d; set vector_dimension in the vector store's config to %d or choose a matching model",
+				modelName, len(embedding), expectedDim, len(embedding))
+		}
+		if err := vecmath.Validate(embedding); err != nil {
+			return nil, &EmbeddingInvalidError{Provider: "ollama", Model: modelName, Err: err}
+		}
+		return embedding, nil
+	}
+}
+
+// batchEmbedOllama embeds texts one request at a time, since /api/embeddings
+// takes a single prompt. Invalid embeddings are collected rather than aborting
+// the batch.
+func batchEmbedOllama(ctx context.Context, embed chromem.EmbeddingFunc, texts []string) ([][]float32, error) {
+	results := make([][]float32, len(texts))
+	var invalid []*EmbeddingInvalidError
+	for i, text := range texts {
+		emb, err := embed(ctx, text)
+		var invalidErr *EmbeddingInvalidError
+		if errors.As(err, &invalidErr) {
+			item := *invalidErr
+			item.Index = i
+			invalid = append(invalid, &item)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("embedding failed for item %d: %w", i, err)
+		}
+		results[i] = emb
+	}
+	if len(invalid) > 0 {
+		return results, &InvalidEmbeddingsError{Embeddings: results, Invalid: invalid}
 	}
-	magnitude := float32(math.Sqrt(sum))
-	if magnitude <= 0 {
-		return
+	return results, nil
+}
+
+// requestOllamaEmbedding performs a single embeddings request. The query task
+// prefix is Gemini-specific and is stripped before sending.
+func requestOllamaEmbedding(ctx context.Context, baseURL, modelName, text string) ([]float32, error) {
+	url := strings.TrimSuffix(baseURL, "/") + "/api/embeddings"
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"model":  modelName,
+		"prompt": strings.TrimPrefix(text, QueryTaskPrefix),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, &HTTPStatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       strings.TrimSpace(string(body)),
+		}
+	}
+
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	for i := range v {
-		v[i] /= magnitude
+	if len(result.Embedding) == 0 {
+		return nil, fmt.Errorf("ollama returned an empty embedding for model %q", modelName)
 	}
+	return result.Embedding, nil
 }
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"google.golang.org/genai"
+)
+
+// fakeGemini is a Gemini API server answering batchEmbedContents with
+// testEmbedding vectors. It records every request it receives, and answers
+// the first ones with the error statuses in failures.
+type fakeGemini struct {
+	mu       sync.Mutex
+	requests []fakeGeminiRequest
+	failures []int
+	attempts int // Requests received, including failed ones
+}
+
+// fakeGeminiRequest is one batchEmbedContents call.
+type fakeGeminiRequest struct {
+	texts    []string
+	taskType string
+}
+
+// newFakeGemini starts a fakeGemini and returns a client talking to it.
+func newFakeGemini(t *testing.T) (*fakeGemini, *genai.Client) {
+	t.Helper()
+	fake := &fakeGemini{}
+	srv := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(srv.Close)
+
+	client, err := genai.NewClient(t.Context(), &genai.ClientConfig{
+		APIKey:      "test-key",
+		Backend:     genai.BackendGeminiAPI,
+		HTTPOptions: genai.HTTPOptions{BaseURL: srv.URL},
+	})
+	if err != nil {
+		t.Fatalf("genai.NewClient: %v", err)
+	}
+	return fake, client
+}
+
+func (f *fakeGemini) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.attempts++
+	status := 0
+	if len(f.failures) > 0 {
+		status, f.failures = f.failures[0], f.failures[1:]
+	}
+	f.mu.Unlock()
+	if status != 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		fmt.Fprintf(w, `{"error": {"code": %d, "message": "injected failure", "status": "UNAVAILABLE"}}`, status)
+		return
+	}
+
+	if !strings.HasSuffix(r.URL.Path, ":batchEmbedContents") {
+		http.Error(w, "unexpected path "+r.URL.Path, http.StatusNotFound)
+		return
+	}
+	var body struct {
+		Requests []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			TaskType string `json:"taskType"`
+		} `json:"requests"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := fakeGeminiRequest{}
+	type embedding struct {
+		Values []float32 `json:"values"`
+	}
+	var resp struct {
+		Embeddings []embedding `json:"embeddings"`
+	}
+	for _, item := range body.Requests {
+		text := item.Content.Parts[0].Text
+		req.texts = append(req.texts, text)
+		req.taskType = item.TaskType
+		vec, _ := testEmbedding(r.Context(), text)
+		resp.Embeddings = append(resp.Embeddings, embedding{Values: vec})
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Attempts returns the number of requests received so far, including failed ones.
+func (f *fakeGemini) Attempts() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.attempts
+}
+
+// Requests returns the requests answered so far.
+func (f *fakeGemini) Requests() []fakeGeminiRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.requests)
+}
+
+func TestBatchEmbedGeminiBatchesRequests(t *testing.T) {
+	fake, client := newFakeGemini(t)
+
+	// 247 documents and 3 queries take three document requests and one query request
+	var texts []string
+	for i := range 250 {
+		texts = append(texts, "document "+strings.Repeat("x", i%7)+" "+string(rune('a'+i%26)))
+	}
+	queries := []int{5, 120, 249}
+	for _, i := range queries {
+		texts[i] = QueryTaskPrefix + texts[i]
+	}
+
+	embeddings, err := batchEmbedGemini(t.Context(), client, "text-embedding-004", testDimension, texts, RetryPolicy{})
+	if err != nil {
+		t.Fatalf("batchEmbedGemini: %v", err)
+	}
+
+	requests := fake.Requests()
+	var sizes []int
+	for _, req := range requests {
+		sizes = append(sizes, len(req.texts))
+		if req.taskType == TaskTypeQuery {
+			for _, text := range req.texts {
+				if strings.HasPrefix(text, QueryTaskPrefix) {
+					t.Errorf("query sent with its prefix: %q", text)
+				}
+			}
+		}
+	}
+	if want := []int{100, 100, 47, 3}; !slices.Equal(sizes, want) {
+		t.Fatalf("request sizes = %v, want %v", sizes, want)
+	}
+	if requests[3].taskType != TaskTypeQuery || requests[0].taskType != TaskTypeDocument {
+		t.Errorf("task types = %q, %q; want documents first, then queries", requests[0].taskType, requests[3].taskType)
+	}
+
+	// Results come back in input order
+	if len(embeddings) != len(texts) {
+		t.Fatalf("got %d embeddings for %d texts", len(embeddings), len(texts))
+	}
+	for i, text := range texts {
+		want, _ := testEmbedding(t.Context(), strings.TrimPrefix(text, QueryTaskPrefix))
+		if !slices.Equal(embeddings[i], want) {
+			t.Fatalf("embedding %d does not belong to %q", i, text)
+		}
+	}
+}
+
+func TestGeminiEmbedderBatchesRememberBatch(t *testing.T) {
+	fake, client := newFakeGemini(t)
+	ta := newTestApp(t, nil)
+	batch := func(ctx context.Context, texts []string) ([][]float32, error) {
+		return batchEmbedGemini(ctx, client, "text-embedding-004", testDimension, texts, RetryPolicy{})
+	}
+	backend, err := NewLocalVectorStore(t.TempDir(), nil, makeGeminiEmbedder("text-embedding-004", client, testDimension, RetryPolicy{}, nil), batch, testDimension, nil)
+	if err != nil {
+		t.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	ta.vectorStore = NewIndexedVectorStore(backend, ta.keywordIndex, ta.hashIndex)
+
+	var memories []any
+	for i := range 30 {
+		memories = append(memories, map[string]any{"id": fmt.Sprintf("batch-%d", i), "content": fmt.Sprintf("memory about topic %d", i)})
+	}
+	if text, isErr := call(t, ta.rememberBatchHandler, map[string]any{"memories": memories}); isErr {
+		t.Fatalf("remember_batch: %s", text)
+	}
+	if n := len(fake.Requests()); n != 1 {
+		t.Errorf("remember_batch of 30 memories made %d embedding requests, want 1", n)
+	}
+	if got := backend.Count(); got != 30 {
+		t.Errorf("stored %d memories, want 30", got)
+	}
+}
+
+// fakeOllama is an Ollama server answering /api/embeddings with testEmbedding
+// vectors scaled away from unit length, or padded to dimension when set.
+type fakeOllama struct {
+	mu        sync.Mutex
+	prompts   []string
+	models    []string
+	failures  []int
+	dimension int
+}
+
+// newFakeOllama starts a fakeOllama and returns it with its base URL.
+func newFakeOllama(t *testing.T) (*fakeOllama, string) {
+	t.Helper()
+	fake := &fakeOllama{}
+	srv := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(srv.Close)
+	return fake, srv.URL
+}
+
+func (f *fakeOllama) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/api/embeddings" {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+		return
+	}
+	var body struct {
+		Model  string `json:"model"`
+		Prompt string `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, `{"error": "invalid request"}`, http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	status := 0
+	if len(f.failures) > 0 {
+		status, f.failures = f.failures[0], f.failures[1:]
+	} else {
+		f.prompts = append(f.prompts, body.Prompt)
+		f.models = append(f.models, body.Model)
+	}
+	dimension := f.dimension
+	f.mu.Unlock()
+	if status != 0 {
+		http.Error(w, `{"error": "model is loading"}`, status)
+		return
+	}
+
+	vec, _ := testEmbedding(r.Context(), body.Prompt)
+	for i := range vec {
+		vec[i] *= 3
+	}
+	if dimension > 0 {
+		vec = append(vec, make([]float32, dimension-len(vec))...)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"embedding": vec})
+}
+
+// Prompts returns the prompts embedded so far.
+func (f *fakeOllama) Prompts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return slices.Clone(f.prompts)
+}
+
+func TestOllamaEmbedderNormalizes(t *testing.T) {
+	fake, baseURL := newFakeOllama(t)
+	embed := makeOllamaEmbedder(baseURL+"/", "nomic-embed-text", 0, RetryPolicy{}, nil)
+
+	vec, err := embed(t.Context(), QueryTaskPrefix+"where is the office")
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if !vecmath.IsUnit(vec) {
+		t.Errorf("embedding norm = %.4f, want 1", vecmath.Norm(vec))
+	}
+	want, _ := testEmbedding(t.Context(), "where is the office")
+	if sim := vecmath.Cosine(vec, want); sim < 0.9999 {
+		t.Errorf("normalized embedding changed direction: cosine %.4f", sim)
+	}
+	if got := fake.Prompts(); !slices.Equal(got, []string{"where is the office"}) {
+		t.Errorf("prompts = %q, want the query without its task prefix", got)
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.models[0] != "nomic-embed-text" {
+		t.Errorf("model = %q", fake.models[0])
+	}
+}
+
+func TestOllamaEmbedderDimensionMismatch(t *testing.T) {
+	_, baseURL := newFakeOllama(t)
+	embed := makeOllamaEmbedder(baseURL, "nomic-embed-text", 768, RetryPolicy{}, nil)
+
+	_, err := embed(t.Context(), "text")
+	if err == nil {
+		t.Fatal("a 64-dimensional embedding was accepted for a 768-dimensional store")
+	}
+	for _, want := range []string{"64-dimensional", "expects 768", "vector_dimension"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %q, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestOllamaEmbedderErrors(t *testing.T) {
+	fake, baseURL := newFakeOllama(t)
+	fake.failures = []int{http.StatusServiceUnavailable}
+	if _, err := makeOllamaEmbedder(baseURL, "m", 0, fastRetries, nil)(t.Context(), "text"); err != nil {
+		t.Errorf("embed after a 503: %v", err)
+	}
+
+	fake.mu.Lock()
+	fake.failures = []int{http.StatusNotFound}
+	fake.mu.Unlock()
+	_, err := makeOllamaEmbedder(baseURL, "missing", 0, fastRetries, nil)(t.Context(), "text")
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Errorf("err = %v, want the 404 from the server", err)
+	}
+
+	zero := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"embedding": make([]float32, testDimension)})
+	}))
+	defer zero.Close()
+	var invalid *EmbeddingInvalidError
+	if _, err := makeOllamaEmbedder(zero.URL, "m", 0, RetryPolicy{}, nil)(t.Context(), "text"); !errors.As(err, &invalid) {
+		t.Errorf("err = %v, want EmbeddingInvalidError for a zero vector", err)
+	}
+}
+
+// newEmbedder builds the Ollama embedder from the configuration and enforces
+// the dimension of a remote vector store.
+func TestNewEmbedderOllama(t *testing.T) {
+	fake, baseURL := newFakeOllama(t)
+	cfg := DefaultConfig()
+	cfg.EmbeddingProvider = "ollama"
+	cfg.Ollama.BaseURL = baseURL
+	cfg.CacheMaxEntries = -1
+
+	embed, batch, err := newEmbedder(cfg, "ollama", nil, "", nil)
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	embeddings, err := batch(t.Context(), []string{"one", "two", "three"})
+	if err != nil || len(embeddings) != 3 {
+		t.Fatalf("batch = %d embeddings, %v", len(embeddings), err)
+	}
+	if n := len(fake.Prompts()); n != 3 {
+		t.Errorf("batch of 3 made %d requests, want 3", n)
+	}
+
+	cfg.Qdrant.Host = "localhost"
+	cfg.Qdrant.VectorDimension = 768
+	embed, _, err = newEmbedder(cfg, "ollama", nil, "", nil)
+	if err != nil {
+		t.Fatalf("newEmbedder: %v", err)
+	}
+	if _, err := embed(t.Context(), "text"); err == nil || !strings.Contains(err.Error(), "expects 768") {
+		t.Errorf("err = %v, want a dimension mismatch with Qdrant", err)
+	}
+	fake.mu.Lock()
+	fake.dimension = 768
+	fake.mu.Unlock()
+	if vec, err := embed(t.Context(), "text"); err != nil || len(vec) != 768 {
+		t.Errorf("embed with a matching dimension = %d, %v", len(vec), err)
+	}
+}
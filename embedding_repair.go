@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+)
+
+// embedDocuments embeds the documents that have no embedding yet. Documents
+// whose embedding comes back invalid are left out of the result and returned
+// in failed by ID, so batch operations can store the rest and report them.
+func (a *App) embedDocuments(ctx context.Context, documents []chromem.Document) ([]chromem.Document, map[string]error, error) {
+	var texts []string
+	var indices []int
+	for i, doc := range documents {
+		if len(doc.Embedding) == 0 {
+			texts = append(texts, doc.Content)
+			indices = append(indices, i)
+		}
+	}
+	if len(texts) == 0 {
+		return documents, nil, nil
+	}
+
+	embeddings, err := a.vectorStore.BatchEmbed(ctx, texts)
+	var invalidErr *InvalidEmbeddingsError
+	if err != nil && !errors.As(err, &invalidErr) {
+		return nil, nil, err
+	}
+	if len(embeddings) != len(texts) {
+		return nil, nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddings))
+	}
+
+	invalid := make(map[int]error)
+	if invalidErr != nil {
+		for _, e := range invalidErr.Invalid {
+			invalid[e.Index] = e
+		}
+	}
+
+	embedded := make([]chromem.Document, 0, len(documents))
+	failed := make(map[string]error)
+	next := 0
+	for i, doc := range documents {
+		if next < len(indices) && indices[next] == i {
+			if err, ok := invalid[next]; ok {
+				failed[doc.ID] = err
+				next++
+				continue
+			}
+			doc.Embedding = embeddings[next]
+			next++
+		}
+		embedded = append(embedded, doc)
+	}
+	return embedded, failed, nil
+}
+
+// invalidEmbeddingNotes describes the memories embedDocuments left out, sorted by ID.
+func invalidEmbeddingNotes(failed map[string]error) []string {
+	notes := make([]string, 0, len(failed))
+	for id, err := range failed {
+		notes = append(notes, fmt.Sprintf("%s (%v)", id, err))
+	}
+	sort.Strings(notes)
+	return notes
+}
+
+// ReembedQueue holds the IDs of memories whose stored embedding is invalid and
+// has to be recomputed from their content. A nil *ReembedQueue is valid and
+// discards everything.
+type ReembedQueue struct {
+	mu  sync.Mutex
+	ids map[string]bool
+}
+
+// NewReembedQueue creates an empty queue.
+func NewReembedQueue() *ReembedQueue {
+	return &ReembedQueue{ids: make(map[string]bool)}
+}
+
+// Add queues memory IDs for re-embedding.
+func (q *ReembedQueue) Add(ids ...string) {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, id := range ids {
+		q.ids[id] = true
+	}
+}
+
+// Take removes and returns all queued IDs in sorted order.
+func (q *ReembedQueue) Take() []string {
+	if q == nil {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ids := make([]string, 0, len(q.ids))
+	for id := range q.ids {
+		ids = append(ids, id)
+	}
+	q.ids = make(map[string]bool)
+	sort.Strings(ids)
+	return ids
+}
+
+// invalidStoredEmbeddings returns the IDs of documents whose stored embedding
+// contains NaN or Inf or is not unit length. Documents without an embedding,
+// from backends that do not return stored vectors, are not checked.
+func invalidStoredEmbeddings(docs []chromem.Document) []string {
+	var ids []string
+	for _, doc := range docs {
+		if len(doc.Embedding) == 0 {
+			continue
+		}
+		if vecmath.CheckFinite(doc.Embedding) != nil || !vecmath.IsUnit(doc.Embedding) {
+			ids = append(ids, doc.ID)
+		}
+	}
+	return ids
+}
+
+// reembedQueued recomputes the embedding of every queued memory from its
+// content. Memories that still fail stay queued for the next maintenance
+// sweep; memories that no longer exist are dropped.
+func (a *App) reembedQueued(ctx context.Context) (repaired []string, failed map[string]error) {
+	failed = make(map[string]error)
+	ids := a.reembed.Take()
+	if len(ids) == 0 {
+		return nil, failed
+	}
+
+	var documents []chromem.Document
+	for _, id := range ids {
+		doc, err := a.vectorStore.GetByID(ctx, id)
+		if err != nil {
+			continue
+		}
+		doc.Embedding = nil
+		documents = append(documents, doc)
+	}
+
+	embedded, invalid, err := a.embedDocuments(ctx, documents)
+	if err != nil {
+		for _, doc := range documents {
+			failed[doc.ID] = err
+		}
+	} else {
+		for id, err := range invalid {
+			failed[id] = err
+		}
+		if len(embedded) > 0 {
+			if err := a.vectorStore.AddDocuments(ctx, embedded, 1); err != nil {
+				for _, doc := range embedded {
+					failed[doc.ID] = err
+				}
+			} else {
+				for _, doc := range embedded {
+					repaired = append(repaired, doc.ID)
+				}
+			}
+		}
+	}
+
+	for id, err := range failed {
+		a.logf(ctx, "Warning: Failed to re-embed memory '%s', will retry: %v", id, err)
+		a.reembed.Add(id)
+	}
+	if len(repaired) > 0 {
+		a.logf(ctx, "Re-embedded %d memories with invalid embeddings", len(repaired))
+		a.recordAudit(ctx, AuditEntry{Tool: "reembed", MemoryIDs: repaired, Status: "ok", Details: "replaced invalid embeddings"})
+	}
+	return repaired, failed
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+)
+
+// fakeLoadingModel is an LM Studio /v1/embeddings server whose model is still
+// loading: until warm is set it returns zero vectors for texts containing
+// "loading". Every other vector comes back scaled by 3, not normalized.
+type fakeLoadingModel struct {
+	warm atomic.Bool
+}
+
+func (f *fakeLoadingModel) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Input []string `json:"input"`
+	}
+	if r.URL.Path != "/v1/embeddings" || json.NewDecoder(r.Body).Decode(&body) != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	type item struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	data := make([]item, len(body.Input))
+	for i, text := range body.Input {
+		vec, _ := testEmbedding(r.Context(), text)
+		for j := range vec {
+			if strings.Contains(text, "loading") && !f.warm.Load() {
+				vec[j] = 0
+			} else {
+				vec[j] *= 3
+			}
+		}
+		data[i].Embedding = vec
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"data": data})
+}
+
+// newLoadingModelApp returns a testApp embedding through a fakeLoadingModel.
+func newLoadingModelApp(t *testing.T) (*testApp, *fakeLoadingModel) {
+	t.Helper()
+	fake := &fakeLoadingModel{}
+	srv := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(srv.Close)
+	baseURL := srv.URL + "/v1"
+
+	ta := newTestApp(t, nil)
+	embed := makeLMStudioEmbedder(baseURL, "warming-up", RetryPolicy{}, nil)
+	batch := func(ctx context.Context, texts []string) ([][]float32, error) {
+		return batchEmbedLMStudio(ctx, baseURL, "warming-up", texts, RetryPolicy{})
+	}
+	backend, err := NewLocalVectorStore(t.TempDir(), nil, embed, batch, testDimension, nil)
+	if err != nil {
+		t.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	ta.backend = backend
+	ta.vectorStore = NewIndexedVectorStore(backend, ta.keywordIndex, ta.hashIndex)
+	return ta, fake
+}
+
+func TestCheckEmbeddings(t *testing.T) {
+	nan := float32(math.NaN())
+	inf := float32(math.Inf(1))
+	embeddings := [][]float32{{3, 4}, {0, 0}, {nan, 1}, {1, inf}, {0.6, 0.8}}
+	checked, err := checkEmbeddings("lmstudio", "m", embeddings)
+
+	var invalid *InvalidEmbeddingsError
+	if !errors.As(err, &invalid) || len(invalid.Invalid) != 3 {
+		t.Fatalf("err = %v, want three invalid embeddings", err)
+	}
+	for i, want := range []int{1, 2, 3} {
+		if got := invalid.Invalid[i]; got.Index != want || got.Provider != "lmstudio" || checked[want] != nil {
+			t.Errorf("invalid[%d] = item %d from %s, kept %v", i, got.Index, got.Provider, checked[want])
+		}
+	}
+	if want := `3 of 5 embeddings are invalid, first: EMBEDDING_INVALID: lmstudio model "m" returned an invalid embedding for item 1`; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error does not name the code and provider: %v", err)
+	}
+	// Valid vectors are normalized in place
+	if !vecmath.IsUnit(checked[0]) || checked[0][0] != 0.6 {
+		t.Errorf("denormalized vector came back as %v", checked[0])
+	}
+	if !vecmath.IsUnit(checked[4]) {
+		t.Errorf("unit vector came back as %v", checked[4])
+	}
+
+	if _, err := checkEmbeddings("gemini", "m", [][]float32{{1, 0}}); err != nil {
+		t.Errorf("valid batch: %v", err)
+	}
+}
+
+func TestRememberRejectsInvalidEmbedding(t *testing.T) {
+	ta, _ := newLoadingModelApp(t)
+
+	text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "early", "content": "stored while the model was loading"})
+	if !isErr || !strings.Contains(text, "EMBEDDING_INVALID: lmstudio model \"warming-up\"") {
+		t.Errorf("remember with a zero vector = %q, want EMBEDDING_INVALID naming the provider", text)
+	}
+	if _, err := ta.vectorStore.GetByID(t.Context(), "early"); err == nil {
+		t.Error("a memory with a zero embedding was stored")
+	}
+	if _, err := ta.versionMgr.GetHistory("early"); err == nil {
+		t.Error("a rejected memory got a version history")
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID); got[DefaultContextID] != 0 {
+		t.Errorf("context count = %d after a rejected memory", got[DefaultContextID])
+	}
+
+	// An unnormalized vector is normalized before storage
+	ta.remember(t, "fine", "stored after the model was ready", nil)
+	doc, err := ta.vectorStore.GetByID(t.Context(), "fine")
+	if err != nil || !vecmath.IsUnit(doc.Embedding) {
+		t.Errorf("stored embedding norm = %.3f, %v; want 1", vecmath.Norm(doc.Embedding), err)
+	}
+}
+
+func TestRememberBatchSkipsInvalidEmbeddings(t *testing.T) {
+	ta, _ := newLoadingModelApp(t)
+
+	text, isErr := call(t, ta.rememberBatchHandler, map[string]any{"memories": []any{
+		map[string]any{"id": "a", "content": "first memory"},
+		map[string]any{"id": "b", "content": "second memory while loading"},
+		map[string]any{"id": "c", "content": "third memory"},
+	}})
+	if isErr || !strings.Contains(text, "Skipped 1 with invalid embeddings: b (EMBEDDING_INVALID: lmstudio") {
+		t.Fatalf("remember_batch = %q, want b skipped and reported", text)
+	}
+	for id, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, err := ta.vectorStore.GetByID(t.Context(), id); (err == nil) != want {
+			t.Errorf("%s stored = %v, want %v", id, err == nil, want)
+		}
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID); got[DefaultContextID] != 2 {
+		t.Errorf("context count = %d, want the 2 stored memories", got[DefaultContextID])
+	}
+
+	text, isErr = call(t, ta.rememberBatchHandler, map[string]any{"memories": []any{
+		map[string]any{"id": "d", "content": "loading one"},
+		map[string]any{"id": "e", "content": "loading two"},
+	}})
+	if !isErr || !strings.Contains(text, "every embedding was invalid") {
+		t.Errorf("remember_batch with only invalid embeddings = %q", text)
+	}
+}
+
+func TestImportSkipsInvalidEmbeddings(t *testing.T) {
+	ta, _ := newLoadingModelApp(t)
+	export := &ExportData{Version: ExportFormatVersion, Memories: []MemoryWithHistory{
+		fixtureHistory("ready", fixtureVersion(1, "exported when ready", "desktop", 0)),
+		fixtureHistory("early", fixtureVersion(1, "exported while loading", "desktop", 0)),
+	}}
+
+	text, isErr := ta.importJSON(t, export, nil)
+	if isErr || !strings.Contains(text, "Created: 1, updated: 0, skipped: 0, failed: 1") || !strings.Contains(text, "early (EMBEDDING_INVALID") {
+		t.Fatalf("import_memories = %q, want early failed with EMBEDDING_INVALID", text)
+	}
+	if _, err := ta.vectorStore.GetByID(t.Context(), "early"); err == nil {
+		t.Error("a memory with a zero embedding was imported")
+	}
+	if _, err := ta.vectorStore.GetByID(t.Context(), "ready"); err != nil {
+		t.Errorf("the valid memory was not imported: %v", err)
+	}
+}
+
+func TestVerifyIntegrityReembedsInvalidVectors(t *testing.T) {
+	ta, fake := newLoadingModelApp(t)
+	ta.remember(t, "healthy", "a healthy memory", nil)
+
+	// Written by an older version that did not validate embeddings
+	poisoned := make([]float32, testDimension)
+	poisoned[0] = float32(math.NaN())
+	if err := ta.backend.AddDocument(t.Context(), chromem.Document{ID: "poisoned", Content: "saved while loading", Embedding: poisoned, Metadata: map[string]string{"context": DefaultContextID}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The model still returns zero vectors, so the memory stays queued
+	text, isErr := call(t, ta.verifyIntegrityHandler, nil)
+	if isErr || !strings.Contains(text, "Invalid embeddings: 1 memories (poisoned); re-embedded 0, 1 queued for retry") {
+		t.Fatalf("verify_integrity = %q", text)
+	}
+
+	fake.warm.Store(true)
+	if repaired, failed := ta.reembedQueued(t.Context()); len(repaired) != 1 || len(failed) != 0 {
+		t.Fatalf("reembedQueued = %v, %v; want poisoned repaired", repaired, failed)
+	}
+	doc, err := ta.vectorStore.GetByID(t.Context(), "poisoned")
+	if err != nil || vecmath.CheckFinite(doc.Embedding) != nil || !vecmath.IsUnit(doc.Embedding) {
+		t.Errorf("repaired embedding = %v, %v", doc.Embedding[:4], err)
+	}
+	if text, _ := call(t, ta.verifyIntegrityHandler, nil); strings.Contains(text, "Invalid embeddings") {
+		t.Errorf("verify_integrity after the repair = %q", text)
+	}
+	if ids := ta.reembed.Take(); len(ids) != 0 {
+		t.Errorf("still queued: %v", ids)
+	}
+}
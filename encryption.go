@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/philippgille/chromem-go"
+	"golang.org/x/crypto/argon2"
+)
+
+// encryptedFileMagic starts every file FileCipher encrypts, so encrypted and
+// plain text files can be told apart. It is followed by the key salt, the
+// nonce and the ciphertext.
+var encryptedFileMagic = []byte("BRAINMCP-ENC2\n")
+
+// Argon2id parameters for deriving the key from the passphrase (RFC 9106's
+// recommendation for memory-constrained environments).
+const (
+	keySaltSize   = 16
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024 // KiB
+	argon2Threads = 4
+)
+
+// errEncryptedNoKey is returned when an encrypted file is read without a key.
+var errEncryptedNoKey = errors.New("file is encrypted; set storage.encryption_key or BRAINMCP_ENCRYPTION_KEY")
+
+// FileCipher encrypts the data files with AES-256-GCM. The key is derived
+// from the configured passphrase with Argon2id and a random salt that is
+// stored in each file's header. A cipher writes all its files with one salt
+// chosen when it is created, and derives the key for other salts when it
+// reads files written by another process. A nil *FileCipher is valid and
+// leaves files in plain text.
+type FileCipher struct {
+	passphrase []byte
+	salt       []byte // Salt of the files this cipher writes
+
+	mu   sync.Mutex
+	keys map[string][]byte // Derived keys by salt
+}
+
+// NewFileCipher returns a cipher for passphrase, or nil if it is empty.
+func NewFileCipher(passphrase string) *FileCipher {
+	if passphrase == "" {
+		return nil
+	}
+	salt := make([]byte, keySaltSize)
+	rand.Read(salt)
+	return &FileCipher{
+		passphrase: []byte(passphrase),
+		salt:       salt,
+		keys:       make(map[string][]byte),
+	}
+}
+
+// encryptionPassphrase returns storage.encryption_key, or
+// BRAINMCP_ENCRYPTION_KEY if it is not configured.
+func encryptionPassphrase(cfg *Config) string {
+	if cfg != nil && cfg.Storage.EncryptionKey != "" {
+		return cfg.Storage.EncryptionKey
+	}
+	return os.Getenv("BRAINMCP_ENCRYPTION_KEY")
+}
+
+// key returns the key derived for salt. Derivation is deliberately slow, so
+// keys are cached.
+func (fc *FileCipher) key(salt []byte) []byte {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	if key, ok := fc.keys[string(salt)]; ok {
+		return key
+	}
+	key := argon2.IDKey(fc.passphrase, salt, argon2Time, argon2Memory, argon2Threads, 32)
+	fc.keys[string(salt)] = key
+	return key
+}
+
+// isEncryptedData reports whether data was written by Seal.
+func isEncryptedData(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedFileMagic)
+}
+
+// Seal encrypts data. Without a key data is returned unchanged.
+func (fc *FileCipher) Seal(data []byte) ([]byte, error) {
+	if fc == nil {
+		return data, nil
+	}
+	gcm, err := newGCM(fc.key(fc.salt))
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	out := append([]byte{}, encryptedFileMagic...)
+	out = append(out, fc.salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, nil), nil
+}
+
+// Open decrypts data written by Seal. Plain text data is returned unchanged,
+// so files written before encryption was enabled stay readable until they are
+// next saved.
+func (fc *FileCipher) Open(data []byte) ([]byte, error) {
+	if !isEncryptedData(data) {
+		return data, nil
+	}
+	if fc == nil {
+		return nil, errEncryptedNoKey
+	}
+	data = data[len(encryptedFileMagic):]
+	if len(data) < keySaltSize {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	salt, data := data[:keySaltSize], data[keySaltSize:]
+	gcm, err := newGCM(fc.key(salt))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is truncated")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt (wrong encryption key?): %w", err)
+	}
+	return plain, nil
+}
+
+// ReadFile reads and decrypts the file at path.
+func (fc *FileCipher) ReadFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fc.Open(data)
+}
+
+// WriteFile encrypts data and writes it to path.
+func (fc *FileCipher) WriteFile(path string, data []byte, perm os.FileMode) error {
+	sealed, err := fc.Seal(data)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, sealed, perm)
+}
+
+// AppendFile appends data to the file at path, creating it with perm. An
+// encrypted file is sealed as a whole, so with a key the file is read,
+// extended and written back in its place; a plain text file left from before
+// encryption was enabled is encrypted on the way.
+func (fc *FileCipher) AppendFile(path string, data []byte, perm os.FileMode) error {
+	if fc == nil {
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, perm)
+		if err != nil {
+			return err
+		}
+		_, err = file.Write(data)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		return err
+	}
+	existing, err := fc.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := fc.WriteFile(tmpPath, append(existing, data...), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// exportDB writes db compressed and encrypted to path.
+func (fc *FileCipher) exportDB(db *chromem.DB, path string) error {
+	var buf bytes.Buffer
+	if err := db.ExportToWriter(&buf, true, ""); err != nil {
+		return err
+	}
+	return fc.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// importDB reads a database written by exportDB into db.
+func (fc *FileCipher) importDB(db *chromem.DB, path string) error {
+	plain, err := fc.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return db.ImportFromReader(bytes.NewReader(plain), "")
+}
+
+// newGCM returns the AES-GCM AEAD for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptedDBPath returns the file the local database is kept in when it is
+// encrypted, next to the plain text database directory dbPath.
+func encryptedDBPath(dbPath string) string {
+	return dbPath + ".enc"
+}
+
+// hasPlaintextDB reports whether dbPath holds a plain text chromem database,
+// i.e. a collection directory.
+func hasPlaintextDB(dbPath string) bool {
+	entries, err := os.ReadDir(dbPath)
+	if err != nil {
+		return false
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// encryptedFiles returns the data files that are encrypted along with the
+// local database: the context state, version history, keyword index, context
+// embeddings, activity counters, the audit log at auditPath and its rotated
+// files, the retention archive and the files written for large responses.
+// Exports written to an explicit file_path are left as the client asked.
+func encryptedFiles(dataDir, auditPath string) []string {
+	files := []string{
+		filepath.Join(dataDir, ContextsDataPath),
+		filepath.Join(dataDir, "memory_versions", "memory_versions.json"),
+		filepath.Join(dataDir, "keyword_index.json"),
+		filepath.Join(dataDir, "context_vectors.json"),
+		filepath.Join(dataDir, "activity.json"),
+		auditPath,
+	}
+	for _, pattern := range []string{
+		auditPath + ".[0-9]*",
+		filepath.Join(dataDir, "archive", "*.jsonl"),
+		filepath.Join(dataDir, "exports", responseFilePattern),
+	} {
+		matches, _ := filepath.Glob(pattern)
+		files = append(files, matches...)
+	}
+	return files
+}
+
+// checkEncryptedFiles verifies that the data files in dataDir and the audit
+// log at auditPath can be read with fc, so a missing or wrong key stops the
+// server instead of having it start over with empty files.
+func checkEncryptedFiles(dataDir, auditPath string, fc *FileCipher) error {
+	for _, path := range encryptedFiles(dataDir, auditPath) {
+		if _, err := fc.ReadFile(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("cannot read %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// migrateEncryption encrypts an existing plain text store with fc: the local
+// database in dbPath, the data files in dataDir and the audit log at
+// auditPath. The encrypted database is read back and compared before the
+// plain text one is removed. Files that are already encrypted are left alone.
+func migrateEncryption(dbPath, dataDir, auditPath string, fc *FileCipher, out io.Writer) error {
+	if fc == nil {
+		return fmt.Errorf("no encryption key; set storage.encryption_key or BRAINMCP_ENCRYPTION_KEY")
+	}
+
+	encPath := encryptedDBPath(dbPath)
+	switch _, err := os.Stat(encPath); {
+	case err == nil:
+		fmt.Fprintf(out, "%s is already encrypted\n", encPath)
+	case hasPlaintextDB(dbPath):
+		count, err := encryptDB(dbPath, encPath, fc)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Encrypted %d memories into %s\n", count, encPath)
+	default:
+		fmt.Fprintf(out, "No local database in %s\n", dbPath)
+	}
+
+	for _, path := range encryptedFiles(dataDir, auditPath) {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if isEncryptedData(data) {
+			fmt.Fprintf(out, "%s is already encrypted\n", path)
+			continue
+		}
+		tmpPath := path + ".tmp"
+		if err := fc.WriteFile(tmpPath, data, 0600); err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", path, err)
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", path, err)
+		}
+		fmt.Fprintf(out, "Encrypted %s\n", path)
+	}
+	return nil
+}
+
+// encryptDB exports the plain text database in dbPath to encPath, checks the
+// export by importing it, and removes the plain text collections. It returns
+// the number of memories encrypted.
+func encryptDB(dbPath, encPath string, fc *FileCipher) (int, error) {
+	plain, err := chromem.NewPersistentDB(dbPath, true)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", dbPath, err)
+	}
+	tmpPath := encPath + ".tmp"
+	if err := fc.exportDB(plain, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to export %s: %w", dbPath, err)
+	}
+
+	check := chromem.NewDB()
+	if err := fc.importDB(check, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to read back the encrypted database: %w", err)
+	}
+	count := 0
+	for name, c := range plain.ListCollections() {
+		exported := check.GetCollection(name, nil)
+		if exported == nil || exported.Count() != c.Count() {
+			os.Remove(tmpPath)
+			return 0, fmt.Errorf("encrypted collection %q does not match the original", name)
+		}
+		count += c.Count()
+	}
+	if err := os.Rename(tmpPath, encPath); err != nil {
+		return 0, fmt.Errorf("failed to finalize %s: %w", encPath, err)
+	}
+
+	// Only the collections go; the mutation stamp stays in the directory
+	entries, err := os.ReadDir(dbPath)
+	if err != nil {
+		return count, fmt.Errorf("failed to remove the plain text database: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			if err := os.RemoveAll(filepath.Join(dbPath, entry.Name())); err != nil {
+				return count, fmt.Errorf("failed to remove the plain text database: %w", err)
+			}
+		}
+	}
+	return count, nil
+}
+
+// runMigrateEncryption implements the migrate_encryption subcommand.
+func runMigrateEncryption(cfg *Config, dataDir string, out io.Writer) int {
+	localDir, err := localDataDir()
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	fc := NewFileCipher(encryptionPassphrase(cfg))
+	if err := migrateEncryption(filepath.Join(localDir, DefaultDBPath), dataDir, auditLogPath(cfg, dataDir), fc, out); err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,331 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestFileCipherRoundTrip(t *testing.T) {
+	fc := NewFileCipher("correct horse battery staple")
+	plain := []byte(`{"memories": ["the launch date is March 3"]}`)
+
+	sealed, err := fc.Seal(plain)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !isEncryptedData(sealed) || bytes.Contains(sealed, []byte("launch")) {
+		t.Fatalf("sealed data is not encrypted: %q", sealed)
+	}
+	if salt := sealed[len(encryptedFileMagic):][:keySaltSize]; !bytes.Equal(salt, fc.salt) {
+		t.Errorf("header salt = %x, want the cipher's salt %x", salt, fc.salt)
+	}
+
+	opened, err := fc.Open(sealed)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !bytes.Equal(opened, plain) {
+		t.Errorf("Open = %q, want %q", opened, plain)
+	}
+}
+
+func TestFileCipherWithoutKey(t *testing.T) {
+	var fc *FileCipher
+	if NewFileCipher("") != nil {
+		t.Fatal("NewFileCipher with an empty passphrase should return nil")
+	}
+	plain := []byte("plain text")
+
+	sealed, err := fc.Seal(plain)
+	if err != nil || !bytes.Equal(sealed, plain) {
+		t.Fatalf("Seal without a key = %q, %v; want the data unchanged", sealed, err)
+	}
+	opened, err := fc.Open(plain)
+	if err != nil || !bytes.Equal(opened, plain) {
+		t.Fatalf("Open of plain data without a key = %q, %v; want the data unchanged", opened, err)
+	}
+
+	encrypted, err := NewFileCipher("secret").Seal(plain)
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if _, err := fc.Open(encrypted); !errors.Is(err, errEncryptedNoKey) {
+		t.Errorf("Open of encrypted data without a key: err = %v, want errEncryptedNoKey", err)
+	}
+}
+
+func TestFileCipherWrongKey(t *testing.T) {
+	sealed, err := NewFileCipher("right").Seal([]byte("data"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if _, err := NewFileCipher("wrong").Open(sealed); err == nil {
+		t.Error("Open with the wrong passphrase succeeded")
+	}
+	if _, err := NewFileCipher("right").Open(sealed[:len(encryptedFileMagic)+4]); err == nil {
+		t.Error("Open of truncated data succeeded")
+	}
+}
+
+// Two processes with the same passphrase use different salts, and each reads
+// what the other wrote.
+func TestFileCipherSaltPerCipher(t *testing.T) {
+	a, b := NewFileCipher("shared"), NewFileCipher("shared")
+	if bytes.Equal(a.salt, b.salt) {
+		t.Fatal("two ciphers got the same salt")
+	}
+	if bytes.Equal(a.key(a.salt), b.key(b.salt)) {
+		t.Fatal("different salts derived the same key")
+	}
+
+	sealed, err := a.Seal([]byte("written by a"))
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	opened, err := b.Open(sealed)
+	if err != nil || string(opened) != "written by a" {
+		t.Fatalf("Open by b = %q, %v", opened, err)
+	}
+}
+
+// openTestStore opens a local store in dir with the given passphrase.
+func openTestStore(t *testing.T, dir, passphrase string) (*LocalVectorStore, error) {
+	t.Helper()
+	return NewLocalVectorStore(filepath.Join(dir, DefaultDBPath), NewFileCipher(passphrase), testEmbedding, nil, testDimension, log.New(io.Discard, "", 0))
+}
+
+// storeTestDocs adds n documents to lvs.
+func storeTestDocs(t *testing.T, lvs *LocalVectorStore, n int) {
+	t.Helper()
+	for i := range n {
+		content := "memory number " + string(rune('a'+i))
+		embedding, _ := testEmbedding(context.Background(), content)
+		doc := chromem.Document{ID: "mem-" + string(rune('a'+i)), Content: content, Embedding: embedding, Metadata: map[string]string{"context": DefaultContextID}}
+		if err := lvs.AddDocument(context.Background(), doc); err != nil {
+			t.Fatalf("AddDocument: %v", err)
+		}
+	}
+}
+
+func TestLocalVectorStoreRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		passphrase string
+	}{
+		{"without key", ""},
+		{"with key", "store passphrase"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			lvs, err := openTestStore(t, dir, tc.passphrase)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			storeTestDocs(t, lvs, 3)
+			if err := lvs.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			reopened, err := openTestStore(t, dir, tc.passphrase)
+			if err != nil {
+				t.Fatalf("reopen: %v", err)
+			}
+			defer reopened.Close()
+			if got := reopened.Count(); got != 3 {
+				t.Fatalf("Count after reopening = %d, want 3", got)
+			}
+			doc, err := reopened.GetByID(context.Background(), "mem-b")
+			if err != nil || doc.Content != "memory number b" {
+				t.Errorf("GetByID after reopening = %q, %v", doc.Content, err)
+			}
+		})
+	}
+}
+
+func TestEncryptedStoreRejectsWrongOrMissingKey(t *testing.T) {
+	dir := t.TempDir()
+	lvs, err := openTestStore(t, dir, "right")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	storeTestDocs(t, lvs, 1)
+	if err := lvs.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(encryptedDBPath(filepath.Join(dir, DefaultDBPath)))
+	if err != nil {
+		t.Fatalf("reading the encrypted database: %v", err)
+	}
+	if !bytes.HasPrefix(data, encryptedFileMagic) || bytes.Contains(data, []byte("memory number")) {
+		t.Fatal("the database file is not in the encrypted format")
+	}
+
+	if _, err := openTestStore(t, dir, "wrong"); err == nil {
+		t.Error("opening with the wrong key succeeded")
+	}
+	if _, err := openTestStore(t, dir, ""); err == nil {
+		t.Error("opening without a key succeeded")
+	}
+}
+
+// Mutations only schedule an export; SaveToDisk writes it at once.
+func TestEncryptedExportIsDebounced(t *testing.T) {
+	dir := t.TempDir()
+	lvs, err := openTestStore(t, dir, "debounce")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer lvs.Close()
+	encPath := encryptedDBPath(filepath.Join(dir, DefaultDBPath))
+
+	storeTestDocs(t, lvs, 5)
+	if _, err := os.Stat(encPath); !os.IsNotExist(err) {
+		t.Fatalf("the database was exported right after the mutations (stat err = %v)", err)
+	}
+	if err := lvs.SaveToDisk(); err != nil {
+		t.Fatalf("SaveToDisk: %v", err)
+	}
+	if _, err := os.Stat(encPath); err != nil {
+		t.Fatalf("SaveToDisk did not export the database: %v", err)
+	}
+	lvs.mu.Lock()
+	pending := lvs.saveTimer != nil
+	lvs.mu.Unlock()
+	if pending {
+		t.Error("SaveToDisk left an export scheduled")
+	}
+}
+
+// writeSideFiles writes every data file kept next to the database through the
+// components that own them, using fc, and returns the audit log's path.
+func writeSideFiles(t *testing.T, ta *testApp, fc *FileCipher) string {
+	t.Helper()
+	dir := ta.dataDir
+	secret := chromem.Document{ID: "secret", Content: "the vault combination is 4711", Metadata: map[string]string{"context": DefaultContextID}}
+
+	ki := NewKeywordIndex(filepath.Join(dir, "keyword_index.json"), fc, nil)
+	ki.Add([]chromem.Document{secret}, 1)
+	if err := ki.Flush(); err != nil {
+		t.Fatalf("keyword index: %v", err)
+	}
+	cv := NewContextVectors(filepath.Join(dir, "context_vectors.json"), fc, nil)
+	if err := cv.Refresh(t.Context(), []*Context{{ID: "vault", Name: "vault combination"}}, ta.vectorStore.BatchEmbed); err != nil {
+		t.Fatalf("context vectors: %v", err)
+	}
+	activity := NewActivityLog(filepath.Join(dir, "activity.json"), fc, time.UTC, nil)
+	activity.Record("vault", ActivityCounts{Created: 1})
+	if err := activity.Flush(); err != nil {
+		t.Fatalf("activity log: %v", err)
+	}
+	auditPath := filepath.Join(dir, "audit.log")
+	audit, err := NewAuditLogger(auditPath, fc)
+	if err != nil {
+		t.Fatalf("audit log: %v", err)
+	}
+	for _, details := range []string{"first vault combination", "second vault combination"} {
+		if err := audit.Record(AuditEntry{Tool: "remember", Status: "ok", Details: details}); err != nil {
+			t.Fatalf("audit log: %v", err)
+		}
+	}
+	audit.Close()
+
+	ta.cipher = fc
+	if err := ta.archiveMemories("vault", []chromem.Document{secret}, time.Now()); err != nil {
+		t.Fatalf("archive: %v", err)
+	}
+	if _, err := ta.writeExportFile("export", "json", []byte(secret.Content), time.Now()); err != nil {
+		t.Fatalf("response file: %v", err)
+	}
+	return auditPath
+}
+
+func TestSideFilesAreEncrypted(t *testing.T) {
+	ta := newTestApp(t, nil)
+	fc := NewFileCipher("side files")
+	auditPath := writeSideFiles(t, ta, fc)
+
+	files := encryptedFiles(ta.dataDir, auditPath)
+	written := 0
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		written++
+		if !isEncryptedData(data) || bytes.Contains(data, []byte("vault")) || bytes.Contains(data, []byte("4711")) {
+			t.Errorf("%s is not encrypted", path)
+		}
+		if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+			t.Errorf("%s has mode %v, want 0600", path, info.Mode().Perm())
+		}
+	}
+	if written != 6 {
+		t.Errorf("%d side files written, want 6: %v", written, files)
+	}
+
+	// The files read back with the key, and the audit log kept both entries
+	if data, err := fc.ReadFile(auditPath); err != nil || bytes.Count(data, []byte("vault combination")) != 2 {
+		t.Errorf("audit log = %q, %v", data, err)
+	}
+	if hits := NewKeywordIndex(filepath.Join(ta.dataDir, "keyword_index.json"), fc, nil).Search("4711", 1); len(hits) != 1 {
+		t.Errorf("reloaded keyword index finds %v", hits)
+	}
+	if err := checkEncryptedFiles(ta.dataDir, auditPath, fc); err != nil {
+		t.Errorf("checkEncryptedFiles with the key: %v", err)
+	}
+	for _, wrong := range []*FileCipher{nil, NewFileCipher("wrong")} {
+		if err := checkEncryptedFiles(ta.dataDir, auditPath, wrong); err == nil {
+			t.Errorf("checkEncryptedFiles accepted key %v", wrong)
+		}
+	}
+}
+
+func TestMigrateEncryptionEncryptsSideFiles(t *testing.T) {
+	ta := newTestApp(t, nil)
+	auditPath := writeSideFiles(t, ta, nil)
+	explicit := filepath.Join(ta.dataDir, "exports", "notes.md")
+	if err := os.WriteFile(explicit, []byte("vault notes"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	plain := make(map[string][]byte)
+	for _, path := range encryptedFiles(ta.dataDir, auditPath) {
+		if data, err := os.ReadFile(path); err == nil {
+			plain[path] = data
+		}
+	}
+
+	fc := NewFileCipher("migrate")
+	var out bytes.Buffer
+	if err := migrateEncryption(filepath.Join(t.TempDir(), DefaultDBPath), ta.dataDir, auditPath, fc, &out); err != nil {
+		t.Fatalf("migrateEncryption: %v\n%s", err, out.String())
+	}
+	if len(plain) != 6 {
+		t.Errorf("%d plain text side files, want 6", len(plain))
+	}
+	for path, want := range plain {
+		data, err := os.ReadFile(path)
+		if err != nil || !isEncryptedData(data) {
+			t.Errorf("%s was not encrypted (%v)", path, err)
+			continue
+		}
+		if got, err := fc.ReadFile(path); err != nil || !bytes.Equal(got, want) {
+			t.Errorf("%s decrypts to %q, %v; want %q", path, got, err, want)
+		}
+	}
+	if data, _ := os.ReadFile(explicit); string(data) != "vault notes" {
+		t.Errorf("explicit export = %q, want it left in plain text", data)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// exportFormat is one historical export format version. upgrade turns an
+// export decoded from this version into the next one in exportFormats; the
+// current version has no upgrade.
+//
+// When a field is renamed or its meaning changes, bump ExportFormatVersion,
+// add the old version here and give it an upgrade that moves the old value
+// into the new field. Fields that are only added need an upgrade that fills
+// in their default. Every version keeps a fixture export in testdata/exports.
+type exportFormat struct {
+	version string
+	upgrade func(*ExportData)
+}
+
+// exportFormats lists every export format version, oldest first.
+var exportFormats = []exportFormat{
+	// Exports written before the version was recorded. Memories may lack
+	// version numbers and a current version.
+	{version: "", upgrade: upgradeUnversionedExport},
+	// 1.0 has no embedding provider.
+	{version: "1.0", upgrade: upgradeExport10},
+	{version: ExportFormatVersion},
+}
+
+// supportedExportVersions lists the versions decodeExport accepts, for error messages.
+func supportedExportVersions() string {
+	var versions []string
+	for _, f := range exportFormats {
+		if f.version != "" {
+			versions = append(versions, f.version)
+		}
+	}
+	return strings.Join(versions, ", ") + " and unversioned exports"
+}
+
+// decodeExport decodes an export of any supported format version and upgrades
+// it to the current one. It returns the version the export was written in;
+// "" for unversioned exports. Exports from a newer brainmcp are rejected
+// rather than imported with their unknown fields dropped.
+func decodeExport(data []byte) (*ExportData, string, error) {
+	var header struct {
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, "", fmt.Errorf("invalid JSON: %v", err)
+	}
+	source := strings.TrimSpace(header.Version)
+
+	start := -1
+	for i, f := range exportFormats {
+		if f.version == source {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		if exportVersionNewer(source, ExportFormatVersion) {
+			return nil, source, fmt.Errorf("export format version %s is newer than this server supports (%s); upgrade brainmcp to import it", source, ExportFormatVersion)
+		}
+		return nil, source, fmt.Errorf("unknown export format version %q (supported: %s)", source, supportedExportVersions())
+	}
+
+	var export ExportData
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, source, fmt.Errorf("invalid export format %s: %v", exportVersionName(source), err)
+	}
+	for _, f := range exportFormats[start:] {
+		if f.upgrade != nil {
+			f.upgrade(&export)
+		}
+	}
+	export.Version = ExportFormatVersion
+	return &export, source, nil
+}
+
+// exportVersionName names a format version for messages.
+func exportVersionName(version string) string {
+	if version == "" {
+		return "unversioned"
+	}
+	return version
+}
+
+// exportVersionNewer reports whether version a is newer than b. Both are
+// "major.minor"; a version that does not parse is never newer.
+func exportVersionNewer(a, b string) bool {
+	parse := func(v string) (major, minor int, ok bool) {
+		majorStr, minorStr, _ := strings.Cut(v, ".")
+		major, err := strconv.Atoi(majorStr)
+		if err != nil {
+			return 0, 0, false
+		}
+		if minorStr != "" {
+			if minor, err = strconv.Atoi(minorStr); err != nil {
+				return 0, 0, false
+			}
+		}
+		return major, minor, true
+	}
+	aMajor, aMinor, okA := parse(a)
+	bMajor, bMinor, okB := parse(b)
+	if !okA || !okB {
+		return false
+	}
+	return aMajor > bMajor || (aMajor == bMajor && aMinor > bMinor)
+}
+
+// upgradeUnversionedExport numbers the versions of exports from before 1.0,
+// which left out version numbers and the current version.
+func upgradeUnversionedExport(export *ExportData) {
+	for i := range export.Memories {
+		m := &export.Memories[i]
+		for j := range m.Versions {
+			if m.Versions[j].VersionNumber == 0 {
+				m.Versions[j].VersionNumber = j + 1
+			}
+		}
+		if m.CurrentVersion < 1 || m.CurrentVersion > len(m.Versions) {
+			m.CurrentVersion = len(m.Versions)
+		}
+	}
+}
+
+// upgradeExport10 upgrades a 1.0 export to 1.1, which added the embedding
+// provider. It is unknown for 1.0 exports and left empty.
+func upgradeExport10(export *ExportData) {
+	export.EmbeddingProvider = ""
+}
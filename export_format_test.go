@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// exportFixture returns the fixture export written in format version, kept
+// in testdata/exports for every version in exportFormats. Each holds the same
+// memories: "recipe" with two versions in context "kitchen" tagged "baking",
+// and "errand" with one version and no context.
+func exportFixture(t *testing.T, version string) []byte {
+	t.Helper()
+	name := "unversioned.json"
+	if version != "" {
+		name = "v" + version + ".json"
+	}
+	data, err := os.ReadFile(filepath.Join("testdata", "exports", name))
+	if err != nil {
+		t.Fatalf("no fixture for export format %s: %v", exportVersionName(version), err)
+	}
+	return data
+}
+
+// Importing the fixture of every format version gives the same memories.
+func TestImportExportFixtureOfEveryVersion(t *testing.T) {
+	for _, format := range exportFormats {
+		t.Run(exportVersionName(format.version), func(t *testing.T) {
+			data := exportFixture(t, format.version)
+			export, source, err := decodeExport(data)
+			if err != nil || source != format.version {
+				t.Fatalf("decodeExport = %q, %v", source, err)
+			}
+			if export.Version != ExportFormatVersion {
+				t.Errorf("upgraded version = %q", export.Version)
+			}
+			recipe := export.Memories[0]
+			if recipe.CurrentVersion != 2 || recipe.Versions[0].VersionNumber != 1 || recipe.Versions[1].VersionNumber != 2 {
+				t.Errorf("recipe numbered current %d, versions %d and %d", recipe.CurrentVersion, recipe.Versions[0].VersionNumber, recipe.Versions[1].VersionNumber)
+			}
+			// The embedding provider is recorded since 1.1
+			wantProvider := ""
+			if format.version == ExportFormatVersion {
+				wantProvider = "gemini"
+			}
+			if export.EmbeddingProvider != wantProvider {
+				t.Errorf("embedding provider = %q, want %q", export.EmbeddingProvider, wantProvider)
+			}
+
+			ta := newTestApp(t, nil)
+			text, isErr := call(t, ta.importMemoriesHandler, map[string]any{"json_data": string(data)})
+			wantSource := "Source format: " + exportVersionName(format.version)
+			if format.version != ExportFormatVersion {
+				wantSource += " (upgraded to " + ExportFormatVersion + ")"
+			}
+			if isErr || !strings.Contains(text, wantSource+"\nCreated: 2, updated: 0, skipped: 0, failed: 0") {
+				t.Fatalf("import_memories = %q, want %q", text, wantSource)
+			}
+
+			history := ta.history(t, "recipe")
+			if got := contents(*history); !slices.Equal(got, []string{"Bake for 20 minutes", "Bake for 25 minutes"}) || history.Versions[1].ChangeNote != "Oven runs cold" {
+				t.Errorf("recipe history = %q, note %q", got, history.Versions[1].ChangeNote)
+			}
+			doc, err := ta.vectorStore.GetByID(t.Context(), "recipe")
+			if err != nil || doc.Content != "Bake for 25 minutes" || doc.Metadata["context"] != "kitchen" || !slices.Equal(ParseTags(doc.Metadata["tags"]), []string{"baking"}) {
+				t.Errorf("stored recipe = %q, %v, %v", doc.Content, doc.Metadata, err)
+			}
+			if doc.Metadata["created_at"] != "2025-05-01T10:00:00Z" {
+				t.Errorf("recipe created_at = %q, want the exported creation time", doc.Metadata["created_at"])
+			}
+			// A memory without a context lands in the default one
+			if doc, err := ta.vectorStore.GetByID(t.Context(), "errand"); err != nil || doc.Metadata["context"] != DefaultContextID {
+				t.Errorf("stored errand = %v, %v", doc.Metadata, err)
+			}
+
+			if c, err := ta.ctx.GetContext("kitchen"); err != nil || c.Name != "Kitchen" || c.Description != "Cooking notes" {
+				t.Errorf("context kitchen = %+v, %v", c, err)
+			}
+			if tag, err := ta.ctx.GetTag("baking"); err != nil || tag.Color != "#d2691e" {
+				t.Errorf("tag baking = %+v, %v", tag, err)
+			}
+		})
+	}
+}
+
+func TestDecodeExportRejects(t *testing.T) {
+	for _, tc := range []struct {
+		name, data, want string
+	}{
+		{"newer", `{"version": "2.0", "memories": []}`, "newer than this server supports"},
+		{"newer minor", `{"version": "1.2", "memories": []}`, "newer than this server supports"},
+		{"unknown", `{"version": "0.9", "memories": []}`, "unknown export format version"},
+		{"not JSON", `memories: []`, "invalid JSON"},
+		{"wrong shape", `{"version": "1.0", "memories": {"id": "x"}}`, "invalid export format 1.0"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, _, err := decodeExport([]byte(tc.data)); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("err = %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestImportRejectsNewerFormat(t *testing.T) {
+	ta := newTestApp(t, nil)
+	text, isErr := call(t, ta.importMemoriesHandler, map[string]any{"json_data": `{"version": "9.0", "memories": [{"id": "future", "versions": [{"content": "x"}]}]}`})
+	if !isErr || !strings.Contains(text, "upgrade brainmcp") {
+		t.Errorf("import_memories(9.0) = %q", text)
+	}
+	if _, err := ta.vectorStore.GetByID(t.Context(), "future"); err == nil {
+		t.Error("a rejected export was imported")
+	}
+}
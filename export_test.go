@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestExportFilePathConfinedToExports(t *testing.T) {
+	app := newTestApp(t, nil)
+	app.remember(t, "m1", "the export test memory", nil)
+	exportDir := filepath.Join(app.dataDir, "exports")
+
+	outside := filepath.Join(t.TempDir(), "victim")
+	if err := os.WriteFile(outside, []byte("keep"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(exportDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Dir(outside), filepath.Join(exportDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		outside,
+		"../escape.json",
+		"sub/../../escape.json",
+		"link/victim",
+		exportDir,
+	} {
+		if text, isErr := call(t, app.exportMemoriesHandler, map[string]any{"file_path": path}); !isErr {
+			t.Errorf("file_path %q was accepted: %s", path, text)
+		}
+	}
+	if data, _ := os.ReadFile(outside); string(data) != "keep" {
+		t.Errorf("file outside the exports folder was overwritten: %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(app.dataDir, "escape.json")); !os.IsNotExist(err) {
+		t.Errorf("escape.json was written outside the exports folder")
+	}
+
+	for _, path := range []string{"ok.json", "nested/dir/ok.json", filepath.Join(exportDir, "abs.json")} {
+		text, isErr := call(t, app.exportMemoriesHandler, map[string]any{"file_path": path})
+		if isErr {
+			t.Errorf("file_path %q was refused: %s", path, text)
+			continue
+		}
+		if !strings.Contains(text, "Exported 1 memories") {
+			t.Errorf("file_path %q: unexpected result %q", path, text)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(exportDir, "nested", "dir", "ok.json")); err != nil {
+		t.Errorf("nested export not written: %v", err)
+	}
+}
+
+func TestImportFilePathConfinedToExports(t *testing.T) {
+	app := newTestApp(t, nil)
+	app.remember(t, "m1", "the import test memory", nil)
+	exportDir := filepath.Join(app.dataDir, "exports")
+	if text, isErr := call(t, app.exportMemoriesHandler, map[string]any{"file_path": "backup.json"}); isErr {
+		t.Fatalf("export_memories: %s", text)
+	}
+	data, err := os.ReadFile(filepath.Join(exportDir, "backup.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(t.TempDir(), "backup.json")
+	if err := os.WriteFile(outside, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{outside, "../state.json", "sub/../../state.json", exportDir} {
+		if text, isErr := call(t, app.importMemoriesHandler, map[string]any{"file_path": path, "preview": true}); !isErr || !strings.Contains(text, "invalid file_path") {
+			t.Errorf("file_path %q = %q, want it refused", path, text)
+		}
+	}
+	for _, path := range []string{"backup.json", filepath.Join(exportDir, "backup.json")} {
+		if text, isErr := call(t, app.importMemoriesHandler, map[string]any{"file_path": path, "preview": true}); isErr {
+			t.Errorf("file_path %q was refused: %s", path, text)
+		}
+	}
+}
+
+// oversizedFile parses the result of a response written to a file and checks
+// the file against the reported size and checksum. It returns the file's content.
+func oversizedFile(t *testing.T, dataDir, text string, wantCount int) []byte {
+	t.Helper()
+	fields := map[string]string{}
+	for _, line := range strings.Split(text, "\n") {
+		if key, value, ok := strings.Cut(line, ": "); ok {
+			fields[key] = value
+		}
+	}
+	path := fields["Path"]
+	if !strings.HasPrefix(text, "Response too large to return inline") || path == "" {
+		t.Fatalf("not written to a file:\n%.300s", text)
+	}
+	if filepath.Dir(path) != filepath.Join(dataDir, "exports") {
+		t.Errorf("written to %s, outside the exports folder", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	if got := hex.EncodeToString(sum[:]); fields["SHA-256"] != got {
+		t.Errorf("reported SHA-256 %s, file has %s", fields["SHA-256"], got)
+	}
+	if fields["Size"] != fmt.Sprintf("%d bytes", len(data)) {
+		t.Errorf("reported size %s, file has %d bytes", fields["Size"], len(data))
+	}
+	if fields["Memories"] != strconv.Itoa(wantCount) {
+		t.Errorf("reported %s memories, want %d", fields["Memories"], wantCount)
+	}
+	if info, err := os.Stat(path); err == nil && info.Mode().Perm() != 0600 {
+		t.Errorf("file mode %v, want 0600", info.Mode().Perm())
+	}
+	return data
+}
+
+func TestOversizedExportIsWrittenToFile(t *testing.T) {
+	ta := newTestApp(t, func(cfg *Config) { cfg.MaxInlineResponseBytes = 2048 })
+	const memories = 40
+	for i := range memories {
+		ta.remember(t, fmt.Sprintf("m%02d", i), fmt.Sprintf("memory %d %s", i, strings.Repeat("padding ", 20)), nil)
+	}
+
+	text, isErr := call(t, ta.exportMemoriesHandler, map[string]any{"include_versions": true})
+	if isErr {
+		t.Fatalf("export_memories: %s", text)
+	}
+	data := oversizedFile(t, ta.dataDir, text, memories)
+	var export ExportData
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatalf("the export file is not valid JSON: %v", err)
+	}
+	if len(export.Memories) != memories {
+		t.Errorf("export file holds %d memories, want %d", len(export.Memories), memories)
+	}
+
+	text, isErr = call(t, ta.listHandler, nil)
+	if isErr {
+		t.Fatalf("list_memories: %s", text)
+	}
+	if listed := oversizedFile(t, ta.dataDir, text, memories); !strings.HasPrefix(string(listed), fmt.Sprintf("Brain contains %d memories:", memories)) {
+		t.Errorf("list file starts with %.60q", listed)
+	}
+
+	// A small export still comes back inline
+	text, _ = call(t, ta.exportMemoriesHandler, map[string]any{"memory_ids": []any{"m01"}})
+	if err := json.Unmarshal([]byte(text), &export); err != nil || len(export.Memories) != 1 {
+		t.Errorf("small export was not returned inline: %.200s", text)
+	}
+}
+
+func TestNegativeInlineLimitDisablesFiles(t *testing.T) {
+	ta := newTestApp(t, func(cfg *Config) { cfg.MaxInlineResponseBytes = -1 })
+	for i := range 20 {
+		ta.remember(t, fmt.Sprintf("m%02d", i), strings.Repeat("long memory content ", 50), nil)
+	}
+	text, _ := call(t, ta.exportMemoriesHandler, nil)
+	if strings.HasPrefix(text, "Response too large") {
+		t.Error("export written to a file although the limit is disabled")
+	}
+	if _, err := os.Stat(filepath.Join(ta.dataDir, "exports")); !os.IsNotExist(err) {
+		t.Error("exports folder created although the limit is disabled")
+	}
+}
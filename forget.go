@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// topicMatch is a memory forget_topic found about a topic.
+type topicMatch struct {
+	ID         string
+	Context    string
+	Content    string
+	Similarity float32
+}
+
+// topicMatches returns every memory whose similarity to query is at least
+// threshold, most similar first. A memory stored in chunks matches with its
+// best chunk. Memories in the trash or in contexts the client may not use are
+// not considered.
+func (a *App) topicMatches(ctx context.Context, query string, threshold float32) ([]topicMatch, error) {
+	count := a.vectorStore.Count()
+	if count == 0 {
+		return nil, nil
+	}
+	results, err := a.vectorStore.Query(ctx, QueryTaskPrefix+query, count, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	best := make(map[string]topicMatch)
+	for _, res := range visibleResults(a.accessibleResults(ctx, results), a.clock()) {
+		if res.Similarity < threshold {
+			continue
+		}
+		id := res.ID
+		if isChunk(res.Metadata) {
+			id = res.Metadata["parent_id"]
+		}
+		if m, ok := best[id]; ok && m.Similarity >= res.Similarity {
+			continue
+		}
+		best[id] = topicMatch{ID: id, Context: res.Metadata["context"], Content: res.Content, Similarity: res.Similarity}
+	}
+
+	matches := make([]topicMatch, 0, len(best))
+	for _, m := range best {
+		matches = append(matches, m)
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches, nil
+}
+
+// forgetMemories deletes the matched memories like batch_operations' delete,
+// saves the context state and writes an audit entry.
+func (a *App) forgetMemories(ctx context.Context, query string, matches []topicMatch) *BatchOperationResult {
+	ids := make([]string, len(matches))
+	for i, m := range matches {
+		ids[i] = m.ID
+	}
+	result := a.batchDelete(ctx, ids)
+	result.OperationType = "forget_topic"
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+
+	var deleted []string
+	for _, item := range result.Items {
+		if item.Status == "ok" {
+			deleted = append(deleted, item.ID)
+		}
+	}
+	entry := AuditEntry{Tool: "forget_topic", MemoryIDs: deleted, ClientID: a.clientIDFrom(ctx), Status: "ok",
+		Details: fmt.Sprintf("query %q: %d of %d deleted", query, result.Successful, result.Total)}
+	if result.Failed > 0 {
+		entry.Status = "error"
+	}
+	a.recordAudit(ctx, entry)
+	return result
+}
+
+// formatTopicMatches lists matches with their similarity, context and a snippet.
+func formatTopicMatches(matches []topicMatch) string {
+	var sb strings.Builder
+	for _, m := range matches {
+		snippet := m.Content
+		if len(snippet) > MaxSnippetLength {
+			snippet = snippet[:MaxSnippetLength-3] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("- [%s] (Sim: %.2f, context: %s) %s\n", m.ID, m.Similarity, m.Context, snippet))
+	}
+	return sb.String()
+}
+
+// parseForgetThreshold reads forget_topic's threshold argument.
+func parseForgetThreshold(args map[string]any) (float32, error) {
+	threshold, ok := args["threshold"].(float64)
+	if !ok {
+		return DefaultForgetThreshold, nil
+	}
+	if threshold <= 0 || threshold > 1 {
+		return 0, fmt.Errorf("threshold must be greater than 0 and at most 1")
+	}
+	return float32(threshold), nil
+}
+
+// forgetTopicHandler handles the forget_topic tool - deletes every memory at
+// least threshold similar to a query. It only lists the matches unless
+// dry_run is false.
+func (a *App) forgetTopicHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	query, _ := args["query"].(string)
+	if query = strings.TrimSpace(query); query == "" {
+		return mcp.NewToolResultError("Query cannot be empty"), nil
+	}
+	threshold, err := parseForgetThreshold(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	dryRun := true
+	if v, ok := args["dry_run"].(bool); ok {
+		dryRun = v
+	}
+
+	matches, err := a.topicMatches(ctx, query, threshold)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+	if len(matches) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories are at least %.2f similar to '%s'.", threshold, query)), nil
+	}
+	if dryRun {
+		return mcp.NewToolResultText(fmt.Sprintf("Would delete %d memories at least %.2f similar to '%s' (run again with dry_run=false to delete them):\n%s",
+			len(matches), threshold, query, formatTopicMatches(matches))), nil
+	}
+
+	result := a.forgetMemories(ctx, query, matches)
+	return mcp.NewToolResultText(formatForgetResult(matches, result, a.settings().SoftDelete)), nil
+}
+
+// formatForgetResult reports the deleted memories with their similarity and
+// the ones that could not be deleted. With soft delete they were moved to the
+// trash.
+func formatForgetResult(matches []topicMatch, result *BatchOperationResult, trashed bool) string {
+	failed := make(map[string]string)
+	for _, item := range result.Items {
+		if item.Status != "ok" {
+			failed[item.ID] = item.Error
+		}
+	}
+	var deleted []topicMatch
+	for _, m := range matches {
+		if _, ok := failed[m.ID]; !ok {
+			deleted = append(deleted, m)
+		}
+	}
+
+	var sb strings.Builder
+	verb := "Deleted"
+	if trashed {
+		verb = "Moved to trash"
+	}
+	sb.WriteString(fmt.Sprintf("%s %d memories:\n%s", verb, len(deleted), formatTopicMatches(deleted)))
+	if len(failed) > 0 {
+		sb.WriteString(fmt.Sprintf("Failed to delete %d:\n", len(failed)))
+		for _, m := range matches {
+			if reason, ok := failed[m.ID]; ok {
+				sb.WriteString(fmt.Sprintf("- [%s] %s\n", m.ID, reason))
+			}
+		}
+	}
+	return sb.String()
+}
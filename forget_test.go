@@ -0,0 +1,138 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var forgetMatchID = regexp.MustCompile(`(?m)^- \[([^\]]+)\] \(Sim: `)
+
+// forgetIDs returns the IDs forget_topic listed, in order.
+func forgetIDs(text string) []string {
+	var ids []string
+	for _, m := range forgetMatchID.FindAllStringSubmatch(text, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+// newForgetApp returns a testApp on a fake backend where, for the query
+// "project falcon", above is 0.80 similar, at exactly the default threshold of
+// 0.75, below 0.7499 and far 0.20. at is in the work context, the others in
+// the default context.
+func newForgetApp(t *testing.T, configure func(cfg *Config)) *testApp {
+	t.Helper()
+	ta := newSyntheticApp(t, configure, syntheticEmbedder{
+		"project falcon":                  angled(1, 1),
+		"falcon launch slipped a week":    angled(0.8, 1),
+		"falcon budget was approved":      angled(0.75, 2),
+		"the falcon logo is blue":         angled(0.7499, 3),
+		"the office plants need watering": angled(0.2, 4),
+	})
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.remember(t, "above", "falcon launch slipped a week", nil)
+	ta.remember(t, "below", "the falcon logo is blue", nil)
+	ta.remember(t, "far", "the office plants need watering", nil)
+	ta.switchContext(t, "work")
+	ta.remember(t, "at", "falcon budget was approved", nil)
+	ta.switchContext(t, DefaultContextID)
+	return ta
+}
+
+func TestForgetTopicThresholdBoundary(t *testing.T) {
+	ta := newForgetApp(t, nil)
+	for _, tc := range []struct {
+		threshold any
+		want      []string
+	}{
+		{nil, []string{"above", "at"}},
+		{0.75, []string{"above", "at"}},
+		{0.7499, []string{"above", "at", "below"}},
+		{0.8, []string{"above"}},
+		{0.81, nil},
+		{1.0, nil},
+	} {
+		args := map[string]any{"query": "project falcon"}
+		if tc.threshold != nil {
+			args["threshold"] = tc.threshold
+		}
+		text, isErr := call(t, ta.forgetTopicHandler, args)
+		if isErr {
+			t.Fatalf("forget_topic threshold %v: %s", tc.threshold, text)
+		}
+		if got := forgetIDs(text); !slices.Equal(got, tc.want) {
+			t.Errorf("threshold %v lists %v, want %v:\n%s", tc.threshold, got, tc.want, text)
+		}
+		if tc.want == nil && !strings.HasPrefix(text, "No memories are at least") {
+			t.Errorf("threshold %v = %q", tc.threshold, text)
+		}
+	}
+
+	text, _ := call(t, ta.forgetTopicHandler, map[string]any{"query": "project falcon"})
+	for _, want := range []string{"Would delete 2 memories at least 0.75 similar", "- [above] (Sim: 0.80, context: " + DefaultContextID + ") falcon launch", "- [at] (Sim: 0.75, context: work)"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("dry run does not contain %q:\n%s", want, text)
+		}
+	}
+	if n := ta.vectorStore.Count(); n != 4 {
+		t.Errorf("a dry run left %d memories, want all 4", n)
+	}
+
+	for _, args := range []map[string]any{
+		{"query": "project falcon", "threshold": 0.0},
+		{"query": "project falcon", "threshold": 1.5},
+		{"query": "  "},
+	} {
+		if text, isErr := call(t, ta.forgetTopicHandler, args); !isErr {
+			t.Errorf("forget_topic %v = %q, want an error", args, text)
+		}
+	}
+}
+
+func TestForgetTopicDeletes(t *testing.T) {
+	ta := newForgetApp(t, nil)
+	text, isErr := call(t, ta.forgetTopicHandler, map[string]any{"query": "project falcon", "dry_run": false})
+	if isErr || !strings.HasPrefix(text, "Deleted 2 memories:\n") || !slices.Equal(forgetIDs(text), []string{"above", "at"}) {
+		t.Fatalf("forget_topic = %q", text)
+	}
+	for id, kept := range map[string]bool{"above": false, "at": false, "below": true, "far": true} {
+		if got := ta.storedContent(t, id) != ""; got != kept {
+			t.Errorf("%s stored = %v, want %v", id, got, kept)
+		}
+		if _, err := ta.versionMgr.GetHistory(id); (err == nil) != kept {
+			t.Errorf("%s has history = %v, want %v", id, err == nil, kept)
+		}
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID, "work"); got[DefaultContextID] != 2 || got["work"] != 0 {
+		t.Errorf("counts after forget_topic = %v, want default 2, work 0", got)
+	}
+}
+
+// Memories forget_topic moved to the trash are not matched again.
+func TestForgetTopicSoftDelete(t *testing.T) {
+	ta := newForgetApp(t, func(cfg *Config) { cfg.SoftDelete = true })
+	text, _ := call(t, ta.forgetTopicHandler, map[string]any{"query": "project falcon", "dry_run": false})
+	if !strings.HasPrefix(text, "Moved to trash 2 memories:") {
+		t.Fatalf("forget_topic with soft delete = %q", text)
+	}
+	text, _ = call(t, ta.forgetTopicHandler, map[string]any{"query": "project falcon"})
+	if !strings.HasPrefix(text, "No memories are at least 0.75 similar") {
+		t.Errorf("trashed memories matched again:\n%s", text)
+	}
+}
+
+func TestCLIForgetAsksFirst(t *testing.T) {
+	ta := newForgetApp(t, nil)
+	out := ta.runScript(t, "forget project falcon\nn\n")
+	if !strings.Contains(out, "Delete these 2 memories? [y/N] Nothing deleted.") || ta.vectorStore.Count() != 4 {
+		t.Errorf("declining deleted memories:\n%s", out)
+	}
+	out = ta.runScript(t, "forget project falcon\nyes\n")
+	if !strings.Contains(out, "Deleted 2 memories:") || ta.vectorStore.Count() != 2 {
+		t.Errorf("confirming did not delete:\n%s", out)
+	}
+}
@@ -2,12 +2,15 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
-	"google.golang.org/genai"
 	"github.com/philippgille/chromem-go"
 )
 
@@ -21,48 +24,272 @@ func (a *App) askBrainHandler(ctx context.Context, request mcp.CallToolRequest)
 		return mcp.NewToolResultError("Question cannot be empty"), nil
 	}
 
+	var schema *AnswerSchema
+	if raw, ok := args["answer_schema"]; ok && raw != nil && raw != "" {
+		var err error
+		if schema, err = ParseAnswerSchema(raw); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	opts, err := a.answerOptions(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
 	count := a.vectorStore.Count()
 	if count == 0 {
 		return mcp.NewToolResultText(NoMemoriesMsg), nil
 	}
 
-	nResults := DefaultSearchResults
-	if count < nResults {
-		nResults = count
+	nResults := a.resultLimit(args, count)
+
+	// Follow-up questions often leave out what they refer to, so the previous
+	// question of the conversation is searched together with the new one
+	conversationID, _ := args["conversation_id"].(string)
+	conversationID = strings.TrimSpace(conversationID)
+	var history []Exchange
+	if conversationID != "" {
+		history = a.conversations.History(conversationID)
+	}
+	retrieval := question
+	if len(history) > 0 {
+		retrieval = history[len(history)-1].Question + "\n" + question
+	}
+
+	// Scope the answer to a context and/or tags. The context is filtered in the
+	// query; tags are a comma-separated list the where filter cannot match, so
+	// with tags every memory in scope is ranked and the tagged ones kept
+	var where map[string]string
+	contextID, _ := args["context_id"].(string)
+	if contextID = strings.TrimSpace(contextID); contextID != "" {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+		where = map[string]string{"context": contextID}
+	}
+	var tags []string
+	if raw, ok := args["tags"].([]any); ok {
+		for _, v := range raw {
+			if tag, ok := v.(string); ok && strings.TrimSpace(tag) != "" {
+				tags = append(tags, strings.TrimSpace(tag))
+			}
+		}
+	}
+
+	// With reranking a larger candidate set is retrieved and the LLM keeps the best nResults
+	rerank := a.settings().Rerank
+	if v, ok := args["rerank"].(bool); ok {
+		rerank = v
+	}
+	candidates := nResults
+	if rerank {
+		candidates = max(nResults, min(a.settings().RerankCandidates, count))
+	}
+	depth := candidates
+	if len(tags) > 0 || (where == nil && a.hidesContexts(ctx)) {
+		depth = count
 	}
 
 	// Use the prefix to trigger RETRIEVAL_QUERY for better accuracy
-	results, err := a.vectorStore.Query(ctx, QueryTaskPrefix+question, nResults, nil, nil)
+	results, err := a.vectorStore.Query(ctx, QueryTaskPrefix+retrieval, depth, where, nil)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Memory retrieval failed: %v", err)), nil
 	}
+	a.activity.Record(a.activityContext(ctx, contextID), ActivityCounts{Asks: 1})
+	results = boostByImportance(searchableResults(visibleResults(a.accessibleResults(ctx, results), a.clock())))
+	if len(tags) == 0 {
+		results = results[:min(len(results), candidates)]
+	}
+	if len(results) == 0 && contextID != "" {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories found in context '%s'.", contextID)), nil
+	}
+	if len(tags) > 0 {
+		results = filterByTags(results, tags)
+		if len(results) == 0 {
+			return mcp.NewToolResultText(noTaggedMemoriesMsg(tags, contextID)), nil
+		}
+		results = results[:min(len(results), candidates)]
+	}
+	if len(results) > nResults {
+		if results, err = a.rerank(ctx, question, results, nResults); err != nil {
+			a.logf(ctx, "Warning: Reranking failed, using retrieval order: %v", err)
+		}
+	}
+
+	expand := a.settings().ExpandRelations
+	if v, ok := args["expand_relations"].(bool); ok {
+		expand = v
+	}
+	var via map[string]string
+	if expand {
+		if results, via, err = a.expandRelations(ctx, results, nResults); err != nil {
+			a.logf(ctx, "Warning: Relation expansion failed: %v", err)
+		}
+	}
+	a.recordAccess(ctx, accessedIDs(results)...)
 
 	var contextBuilder strings.Builder
 	for _, res := range results {
+		if note := via[res.ID]; note != "" {
+			contextBuilder.WriteString(fmt.Sprintf("- Memory [%s] (%s): %s\n", res.ID, note, res.Content))
+			continue
+		}
 		contextBuilder.WriteString(fmt.Sprintf("- Memory [%s]: %s\n", res.ID, res.Content))
 	}
 
-	prompt := fmt.Sprintf(`You are a personal memory assistant. Based ONLY on the retrieved memories provided below, answer the user's question. 
-If the answer is not contained within the memories, politely state that you don't recall that information.
+	citeSources := a.settings().CiteSources
+	if v, ok := args["include_sources"].(bool); ok {
+		citeSources = v
+	}
+	citation := ""
+	if citeSources {
+		citation = "\nCite every memory you use inline as [memory-id], using the IDs shown in brackets below."
+	}
 
-Retrieved Memories:
-%s
+	prompt, err := a.askPrompt(askPromptData{Memories: contextBuilder.String(), Question: question, Citation: citation, History: formatHistory(history)})
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
-User Question: %s`, contextBuilder.String(), question)
+	if schema != nil {
+		result, err := a.askStructured(ctx, prompt, schema, opts, results, via, citeSources)
+		if conversationID != "" && result != nil && !result.IsError {
+			if answer, err := json.Marshal(result.StructuredContent); err == nil {
+				a.conversations.Append(conversationID, Exchange{Question: question, Answer: string(answer)}, a.settings().MaxConversationTurns, time.Now())
+			}
+		}
+		return result, err
+	}
 
-	resp, err := a.client.Models.GenerateContent(ctx, a.llmModel, genai.Text(prompt), nil)
+	answer, err := a.generateAnswer(ctx, prompt, opts, a.answerStream(ctx, request))
+	if errors.Is(err, errNoAnswer) {
+		return mcp.NewToolResultText("Unable to generate an answer (check safety filters)."), nil
+	}
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("LLM synthesis failed: %v", err)), nil
 	}
 
-	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
-		return mcp.NewToolResultText("Unable to generate an answer (check safety filters)."), nil
+	if conversationID != "" {
+		a.conversations.Append(conversationID, Exchange{Question: question, Answer: answer}, a.settings().MaxConversationTurns, time.Now())
+	}
+	if citeSources {
+		answer += formatSources(answer, results, via)
 	}
-
-	answer := resp.Candidates[0].Content.Parts[0].Text
 	return mcp.NewToolResultText(answer), nil
 }
 
+// filterByTags keeps the results tagged with any of tags, ignoring case.
+func filterByTags(results []chromem.Result, tags []string) []chromem.Result {
+	tagged := results[:0]
+	for _, res := range results {
+		if matchesTags(ParseTags(res.Metadata["tags"]), tags, false) {
+			tagged = append(tagged, res)
+		}
+	}
+	return tagged
+}
+
+// noTaggedMemoriesMsg reports that no memory carries any of tags, naming the
+// context the search was restricted to.
+func noTaggedMemoriesMsg(tags []string, contextID string) string {
+	if contextID != "" {
+		return fmt.Sprintf("No memories in context '%s' are tagged %s.", contextID, strings.Join(tags, ", "))
+	}
+	return fmt.Sprintf("No memories are tagged %s.", strings.Join(tags, ", "))
+}
+
+// answerOptions returns the sampling settings of an ask_brain answer: the
+// configured ones, overridden by the temperature and max_tokens arguments.
+func (a *App) answerOptions(args map[string]any) (*GenerateOptions, error) {
+	settings := a.settings()
+	temperature, topP := settings.LLMTemperature, settings.LLMTopP
+	opts := &GenerateOptions{Temperature: &temperature, TopP: &topP, MaxOutputTokens: settings.LLMMaxOutputTokens}
+	if v, ok := args["temperature"].(float64); ok {
+		if v < 0 || v > 2 {
+			return nil, fmt.Errorf("temperature must be between 0 and 2, got %g", v)
+		}
+		opts.Temperature = &v
+	}
+	if v, ok := args["max_tokens"].(float64); ok {
+		if v < 1 || v != float64(int(v)) {
+			return nil, fmt.Errorf("max_tokens must be a positive integer, got %g", v)
+		}
+		opts.MaxOutputTokens = int(v)
+	}
+	return opts, nil
+}
+
+// askStructured asks the LLM for a JSON answer conforming to schema, using
+// the provider's structured output mode and the sampling settings of base.
+// An answer that fails validation is retried once with the violations
+// appended to the prompt.
+func (a *App) askStructured(ctx context.Context, prompt string, schema *AnswerSchema, base *GenerateOptions, results []chromem.Result, via map[string]string, citeSources bool) (*mcp.CallToolResult, error) {
+	prompt += fmt.Sprintf("\n\nRespond ONLY with a JSON object conforming to this JSON schema:\n%s", schema)
+	opts := &GenerateOptions{Schema: schema}
+	if base != nil {
+		opts.Temperature, opts.TopP, opts.MaxOutputTokens = base.Temperature, base.TopP, base.MaxOutputTokens
+	}
+
+	raw, err := a.generate(ctx, prompt, opts)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("LLM synthesis failed: %v", err)), nil
+	}
+
+	value, violations := schema.ValidateAnswer(raw)
+	if len(violations) > 0 {
+		a.logf(ctx, "Warning: Answer violates answer_schema, retrying: %s", strings.Join(violations, "; "))
+		retryPrompt := fmt.Sprintf("%s\n\nYour previous response did not conform to the schema:\n- %s\n\nPrevious response:\n%s\n\nReturn only the corrected JSON.",
+			prompt, strings.Join(violations, "\n- "), raw)
+		raw, err = a.generate(ctx, retryPrompt, opts)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("LLM synthesis failed: %v", err)), nil
+		}
+		value, violations = schema.ValidateAnswer(raw)
+	}
+	if len(violations) > 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("Schema violation: answer does not conform to answer_schema after retry:\n- %s\n\nRaw model output:\n%s",
+			strings.Join(violations, "\n- "), raw)), nil
+	}
+
+	rendered, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to render answer: %v", err)), nil
+	}
+	text := string(rendered)
+	if citeSources {
+		text += formatSources(raw, results, via)
+	}
+	return mcp.NewToolResultStructured(value, text), nil
+}
+
+// generate runs a single completion with the configured LLM provider.
+func (a *App) generate(ctx context.Context, prompt string, opts *GenerateOptions) (string, error) {
+	if a.llm == nil {
+		return "", errors.New("no LLM configured (set GEMINI_API_KEY or llm_provider)")
+	}
+	return a.llm.Generate(ctx, prompt, opts)
+}
+
+// formatSources builds the sources footer for an answer: every memory that was
+// fed to the prompt with its similarity, marking the ones the answer cites as [id].
+// Memories brought in by relation expansion carry their note from via.
+func formatSources(answer string, results []chromem.Result, via map[string]string) string {
+	var sb strings.Builder
+	sb.WriteString("\n\nSources:\n")
+	for _, res := range results {
+		cited := ""
+		if note := via[res.ID]; note != "" {
+			cited = ", " + note
+		}
+		if strings.Contains(answer, "["+res.ID+"]") {
+			cited += ", cited"
+		}
+		sb.WriteString(fmt.Sprintf("- [%s] (Sim: %.2f%s)\n", res.ID, res.Similarity, cited))
+	}
+	return sb.String()
+}
+
 // rememberHandler handles the remember tool - stores or updates memories with semantic embeddings.
 func (a *App) rememberHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 	args, ok := request.Params.Arguments.(map[string]any)
@@ -72,7 +299,6 @@ func (a *App) rememberHandler(ctx context.Context, request mcp.CallToolRequest)
 
 	id, _ := args["id"].(string)
 	content, _ := args["content"].(string)
-	meta, _ := args["metadata"].(string)
 
 	if id = strings.TrimSpace(id); id == "" {
 		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
@@ -80,40 +306,210 @@ func (a *App) rememberHandler(ctx context.Context, request mcp.CallToolRequest)
 	if content = strings.TrimSpace(content); content == "" {
 		return mcp.NewToolResultError("Memory content cannot be empty"), nil
 	}
+	// Personal data is refused or redacted before anything is hashed or embedded
+	var redacted []string
+	if settings := a.settings(); settings.PIIDetection {
+		if settings.PIIReject {
+			if kinds := detectPII(content); len(kinds) > 0 {
+				return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' not stored: the content contains personal data (%s)", id, strings.Join(kinds, ", "))), nil
+			}
+		} else {
+			content, redacted = redactPII(content)
+		}
+	}
+	extra, err := parseMetadataArg(args["metadata"])
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	strategy, err := parseDuplicateStrategy(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	dedupe, err := parseDedupeMode(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	relations, err := parseRelations(id, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	ttl, _ := args["ttl"].(string)
+	expiresAtArg, _ := args["expires_at"].(string)
+	expiresAt, err := parseExpiry(ttl, expiresAtArg, a.clock())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	tags := batchTags(args["tags"])
+	contextArg, _ := args["context"].(string)
+	autoContext := a.settings().AutoContext
+	switch contextArg = strings.TrimSpace(contextArg); {
+	case contextArg == "":
+	case strings.EqualFold(contextArg, "auto"):
+		autoContext = true
+	default:
+		return mcp.NewToolResultError(fmt.Sprintf("context must be \"auto\" or left out, got %q; use switch_context to choose a context", contextArg)), nil
+	}
+
+	// Exact duplicates are caught by content hash regardless of ID
+	duplicate := a.exactDuplicate(ctx, id, content)
+	switch {
+	case duplicate == "":
+	case strategy == DuplicateSkip:
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' not stored: identical content already exists as '%s'.", id, duplicate)), nil
+	case strategy == DuplicateLink:
+		if err := a.linkDuplicate(ctx, duplicate, id, tags); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to link duplicate: %v", err)), nil
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' not stored: linked to identical memory '%s'.", id, duplicate)), nil
+	}
 
 	// Get client's current context
-	currentContext, err := a.ctx.GetClientContext(a.clientID)
+	currentContext, err := a.ctx.GetClientContext(a.clientIDFrom(ctx))
 	if err != nil {
+		a.logf(ctx, "Warning: %v, using default context", err)
 		currentContext = DefaultContextID
 	}
+	// The LLM may pick a better fitting context; the current one is the fallback
+	autoNote := ""
+	var autoChosen bool
+	if autoContext {
+		currentContext, autoChosen, autoNote = a.classifyContext(ctx, content, currentContext)
+	}
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), currentContext); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+	// An existing memory is overwritten, and moved if it is stored in another
+	// context, so that context must be one the client may use as well
+	existing, getErr := a.vectorStore.GetByID(ctx, id)
+	isNew := getErr != nil
+	if !isNew {
+		if msg := a.memoryAccessDenied(ctx, existing); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+	}
+	if msg := a.contextCapExceeded(ctx, currentContext, id); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
 
 	// Create metadata with context info
 	metadata := map[string]string{
-		"extra":    meta,
-		"context":  currentContext,
-		"client":   a.clientID,
+		"context":    currentContext,
+		"client":     a.clientIDFrom(ctx),
+		"created_at": a.createdAt(ctx, id),
+	}
+	for k, v := range extra {
+		metadata[k] = v
+	}
+	if autoChosen {
+		metadata["auto_classified"] = "true"
+	}
+	a.keepAccessStats(ctx, id, metadata)
+	if !expiresAt.IsZero() {
+		metadata["expires_at"] = expiresAt.UTC().Format(time.RFC3339)
 	}
+	for relation, ids := range relations {
+		metadata[relation] = ids
+	}
+	storedTags := ParseTags(existing.Metadata["tags"])
+	withTags(metadata, storedTags, tags)
 
-	err = a.vectorStore.AddDocuments(ctx, []chromem.Document{{
+	// Embed first so the new memory can be compared with stored ones. Long
+	// content is split into chunks, stored after the memory's parent record
+	previousChunks := a.storedChunkCount(ctx, id)
+	embedded, invalid, err := a.embedChunked(ctx, []chromem.Document{{
 		ID:       id,
 		Content:  content,
 		Metadata: metadata,
-	}}, 1)
+	}})
+	if err == nil && invalid[id] != nil {
+		err = invalid[id]
+	}
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to store memory: %v", err)), nil
 	}
+	doc, chunks := embedded[0], len(embedded)-1
+	changeNote, _ := args["change_note"].(string)
 
-	// Update context memory count
-	if err := a.ctx.IncrementMemoryCount(currentContext); err != nil {
-		a.logger.Printf("Warning: Failed to update context count: %v", err)
+	var near *nearDuplicate
+	if chunks == 0 {
+		if near, err = a.findNearDuplicate(ctx, doc, duplicate); err != nil {
+			a.logf(ctx, "Warning: Near-duplicate check failed: %v", err)
+		}
+	}
+	if near != nil && dedupe == DedupeSkip {
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' not stored: very similar to memory '%s' (similarity %.2f).", id, near.ID, near.Similarity)), nil
+	}
+	if near != nil && dedupe == DedupeMerge {
+		if err := a.mergeNearDuplicate(ctx, near.ID, doc, changeNote); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to merge into '%s': %v", near.ID, err)), nil
+		}
+		if err := a.ctx.Save(); err != nil {
+			a.logf(ctx, "Warning: Failed to save context state: %v", err)
+		}
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' not stored: merged into very similar memory '%s' (similarity %.2f).", id, near.ID, near.Similarity)), nil
+	}
+
+	entry := AuditEntry{Tool: "remember", MemoryIDs: []string{id}, ClientID: a.clientIDFrom(ctx), ContextID: currentContext, Status: "ok"}
+	if err := a.vectorStore.AddDocuments(ctx, embedded, 1); err != nil {
+		entry.Status, entry.Details = "error", err.Error()
+		a.recordAudit(ctx, entry)
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to store memory: %v", err)), nil
+	}
+	a.removeStaleChunks(ctx, id, previousChunks, chunks)
+	a.recordVersion(ctx, id, content, currentContext, ParseTags(metadata["tags"]), changeNote)
+	a.rememberTags(ctx, storedTags, tags)
+
+	if duplicate != "" {
+		// Overwrite: the new memory replaces its identical predecessor
+		if err := a.removeDuplicates(ctx, []string{duplicate}); err != nil {
+			a.logf(ctx, "Warning: Failed to remove duplicate '%s': %v", duplicate, err)
+		}
+	}
+
+	// Updates leave the context memory count as it is, unless they move the
+	// memory to another context
+	switch previous := existing.Metadata["context"]; {
+	case isNew:
+		if err := a.ctx.IncrementMemoryCount(currentContext); err != nil {
+			a.logf(ctx, "Warning: Failed to update context count: %v", err)
+		}
+	case previous != currentContext:
+		if err := a.ctx.MoveMemoryCount(previous, currentContext); err != nil {
+			a.logf(ctx, "Warning: Failed to update context counts: %v", err)
+		}
 	}
 
 	// Save context state (vector store persists automatically)
 	if err := a.ctx.Save(); err != nil {
-		a.logger.Printf("Warning: Failed to save context state: %v", err)
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
 	}
+	if duplicate != "" {
+		entry.Details = "replaced identical memory " + duplicate
+	}
+	a.recordAudit(ctx, entry)
 
-	return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' saved in context '%s'.", id, currentContext)), nil
+	msg := fmt.Sprintf("Memory '%s' saved in context '%s'.", id, currentContext)
+	if !expiresAt.IsZero() {
+		msg = fmt.Sprintf("Memory '%s' saved in context '%s', expires %s.", id, currentContext, a.formatTime(expiresAt))
+	}
+	if autoChosen {
+		msg += fmt.Sprintf(" The context was chosen automatically (%s).", autoNote)
+	} else if autoContext {
+		msg += fmt.Sprintf(" Automatic context detection kept the current context: %s.", autoNote)
+	}
+	if len(redacted) > 0 {
+		msg += fmt.Sprintf(" Personal data was redacted: %s.", strings.Join(redacted, ", "))
+	}
+	if chunks > 0 {
+		msg += fmt.Sprintf(" Content was split into %d chunks.", chunks)
+	}
+	if len(tags) > 0 {
+		msg += fmt.Sprintf(" Tags: %s.", strings.Join(tags, ", "))
+	}
+	if near != nil {
+		msg += fmt.Sprintf(" Warning: very similar to memory '%s' (similarity %.2f).", near.ID, near.Similarity)
+	}
+	return mcp.NewToolResultText(msg), nil
 }
 
 // rememberBatchHandler handles storing multiple memories at once.
@@ -127,13 +523,27 @@ func (a *App) rememberBatchHandler(ctx context.Context, request mcp.CallToolRequ
 	if len(memoriesRaw) == 0 {
 		return mcp.NewToolResultError("No memories provided"), nil
 	}
+	strategy, err := parseDuplicateStrategy(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	dedupe, err := parseDedupeMode(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 
 	// Get client's current context
-	currentContext, err := a.ctx.GetClientContext(a.clientID)
+	currentContext, err := a.ctx.GetClientContext(a.clientIDFrom(ctx))
 	if err != nil {
+		a.logf(ctx, "Warning: %v, using default context", err)
 		currentContext = DefaultContextID
 	}
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), currentContext); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
 
+	var dups duplicateSet
+	requestedTags := make(map[string][]string) // Memory ID -> tags given for it
 	documents := make([]chromem.Document, 0, len(memoriesRaw))
 	for _, m := range memoriesRaw {
 		mem, ok := m.(map[string]any)
@@ -143,7 +553,6 @@ func (a *App) rememberBatchHandler(ctx context.Context, request mcp.CallToolRequ
 
 		id, _ := mem["id"].(string)
 		content, _ := mem["content"].(string)
-		meta, _ := mem["metadata"].(string)
 
 		if id = strings.TrimSpace(id); id == "" {
 			continue
@@ -151,11 +560,26 @@ func (a *App) rememberBatchHandler(ctx context.Context, request mcp.CallToolRequ
 		if content = strings.TrimSpace(content); content == "" {
 			continue
 		}
+		extra, err := parseMetadataArg(mem["metadata"])
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Memory '%s': %v", id, err)), nil
+		}
+		tags := batchTags(mem["tags"])
+		if !dups.admit(ctx, a, strategy, id, content, tags) {
+			continue
+		}
 
 		metadata := map[string]string{
-			"extra":   meta,
-			"context": currentContext,
-			"client":  a.clientID,
+			"context":    currentContext,
+			"client":     a.clientIDFrom(ctx),
+			"created_at": a.createdAt(ctx, id),
+		}
+		for k, v := range extra {
+			metadata[k] = v
+		}
+		a.keepAccessStats(ctx, id, metadata)
+		if len(tags) > 0 {
+			requestedTags[id] = tags
 		}
 
 		documents = append(documents, chromem.Document{
@@ -166,27 +590,150 @@ func (a *App) rememberBatchHandler(ctx context.Context, request mcp.CallToolRequ
 	}
 
 	if len(documents) == 0 {
+		if dups.count() > 0 {
+			return mcp.NewToolResultText(fmt.Sprintf("No memories stored: %s.", dups.summary())), nil
+		}
 		return mcp.NewToolResultError("No valid memories to store"), nil
 	}
+	if msg := a.contextCapExceeded(ctx, currentContext, documentIDs(documents)...); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	// Existing memories are overwritten and moved to the current context, so
+	// the contexts they are stored in must be ones the client may use as well
+	previousChunks := make(map[string]int, len(documents))
+	existed := make(map[string]string, len(documents))      // Memory ID -> context it was stored in
+	storedTags := make(map[string][]string, len(documents)) // Memory ID -> tags it was stored with
+	for _, doc := range documents {
+		previousChunks[doc.ID] = a.storedChunkCount(ctx, doc.ID)
+		if existing, err := a.vectorStore.GetByID(ctx, doc.ID); err == nil {
+			if msg := a.memoryAccessDenied(ctx, existing); msg != "" {
+				return mcp.NewToolResultError(msg), nil
+			}
+			existed[doc.ID] = existing.Metadata["context"]
+			storedTags[doc.ID] = ParseTags(existing.Metadata["tags"])
+		}
+		withTags(doc.Metadata, storedTags[doc.ID], requestedTags[doc.ID])
+	}
 
-	err = a.vectorStore.AddDocuments(ctx, documents, 4) // Concurrency 4 for batch
+	// Memories whose embedding comes back invalid are skipped and reported.
+	// Long memories are split into chunks, stored after their parent record
+	documents, invalid, err := a.embedChunked(ctx, documents)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to store batch: %v", err)), nil
 	}
+	invalidNotes := invalidEmbeddingNotes(invalid)
+	if len(documents) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("No memories stored, every embedding was invalid: %s", strings.Join(invalidNotes, "; "))), nil
+	}
 
-	// Update context memory count
-	for range documents {
-		if err := a.ctx.IncrementMemoryCount(currentContext); err != nil {
-			a.logger.Printf("Warning: Failed to update context count: %v", err)
+	// Each memory is compared with the stored ones, not with the rest of the batch
+	changeNote, _ := args["change_note"].(string)
+	var nearNotes, nearSkipped, nearMerged []string
+	stored := documents[:0]
+	for _, doc := range documents {
+		if isChunk(doc.Metadata) || isChunkedParent(doc.Metadata) {
+			stored = append(stored, doc)
+			continue
+		}
+		near, err := a.findNearDuplicate(ctx, doc, dups.overwritten...)
+		if err != nil {
+			a.logf(ctx, "Warning: Near-duplicate check of '%s' failed: %v", doc.ID, err)
+		}
+		if near == nil {
+			stored = append(stored, doc)
+			continue
+		}
+		note := fmt.Sprintf("%s ~ %s (%.2f)", doc.ID, near.ID, near.Similarity)
+		switch dedupe {
+		case DedupeSkip:
+			nearSkipped = append(nearSkipped, note)
+		case DedupeMerge:
+			if err := a.mergeNearDuplicate(ctx, near.ID, doc, changeNote); err != nil {
+				a.logf(ctx, "Warning: Failed to merge '%s' into '%s': %v", doc.ID, near.ID, err)
+				stored = append(stored, doc)
+				continue
+			}
+			nearMerged = append(nearMerged, note)
+		default:
+			nearNotes = append(nearNotes, note)
+			stored = append(stored, doc)
+		}
+	}
+	documents = stored
+
+	if len(documents) > 0 {
+		err = a.vectorStore.AddDocuments(ctx, documents, 4) // Concurrency 4 for batch
+		if err != nil {
+			a.recordAudit(ctx, AuditEntry{Tool: "remember_batch", MemoryIDs: documentIDs(documents), ClientID: a.clientIDFrom(ctx),
+				ContextID: currentContext, Status: "error", Details: err.Error()})
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to store batch: %v", err)), nil
+		}
+	}
+	dups.apply(ctx, a)
+
+	// Chunks are part of their parent memory; they get no history or count of their own
+	stored = stored[:0]
+	for _, doc := range documents {
+		if !isChunk(doc.Metadata) {
+			stored = append(stored, doc)
+		}
+	}
+	documents = stored
+	var tagNotes []string
+	for _, doc := range documents {
+		a.removeStaleChunks(ctx, doc.ID, previousChunks[doc.ID], chunkCount(doc.Metadata))
+		a.recordVersion(ctx, doc.ID, doc.Content, currentContext, ParseTags(doc.Metadata["tags"]), changeNote)
+		a.rememberTags(ctx, storedTags[doc.ID], requestedTags[doc.ID])
+		if tags := requestedTags[doc.ID]; len(tags) > 0 {
+			tagNotes = append(tagNotes, fmt.Sprintf("%s (%s)", doc.ID, strings.Join(tags, ", ")))
+		}
+	}
+
+	// Update context memory counts for the new memories and those moved here
+	for _, doc := range documents {
+		previous, ok := existed[doc.ID]
+		switch {
+		case !ok:
+			if err := a.ctx.IncrementMemoryCount(currentContext); err != nil {
+				a.logf(ctx, "Warning: Failed to update context count: %v", err)
+			}
+		case previous != currentContext:
+			if err := a.ctx.MoveMemoryCount(previous, currentContext); err != nil {
+				a.logf(ctx, "Warning: Failed to update context counts: %v", err)
+			}
 		}
 	}
 
 	// Save context state
 	if err := a.ctx.Save(); err != nil {
-		a.logger.Printf("Warning: Failed to save context state: %v", err)
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	if len(documents) > 0 {
+		a.recordAudit(ctx, AuditEntry{Tool: "remember_batch", MemoryIDs: documentIDs(documents), ClientID: a.clientIDFrom(ctx),
+			ContextID: currentContext, Status: "ok", Details: fmt.Sprintf("stored %d memories", len(documents))})
 	}
 
-	return mcp.NewToolResultText(fmt.Sprintf("Successfully stored %d memories in context '%s'.", len(documents), currentContext)), nil
+	msg := fmt.Sprintf("Successfully stored %d memories in context '%s'.", len(documents), currentContext)
+	if len(tagNotes) > 0 {
+		msg += fmt.Sprintf(" Tagged: %s.", strings.Join(tagNotes, ", "))
+	}
+	if dups.count() > 0 {
+		msg += fmt.Sprintf(" Exact duplicates: %s.", dups.summary())
+	}
+	if len(invalidNotes) > 0 {
+		msg += fmt.Sprintf(" Skipped %d with invalid embeddings: %s.", len(invalidNotes), strings.Join(invalidNotes, "; "))
+	}
+	if len(nearNotes) > 0 {
+		msg += fmt.Sprintf(" Near duplicates stored anyway: %s.", strings.Join(nearNotes, ", "))
+	}
+	if len(nearSkipped) > 0 {
+		msg += fmt.Sprintf(" Near duplicates skipped: %s.", strings.Join(nearSkipped, ", "))
+	}
+	if len(nearMerged) > 0 {
+		msg += fmt.Sprintf(" Near duplicates merged: %s.", strings.Join(nearMerged, ", "))
+	}
+	return mcp.NewToolResultText(msg), nil
 }
 
 // searchHandler handles the search_memory tool - semantic similarity search.
@@ -206,22 +753,442 @@ func (a *App) searchHandler(ctx context.Context, request mcp.CallToolRequest) (*
 		return mcp.NewToolResultText(NoMemoriesMsg), nil
 	}
 
-	nResults := DefaultSearchResults
-	if totalDocs < nResults {
-		nResults = totalDocs
+	nResults := a.resultLimit(args, totalDocs)
+	mode, err := parseSearchMode(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	after, before, err := a.parseDateRange(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	dated := !after.IsZero() || !before.IsZero()
+	settings := a.settings()
+	useMMR := settings.UseMMR
+	if v, ok := args["use_mmr"].(bool); ok {
+		useMMR = v
+	}
+
+	// The date range is applied after the search and MMR picks from a larger
+	// pool, so more candidates are retrieved
+	depth := nResults
+	if dated {
+		depth = min(nResults*DateRangeCandidateFactor, totalDocs)
+	}
+	if useMMR {
+		depth = min(max(depth, nResults*MMRCandidateFactor), totalDocs)
+	}
+
+	// Restrict results to a single context via the "context" metadata key
+	var where map[string]string
+	contextID, _ := args["context_id"].(string)
+	if contextID = strings.TrimSpace(contextID); contextID != "" {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+		where = map[string]string{"context": contextID}
+	}
+
+	returnParent := true
+	if v, ok := args["return_parent"].(bool); ok {
+		returnParent = v
+	}
+
+	// Chunks of one memory collapse into a single result, so the search goes
+	// deeper until there are enough distinct memories
+	var results []chromem.Result
+	wanted := depth
+	for {
+		results, err = a.searchMemories(ctx, mode, query, depth, where)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+		}
+		if !returnParent {
+			break
+		}
+		found := len(results)
+		results = a.parentResults(ctx, results)
+		if found == len(results) || len(results) >= wanted || depth >= totalDocs {
+			break
+		}
+		depth = min(depth*2, totalDocs)
+	}
+	results = boostByImportance(results)
+	if !dated {
+		results = selectResults(results, nResults, useMMR, settings.MMRLambda, mode)
+	}
+	a.activity.Record(a.activityContext(ctx, contextID), ActivityCounts{Searches: 1})
+	if len(results) == 0 && contextID != "" {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories found in context '%s'.", contextID)), nil
+	}
+	if len(results) == 0 && mode == SearchModeKeyword {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories contain '%s'.", query)), nil
+	}
+	if dated {
+		results = createdWithin(results, after, before)
+		if len(results) == 0 {
+			return mcp.NewToolResultText("No matching memories were created in the given date range."), nil
+		}
+		results = selectResults(results, nResults, useMMR, settings.MMRLambda, mode)
+	}
+	a.recordAccess(ctx, accessedIDs(results)...)
+
+	var sb strings.Builder
+	sb.WriteString("Relevant memories:\n\n")
+	for _, res := range results {
+		score := formatSearchScore(mode, res.Similarity)
+		if label := chunkLabel(res.Metadata); label != "" {
+			sb.WriteString(fmt.Sprintf("[%s] (%s, %s)\n%s\n---\n", res.ID, score, label, res.Content))
+			continue
+		}
+		if matched := res.Metadata["matched_chunks"]; matched != "" {
+			sb.WriteString(fmt.Sprintf("[%s] (%s, %s of %s chunks matched)\n%s\n---\n", res.ID, score, matched, res.Metadata["chunk_count"], res.Content))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("[%s] (%s)\n%s\n---\n", res.ID, score, res.Content))
+	}
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// findSimilarHandler handles the find_similar tool - finds the nearest
+// neighbours of a stored memory using its own embedding as the query.
+func (a *App) findSimilarHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]any)
+	if !ok {
+		return mcp.NewToolResultError("Invalid arguments"), nil
+	}
+
+	id, _ := args["memory_id"].(string)
+	if id = strings.TrimSpace(id); id == "" {
+		return mcp.NewToolResultError("memory_id cannot be empty"), nil
 	}
 
-	results, err := a.vectorStore.Query(ctx, QueryTaskPrefix+query, nResults, nil, nil)
+	doc, err := a.vectorStore.GetByID(ctx, id)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' not found: %v", id, err)), nil
+	}
+	if msg := a.memoryAccessDenied(ctx, doc); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	// Backends that do not return stored vectors get the content re-embedded
+	embedding := doc.Embedding
+	if len(embedding) == 0 {
+		embeddings, err := a.vectorStore.BatchEmbed(ctx, []string{doc.Content})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Embedding failed: %v", err)), nil
+		}
+		embedding = embeddings[0]
+	}
+
+	totalDocs := a.vectorStore.Count()
+	if totalDocs <= 1 {
+		return mcp.NewToolResultText(fmt.Sprintf("No other memories to compare '%s' with.", id)), nil
+	}
+
+	// One extra result, since the memory itself is its own nearest neighbour.
+	// Every memory is ranked if some are off limits to the client
+	nResults := a.resultLimit(args, totalDocs-1)
+	depth := nResults + 1
+	if a.hidesContexts(ctx) {
+		depth = totalDocs
+	}
+	results, err := a.vectorStore.QueryEmbedding(ctx, embedding, depth, nil, nil)
 	if err != nil {
 		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
 	}
+	a.activity.Record(a.activityContext(ctx, doc.Metadata["context"]), ActivityCounts{Searches: 1})
+
+	var similar []chromem.Result
+	for _, res := range searchableResults(visibleResults(a.accessibleResults(ctx, results), a.clock())) {
+		if res.ID != id && res.Metadata["parent_id"] != id && len(similar) < nResults {
+			similar = append(similar, res)
+		}
+	}
+	if len(similar) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories similar to '%s' found.", id)), nil
+	}
 
 	var sb strings.Builder
-	sb.WriteString("Relevant memories:\n\n")
+	sb.WriteString(fmt.Sprintf("Memories similar to '%s':\n\n", id))
+	for _, res := range similar {
+		sb.WriteString(fmt.Sprintf("[%s] (Sim: %.2f)\n%s\n---\n", res.ID, res.Similarity, res.Content))
+	}
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// createdAt returns the created_at timestamp of an existing memory so updates
+// keep their original creation time, or the current time for new memories.
+// Timestamps written by older versions in server-local time are migrated to UTC.
+func (a *App) createdAt(ctx context.Context, id string) string {
+	if doc, err := a.vectorStore.GetByID(ctx, id); err == nil && doc.Metadata["created_at"] != "" {
+		if t, _, err := parseStoredTime(doc.Metadata["created_at"]); err == nil {
+			return t.Format(time.RFC3339)
+		}
+		return doc.Metadata["created_at"]
+	}
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// contextCapExceeded returns a message when storing the memories ids in
+// contextID would take it past the max_memories_per_context cap, or "" if they
+// fit. Memories already stored in the context are updates and do not add to
+// it. The memories are counted in the store, leaving out chunks and the trash,
+// since the context's MemoryCount can drift.
+func (a *App) contextCapExceeded(ctx context.Context, contextID string, ids ...string) string {
+	limit := a.settings().MaxMemoriesPerContext
+	if limit <= 0 || len(ids) == 0 {
+		return ""
+	}
+	docs, err := a.vectorStore.ListDocuments(ctx, map[string]string{"context": contextID}, 0, 0)
+	if err != nil {
+		a.logf(ctx, "Warning: Failed to count the memories of context '%s': %v", contextID, err)
+		return ""
+	}
+	stored := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		if !isChunk(doc.Metadata) && !isSoftDeleted(doc.Metadata) {
+			stored[doc.ID] = true
+		}
+	}
+	added := make(map[string]bool)
+	for _, id := range ids {
+		if !stored[id] {
+			added[id] = true
+		}
+	}
+	switch {
+	case len(stored)+len(added) <= limit:
+		return ""
+	case len(stored) >= limit:
+		return fmt.Sprintf("Context '%s' has reached its limit of %d memories. Delete old memories from it or switch to another context before saving new ones.", contextID, limit)
+	}
+	return fmt.Sprintf("Context '%s' holds %d memories and its limit is %d, so %d new ones do not fit. Delete old memories from it or store fewer.", contextID, len(stored), limit, len(added))
+}
+
+// recordVersion appends content to the memory's version history and counts
+// the creation or update in the activity log, unless it is unchanged from the
+// latest version. Failures are logged, not returned, since the memory itself
+// has already been stored.
+func (a *App) recordVersion(ctx context.Context, id, content, contextID string, tags []string, changeNote string) {
+	if a.versionMgr == nil {
+		return
+	}
+
+	note := "Created"
+	activity := ActivityCounts{Created: 1}
+	if latest, ok := a.versionMgr.LatestVersion(id); ok {
+		if latest.Content == content {
+			return
+		}
+		note = "Updated"
+		activity = ActivityCounts{Updated: 1}
+	}
+	a.activity.Record(contextID, activity)
+	if changeNote = strings.TrimSpace(changeNote); changeNote != "" {
+		note = changeNote
+	}
+
+	if err := a.versionMgr.AddVersion(id, content, a.clientIDFrom(ctx), note, contextID, tags); err != nil {
+		a.logf(ctx, "Warning: Failed to record version of '%s': %v", id, err)
+	}
+}
+
+// activityContext returns the context a query is counted under: the context it
+// was restricted to, or else the caller's current context.
+func (a *App) activityContext(ctx context.Context, contextID string) string {
+	if contextID != "" {
+		return contextID
+	}
+	current, err := a.ctx.GetClientContext(a.clientIDFrom(ctx))
+	if err != nil {
+		return DefaultContextID
+	}
+	return current
+}
+
+// splitList parses a comma-separated metadata list, such as relation IDs.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// resultLimit returns the number of results to query: the max_results argument
+// (or the server default) bounded by MaxSearchResultsCap and the number of documents.
+func (a *App) resultLimit(args map[string]any, totalDocs int) int {
+	n := a.settings().DefaultSearchResults
+	if requested, ok := args["max_results"].(float64); ok && requested >= 1 {
+		n = int(requested)
+	}
+	return max(1, min(n, totalDocs, MaxSearchResultsCap))
+}
+
+// searchAcrossContextsHandler handles the search_across_contexts tool - semantic
+// search over several contexts at once, merged and re-ranked by similarity.
+func (a *App) searchAcrossContextsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	query, _ := args["query"].(string)
+
+	if query = strings.TrimSpace(query); query == "" {
+		return mcp.NewToolResultError("Search query cannot be empty"), nil
+	}
+
+	var contextIDs []string
+	if raw, ok := args["context_ids"].([]any); ok {
+		for _, v := range raw {
+			if id, ok := v.(string); ok && strings.TrimSpace(id) != "" {
+				contextIDs = append(contextIDs, strings.TrimSpace(id))
+			}
+		}
+	}
+	for _, contextID := range contextIDs {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+	}
+	// Without context_ids every context the client may use is searched
+	if len(contextIDs) == 0 {
+		for _, c := range a.ctx.ListContexts() {
+			if a.contextAccessDenied(a.clientIDFrom(ctx), c.ID) == "" {
+				contextIDs = append(contextIDs, c.ID)
+			}
+		}
+	}
+
+	totalDocs := a.vectorStore.Count()
+	if totalDocs == 0 {
+		return mcp.NewToolResultText(NoMemoriesMsg), nil
+	}
+	nResults := a.resultLimit(args, totalDocs)
+
+	results, err := a.multiContextSearch(ctx, query, contextIDs, nResults)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+	a.activity.Record(a.activityContext(ctx, ""), ActivityCounts{Searches: 1})
+	if len(results) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories found in contexts: %s", strings.Join(contextIDs, ", "))), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Relevant memories across %d contexts:\n\n", len(contextIDs)))
 	for _, res := range results {
-		sb.WriteString(fmt.Sprintf("[%s] (Sim: %.2f)\n%s\n---\n", res.ID, 1-res.Similarity, res.Content))
+		if label := chunkLabel(res.Metadata); label != "" {
+			sb.WriteString(fmt.Sprintf("[%s] (Sim: %.2f, Context: %s, %s)\n%s\n---\n", res.ID, res.Similarity, res.Metadata["context"], label, res.Content))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("[%s] (Sim: %.2f, Context: %s)\n%s\n---\n", res.ID, res.Similarity, res.Metadata["context"], res.Content))
+	}
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// multiContextSearch embeds the query once, searches each context in parallel
+// using the "context" metadata filter, and merges the results. Memories that
+// show up in several contexts keep their best score; the top nResults are returned.
+func (a *App) multiContextSearch(ctx context.Context, query string, contextIDs []string, nResults int) ([]chromem.Result, error) {
+	embeddings, err := a.vectorStore.BatchEmbed(ctx, []string{QueryTaskPrefix + query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(embeddings) != 1 {
+		return nil, fmt.Errorf("expected 1 embedding, got %d", len(embeddings))
+	}
+
+	type contextResults struct {
+		contextID string
+		results   []chromem.Result
+		err       error
+	}
+	ch := make(chan contextResults, len(contextIDs))
+	for _, contextID := range contextIDs {
+		go func(contextID string) {
+			results, err := a.vectorStore.QueryEmbedding(ctx, embeddings[0], nResults, map[string]string{"context": contextID}, nil)
+			ch <- contextResults{contextID: contextID, results: results, err: err}
+		}(contextID)
+	}
+
+	best := make(map[string]chromem.Result)
+	var failed []string
+	for range contextIDs {
+		cr := <-ch
+		if cr.err != nil {
+			a.logf(ctx, "Warning: Search in context '%s' failed: %v", cr.contextID, cr.err)
+			failed = append(failed, cr.contextID)
+			continue
+		}
+		for _, res := range searchableResults(visibleResults(cr.results, a.clock())) {
+			if existing, ok := best[res.ID]; !ok || res.Similarity > existing.Similarity {
+				best[res.ID] = res
+			}
+		}
+	}
+	if len(failed) == len(contextIDs) {
+		return nil, fmt.Errorf("search failed in all contexts")
+	}
+
+	merged := make([]chromem.Result, 0, len(best))
+	for _, res := range best {
+		merged = append(merged, res)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].Similarity != merged[j].Similarity {
+			return merged[i].Similarity > merged[j].Similarity
+		}
+		return merged[i].ID < merged[j].ID
+	})
+	if len(merged) > nResults {
+		merged = merged[:nResults]
+	}
+	return merged, nil
+}
+
+// getMemoryHandler handles the get_memory tool - retrieves a single memory by its exact ID.
+func (a *App) getMemoryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	id, _ := args["id"].(string)
+
+	if id = strings.TrimSpace(id); id == "" {
+		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
+	}
+
+	doc, err := a.vectorStore.GetByID(ctx, id)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' not found: %v", id, err)), nil
+	}
+	if msg := a.memoryAccessDenied(ctx, doc); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+	a.recordAccess(ctx, accessedIDs([]chromem.Result{{ID: doc.ID, Metadata: doc.Metadata}})...)
+
+	versionCount := 0
+	if history, err := a.versionMgr.GetHistory(id); err == nil {
+		versionCount = len(history.Versions)
 	}
 
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Memory [%s]\n\n%s\n\n", doc.ID, doc.Content))
+	sb.WriteString("Metadata:\n")
+	keys := make([]string, 0, len(doc.Metadata))
+	for k := range doc.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if doc.Metadata[k] == "" {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- %s: %s\n", k, a.formatMetadataValue(k, doc.Metadata[k])))
+	}
+	sb.WriteString(fmt.Sprintf("- versions: %d\n", versionCount))
+
 	return mcp.NewToolResultText(sb.String()), nil
 }
 
@@ -234,29 +1201,74 @@ func (a *App) deleteHandler(ctx context.Context, request mcp.CallToolRequest) (*
 		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
 	}
 
-	err := a.vectorStore.Delete(ctx, nil, nil, id)
+	doc, err := a.vectorStore.GetByID(ctx, id)
 	if err != nil {
-		return mcp.NewToolResultError(fmt.Sprintf("Delete failed: %v", err)), nil
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' not found: %v", id, err)), nil
+	}
+	if msg := a.memoryAccessDenied(ctx, doc); msg != "" {
+		return mcp.NewToolResultError(msg), nil
 	}
 
-	// Update context memory count
-	currentContext, err := a.ctx.GetClientContext(a.clientID)
-	if err == nil {
-		if err := a.ctx.DecrementMemoryCount(currentContext); err != nil {
-			a.logger.Printf("Warning: Failed to update context count: %v", err)
+	// With soft delete enabled the memory goes to the trash first; deleting a
+	// memory that is already in the trash removes it for good
+	trashed := a.settings().SoftDelete && !isSoftDeleted(doc.Metadata)
+	if trashed {
+		if err := a.moveToTrash(ctx, doc, time.Now()); err != nil {
+			a.recordAudit(ctx, AuditEntry{Tool: "delete_memory", MemoryIDs: []string{id}, ClientID: a.clientIDFrom(ctx), ContextID: doc.Metadata["context"], Status: "error", Details: err.Error()})
+			return mcp.NewToolResultError(fmt.Sprintf("Delete failed: %v", err)), nil
+		}
+	} else {
+		if err := a.vectorStore.Delete(ctx, nil, nil, id); err != nil {
+			a.recordAudit(ctx, AuditEntry{Tool: "delete_memory", MemoryIDs: []string{id}, ClientID: a.clientIDFrom(ctx), ContextID: doc.Metadata["context"], Status: "error", Details: err.Error()})
+			return mcp.NewToolResultError(fmt.Sprintf("Delete failed: %v", err)), nil
+		}
+		if _, err := a.versionMgr.GetHistory(id); err == nil {
+			if err := a.versionMgr.DeleteMemoryHistory(id); err != nil {
+				a.logf(ctx, "Warning: Failed to delete version history: %v", err)
+			}
 		}
 	}
+	a.deleteChunksOf(ctx, doc, trashed)
+
+	// Update the memory count of the context the memory belonged to, which
+	// need not be the caller's current context. Soft-deleted memories were
+	// already uncounted.
+	if !isSoftDeleted(doc.Metadata) {
+		contextID := doc.Metadata["context"]
+		if contextID == "" {
+			contextID = DefaultContextID
+		}
+		if err := a.ctx.DecrementMemoryCount(contextID); err != nil {
+			a.logf(ctx, "Warning: Failed to update context count: %v", err)
+		}
+		a.activity.Record(contextID, ActivityCounts{Deleted: 1})
+	}
 
 	// Save both database and context state
 	if err := a.ctx.Save(); err != nil {
-		a.logger.Printf("Warning: Failed to save context state: %v", err)
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	entry := AuditEntry{Tool: "delete_memory", MemoryIDs: []string{id}, ClientID: a.clientIDFrom(ctx), ContextID: doc.Metadata["context"], Status: "ok", Details: "deleted"}
+	if trashed {
+		entry.Details = "moved to trash"
 	}
+	a.recordAudit(ctx, entry)
 
+	if trashed {
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' moved to trash. Use restore_memory to bring it back.", id)), nil
+	}
 	return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' deleted.", id)), nil
 }
 
-// listHandler handles the list_memories tool - returns all stored memory IDs and snippets.
+// listHandler handles the list_memories tool - returns all stored memory IDs and snippets,
+// optionally limited to memories created within a date range.
 func (a *App) listHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	after, before, err := a.parseDateRange(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
 	count := a.vectorStore.Count()
 	if count == 0 {
 		return mcp.NewToolResultText(EmptyBrainMsg), nil
@@ -266,36 +1278,174 @@ func (a *App) listHandler(ctx context.Context, request mcp.CallToolRequest) (*mc
 	if err != nil {
 		return mcp.NewToolResultError("Could not retrieve memory list"), nil
 	}
+	results = visibleResults(a.accessibleResults(ctx, results), a.clock())
+	if !after.IsZero() || !before.IsZero() {
+		results = createdWithin(results, after, before)
+	}
+
+	// Chunks are listed through their parent
+	listed := results[:0]
+	for _, res := range results {
+		if !isChunk(res.Metadata) {
+			listed = append(listed, res)
+		}
+	}
+	results = listed
 
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("Brain contains %d memories:\n", count))
+	sb.WriteString(fmt.Sprintf("Brain contains %d memories:\n", len(results)))
 	for _, res := range results {
 		snippet := res.Content
 		if len(snippet) > MaxSnippetLength {
 			snippet = snippet[:MaxSnippetLength-3] + "..."
 		}
-		sb.WriteString(fmt.Sprintf("- %s: %s\n", res.ID, snippet))
+		star := ""
+		if isStarred(res.Metadata) {
+			star = "★ "
+		}
+		if isChunkedParent(res.Metadata) {
+			sb.WriteString(fmt.Sprintf("- %s%s (%d chunks): %s\n", star, res.ID, chunkCount(res.Metadata), snippet))
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("- %s%s: %s\n", star, res.ID, snippet))
 	}
 
-	return mcp.NewToolResultText(sb.String()), nil
+	return a.sizedResult("list", "txt", len(results), []byte(sb.String())), nil
+}
+
+// parseDateRange reads the created_after and created_before arguments. Naked
+// dates are interpreted in the configured timezone, and a naked created_before
+// date includes that whole day.
+func (a *App) parseDateRange(args map[string]any) (after, before time.Time, err error) {
+	if value, _ := args["created_after"].(string); strings.TrimSpace(value) != "" {
+		if after, _, err = parseUserTime(value, a.location); err != nil {
+			return after, before, fmt.Errorf("invalid created_after: %w", err)
+		}
+	}
+	if value, _ := args["created_before"].(string); strings.TrimSpace(value) != "" {
+		var dateOnly bool
+		if before, dateOnly, err = parseUserTime(value, a.location); err != nil {
+			return after, before, fmt.Errorf("invalid created_before: %w", err)
+		}
+		if dateOnly {
+			before = endOfDay(before, a.location)
+		}
+	}
+	if !after.IsZero() && !before.IsZero() && !after.Before(before) {
+		return after, before, fmt.Errorf("created_after must be before created_before")
+	}
+	return after, before, nil
+}
+
+// createdWithin keeps results whose created_at lies in [after, before). A zero
+// bound is open. Memories without created_at are dropped.
+func createdWithin(results []chromem.Result, after, before time.Time) []chromem.Result {
+	var kept []chromem.Result
+	for _, res := range results {
+		t, _, err := parseStoredTime(res.Metadata["created_at"])
+		if err != nil {
+			continue
+		}
+		if (!after.IsZero() && t.Before(after)) || (!before.IsZero() && !t.Before(before)) {
+			continue
+		}
+		kept = append(kept, res)
+	}
+	return kept
 }
 
 // wipeHandler handles the wipe_all_memories tool - completely clears the brain database.
 func (a *App) wipeHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	// Wiping would take the memories of contexts the client may not use with it
+	if a.hidesContexts(ctx) {
+		return mcp.NewToolResultError("Cannot wipe all memories: some contexts belong to other clients and are not shared with you. Use delete_context on your own contexts instead."), nil
+	}
+
+	// Collect the contexts that hold memories before they are gone
+	docs, err := a.vectorStore.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list memories: %v", err)), nil
+	}
+	var contextIDs []string
+	for _, doc := range docs {
+		contextID := doc.Metadata["context"]
+		if contextID == "" {
+			contextID = DefaultContextID
+		}
+		if !slices.Contains(contextIDs, contextID) {
+			contextIDs = append(contextIDs, contextID)
+		}
+	}
+
 	if err := a.vectorStore.ClearAll(ctx); err != nil {
+		a.recordAudit(ctx, AuditEntry{Tool: "wipe_all_memories", ClientID: a.clientIDFrom(ctx), Status: "error", Details: err.Error()})
 		return mcp.NewToolResultError(fmt.Sprintf("Failed to wipe memories: %v", err)), nil
 	}
+	for _, doc := range docs {
+		if !isSoftDeleted(doc.Metadata) {
+			a.activity.Record(doc.Metadata["context"], ActivityCounts{Deleted: 1})
+		}
+	}
 
 	// Reset context memory counts
-	contexts := a.ctx.ListContexts()
-	for _, c := range contexts {
-		c.MemoryCount = 0
-	}
+	a.ctx.ResetMemoryCounts(contextIDs)
 
 	// Save reset state
 	if err := a.ctx.Save(); err != nil {
-		a.logger.Printf("Warning: Failed to save context state: %v", err)
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
 	}
+	a.recordAudit(ctx, AuditEntry{Tool: "wipe_all_memories", MemoryIDs: documentIDs(docs), ClientID: a.clientIDFrom(ctx), Status: "ok",
+		Details: fmt.Sprintf("wiped %d documents", len(docs))})
 
 	return mcp.NewToolResultText(BrainWipedMsg), nil
 }
+
+// brainStatusHandler handles the brain_status and get_brain_status tools -
+// summarizes the brain and how the server is set up: which backend holds the
+// memories and where, the providers, version and uptime.
+func (a *App) brainStatusHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	currentContext, err := a.ctx.GetClientContext(a.clientIDFrom(ctx))
+	if err != nil {
+		a.logf(ctx, "Warning: %v, using default context", err)
+		currentContext = DefaultContextID
+	}
+
+	llm := "none"
+	if a.llm != nil {
+		llm = a.llm.Name()
+	}
+	embedding := a.embeddingProvider
+	if embedding == "" {
+		embedding = "gemini"
+	}
+	a.reloadMu.Lock()
+	cfg := a.config
+	a.reloadMu.Unlock()
+	backend, location := storeLocation(cfg)
+
+	var sb strings.Builder
+	sb.WriteString("Brain status:\n")
+	sb.WriteString(fmt.Sprintf("- Memories: %d\n", a.vectorStore.Count()))
+	sb.WriteString(fmt.Sprintf("- Contexts: %d (current: %s)\n", len(a.ctx.ListContexts()), currentContext))
+	sb.WriteString(fmt.Sprintf("- Tags: %d\n", len(a.ctx.ListTags())))
+	sb.WriteString(fmt.Sprintf("- Active sessions: %d\n", a.ctx.SessionCount()))
+	sb.WriteString(fmt.Sprintf("- LLM: %s\n", llm))
+	sb.WriteString(fmt.Sprintf("- Embedding provider: %s\n", embedding))
+	sb.WriteString(fmt.Sprintf("- Backend: %s\n", backend))
+	sb.WriteString(fmt.Sprintf("- Data store: %s\n", location))
+	sb.WriteString(fmt.Sprintf("- Server data directory: %s\n", a.dataDir))
+	sb.WriteString(fmt.Sprintf("- Server version: %s\n", ServerVersion))
+	sb.WriteString(fmt.Sprintf("- Timezone: %s (now %s)\n", a.location, a.formatTime(time.Now())))
+	if !a.startTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("- Uptime: %s (since %s)\n", time.Since(a.startTime).Round(time.Second), a.formatTime(a.startTime)))
+	}
+
+	if reports := vectorBackends.completed(); len(reports) > 0 {
+		sb.WriteString("\nDeveloper:\n")
+		for _, report := range reports {
+			sb.WriteString(fmt.Sprintf("- Conformance %s (ran %s)\n", report.Summary(), a.formatTime(report.RanAt)))
+		}
+	}
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"context"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// testDimension is the size of the vectors produced by testEmbedding.
+const testDimension = 64
+
+// testEmbedding is a deterministic bag-of-words embedder, so texts sharing
+// words are similar and tests need no embedding provider.
+func testEmbedding(_ context.Context, text string) ([]float32, error) {
+	vec := make([]float32, testDimension)
+	for _, word := range strings.Fields(strings.ToLower(strings.TrimPrefix(text, QueryTaskPrefix))) {
+		h := fnv.New32a()
+		h.Write([]byte(strings.Trim(word, ".,;:!?\"'()")))
+		vec[h.Sum32()%testDimension]++
+	}
+	if vecmath.Norm(vec) == 0 {
+		vec[0] = 1
+	}
+	if err := vecmath.Normalize(vec); err != nil {
+		return nil, err
+	}
+	return vec, nil
+}
+
+// countingEmbedder embeds with testEmbedding and counts the texts it embeds,
+// single or batched, so tests can assert that nothing was embedded.
+type countingEmbedder struct {
+	texts atomic.Int64
+}
+
+// Embed is a chromem.EmbeddingFunc.
+func (e *countingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	e.texts.Add(1)
+	return testEmbedding(ctx, text)
+}
+
+// BatchEmbed is a BatchEmbeddingFunc.
+func (e *countingEmbedder) BatchEmbed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		vec, err := e.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = vec
+	}
+	return out, nil
+}
+
+// Count returns the number of texts embedded so far.
+func (e *countingEmbedder) Count() int {
+	return int(e.texts.Load())
+}
+
+// syntheticEmbedder embeds the texts it has a vector for as that vector, so
+// tests can set exact similarities, and every other text with testEmbedding.
+type syntheticEmbedder map[string][]float32
+
+// Embed is a chromem.EmbeddingFunc.
+func (s syntheticEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if vec, ok := s[strings.TrimPrefix(text, QueryTaskPrefix)]; ok {
+		return slices.Clone(vec), nil
+	}
+	return testEmbedding(ctx, text)
+}
+
+// BatchEmbed is a BatchEmbeddingFunc.
+func (s syntheticEmbedder) BatchEmbed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i], _ = s.Embed(ctx, text)
+	}
+	return out, nil
+}
+
+// angled returns the unit vector whose cosine similarity to the first axis is
+// cos, turned toward axis. Vectors turned toward the same axis are as similar
+// as the cosine of the difference of their angles.
+func angled(cos float64, axis int) []float32 {
+	vec := make([]float32, testDimension)
+	vec[0] = float32(cos)
+	vec[axis] = float32(math.Sqrt(1 - cos*cos))
+	return vec
+}
+
+// newSyntheticApp returns a testApp whose backend embeds with vectors.
+func newSyntheticApp(t *testing.T, configure func(cfg *Config), vectors syntheticEmbedder) *testApp {
+	t.Helper()
+	ta := newTestApp(t, configure)
+	backend, err := NewLocalVectorStore(t.TempDir(), nil, vectors.Embed, vectors.BatchEmbed, testDimension, nil)
+	if err != nil {
+		t.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	ta.backend = backend
+	ta.vectorStore = NewIndexedVectorStore(backend, ta.keywordIndex, ta.hashIndex)
+	ta.filterEngine = NewSearchFilterEngine(ta.vectorStore, ta.versionMgr, ta.ctx)
+	return ta
+}
+
+// fakeLLM is an LLMProvider that answers every prompt with reply and records
+// the prompts it was given.
+type fakeLLM struct {
+	mu      sync.Mutex
+	reply   func(prompt string) (string, error)
+	prompts []string
+}
+
+// newFakeLLM returns a fakeLLM that always answers answer.
+func newFakeLLM(answer string) *fakeLLM {
+	return &fakeLLM{reply: func(string) (string, error) { return answer, nil }}
+}
+
+func (f *fakeLLM) Generate(_ context.Context, prompt string, _ *GenerateOptions) (string, error) {
+	f.mu.Lock()
+	f.prompts = append(f.prompts, prompt)
+	f.mu.Unlock()
+	return f.reply(prompt)
+}
+
+func (f *fakeLLM) Name() string { return "fake" }
+
+// Prompts returns the prompts received so far.
+func (f *fakeLLM) Prompts() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.prompts...)
+}
+
+// testApp is an App on a local store in a temporary data directory, embedding
+// with a countingEmbedder.
+type testApp struct {
+	*App
+	embedder *countingEmbedder
+	backend  *LocalVectorStore
+}
+
+// newTestApp creates a testApp with the default configuration, adjusted by
+// configure if it is not nil.
+func newTestApp(t *testing.T, configure func(cfg *Config)) *testApp {
+	t.Helper()
+	dir := t.TempDir()
+	logger := log.New(io.Discard, "", 0)
+	cfg := DefaultConfig()
+	if configure != nil {
+		configure(cfg)
+	}
+
+	embedder := &countingEmbedder{}
+	backend, err := NewLocalVectorStore(filepath.Join(dir, DefaultDBPath), nil, embedder.Embed, embedder.BatchEmbed, testDimension, logger)
+	if err != nil {
+		t.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	keywordIndex := NewKeywordIndex(filepath.Join(dir, "keyword_index.json"), nil, logger)
+	hashIndex := NewContentHashIndex()
+	store := NewIndexedVectorStore(backend, keywordIndex, hashIndex)
+	versionMgr, err := NewMemoryVersionManager(filepath.Join(dir, "memory_versions"), nil, logger)
+	if err != nil {
+		t.Fatalf("NewMemoryVersionManager: %v", err)
+	}
+
+	app := &App{
+		vectorStore:       store,
+		logger:            logger,
+		dataDir:           dir,
+		keywordIndex:      keywordIndex,
+		hashIndex:         hashIndex,
+		versionMgr:        versionMgr,
+		embeddingProvider: "fake",
+		embedders:         map[string]chromem.EmbeddingFunc{"fake": embedder.Embed},
+		tracer:            &Tracer{logger: logger, buffer: NewTraceBuffer(DefaultTraceBufferSize)},
+		location:          time.UTC,
+		clientID:          "test-client",
+		config:            cfg,
+		startTime:         time.Now(),
+		reembed:           NewReembedQueue(),
+		conversations:     NewConversationStore(),
+		ctx:               NewContextManager(filepath.Join(dir, ContextsDataPath), nil),
+		contextVectors:    NewContextVectors(filepath.Join(dir, "context_vectors.json"), nil, logger),
+		activity:          NewActivityLog(filepath.Join(dir, "activity.json"), nil, time.UTC, logger),
+	}
+	app.filterEngine = NewSearchFilterEngine(store, versionMgr, app.ctx)
+	app.currentSettings.Store(newSettings(cfg, settingOverrides{}))
+	return &testApp{App: app, embedder: embedder, backend: backend}
+}
+
+// call invokes a tool handler with args and returns the text of its result
+// and whether it is an error.
+func call(t *testing.T, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) (string, bool) {
+	t.Helper()
+	return callAs(t, context.Background(), handler, args)
+}
+
+// callAs is call with the given context, e.g. one carrying a client ID.
+func callAs(t *testing.T, ctx context.Context, handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]any) (string, bool) {
+	t.Helper()
+	var request mcp.CallToolRequest
+	request.Params.Arguments = args
+	result, err := handler(ctx, request)
+	if err != nil {
+		t.Fatalf("handler returned error: %v", err)
+	}
+	return resultText(result), result.IsError
+}
+
+// remember stores a memory through the remember tool and fails the test if
+// that fails.
+func (ta *testApp) remember(t *testing.T, id, content string, extra map[string]any) {
+	t.Helper()
+	args := map[string]any{"id": id, "content": content}
+	for k, v := range extra {
+		args[k] = v
+	}
+	if text, isErr := call(t, ta.rememberHandler, args); isErr {
+		t.Fatalf("remember %s: %s", id, text)
+	}
+}
+
+// switchContext switches the test client to contextID and fails the test if
+// that fails.
+func (ta *testApp) switchContext(t *testing.T, contextID string) {
+	t.Helper()
+	if text, isErr := call(t, ta.switchContextHandler, map[string]any{"context_id": contextID}); isErr {
+		t.Fatalf("switch_context %s: %s", contextID, text)
+	}
+}
+
+// captureStdout runs f and returns what it printed to standard output, for
+// testing the interactive CLI.
+func captureStdout(t *testing.T, f func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	out := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- string(data)
+	}()
+	f()
+	w.Close()
+	return <-out
+}
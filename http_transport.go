@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Transports of the MCP server, see -transport
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+)
+
+// serveSSE serves s over HTTP with Server-Sent Events on host:port until a
+// signal arrives on sigChan or the server fails. Clients connect to /sse and
+// post their messages to /message. On a signal the open SSE connections are
+// closed and in-flight requests get HTTPShutdownTimeout to finish; the caller
+// then closes the stores.
+func (a *App) serveSSE(s *server.MCPServer, host string, port int, sigChan <-chan os.Signal) error {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	sse := server.NewSSEServer(s, server.WithKeepAlive(true))
+
+	errCh := make(chan error, 1)
+	go func() { errCh <- sse.Start(addr) }()
+	a.logger.Printf("BrainMCP Server starting (version %s) on http://%s/sse...", ServerVersion, addr)
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("HTTP server on %s failed: %w", addr, err)
+	case sig := <-sigChan:
+		a.logger.Printf("Received signal %v, draining HTTP connections...", sig)
+		ctx, cancel := context.WithTimeout(context.Background(), HTTPShutdownTimeout)
+		defer cancel()
+		if err := sse.Shutdown(ctx); err != nil {
+			a.logger.Printf("Warning: HTTP connections did not drain in time: %v", err)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// parseSearchMode reads the mode argument of search_memory and search_advanced.
+func parseSearchMode(args map[string]any) (string, error) {
+	mode, _ := args["mode"].(string)
+	switch mode = strings.TrimSpace(mode); mode {
+	case "":
+		return SearchModeSemantic, nil
+	case SearchModeSemantic, SearchModeKeyword, SearchModeHybrid:
+		return mode, nil
+	}
+	return "", fmt.Errorf("mode must be '%s', '%s' or '%s'", SearchModeSemantic, SearchModeKeyword, SearchModeHybrid)
+}
+
+// fusedHit is a memory ranked by reciprocal-rank fusion.
+type fusedHit struct {
+	ID    string
+	Score float64
+}
+
+// reciprocalRankFusion merges rankings of memory IDs, best first. A memory
+// scores the sum of 1/(RRFRankConstant+rank) over the rankings it appears in,
+// with ranks starting at 1, so memories found by several rankings rise to the
+// top. Ties keep the order of first appearance.
+func reciprocalRankFusion(rankings ...[]string) []fusedHit {
+	scores := make(map[string]float64)
+	var order []string
+	for _, ranking := range rankings {
+		for rank, id := range ranking {
+			if _, ok := scores[id]; !ok {
+				order = append(order, id)
+			}
+			scores[id] += 1 / float64(RRFRankConstant+rank+1)
+		}
+	}
+
+	hits := make([]fusedHit, len(order))
+	for i, id := range order {
+		hits[i] = fusedHit{ID: id, Score: scores[id]}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+	return hits
+}
+
+// keywordSearch returns up to n memories matching the query's tokens in the
+// keyword index, restricted to the where metadata filter. Memories containing
+// the whole query (ignoring case) come first, then by TF-IDF score, which is
+// returned as the result's Similarity. Hidden memories, chunked parents and
+// memories in contexts the client may not use are skipped like in semantic
+// search.
+func (a *App) keywordSearch(ctx context.Context, query string, n int, where map[string]string) []chromem.Result {
+	if a.keywordIndex == nil {
+		return nil
+	}
+	phrase := strings.ToLower(strings.TrimSpace(query))
+	now := a.clock()
+
+	var results []chromem.Result
+	var phraseMatch []bool
+	for _, hit := range a.keywordIndex.Search(query, 0) {
+		doc, err := a.vectorStore.GetByID(ctx, hit.ID)
+		if err != nil || isSoftDeleted(doc.Metadata) || isExpired(doc.Metadata, now) || isChunkedParent(doc.Metadata) {
+			continue
+		}
+		if a.memoryAccessDenied(ctx, doc) != "" {
+			continue
+		}
+		matches := true
+		for k, v := range where {
+			if doc.Metadata[k] != v {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		results = append(results, chromem.Result{ID: doc.ID, Metadata: doc.Metadata, Embedding: doc.Embedding, Content: doc.Content, Similarity: float32(hit.Score)})
+		phraseMatch = append(phraseMatch, strings.Contains(strings.ToLower(doc.Content), phrase))
+	}
+
+	// Index hits are already sorted by score, so a stable sort keeps that order within each group
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return phraseMatch[order[i]] && !phraseMatch[order[j]]
+	})
+	sorted := make([]chromem.Result, 0, min(n, len(results)))
+	for _, i := range order {
+		if len(sorted) == n {
+			break
+		}
+		sorted = append(sorted, results[i])
+	}
+	return sorted
+}
+
+// searchMemories runs a search_memory query in the given mode and returns up
+// to n results, best first. Semantic results carry their similarity, keyword
+// results their keyword score and hybrid results their fused score in
+// Similarity.
+func (a *App) searchMemories(ctx context.Context, mode, query string, n int, where map[string]string) ([]chromem.Result, error) {
+	// Without a context every memory is ranked if some are off limits to the
+	// client, so the ones it may see still fill the results
+	hides := where["context"] == "" && a.hidesContexts(ctx)
+	semantic := func(n int) ([]chromem.Result, error) {
+		depth := n
+		if hides {
+			depth = a.vectorStore.Count()
+		}
+		results, err := a.vectorStore.Query(ctx, QueryTaskPrefix+query, depth, where, nil)
+		if err != nil {
+			return nil, err
+		}
+		results = searchableResults(visibleResults(a.accessibleResults(ctx, results), a.clock()))
+		return results[:min(len(results), n)], nil
+	}
+
+	switch mode {
+	case SearchModeKeyword:
+		return a.keywordSearch(ctx, query, n, where), nil
+	case SearchModeHybrid:
+		// Both rankings go deeper than n so fusion can promote memories ranked lower in one of them
+		depth := min(n*HybridCandidateFactor, a.vectorStore.Count())
+		semanticResults, err := semantic(depth)
+		if err != nil {
+			return nil, err
+		}
+		keywordResults := a.keywordSearch(ctx, query, depth, where)
+		return fuseResults(n, semanticResults, keywordResults), nil
+	}
+	return semantic(n)
+}
+
+// fuseResults merges result lists with reciprocal-rank fusion and returns the
+// top n, with the fused score as Similarity.
+func fuseResults(n int, lists ...[]chromem.Result) []chromem.Result {
+	byID := make(map[string]chromem.Result)
+	rankings := make([][]string, len(lists))
+	for i, list := range lists {
+		for _, res := range list {
+			rankings[i] = append(rankings[i], res.ID)
+			if _, ok := byID[res.ID]; !ok {
+				byID[res.ID] = res
+			}
+		}
+	}
+
+	var fused []chromem.Result
+	for _, hit := range reciprocalRankFusion(rankings...) {
+		if len(fused) == n {
+			break
+		}
+		res := byID[hit.ID]
+		res.Similarity = float32(hit.Score)
+		fused = append(fused, res)
+	}
+	return fused
+}
+
+// rankByMode orders search_advanced candidates by the filter's search mode,
+// keeping only those the mode's ranking matched.
+func (a *App) rankByMode(ctx context.Context, filter SearchFilter, candidates []SearchResult) ([]SearchResult, error) {
+	switch filter.Mode {
+	case SearchModeKeyword:
+		return a.rankByKeyword(ctx, filter, candidates), nil
+	case SearchModeHybrid:
+		// Both rankings reorder their input in place
+		semantic, err := a.rankBySimilarity(ctx, filter, slices.Clone(candidates))
+		if err != nil {
+			return nil, err
+		}
+		keyword := a.rankByKeyword(ctx, filter, slices.Clone(candidates))
+
+		byID := make(map[string]SearchResult, len(candidates))
+		rankings := make([][]string, 2)
+		for i, ranked := range [][]SearchResult{semantic, keyword} {
+			for _, c := range ranked {
+				rankings[i] = append(rankings[i], c.ID)
+				byID[c.ID] = c
+			}
+		}
+		fused := make([]SearchResult, 0, len(byID))
+		for _, hit := range reciprocalRankFusion(rankings...) {
+			c := byID[hit.ID]
+			c.Similarity = float32(hit.Score)
+			fused = append(fused, c)
+		}
+		return fused, nil
+	}
+	return a.rankBySimilarity(ctx, filter, candidates)
+}
+
+// rankByKeyword keeps the candidates matching the filter's query in the
+// keyword index, in keyword order, with their keyword score as Similarity.
+func (a *App) rankByKeyword(ctx context.Context, filter SearchFilter, candidates []SearchResult) []SearchResult {
+	var where map[string]string
+	if filter.ContextID != "" {
+		where = map[string]string{"context": filter.ContextID}
+	}
+	byID := make(map[string]SearchResult, len(candidates))
+	for _, c := range candidates {
+		byID[c.ID] = c
+	}
+
+	ranked := candidates[:0]
+	for _, res := range a.keywordSearch(ctx, filter.Query, a.vectorStore.Count(), where) {
+		if c, ok := byID[res.ID]; ok {
+			c.Similarity = res.Similarity
+			ranked = append(ranked, c)
+		}
+	}
+	return ranked
+}
+
+// formatSearchScore renders a result's score as search output shows it for mode.
+func formatSearchScore(mode string, score float32) string {
+	switch mode {
+	case SearchModeKeyword:
+		return fmt.Sprintf("Keyword: %.2f", score)
+	case SearchModeHybrid:
+		return fmt.Sprintf("RRF: %.4f", score)
+	}
+	return fmt.Sprintf("Sim: %.2f", score)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestReciprocalRankFusion(t *testing.T) {
+	hits := reciprocalRankFusion([]string{"a", "b", "c"}, []string{"c", "d"})
+	var ids []string
+	for _, hit := range hits {
+		ids = append(ids, hit.ID)
+	}
+	// c is in both rankings; b and d tie at rank 2 and keep their first appearance
+	if want := []string{"c", "a", "b", "d"}; !slices.Equal(ids, want) {
+		t.Errorf("fused order = %v, want %v", ids, want)
+	}
+	if want := 1.0/61 + 1.0/63; hits[0].Score != want {
+		t.Errorf("score of c = %v, want %v", hits[0].Score, want)
+	}
+	if hits := reciprocalRankFusion(); len(hits) != 0 {
+		t.Errorf("fusing nothing = %v", hits)
+	}
+}
+
+// newErrorCodeApp returns a testApp on a fake backend whose semantic ranking
+// for the query "ERR_4021" is fixed: crash-1, crash-2 and leak, in that order,
+// with the only memory that contains the code, gateway, unrelated to it.
+func newErrorCodeApp(t *testing.T) *testApp {
+	t.Helper()
+	ta := newSyntheticApp(t, nil, syntheticEmbedder{
+		"ERR_4021":                         angled(1, 1),
+		"the app crashes on startup":       angled(0.9, 1),
+		"startup crash reported by users":  angled(0.8, 2),
+		"memory leak during sync":          angled(0.7, 3),
+		"the gateway logs ERR_4021 hourly": angled(0, 4),
+	})
+	ta.remember(t, "crash-1", "the app crashes on startup", nil)
+	ta.remember(t, "crash-2", "startup crash reported by users", nil)
+	ta.remember(t, "leak", "memory leak during sync", nil)
+	ta.remember(t, "gateway", "the gateway logs ERR_4021 hourly", nil)
+	return ta
+}
+
+func TestSearchModesFindErrorCode(t *testing.T) {
+	ta := newErrorCodeApp(t)
+	for _, tc := range []struct {
+		mode  string
+		want  []string
+		score string
+	}{
+		{"", []string{"crash-1", "crash-2", "leak"}, "(Sim: "},
+		{"semantic", []string{"crash-1", "crash-2", "leak"}, "(Sim: "},
+		{"keyword", []string{"gateway"}, "(Keyword: "},
+		// gateway is first in the keyword ranking and last in the semantic
+		// one: 1/61 + 1/64 beats crash-1's 1/61 alone
+		{"hybrid", []string{"gateway", "crash-1", "crash-2"}, "(RRF: 0.0320)"},
+	} {
+		text, isErr := call(t, ta.searchHandler, map[string]any{"query": "ERR_4021", "mode": tc.mode, "max_results": 3.0})
+		if isErr {
+			t.Fatalf("search_memory mode %q: %s", tc.mode, text)
+		}
+		if got := advancedIDs(text); !slices.Equal(got, tc.want) {
+			t.Errorf("mode %q found %v, want %v", tc.mode, got, tc.want)
+		}
+		if !strings.Contains(text, tc.score) {
+			t.Errorf("mode %q does not show %q:\n%s", tc.mode, tc.score, text)
+		}
+	}
+
+	text, isErr := call(t, ta.searchHandler, map[string]any{"query": "ERR_4021", "mode": "fuzzy"})
+	if !isErr || !strings.Contains(text, "mode must be") {
+		t.Errorf("search_memory mode fuzzy = %q, want an error", text)
+	}
+}
+
+func TestSearchAdvancedHybridOrdering(t *testing.T) {
+	ta := newErrorCodeApp(t)
+	text, isErr := call(t, ta.searchAdvancedHandler, map[string]any{"query": "ERR_4021", "mode": "hybrid", "max_results": 4.0})
+	if isErr {
+		t.Fatalf("search_advanced: %s", text)
+	}
+	if got, want := advancedIDs(text), []string{"gateway", "crash-1", "crash-2", "leak"}; !slices.Equal(got, want) {
+		t.Errorf("search_advanced hybrid found %v, want %v", got, want)
+	}
+
+	text, _ = call(t, ta.searchAdvancedHandler, map[string]any{"query": "ERR_4021", "mode": "keyword"})
+	if got := advancedIDs(text); !slices.Equal(got, []string{"gateway"}) {
+		t.Errorf("search_advanced keyword found %v, want gateway only", got)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import "sync"
+
+// idLocks serializes work on the same ID while letting work on different IDs
+// run in parallel. The zero value is ready to use; a lock is dropped once no
+// one holds or waits for it.
+type idLocks struct {
+	mu    sync.Mutex
+	locks map[string]*idLock
+}
+
+// idLock is the lock of one ID and the number of callers holding or waiting
+// for it.
+type idLock struct {
+	sync.Mutex
+	refs int
+}
+
+// Lock locks id and returns the function that unlocks it.
+func (l *idLocks) Lock(id string) (unlock func()) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*idLock)
+	}
+	lock := l.locks[id]
+	if lock == nil {
+		lock = &idLock{}
+		l.locks[id] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+		l.mu.Lock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, id)
+		}
+		l.mu.Unlock()
+	}
+}
@@ -0,0 +1,364 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mergeEpoch is the creation time of the first version in the fixtures.
+var mergeEpoch = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+
+// fixtureVersion returns a version written by machine at mergeEpoch plus hours.
+func fixtureVersion(n int, content, machine string, hours int) MemoryVersion {
+	return MemoryVersion{VersionNumber: n, Content: content, CreatedAt: mergeEpoch.Add(time.Duration(hours) * time.Hour), CreatedBy: machine}
+}
+
+// fixtureHistory returns the history of id with versions, the last one current.
+func fixtureHistory(id string, versions ...MemoryVersion) MemoryWithHistory {
+	return MemoryWithHistory{
+		ID:             id,
+		CurrentVersion: len(versions),
+		Versions:       versions,
+		Context:        DefaultContextID,
+		Tags:           []string{"sync"},
+		CreatedAt:      versions[0].CreatedAt,
+		UpdatedAt:      versions[len(versions)-1].CreatedAt,
+		Metadata:       map[string]string{},
+	}
+}
+
+// divergedFixtures returns the local histories of two machines that share the
+// first version of every memory, and the export of the other machine:
+// "new" only exists there, "same" is unchanged, "ahead" gained a version on
+// the other machine, "behind" gained one locally and "split" was edited on
+// both.
+func divergedFixtures() (local, incoming *ExportData) {
+	base := func(id string) MemoryVersion { return fixtureVersion(1, id+" v1", "laptop", 0) }
+	local = &ExportData{Memories: []MemoryWithHistory{
+		fixtureHistory("same", base("same")),
+		fixtureHistory("ahead", base("ahead")),
+		fixtureHistory("behind", base("behind"), fixtureVersion(2, "behind local v2", "laptop", 1)),
+		fixtureHistory("split", base("split"), fixtureVersion(2, "split local v2", "laptop", 2)),
+	}}
+	incoming = &ExportData{Memories: []MemoryWithHistory{
+		fixtureHistory("new", fixtureVersion(1, "new v1", "desktop", 1)),
+		fixtureHistory("same", base("same")),
+		fixtureHistory("ahead", base("ahead"), fixtureVersion(2, "ahead incoming v2", "desktop", 1)),
+		fixtureHistory("behind", base("behind")),
+		fixtureHistory("split", base("split"), fixtureVersion(2, "split incoming v2", "desktop", 3), fixtureVersion(3, "split incoming v3", "desktop", 4)),
+	}}
+	return local, incoming
+}
+
+// newMergeManager returns a version manager holding the local fixtures.
+func newMergeManager(t *testing.T, local *ExportData) *MemoryVersionManager {
+	t.Helper()
+	m, err := NewMemoryVersionManager(t.TempDir(), nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("NewMemoryVersionManager: %v", err)
+	}
+	if err := m.ImportMemories(local); err != nil {
+		t.Fatalf("ImportMemories: %v", err)
+	}
+	return m
+}
+
+// contents returns the contents of the versions of history.
+func contents(history MemoryWithHistory) []string {
+	var out []string
+	for _, v := range history.Versions {
+		out = append(out, v.Content)
+	}
+	return out
+}
+
+func TestPreviewImportClassifies(t *testing.T) {
+	local, incoming := divergedFixtures()
+	m := newMergeManager(t, local)
+
+	preview := m.PreviewImport(incoming)
+	want := map[string]string{
+		"new":    ImportClassNew,
+		"same":   ImportClassIdentical,
+		"ahead":  ImportClassFastForward,
+		"behind": ImportClassStale,
+		"split":  ImportClassConflict,
+	}
+	for _, item := range preview.Items {
+		if item.Class != want[item.ID] {
+			t.Errorf("%s classified as %s, want %s", item.ID, item.Class, want[item.ID])
+		}
+		if item.ID == "split" && (item.CommonVersions != 1 || item.LocalVersions != 2 || item.IncomingVersions != 3) {
+			t.Errorf("split: common %d, local %d, incoming %d; want 1, 2, 3", item.CommonVersions, item.LocalVersions, item.IncomingVersions)
+		}
+	}
+	for class, n := range preview.Counts {
+		if n != 1 {
+			t.Errorf("count of %s = %d, want 1", class, n)
+		}
+	}
+
+	// The preview changes nothing
+	if history, _ := m.ExportMemory("split", true); len(history.Versions) != 2 {
+		t.Errorf("preview changed the local history: %v", contents(history))
+	}
+	if _, ok := m.ExportMemory("new", true); ok {
+		t.Error("preview imported a new memory")
+	}
+}
+
+func TestCommitImportStrategies(t *testing.T) {
+	for _, tc := range []struct {
+		strategy     ImportStrategy
+		wantSplit    []string
+		wantCurrent  string
+		splitChanged bool
+	}{
+		{ImportStrategy{Conflict: MergeKeepLocal}, []string{"split v1", "split local v2"}, "split local v2", false},
+		{ImportStrategy{Conflict: MergeKeepIncoming}, []string{"split v1", "split incoming v2", "split incoming v3"}, "split incoming v3", true},
+		{ImportStrategy{Conflict: MergeAppend}, []string{"split v1", "split local v2", "split incoming v2", "split incoming v3"}, "split incoming v3", true},
+	} {
+		t.Run(tc.strategy.Conflict, func(t *testing.T) {
+			local, incoming := divergedFixtures()
+			m := newMergeManager(t, local)
+
+			result, changed, err := m.CommitImport(incoming, tc.strategy)
+			if err != nil {
+				t.Fatalf("CommitImport: %v", err)
+			}
+
+			split, _ := m.ExportMemory("split", true)
+			if got := contents(split); !slices.Equal(got, tc.wantSplit) {
+				t.Errorf("split history = %q, want %q", got, tc.wantSplit)
+			}
+			if got := split.CurrentContent(); got != tc.wantCurrent {
+				t.Errorf("split current content = %q, want %q", got, tc.wantCurrent)
+			}
+			if slices.Contains(changed, "split") != tc.splitChanged {
+				t.Errorf("changed = %v; split changed should be %v", changed, tc.splitChanged)
+			}
+
+			// The other classes do not depend on the conflict strategy
+			if ahead, _ := m.ExportMemory("ahead", true); !slices.Equal(contents(ahead), []string{"ahead v1", "ahead incoming v2"}) {
+				t.Errorf("ahead was not fast-forwarded: %q", contents(ahead))
+			}
+			if behind, _ := m.ExportMemory("behind", true); len(behind.Versions) != 2 {
+				t.Errorf("the newer local history of behind was replaced: %q", contents(behind))
+			}
+			if _, ok := m.ExportMemory("new", true); !ok {
+				t.Error("new was not imported")
+			}
+			for _, id := range []string{"same", "behind"} {
+				if slices.Contains(changed, id) {
+					t.Errorf("%s reported as changed", id)
+				}
+			}
+			if result.Counts[ImportClassConflict] != 1 {
+				t.Errorf("conflict count = %d, want 1", result.Counts[ImportClassConflict])
+			}
+		})
+	}
+}
+
+func TestMergeAnnotatesAppendedVersions(t *testing.T) {
+	local, incoming := divergedFixtures()
+	m := newMergeManager(t, local)
+	if _, _, err := m.CommitImport(incoming, ImportStrategy{Conflict: MergeAppend}); err != nil {
+		t.Fatalf("CommitImport: %v", err)
+	}
+
+	split, _ := m.ExportMemory("split", true)
+	for i, v := range split.Versions {
+		if v.VersionNumber != i+1 {
+			t.Errorf("version %d is numbered %d", i+1, v.VersionNumber)
+		}
+	}
+	merged := split.Versions[2:]
+	for i, v := range merged {
+		if want := "Merged from import (incoming version " + string(rune('2'+i)) + ")"; !strings.HasPrefix(v.ChangeNote, want) {
+			t.Errorf("change note of merged version %d = %q, want prefix %q", v.VersionNumber, v.ChangeNote, want)
+		}
+	}
+}
+
+// When the local edit is the newest, merging keeps it current.
+func TestMergeKeepsNewestLocalVersionCurrent(t *testing.T) {
+	local, incoming := divergedFixtures()
+	split := &local.Memories[3]
+	split.Versions[1].CreatedAt = mergeEpoch.Add(10 * time.Hour)
+	m := newMergeManager(t, local)
+
+	_, changed, err := m.CommitImport(incoming, ImportStrategy{Conflict: MergeAppend})
+	if err != nil {
+		t.Fatalf("CommitImport: %v", err)
+	}
+	history, _ := m.ExportMemory("split", true)
+	if got := history.CurrentContent(); got != "split local v2" {
+		t.Errorf("current content = %q, want the newer local edit", got)
+	}
+	if len(history.Versions) != 4 {
+		t.Errorf("history has %d versions, want 4", len(history.Versions))
+	}
+	if slices.Contains(changed, "split") {
+		t.Error("split reported as changed although its current content is unchanged")
+	}
+}
+
+func TestCommitImportSkipsByClass(t *testing.T) {
+	local, incoming := divergedFixtures()
+	m := newMergeManager(t, local)
+
+	result, changed, err := m.CommitImport(incoming, ImportStrategy{New: "skip", FastForward: "skip"})
+	if err != nil {
+		t.Fatalf("CommitImport: %v", err)
+	}
+	if len(changed) != 0 {
+		t.Errorf("changed = %v, want nothing", changed)
+	}
+	if _, ok := m.ExportMemory("new", true); ok {
+		t.Error("new was imported although new memories are skipped")
+	}
+	actions := map[string]string{}
+	for _, item := range result.Items {
+		actions[item.ID] = item.Action
+	}
+	if actions["new"] != "skipped" || actions["ahead"] != "skipped" || actions["split"] != "kept_local" {
+		t.Errorf("actions = %v", actions)
+	}
+}
+
+// importJSON runs import_memories on export with the extra arguments.
+func (ta *testApp) importJSON(t *testing.T, export *ExportData, extra map[string]any) (string, bool) {
+	t.Helper()
+	data, err := json.Marshal(export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := map[string]any{"json_data": string(data)}
+	for k, v := range extra {
+		args[k] = v
+	}
+	return call(t, ta.importMemoriesHandler, args)
+}
+
+func TestImportConflictStrategies(t *testing.T) {
+	incoming := func() *ExportData {
+		shared := fixtureHistory("shared", fixtureVersion(1, "incoming shared v1", "desktop", 0), fixtureVersion(2, "incoming shared v2", "desktop", 1))
+		shared.Context = "projects"
+		shared.Tags = []string{"from-desktop"}
+		return &ExportData{Version: ExportFormatVersion, Memories: []MemoryWithHistory{
+			shared,
+			fixtureHistory("fresh", fixtureVersion(1, "fresh from the desktop", "desktop", 0)),
+		}}
+	}
+
+	for _, tc := range []struct {
+		strategy    string
+		wantShared  []string // History of "shared" after the import
+		wantRenamed bool     // Whether "shared-imported" holds the incoming memory
+		wantSummary string
+	}{
+		{ConflictSkip, []string{"local shared"}, false, "Created: 1, updated: 0, skipped: 1, failed: 0"},
+		{ConflictOverwrite, []string{"incoming shared v1", "incoming shared v2"}, false, "Created: 1, updated: 1, skipped: 0, failed: 0"},
+		{ConflictRename, []string{"local shared"}, true, "Created: 2, updated: 0, skipped: 0, failed: 0"},
+	} {
+		t.Run(tc.strategy, func(t *testing.T) {
+			ta := newTestApp(t, nil)
+			ta.remember(t, "shared", "local shared", nil)
+
+			text, isErr := ta.importJSON(t, incoming(), map[string]any{"conflict_strategy": tc.strategy})
+			if isErr || !strings.Contains(text, tc.wantSummary) {
+				t.Fatalf("import_memories = %q, want %q", text, tc.wantSummary)
+			}
+
+			if got := contents(*ta.history(t, "shared")); !slices.Equal(got, tc.wantShared) {
+				t.Errorf("shared history = %q, want %q", got, tc.wantShared)
+			}
+			doc, err := ta.vectorStore.GetByID(t.Context(), "shared")
+			if err != nil || doc.Content != tc.wantShared[len(tc.wantShared)-1] {
+				t.Errorf("stored shared = %q, %v", doc.Content, err)
+			}
+
+			renamed, err := ta.vectorStore.GetByID(t.Context(), "shared-imported")
+			if (err == nil) != tc.wantRenamed {
+				t.Errorf("shared-imported stored = %v, want %v", err == nil, tc.wantRenamed)
+			}
+			if tc.wantRenamed {
+				if renamed.Content != "incoming shared v2" || renamed.Metadata["context"] != "projects" {
+					t.Errorf("shared-imported = %q in %q", renamed.Content, renamed.Metadata["context"])
+				}
+				if !strings.Contains(text, `"renamed_from": "shared"`) {
+					t.Errorf("the rename is not reported:\n%s", text)
+				}
+			}
+
+			if _, err := ta.vectorStore.GetByID(t.Context(), "fresh"); err != nil {
+				t.Errorf("fresh was not imported: %v", err)
+			}
+			// Contexts and tags of imported memories are created; a skipped memory creates none
+			_, contextErr := ta.ctx.GetContext("projects")
+			_, tagErr := ta.ctx.GetTag("from-desktop")
+			if imported := tc.strategy != ConflictSkip; (contextErr == nil) != imported || (tagErr == nil) != imported {
+				t.Errorf("context projects: %v, tag from-desktop: %v; want them created = %v", contextErr, tagErr, imported)
+			}
+		})
+	}
+
+	t.Run("rename twice", func(t *testing.T) {
+		ta := newTestApp(t, nil)
+		ta.remember(t, "shared", "local shared", nil)
+		for i := range 2 {
+			// Identical content would be screened out as an exact duplicate
+			export := incoming()
+			export.Memories[0].Versions[1].Content += fmt.Sprintf(" (import %d)", i+1)
+			if text, isErr := ta.importJSON(t, export, map[string]any{"conflict_strategy": ConflictRename}); isErr {
+				t.Fatalf("import_memories: %s", text)
+			}
+		}
+		if got := ta.history(t, "shared-imported-2").CurrentContent(); got != "incoming shared v2 (import 2)" {
+			t.Errorf("second rename holds %q", got)
+		}
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ta := newTestApp(t, nil)
+		text, isErr := ta.importJSON(t, incoming(), map[string]any{"conflict_strategy": "replace"})
+		if !isErr || !strings.Contains(text, "conflict_strategy must be") {
+			t.Errorf("import_memories with an unknown strategy = %q", text)
+		}
+		if _, err := ta.vectorStore.GetByID(t.Context(), "fresh"); err == nil {
+			t.Error("a rejected import stored memories")
+		}
+	})
+}
+
+func TestImportedContextsBelongToImporter(t *testing.T) {
+	ta := newTestApp(t, nil)
+	importer, stranger := newClientID("Importer App"), newClientID("Stranger App")
+	memory := fixtureHistory("plan", fixtureVersion(1, "the imported plan", "desktop", 0))
+	memory.Context = "projects"
+	data, err := json.Marshal(&ExportData{Version: ExportFormatVersion, Memories: []MemoryWithHistory{memory}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if text, isErr := callAs(t, WithClientID(t.Context(), importer), ta.importMemoriesHandler, map[string]any{"json_data": string(data)}); isErr {
+		t.Fatalf("import_memories: %s", text)
+	}
+	c, err := ta.ctx.GetContext("projects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.OwnerClientID != importer {
+		t.Errorf("imported context owner = %q, want %q", c.OwnerClientID, importer)
+	}
+	if text, isErr := callAs(t, WithClientID(t.Context(), stranger), ta.getMemoryHandler, map[string]any{"id": "plan"}); !isErr {
+		t.Errorf("another client read the imported memory: %s", text)
+	}
+}
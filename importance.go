@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// memoryImportance returns a memory's importance from 0 to 1. Memories pinned
+// without an importance, e.g. through retention, count as fully important.
+func memoryImportance(metadata map[string]string) float64 {
+	if v, err := strconv.ParseFloat(metadata["importance"], 64); err == nil {
+		return min(max(v, 0), 1)
+	}
+	if isPinned(metadata) {
+		return 1
+	}
+	return 0
+}
+
+// isStarred reports whether list_memories marks a memory as pinned.
+func isStarred(metadata map[string]string) bool {
+	return memoryImportance(metadata) >= PinnedImportance
+}
+
+// boostByImportance multiplies each result's score by 1 + its importance and
+// re-sorts them, so important memories rank above equally relevant ones.
+func boostByImportance(results []chromem.Result) []chromem.Result {
+	boosted := false
+	for i := range results {
+		if importance := memoryImportance(results[i].Metadata); importance > 0 {
+			results[i].Similarity *= float32(1 + importance)
+			boosted = true
+		}
+	}
+	if boosted {
+		sort.SliceStable(results, func(i, j int) bool { return results[i].Similarity > results[j].Similarity })
+	}
+	return results
+}
+
+// setImportance stores importance on a memory, and pins or unpins it when
+// pinned is given. The embedding is kept; only the metadata is rewritten.
+func (a *App) setImportance(ctx context.Context, id string, importance float64, pinned *bool) (string, error) {
+	doc, err := a.vectorStore.GetByID(ctx, id)
+	if err != nil {
+		return "", fmt.Errorf("memory '%s' not found: %w", id, err)
+	}
+	if msg := a.memoryAccessDenied(ctx, doc); msg != "" {
+		return "", errors.New(msg)
+	}
+	if isChunk(doc.Metadata) {
+		return "", fmt.Errorf("'%s' is a chunk of memory '%s'; set the importance of the memory instead", id, doc.Metadata["parent_id"])
+	}
+
+	metadata := make(map[string]string, len(doc.Metadata)+2)
+	for k, v := range doc.Metadata {
+		metadata[k] = v
+	}
+	metadata["importance"] = strconv.FormatFloat(importance, 'f', -1, 64)
+	if pinned != nil {
+		if *pinned {
+			metadata["pinned"] = "true"
+		} else {
+			delete(metadata, "pinned")
+		}
+	}
+	contextID := metadata["context"]
+	if contextID == "" {
+		contextID = DefaultContextID
+	}
+	if err := a.vectorStore.UpdateMetadata(ctx, id, metadata); err != nil {
+		a.recordAudit(ctx, AuditEntry{Tool: "set_importance", MemoryIDs: []string{id}, ContextID: contextID, ClientID: a.clientIDFrom(ctx), Status: "error", Details: err.Error()})
+		return "", fmt.Errorf("failed to update memory '%s': %w", id, err)
+	}
+	return contextID, nil
+}
+
+// pinMemoryHandler handles the pin_memory tool - pins a memory, giving it full
+// importance and exempting it from retention, or unpins it.
+func (a *App) pinMemoryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	id, _ := args["memory_id"].(string)
+	if id = strings.TrimSpace(id); id == "" {
+		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
+	}
+	pinned := true
+	if v, ok := args["pinned"].(bool); ok {
+		pinned = v
+	}
+
+	importance := 1.0
+	if !pinned {
+		importance = 0
+	}
+	contextID, err := a.setImportance(ctx, id, importance, &pinned)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if !pinned {
+		a.recordAudit(ctx, AuditEntry{Tool: "pin_memory", MemoryIDs: []string{id}, ContextID: contextID, ClientID: a.clientIDFrom(ctx), Status: "ok", Details: "unpinned"})
+		return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' unpinned.", id)), nil
+	}
+	a.recordAudit(ctx, AuditEntry{Tool: "pin_memory", MemoryIDs: []string{id}, ContextID: contextID, ClientID: a.clientIDFrom(ctx), Status: "ok", Details: "pinned"})
+	return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' pinned.", id)), nil
+}
+
+// setImportanceHandler handles the set_importance tool.
+func (a *App) setImportanceHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	id, _ := args["memory_id"].(string)
+	if id = strings.TrimSpace(id); id == "" {
+		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
+	}
+	importance, ok := args["importance"].(float64)
+	if !ok || importance < 0 || importance > 1 {
+		return mcp.NewToolResultError("importance must be a number from 0 to 1"), nil
+	}
+
+	contextID, err := a.setImportance(ctx, id, importance, nil)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	a.recordAudit(ctx, AuditEntry{Tool: "set_importance", MemoryIDs: []string{id}, ContextID: contextID, ClientID: a.clientIDFrom(ctx), Status: "ok", Details: fmt.Sprintf("importance %g", importance)})
+	return mcp.NewToolResultText(fmt.Sprintf("Importance of memory '%s' set to %g.", id, importance)), nil
+}
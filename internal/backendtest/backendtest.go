@@ -0,0 +1,491 @@
+// Package backendtest is the conformance suite for vector backends: the
+// behavior every store behind brainmcp's VectorBackend must provide. Tests
+// run it with Run; the server runs it with Check before selecting a backend.
+package backendtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+)
+
+// Backend is the part of a vector backend the suite exercises.
+type Backend interface {
+	AddDocument(ctx context.Context, document chromem.Document) error
+	AddDocuments(ctx context.Context, documents []chromem.Document, concurrency int) error
+	Query(ctx context.Context, queryText string, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error)
+	QueryEmbedding(ctx context.Context, queryEmbedding []float32, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error)
+	GetByID(ctx context.Context, id string) (chromem.Document, error)
+	UpdateMetadata(ctx context.Context, id string, metadata map[string]string) error
+	Delete(ctx context.Context, where, whereDocument map[string]string, ids ...string) error
+	ClearAll(ctx context.Context) error
+	Count() int
+	ListDocuments(ctx context.Context, where map[string]string, offset, limit int) ([]chromem.Document, error)
+	ListByTag(ctx context.Context, tag string) ([]chromem.Document, error)
+	MutationStamp() uint64
+}
+
+// Factory creates an empty, throwaway backend that embeds with embed. The
+// returned cleanup function releases everything the backend created.
+type Factory func(embed chromem.EmbeddingFunc) (Backend, func(), error)
+
+// ErrSkipped is returned by a Factory when no throwaway instance of the
+// backend is available (e.g. an opt-in live service).
+var ErrSkipped = errors.New("conformance suite skipped")
+
+// Scenario is one behavior every backend must provide.
+type Scenario struct {
+	Name string
+	Run  func(ctx context.Context, backend Backend) error
+}
+
+// Scenarios is the behavior shared by all backends. Callers add scenarios
+// that need more than the Backend interface, such as migration.
+var Scenarios = []Scenario{
+	{"add_get_delete", conformAddGetDelete},
+	{"upsert", conformUpsert},
+	{"update_metadata", conformUpdateMetadata},
+	{"metadata_filter", conformMetadataFilter},
+	{"list_by_tag", conformListByTag},
+	{"query_ordering", conformQueryOrdering},
+	{"list_pagination", conformListPagination},
+	{"clear_all", conformClearAll},
+	{"mutation_stamp", conformMutationStamp},
+	{"concurrent_access", conformConcurrentAccess},
+}
+
+// Run runs every scenario, followed by extra, as a subtest against a fresh
+// backend from factory. The test is skipped if factory returns ErrSkipped.
+func Run(t *testing.T, factory Factory, extra ...Scenario) {
+	t.Helper()
+	for _, scenario := range append(slices.Clone(Scenarios), extra...) {
+		t.Run(scenario.Name, func(t *testing.T) {
+			backend, cleanup, err := factory(Embedding)
+			if errors.Is(err, ErrSkipped) {
+				t.Skip(err)
+			}
+			if err != nil {
+				t.Fatalf("failed to create backend: %v", err)
+			}
+			defer cleanup()
+			if err := scenario.Run(context.Background(), backend); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// Result is the outcome of a single scenario.
+type Result struct {
+	Scenario string
+	Err      error
+	Duration time.Duration
+}
+
+// Report is the outcome of running the suite against one backend.
+type Report struct {
+	Backend string
+	Skipped string // Reason the suite could not run; empty if it ran
+	Results []Result
+	RanAt   time.Time
+}
+
+// Passed reports whether every scenario succeeded. A skipped suite passes.
+func (r Report) Passed() bool {
+	for _, res := range r.Results {
+		if res.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Summary returns a one-line description of the report.
+func (r Report) Summary() string {
+	if r.Skipped != "" {
+		return fmt.Sprintf("%s: skipped (%s)", r.Backend, r.Skipped)
+	}
+	passed := 0
+	for _, res := range r.Results {
+		if res.Err == nil {
+			passed++
+		}
+	}
+	return fmt.Sprintf("%s: %d/%d scenarios passed", r.Backend, passed, len(r.Results))
+}
+
+// String returns the summary followed by one line per scenario.
+func (r Report) String() string {
+	var sb strings.Builder
+	sb.WriteString(r.Summary() + "\n")
+	for _, res := range r.Results {
+		status := "ok"
+		if res.Err != nil {
+			status = "FAIL: " + res.Err.Error()
+		}
+		sb.WriteString(fmt.Sprintf("  %-18s %s (%s)\n", res.Scenario, status, res.Duration.Round(time.Millisecond)))
+	}
+	return sb.String()
+}
+
+// Check runs every scenario, followed by extra, against a fresh backend from
+// factory and reports the outcome under name. Scenarios never share an
+// instance, so one failure cannot cascade.
+func Check(ctx context.Context, name string, factory Factory, extra ...Scenario) Report {
+	report := Report{Backend: name, RanAt: time.Now().UTC()}
+	for _, scenario := range append(slices.Clone(Scenarios), extra...) {
+		start := time.Now()
+		backend, cleanup, err := factory(Embedding)
+		if errors.Is(err, ErrSkipped) {
+			report.Skipped = strings.TrimPrefix(err.Error(), ErrSkipped.Error()+": ")
+			report.Results = nil
+			return report
+		}
+		if err == nil {
+			err = scenario.Run(ctx, backend)
+			cleanup()
+		} else {
+			err = fmt.Errorf("failed to create backend: %w", err)
+		}
+		report.Results = append(report.Results, Result{
+			Scenario: scenario.Name,
+			Err:      err,
+			Duration: time.Since(start),
+		})
+	}
+	return report
+}
+
+// Dimension is the size of the vectors produced by Embedding.
+const Dimension = 64
+
+// Embedding is a deterministic bag-of-words embedder, so the suite needs no
+// embedding provider and texts sharing words are similar.
+func Embedding(_ context.Context, text string) ([]float32, error) {
+	vec := make([]float32, Dimension)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		h.Write([]byte(word))
+		vec[h.Sum32()%Dimension]++
+	}
+	if vecmath.Norm(vec) == 0 {
+		vec[0] = 1
+	}
+	if err := vecmath.Normalize(vec); err != nil {
+		return nil, err
+	}
+	return vec, nil
+}
+
+func conformAddGetDelete(ctx context.Context, b Backend) error {
+	doc := chromem.Document{ID: "doc-1", Content: "alpha bravo", Metadata: map[string]string{"context": "work", "tags": "x,y"}}
+	if err := b.AddDocument(ctx, doc); err != nil {
+		return fmt.Errorf("AddDocument: %w", err)
+	}
+	got, err := b.GetByID(ctx, "doc-1")
+	if err != nil {
+		return fmt.Errorf("GetByID after add: %w", err)
+	}
+	if got.Content != doc.Content || got.Metadata["context"] != "work" || got.Metadata["tags"] != "x,y" {
+		return fmt.Errorf("GetByID returned %q %v, want %q %v", got.Content, got.Metadata, doc.Content, doc.Metadata)
+	}
+	if len(got.Embedding) != Dimension {
+		return fmt.Errorf("GetByID returned a %d-component embedding, want %d", len(got.Embedding), Dimension)
+	}
+	if n := b.Count(); n != 1 {
+		return fmt.Errorf("Count after add = %d, want 1", n)
+	}
+	if err := b.Delete(ctx, nil, nil, "doc-1"); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+	if _, err := b.GetByID(ctx, "doc-1"); err == nil {
+		return errors.New("GetByID found a deleted document")
+	}
+	if n := b.Count(); n != 0 {
+		return fmt.Errorf("Count after delete = %d, want 0", n)
+	}
+	return nil
+}
+
+func conformUpsert(ctx context.Context, b Backend) error {
+	if err := b.AddDocument(ctx, chromem.Document{ID: "doc-1", Content: "first", Metadata: map[string]string{"context": "a"}}); err != nil {
+		return fmt.Errorf("AddDocument: %w", err)
+	}
+	if err := b.AddDocument(ctx, chromem.Document{ID: "doc-1", Content: "second", Metadata: map[string]string{"context": "b"}}); err != nil {
+		return fmt.Errorf("AddDocument with existing ID: %w", err)
+	}
+	got, err := b.GetByID(ctx, "doc-1")
+	if err != nil {
+		return fmt.Errorf("GetByID: %w", err)
+	}
+	if got.Content != "second" || got.Metadata["context"] != "b" {
+		return fmt.Errorf("GetByID returned %q %v, want the second write", got.Content, got.Metadata)
+	}
+	if n := b.Count(); n != 1 {
+		return fmt.Errorf("Count after overwriting = %d, want 1", n)
+	}
+	return nil
+}
+
+func conformUpdateMetadata(ctx context.Context, b Backend) error {
+	if err := b.AddDocument(ctx, chromem.Document{ID: "doc-1", Content: "alpha bravo", Metadata: map[string]string{"context": "a"}}); err != nil {
+		return fmt.Errorf("AddDocument: %w", err)
+	}
+	before, err := b.GetByID(ctx, "doc-1")
+	if err != nil {
+		return fmt.Errorf("GetByID: %w", err)
+	}
+	if err := b.UpdateMetadata(ctx, "doc-1", map[string]string{"context": "b", "tags": "x"}); err != nil {
+		return fmt.Errorf("UpdateMetadata: %w", err)
+	}
+	got, err := b.GetByID(ctx, "doc-1")
+	if err != nil {
+		return fmt.Errorf("GetByID after update: %w", err)
+	}
+	if got.Content != before.Content || got.Metadata["context"] != "b" || got.Metadata["tags"] != "x" {
+		return fmt.Errorf("GetByID returned %q %v, want %q with the new metadata", got.Content, got.Metadata, before.Content)
+	}
+	if !slices.Equal(got.Embedding, before.Embedding) {
+		return errors.New("UpdateMetadata changed the embedding")
+	}
+	if listed, err := b.ListDocuments(ctx, map[string]string{"context": "a"}, 0, 0); err != nil || len(listed) != 0 {
+		return fmt.Errorf("filtering on the old metadata returned %v (err %v), want none", documentIDs(listed), err)
+	}
+	if listed, err := b.ListDocuments(ctx, map[string]string{"context": "b"}, 0, 0); err != nil || len(listed) != 1 {
+		return fmt.Errorf("filtering on the new metadata returned %v (err %v), want [doc-1]", documentIDs(listed), err)
+	}
+	if err := b.UpdateMetadata(ctx, "missing", map[string]string{"context": "b"}); err == nil {
+		return errors.New("UpdateMetadata of a missing document succeeded")
+	}
+	return nil
+}
+
+func conformMetadataFilter(ctx context.Context, b Backend) error {
+	docs := []chromem.Document{
+		{ID: "a-1", Content: "alpha one", Metadata: map[string]string{"context": "a"}},
+		{ID: "a-2", Content: "alpha two", Metadata: map[string]string{"context": "a"}},
+		{ID: "b-1", Content: "bravo one", Metadata: map[string]string{"context": "b"}},
+	}
+	if err := b.AddDocuments(ctx, docs, 1); err != nil {
+		return fmt.Errorf("AddDocuments: %w", err)
+	}
+	query, _ := Embedding(ctx, "one")
+	results, err := b.QueryEmbedding(ctx, query, 2, map[string]string{"context": "a"}, nil)
+	if err != nil {
+		return fmt.Errorf("QueryEmbedding with filter: %w", err)
+	}
+	if len(results) != 2 {
+		return fmt.Errorf("filtered query returned %d results, want 2", len(results))
+	}
+	for _, res := range results {
+		if res.Metadata["context"] != "a" {
+			return fmt.Errorf("filtered query returned %s from context %q", res.ID, res.Metadata["context"])
+		}
+	}
+	listed, err := b.ListDocuments(ctx, map[string]string{"context": "b"}, 0, 0)
+	if err != nil {
+		return fmt.Errorf("ListDocuments with filter: %w", err)
+	}
+	if len(listed) != 1 || listed[0].ID != "b-1" {
+		return fmt.Errorf("filtered listing returned %v, want [b-1]", documentIDs(listed))
+	}
+	if err := b.Delete(ctx, map[string]string{"context": "a"}, nil); err != nil {
+		return fmt.Errorf("Delete with filter: %w", err)
+	}
+	if n := b.Count(); n != 1 {
+		return fmt.Errorf("Count after filtered delete = %d, want 1", n)
+	}
+	return nil
+}
+
+func conformListByTag(ctx context.Context, b Backend) error {
+	docs := []chromem.Document{
+		{ID: "t-3", Content: "charlie", Metadata: map[string]string{"context": "a", "tags": "Go, web"}},
+		{ID: "t-1", Content: "alpha", Metadata: map[string]string{"context": "a", "tags": "go"}},
+		{ID: "t-2", Content: "bravo", Metadata: map[string]string{"context": "a", "tags": "golang,cargo"}},
+		{ID: "t-4", Content: "delta", Metadata: map[string]string{"context": "a"}},
+		{ID: "t-5", Content: "echo", Metadata: map[string]string{"context": "a", "tags": `["go, web"]`}},
+		{ID: "t-6", Content: "foxtrot", Metadata: map[string]string{"context": "a", "tags": `["Rust","Go"]`}},
+	}
+	if err := b.AddDocuments(ctx, docs, 1); err != nil {
+		return fmt.Errorf("AddDocuments: %w", err)
+	}
+	tagged, err := b.ListByTag(ctx, "go")
+	if err != nil {
+		return fmt.Errorf("ListByTag: %w", err)
+	}
+	// "golang" and "cargo" contain "go" but are other tags, as is the JSON-encoded "go, web"
+	if got := strings.Join(documentIDs(tagged), ","); got != "t-1,t-3,t-6" {
+		return fmt.Errorf("ListByTag(go) = [%s], want [t-1,t-3,t-6]", got)
+	}
+	if err := b.UpdateMetadata(ctx, "t-1", map[string]string{"context": "a", "tags": "rust"}); err != nil {
+		return fmt.Errorf("UpdateMetadata: %w", err)
+	}
+	if tagged, err = b.ListByTag(ctx, "go"); err != nil || len(tagged) != 2 {
+		return fmt.Errorf("ListByTag(go) after retagging = %v (err %v), want [t-3 t-6]", documentIDs(tagged), err)
+	}
+	if tagged, err = b.ListByTag(ctx, "missing"); err != nil || len(tagged) != 0 {
+		return fmt.Errorf("ListByTag(missing) = %v (err %v), want none", documentIDs(tagged), err)
+	}
+	return nil
+}
+
+func conformQueryOrdering(ctx context.Context, b Backend) error {
+	docs := []chromem.Document{
+		{ID: "match", Content: "alpha bravo charlie", Metadata: map[string]string{"context": "a"}},
+		{ID: "partial", Content: "alpha kilo lima", Metadata: map[string]string{"context": "a"}},
+		{ID: "other", Content: "xray yankee zulu", Metadata: map[string]string{"context": "a"}},
+	}
+	if err := b.AddDocuments(ctx, docs, 1); err != nil {
+		return fmt.Errorf("AddDocuments: %w", err)
+	}
+	results, err := b.Query(ctx, "alpha bravo charlie", 3, nil, nil)
+	if err != nil {
+		return fmt.Errorf("Query: %w", err)
+	}
+	if len(results) != 3 {
+		return fmt.Errorf("Query returned %d results, want 3", len(results))
+	}
+	if results[0].ID != "match" || results[1].ID != "partial" {
+		return fmt.Errorf("Query returned %s, %s, %s; want match, partial, other", results[0].ID, results[1].ID, results[2].ID)
+	}
+	for i := 1; i < len(results); i++ {
+		if results[i].Similarity > results[i-1].Similarity {
+			return fmt.Errorf("results are not ordered by similarity: %.4f after %.4f", results[i].Similarity, results[i-1].Similarity)
+		}
+	}
+	return nil
+}
+
+func conformListPagination(ctx context.Context, b Backend) error {
+	for i := 4; i >= 0; i-- {
+		id := "m" + strconv.Itoa(i)
+		if err := b.AddDocument(ctx, chromem.Document{ID: id, Content: "memory " + id, Metadata: map[string]string{"context": "a"}}); err != nil {
+			return fmt.Errorf("AddDocument %s: %w", id, err)
+		}
+	}
+	pages := []struct {
+		offset, limit int
+		want          string
+	}{
+		{0, 0, "m0,m1,m2,m3,m4"},
+		{1, 2, "m1,m2"},
+		{4, 10, "m4"},
+		{5, 2, ""},
+	}
+	for _, page := range pages {
+		docs, err := b.ListDocuments(ctx, nil, page.offset, page.limit)
+		if err != nil {
+			return fmt.Errorf("ListDocuments(%d, %d): %w", page.offset, page.limit, err)
+		}
+		if got := strings.Join(documentIDs(docs), ","); got != page.want {
+			return fmt.Errorf("ListDocuments(%d, %d) = [%s], want [%s]", page.offset, page.limit, got, page.want)
+		}
+	}
+	return nil
+}
+
+func conformClearAll(ctx context.Context, b Backend) error {
+	docs := []chromem.Document{
+		{ID: "c-1", Content: "alpha", Metadata: map[string]string{"context": "a"}},
+		{ID: "c-2", Content: "bravo", Metadata: map[string]string{"context": "b"}},
+	}
+	if err := b.AddDocuments(ctx, docs, 1); err != nil {
+		return fmt.Errorf("AddDocuments: %w", err)
+	}
+	if err := b.ClearAll(ctx); err != nil {
+		return fmt.Errorf("ClearAll: %w", err)
+	}
+	if n := b.Count(); n != 0 {
+		return fmt.Errorf("Count after ClearAll = %d, want 0", n)
+	}
+	if listed, err := b.ListDocuments(ctx, nil, 0, 0); err != nil || len(listed) != 0 {
+		return fmt.Errorf("ListDocuments after ClearAll = %v (err %v), want none", documentIDs(listed), err)
+	}
+	if err := b.AddDocument(ctx, docs[0]); err != nil {
+		return fmt.Errorf("AddDocument after ClearAll: %w", err)
+	}
+	if n := b.Count(); n != 1 {
+		return fmt.Errorf("Count after re-adding = %d, want 1", n)
+	}
+	return nil
+}
+
+func conformMutationStamp(ctx context.Context, b Backend) error {
+	before := b.MutationStamp()
+	if err := b.AddDocument(ctx, chromem.Document{ID: "s-1", Content: "alpha", Metadata: map[string]string{"context": "a"}}); err != nil {
+		return fmt.Errorf("AddDocument: %w", err)
+	}
+	afterAdd := b.MutationStamp()
+	if err := b.Delete(ctx, nil, nil, "s-1"); err != nil {
+		return fmt.Errorf("Delete: %w", err)
+	}
+	afterDelete := b.MutationStamp()
+
+	// Backends that cannot track mutations report 0 throughout
+	if before == 0 && afterAdd == 0 && afterDelete == 0 {
+		return nil
+	}
+	if afterAdd == before || afterDelete == afterAdd {
+		return fmt.Errorf("mutation stamp did not change: %d, %d, %d", before, afterAdd, afterDelete)
+	}
+	return nil
+}
+
+func conformConcurrentAccess(ctx context.Context, b Backend) error {
+	const workers, perWorker = 8, 5
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				doc := chromem.Document{ID: id, Content: fmt.Sprintf("worker %d item %d", w, i), Metadata: map[string]string{"context": "a"}}
+				if err := b.AddDocument(ctx, doc); err != nil {
+					errs <- fmt.Errorf("AddDocument %s: %w", id, err)
+					return
+				}
+				if _, err := b.GetByID(ctx, id); err != nil {
+					errs <- fmt.Errorf("GetByID %s: %w", id, err)
+					return
+				}
+				if _, err := b.Query(ctx, "worker item", 1, nil, nil); err != nil {
+					errs <- fmt.Errorf("Query: %w", err)
+					return
+				}
+				b.Count()
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	if err := <-errs; err != nil {
+		return err
+	}
+	if n := b.Count(); n != workers*perWorker {
+		return fmt.Errorf("Count after concurrent adds = %d, want %d", n, workers*perWorker)
+	}
+	return nil
+}
+
+// documentIDs returns the IDs of docs in order.
+func documentIDs(docs []chromem.Document) []string {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids
+}
@@ -0,0 +1,32 @@
+package backendtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+func TestCheckReportsSkipsAndFailures(t *testing.T) {
+	skipped := func(chromem.EmbeddingFunc) (Backend, func(), error) {
+		return nil, nil, fmt.Errorf("%w: no server", ErrSkipped)
+	}
+	report := Check(context.Background(), "remote", skipped)
+	if report.Skipped != "no server" || !report.Passed() || len(report.Results) != 0 {
+		t.Errorf("skipped suite reported %+v", report)
+	}
+
+	broken := func(chromem.EmbeddingFunc) (Backend, func(), error) {
+		return nil, nil, errors.New("connection refused")
+	}
+	extra := Scenario{Name: "extra", Run: func(context.Context, Backend) error { return nil }}
+	report = Check(context.Background(), "broken", broken, extra)
+	if report.Passed() || len(report.Results) != len(Scenarios)+1 {
+		t.Fatalf("broken backend reported %s", report)
+	}
+	if last := report.Results[len(report.Results)-1]; last.Scenario != "extra" || last.Err == nil {
+		t.Errorf("extra scenario reported %+v", last)
+	}
+}
@@ -0,0 +1,132 @@
+// Package vecmath is the vector math shared by the embedders, the vector
+// backends and the embedding playground. Sums are accumulated in float64 so
+// long vectors stay accurate.
+package vecmath
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+var (
+	// ErrZeroVector is returned for vectors that cannot be normalized.
+	ErrZeroVector = errors.New("vector has zero magnitude")
+	// ErrNonFiniteVector is returned for vectors containing NaN or Inf, which
+	// would otherwise poison every similarity score computed from them.
+	ErrNonFiniteVector = errors.New("vector contains NaN or Inf")
+	// ErrNotUnitVector is returned when a vector is not unit length after
+	// normalization, e.g. because its components underflowed.
+	ErrNotUnitVector = errors.New("vector is not unit length after normalization")
+)
+
+// UnitTolerance is how far a norm may be from 1 for the vector to count as unit length.
+const UnitTolerance = 1e-3
+
+// CheckFinite returns an error if any component of v is NaN or Inf.
+func CheckFinite(v []float32) error {
+	for i, x := range v {
+		if math.IsNaN(float64(x)) || math.IsInf(float64(x), 0) {
+			return fmt.Errorf("%w (component %d is %v)", ErrNonFiniteVector, i, x)
+		}
+	}
+	return nil
+}
+
+// Dot returns the dot product of a and b, which must have the same length.
+func Dot(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// Norm returns the L2 norm of v.
+func Norm(v []float32) float64 {
+	return math.Sqrt(Dot(v, v))
+}
+
+// Normalize scales v in place to unit L2 norm, so embeddings lie on the unit
+// sphere and dot products equal cosine similarities. Vectors containing NaN or
+// Inf and zero vectors are rejected and left unchanged.
+func Normalize(v []float32) error {
+	if err := CheckFinite(v); err != nil {
+		return err
+	}
+	magnitude := Norm(v)
+	if magnitude == 0 {
+		return ErrZeroVector
+	}
+	for i := range v {
+		v[i] = float32(float64(v[i]) / magnitude)
+	}
+	return nil
+}
+
+// Validate normalizes v in place and checks that the result is a
+// usable unit vector.
+func Validate(v []float32) error {
+	if err := Normalize(v); err != nil {
+		return err
+	}
+	if !IsUnit(v) {
+		return fmt.Errorf("%w (norm %.4f)", ErrNotUnitVector, Norm(v))
+	}
+	return nil
+}
+
+// IsUnit reports whether v is L2-normalized within UnitTolerance.
+func IsUnit(v []float32) bool {
+	return math.Abs(Norm(v)-1) < UnitTolerance
+}
+
+// Cosine returns the cosine similarity of a and b, which must have
+// the same length. It is 0 if either vector is zero and is clamped to [-1, 1]
+// against rounding.
+func Cosine(a, b []float32) float64 {
+	normA, normB := Norm(a), Norm(b)
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return max(-1, min(1, Dot(a, b)/(normA*normB)))
+}
+
+// Truncate returns the first dim components of v renormalized to unit
+// length, for embedding models whose leading dimensions form a usable smaller
+// embedding. v is not modified.
+func Truncate(v []float32, dim int) ([]float32, error) {
+	if dim <= 0 || dim > len(v) {
+		return nil, fmt.Errorf("cannot truncate a %d-dimensional vector to %d dimensions", len(v), dim)
+	}
+	truncated := append([]float32(nil), v[:dim]...)
+	if err := Normalize(truncated); err != nil {
+		return nil, err
+	}
+	return truncated, nil
+}
+
+// Mean returns the normalized mean of vectors, which must all have the
+// same length. The inputs are not modified.
+func Mean(vectors [][]float32) ([]float32, error) {
+	if len(vectors) == 0 {
+		return nil, ErrZeroVector
+	}
+	sum := make([]float64, len(vectors[0]))
+	for _, v := range vectors {
+		if len(v) != len(sum) {
+			return nil, fmt.Errorf("cannot average a %d-dimensional vector with %d-dimensional ones", len(v), len(sum))
+		}
+		for i, x := range v {
+			sum[i] += float64(x)
+		}
+	}
+	mean := make([]float32, len(sum))
+	for i, x := range sum {
+		mean[i] = float32(x / float64(len(vectors)))
+	}
+	if err := Validate(mean); err != nil {
+		return nil, err
+	}
+	return mean, nil
+}
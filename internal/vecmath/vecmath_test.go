@@ -0,0 +1,182 @@
+package vecmath
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+	"testing/quick"
+)
+
+// propertyConfig runs each property against this many random inputs.
+var propertyConfig = &quick.Config{MaxCount: 2000}
+
+// isZero reports whether every component of v is 0.
+func isZero(v []float32) bool {
+	return !slices.ContainsFunc(v, func(x float32) bool { return x != 0 })
+}
+
+func TestNormalizeGivesUnitNorm(t *testing.T) {
+	property := func(v []float32) bool {
+		err := Normalize(v)
+		if isZero(v) {
+			return errors.Is(err, ErrZeroVector)
+		}
+		return err == nil && math.Abs(Norm(v)-1) < 1e-5
+	}
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNormalizeIsIdempotent(t *testing.T) {
+	property := func(v []float32) bool {
+		if Normalize(v) != nil {
+			return true
+		}
+		once := slices.Clone(v)
+		if err := Normalize(v); err != nil {
+			return false
+		}
+		for i := range v {
+			if math.Abs(float64(v[i]-once[i])) > 1e-6 {
+				return false
+			}
+		}
+		return true
+	}
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCosineIsSymmetricAndBounded(t *testing.T) {
+	property := func(a, b []float32) bool {
+		n := min(len(a), len(b))
+		a, b = a[:n], b[:n]
+		ab, ba := Cosine(a, b), Cosine(b, a)
+		return ab == ba && ab >= -1 && ab <= 1
+	}
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCosineIgnoresScale(t *testing.T) {
+	property := func(v []float32, scale float32) bool {
+		if isZero(v) || scale == 0 {
+			return true
+		}
+		// Keep the scaled components finite
+		scale = float32(math.Copysign(1+math.Mod(math.Abs(float64(scale)), 100), float64(scale)))
+		scaled := make([]float32, len(v))
+		for i, x := range v {
+			scaled[i] = float32(float64(x) / 1e3 * float64(scale))
+		}
+		if isZero(scaled) {
+			return true
+		}
+		want := math.Copysign(1, float64(scale))
+		return math.Abs(Cosine(v, scaled)-want) < 1e-5 && math.Abs(Cosine(v, v)-1) < 1e-5
+	}
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDotOfUnitVectorsIsCosine(t *testing.T) {
+	property := func(a, b []float32) bool {
+		n := min(len(a), len(b))
+		a, b = slices.Clone(a[:n]), slices.Clone(b[:n])
+		want := Cosine(a, b)
+		if Normalize(a) != nil || Normalize(b) != nil {
+			return true
+		}
+		return math.Abs(Dot(a, b)-want) < 1e-5
+	}
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRejectsZeroAndNonFiniteVectors(t *testing.T) {
+	nan, inf := float32(math.NaN()), float32(math.Inf(1))
+	for _, tc := range []struct {
+		name string
+		v    []float32
+		want error
+	}{
+		{"empty", []float32{}, ErrZeroVector},
+		{"zero", []float32{0, 0, 0}, ErrZeroVector},
+		{"NaN", []float32{1, nan, 0}, ErrNonFiniteVector},
+		{"+Inf", []float32{inf, 1, 0}, ErrNonFiniteVector},
+		{"-Inf", []float32{0, 1, -inf}, ErrNonFiniteVector},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v := slices.Clone(tc.v)
+			if err := Normalize(v); !errors.Is(err, tc.want) {
+				t.Errorf("Normalize err = %v, want %v", err, tc.want)
+			}
+			if err := Validate(v); !errors.Is(err, tc.want) {
+				t.Errorf("Validate err = %v, want %v", err, tc.want)
+			}
+			for i := range v {
+				if math.Float32bits(v[i]) != math.Float32bits(tc.v[i]) {
+					t.Fatalf("the rejected vector was modified: %v", v)
+				}
+			}
+		})
+	}
+
+	if got := Cosine([]float32{0, 0}, []float32{1, 0}); got != 0 {
+		t.Errorf("Cosine with a zero vector = %v, want 0", got)
+	}
+	if err := CheckFinite([]float32{1, 2, 3}); err != nil {
+		t.Errorf("CheckFinite of a finite vector: %v", err)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	property := func(v []float32, dim uint8) bool {
+		d := int(dim)
+		original := slices.Clone(v)
+		out, err := Truncate(v, d)
+		if !slices.Equal(v, original) {
+			return false
+		}
+		switch {
+		case d <= 0 || d > len(v):
+			return err != nil
+		case isZero(v[:d]):
+			return errors.Is(err, ErrZeroVector)
+		}
+		return err == nil && len(out) == d && IsUnit(out)
+	}
+	if err := quick.Check(property, propertyConfig); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMean(t *testing.T) {
+	if _, err := Mean(nil); !errors.Is(err, ErrZeroVector) {
+		t.Errorf("Mean of no vectors: err = %v", err)
+	}
+	if _, err := Mean([][]float32{{1, 0}, {1, 0, 0}}); err == nil {
+		t.Error("Mean of vectors of different lengths succeeded")
+	}
+	if _, err := Mean([][]float32{{1, 0}, {-1, 0}}); !errors.Is(err, ErrZeroVector) {
+		t.Errorf("Mean of opposite vectors: err = %v, want ErrZeroVector", err)
+	}
+
+	a, b := []float32{1, 0}, []float32{0, 1}
+	mean, err := Mean([][]float32{a, b})
+	if err != nil {
+		t.Fatalf("Mean: %v", err)
+	}
+	if !IsUnit(mean) || math.Abs(Cosine(mean, a)-Cosine(mean, b)) > 1e-6 {
+		t.Errorf("Mean = %v, want a unit vector equally close to both inputs", mean)
+	}
+	if a[0] != 1 || b[1] != 1 {
+		t.Error("Mean modified its inputs")
+	}
+}
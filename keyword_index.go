@@ -0,0 +1,434 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// KeywordIndex is an inverted index over memory content used for keyword
+// matching. It is persisted to the data directory together with the backend's
+// mutation stamp, so a warm start only loads the file instead of tokenizing
+// every document again.
+type KeywordIndex struct {
+	mu        sync.RWMutex
+	postings  map[string]map[string]int // token -> document ID -> term frequency
+	docs      map[string]*indexedDoc    // document ID -> indexed form
+	stamp     uint64                    // Backend mutation stamp the index reflects
+	path      string
+	cipher    *FileCipher // Encrypts the index file when set
+	logger    *log.Logger
+	saveTimer *time.Timer
+}
+
+// indexedDoc is the persisted per-document entry of the keyword index.
+type indexedDoc struct {
+	Hash   uint64         `json:"hash"`   // Content hash used for incremental rebuilds
+	Tokens map[string]int `json:"tokens"` // Token frequencies
+}
+
+// keywordIndexFile is the on-disk representation of the index.
+type keywordIndexFile struct {
+	Version string                 `json:"version"`
+	Stamp   uint64                 `json:"stamp"`
+	Docs    map[string]*indexedDoc `json:"docs"`
+}
+
+// KeywordHit is a single keyword search result.
+type KeywordHit struct {
+	ID    string
+	Score float64
+}
+
+// NewKeywordIndex creates a keyword index persisted at path and loads any existing state.
+func NewKeywordIndex(path string, cipher *FileCipher, logger *log.Logger) *KeywordIndex {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	ki := &KeywordIndex{
+		postings: make(map[string]map[string]int),
+		docs:     make(map[string]*indexedDoc),
+		path:     path,
+		cipher:   cipher,
+		logger:   logger,
+	}
+
+	if err := ki.load(); err != nil && !os.IsNotExist(err) {
+		logger.Printf("Warning: Failed to load keyword index: %v. Rebuilding.", err)
+		ki.postings = make(map[string]map[string]int)
+		ki.docs = make(map[string]*indexedDoc)
+		ki.stamp = 0
+	}
+
+	return ki
+}
+
+// tokenize splits text into lowercase tokens. Underscores are kept so that
+// identifiers like ERR_4021 stay a single token.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
+
+// contentHash returns a stable hash of document content.
+func contentHash(content string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(content))
+	return h.Sum64()
+}
+
+// Sync brings the index up to date with the backend. When the persisted stamp
+// matches the backend's mutation stamp nothing is done; otherwise only
+// documents whose content changed are re-tokenized and removed documents are dropped.
+func (ki *KeywordIndex) Sync(ctx context.Context, backend VectorBackend) error {
+	stamp := backend.MutationStamp()
+
+	ki.mu.RLock()
+	upToDate := stamp != 0 && stamp == ki.stamp
+	indexed := len(ki.docs)
+	ki.mu.RUnlock()
+	if upToDate {
+		ki.logger.Printf("Keyword index is up to date (%d documents, stamp %d)", indexed, stamp)
+		return nil
+	}
+
+	docs, err := backend.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	ki.mu.Lock()
+	defer ki.mu.Unlock()
+
+	seen := make(map[string]bool, len(docs))
+	reindexed := 0
+	for _, doc := range docs {
+		seen[doc.ID] = true
+		hash := contentHash(doc.Content)
+		if existing, ok := ki.docs[doc.ID]; ok && existing.Hash == hash {
+			continue
+		}
+		ki.addLocked(doc.ID, doc.Content, hash)
+		reindexed++
+	}
+
+	removed := 0
+	for id := range ki.docs {
+		if !seen[id] {
+			ki.removeLocked(id)
+			removed++
+		}
+	}
+
+	ki.stamp = stamp
+	ki.logger.Printf("Keyword index synced: %d documents, %d re-indexed, %d removed", len(ki.docs), reindexed, removed)
+	if reindexed > 0 || removed > 0 {
+		ki.scheduleSaveLocked()
+	}
+	return nil
+}
+
+// Add indexes (or re-indexes) documents and records the backend stamp.
+func (ki *KeywordIndex) Add(documents []chromem.Document, stamp uint64) {
+	ki.mu.Lock()
+	defer ki.mu.Unlock()
+
+	for _, doc := range documents {
+		ki.addLocked(doc.ID, doc.Content, contentHash(doc.Content))
+	}
+	ki.stamp = stamp
+	ki.scheduleSaveLocked()
+}
+
+// Remove drops documents from the index and records the backend stamp.
+func (ki *KeywordIndex) Remove(ids []string, stamp uint64) {
+	ki.mu.Lock()
+	defer ki.mu.Unlock()
+
+	for _, id := range ids {
+		ki.removeLocked(id)
+	}
+	ki.stamp = stamp
+	ki.scheduleSaveLocked()
+}
+
+// Reset clears the whole index.
+func (ki *KeywordIndex) Reset(stamp uint64) {
+	ki.mu.Lock()
+	defer ki.mu.Unlock()
+
+	ki.postings = make(map[string]map[string]int)
+	ki.docs = make(map[string]*indexedDoc)
+	ki.stamp = stamp
+	ki.scheduleSaveLocked()
+}
+
+// Search returns documents containing any of the query tokens, scored by TF-IDF.
+func (ki *KeywordIndex) Search(query string, limit int) []KeywordHit {
+	ki.mu.RLock()
+	defer ki.mu.RUnlock()
+
+	scores := make(map[string]float64)
+	total := float64(len(ki.docs))
+	for _, token := range tokenize(query) {
+		posting := ki.postings[token]
+		if len(posting) == 0 {
+			continue
+		}
+		idf := math.Log(1 + total/float64(len(posting)))
+		for id, tf := range posting {
+			scores[id] += float64(tf) * idf
+		}
+	}
+
+	hits := make([]KeywordHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, KeywordHit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// Flush writes pending changes to disk immediately.
+func (ki *KeywordIndex) Flush() error {
+	ki.mu.Lock()
+	defer ki.mu.Unlock()
+
+	if ki.saveTimer != nil {
+		ki.saveTimer.Stop()
+		ki.saveTimer = nil
+	}
+	return ki.saveLocked()
+}
+
+// addLocked indexes one document (caller must hold the write lock).
+func (ki *KeywordIndex) addLocked(id, content string, hash uint64) {
+	ki.removeLocked(id)
+
+	tokens := make(map[string]int)
+	for _, token := range tokenize(content) {
+		tokens[token]++
+	}
+	ki.docs[id] = &indexedDoc{Hash: hash, Tokens: tokens}
+	for token, tf := range tokens {
+		posting, ok := ki.postings[token]
+		if !ok {
+			posting = make(map[string]int)
+			ki.postings[token] = posting
+		}
+		posting[id] = tf
+	}
+}
+
+// removeLocked drops one document (caller must hold the write lock).
+func (ki *KeywordIndex) removeLocked(id string) {
+	doc, ok := ki.docs[id]
+	if !ok {
+		return
+	}
+	for token := range doc.Tokens {
+		delete(ki.postings[token], id)
+		if len(ki.postings[token]) == 0 {
+			delete(ki.postings, token)
+		}
+	}
+	delete(ki.docs, id)
+}
+
+// scheduleSaveLocked debounces writes so bursts of mutations cause a single save.
+func (ki *KeywordIndex) scheduleSaveLocked() {
+	if ki.saveTimer != nil {
+		ki.saveTimer.Stop()
+	}
+	ki.saveTimer = time.AfterFunc(KeywordIndexSaveDelay, func() {
+		ki.mu.Lock()
+		defer ki.mu.Unlock()
+		ki.saveTimer = nil
+		if err := ki.saveLocked(); err != nil {
+			ki.logger.Printf("Warning: Failed to persist keyword index: %v", err)
+		}
+	})
+}
+
+// load reads the persisted index (internal, called before the index is shared).
+func (ki *KeywordIndex) load() error {
+	data, err := ki.cipher.ReadFile(ki.path)
+	if err != nil {
+		return err
+	}
+
+	var file keywordIndexFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	if file.Docs == nil {
+		file.Docs = make(map[string]*indexedDoc)
+	}
+
+	ki.docs = file.Docs
+	ki.stamp = file.Stamp
+	for id, doc := range ki.docs {
+		for token, tf := range doc.Tokens {
+			posting, ok := ki.postings[token]
+			if !ok {
+				posting = make(map[string]int)
+				ki.postings[token] = posting
+			}
+			posting[id] = tf
+		}
+	}
+
+	ki.logger.Printf("Loaded keyword index with %d documents (stamp %d)", len(ki.docs), ki.stamp)
+	return nil
+}
+
+// saveLocked writes the index atomically (caller must hold the lock).
+func (ki *KeywordIndex) saveLocked() error {
+	data, err := json.Marshal(keywordIndexFile{Version: "1", Stamp: ki.stamp, Docs: ki.docs})
+	if err != nil {
+		return fmt.Errorf("failed to marshal keyword index: %w", err)
+	}
+
+	tmpPath := ki.path + ".tmp"
+	if err := ki.cipher.WriteFile(tmpPath, data, 0600); err != nil {
+		return fmt.Errorf("failed to write keyword index: %w", err)
+	}
+	if err := os.Rename(tmpPath, ki.path); err != nil {
+		return fmt.Errorf("failed to finalize keyword index: %w", err)
+	}
+	return nil
+}
+
+// IndexedVectorStore wraps a VectorBackend and keeps a KeywordIndex and a
+// ContentHashIndex, and the MCP memory resources when set, in sync with every
+// mutation that goes through it.
+type IndexedVectorStore struct {
+	VectorBackend
+	index     *KeywordIndex
+	hashes    *ContentHashIndex
+	resources *MemoryResources // Set once the MCP server exists, nil in the CLI
+}
+
+// NewIndexedVectorStore wraps backend so that mutations write through to index and hashes.
+func NewIndexedVectorStore(backend VectorBackend, index *KeywordIndex, hashes *ContentHashIndex) *IndexedVectorStore {
+	return &IndexedVectorStore{VectorBackend: backend, index: index, hashes: hashes}
+}
+
+// AddDocuments stores documents with their content_hash metadata and indexes their content.
+func (ivs *IndexedVectorStore) AddDocuments(ctx context.Context, documents []chromem.Document, concurrency int) error {
+	documents = withContentHashes(documents)
+	if err := ivs.VectorBackend.AddDocuments(ctx, documents, concurrency); err != nil {
+		return err
+	}
+	ivs.index.Add(documents, ivs.MutationStamp())
+	ivs.hashes.Add(documents)
+	ivs.resources.Add(documents)
+	return nil
+}
+
+// AddDocument stores a single document and indexes its content.
+func (ivs *IndexedVectorStore) AddDocument(ctx context.Context, document chromem.Document) error {
+	document = withContentHashes([]chromem.Document{document})[0]
+	if err := ivs.VectorBackend.AddDocument(ctx, document); err != nil {
+		return err
+	}
+	ivs.index.Add([]chromem.Document{document}, ivs.MutationStamp())
+	ivs.hashes.Add([]chromem.Document{document})
+	ivs.resources.Add([]chromem.Document{document})
+	return nil
+}
+
+// UpdateMetadata replaces a document's metadata, keeping its content_hash, and
+// refreshes the resources listing it. The content, and so the keyword index,
+// stays the same.
+func (ivs *IndexedVectorStore) UpdateMetadata(ctx context.Context, id string, metadata map[string]string) error {
+	doc, err := ivs.VectorBackend.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	doc.Metadata = metadata
+	doc = withContentHashes([]chromem.Document{doc})[0]
+	if err := ivs.VectorBackend.UpdateMetadata(ctx, id, doc.Metadata); err != nil {
+		return err
+	}
+	ivs.index.Add([]chromem.Document{doc}, ivs.MutationStamp())
+	ivs.resources.Add([]chromem.Document{doc})
+	return nil
+}
+
+// withContentHashes returns copies of documents whose metadata carries the
+// normalized content hash. The caller's metadata maps are not modified.
+func withContentHashes(documents []chromem.Document) []chromem.Document {
+	out := make([]chromem.Document, len(documents))
+	for i, doc := range documents {
+		metadata := make(map[string]string, len(doc.Metadata)+1)
+		for k, v := range doc.Metadata {
+			metadata[k] = v
+		}
+		metadata["content_hash"] = normalizedHash(doc.Content)
+		doc.Metadata = metadata
+		out[i] = doc
+	}
+	return out
+}
+
+// Delete removes documents and drops them from the index. Filter-based
+// deletes cannot be mapped to IDs, so they trigger a resync instead.
+func (ivs *IndexedVectorStore) Delete(ctx context.Context, where, whereDocument map[string]string, ids ...string) error {
+	if err := ivs.VectorBackend.Delete(ctx, where, whereDocument, ids...); err != nil {
+		return err
+	}
+	if len(where) > 0 || len(whereDocument) > 0 {
+		if err := ivs.hashes.Rebuild(ctx, ivs.VectorBackend); err != nil {
+			return err
+		}
+		if err := ivs.resources.Sync(ctx, ivs.VectorBackend); err != nil {
+			return err
+		}
+		return ivs.index.Sync(ctx, ivs.VectorBackend)
+	}
+	ivs.index.Remove(ids, ivs.MutationStamp())
+	ivs.hashes.Remove(ids)
+	ivs.resources.Remove(ids)
+	return nil
+}
+
+// ClearAll removes all documents and resets the index.
+func (ivs *IndexedVectorStore) ClearAll(ctx context.Context) error {
+	if err := ivs.VectorBackend.ClearAll(ctx); err != nil {
+		return err
+	}
+	ivs.index.Reset(ivs.MutationStamp())
+	ivs.hashes.Reset()
+	return ivs.resources.Sync(ctx, ivs.VectorBackend)
+}
+
+// Close flushes the index before closing the wrapped backend.
+func (ivs *IndexedVectorStore) Close() error {
+	if err := ivs.index.Flush(); err != nil {
+		return err
+	}
+	return ivs.VectorBackend.Close()
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// warmStartDocuments is the corpus size of the warm-start benchmarks.
+const warmStartDocuments = 50000
+
+// stampedBackend is a VectorBackend serving a fixed document list under a
+// fixed mutation stamp and counting how often the list is read.
+type stampedBackend struct {
+	VectorBackend
+	docs  []chromem.Document
+	stamp uint64
+	lists int
+}
+
+func (b *stampedBackend) MutationStamp() uint64 { return b.stamp }
+
+func (b *stampedBackend) ListDocuments(context.Context, map[string]string, int, int) ([]chromem.Document, error) {
+	b.lists++
+	return b.docs, nil
+}
+
+func newStampedBackend(n int) *stampedBackend {
+	docs := make([]chromem.Document, n)
+	for i := range docs {
+		docs[i] = chromem.Document{
+			ID:      fmt.Sprintf("mem-%d", i),
+			Content: fmt.Sprintf("memory %d about project ERR_%d deployed to region eu-%d on host node%d", i, i%997, i%7, i%113),
+		}
+	}
+	return &stampedBackend{docs: docs, stamp: 42}
+}
+
+// persistedIndex builds the index of backend, saves it to a temporary file
+// and returns the file's path.
+func persistedIndex(tb testing.TB, backend *stampedBackend) string {
+	tb.Helper()
+	path := filepath.Join(tb.TempDir(), "keyword_index.json")
+	ki := NewKeywordIndex(path, nil, nil)
+	if err := ki.Sync(context.Background(), backend); err != nil {
+		tb.Fatalf("Sync: %v", err)
+	}
+	ki.mu.Lock()
+	if ki.saveTimer != nil {
+		ki.saveTimer.Stop()
+	}
+	err := ki.saveLocked()
+	ki.mu.Unlock()
+	if err != nil {
+		tb.Fatalf("saveLocked: %v", err)
+	}
+	return path
+}
+
+func TestKeywordIndexWarmStartSkipsListing(t *testing.T) {
+	backend := newStampedBackend(100)
+	path := persistedIndex(t, backend)
+
+	backend.lists = 0
+	ki := NewKeywordIndex(path, nil, nil)
+	if err := ki.Sync(context.Background(), backend); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if backend.lists != 0 {
+		t.Errorf("warm start listed the backend %d times, want 0", backend.lists)
+	}
+	if hits := ki.Search("ERR_5", 10); len(hits) == 0 {
+		t.Error("loaded index finds nothing for ERR_5")
+	}
+
+	backend.stamp++
+	backend.docs = backend.docs[1:]
+	if err := ki.Sync(context.Background(), backend); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if backend.lists != 1 {
+		t.Errorf("changed stamp listed the backend %d times, want 1", backend.lists)
+	}
+	if _, ok := ki.docs["mem-0"]; ok {
+		t.Error("document removed from the backend is still indexed")
+	}
+}
+
+// localBenchmarkStore returns a local store holding the documents of
+// newStampedBackend(n), so cold starts pay for listing them.
+func localBenchmarkStore(b *testing.B, n int) *LocalVectorStore {
+	b.Helper()
+	ctx := context.Background()
+	store, err := NewLocalVectorStore(filepath.Join(b.TempDir(), DefaultDBPath), nil, testEmbedding, nil, testDimension, nil)
+	if err != nil {
+		b.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	docs := newStampedBackend(n).docs
+	for i := range docs {
+		if docs[i].Embedding, err = testEmbedding(ctx, docs[i].Content); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := store.AddDocuments(ctx, docs, 8); err != nil {
+		b.Fatalf("AddDocuments: %v", err)
+	}
+	return store
+}
+
+// BenchmarkKeywordIndexWarmStart loads a persisted 50k-document index whose
+// stamp matches the local store.
+func BenchmarkKeywordIndexWarmStart(b *testing.B) {
+	store := localBenchmarkStore(b, warmStartDocuments)
+	path := filepath.Join(b.TempDir(), "keyword_index.json")
+	ctx := context.Background()
+	ki := NewKeywordIndex(path, nil, nil)
+	if err := ki.Sync(ctx, store); err != nil {
+		b.Fatal(err)
+	}
+	ki.mu.Lock()
+	if ki.saveTimer != nil {
+		ki.saveTimer.Stop()
+	}
+	if err := ki.saveLocked(); err != nil {
+		b.Fatal(err)
+	}
+	ki.mu.Unlock()
+
+	for b.Loop() {
+		ki := NewKeywordIndex(path, nil, nil)
+		if err := ki.Sync(ctx, store); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkKeywordIndexColdStart lists and tokenizes the same 50k documents
+// without a persisted index, for comparison.
+func BenchmarkKeywordIndexColdStart(b *testing.B) {
+	store := localBenchmarkStore(b, warmStartDocuments)
+	path := filepath.Join(b.TempDir(), "keyword_index.json")
+	ctx := context.Background()
+
+	for b.Loop() {
+		ki := NewKeywordIndex(path, nil, nil)
+		if err := ki.Sync(ctx, store); err != nil {
+			b.Fatal(err)
+		}
+		ki.mu.Lock()
+		if ki.saveTimer != nil {
+			ki.saveTimer.Stop()
+		}
+		ki.mu.Unlock()
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// sizedResult returns payload inline when it fits within the configured inline
+// limit. Larger payloads are written to exports/<name>-<timestamp>.<ext> in the
+// data directory, since many MCP clients truncate or fail on multi-megabyte
+// text results; the result then describes the file instead.
+func (a *App) sizedResult(name, ext string, count int, payload []byte) *mcp.CallToolResult {
+	limit := a.settings().MaxInlineResponseBytes
+	if limit <= 0 || len(payload) <= limit {
+		return mcp.NewToolResultText(string(payload))
+	}
+
+	path, err := a.writeExportFile(name, ext, payload, time.Now())
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Response of %d bytes exceeds the inline limit of %d bytes and could not be written to a file: %v", len(payload), limit, err))
+	}
+
+	sum := sha256.Sum256(payload)
+	return mcp.NewToolResultText(fmt.Sprintf(
+		"Response too large to return inline (%d bytes, limit %d); written to a file instead.\nPath: %s\nMemories: %d\nSize: %d bytes\nSHA-256: %s",
+		len(payload), limit, path, count, len(payload), hex.EncodeToString(sum[:]),
+	))
+}
+
+// responseFilePattern matches the names of the files writeExportFile writes.
+const responseFilePattern = "*-[0-9][0-9][0-9][0-9][0-9][0-9][0-9][0-9]-[0-9][0-9][0-9][0-9][0-9][0-9].[0-9]*"
+
+// writeExportFile writes payload to a new file in the exports directory,
+// encrypted like the other data files when a key is set, and returns its path.
+func (a *App) writeExportFile(name, ext string, payload []byte, now time.Time) (string, error) {
+	dir := filepath.Join(a.dataDir, "exports")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create exports directory: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.%s", name, now.UTC().Format("20060102-150405.000000000"), ext))
+	if err := a.cipher.WriteFile(path, payload, 0600); err != nil {
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return path, nil
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// errNoAnswer is returned by Generate when the model produced no candidates,
+// typically because of safety filters.
+var errNoAnswer = errors.New("no answer candidates returned")
+
+// LLMProvider generates text for ask_brain and other LLM-assisted features.
+type LLMProvider interface {
+	// Generate returns the model's reply to prompt. opts may be nil.
+	Generate(ctx context.Context, prompt string, opts *GenerateOptions) (string, error)
+	// Name identifies the provider and model in logs.
+	Name() string
+}
+
+// StreamingLLM is implemented by providers that can stream their reply.
+type StreamingLLM interface {
+	// GenerateStream works like Generate but calls onText with each piece of
+	// the reply as it arrives. If the stream breaks off after some text was
+	// produced, it returns that text together with the error.
+	GenerateStream(ctx context.Context, prompt string, opts *GenerateOptions, onText func(string)) (string, error)
+}
+
+// GenerateOptions adjusts a single generation request.
+type GenerateOptions struct {
+	// Schema requests a JSON reply conforming to the schema, using the
+	// provider's native structured output when available.
+	Schema *AnswerSchema
+	// Usage, if set, receives the token counts the provider reports.
+	Usage *TokenUsage
+	// Sampling settings; nil or zero leaves the provider's default.
+	Temperature     *float64
+	TopP            *float64
+	MaxOutputTokens int
+}
+
+// TokenUsage is the number of tokens a generation request consumed. Counts
+// are zero when the provider does not report them.
+type TokenUsage struct {
+	PromptTokens int
+	OutputTokens int
+}
+
+// GeminiLLM generates text with the Gemini API.
+type GeminiLLM struct {
+	client *genai.Client
+	model  string
+	retry  RetryPolicy
+}
+
+// NewGeminiLLM creates a Gemini-backed LLM provider. Rate limits and server
+// errors are retried according to retry.
+func NewGeminiLLM(client *genai.Client, model string, retry RetryPolicy) *GeminiLLM {
+	return &GeminiLLM{client: client, model: model, retry: retry}
+}
+
+// Name returns the provider and model name.
+func (g *GeminiLLM) Name() string {
+	return "gemini/" + g.model
+}
+
+// generateConfig returns the request config for opts, nil for plain text
+// with the model's default sampling.
+func (g *GeminiLLM) generateConfig(opts *GenerateOptions) *genai.GenerateContentConfig {
+	if opts == nil || (opts.Schema == nil && opts.Temperature == nil && opts.TopP == nil && opts.MaxOutputTokens <= 0) {
+		return nil
+	}
+	config := &genai.GenerateContentConfig{}
+	if opts.Schema != nil {
+		config.ResponseMIMEType = "application/json"
+		config.ResponseSchema = opts.Schema.GenaiSchema()
+	}
+	if opts.Temperature != nil {
+		config.Temperature = genai.Ptr(float32(*opts.Temperature))
+	}
+	if opts.TopP != nil {
+		config.TopP = genai.Ptr(float32(*opts.TopP))
+	}
+	if opts.MaxOutputTokens > 0 {
+		config.MaxOutputTokens = int32(opts.MaxOutputTokens)
+	}
+	return config
+}
+
+// recordGeminiUsage copies the token counts of resp into opts.Usage, if requested.
+func recordGeminiUsage(opts *GenerateOptions, resp *genai.GenerateContentResponse) {
+	if opts != nil && opts.Usage != nil && resp.UsageMetadata != nil {
+		opts.Usage.PromptTokens = int(resp.UsageMetadata.PromptTokenCount)
+		opts.Usage.OutputTokens = int(resp.UsageMetadata.CandidatesTokenCount)
+	}
+}
+
+// Generate runs a single GenerateContent call and returns the text of the first candidate.
+func (g *GeminiLLM) Generate(ctx context.Context, prompt string, opts *GenerateOptions) (string, error) {
+	var resp *genai.GenerateContentResponse
+	err := withRetry(ctx, g.retry, func() error {
+		var err error
+		resp, err = g.client.Models.GenerateContent(ctx, g.model, genai.Text(prompt), g.generateConfig(opts))
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	recordGeminiUsage(opts, resp)
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", errNoAnswer
+	}
+	return resp.Candidates[0].Content.Parts[0].Text, nil
+}
+
+// GenerateStream runs a GenerateContentStream call, passing each chunk's text
+// to onText. Failures before the first chunk are retried like Generate's;
+// once text was produced the stream is not restarted.
+func (g *GeminiLLM) GenerateStream(ctx context.Context, prompt string, opts *GenerateOptions, onText func(string)) (string, error) {
+	var sb strings.Builder
+	var streamErr error
+	err := withRetry(ctx, g.retry, func() error {
+		for resp, err := range g.client.Models.GenerateContentStream(ctx, g.model, genai.Text(prompt), g.generateConfig(opts)) {
+			if err != nil {
+				if sb.Len() > 0 {
+					streamErr = err
+					return nil
+				}
+				return err
+			}
+			recordGeminiUsage(opts, resp)
+			if text := resp.Text(); text != "" {
+				sb.WriteString(text)
+				onText(text)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if sb.Len() == 0 && streamErr == nil {
+		return "", errNoAnswer
+	}
+	return sb.String(), streamErr
+}
+
+// OpenAICompatLLM generates text with an OpenAI-compatible /chat/completions
+// endpoint such as LM Studio, Ollama or llama.cpp's server.
+type OpenAICompatLLM struct {
+	baseURL string
+	model   string
+	apiKey  string
+	client  *http.Client
+	retry   RetryPolicy
+}
+
+// NewOpenAICompatLLM creates a provider for the chat completions API at
+// baseURL. Rate limits, server errors and network failures are retried
+// according to retry.
+func NewOpenAICompatLLM(baseURL, model, apiKey string, retry RetryPolicy) *OpenAICompatLLM {
+	return &OpenAICompatLLM{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		model:   model,
+		apiKey:  apiKey,
+		client:  &http.Client{},
+		retry:   retry,
+	}
+}
+
+// Name returns the provider and model name.
+func (o *OpenAICompatLLM) Name() string {
+	return "openai-compat/" + o.model
+}
+
+// Generate sends prompt as a single user message and returns the first choice.
+func (o *OpenAICompatLLM) Generate(ctx context.Context, prompt string, opts *GenerateOptions) (string, error) {
+	resp, err := o.post(ctx, o.requestBody(prompt, opts))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+		} `json:"usage"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if opts != nil && opts.Usage != nil {
+		opts.Usage.PromptTokens = result.Usage.PromptTokens
+		opts.Usage.OutputTokens = result.Usage.CompletionTokens
+	}
+	if len(result.Choices) == 0 {
+		return "", errNoAnswer
+	}
+	return result.Choices[0].Message.Content, nil
+}
+
+// GenerateStream sends the request with stream enabled and reads the reply
+// as server-sent events, passing each content delta to onText.
+func (o *OpenAICompatLLM) GenerateStream(ctx context.Context, prompt string, opts *GenerateOptions, onText func(string)) (string, error) {
+	body := o.requestBody(prompt, opts)
+	body["stream"] = true
+	resp, err := o.post(ctx, body)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var sb strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		if data = strings.TrimSpace(data); data == "[DONE]" {
+			break
+		}
+		var event struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return sb.String(), fmt.Errorf("failed to decode stream event: %w", err)
+		}
+		if len(event.Choices) > 0 && event.Choices[0].Delta.Content != "" {
+			sb.WriteString(event.Choices[0].Delta.Content)
+			onText(event.Choices[0].Delta.Content)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sb.String(), fmt.Errorf("stream failed: %w", err)
+	}
+	if sb.Len() == 0 {
+		return "", errNoAnswer
+	}
+	return sb.String(), nil
+}
+
+// requestBody builds the chat completions request for prompt.
+func (o *OpenAICompatLLM) requestBody(prompt string, opts *GenerateOptions) map[string]any {
+	body := map[string]any{
+		"messages": []map[string]string{{"role": "user", "content": prompt}},
+	}
+	if o.model != "" {
+		body["model"] = o.model
+	}
+	if opts != nil && opts.Temperature != nil {
+		body["temperature"] = *opts.Temperature
+	}
+	if opts != nil && opts.TopP != nil {
+		body["top_p"] = *opts.TopP
+	}
+	if opts != nil && opts.MaxOutputTokens > 0 {
+		body["max_tokens"] = opts.MaxOutputTokens
+	}
+	if opts != nil && opts.Schema != nil {
+		body["response_format"] = map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   "answer",
+				"schema": opts.Schema,
+			},
+		}
+	}
+	return body
+}
+
+// post sends a chat completions request, retrying according to the retry
+// policy, and returns the successful response for the caller to read and close.
+func (o *OpenAICompatLLM) post(ctx context.Context, body map[string]any) (*http.Response, error) {
+	requestBody, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	var resp *http.Response
+	err = withRetry(ctx, o.retry, func() error {
+		req, err := http.NewRequestWithContext(ctx, "POST", o.baseURL+"/chat/completions", bytes.NewReader(requestBody))
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if o.apiKey != "" {
+			req.Header.Set("Authorization", "Bearer "+o.apiKey)
+		}
+
+		resp, err = o.client.Do(req)
+		if err != nil {
+			return fmt.Errorf("request failed: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			defer resp.Body.Close()
+			errBody, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return &HTTPStatusError{
+				StatusCode: resp.StatusCode,
+				RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+				Body:       strings.TrimSpace(string(errBody)),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
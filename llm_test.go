@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeChat is an OpenAI-compatible /chat/completions server answering every
+// request with reply. It records the decoded requests, and answers the first
+// ones with the error statuses in failures. Streaming requests get the reply
+// word by word as server-sent events; with breakStream set the stream breaks
+// off with a malformed event after the first word.
+type fakeChat struct {
+	mu          sync.Mutex
+	reply       string
+	failures    []int
+	breakStream bool
+	requests    []map[string]any
+	auth        []string
+}
+
+// newFakeChat starts a fakeChat replying reply and returns it with its base URL.
+func newFakeChat(t *testing.T, reply string) (*fakeChat, string) {
+	t.Helper()
+	fake := &fakeChat{reply: reply}
+	srv := httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(srv.Close)
+	return fake, srv.URL + "/v1"
+}
+
+func (f *fakeChat) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var body map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, body)
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	status := 0
+	if len(f.failures) > 0 {
+		status, f.failures = f.failures[0], f.failures[1:]
+	}
+	reply, breakStream := f.reply, f.breakStream
+	f.mu.Unlock()
+	if status != 0 {
+		http.Error(w, `{"error": {"message": "injected failure"}}`, status)
+		return
+	}
+	if body["stream"] == true {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for i, word := range strings.SplitAfter(reply, " ") {
+			if i == 1 && breakStream {
+				fmt.Fprint(w, "data: {\"choices\": [\n\n")
+				return
+			}
+			event, _ := json.Marshal(map[string]any{"choices": []any{map[string]any{"index": 0, "delta": map[string]any{"content": word}}}})
+			fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if reply == "" {
+		json.NewEncoder(w).Encode(map[string]any{"choices": []any{}})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"choices": []any{map[string]any{"index": 0, "message": map[string]any{"role": "assistant", "content": reply}, "finish_reason": "stop"}},
+		"usage":   map[string]any{"prompt_tokens": 42, "completion_tokens": 7, "total_tokens": 49},
+	})
+}
+
+// Requests returns the requests received so far, including failed ones.
+func (f *fakeChat) Requests() []map[string]any {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]map[string]any(nil), f.requests...)
+}
+
+// Auth returns the Authorization headers received so far.
+func (f *fakeChat) Auth() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.auth...)
+}
+
+func TestOpenAICompatGenerate(t *testing.T) {
+	fake, baseURL := newFakeChat(t, "The office is at 1 Main St.")
+	llm := NewOpenAICompatLLM(baseURL+"/", "local-model", "secret", RetryPolicy{})
+
+	temperature := 0.2
+	usage := &TokenUsage{}
+	answer, err := llm.Generate(t.Context(), "Where is the office?", &GenerateOptions{Temperature: &temperature, MaxOutputTokens: 256, Usage: usage})
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if answer != "The office is at 1 Main St." {
+		t.Errorf("answer = %q", answer)
+	}
+	if usage.PromptTokens != 42 || usage.OutputTokens != 7 {
+		t.Errorf("usage = %+v, want 42 prompt and 7 output tokens", usage)
+	}
+
+	req := fake.Requests()[0]
+	messages, _ := req["messages"].([]any)
+	if len(messages) != 1 {
+		t.Fatalf("messages = %v, want one user message", req["messages"])
+	}
+	if msg := messages[0].(map[string]any); msg["role"] != "user" || msg["content"] != "Where is the office?" {
+		t.Errorf("message = %v", msg)
+	}
+	if req["model"] != "local-model" || req["temperature"] != 0.2 || req["max_tokens"] != 256.0 {
+		t.Errorf("request = %v", req)
+	}
+	if _, ok := req["top_p"]; ok {
+		t.Error("unset top_p was sent")
+	}
+	if fake.Auth()[0] != "Bearer secret" {
+		t.Errorf("Authorization = %q", fake.Auth()[0])
+	}
+}
+
+func TestOpenAICompatStructuredRequest(t *testing.T) {
+	fake, baseURL := newFakeChat(t, `{"address": "1 Main St", "confidence": 1}`)
+	schema, err := ParseAnswerSchema(addressSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewOpenAICompatLLM(baseURL, "", "", RetryPolicy{}).Generate(t.Context(), "q", &GenerateOptions{Schema: schema}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	req := fake.Requests()[0]
+	if _, ok := req["model"]; ok {
+		t.Error("an empty model was sent; the server's loaded model should be used")
+	}
+	format, _ := req["response_format"].(map[string]any)
+	jsonSchema, _ := format["json_schema"].(map[string]any)
+	if format["type"] != "json_schema" || jsonSchema["schema"] == nil {
+		t.Errorf("response_format = %v", req["response_format"])
+	}
+	if fake.Auth()[0] != "" {
+		t.Errorf("Authorization sent without an API key: %q", fake.Auth()[0])
+	}
+}
+
+func TestOpenAICompatErrors(t *testing.T) {
+	fake, baseURL := newFakeChat(t, "answer")
+	fake.failures = []int{http.StatusTooManyRequests, http.StatusBadGateway}
+	if answer, err := NewOpenAICompatLLM(baseURL, "m", "", fastRetries).Generate(t.Context(), "q", nil); err != nil || answer != "answer" {
+		t.Errorf("Generate after transient failures = %q, %v", answer, err)
+	}
+	if n := len(fake.Requests()); n != 3 {
+		t.Errorf("made %d requests, want 3", n)
+	}
+
+	fake, baseURL = newFakeChat(t, "answer")
+	fake.failures = []int{http.StatusUnauthorized}
+	_, err := NewOpenAICompatLLM(baseURL, "m", "wrong", fastRetries).Generate(t.Context(), "q", nil)
+	var statusErr *HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusUnauthorized || !strings.Contains(statusErr.Body, "injected failure") {
+		t.Errorf("err = %v, want the 401 with its body", err)
+	}
+	if n := len(fake.Requests()); n != 1 {
+		t.Errorf("a 401 was retried: %d requests", n)
+	}
+
+	_, baseURL = newFakeChat(t, "")
+	if _, err := NewOpenAICompatLLM(baseURL, "m", "", RetryPolicy{}).Generate(t.Context(), "q", nil); !errors.Is(err, errNoAnswer) {
+		t.Errorf("err = %v, want errNoAnswer for a reply without choices", err)
+	}
+}
+
+func TestOpenAICompatGenerateStream(t *testing.T) {
+	fake, baseURL := newFakeChat(t, "The office is at 1 Main St.")
+	llm := NewOpenAICompatLLM(baseURL, "local-model", "", RetryPolicy{})
+
+	var pieces []string
+	answer, err := llm.GenerateStream(t.Context(), "Where is the office?", nil, func(text string) { pieces = append(pieces, text) })
+	if err != nil || answer != "The office is at 1 Main St." {
+		t.Fatalf("GenerateStream = %q, %v", answer, err)
+	}
+	if len(pieces) != 7 || strings.Join(pieces, "") != answer {
+		t.Errorf("streamed %q, want the answer word by word", pieces)
+	}
+	if fake.Requests()[0]["stream"] != true {
+		t.Error("the request did not ask for a stream")
+	}
+
+	fake.mu.Lock()
+	fake.breakStream = true
+	fake.mu.Unlock()
+	pieces = nil
+	answer, err = llm.GenerateStream(t.Context(), "Where is the office?", nil, func(text string) { pieces = append(pieces, text) })
+	if err == nil || answer != "The " || len(pieces) != 1 {
+		t.Errorf("broken stream = %q, %v after %q; want the first word and an error", answer, err, pieces)
+	}
+
+	_, baseURL = newFakeChat(t, "")
+	if _, err := NewOpenAICompatLLM(baseURL, "", "", RetryPolicy{}).GenerateStream(t.Context(), "q", nil, func(string) {}); !errors.Is(err, errNoAnswer) {
+		t.Errorf("empty stream: err = %v, want errNoAnswer", err)
+	}
+}
+
+// ask_brain answers through the OpenAI-compatible provider without Gemini.
+func TestAskBrainWithOpenAICompatLLM(t *testing.T) {
+	fake, baseURL := newFakeChat(t, "It is at 1 Main St.")
+	ta := newTestApp(t, nil)
+	ta.remember(t, "office", "The office is at 1 Main St", nil)
+	ta.llm = NewOpenAICompatLLM(baseURL, "local-model", "", RetryPolicy{})
+
+	text, isErr := call(t, ta.askBrainHandler, map[string]any{"question": "Where is the office?"})
+	if isErr || !strings.Contains(text, "It is at 1 Main St.") {
+		t.Fatalf("ask_brain = %q", text)
+	}
+	messages := fake.Requests()[0]["messages"].([]any)
+	if prompt := messages[0].(map[string]any)["content"].(string); !strings.Contains(prompt, "The office is at 1 Main St") {
+		t.Errorf("the prompt does not contain the retrieved memory:\n%s", prompt)
+	}
+}
+
+func TestOpenAICompatDefaultsToLMStudio(t *testing.T) {
+	cfg := &Config{LLMProvider: "openai", LMStudio: LMStudioConfig{BaseURL: "http://studio:1234/v1"}}
+	if err := applyDefaults(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OpenAICompat.BaseURL != "http://studio:1234/v1" {
+		t.Errorf("base URL = %q, want the LM Studio base URL", cfg.OpenAICompat.BaseURL)
+	}
+
+	cfg = &Config{}
+	if err := applyDefaults(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LLMProvider != "gemini" {
+		t.Errorf("default LLM provider = %q", cfg.LLMProvider)
+	}
+}
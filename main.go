@@ -9,7 +9,10 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -19,26 +22,73 @@ import (
 
 // App encapsulates the BrainMCP server state and dependencies.
 type App struct {
-	vectorStore  VectorBackend
-	client       *genai.Client
-	testMode     bool
-	modelName    string
-	llmModel     string
-	logger       *log.Logger
-	ctx          *ContextManager
-	versionMgr   *MemoryVersionManager
-	filterEngine *SearchFilterEngine
-	clientID     string // Default client ID for server operations
+	vectorStore       VectorBackend
+	llm               LLMProvider
+	testMode          bool
+	modelName         string
+	logger            *log.Logger
+	ctx               *ContextManager
+	versionMgr        *MemoryVersionManager
+	filterEngine      *SearchFilterEngine
+	keywordIndex      *KeywordIndex
+	embeddingProvider string                           // Provider used for stored memories
+	embedders         map[string]chromem.EmbeddingFunc // Available embedders by provider, for embed_compare/embed_inspect
+	hashIndex         *ContentHashIndex
+	audit             *AuditLogger
+	tracer            *Tracer
+	dataDir           string
+	cipher            *FileCipher // Encrypts the archive and response files in dataDir when set
+	stopMaintenance   context.CancelFunc
+	stopTelemetry     func(context.Context) error
+	location          *time.Location           // Timezone for displayed times and naked dates in filters
+	clientID          string                   // Client ID of the CLI and background tasks; MCP clients get their own, see clientIDFrom
+	clients           *ClientIdentities        // Client IDs of MCP sessions, nil in the CLI
+	activity          *ActivityLog             // Per-day activity counters for activity_report
+	purgeTrashAfter   time.Duration            // Age at which trashed memories are purged, 0 keeps them
+	s3                S3Config                 // Bucket for export_to_s3 and import_from_s3
+	contextVectors    *ContextVectors          // Context name and description embeddings for find_context
+	reembed           *ReembedQueue            // Memories with invalid stored embeddings, re-embedded by maintenance
+	conversations     *ConversationStore       // Recent exchanges of ask_brain conversations
+	resources         *MemoryResources         // Memories exposed as MCP resources, nil in the CLI
+	currentSettings   atomic.Pointer[Settings] // Settings that reload_config can change, see settings()
+	overrides         settingOverrides         // Settings given as flags, kept across reloads
+	config            *Config                  // Last loaded configuration, guarded by reloadMu
+	reloadMu          sync.Mutex
+	accesses          accessBuffer     // Memory accesses not written back yet, see recordAccess
+	accessMu          sync.Mutex       // Serializes access statistics writes, see flushAccess
+	moveLocks         idLocks          // Serializes move_memory calls per memory ID
+	startTime         time.Time        // When the server started, for brain_status
+	now               func() time.Time // Clock for memory expiry, time.Now if nil; tests replace it
 }
 
 func main() {
 	testMode := flag.Bool("t", false, "Run in interactive CLI test mode")
 	modelFlag := flag.String("model", DefaultEmbeddingModel, "Gemini embedding model")
+	embeddingDimFlag := flag.Int("embedding-dim", 0, "Output dimension of Gemini embeddings, overriding gemini.embedding_dimension (e.g. 256 or 512)")
 	llmFlag := flag.String("llm", DefaultLLMModel, "Gemini model for assisted search")
+	citeFlag := flag.Bool("cite-sources", false, "Cite source memory IDs in ask_brain answers even if cite_sources is false in config.json")
+	mmrFlag := flag.Bool("use-mmr", false, "Diversify search_memory results with maximal marginal relevance even if use_mmr is false in config.json")
+	traceBufferFlag := flag.Int("trace-buffer", DefaultTraceBufferSize, "Number of trace events kept for get_request_trace (0 disables)")
+	searchResultsFlag := flag.Int("default-search-results", DefaultSearchResults, "Default number of results for search_memory and ask_brain")
+	purgeTrashFlag := flag.Duration("purge-trash-after", 0, "Permanently delete memories that have been in the trash this long (e.g. 720h; 0 keeps them)")
+	conformanceFlag := flag.Bool("conformance", false, "Run the vector backend conformance suite against every backend and exit")
+	maxPerContextFlag := flag.Int("max-per-context", 0, "Maximum number of memories per context, overriding max_memories_per_context (0 means unlimited)")
+	forceFreshFlag := flag.Bool("force-fresh", false, "Start with an empty brain if the local database cannot be read and no backup restores it; the unreadable database is kept aside")
+	transportFlag := flag.String("transport", TransportStdio, "MCP transport: stdio, or http for Server-Sent Events on http_host:http_port")
+	systemPromptFlag := flag.String("system-prompt-file", "", "Read the ask_brain prompt template from this file instead of system_prompt in config.json")
 	flag.Parse()
 
 	ctx := context.Background()
 
+	if *transportFlag != TransportStdio && *transportFlag != TransportHTTP {
+		fmt.Fprintf(os.Stderr, "brainmcp: unknown transport %q; use %s or %s\n", *transportFlag, TransportStdio, TransportHTTP)
+		os.Exit(2)
+	}
+
+	if *conformanceFlag {
+		os.Exit(runConformance(ctx, os.Stdout))
+	}
+
 	// Initialize logger - output to stderr in test mode, file in MCP mode
 	var logger *log.Logger
 
@@ -64,9 +114,44 @@ func main() {
 	if err != nil {
 		logger.Printf("Warning: Failed to load config: %v", err)
 		// Continue with default config
-		cfg = &Config{
-			Qdrant: QdrantConfig{UseTLS: true, VectorDimension: 768},
+		cfg = DefaultConfig()
+	}
+	applyStartupOverrides(cfg, settingOverrides{embeddingDimension: *embeddingDimFlag})
+
+	// Initialize data directory (use home directory for multi-instance safety)
+	dataDir := filepath.Join(os.Getenv("HOME"), ".brainmcp")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		logger.Printf("Warning: Failed to create data directory: %v", err)
+	}
+
+	// Subcommands run instead of the server
+	if flag.Arg(0) == "migrate_encryption" {
+		os.Exit(runMigrateEncryption(cfg, dataDir, os.Stdout))
+	}
+
+	// A missing or wrong encryption key must not start an empty brain
+	cipher := NewFileCipher(encryptionPassphrase(cfg))
+	if err := checkEncryptedFiles(dataDir, auditLogPath(cfg, dataDir), cipher); err != nil {
+		logger.Printf("Failed to open data files: %v", err)
+		if !*testMode {
+			fmt.Fprintf(os.Stderr, "brainmcp: %v\n", err)
 		}
+		os.Exit(1)
+	}
+
+	// OpenTelemetry tracing, a no-op unless otel_endpoint is set
+	stopTelemetry, err := setupTelemetry(ctx, cfg.OtelEndpoint)
+	if err != nil {
+		logger.Printf("Warning: Tracing disabled: %v", err)
+		stopTelemetry = func(context.Context) error { return nil }
+	} else if cfg.OtelEndpoint != "" {
+		logger.Printf("Exporting OpenTelemetry traces to %s", cfg.OtelEndpoint)
+	}
+
+	location, err := loadTimezone(cfg.Timezone)
+	if err != nil {
+		logger.Printf("Warning: %v, using server local time", err)
+		location = time.Local
 	}
 
 	// Validate Gemini API key
@@ -75,7 +160,8 @@ func main() {
 		geminiKey = os.Getenv("GEMINI_API_KEY")
 	}
 
-	if geminiKey == "" {
+	// Local embedding providers can run without Gemini; only ask_brain needs it then
+	if geminiKey == "" && cfg.EmbeddingProvider == "gemini" {
 		if *testMode {
 			logger.Fatal("GEMINI_API_KEY environment variable or config is required")
 		}
@@ -84,61 +170,138 @@ func main() {
 	}
 
 	// Initialize Gemini client
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey: geminiKey,
-	})
+	var client *genai.Client
+	if geminiKey != "" {
+		client, err = genai.NewClient(ctx, &genai.ClientConfig{
+			APIKey: geminiKey,
+		})
+		if err != nil {
+			logger.Printf("Failed to create GenAI client: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	// Select the LLM used by ask_brain
+	var llm LLMProvider
+	switch cfg.LLMProvider {
+	case "openai":
+		logger.Printf("Using OpenAI-compatible LLM provider: %s (model: %s)", cfg.OpenAICompat.BaseURL, cfg.OpenAICompat.Model)
+		llm = NewOpenAICompatLLM(cfg.OpenAICompat.BaseURL, cfg.OpenAICompat.Model, cfg.OpenAICompat.APIKey, cfg.Gemini.RetryPolicy())
+	default:
+		if client != nil {
+			llm = NewGeminiLLM(client, *llmFlag, cfg.Gemini.RetryPolicy())
+		} else {
+			logger.Printf("Warning: GEMINI_API_KEY not set, ask_brain is unavailable")
+		}
+	}
+
+	// Create embedding function before vector store
+	embFunc, batchEmbFunc, err := newEmbedder(cfg, cfg.EmbeddingProvider, client, *modelFlag, logger)
 	if err != nil {
-		logger.Printf("Failed to create GenAI client: %v", err)
+		logger.Printf("Failed to initialize embedding provider: %v", err)
 		os.Exit(1)
 	}
 
-	// Initialize data directory (use home directory for multi-instance safety)
-	dataDir := filepath.Join(os.Getenv("HOME"), ".brainmcp")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		logger.Printf("Warning: Failed to create data directory: %v", err)
+	// Copying between backends needs the embedder to open the stores, but embeds nothing
+	if flag.Arg(0) == "migrate" {
+		os.Exit(runMigrate(ctx, cfg, flag.Args()[1:], embFunc, batchEmbFunc, logger, os.Stdout))
 	}
 
-	// Create embedding function before vector store
-	var embFunc chromem.EmbeddingFunc
-	var batchEmbFunc BatchEmbeddingFunc
-	if cfg.EmbeddingProvider == "lmstudio" {
-		logger.Printf("Using LM Studio embedding provider: %s (model: %s)", cfg.LMStudio.BaseURL, cfg.LMStudio.EmbeddingModel)
-		embFunc = makeLMStudioEmbedder(cfg.LMStudio.BaseURL, cfg.LMStudio.EmbeddingModel, logger)
-		batchEmbFunc = func(ctx context.Context, texts []string) ([][]float32, error) {
-			return batchEmbedLMStudio(ctx, cfg.LMStudio.BaseURL, cfg.LMStudio.EmbeddingModel, texts)
+	// Prometheus metrics, served on /metrics when metrics_port is set
+	var metrics *Metrics
+	if cfg.MetricsPort > 0 {
+		metrics = NewMetrics()
+		embFunc, batchEmbFunc = metrics.InstrumentEmbedder(embFunc, batchEmbFunc)
+	}
+
+	// Every configured provider is available to the embedding playground tools
+	embedders := map[string]chromem.EmbeddingFunc{cfg.EmbeddingProvider: embFunc}
+	for _, provider := range []string{"gemini", "lmstudio", "ollama"} {
+		if _, ok := embedders[provider]; ok {
+			continue
 		}
-	} else {
-		logger.Printf("Using Gemini embedding provider (model: %s)", *modelFlag)
-		embFunc = makeGeminiEmbedder(*modelFlag, client, logger)
-		batchEmbFunc = func(ctx context.Context, texts []string) ([][]float32, error) {
-			return batchEmbedGemini(ctx, client, *modelFlag, texts)
+		if f, _, err := newEmbedder(cfg, provider, client, *modelFlag, nil); err == nil {
+			embedders[provider] = f
 		}
 	}
 
-	// Initialize vector backend (supports local and Qdrant)
-	vectorStore, err := NewVectorBackend(cfg, embFunc, batchEmbFunc, logger)
+	// Initialize vector backend (supports local, Qdrant, pgvector and Redis)
+	// An unreadable local database is recovered from the newest backup rather than overwritten
+	backupDir := cfg.Backup.BackupDir
+	if backupDir == "" {
+		backupDir = filepath.Join(dataDir, "backups")
+	}
+	backend, err := openVectorBackend(cfg, embFunc, batchEmbFunc, logger, backupDir, *forceFreshFlag)
 	if err != nil {
 		logger.Printf("Failed to initialize vector backend: %v", err)
+		if !*testMode {
+			fmt.Fprintf(os.Stderr, "brainmcp: failed to initialize vector backend: %v\n", err)
+		}
 		os.Exit(1)
 	}
 
+	// Keep the keyword index in sync with every mutation; warm starts reuse the
+	// persisted index when the backend's mutation stamp is unchanged
+	keywordIndex := NewKeywordIndex(filepath.Join(dataDir, "keyword_index.json"), cipher, logger)
+	if err := keywordIndex.Sync(ctx, backend); err != nil {
+		logger.Printf("Warning: Failed to sync keyword index: %v", err)
+	}
+	hashIndex := NewContentHashIndex()
+	if err := hashIndex.Rebuild(ctx, backend); err != nil {
+		logger.Printf("Warning: Failed to build content hash index: %v", err)
+	}
+	vectorStore := NewIndexedVectorStore(backend, keywordIndex, hashIndex)
+
+	// Flags override config.json only when given explicitly
+	overrides := settingOverrides{citeSources: *citeFlag, embeddingDimension: *embeddingDimFlag, useMMR: *mmrFlag}
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "default-search-results" {
+			overrides.defaultSearchResults = *searchResultsFlag
+		}
+		if f.Name == "max-per-context" {
+			overrides.maxPerContext = maxPerContextFlag
+		}
+	})
+	if *systemPromptFlag != "" {
+		if overrides.systemPrompt, err = readPromptFile(*systemPromptFlag); err != nil {
+			logger.Fatalf("%v", err)
+		}
+	}
+
 	app := &App{
-		vectorStore: vectorStore,
-		client:      client,
-		testMode:    *testMode,
-		modelName:   *modelFlag,
-		llmModel:    *llmFlag,
-		logger:      logger,
-		clientID:    fmt.Sprintf("session-%d", os.Getpid()),
+		vectorStore:       vectorStore,
+		llm:               llm,
+		keywordIndex:      keywordIndex,
+		embeddingProvider: cfg.EmbeddingProvider,
+		embedders:         embedders,
+		hashIndex:         hashIndex,
+		testMode:          *testMode,
+		modelName:         *modelFlag,
+		logger:            logger,
+		dataDir:           dataDir,
+		cipher:            cipher,
+		tracer:            &Tracer{logger: logger, buffer: NewTraceBuffer(*traceBufferFlag)},
+		location:          location,
+		clientID:          fmt.Sprintf("session-%d", os.Getpid()),
+		purgeTrashAfter:   *purgeTrashFlag,
+		s3:                cfg.S3,
+		overrides:         overrides,
+		config:            cfg,
+		startTime:         time.Now(),
+		reembed:           NewReembedQueue(),
+		conversations:     NewConversationStore(),
+		stopTelemetry:     stopTelemetry,
 	}
+	app.currentSettings.Store(newSettings(cfg, overrides))
+	settings := app.settings()
 
 	// Initialize context manager for persistent contexts and tagging
-	contextMgr := NewContextManager(filepath.Join(dataDir, "brain_contexts.json"))
+	contextMgr := NewContextManager(filepath.Join(dataDir, "brain_contexts.json"), cipher)
 	app.ctx = contextMgr
 
 	// Initialize version manager with JSON-based storage for versioning
 	versionDir := filepath.Join(dataDir, "memory_versions")
-	versionMgr, err := NewMemoryVersionManager(versionDir, logger)
+	versionMgr, err := NewMemoryVersionManager(versionDir, cipher, logger)
 	if err != nil {
 		logger.Printf("Failed to initialize version manager: %v", err)
 		os.Exit(1)
@@ -146,7 +309,30 @@ func main() {
 	app.versionMgr = versionMgr
 
 	// Initialize search filter engine
-	app.filterEngine = NewSearchFilterEngine(versionMgr, contextMgr)
+	app.filterEngine = NewSearchFilterEngine(vectorStore, versionMgr, contextMgr)
+
+	// Audit log of every mutation, by tool calls and maintenance sweeps alike
+	if audit, err := NewAuditLogger(auditLogPath(cfg, dataDir), cipher); err != nil {
+		logger.Printf("Warning: Failed to open audit log: %v", err)
+	} else {
+		app.audit = audit
+	}
+
+	// Context description embeddings, computed lazily on first use
+	app.contextVectors = NewContextVectors(filepath.Join(dataDir, "context_vectors.json"), cipher, logger)
+
+	// Activity counters; memories stored before they existed are counted once
+	app.activity = NewActivityLog(filepath.Join(dataDir, "activity.json"), cipher, location, logger)
+	if app.activity.Empty() {
+		if err := app.activity.Backfill(ctx, backend); err != nil {
+			logger.Printf("Warning: Failed to backfill activity log: %v", err)
+		}
+	}
+
+	// Tags stored comma-separated by older versions are rewritten as JSON once
+	if err := migrateTagEncoding(ctx, vectorStore, filepath.Join(dataDir, "tags_migrated"), logger); err != nil {
+		logger.Printf("Warning: Failed to migrate tags: %v", err)
+	}
 
 	// Run in appropriate mode
 	if *testMode {
@@ -154,41 +340,153 @@ func main() {
 		return
 	}
 
-	// Initialize MCP server
-	s := server.NewMCPServer(ServerName, ServerVersion)
+	// Initialize MCP server; every connection gets its own client ID
+	app.clients = NewClientIdentities()
+	s := server.NewMCPServer(ServerName, ServerVersion,
+		server.WithToolHandlerMiddleware(app.requestIDMiddleware),
+		server.WithToolHandlerMiddleware(app.telemetryMiddleware),
+		server.WithToolHandlerMiddleware(app.clientIdentityMiddleware),
+		server.WithHooks(app.clientHooks()),
+		server.WithResourceCapabilities(false, true),
+	)
 
 	// Register all tools
 	s.AddTool(mcp.NewTool("remember",
 		mcp.WithDescription("Stores or updates information with semantic vectors for long-term recall."),
 		mcp.WithString("id", mcp.Required(), mcp.Description("Unique ID for this memory")),
 		mcp.WithString("content", mcp.Required(), mcp.Description("The text content to remember")),
-		mcp.WithString("metadata", mcp.Description("Optional metadata")),
-	), app.rememberHandler)
+		mcp.WithString("metadata", mcp.Description("Optional metadata as a JSON object, e.g. {\"project\": \"apollo\", \"priority\": 2}; each property becomes a metadata key. A plain string is stored as 'extra'")),
+		mcp.WithString("duplicate_strategy", mcp.Description("When identical content (ignoring case and whitespace) is already stored under another ID: 'skip' (default), 'link' (record the ID and tags on the existing memory) or 'overwrite' (replace the existing memory)")),
+		mcp.WithString("change_note", mcp.Description("Optional note recorded with this version in the memory's history")),
+		mcp.WithString("dedupe", mcp.Description("When a stored memory is at least near_duplicate_threshold similar: 'warn' (default, store and mention it), 'skip' (do not store) or 'merge' (update the existing memory with this content)")),
+		mcp.WithString("supersedes", mcp.Description("Comma-separated IDs of older memories this memory replaces")),
+		mcp.WithString("part_of", mcp.Description("Comma-separated IDs of memories this memory is a part of")),
+		mcp.WithString("ttl", mcp.Description("Delete the memory automatically after this long, e.g. \"24h\" or \"7d\"")),
+		mcp.WithString("expires_at", mcp.Description("Delete the memory automatically at this RFC 3339 time, instead of ttl")),
+		mcp.WithArray("tags", mcp.WithStringItems(), mcp.Description("Tags to apply, e.g. [\"work\", \"urgent\"]; lowercased, created if missing and added to the tags the memory already has")),
+		mcp.WithString("context", mcp.Description("\"auto\" lets the LLM pick the best fitting context from their names and descriptions, keeping the current context when it is unsure (default: the current context, or auto with auto_context in the config)")),
+	), metrics.Remember(app.rememberHandler))
 
 	s.AddTool(mcp.NewTool("remember_batch",
 		mcp.WithDescription("Stores multiple memories at once with semantic vectors. Efficient for bulk ingestion."),
-		mcp.WithArray("memories", mcp.Required(), mcp.Description("List of objects with 'id', 'content', and optional 'metadata'")),
-	), app.rememberBatchHandler)
+		mcp.WithArray("memories", mcp.Required(), mcp.Description("List of objects with 'id', 'content', and optional 'metadata' (a JSON object or a string, as for remember) and 'tags' (an array of tags, as for remember)")),
+		mcp.WithString("duplicate_strategy", mcp.Description("When identical content (ignoring case and whitespace) is already stored under another ID: 'skip' (default), 'link' (record the ID and tags on the existing memory) or 'overwrite' (replace the existing memory)")),
+		mcp.WithString("change_note", mcp.Description("Optional note recorded with each stored memory's new version")),
+		mcp.WithString("dedupe", mcp.Description("When a stored memory is at least near_duplicate_threshold similar to a batch member: 'warn' (default), 'skip' or 'merge'; reported per memory")),
+	), metrics.Remember(app.rememberBatchHandler))
 
 	s.AddTool(mcp.NewTool("search_memory",
-		mcp.WithDescription("Search memory using semantic similarity. Returns raw snippets."),
+		mcp.WithDescription("Search memory using semantic similarity, keyword matching or both. Returns raw snippets."),
+		mcp.WithString("query", mcp.Required(), mcp.Description("Natural language search query")),
+		mcp.WithNumber("max_results", mcp.Description(fmt.Sprintf("Maximum number of results to return (default %d, capped at %d)", settings.DefaultSearchResults, MaxSearchResultsCap))),
+		mcp.WithString("context_id", mcp.Description("Only return memories stored in this context (filters on the \"context\" metadata key)")),
+		mcp.WithString("created_after", mcp.Description("Only memories created at or after this date (YYYY-MM-DD in the server timezone, or RFC 3339)")),
+		mcp.WithString("created_before", mcp.Description("Only memories created before this date; a plain date includes that whole day")),
+		mcp.WithString("mode", mcp.Description("'semantic' (default) ranks by meaning, 'keyword' by exact word matches such as error codes or identifiers, 'hybrid' merges both rankings")),
+		mcp.WithBoolean("return_parent", mcp.Description("Return the full memory once for matching chunks of a long memory, ranked by its best chunk, instead of each chunk (default true)")),
+		mcp.WithBoolean("use_mmr", mcp.Description("Rerank with maximal marginal relevance so near-identical memories do not crowd out others (default: use_mmr in the config file)")),
+	), metrics.Search(app.searchHandler))
+
+	s.AddTool(mcp.NewTool("batch_search",
+		mcp.WithDescription("Run several semantic searches in one call. Returns a JSON object mapping each query to its results (id, similarity, context, content)."),
+		mcp.WithArray("queries", mcp.Required(), mcp.WithStringItems(), mcp.Description(fmt.Sprintf("Search queries, at most %d", MaxBatchQueries))),
+		mcp.WithNumber("max_results", mcp.Description(fmt.Sprintf("Maximum number of results per query (default %d, capped at %d)", settings.DefaultSearchResults, MaxSearchResultsCap))),
+		mcp.WithNumber("concurrency", mcp.Description(fmt.Sprintf("Queries searched at the same time (default %d)", DefaultBatchSearchConcurrency))),
+		mcp.WithString("context_id", mcp.Description("Only return memories stored in this context")),
+	), metrics.Search(app.batchSearchHandler))
+
+	s.AddTool(mcp.NewTool("find_similar",
+		mcp.WithDescription("Find the memories most similar to an existing memory, using its stored embedding as the query."),
+		mcp.WithString("memory_id", mcp.Required(), mcp.Description("ID of the memory to find neighbours of")),
+		mcp.WithNumber("max_results", mcp.Description(fmt.Sprintf("Maximum number of results to return (default %d, capped at %d)", settings.DefaultSearchResults, MaxSearchResultsCap))),
+	), metrics.Search(app.findSimilarHandler))
+
+	s.AddTool(mcp.NewTool("search_across_contexts",
+		mcp.WithDescription("Semantic search over several contexts in parallel. Results are merged, deduplicated and re-ranked by similarity."),
 		mcp.WithString("query", mcp.Required(), mcp.Description("Natural language search query")),
-	), app.searchHandler)
+		mcp.WithArray("context_ids", mcp.WithStringItems(), mcp.Description("Contexts to search (default: all contexts)")),
+		mcp.WithNumber("max_results", mcp.Description(fmt.Sprintf("Maximum number of results to return (default %d, capped at %d)", settings.DefaultSearchResults, MaxSearchResultsCap))),
+	), metrics.Search(app.searchAcrossContextsHandler))
+
+	s.AddTool(mcp.NewTool("search_advanced",
+		mcp.WithDescription("Searches memories by meaning combined with filters on context, tags, creation date and author. Without a query, returns the memories matching the filters, most recently updated first."),
+		mcp.WithString("query", mcp.Description("Optional search query; results are sorted by similarity, then recency")),
+		mcp.WithString("context_id", mcp.Description("Only memories in this context")),
+		mcp.WithArray("tags", mcp.Description("Only memories with these tags")),
+		mcp.WithString("tag_filter_mode", mcp.Description("'any' (default) or 'all' of the given tags")),
+		mcp.WithString("created_after", mcp.Description("Only memories created at or after this date (YYYY-MM-DD in the server timezone, or RFC 3339)")),
+		mcp.WithString("created_before", mcp.Description("Only memories created before this date; a plain date includes that whole day")),
+		mcp.WithString("created_by", mcp.Description("Only memories created by this client ID")),
+		mcp.WithNumber("max_results", mcp.Description(fmt.Sprintf("Maximum number of results to return (default %d, capped at %d)", settings.DefaultSearchResults, MaxSearchResultsCap))),
+		mcp.WithString("mode", mcp.Description("How the query ranks: 'semantic' (default), 'keyword' or 'hybrid'")),
+	), metrics.Search(app.searchAdvancedHandler))
 
 	s.AddTool(mcp.NewTool("ask_brain",
-		mcp.WithDescription("LLM-assisted search. Processes your question, searches memory, and provides a conversational answer based on found facts."),
+		mcp.WithDescription("LLM-assisted search. Processes your question, searches memory, and provides a conversational answer based on found facts. With a progress token the answer is streamed as progress notifications while it is generated."),
 		mcp.WithString("question", mcp.Required(), mcp.Description("The question you want to ask your memory")),
+		mcp.WithNumber("max_results", mcp.Description(fmt.Sprintf("Maximum number of memories to retrieve for the answer (default %d, capped at %d)", settings.DefaultSearchResults, MaxSearchResultsCap))),
+		mcp.WithObject("answer_schema", mcp.Description("Optional JSON schema (object with string/number/integer/boolean/array/object properties) the answer must conform to. The answer is returned as structured JSON.")),
+		mcp.WithString("context_id", mcp.Description("Only answer from memories stored in this context")),
+		mcp.WithArray("tags", mcp.WithStringItems(), mcp.Description("Only answer from memories with any of these tags")),
+		mcp.WithBoolean("include_sources", mcp.Description("Cite memory IDs inline and end the answer with a Sources list of the memories fed to the LLM and their similarity (default true, see cite_sources in the config)")),
+		mcp.WithBoolean("rerank", mcp.Description("Retrieve a larger candidate set and let the LLM pick the most relevant max_results memories before answering (default: ask_brain.rerank in the config)")),
+		mcp.WithBoolean("expand_relations", mcp.Description("Follow the supersedes and part_of relations of retrieved memories one hop: superseded memories are replaced by their successors and the memories they are part of are added (default: expand_relations in the config)")),
+		mcp.WithString("conversation_id", mcp.Description("Any ID chosen by the caller to continue a conversation: the last questions and answers under this ID are passed to the LLM so follow-up questions can refer to them")),
+		mcp.WithNumber("temperature", mcp.Description(fmt.Sprintf("Sampling temperature of the answer, 0 to 2 (default %g, see gemini.llm_temperature in the config)", settings.LLMTemperature))),
+		mcp.WithNumber("max_tokens", mcp.Description(fmt.Sprintf("Maximum length of the answer in tokens (default %d, see gemini.llm_max_output_tokens in the config)", settings.LLMMaxOutputTokens))),
 	), app.askBrainHandler)
 
+	s.AddTool(mcp.NewTool("get_memory",
+		mcp.WithDescription("Retrieves a single memory by its exact ID, including all metadata and its version count."),
+		mcp.WithString("id", mcp.Required(), mcp.Description("The unique ID of the memory to retrieve")),
+	), app.getMemoryHandler)
+
 	s.AddTool(mcp.NewTool("delete_memory",
-		mcp.WithDescription("Removes a specific memory from the brain by its ID."),
+		mcp.WithDescription("Removes a specific memory from the brain by its ID. With soft_delete enabled the memory is moved to the trash (see restore_memory); deleting a memory already in the trash removes it for good."),
 		mcp.WithString("id", mcp.Required(), mcp.Description("The unique ID of the memory to delete")),
-	), app.deleteHandler)
+	), metrics.Delete(app.deleteHandler))
+
+	s.AddTool(mcp.NewTool("forget_topic",
+		mcp.WithDescription("Delete every memory semantically related to a topic: searches the whole collection and deletes the memories at least threshold similar to the query, with their version history. By default only lists what would be deleted; pass dry_run=false to delete. With soft_delete enabled the memories are moved to the trash."),
+		mcp.WithString("query", mcp.Required(), mcp.Description("Natural language description of the topic to forget")),
+		mcp.WithNumber("threshold", mcp.Description(fmt.Sprintf("Minimum similarity of a memory to the query to be deleted (default %.2f)", DefaultForgetThreshold))),
+		mcp.WithBoolean("dry_run", mcp.Description("Only list the memories that would be deleted, with their similarity (default true)")),
+	), metrics.Delete(app.forgetTopicHandler))
+
+	s.AddTool(mcp.NewTool("restore_memory",
+		mcp.WithDescription("Move a memory back out of the trash."),
+		mcp.WithString("id", mcp.Required(), mcp.Description("ID of the deleted memory")),
+	), app.restoreMemoryHandler)
+
+	s.AddTool(mcp.NewTool("list_deleted_memories",
+		mcp.WithDescription("List the memories in the trash, most recently deleted first."),
+		mcp.WithString("context_id", mcp.Description("Only list memories deleted from this context")),
+	), app.listDeletedMemoriesHandler)
+
+	s.AddTool(mcp.NewTool("expire_memories",
+		mcp.WithDescription("Delete every memory whose ttl or expires_at has passed, with its version history, and list what was removed. The same sweep also runs on startup and every expiry_interval."),
+	), app.expireMemoriesHandler)
+
+	s.AddTool(mcp.NewTool("list_stale_memories",
+		mcp.WithDescription("List the memories not retrieved by search_memory, ask_brain or get_memory in the last stale_days days, least recently used first. Memories never accessed count from their creation."),
+		mcp.WithNumber("stale_days", mcp.Description(fmt.Sprintf("Days without access after which a memory is listed (default %d)", DefaultStaleDays))),
+		mcp.WithString("context_id", mcp.Description("Only memories in this context")),
+	), app.listStaleMemoriesHandler)
 
 	s.AddTool(mcp.NewTool("list_memories",
-		mcp.WithDescription("Returns a list of all stored memory IDs and a snippet of their content."),
+		mcp.WithDescription(fmt.Sprintf("Returns a list of all stored memory IDs and a snippet of their content. Lists larger than %d bytes are written to a file in the data directory's exports folder and the path is returned.", settings.MaxInlineResponseBytes)),
+		mcp.WithString("created_after", mcp.Description("Only list memories created at or after this date (YYYY-MM-DD in the server timezone, or RFC 3339)")),
+		mcp.WithString("created_before", mcp.Description("Only list memories created before this date; a plain date includes that whole day")),
 	), app.listHandler)
 
+	s.AddTool(mcp.NewTool("brain_status",
+		mcp.WithDescription("Shows memory, context, tag and session counts, the active providers, the vector backend and where it stores data, the server version, timezone and uptime."),
+	), app.brainStatusHandler)
+
+	s.AddTool(mcp.NewTool("get_brain_status",
+		mcp.WithDescription("Same as brain_status."),
+	), app.brainStatusHandler)
+
 	s.AddTool(mcp.NewTool("wipe_all_memories",
 		mcp.WithDescription("Completely clears the brain. Use with caution."),
 	), app.wipeHandler)
@@ -201,53 +499,383 @@ func main() {
 		mcp.WithString("description", mcp.Description("Optional description of the context")),
 	), app.createContextHandler)
 
+	s.AddTool(mcp.NewTool("update_context",
+		mcp.WithDescription("Rename a context or change its description. The context ID stays the same, so its memories are unaffected."),
+		mcp.WithString("context_id", mcp.Required(), mcp.Description("Context to update")),
+		mcp.WithString("name", mcp.Description("New human-readable name (default: unchanged)")),
+		mcp.WithString("description", mcp.Description("New description; an empty string clears it (default: unchanged)")),
+	), app.updateContextHandler)
+
+	s.AddTool(mcp.NewTool("find_context",
+		mcp.WithDescription("Suggests the contexts that best fit a text, e.g. before storing a memory. Scores blend similarity to each context's name and description with similarity to the memories it already holds, so well-described contexts match even when empty."),
+		mcp.WithString("query", mcp.Required(), mcp.Description("Text to find a context for, such as the memory about to be stored")),
+		mcp.WithNumber("max_results", mcp.Description("Number of contexts to return (default: 3)")),
+	), app.findContextHandler)
+
 	s.AddTool(mcp.NewTool("list_contexts",
 		mcp.WithDescription("List all named contexts in the brain."),
 	), app.listContextsHandler)
 
+	s.AddTool(mcp.NewTool("get_context_stats",
+		mcp.WithDescription("Get statistics for a context as JSON: memory count, tag frequencies, total and average content length, oldest and newest memory. Use context_id \"all\" for every context."),
+		mcp.WithString("context_id", mcp.Required(), mcp.Description("Context to describe, or \"all\"")),
+	), app.getContextStatsHandler)
+
+	s.AddTool(mcp.NewTool("set_context_retention",
+		mcp.WithDescription("Set or clear a context's retention policy. The maintenance sweep evicts memories older than max_age (by created_at/last_accessed_at) or beyond max_memories (oldest first). Pinned memories are exempt."),
+		mcp.WithString("context_id", mcp.Required(), mcp.Description("Context to configure")),
+		mcp.WithString("max_age", mcp.Description("Maximum age, e.g. \"7d\", \"2w\" or \"12h\"")),
+		mcp.WithNumber("max_memories", mcp.Description("Maximum number of memories to keep")),
+		mcp.WithString("action", mcp.Description("Eviction action: delete (default), archive or soft-delete")),
+		mcp.WithBoolean("clear", mcp.Description("Remove the retention policy")),
+	), app.setContextRetentionHandler)
+
 	s.AddTool(mcp.NewTool("switch_context",
 		mcp.WithDescription("Switch to a different context for organizing memories."),
 		mcp.WithString("context_id", mcp.Required(), mcp.Description("The context ID to switch to")),
-		mcp.WithString("client_id", mcp.Description("Optional client ID (uses server default if not provided)")),
+		mcp.WithString("client_id", mcp.Description("Optional client ID (default: the calling client)")),
 	), app.switchContextHandler)
 
+	s.AddTool(mcp.NewTool("move_memory",
+		mcp.WithDescription("Move a memory to another context."),
+		mcp.WithString("id", mcp.Required(), mcp.Description("ID of the memory to move")),
+		mcp.WithString("target_context_id", mcp.Required(), mcp.Description("Context to move the memory into")),
+	), app.moveMemoryHandler)
+
+	s.AddTool(mcp.NewTool("clone_memory",
+		mcp.WithDescription("Copy a memory to a new ID, e.g. as the starting point of a variant. The copy keeps the content, context, tags and embedding."),
+		mcp.WithString("source_id", mcp.Required(), mcp.Description("ID of the memory to copy")),
+		mcp.WithString("new_id", mcp.Required(), mcp.Description("ID of the copy; must not exist yet")),
+	), app.cloneMemoryHandler)
+
+	s.AddTool(mcp.NewTool("merge_contexts",
+		mcp.WithDescription("Move every memory of one context into another, e.g. to consolidate two contexts about the same topic."),
+		mcp.WithString("source_context_id", mcp.Required(), mcp.Description("Context whose memories are moved")),
+		mcp.WithString("target_context_id", mcp.Required(), mcp.Description("Context to move the memories into")),
+		mcp.WithBoolean("delete_source", mcp.Description("Delete the source context afterwards; clients working in it switch to the target (default false)")),
+	), app.mergeContextsHandler)
+
+	s.AddTool(mcp.NewTool("delete_context",
+		mcp.WithDescription("Delete a context. The default context cannot be deleted; clients working in the deleted context switch to it."),
+		mcp.WithString("context_id", mcp.Required(), mcp.Description("Context to delete")),
+		mcp.WithString("strategy", mcp.Description(fmt.Sprintf("What happens to the context's memories: '%s' (default) refuses if it holds any, '%s' moves them to '%s', '%s' deletes them with their version histories", DeleteContextAbortIfNonEmpty, DeleteContextReassign, DefaultContextID, DeleteContextCascade)),
+			mcp.Enum(DeleteContextAbortIfNonEmpty, DeleteContextReassign, DeleteContextCascade)),
+	), app.deleteContextHandler)
+
 	s.AddTool(mcp.NewTool("share_context",
 		mcp.WithDescription("Share a context with another client to enable collaboration."),
 		mcp.WithString("context_id", mcp.Required(), mcp.Description("Context to share")),
 		mcp.WithString("target_client_id", mcp.Required(), mcp.Description("Client ID to share with")),
 	), app.shareContextHandler)
 
+	s.AddTool(mcp.NewTool("unshare_context",
+		mcp.WithDescription("Revoke a client's access to a shared context. A client working in it is switched to the default context."),
+		mcp.WithString("context_id", mcp.Required(), mcp.Description("Context to stop sharing")),
+		mcp.WithString("target_client_id", mcp.Required(), mcp.Description("Client ID to revoke access from")),
+	), app.unshareContextHandler)
+
+	s.AddTool(mcp.NewTool("list_context_shares",
+		mcp.WithDescription("List the client IDs a context is shared with."),
+		mcp.WithString("context_id", mcp.Required(), mcp.Description("Context to inspect")),
+	), app.listContextSharesHandler)
+
+	// Session management tools
+	s.AddTool(mcp.NewTool("list_sessions",
+		mcp.WithDescription("List all client sessions with their current context and last activity, most recently active first."),
+	), app.listSessionsHandler)
+
+	s.AddTool(mcp.NewTool("get_session",
+		mcp.WithDescription("Show the details of a client session: current context, creation and last activity times, sharing and whether the client is connected."),
+		mcp.WithString("client_id", mcp.Required(), mcp.Description("Client ID of the session")),
+	), app.getSessionHandler)
+
+	s.AddTool(mcp.NewTool("end_session",
+		mcp.WithDescription("End a client session, e.g. a stale one left by a client that crashed. A client that is still connected starts a new session in the default context on its next call."),
+		mcp.WithString("client_id", mcp.Required(), mcp.Description("Client ID of the session to end")),
+	), app.endSessionHandler)
+
 	// Tag management tools
+	s.AddTool(mcp.NewTool("pin_memory",
+		mcp.WithDescription("Pin a memory: it gets full importance, ranks first among equally relevant search and ask_brain results, is starred in list_memories and is exempt from retention. Pass pinned=false to unpin it."),
+		mcp.WithString("memory_id", mcp.Required(), mcp.Description("ID of the memory")),
+		mcp.WithBoolean("pinned", mcp.Description("false unpins the memory and resets its importance to 0 (default true)")),
+	), app.pinMemoryHandler)
+
+	s.AddTool(mcp.NewTool("set_importance",
+		mcp.WithDescription(fmt.Sprintf("Set a memory's importance. Search and ask_brain multiply its similarity by 1 + importance; list_memories stars memories of importance %.1f or more.", PinnedImportance)),
+		mcp.WithString("memory_id", mcp.Required(), mcp.Description("ID of the memory")),
+		mcp.WithNumber("importance", mcp.Required(), mcp.Description("From 0 (default, no boost) to 1")),
+	), app.setImportanceHandler)
+
 	s.AddTool(mcp.NewTool("add_tag",
 		mcp.WithDescription("Add a tag to a memory for categorization."),
 		mcp.WithString("memory_id", mcp.Required(), mcp.Description("ID of the memory to tag")),
 		mcp.WithString("tag", mcp.Required(), mcp.Description("Tag to add")),
 	), app.addTagHandler)
 
+	s.AddTool(mcp.NewTool("remove_tag",
+		mcp.WithDescription("Remove a tag from a memory."),
+		mcp.WithString("memory_id", mcp.Required(), mcp.Description("ID of the memory to untag")),
+		mcp.WithString("tag", mcp.Required(), mcp.Description("Tag to remove")),
+	), app.removeTagHandler)
+
+	s.AddTool(mcp.NewTool("auto_tag",
+		mcp.WithDescription("Use the LLM to suggest tags for a memory and add them."),
+		mcp.WithString("memory_id", mcp.Required(), mcp.Description("ID of the memory to tag")),
+		mcp.WithNumber("max_tags", mcp.Description("Maximum number of tags to add (default 5, max 10)")),
+		mcp.WithString("tag_prefix", mcp.Description("Prefix for the generated tags, e.g. 'auto:'")),
+	), app.autoTagHandler)
+
 	s.AddTool(mcp.NewTool("create_tag",
 		mcp.WithDescription("Create a new tag definition for categorization."),
 		mcp.WithString("name", mcp.Required(), mcp.Description("Tag name")),
 		mcp.WithString("description", mcp.Description("Optional description")),
 		mcp.WithString("color", mcp.Description("Optional hex color for UI")),
+		mcp.WithString("parent_tag", mcp.Description("Existing tag to create this one under, e.g. 'work' for 'work-meetings'")),
 	), app.createTagHandler)
 
 	s.AddTool(mcp.NewTool("list_tags",
-		mcp.WithDescription("List all available tags."),
+		mcp.WithDescription("List all available tags, child tags indented below their parent."),
 	), app.listTagsHandler)
 
 	s.AddTool(mcp.NewTool("search_by_tag",
-		mcp.WithDescription("Search memories by tag."),
+		mcp.WithDescription("List the memories carrying a tag, ordered by ID. Only whole tags match: 'go' does not find memories tagged 'golang'."),
 		mcp.WithString("tag", mcp.Required(), mcp.Description("Tag to search for")),
+		mcp.WithBoolean("include_children", mcp.Description("Also list memories carrying any child tag, at any depth (default false)")),
+		mcp.WithNumber("offset", mcp.Description("Number of matching memories to skip (default 0)")),
+		mcp.WithNumber("limit", mcp.Description(fmt.Sprintf("Number of memories to return (default %d, capped at %d)", DefaultTagPageSize, MaxTagPageSize))),
 	), app.searchByTagHandler)
 
+	s.AddTool(mcp.NewTool("batch_operations",
+		mcp.WithDescription("Create or delete many memories, or add or remove tags on many memories, in one call. The vector store and version history are updated together; the result reports the outcome of every item as JSON, so some items can fail while the rest succeed."),
+		mcp.WithString("operation", mcp.Required(), mcp.Description("'create', 'delete', 'add_tags' or 'remove_tags'")),
+		mcp.WithArray("memories", mcp.Required(), mcp.Description("For create: objects with 'id', 'content' and optional 'context' and 'tags'. For the other operations: memory IDs, as strings or objects with 'id'")),
+		mcp.WithArray("tags", mcp.WithStringItems(), mcp.Description("Tags to add or remove (add_tags and remove_tags only)")),
+	), app.batchOperationsHandler)
+
+	s.AddTool(mcp.NewTool("export_memories",
+		mcp.WithDescription(fmt.Sprintf("Export memories with their version history, contexts and tags as JSON for import_memories, or as Markdown. Exports larger than %d bytes (max_inline_response_bytes) are written to a file in the data directory's exports folder; the result then gives its path, memory count, size and SHA-256.", settings.MaxInlineResponseBytes)),
+		mcp.WithArray("memory_ids", mcp.Description("IDs of the memories to export (default: all)")),
+		mcp.WithBoolean("include_versions", mcp.Description("Include every version instead of only the latest (default: false)")),
+		mcp.WithString("format", mcp.Description("'json' (default, for import_memories) or 'markdown' (one section per memory, e.g. for a notes vault)")),
+		mcp.WithString("file_path", mcp.Description("Write the export to this file in the data directory's exports folder instead of returning it; relative paths are resolved there and paths outside it are refused")),
+	), app.exportMemoriesHandler)
+
+	s.AddTool(mcp.NewTool("import_memories",
+		mcp.WithDescription("Import memories from an export, creating missing contexts and tags, and report created/updated/skipped/failed per memory. Progress is sent as notifications when the request carries a progress token. Use preview=true to classify each memory as new, identical, fast_forward (incoming extends local history), stale (local is ahead), or conflict (histories diverged) without changing anything."),
+		mcp.WithString("json_data", mcp.Description("Export JSON produced by export_memories (or use file_path)")),
+		mcp.WithString("file_path", mcp.Description("Export file in the data directory's exports folder to read instead of json_data; relative paths are resolved there and paths outside it are refused")),
+		mcp.WithBoolean("preview", mcp.Description("Only classify incoming memories, do not import (default: false)")),
+		mcp.WithString("new_strategy", mcp.Description("For new memories: 'import' (default) or 'skip'")),
+		mcp.WithString("fast_forward_strategy", mcp.Description("For fast-forward memories: 'apply' (default) or 'skip'")),
+		mcp.WithString("merge_strategy", mcp.Description("For conflicted memories: 'keep_local' (default), 'keep_incoming', or 'merge' (append incoming versions, newest becomes current)")),
+		mcp.WithString("conflict_strategy", mcp.Description("For memories whose ID already exists, overriding the history-based strategies: 'skip', 'overwrite' (replace the local memory and its history) or 'rename' (import under the ID with an '-imported' suffix)")),
+		mcp.WithString("duplicate_strategy", mcp.Description("When identical content (ignoring case and whitespace) is already stored under another ID: 'skip' (default), 'link' (record the ID and tags on the existing memory) or 'overwrite' (replace the existing memory)")),
+	), app.importMemoriesHandler)
+
+	s.AddTool(mcp.NewTool("export_to_s3",
+		mcp.WithDescription("Export memories as gzipped JSON to the S3 bucket configured in config.json (s3), under <prefix>/brain_export_<timestamp>.json.gz, and return the object's s3:// URL. Works with S3-compatible services such as MinIO and Cloudflare R2 via s3.endpoint_url."),
+		mcp.WithArray("memory_ids", mcp.Description("IDs of the memories to export (default: all)")),
+		mcp.WithBoolean("include_versions", mcp.Description("Include every version instead of only the latest (default: false)")),
+	), app.exportToS3Handler)
+
+	s.AddTool(mcp.NewTool("import_from_s3",
+		mcp.WithDescription("Import an export from S3 like import_memories. Gzipped and plain JSON exports are accepted; without key the newest export under the configured prefix is imported."),
+		mcp.WithString("key", mcp.Description("Object key in the configured bucket or an s3://bucket/key URL (default: newest export under s3.prefix)")),
+		mcp.WithBoolean("preview", mcp.Description("Only classify incoming memories, do not import (default: false)")),
+		mcp.WithString("new_strategy", mcp.Description("For new memories: 'import' (default) or 'skip'")),
+		mcp.WithString("fast_forward_strategy", mcp.Description("For fast-forward memories: 'apply' (default) or 'skip'")),
+		mcp.WithString("merge_strategy", mcp.Description("For conflicted memories: 'keep_local' (default), 'keep_incoming', or 'merge' (append incoming versions, newest becomes current)")),
+		mcp.WithString("conflict_strategy", mcp.Description("For memories whose ID already exists, overriding the history-based strategies: 'skip', 'overwrite' (replace the local memory and its history) or 'rename' (import under the ID with an '-imported' suffix)")),
+		mcp.WithString("duplicate_strategy", mcp.Description("When identical content (ignoring case and whitespace) is already stored under another ID: 'skip' (default), 'link' (record the ID and tags on the existing memory) or 'overwrite' (replace the existing memory)")),
+	), app.importFromS3Handler)
+
+	s.AddTool(mcp.NewTool("dedupe_exact",
+		mcp.WithDescription("Merge memories with identical content (ignoring case and whitespace). The oldest memory of each group is kept and the others' tags and IDs are folded into it."),
+		mcp.WithBoolean("dry_run", mcp.Description("Only list the duplicate groups without changing anything")),
+	), app.dedupeExactHandler)
+
+	s.AddTool(mcp.NewTool("find_duplicates",
+		mcp.WithDescription(fmt.Sprintf("List pairs of memories whose embeddings are at least threshold similar, most similar first, as [id_a, id_b, similarity]. Each memory is compared with its %d nearest neighbours. Nothing is changed; see consolidate_memories to merge them.", DuplicateNeighbours)),
+		mcp.WithNumber("threshold", mcp.Description("Minimum similarity of a pair (default near_duplicate_threshold, 0.95)")),
+		mcp.WithString("context_id", mcp.Description("Only compare memories in this context")),
+	), app.findDuplicatesHandler)
+
+	s.AddTool(mcp.NewTool("consolidate_memories",
+		mcp.WithDescription("Merge clusters of near-duplicate memories with the LLM. Memories in the same context that are all at least threshold similar to each other form a cluster; the LLM merges each cluster into one canonical statement, which is stored under the oldest memory with a new version, and the others are deleted with their IDs recorded in merged_ids. By default only lists the clusters and the proposed merges; pass dry_run=false to apply them."),
+		mcp.WithNumber("threshold", mcp.Description("Minimum pairwise similarity within a cluster (default near_duplicate_threshold, 0.95)")),
+		mcp.WithNumber("max_cluster_size", mcp.Description(fmt.Sprintf("Clusters with more memories than this are reported but not merged (default %d)", DefaultMaxClusterSize))),
+		mcp.WithString("context_id", mcp.Description("Only consolidate memories in this context")),
+		mcp.WithBoolean("dry_run", mcp.Description("Only list the clusters and proposed merges (default true)")),
+	), app.consolidateMemoriesHandler)
+
+	s.AddTool(mcp.NewTool("verify_storage",
+		mcp.WithDescription("Check the stored memories without changing anything: the document count, embedding dimensions and values, contexts, timestamps, chunks and context counts. Reports the problems found with example IDs."),
+	), app.verifyStorageHandler)
+
+	s.AddTool(mcp.NewTool("verify_integrity",
+		mcp.WithDescription("Rebuild the content hash and keyword indexes and report duplicates and inconsistent context counts."),
+	), app.verifyIntegrityHandler)
+
+	s.AddTool(mcp.NewTool("embed_compare",
+		mcp.WithDescription("Embed two texts without storing them and report their cosine similarity, the vector dimension and whether the vectors are normalized. Useful for diagnosing why a query does not match a memory."),
+		mcp.WithString("text_a", mcp.Required(), mcp.Description("First text")),
+		mcp.WithString("text_b", mcp.Required(), mcp.Description("Second text, always embedded as a document")),
+		mcp.WithBoolean("a_as_query", mcp.Description("Embed text_a as a search query, as search_memory does (default: false)")),
+		mcp.WithString("provider", mcp.Description("Embedding provider to use instead of the configured one: gemini, lmstudio or ollama")),
+	), app.embedCompareHandler)
+
+	s.AddTool(mcp.NewTool("compare_texts",
+		mcp.WithDescription("Embeds two texts with the configured embedder without storing them and returns their cosine similarity with a label (Very similar, Somewhat similar, Unrelated). Useful for checking embedding quality."),
+		mcp.WithString("text_a", mcp.Required(), mcp.Description("First text")),
+		mcp.WithString("text_b", mcp.Required(), mcp.Description("Second text")),
+	), app.compareTextsHandler)
+
+	s.AddTool(mcp.NewTool("scan_for_pii",
+		mcp.WithDescription("Detect email addresses, phone numbers, social security numbers and credit card numbers in a text without storing anything. Returns the kinds of personal data found."),
+		mcp.WithString("text", mcp.Required(), mcp.Description("Text to scan")),
+	), app.scanForPIIHandler)
+
+	s.AddTool(mcp.NewTool("embed_inspect",
+		mcp.WithDescription("Embed a text without storing it and show the vector's norm and leading components."),
+		mcp.WithString("text", mcp.Required(), mcp.Description("Text to embed")),
+		mcp.WithNumber("components", mcp.Description(fmt.Sprintf("Number of leading components to show (default %d, max %d)", DefaultInspectComponents, MaxInspectComponents))),
+		mcp.WithBoolean("as_query", mcp.Description("Embed the text as a search query (default: false)")),
+		mcp.WithString("provider", mcp.Description("Embedding provider to use instead of the configured one: gemini, lmstudio or ollama")),
+	), app.embedInspectHandler)
+
+	s.AddTool(mcp.NewTool("get_memory_history",
+		mcp.WithDescription(fmt.Sprintf("Show the version history of a memory: each version's number, time, author, change note and a content preview. Lists the latest %d versions.", MaxHistoryVersionsShown)),
+		mcp.WithString("memory_id", mcp.Required(), mcp.Description("ID of the memory")),
+		mcp.WithBoolean("include_content", mcp.Description("Show full version contents instead of previews")),
+		mcp.WithNumber("version", mcp.Description("Return only this version, with its full content")),
+	), app.getMemoryHistoryHandler)
+
+	s.AddTool(mcp.NewTool("restore_version",
+		mcp.WithDescription("Restore a memory to an earlier version from its history. The content is re-embedded and the restoration is recorded as a new version."),
+		mcp.WithString("memory_id", mcp.Required(), mcp.Description("ID of the memory to restore")),
+		mcp.WithNumber("version_number", mcp.Required(), mcp.Description("Version to restore (1 is the oldest)")),
+		mcp.WithString("restore_reason", mcp.Description("Optional note recorded with the new version")),
+	), app.restoreVersionHandler)
+
+	s.AddTool(mcp.NewTool("prune_versions",
+		mcp.WithDescription("Drop old versions from a memory's history, keeping only the newest ones. Remaining versions are renumbered from 1."),
+		mcp.WithString("memory_id", mcp.Description("ID of the memory to prune (required unless prune_all_versions is set)")),
+		mcp.WithNumber("keep_latest", mcp.Description(fmt.Sprintf("Number of newest versions to keep (default %d)", DefaultKeepVersions))),
+		mcp.WithBoolean("prune_all_versions", mcp.Description("Prune the history of every memory in one pass")),
+	), app.pruneVersionsHandler)
+
+	s.AddTool(mcp.NewTool("activity_report",
+		mcp.WithDescription("Show memory creations, updates and deletions and search/ask counts per day or week as a sparkline and table, followed by JSON."),
+		mcp.WithString("granularity", mcp.Description("'day' (default) or 'week' (weeks start on Monday)")),
+		mcp.WithNumber("window", mcp.Description(fmt.Sprintf("Number of days or weeks to show, ending today (default %d days or %d weeks, capped at %d)", DefaultActivityDays, DefaultActivityWeeks, MaxActivityBuckets))),
+		mcp.WithBoolean("by_context", mcp.Description("Break the counts down by context")),
+	), app.activityReportHandler)
+
+	s.AddTool(mcp.NewTool("get_request_trace",
+		mcp.WithDescription("Returns the log lines, provider calls and audit entries recorded for a request ID (shown in error messages and in each result's _meta.request_id)."),
+		mcp.WithString("request_id", mcp.Required(), mcp.Description("Request ID to look up")),
+	), app.getRequestTraceHandler)
+
+	s.AddTool(mcp.NewTool("reload_config",
+		mcp.WithDescription("Re-read config.json and apply the settings that can change at runtime (cite_sources, soft_delete, default_search_results, max_inline_response_bytes, similarity_thresholds, near_duplicate_threshold, expand_relations, ask_brain.rerank, ask_brain.rerank_candidates, chunk_size, chunk_overlap, system_prompt) without dropping the session. Changes to providers, models, the vector backend, the timezone or backups are reported as requiring a restart."),
+	), app.reloadConfigHandler)
+
+	s.AddTool(mcp.NewTool("rotate_audit_log",
+		mcp.WithDescription("Rename the audit log to <path>.<timestamp> and continue in a fresh file."),
+	), app.rotateAuditLogHandler)
+
 	s.AddTool(mcp.NewTool("save_to_disk",
 		mcp.WithDescription("Explicitly persist the database and context state to disk."),
 	), app.saveToDiskHandler)
 
+	// Prompts for common memory workflows
+	s.AddPrompt(mcp.NewPrompt("recall",
+		mcp.WithPromptDescription("Search memory for a topic and synthesize what is stored about it, citing memory IDs"),
+		mcp.WithArgument("topic", mcp.RequiredArgument(), mcp.ArgumentDescription("What to recall")),
+		mcp.WithArgument("context_id", mcp.ArgumentDescription("Only search this context")),
+	), app.recallPromptHandler)
+
+	s.AddPrompt(mcp.NewPrompt("daily_summary",
+		mcp.WithPromptDescription("Summarize the memories created on a day, today by default"),
+		mcp.WithArgument("date", mcp.ArgumentDescription("Day to summarize as YYYY-MM-DD in the server timezone (default today)")),
+		mcp.WithArgument("context_id", mcp.ArgumentDescription("Only summarize memories in this context")),
+	), app.dailySummaryPromptHandler)
+
+	s.AddPrompt(mcp.NewPrompt("save_conversation",
+		mcp.WithPromptDescription("Extract the key facts of the current conversation and remember them, updating existing memories instead of duplicating them"),
+		mcp.WithArgument("context_id", mcp.ArgumentDescription("Context to save to (default the current context)")),
+		mcp.WithArgument("focus", mcp.ArgumentDescription("What to concentrate on, e.g. 'decisions about the API'")),
+	), app.saveConversationPromptHandler)
+
+	// Expose memories as memory://<id> resources that follow every mutation
+	app.resources = NewMemoryResources(s, app.readMemoryResource)
+	if err := app.resources.Sync(ctx, vectorStore); err != nil {
+		logger.Printf("Warning: Failed to register memory resources: %v", err)
+	}
+	vectorStore.resources = app.resources
+
+	// Enforce context retention policies in the background
+	maintenanceCtx, stopMaintenance := context.WithCancel(ctx)
+	app.stopMaintenance = stopMaintenance
+	app.startMaintenance(maintenanceCtx, MaintenanceInterval)
+
+	// Periodically copy the data files to the backup directory
+	if cfg.Backup.Enabled {
+		if interval, err := cfg.Backup.IntervalDuration(); err != nil {
+			logger.Printf("Warning: Backups disabled: %v", err)
+		} else {
+			logger.Printf("Backing up to %s every %s (keeping %d)", backupDir, interval, cfg.Backup.MaxBackups)
+			app.startBackups(maintenanceCtx, interval, backupDir, cfg.Backup.MaxBackups)
+		}
+	}
+
+	// Delete memories past their ttl, on startup and then periodically
+	expiryInterval, err := cfg.ExpiryIntervalDuration()
+	if err != nil {
+		logger.Printf("Warning: %v, using %s", err, DefaultExpiryInterval)
+		expiryInterval, _ = time.ParseDuration(DefaultExpiryInterval)
+	}
+	app.startExpiry(maintenanceCtx, expiryInterval)
+
+	// Forget ask_brain conversations nobody continued
+	conversationTTL, err := cfg.ConversationTTLDuration()
+	if err != nil {
+		logger.Printf("Warning: %v, using %s", err, DefaultConversationTTL)
+		conversationTTL, _ = time.ParseDuration(DefaultConversationTTL)
+	}
+	app.startConversationCleanup(maintenanceCtx, conversationTTL)
+
+	if metrics != nil {
+		go metrics.Serve(maintenanceCtx, cfg.MetricsPort, logger)
+	}
+
 	// Setup graceful shutdown on signal
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP reloads the configuration like reload_config
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			if _, err := app.reloadConfig(ctx); err != nil {
+				logger.Printf("Warning: Config reload failed: %v", err)
+			}
+		}
+	}()
+
+	// Over HTTP the signal first drains the open connections
+	if *transportFlag == TransportHTTP {
+		err := app.serveSSE(s, cfg.HTTPHost, cfg.HTTPPort, sigChan)
+		app.gracefulShutdown()
+		if err != nil {
+			logger.Printf("Server error: %v", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Start server
 	logger.Printf("BrainMCP Server starting (version %s) on Stdio...", ServerVersion)
 	go func() {
@@ -268,6 +896,13 @@ func main() {
 func (a *App) gracefulShutdown() {
 	a.logger.Println("Shutting down...")
 
+	// Stop background maintenance before closing the stores it uses
+	if a.stopMaintenance != nil {
+		a.stopMaintenance()
+	}
+
+	a.flushAccess(context.Background())
+
 	// Close vector store
 	if err := a.vectorStore.Close(); err != nil {
 		a.logger.Printf("Error closing vector store: %v", err)
@@ -291,5 +926,21 @@ func (a *App) gracefulShutdown() {
 		}
 	}
 
+	if err := a.audit.Close(); err != nil {
+		a.logger.Printf("Error closing audit log: %v", err)
+	}
+
+	if err := a.activity.Flush(); err != nil {
+		a.logger.Printf("Error saving activity log: %v", err)
+	}
+
+	if a.stopTelemetry != nil {
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := a.stopTelemetry(shutdownCtx); err != nil {
+			a.logger.Printf("Error flushing traces: %v", err)
+		}
+		cancel()
+	}
+
 	a.logger.Println("Shutdown complete")
 }
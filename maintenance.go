@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// startMaintenance runs the maintenance sweep once and then every interval
+// until ctx is cancelled.
+func (a *App) startMaintenance(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			a.runMaintenance(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// runMaintenance performs one maintenance sweep: it enforces context retention
+// policies, purges expired trash and logs and audits every eviction.
+func (a *App) runMaintenance(ctx context.Context) {
+	ctx = WithRequestID(ctx, newRequestID("maint"), a.tracer)
+	// Retention reads last_accessed_at
+	a.flushAccess(ctx)
+	results := a.enforceRetention(ctx, time.Now())
+	for _, res := range results {
+		evicted := append(append([]string{}, res.Expired...), res.Overflow...)
+		if res.Err == nil && len(evicted) == 0 {
+			continue
+		}
+		if res.Err != nil {
+			a.logf(ctx, "Warning: Retention for context '%s' failed: %v", res.ContextID, res.Err)
+		} else {
+			a.logf(ctx, "Retention for context '%s': %s %d memories (%d expired, %d over limit)",
+				res.ContextID, res.Action, len(evicted), len(res.Expired), len(res.Overflow))
+		}
+
+		entry := AuditEntry{
+			Tool:      "retention",
+			MemoryIDs: evicted,
+			ClientID:  a.clientID,
+			ContextID: res.ContextID,
+			Status:    "ok",
+			Details:   res.Action,
+		}
+		if res.Err != nil {
+			entry.Status = "error"
+			entry.Details = res.Action + ": " + res.Err.Error()
+		}
+		a.recordAudit(ctx, entry)
+	}
+
+	// Retry memories whose stored embedding was found invalid
+	a.reembedQueued(ctx)
+
+	purged, err := a.purgeTrash(ctx, time.Now())
+	if err != nil {
+		a.logf(ctx, "Warning: Failed to purge trash: %v", err)
+	}
+	if len(purged) > 0 || err != nil {
+		entry := AuditEntry{Tool: "purge_trash", MemoryIDs: purged, ClientID: a.clientID, Status: "ok", Details: fmt.Sprintf("older than %s", a.purgeTrashAfter)}
+		if err != nil {
+			entry.Status = "error"
+			entry.Details = err.Error()
+		} else {
+			a.logf(ctx, "Purged %d memories from the trash", len(purged))
+		}
+		a.recordAudit(ctx, entry)
+	}
+
+	if len(results) > 0 {
+		if err := a.ctx.Save(); err != nil {
+			a.logf(ctx, "Warning: Failed to save context state: %v", err)
+		}
+	}
+}
+
+// verifyIntegrityHandler handles the verify_integrity tool - rebuilds the
+// derived indexes from the vector store and reports exact duplicates and
+// contexts whose memory count does not match the store.
+func (a *App) verifyIntegrityHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if err := a.hashIndex.Rebuild(ctx, a.vectorStore); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to rebuild content hash index: %v", err)), nil
+	}
+	if err := a.keywordIndex.Sync(ctx, a.vectorStore); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to sync keyword index: %v", err)), nil
+	}
+
+	docs, err := a.vectorStore.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list memories: %v", err)), nil
+	}
+	actual := make(map[string]int)
+	for _, doc := range docs {
+		if isSoftDeleted(doc.Metadata) || isChunk(doc.Metadata) {
+			continue
+		}
+		contextID := doc.Metadata["context"]
+		if contextID == "" {
+			contextID = DefaultContextID
+		}
+		actual[contextID]++
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Checked %d memories; content hash index rebuilt with %d entries.\n", len(docs), a.hashIndex.Len()))
+
+	// Invalid stored vectors are re-embedded now; failures stay queued for the maintenance sweep
+	if invalid := invalidStoredEmbeddings(docs); len(invalid) > 0 {
+		a.reembed.Add(invalid...)
+		repaired, failed := a.reembedQueued(ctx)
+		sb.WriteString(fmt.Sprintf("Invalid embeddings: %d memories (%s); re-embedded %d, %d queued for retry\n",
+			len(invalid), strings.Join(invalid, ", "), len(repaired), len(failed)))
+	}
+
+	if groups := a.hashIndex.Collisions(); len(groups) > 0 {
+		sb.WriteString(fmt.Sprintf("Exact duplicates: %d groups (run dedupe_exact to merge)\n", len(groups)))
+	}
+	for _, c := range a.ctx.ListContexts() {
+		if c.MemoryCount != actual[c.ID] {
+			sb.WriteString(fmt.Sprintf("Context '%s' records %d memories but holds %d\n", c.ID, c.MemoryCount, actual[c.ID]))
+		}
+	}
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Export formats accepted by export_memories
+const (
+	ExportFormatJSON     = "json"
+	ExportFormatMarkdown = "markdown"
+)
+
+// markdownEscaper backslash-escapes characters with inline Markdown meaning.
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "`", "\\`", `*`, `\*`, `_`, `\_`, `[`, `\[`, `]`, `\]`,
+	`<`, `\<`, `>`, `\>`, `|`, `\|`, `~`, `\~`, `#`, `\#`,
+)
+
+// markdownBlockStart matches line starts that Markdown would read as a list
+// item, heading underline or thematic break.
+var markdownBlockStart = regexp.MustCompile(`(?m)^(\s*)([-+=]|\d+\.)`)
+
+// escapeMarkdown escapes text so it renders literally as Markdown.
+func escapeMarkdown(text string) string {
+	text = markdownEscaper.Replace(text)
+	return markdownBlockStart.ReplaceAllStringFunc(text, func(m string) string {
+		i := len(m) - 1
+		return m[:i] + `\` + m[i:]
+	})
+}
+
+// exportMarkdown renders an export as one Markdown section per memory, ready
+// to drop into a notes vault or repository. Older versions are listed when the
+// export includes them.
+func (a *App) exportMarkdown(export *ExportData) string {
+	var sb strings.Builder
+	for i, memory := range export.Memories {
+		if i > 0 {
+			sb.WriteString("\n---\n\n")
+		}
+		sb.WriteString(fmt.Sprintf("# Memory: %s\n\n%s\n\n", escapeMarkdown(memory.ID), escapeMarkdown(memory.CurrentContent())))
+
+		tags := "none"
+		if len(memory.Tags) > 0 {
+			tags = escapeMarkdown(strings.Join(memory.Tags, ", "))
+		}
+		sb.WriteString(fmt.Sprintf("**Tags:** %s\n", tags))
+		sb.WriteString(fmt.Sprintf("**Context:** %s\n", escapeMarkdown(memory.Context)))
+		sb.WriteString(fmt.Sprintf("**Created:** %s\n", a.formatTime(memory.CreatedAt)))
+
+		if len(memory.Versions) > 1 {
+			sb.WriteString("\n## History\n")
+			for _, v := range memory.Versions {
+				sb.WriteString(fmt.Sprintf("\n### Version %d - %s\n\n%s\n", v.VersionNumber, a.formatTime(v.CreatedAt), escapeMarkdown(v.Content)))
+			}
+		}
+	}
+	return sb.String()
+}
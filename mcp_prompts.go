@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// MCP prompts are user-selectable templates for common memory workflows.
+// Their handlers fill the templates with live data from the brain, so the
+// model starts with the current state instead of having to look it up.
+
+// userPrompt wraps text as a prompt result with a single user message.
+func userPrompt(description, text string) *mcp.GetPromptResult {
+	return mcp.NewGetPromptResult(description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(text)),
+	})
+}
+
+// recallPromptHandler handles the recall prompt - asks the model to search
+// memory for a topic and synthesize what it finds.
+func (a *App) recallPromptHandler(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	topic := strings.TrimSpace(request.Params.Arguments["topic"])
+	if topic == "" {
+		return nil, fmt.Errorf("topic is required")
+	}
+	contextID := strings.TrimSpace(request.Params.Arguments["context_id"])
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Recall everything I have stored about: %s\n\n", topic))
+	if contextID != "" {
+		sb.WriteString(fmt.Sprintf("Call the search_memory tool with this topic as the query and context_id \"%s\".", contextID))
+	} else {
+		sb.WriteString("Call the search_memory tool with this topic as the query.")
+	}
+	sb.WriteString(fmt.Sprintf(" The brain holds %d memories. If the first search returns little, search again with other phrasings or related terms, and use mode \"keyword\" for exact names, codes or identifiers.\n\n", a.vectorStore.Count()))
+	sb.WriteString("Then synthesize the results into a concise answer: combine related memories, point out where they contradict each other or look outdated, and cite the IDs of the memories you used in square brackets. Do not add facts that are not in the memories; if nothing relevant is stored, say so.")
+	return userPrompt(fmt.Sprintf("Recall memories about %s", topic), sb.String()), nil
+}
+
+// memoriesCreatedOn returns the memories created on the day starting at
+// start, oldest first, optionally only those in contextID. Chunks, trashed
+// and expired memories and those in contexts the client may not use are left
+// out.
+func (a *App) memoriesCreatedOn(ctx context.Context, start time.Time, contextID string) ([]chromem.Document, error) {
+	var where map[string]string
+	if contextID != "" {
+		where = map[string]string{"context": contextID}
+	}
+	docs, err := a.vectorStore.ListDocuments(ctx, where, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	end := endOfDay(start, a.location)
+	now := a.clock()
+	var created []chromem.Document
+	for _, doc := range a.accessibleDocuments(ctx, docs) {
+		if isChunk(doc.Metadata) || isSoftDeleted(doc.Metadata) || isExpired(doc.Metadata, now) {
+			continue
+		}
+		t, _, err := parseStoredTime(doc.Metadata["created_at"])
+		if err != nil || t.Before(start) || !t.Before(end) {
+			continue
+		}
+		created = append(created, doc)
+	}
+	sort.SliceStable(created, func(i, j int) bool {
+		return createdTime(created[i]).Before(createdTime(created[j]))
+	})
+	return created, nil
+}
+
+// dailySummaryPromptHandler handles the daily_summary prompt - puts the
+// memories created on a day into a summarization request.
+func (a *App) dailySummaryPromptHandler(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	day := a.clock().In(a.location)
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, a.location).UTC()
+	if value := strings.TrimSpace(request.Params.Arguments["date"]); value != "" {
+		t, err := time.ParseInLocation(time.DateOnly, value, a.location)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q (use YYYY-MM-DD)", value)
+		}
+		start = t.UTC()
+	}
+	date := start.In(a.location).Format(time.DateOnly)
+	contextID := strings.TrimSpace(request.Params.Arguments["context_id"])
+	if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); contextID != "" && msg != "" {
+		return nil, errors.New(msg)
+	}
+
+	docs, err := a.memoriesCreatedOn(ctx, start, contextID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+	scope := ""
+	if contextID != "" {
+		scope = fmt.Sprintf(" in context '%s'", contextID)
+	}
+	description := fmt.Sprintf("Summary of the %d memories created on %s%s", len(docs), date, scope)
+	if len(docs) == 0 {
+		return userPrompt(description, fmt.Sprintf("No memories were created on %s%s. Tell me so, and offer to search for memories from other days with search_memory's created_after and created_before.", date, scope)), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Write a summary of what I stored in my memory on %s%s. Group related memories by theme, highlight decisions, open questions and follow-ups, and cite memory IDs in square brackets. Keep it short enough to read in a minute.\n\n", date, scope))
+	shown := docs[:min(len(docs), MaxDailySummaryMemories)]
+	sb.WriteString(fmt.Sprintf("The %d memories created that day, oldest first:\n\n", len(docs)))
+	for _, doc := range shown {
+		contextName := doc.Metadata["context"]
+		if contextName == "" {
+			contextName = DefaultContextID
+		}
+		sb.WriteString(fmt.Sprintf("[%s] (%s, context: %s", doc.ID, a.formatTime(createdTime(doc)), contextName))
+		if tags := ParseTags(doc.Metadata["tags"]); len(tags) > 0 {
+			sb.WriteString(", tags: " + strings.Join(tags, ", "))
+		}
+		sb.WriteString(fmt.Sprintf(")\n%s\n\n", doc.Content))
+	}
+	if len(docs) > len(shown) {
+		sb.WriteString(fmt.Sprintf("(%d more memories are not shown; use list_memories with created_after \"%s\" to read them.)\n", len(docs)-len(shown), date))
+	}
+	return userPrompt(description, sb.String()), nil
+}
+
+// saveConversationPromptHandler handles the save_conversation prompt - asks
+// the model to extract the key facts of the conversation and remember them.
+func (a *App) saveConversationPromptHandler(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	current, err := a.ctx.GetClientContext(a.clientIDFrom(ctx))
+	if err != nil {
+		current = DefaultContextID
+	}
+	contextID := strings.TrimSpace(request.Params.Arguments["context_id"])
+	if contextID == "" {
+		contextID = current
+	}
+	focus := strings.TrimSpace(request.Params.Arguments["focus"])
+
+	var sb strings.Builder
+	sb.WriteString("Extract the key facts from our conversation so far and save them to my memory.\n\n")
+	if focus != "" {
+		sb.WriteString(fmt.Sprintf("Focus on: %s\n\n", focus))
+	}
+	sb.WriteString("Keep decisions and their reasons, preferences, names, dates, numbers and open tasks; skip small talk and anything that only mattered in the moment. Write each fact as one self-contained statement that makes sense without this conversation.\n\n")
+	sb.WriteString("Before saving, call search_memory for each fact to check whether it is already stored; update the existing memory under its ID instead of creating a duplicate. ")
+	if contextID != current {
+		sb.WriteString(fmt.Sprintf("Memories are saved to the current context, \"%s\", so first call switch_context with context_id \"%s\". ", current, contextID))
+	}
+	sb.WriteString(fmt.Sprintf("Save new facts to context \"%s\" with remember_batch (or remember for a single fact), with short descriptive IDs such as \"project-deadline\".", contextID))
+
+	tags := a.ctx.ListTags()
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].MemoryCount != tags[j].MemoryCount {
+			return tags[i].MemoryCount > tags[j].MemoryCount
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	if len(tags) > 0 {
+		names := make([]string, 0, min(len(tags), MaxPromptTags))
+		for _, tag := range tags[:min(len(tags), MaxPromptTags)] {
+			names = append(names, tag.Name)
+		}
+		sb.WriteString(fmt.Sprintf(" Reuse existing tags where they fit: %s.", strings.Join(names, ", ")))
+	}
+	sb.WriteString("\n\nFinish by listing the IDs you saved or updated.")
+	return userPrompt(fmt.Sprintf("Save the key facts of this conversation to context '%s'", contextID), sb.String()), nil
+}
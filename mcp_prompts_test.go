@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// getPrompt calls a prompt handler with args and returns the description and
+// the text of its single user message.
+func getPrompt(t *testing.T, handler func(context.Context, mcp.GetPromptRequest) (*mcp.GetPromptResult, error), args map[string]string) (string, string) {
+	t.Helper()
+	var request mcp.GetPromptRequest
+	request.Params.Arguments = args
+	result, err := handler(t.Context(), request)
+	if err != nil {
+		t.Fatalf("prompt handler returned error: %v", err)
+	}
+	if len(result.Messages) != 1 || result.Messages[0].Role != mcp.RoleUser {
+		t.Fatalf("prompt messages = %+v, want one user message", result.Messages)
+	}
+	text, ok := result.Messages[0].Content.(mcp.TextContent)
+	if !ok {
+		t.Fatalf("prompt content is %T, want text", result.Messages[0].Content)
+	}
+	return result.Description, text.Text
+}
+
+func TestRecallPrompt(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "deadline", "the launch is on May 3", nil)
+	ta.remember(t, "owner", "Dana owns the launch", nil)
+
+	description, text := getPrompt(t, ta.recallPromptHandler, map[string]string{"topic": " the launch "})
+	if description != "Recall memories about the launch" {
+		t.Errorf("description = %q", description)
+	}
+	for _, want := range []string{
+		"Recall everything I have stored about: the launch\n\n",
+		"Call the search_memory tool with this topic as the query. The brain holds 2 memories.",
+		"cite the IDs of the memories you used in square brackets",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("recall prompt does not contain %q:\n%s", want, text)
+		}
+	}
+
+	_, text = getPrompt(t, ta.recallPromptHandler, map[string]string{"topic": "the launch", "context_id": "work"})
+	if !strings.Contains(text, `Call the search_memory tool with this topic as the query and context_id "work".`) {
+		t.Errorf("recall prompt ignores context_id:\n%s", text)
+	}
+
+	var request mcp.GetPromptRequest
+	request.Params.Arguments = map[string]string{"topic": "  "}
+	if _, err := ta.recallPromptHandler(t.Context(), request); err == nil {
+		t.Error("recall without a topic was accepted")
+	}
+}
+
+// newDailySummaryApp returns a testApp in Tokyo time on 2026-03-05 21:00 JST
+// holding memories created on the evening before (before-midnight), after
+// midnight UTC of the day before (early, tagged ops), that morning in the work
+// context (standup) and that evening (late, expiring an hour later).
+func newDailySummaryApp(t *testing.T) (*testApp, *testClock) {
+	t.Helper()
+	ta := newTestApp(t, nil)
+	ta.location = mustLoadLocation(t, "Asia/Tokyo")
+	clock := ta.useClock(time.Date(2026, 3, 5, 12, 0, 0, 0, time.UTC))
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.remember(t, "before-midnight", "watched the match", nil)
+	ta.remember(t, "early", "the backup job moved to 01:00", map[string]any{"tags": []any{"ops"}})
+	ta.remember(t, "late", "pick up the parcel tonight", map[string]any{"ttl": "1h"})
+	ta.switchContext(t, "work")
+	ta.remember(t, "standup", "the standup moves to 9:30", nil)
+	ta.switchContext(t, DefaultContextID)
+	for id, created := range map[string]string{
+		"before-midnight": "2026-03-04T14:59:00Z",
+		"early":           "2026-03-04T15:30:00Z",
+		"standup":         "2026-03-05T01:00:00Z",
+		"late":            "2026-03-05T11:00:00Z",
+	} {
+		ta.setCreatedAt(t, id, created)
+	}
+	return ta, clock
+}
+
+func TestDailySummaryPrompt(t *testing.T) {
+	ta, clock := newDailySummaryApp(t)
+
+	description, text := getPrompt(t, ta.dailySummaryPromptHandler, nil)
+	if description != "Summary of the 3 memories created on 2026-03-05" {
+		t.Errorf("description = %q", description)
+	}
+	want := "Write a summary of what I stored in my memory on 2026-03-05. " +
+		"Group related memories by theme, highlight decisions, open questions and follow-ups, and cite memory IDs in square brackets. Keep it short enough to read in a minute.\n\n" +
+		"The 3 memories created that day, oldest first:\n\n" +
+		"[early] (2026-03-05 00:30:00 JST, context: " + DefaultContextID + ", tags: ops)\nthe backup job moved to 01:00\n\n" +
+		"[standup] (2026-03-05 10:00:00 JST, context: work)\nthe standup moves to 9:30\n\n" +
+		"[late] (2026-03-05 20:00:00 JST, context: " + DefaultContextID + ")\npick up the parcel tonight\n\n"
+	if text != want {
+		t.Errorf("daily_summary =\n%s\nwant\n%s", text, want)
+	}
+
+	_, text = getPrompt(t, ta.dailySummaryPromptHandler, map[string]string{"context_id": "work"})
+	if !strings.Contains(text, "on 2026-03-05 in context 'work'.") || !strings.Contains(text, "The 1 memories") || strings.Contains(text, "[early]") {
+		t.Errorf("daily_summary in work:\n%s", text)
+	}
+
+	_, text = getPrompt(t, ta.dailySummaryPromptHandler, map[string]string{"date": "2026-03-04"})
+	if !strings.Contains(text, "The 1 memories created that day") || !strings.Contains(text, "[before-midnight]") {
+		t.Errorf("daily_summary of 2026-03-04:\n%s", text)
+	}
+
+	// An expired memory is left out
+	clock.Advance(2 * time.Hour)
+	description, text = getPrompt(t, ta.dailySummaryPromptHandler, nil)
+	if description != "Summary of the 2 memories created on 2026-03-05" || strings.Contains(text, "[late]") {
+		t.Errorf("daily_summary after late expired = %q:\n%s", description, text)
+	}
+
+	_, text = getPrompt(t, ta.dailySummaryPromptHandler, map[string]string{"date": "2026-03-01"})
+	if !strings.HasPrefix(text, "No memories were created on 2026-03-01.") {
+		t.Errorf("daily_summary of an empty day = %q", text)
+	}
+
+	var request mcp.GetPromptRequest
+	request.Params.Arguments = map[string]string{"date": "yesterday"}
+	if _, err := ta.dailySummaryPromptHandler(t.Context(), request); err == nil || !strings.Contains(err.Error(), `invalid date "yesterday"`) {
+		t.Errorf("daily_summary with an invalid date: %v", err)
+	}
+}
+
+func TestDailySummaryPromptLimitsMemories(t *testing.T) {
+	ta, _ := newDailySummaryApp(t)
+	for i := range MaxDailySummaryMemories {
+		id := fmt.Sprintf("extra-%02d", i)
+		ta.remember(t, id, fmt.Sprintf("extra note %d", i), nil)
+		ta.setCreatedAt(t, id, "2026-03-05T05:00:00Z")
+	}
+	_, text := getPrompt(t, ta.dailySummaryPromptHandler, nil)
+	if n := strings.Count(text, ", context: "); n != MaxDailySummaryMemories {
+		t.Errorf("daily_summary shows %d memories, want %d", n, MaxDailySummaryMemories)
+	}
+	if !strings.Contains(text, `(3 more memories are not shown; use list_memories with created_after "2026-03-05" to read them.)`) {
+		t.Errorf("daily_summary does not point to the rest:\n%s", text)
+	}
+}
+
+func TestSaveConversationPrompt(t *testing.T) {
+	ta := newTestApp(t, nil)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.remember(t, "a", "first", map[string]any{"tags": []any{"ops", "billing"}})
+	ta.remember(t, "b", "second", map[string]any{"tags": []any{"ops"}})
+	ta.remember(t, "c", "third", map[string]any{"tags": []any{"api"}})
+
+	description, text := getPrompt(t, ta.saveConversationPromptHandler, map[string]string{"focus": "decisions about the API"})
+	if description != "Save the key facts of this conversation to context '"+DefaultContextID+"'" {
+		t.Errorf("description = %q", description)
+	}
+	for _, want := range []string{
+		"Focus on: decisions about the API\n\n",
+		`Save new facts to context "` + DefaultContextID + `" with remember_batch`,
+		"Reuse existing tags where they fit: ops, api, billing.",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("save_conversation does not contain %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "switch_context") {
+		t.Errorf("saving to the current context asks to switch:\n%s", text)
+	}
+
+	_, text = getPrompt(t, ta.saveConversationPromptHandler, map[string]string{"context_id": "work"})
+	if !strings.Contains(text, `Memories are saved to the current context, "`+DefaultContextID+`", so first call switch_context with context_id "work".`) ||
+		!strings.Contains(text, `Save new facts to context "work"`) || strings.Contains(text, "Focus on:") {
+		t.Errorf("save_conversation to work:\n%s", text)
+	}
+
+	// The current context is the default target
+	ta.switchContext(t, "work")
+	if description, _ := getPrompt(t, ta.saveConversationPromptHandler, nil); description != "Save the key facts of this conversation to context 'work'" {
+		t.Errorf("description after switching = %q", description)
+	}
+}
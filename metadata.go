@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// reservedMetadataKeys are the metadata keys the server manages itself, which
+// remember's metadata argument cannot set.
+var reservedMetadataKeys = []string{
+	"access_count", "chunk_count", "chunk_index", "client", "content_hash", "context",
+	"created_at", "deleted_at", "expires_at", "extra", "importance", "last_accessed_at", "merged_ids",
+	"parent_id", "pinned", "pruned_versions", "tags", RelationSupersedes, RelationPartOf,
+}
+
+// parseMetadataArg reads the metadata argument of remember and remember_batch.
+// A JSON object, given as an object or as a string holding one, becomes one
+// metadata key per property; strings are stored as they are, other values as
+// JSON. Anything else is stored unparsed under "extra", as before structured
+// metadata was supported.
+func parseMetadataArg(raw any) (map[string]string, error) {
+	var fields map[string]any
+	switch v := raw.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		fields = v
+	case string:
+		text := strings.TrimSpace(v)
+		if text == "" {
+			return nil, nil
+		}
+		if !strings.HasPrefix(text, "{") || json.Unmarshal([]byte(text), &fields) != nil {
+			return map[string]string{"extra": v}, nil
+		}
+	default:
+		return nil, fmt.Errorf("metadata must be a JSON object or a string")
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	metadata := make(map[string]string, len(fields))
+	for _, key := range keys {
+		name := strings.TrimSpace(key)
+		if name == "" {
+			return nil, fmt.Errorf("metadata keys cannot be empty")
+		}
+		if slices.Contains(reservedMetadataKeys, name) {
+			return nil, fmt.Errorf("metadata key %q is managed by the server", name)
+		}
+		switch value := fields[key].(type) {
+		case nil:
+			continue
+		case string:
+			metadata[name] = value
+		case bool:
+			metadata[name] = strconv.FormatBool(value)
+		case float64:
+			metadata[name] = strconv.FormatFloat(value, 'f', -1, 64)
+		default:
+			data, err := json.Marshal(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata value for %q: %w", name, err)
+			}
+			metadata[name] = string(data)
+		}
+	}
+	return metadata, nil
+}
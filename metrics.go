@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/philippgille/chromem-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics holds the Prometheus metrics served on /metrics when metrics_port
+// is set. They are registered on their own registry, so only brainmcp
+// metrics are exported. A nil *Metrics records nothing.
+type Metrics struct {
+	registry        *prometheus.Registry
+	rememberTotal   prometheus.Counter
+	searchTotal     prometheus.Counter
+	deleteTotal     prometheus.Counter
+	embeddingErrors prometheus.Counter
+	embedLatency    prometheus.Histogram
+	searchLatency   prometheus.Histogram
+}
+
+// NewMetrics creates and registers the brainmcp metrics.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		rememberTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "brainmcp_remember_total",
+			Help: "Number of remember and remember_batch calls.",
+		}),
+		searchTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "brainmcp_search_total",
+			Help: "Number of search calls.",
+		}),
+		deleteTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "brainmcp_delete_total",
+			Help: "Number of delete_memory calls.",
+		}),
+		embeddingErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "brainmcp_embedding_errors_total",
+			Help: "Number of embedding requests that failed or returned invalid embeddings.",
+		}),
+		embedLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "brainmcp_embed_latency_seconds",
+			Help:    "Latency of embedding requests, single and batch.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		searchLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "brainmcp_search_latency_seconds",
+			Help:    "Latency of search calls.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+	m.registry.MustRegister(m.rememberTotal, m.searchTotal, m.deleteTotal, m.embeddingErrors, m.embedLatency, m.searchLatency)
+	return m
+}
+
+// instrument wraps a tool handler so every call increments counter and, if
+// latency is not nil, observes the call's duration.
+func (m *Metrics) instrument(counter prometheus.Counter, latency prometheus.Histogram, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := handler(ctx, request)
+		counter.Inc()
+		if latency != nil {
+			latency.Observe(time.Since(start).Seconds())
+		}
+		return result, err
+	}
+}
+
+// Remember wraps a handler that stores memories.
+func (m *Metrics) Remember(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if m == nil {
+		return handler
+	}
+	return m.instrument(m.rememberTotal, nil, handler)
+}
+
+// Search wraps a handler that searches memories and records its latency.
+func (m *Metrics) Search(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if m == nil {
+		return handler
+	}
+	return m.instrument(m.searchTotal, m.searchLatency, handler)
+}
+
+// Delete wraps a handler that deletes memories.
+func (m *Metrics) Delete(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	if m == nil {
+		return handler
+	}
+	return m.instrument(m.deleteTotal, nil, handler)
+}
+
+// InstrumentEmbedder wraps the embedding functions to record their latency
+// and failures, including batches rejected for invalid embeddings.
+func (m *Metrics) InstrumentEmbedder(embFunc chromem.EmbeddingFunc, batchEmbFunc BatchEmbeddingFunc) (chromem.EmbeddingFunc, BatchEmbeddingFunc) {
+	if m == nil {
+		return embFunc, batchEmbFunc
+	}
+	observe := func(start time.Time, err error) {
+		m.embedLatency.Observe(time.Since(start).Seconds())
+		if err != nil {
+			m.embeddingErrors.Inc()
+		}
+	}
+	wrappedEmb := func(ctx context.Context, text string) ([]float32, error) {
+		start := time.Now()
+		emb, err := embFunc(ctx, text)
+		observe(start, err)
+		return emb, err
+	}
+	if batchEmbFunc == nil {
+		return wrappedEmb, nil
+	}
+	return wrappedEmb, func(ctx context.Context, texts []string) ([][]float32, error) {
+		start := time.Now()
+		embs, err := batchEmbFunc(ctx, texts)
+		observe(start, err)
+		return embs, err
+	}
+}
+
+// Serve serves /metrics on port until ctx is cancelled.
+func (m *Metrics) Serve(ctx context.Context, port int, logger *log.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	srv := &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdownCtx)
+	}()
+
+	logger.Printf("Serving Prometheus metrics on :%d/metrics", port)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Printf("Warning: Metrics server stopped: %v", err)
+	}
+}
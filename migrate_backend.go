@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// migrationBackends are the backends the migrate subcommand copies between.
+var migrationBackends = []string{"local", "qdrant", "pgvector", "redis"}
+
+// backendConfig returns a copy of cfg that selects only the named backend, so
+// NewVectorBackend opens it even when config.json configures another one.
+// Remote backends must be configured in config.json.
+func backendConfig(cfg *Config, name string) (*Config, error) {
+	c := *cfg
+	switch name {
+	case "local":
+		c.Qdrant.Host, c.Pgvector.DSN, c.Redis.Addr = "", "", ""
+	case "qdrant":
+		if c.Qdrant.Host == "" {
+			return nil, fmt.Errorf("qdrant.host is not configured")
+		}
+		c.Pgvector.DSN, c.Redis.Addr = "", ""
+	case "pgvector":
+		if c.Pgvector.DSN == "" {
+			return nil, fmt.Errorf("pgvector.dsn is not configured")
+		}
+		c.Qdrant.Host, c.Redis.Addr = "", ""
+	case "redis":
+		if c.Redis.Addr == "" {
+			return nil, fmt.Errorf("redis.addr is not configured")
+		}
+		c.Qdrant.Host, c.Pgvector.DSN = "", ""
+	default:
+		return nil, fmt.Errorf("unknown backend %q; use one of %s", name, strings.Join(migrationBackends, ", "))
+	}
+	return &c, nil
+}
+
+// configuredDimension returns the embedding size the named backend is set up
+// for, or 0 if it takes whatever it is given.
+func configuredDimension(cfg *Config, name string) int {
+	switch name {
+	case "qdrant":
+		return cfg.Qdrant.VectorDimension
+	case "pgvector":
+		return cfg.Pgvector.VectorDimension
+	case "redis":
+		return cfg.Redis.VectorDimension
+	}
+	return 0
+}
+
+// migrateDocuments copies every document of src, with its stored embedding,
+// into dst in batches of MigrateBatchSize and checks the destination count
+// afterwards. Nothing is embedded again, so it refuses to copy documents
+// without an embedding or of a dimension other than dstDim (when above 0) or
+// the documents already in dst. Documents already in dst are overwritten. It
+// returns the number of documents copied.
+func migrateDocuments(ctx context.Context, src, dst VectorBackend, dstDim int, out io.Writer) (int, error) {
+	docs, err := src.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the source: %w", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	dim := len(docs[0].Embedding)
+	for _, doc := range docs {
+		if len(doc.Embedding) == 0 {
+			return 0, fmt.Errorf("memory %s has no stored embedding in the source", doc.ID)
+		}
+		if len(doc.Embedding) != dim {
+			return 0, fmt.Errorf("the source holds embeddings of different dimensions (%d for %s, %d for %s); run verify_storage", dim, docs[0].ID, len(doc.Embedding), doc.ID)
+		}
+	}
+	if dstDim > 0 && dstDim != dim {
+		return 0, fmt.Errorf("the source memories have %d-dimensional embeddings but the destination is configured for %d; embeddings are copied, not regenerated, so the dimensions must match", dim, dstDim)
+	}
+
+	existing, err := dst.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the destination: %w", err)
+	}
+	expected := len(existing) + len(docs)
+	have := make(map[string]bool, len(existing))
+	for _, doc := range existing {
+		if n := len(doc.Embedding); n > 0 && n != dim {
+			return 0, fmt.Errorf("the destination already holds %d-dimensional embeddings but the source memories have %d", n, dim)
+		}
+		have[doc.ID] = true
+	}
+	for _, doc := range docs {
+		if have[doc.ID] {
+			expected--
+		}
+	}
+
+	for start := 0; start < len(docs); start += MigrateBatchSize {
+		end := min(start+MigrateBatchSize, len(docs))
+		if err := dst.AddDocuments(ctx, docs[start:end], 4); err != nil {
+			return start, fmt.Errorf("failed to write memories %d-%d: %w", start+1, end, err)
+		}
+		fmt.Fprintf(out, "Copied %d/%d memories\n", end, len(docs))
+	}
+
+	if count := dst.Count(); count != expected {
+		return len(docs), fmt.Errorf("the destination holds %d memories after the copy, expected %d", count, expected)
+	}
+	return len(docs), nil
+}
+
+// runMigrate implements the migrate subcommand: brainmcp migrate --from
+// <backend> --to <backend>.
+func runMigrate(ctx context.Context, cfg *Config, args []string, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, logger *log.Logger, out io.Writer) int {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	from := fs.String("from", "", "Backend to copy from: "+strings.Join(migrationBackends, ", "))
+	to := fs.String("to", "", "Backend to copy to")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" || *to == "" {
+		fmt.Fprintln(out, "Usage: brainmcp migrate --from <backend> --to <backend>")
+		return 2
+	}
+	if *from == *to {
+		fmt.Fprintf(out, "Error: --from and --to are both %s\n", *from)
+		return 2
+	}
+
+	srcCfg, err := backendConfig(cfg, *from)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 2
+	}
+	dstCfg, err := backendConfig(cfg, *to)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 2
+	}
+
+	src, err := NewVectorBackend(srcCfg, embFunc, batchEmbf, logger)
+	if err != nil {
+		fmt.Fprintf(out, "Error: failed to open %s: %v\n", *from, err)
+		return 1
+	}
+	defer src.Close()
+	dst, err := NewVectorBackend(dstCfg, embFunc, batchEmbf, logger)
+	if err != nil {
+		fmt.Fprintf(out, "Error: failed to open %s: %v\n", *to, err)
+		return 1
+	}
+	defer dst.Close()
+
+	fmt.Fprintf(out, "Copying %d memories from %s to %s\n", src.Count(), *from, *to)
+	count, err := migrateDocuments(ctx, src, dst, configuredDimension(cfg, *to), out)
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return 1
+	}
+	logger.Printf("Migrated %d memories from %s to %s", count, *from, *to)
+	fmt.Fprintf(out, "Migrated %d memories from %s to %s; set up config.json to use %s\n", count, *from, *to, *to)
+	return 0
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// newMigrationStore returns an empty local store in a temporary directory,
+// embedding with embedder.
+func newMigrationStore(t *testing.T, embedder *countingEmbedder) *LocalVectorStore {
+	t.Helper()
+	store, err := NewLocalVectorStore(filepath.Join(t.TempDir(), DefaultDBPath), nil, embedder.Embed, embedder.BatchEmbed, 0, nil)
+	if err != nil {
+		t.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	return store
+}
+
+// storedDocuments returns the documents of store by ID.
+func storedDocuments(t *testing.T, store VectorBackend) map[string]chromem.Document {
+	t.Helper()
+	docs, err := store.ListDocuments(t.Context(), nil, 0, 0)
+	if err != nil {
+		t.Fatalf("ListDocuments: %v", err)
+	}
+	byID := make(map[string]chromem.Document, len(docs))
+	for _, doc := range docs {
+		byID[doc.ID] = doc
+	}
+	return byID
+}
+
+func TestMigrateLocalToLocal(t *testing.T) {
+	src := newMigrationStore(t, &countingEmbedder{})
+	var docs []chromem.Document
+	for i := range 2*MigrateBatchSize + 50 {
+		docs = append(docs, chromem.Document{
+			ID:       fmt.Sprintf("mem-%03d", i),
+			Content:  fmt.Sprintf("memory number %d", i),
+			Metadata: map[string]string{"context": DefaultContextID, "tags": "migrated"},
+		})
+	}
+	if err := src.AddDocuments(t.Context(), docs, 4); err != nil {
+		t.Fatalf("AddDocuments: %v", err)
+	}
+
+	// The destination must not embed anything
+	dstEmbedder := &countingEmbedder{}
+	dst := newMigrationStore(t, dstEmbedder)
+	var out bytes.Buffer
+	count, err := migrateDocuments(t.Context(), src, dst, 0, &out)
+	if err != nil || count != len(docs) {
+		t.Fatalf("migrateDocuments = %d, %v; want %d", count, err, len(docs))
+	}
+	if n := dstEmbedder.Count(); n != 0 {
+		t.Errorf("the migration embedded %d texts", n)
+	}
+	if got, want := out.String(), "Copied 100/250 memories\nCopied 200/250 memories\nCopied 250/250 memories\n"; got != want {
+		t.Errorf("progress = %q, want %q", got, want)
+	}
+
+	want, got := storedDocuments(t, src), storedDocuments(t, dst)
+	if len(got) != len(want) {
+		t.Fatalf("destination holds %d memories, want %d", len(got), len(want))
+	}
+	for id, doc := range want {
+		copied := got[id]
+		if copied.Content != doc.Content || !maps.Equal(copied.Metadata, doc.Metadata) || !slices.Equal(copied.Embedding, doc.Embedding) {
+			t.Errorf("%s copied as %+v, want %+v", id, copied, doc)
+		}
+	}
+
+	// Running it again overwrites rather than duplicates, and the copy
+	// migrates back the other way
+	if _, err := migrateDocuments(t.Context(), src, dst, 0, io.Discard); err != nil || dst.Count() != len(docs) {
+		t.Errorf("second migration: %v, %d memories", err, dst.Count())
+	}
+	back := newMigrationStore(t, dstEmbedder)
+	if count, err := migrateDocuments(t.Context(), dst, back, testDimension, io.Discard); err != nil || count != len(docs) {
+		t.Errorf("migrating back = %d, %v", count, err)
+	}
+	if n := dstEmbedder.Count(); n != 0 {
+		t.Errorf("migrating back embedded %d texts", n)
+	}
+}
+
+func TestMigrateRefusesDimensionMismatch(t *testing.T) {
+	src := newMigrationStore(t, &countingEmbedder{})
+	if err := src.AddDocuments(t.Context(), []chromem.Document{{ID: "a", Content: "memory a"}}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	dst := newMigrationStore(t, &countingEmbedder{})
+	_, err := migrateDocuments(t.Context(), src, dst, 768, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "64-dimensional embeddings but the destination is configured for 768") {
+		t.Errorf("migrating into a 768-dimensional destination = %v", err)
+	}
+
+	// A destination holding embeddings of another size refuses too
+	if err := dst.AddDocuments(t.Context(), []chromem.Document{{ID: "other", Content: "other", Embedding: []float32{1, 0, 0}}}, 1); err != nil {
+		t.Fatal(err)
+	}
+	_, err = migrateDocuments(t.Context(), src, dst, 0, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "already holds 3-dimensional embeddings") {
+		t.Errorf("migrating into a 3-dimensional destination = %v", err)
+	}
+	if dst.Count() != 1 {
+		t.Errorf("refused migrations left %d memories, want 1", dst.Count())
+	}
+}
+
+func TestRunMigrateArguments(t *testing.T) {
+	logger := log.New(io.Discard, "", 0)
+	for _, tc := range []struct {
+		args []string
+		code int
+		want string
+	}{
+		{nil, 2, "Usage: brainmcp migrate --from <backend> --to <backend>"},
+		{[]string{"--from", "local", "--to", "local"}, 2, "--from and --to are both local"},
+		{[]string{"--from", "local", "--to", "s3"}, 2, `unknown backend "s3"`},
+		{[]string{"--from", "local", "--to", "qdrant"}, 2, "qdrant.host is not configured"},
+	} {
+		var out bytes.Buffer
+		if code := runMigrate(t.Context(), DefaultConfig(), tc.args, testEmbedding, nil, logger, &out); code != tc.code || !strings.Contains(out.String(), tc.want) {
+			t.Errorf("migrate %v = %d, %q; want %d, %q", tc.args, code, out.String(), tc.code, tc.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+)
+
+// mmrRerank picks n of the candidates by maximal marginal relevance: each step
+// takes the candidate maximizing
+//
+//	lambda * relevance - (1-lambda) * max similarity to the ones already taken
+//
+// so a memory that repeats an earlier pick loses out to a less similar one
+// that adds something new. Relevance is the candidate's Similarity; with
+// scale it is divided by the top score first, so keyword and fused scores
+// weigh the same as cosine similarity. Candidates without an embedding count
+// as unlike every other. The picks are returned in selection order.
+func mmrRerank(candidates []chromem.Result, n int, lambda float64, scale bool) []chromem.Result {
+	if n <= 0 {
+		return nil
+	}
+	if len(candidates) <= 1 {
+		return candidates
+	}
+
+	relevance := make([]float64, len(candidates))
+	top := 0.0
+	for i, res := range candidates {
+		relevance[i] = float64(res.Similarity)
+		top = max(top, relevance[i])
+	}
+	if scale && top > 0 {
+		for i := range relevance {
+			relevance[i] /= top
+		}
+	}
+
+	selected := make([]chromem.Result, 0, min(n, len(candidates)))
+	taken := make([]bool, len(candidates))
+	// Highest similarity of each candidate to any selected memory so far
+	redundancy := make([]float64, len(candidates))
+	for len(selected) < cap(selected) {
+		best, bestScore := -1, 0.0
+		for i := range candidates {
+			if taken[i] {
+				continue
+			}
+			score := lambda*relevance[i] - (1-lambda)*redundancy[i]
+			if best < 0 || score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		taken[best] = true
+		selected = append(selected, candidates[best])
+
+		picked := candidates[best].Embedding
+		if len(picked) == 0 {
+			continue
+		}
+		for i, res := range candidates {
+			if !taken[i] && len(res.Embedding) == len(picked) {
+				redundancy[i] = max(redundancy[i], vecmath.Cosine(picked, res.Embedding))
+			}
+		}
+	}
+	return selected
+}
+
+// selectResults returns the n results search_memory shows: the first n, or
+// with MMR a diverse n of all candidates.
+func selectResults(results []chromem.Result, n int, useMMR bool, lambda float64, mode string) []chromem.Result {
+	if useMMR {
+		return mmrRerank(results, n, lambda, mode != SearchModeSemantic)
+	}
+	return results[:min(len(results), n)]
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+)
+
+// For the query "standup time", standup and standup-again are the two most
+// relevant memories but say the same thing (0.9995 similar to each other),
+// while retro is less relevant but only 0.76 similar to either.
+var mmrVectors = syntheticEmbedder{
+	"standup time":                     angled(1, 1),
+	"the standup is at 9:30":           angled(0.95, 2),
+	"standup starts at half past nine": angled(0.94, 2),
+	"the retro is on Friday afternoon": angled(0.8, 3),
+	"lunch is at noon":                 angled(0.3, 4),
+}
+
+// maxPairwiseSimilarity returns the highest cosine similarity between any
+// two of the results.
+func maxPairwiseSimilarity(results []chromem.Result) float64 {
+	highest := -1.0
+	for i := range results {
+		for j := i + 1; j < len(results); j++ {
+			highest = max(highest, vecmath.Cosine(results[i].Embedding, results[j].Embedding))
+		}
+	}
+	return highest
+}
+
+func TestMMRRerank(t *testing.T) {
+	candidates := []chromem.Result{
+		{ID: "standup", Similarity: 0.95, Embedding: mmrVectors["the standup is at 9:30"]},
+		{ID: "standup-again", Similarity: 0.94, Embedding: mmrVectors["standup starts at half past nine"]},
+		{ID: "retro", Similarity: 0.8, Embedding: mmrVectors["the retro is on Friday afternoon"]},
+		{ID: "lunch", Similarity: 0.3, Embedding: mmrVectors["lunch is at noon"]},
+	}
+	ids := func(results []chromem.Result) []string {
+		var ids []string
+		for _, res := range results {
+			ids = append(ids, res.ID)
+		}
+		return ids
+	}
+
+	plain := selectResults(candidates, 2, false, DefaultMMRLambda, SearchModeSemantic)
+	diverse := selectResults(candidates, 2, true, DefaultMMRLambda, SearchModeSemantic)
+	if got := ids(plain); !slices.Equal(got, []string{"standup", "standup-again"}) {
+		t.Errorf("plain ranking = %v", got)
+	}
+	if got := ids(diverse); !slices.Equal(got, []string{"standup", "retro"}) {
+		t.Errorf("MMR = %v, want the retro instead of the repeated standup", got)
+	}
+	if p, d := maxPairwiseSimilarity(plain), maxPairwiseSimilarity(diverse); d >= p || d > 0.8 {
+		t.Errorf("MMR results are up to %.3f similar, plain ones %.3f; want MMR more diverse", d, p)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		n      int
+		lambda float64
+		scale  bool
+		want   []string
+	}{
+		// Relevance alone keeps the plain order
+		{"lambda 1", 3, 1, false, []string{"standup", "standup-again", "retro"}},
+		{"all", 4, DefaultMMRLambda, false, []string{"standup", "retro", "lunch", "standup-again"}},
+		{"scaled", 2, DefaultMMRLambda, true, []string{"standup", "retro"}},
+		{"none", 0, DefaultMMRLambda, false, nil},
+	} {
+		if got := ids(mmrRerank(candidates, tc.n, tc.lambda, tc.scale)); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: mmrRerank = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// A candidate without an embedding is unlike every other
+	unembedded := slices.Clone(candidates)
+	unembedded[1].Embedding = nil
+	if got := ids(mmrRerank(unembedded, 2, DefaultMMRLambda, false)); !slices.Equal(got, []string{"standup", "standup-again"}) {
+		t.Errorf("mmrRerank without an embedding = %v", got)
+	}
+}
+
+func TestSearchMemoryUseMMR(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		config  bool
+		args    map[string]any
+		wantMMR bool
+	}{
+		{"default", false, nil, false},
+		{"argument", false, map[string]any{"use_mmr": true}, true},
+		{"config", true, nil, true},
+		{"argument overrides config", true, map[string]any{"use_mmr": false}, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ta := newSyntheticApp(t, func(cfg *Config) { cfg.UseMMR = tc.config }, mmrVectors)
+			ta.remember(t, "standup", "the standup is at 9:30", nil)
+			ta.remember(t, "standup-again", "standup starts at half past nine", nil)
+			ta.remember(t, "retro", "the retro is on Friday afternoon", nil)
+			ta.remember(t, "lunch", "lunch is at noon", nil)
+
+			args := map[string]any{"query": "standup time", "max_results": 2.0, "mode": SearchModeSemantic}
+			for k, v := range tc.args {
+				args[k] = v
+			}
+			text, isErr := call(t, ta.searchHandler, args)
+			if isErr {
+				t.Fatalf("search_memory: %s", text)
+			}
+			want := []string{"standup", "standup-again"}
+			if tc.wantMMR {
+				want = []string{"standup", "retro"}
+			}
+			if got := advancedIDs(text); !slices.Equal(got, want) {
+				t.Errorf("search_memory lists %v, want %v:\n%s", got, want, text)
+			}
+		})
+	}
+}
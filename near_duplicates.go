@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// nearDuplicate is a stored memory whose embedding is at least as similar to
+// a new memory's as the near_duplicate_threshold.
+type nearDuplicate struct {
+	ID         string
+	Similarity float32
+}
+
+// parseDedupeMode reads the dedupe argument of remember and remember_batch.
+func parseDedupeMode(args map[string]any) (string, error) {
+	mode, _ := args["dedupe"].(string)
+	switch mode = strings.TrimSpace(mode); mode {
+	case "":
+		return DedupeWarn, nil
+	case DedupeWarn, DedupeSkip, DedupeMerge:
+		return mode, nil
+	}
+	return "", fmt.Errorf("dedupe must be '%s', '%s' or '%s'", DedupeWarn, DedupeSkip, DedupeMerge)
+}
+
+// findNearDuplicate returns the stored memory most similar to doc, which must
+// carry its embedding, if it reaches the near-duplicate threshold. The memory
+// doc replaces, the IDs in exclude and memories in contexts the client may not
+// use are not considered.
+func (a *App) findNearDuplicate(ctx context.Context, doc chromem.Document, exclude ...string) (*nearDuplicate, error) {
+	if len(doc.Embedding) == 0 || a.vectorStore.Count() == 0 {
+		return nil, nil
+	}
+	results, err := a.vectorStore.QueryEmbedding(ctx, doc.Embedding, min(len(exclude)+5, a.vectorStore.Count()), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	threshold := float32(a.settings().NearDuplicateThreshold)
+	for _, res := range visibleResults(a.accessibleResults(ctx, results), a.clock()) {
+		if res.ID == doc.ID || slices.Contains(exclude, res.ID) {
+			continue
+		}
+		if res.Similarity >= threshold {
+			return &nearDuplicate{ID: res.ID, Similarity: res.Similarity}, nil
+		}
+		break
+	}
+	return nil, nil
+}
+
+// mergeNearDuplicate stores doc's content under the near-duplicate memory
+// keepID instead of storing doc, records doc's ID in merged_ids and adds a
+// version to keepID's history. The existing memory keeps its context and tags.
+func (a *App) mergeNearDuplicate(ctx context.Context, keepID string, doc chromem.Document, changeNote string) error {
+	keep, err := a.vectorStore.GetByID(ctx, keepID)
+	if err != nil {
+		return fmt.Errorf("memory %q not found: %w", keepID, err)
+	}
+
+	metadata := make(map[string]string, len(keep.Metadata)+1)
+	for k, v := range keep.Metadata {
+		metadata[k] = v
+	}
+	metadata["merged_ids"] = mergeList(metadata["merged_ids"], doc.ID)
+	keep.Metadata = metadata
+	keep.Content = doc.Content
+	keep.Embedding = doc.Embedding
+
+	if err := a.vectorStore.AddDocument(ctx, keep); err != nil {
+		return fmt.Errorf("failed to update memory %q: %w", keepID, err)
+	}
+	if changeNote = strings.TrimSpace(changeNote); changeNote == "" {
+		changeNote = fmt.Sprintf("Merged near-duplicate '%s'", doc.ID)
+	}
+	contextID := metadata["context"]
+	if contextID == "" {
+		contextID = DefaultContextID
+	}
+	a.recordVersion(ctx, keepID, doc.Content, contextID, ParseTags(metadata["tags"]), changeNote)
+	return nil
+}
+
+// duplicatePair is two memories find_duplicates found at least the threshold
+// similar, with A sorting before B.
+type duplicatePair struct {
+	A, B       string
+	Similarity float32
+}
+
+// MarshalJSON writes the pair as [id_a, id_b, similarity].
+func (p duplicatePair) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]any{p.A, p.B, math.Round(float64(p.Similarity)*1e4) / 1e4})
+}
+
+// findDuplicatePairs queries each of docs, which must carry their embeddings,
+// against its DuplicateNeighbours nearest neighbours in where and returns the
+// pairs at least threshold similar, most similar first. Neighbours not among
+// docs, such as chunks or memories in the trash, are skipped. Comparing with
+// the nearest neighbours only keeps large collections from costing n² comparisons.
+func (a *App) findDuplicatePairs(ctx context.Context, docs []chromem.Document, where map[string]string, threshold float64) ([]duplicatePair, error) {
+	candidates := make(map[string]bool, len(docs))
+	for _, doc := range docs {
+		candidates[doc.ID] = true
+	}
+	n := min(DuplicateNeighbours+1, a.vectorStore.Count())
+
+	seen := make(map[[2]string]bool)
+	var pairs []duplicatePair
+	for _, doc := range docs {
+		if len(doc.Embedding) == 0 {
+			continue
+		}
+		results, err := a.vectorStore.QueryEmbedding(ctx, doc.Embedding, n, where, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query neighbours of %q: %w", doc.ID, err)
+		}
+		for _, res := range results {
+			if res.ID == doc.ID || !candidates[res.ID] {
+				continue
+			}
+			if float64(res.Similarity) < threshold {
+				break
+			}
+			key := [2]string{min(doc.ID, res.ID), max(doc.ID, res.ID)}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			pairs = append(pairs, duplicatePair{A: key[0], B: key[1], Similarity: res.Similarity})
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Similarity != pairs[j].Similarity {
+			return pairs[i].Similarity > pairs[j].Similarity
+		}
+		return pairs[i].A+"\x00"+pairs[i].B < pairs[j].A+"\x00"+pairs[j].B
+	})
+	return pairs, nil
+}
+
+// findDuplicatesHandler handles the find_duplicates tool - lists pairs of
+// memories whose embeddings are at least threshold similar, without changing
+// anything.
+func (a *App) findDuplicatesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+
+	threshold := a.settings().NearDuplicateThreshold
+	if v, ok := args["threshold"].(float64); ok {
+		if v <= 0 || v > 1 {
+			return mcp.NewToolResultError("threshold must be greater than 0 and at most 1"), nil
+		}
+		threshold = v
+	}
+	contextID, _ := args["context_id"].(string)
+	var where map[string]string
+	if contextID = strings.TrimSpace(contextID); contextID != "" {
+		if msg := a.contextAccessDenied(a.clientIDFrom(ctx), contextID); msg != "" {
+			return mcp.NewToolResultError(msg), nil
+		}
+		where = map[string]string{"context": contextID}
+	}
+
+	docs, err := a.consolidationCandidates(ctx, contextID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list memories: %v", err)), nil
+	}
+	pairs, err := a.findDuplicatePairs(ctx, docs, where, threshold)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to find duplicates: %v", err)), nil
+	}
+	if len(pairs) == 0 {
+		return mcp.NewToolResultText(fmt.Sprintf("No memories are at least %.2f similar to each other.", threshold)), nil
+	}
+
+	out, err := json.MarshalIndent(pairs, "", "  ")
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to format duplicates: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Found %d pairs of memories at least %.2f similar, as [id_a, id_b, similarity]:\n%s", len(pairs), threshold, out)), nil
+}
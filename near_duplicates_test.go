@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// nearDuplicateVectors places "near" at similarity 0.97 to "base" and "far"
+// at 0.93, on either side of the default threshold of 0.95. They are turned
+// toward different axes, so near and far are only 0.90 similar.
+var nearDuplicateVectors = syntheticEmbedder{
+	"the deploy runs at noon":          angled(1, 1),
+	"the deploy runs at noon sharp":    angled(0.97, 1),
+	"the deploy runs around noon":      angled(0.93, 2),
+	"the deploy runs at noon, usually": angled(0.99, 1),
+	"backups run at midnight":          angled(0, 3),
+}
+
+func TestRememberWarnsOfNearDuplicate(t *testing.T) {
+	ta := newSyntheticApp(t, nil, nearDuplicateVectors)
+	ta.remember(t, "base", "the deploy runs at noon", nil)
+
+	// A memory is not a near duplicate of its own previous version
+	text, _ := call(t, ta.rememberHandler, map[string]any{"id": "base", "content": "the deploy runs at noon, usually"})
+	if strings.Contains(text, "very similar") {
+		t.Errorf("updating a memory warned about itself: %s", text)
+	}
+	ta.remember(t, "base", "the deploy runs at noon", nil)
+
+	text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "near", "content": "the deploy runs at noon sharp"})
+	if isErr || !strings.Contains(text, "Warning: very similar to memory 'base' (similarity 0.97).") {
+		t.Errorf("remember at 0.97 = %q, want a warning naming base", text)
+	}
+	if ta.storedContent(t, "near") == "" {
+		t.Error("a warned near duplicate was not stored")
+	}
+
+	text, _ = call(t, ta.rememberHandler, map[string]any{"id": "far", "content": "the deploy runs around noon"})
+	if strings.Contains(text, "very similar") {
+		t.Errorf("remember at 0.93 warned: %s", text)
+	}
+
+	text, isErr = call(t, ta.rememberHandler, map[string]any{"id": "x", "content": "backups run at midnight", "dedupe": "ignore"})
+	if !isErr || !strings.Contains(text, "dedupe must be") {
+		t.Errorf("remember with dedupe ignore = %q, want an error", text)
+	}
+}
+
+func TestNearDuplicateThresholdIsConfigurable(t *testing.T) {
+	ta := newSyntheticApp(t, func(cfg *Config) { cfg.NearDuplicateThreshold = 0.9 }, nearDuplicateVectors)
+	ta.remember(t, "base", "the deploy runs at noon", nil)
+
+	text, _ := call(t, ta.rememberHandler, map[string]any{"id": "far", "content": "the deploy runs around noon"})
+	if !strings.Contains(text, "very similar to memory 'base' (similarity 0.93)") {
+		t.Errorf("remember at 0.93 with threshold 0.9 = %q, want a warning", text)
+	}
+	text, _ = call(t, ta.rememberHandler, map[string]any{"id": "other", "content": "backups run at midnight"})
+	if strings.Contains(text, "very similar") {
+		t.Errorf("an unrelated memory warned: %s", text)
+	}
+}
+
+func TestRememberDedupeSkipAndMerge(t *testing.T) {
+	ta := newSyntheticApp(t, nil, nearDuplicateVectors)
+	ta.remember(t, "base", "the deploy runs at noon", map[string]any{"tags": []any{"ops"}})
+
+	text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "near", "content": "the deploy runs at noon sharp", "dedupe": "skip"})
+	if isErr || !strings.Contains(text, "Memory 'near' not stored: very similar to memory 'base'") {
+		t.Errorf("dedupe skip = %q", text)
+	}
+	if ta.storedContent(t, "near") != "" {
+		t.Error("a skipped near duplicate was stored")
+	}
+	ta.remember(t, "far", "the deploy runs around noon", map[string]any{"dedupe": "skip"})
+	if ta.storedContent(t, "far") == "" {
+		t.Error("dedupe skip refused a memory below the threshold")
+	}
+
+	text, isErr = call(t, ta.rememberHandler, map[string]any{"id": "near", "content": "the deploy runs at noon sharp", "dedupe": "merge"})
+	if isErr || !strings.Contains(text, "merged into very similar memory 'base'") {
+		t.Errorf("dedupe merge = %q", text)
+	}
+	if ta.storedContent(t, "near") != "" {
+		t.Error("a merged near duplicate was stored under its own ID")
+	}
+	if got := ta.storedContent(t, "base"); got != "the deploy runs at noon sharp" {
+		t.Errorf("base content after merge = %q", got)
+	}
+	doc, _ := ta.vectorStore.GetByID(t.Context(), "base")
+	if doc.Metadata["merged_ids"] != "near" || !strings.Contains(doc.Metadata["tags"], "ops") {
+		t.Errorf("base metadata after merge = %v, want merged_ids near and its tags kept", doc.Metadata)
+	}
+	history := ta.history(t, "base")
+	if n := len(history.Versions); n != 2 || !strings.Contains(history.Versions[1].ChangeNote, "Merged near-duplicate 'near'") {
+		t.Errorf("base has %d versions after merge: %+v", n, history.Versions)
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID); got[DefaultContextID] != 2 {
+		t.Errorf("context count = %d, want 2: base and far", got[DefaultContextID])
+	}
+}
+
+// Each memory of a batch is compared with the stored ones and reported by ID.
+func TestRememberBatchReportsNearDuplicates(t *testing.T) {
+	batch := func(ta *testApp, dedupe string) string {
+		t.Helper()
+		text, isErr := call(t, ta.rememberBatchHandler, map[string]any{
+			"dedupe": dedupe,
+			"memories": []any{
+				map[string]any{"id": "near", "content": "the deploy runs at noon sharp"},
+				map[string]any{"id": "far", "content": "the deploy runs around noon"},
+				map[string]any{"id": "backups", "content": "backups run at midnight"},
+			},
+		})
+		if isErr {
+			t.Fatalf("remember_batch with dedupe %s: %s", dedupe, text)
+		}
+		return text
+	}
+
+	for _, tc := range []struct {
+		dedupe, report string
+		stored         int
+		nearStored     bool
+	}{
+		{"warn", "Near duplicates stored anyway: near ~ base (0.97).", 3, true},
+		{"skip", "Near duplicates skipped: near ~ base (0.97).", 2, false},
+		{"merge", "Near duplicates merged: near ~ base (0.97).", 2, false},
+	} {
+		t.Run(tc.dedupe, func(t *testing.T) {
+			ta := newSyntheticApp(t, nil, nearDuplicateVectors)
+			ta.remember(t, "base", "the deploy runs at noon", nil)
+
+			text := batch(ta, tc.dedupe)
+			if !strings.Contains(text, tc.report) || strings.Contains(text, "far ~") || strings.Contains(text, "backups ~") {
+				t.Errorf("result = %q, want only near reported: %s", text, tc.report)
+			}
+			if want := fmt.Sprintf("Successfully stored %d memories", tc.stored); !strings.HasPrefix(text, want) {
+				t.Errorf("result = %q, want %q", text, want)
+			}
+			if (ta.storedContent(t, "near") != "") != tc.nearStored || ta.storedContent(t, "far") == "" || ta.storedContent(t, "backups") == "" {
+				t.Errorf("near stored = %v, want %v; far and backups must be stored", ta.storedContent(t, "near") != "", tc.nearStored)
+			}
+			if merged := ta.storedContent(t, "base") == "the deploy runs at noon sharp"; merged != (tc.dedupe == "merge") {
+				t.Errorf("base content = %q", ta.storedContent(t, "base"))
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pgvector/pgvector-go"
+	pgxvec "github.com/pgvector/pgvector-go/pgx"
+	"github.com/philippgille/chromem-go"
+)
+
+// pgvectorTableName matches the table names the pgvector backend accepts.
+var pgvectorTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PgvectorVectorStore implements VectorBackend on PostgreSQL with the pgvector
+// extension. Documents live in one table; metadata is a JSONB column, so
+// metadata filters run in the database.
+type PgvectorVectorStore struct {
+	pool      *pgxpool.Pool
+	table     string // Quoted table name, safe to interpolate into SQL
+	embFunc   chromem.EmbeddingFunc
+	batchEmbf BatchEmbeddingFunc
+	logger    *log.Logger
+	vectorDim int
+}
+
+// NewPgvectorVectorStore connects to PostgreSQL, enables the vector extension
+// and creates the table if it does not exist.
+func NewPgvectorVectorStore(ctx context.Context, dsn, tableName string, vectorDim int, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, logger *log.Logger) (*PgvectorVectorStore, error) {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	if !pgvectorTableName.MatchString(tableName) {
+		return nil, fmt.Errorf("invalid pgvector table name %q", tableName)
+	}
+
+	// The extension must exist before the pool's connections register its types
+	conn, err := pgx.Connect(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	_, err = conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector")
+	conn.Close(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enable the vector extension: %w", err)
+	}
+
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pgvector dsn: %w", err)
+	}
+	poolCfg.AfterConnect = func(ctx context.Context, conn *pgx.Conn) error {
+		return pgxvec.RegisterTypes(ctx, conn)
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, poolCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+
+	pvs := &PgvectorVectorStore{
+		pool:      pool,
+		table:     pgx.Identifier{tableName}.Sanitize(),
+		embFunc:   embFunc,
+		batchEmbf: batchEmbf,
+		logger:    logger,
+		vectorDim: vectorDim,
+	}
+
+	_, err = pool.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		id TEXT PRIMARY KEY,
+		content TEXT NOT NULL,
+		embedding vector(%d) NOT NULL,
+		metadata JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`, pvs.table, vectorDim))
+	if err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("failed to create table %s: %w", pvs.table, err)
+	}
+
+	logger.Printf("Connected to PostgreSQL with pgvector (table: %s, vector_dimension: %d)", tableName, vectorDim)
+	return pvs, nil
+}
+
+// AddDocuments upserts documents in a single transaction. An existing
+// document keeps its created_at.
+func (pvs *PgvectorVectorStore) AddDocuments(ctx context.Context, documents []chromem.Document, concurrency int) error {
+	if len(documents) == 0 {
+		return nil
+	}
+
+	documents, err := embedMissing(ctx, pvs, documents)
+	if err != nil {
+		return fmt.Errorf("batch embedding failed: %w", err)
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %s (id, content, embedding, metadata) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO UPDATE SET content = EXCLUDED.content, embedding = EXCLUDED.embedding, metadata = EXCLUDED.metadata`, pvs.table)
+	batch := &pgx.Batch{}
+	for _, doc := range documents {
+		if len(doc.Embedding) != pvs.vectorDim {
+			return fmt.Errorf("document %q has a %d-dimensional embedding, the table stores %d dimensions", doc.ID, len(doc.Embedding), pvs.vectorDim)
+		}
+		metadata, err := metadataJSON(doc.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata of %q: %w", doc.ID, err)
+		}
+		batch.Queue(query, doc.ID, doc.Content, pgvector.NewVector(doc.Embedding), metadata)
+	}
+
+	tx, err := pvs.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return fmt.Errorf("failed to upsert documents: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit documents: %w", err)
+	}
+
+	pvs.logger.Printf("Added %d documents to pgvector", len(documents))
+	return nil
+}
+
+// AddDocument upserts a single document.
+func (pvs *PgvectorVectorStore) AddDocument(ctx context.Context, document chromem.Document) error {
+	return pvs.AddDocuments(ctx, []chromem.Document{document}, 1)
+}
+
+// Query embeds the query text and delegates to QueryEmbedding.
+func (pvs *PgvectorVectorStore) Query(ctx context.Context, queryText string, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	embedding, err := pvs.embFunc(ctx, queryText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	return pvs.QueryEmbedding(ctx, embedding, nResults, where, whereDocument)
+}
+
+// QueryEmbedding returns the nResults documents closest to queryEmbedding by
+// cosine distance. Similarity is 1 minus the distance, the cosine similarity
+// the local store reports.
+func (pvs *PgvectorVectorStore) QueryEmbedding(ctx context.Context, queryEmbedding []float32, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	if err := vecmath.CheckFinite(queryEmbedding); err != nil {
+		return nil, fmt.Errorf("invalid query embedding: %w", err)
+	}
+	if nResults <= 0 {
+		return nil, nil
+	}
+
+	args := []any{pgvector.NewVector(queryEmbedding), nResults}
+	conditions, err := pgvectorConditions(where, whereDocument, &args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pvs.pool.Query(ctx, fmt.Sprintf(`SELECT id, content, embedding, metadata, 1 - (embedding <=> $1) FROM %s
+		WHERE %s ORDER BY embedding <=> $1 LIMIT $2`, pvs.table, conditions), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pgvector: %w", err)
+	}
+	defer rows.Close()
+
+	var results []chromem.Result
+	for rows.Next() {
+		var res chromem.Result
+		var embedding pgvector.Vector
+		var metadata []byte
+		var similarity float64
+		if err := rows.Scan(&res.ID, &res.Content, &embedding, &metadata, &similarity); err != nil {
+			return nil, fmt.Errorf("failed to read pgvector result: %w", err)
+		}
+		if err := json.Unmarshal(metadata, &res.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata of %q: %w", res.ID, err)
+		}
+		res.Embedding = embedding.Slice()
+		res.Similarity = float32(similarity)
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to query pgvector: %w", err)
+	}
+	return results, nil
+}
+
+// GetByID retrieves a document by ID.
+func (pvs *PgvectorVectorStore) GetByID(ctx context.Context, id string) (chromem.Document, error) {
+	doc := chromem.Document{ID: id}
+	var embedding pgvector.Vector
+	var metadata []byte
+	err := pvs.pool.QueryRow(ctx, fmt.Sprintf("SELECT content, embedding, metadata FROM %s WHERE id = $1", pvs.table), id).
+		Scan(&doc.Content, &embedding, &metadata)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return chromem.Document{}, fmt.Errorf("document %q not found", id)
+	}
+	if err != nil {
+		return chromem.Document{}, fmt.Errorf("failed to get document %q: %w", id, err)
+	}
+	if err := json.Unmarshal(metadata, &doc.Metadata); err != nil {
+		return chromem.Document{}, fmt.Errorf("failed to decode metadata of %q: %w", id, err)
+	}
+	doc.Embedding = embedding.Slice()
+	return doc, nil
+}
+
+// UpdateMetadata replaces the metadata column of a document.
+func (pvs *PgvectorVectorStore) UpdateMetadata(ctx context.Context, id string, metadata map[string]string) error {
+	data, err := metadataJSON(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata of %q: %w", id, err)
+	}
+	tag, err := pvs.pool.Exec(ctx, fmt.Sprintf("UPDATE %s SET metadata = $2 WHERE id = $1", pvs.table), id, data)
+	if err != nil {
+		return fmt.Errorf("failed to update metadata of %q: %w", id, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return fmt.Errorf("document %q not found", id)
+	}
+	return nil
+}
+
+// Delete removes the documents with the given IDs, or, without IDs, those
+// matching the filters. Without IDs or filters nothing is deleted.
+func (pvs *PgvectorVectorStore) Delete(ctx context.Context, where, whereDocument map[string]string, ids ...string) error {
+	var args []any
+	var conditions string
+	if len(ids) > 0 {
+		args = append(args, ids)
+		conditions = "id = ANY($1)"
+	} else {
+		if len(where) == 0 && len(whereDocument) == 0 {
+			return nil
+		}
+		var err error
+		if conditions, err = pgvectorConditions(where, whereDocument, &args); err != nil {
+			return err
+		}
+	}
+
+	tag, err := pvs.pool.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE %s", pvs.table, conditions), args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete documents from pgvector: %w", err)
+	}
+	pvs.logger.Printf("Deleted %d documents from pgvector", tag.RowsAffected())
+	return nil
+}
+
+// ClearAll removes all documents from the table.
+func (pvs *PgvectorVectorStore) ClearAll(ctx context.Context) error {
+	if _, err := pvs.pool.Exec(ctx, fmt.Sprintf("TRUNCATE %s", pvs.table)); err != nil {
+		return fmt.Errorf("failed to truncate %s: %w", pvs.table, err)
+	}
+	pvs.logger.Printf("Cleared all documents from pgvector table %s", pvs.table)
+	return nil
+}
+
+// Count returns the number of documents in the table.
+func (pvs *PgvectorVectorStore) Count() int {
+	var n int
+	if err := pvs.pool.QueryRow(context.Background(), fmt.Sprintf("SELECT count(*) FROM %s", pvs.table)).Scan(&n); err != nil {
+		pvs.logger.Printf("Warning: Failed to count pgvector documents: %v", err)
+		return 0
+	}
+	return n
+}
+
+// Close closes the connection pool.
+func (pvs *PgvectorVectorStore) Close() error {
+	pvs.pool.Close()
+	return nil
+}
+
+// SaveToDisk is a no-op for pgvector since PostgreSQL persists every write.
+func (pvs *PgvectorVectorStore) SaveToDisk() error {
+	return nil
+}
+
+// BatchEmbed generates embeddings for multiple texts.
+func (pvs *PgvectorVectorStore) BatchEmbed(ctx context.Context, texts []string) ([][]float32, error) {
+	if pvs.batchEmbf != nil {
+		return pvs.batchEmbf(ctx, texts)
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		emb, err := pvs.embFunc(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = emb
+	}
+	return embeddings, nil
+}
+
+// ListDocuments returns the documents matching where, ordered by ID bytewise
+// like the other backends and paginated in the database.
+func (pvs *PgvectorVectorStore) ListDocuments(ctx context.Context, where map[string]string, offset, limit int) ([]chromem.Document, error) {
+	// LIMIT NULL returns all rows
+	var limitArg any
+	if limit > 0 {
+		limitArg = limit
+	}
+	args := []any{max(offset, 0), limitArg}
+	conditions, err := pgvectorConditions(where, nil, &args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pvs.pool.Query(ctx, fmt.Sprintf(`SELECT id, content, embedding, metadata FROM %s
+		WHERE %s ORDER BY id COLLATE "C" OFFSET $1 LIMIT $2`, pvs.table, conditions), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pgvector documents: %w", err)
+	}
+	return scanPgvectorDocuments(rows)
+}
+
+// ListByTag selects the documents whose tags include tag in the database.
+// Tags stored as a JSON array are matched as jsonb; the comma-separated form
+// of older versions is split into whole entries.
+func (pvs *PgvectorVectorStore) ListByTag(ctx context.Context, tag string) ([]chromem.Document, error) {
+	rows, err := pvs.pool.Query(ctx, fmt.Sprintf(`SELECT id, content, embedding, metadata FROM %s
+		WHERE CASE WHEN left(metadata->>'tags', 2) = '["'
+			THEN lower(metadata->>'tags')::jsonb @> jsonb_build_array($1::text)
+			ELSE $1 = ANY(regexp_split_to_array(lower(metadata->>'tags'), '\s*,\s*'))
+		END ORDER BY id COLLATE "C"`, pvs.table),
+		strings.ToLower(strings.TrimSpace(tag)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pgvector documents: %w", err)
+	}
+	docs, err := scanPgvectorDocuments(rows)
+	if err != nil {
+		return nil, err
+	}
+	return documentsWithTag(docs, tag), nil
+}
+
+// scanPgvectorDocuments reads and closes the rows of a document query.
+func scanPgvectorDocuments(rows pgx.Rows) ([]chromem.Document, error) {
+	defer rows.Close()
+
+	var docs []chromem.Document
+	for rows.Next() {
+		var doc chromem.Document
+		var embedding pgvector.Vector
+		var metadata []byte
+		if err := rows.Scan(&doc.ID, &doc.Content, &embedding, &metadata); err != nil {
+			return nil, fmt.Errorf("failed to read pgvector document: %w", err)
+		}
+		if err := json.Unmarshal(metadata, &doc.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to decode metadata of %q: %w", doc.ID, err)
+		}
+		doc.Embedding = embedding.Slice()
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list pgvector documents: %w", err)
+	}
+	return docs, nil
+}
+
+// MutationStamp returns 0 since a shared PostgreSQL table can be modified by other clients.
+func (pvs *PgvectorVectorStore) MutationStamp() uint64 {
+	return 0
+}
+
+// metadataJSON encodes document metadata for the JSONB column, with nil
+// metadata stored as an empty object.
+func metadataJSON(metadata map[string]string) ([]byte, error) {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	return json.Marshal(metadata)
+}
+
+// pgvectorConditions builds the WHERE clause for a metadata filter and a
+// chromem-style document filter ($contains, $not_contains), appending its
+// parameters to args.
+func pgvectorConditions(where, whereDocument map[string]string, args *[]any) (string, error) {
+	conditions := []string{"TRUE"}
+	if len(where) > 0 {
+		metadata, err := metadataJSON(where)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal metadata filter: %w", err)
+		}
+		*args = append(*args, metadata)
+		conditions = append(conditions, fmt.Sprintf("metadata @> $%d::jsonb", len(*args)))
+	}
+	for op, text := range whereDocument {
+		*args = append(*args, text)
+		switch op {
+		case "$contains":
+			conditions = append(conditions, fmt.Sprintf("strpos(content, $%d) > 0", len(*args)))
+		case "$not_contains":
+			conditions = append(conditions, fmt.Sprintf("strpos(content, $%d) = 0", len(*args)))
+		default:
+			return "", fmt.Errorf("unsupported document filter %q", op)
+		}
+	}
+	return strings.Join(conditions, " AND "), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// piiPattern is one kind of personal data found by a regular expression. A
+// match is only counted if valid is nil or accepts it.
+type piiPattern struct {
+	kind  string
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+// piiPatterns are applied in order and each one redacts its matches before
+// the next runs, so a card number is not also reported as a phone number.
+var piiPatterns = []piiPattern{
+	{"email", regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), nil},
+	{"credit_card", regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`), luhnValid},
+	{"ssn", regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), nil},
+	{"phone", regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{2,4}\)\s?|\b\d{2,4}[\s.-])\d{3,4}[\s.-]\d{3,4}\b`), nil},
+}
+
+// redactPII replaces the personal data in text with PIIRedaction and returns
+// the kinds found, in piiPatterns order.
+func redactPII(text string) (string, []string) {
+	var kinds []string
+	for _, p := range piiPatterns {
+		found := false
+		text = p.re.ReplaceAllStringFunc(text, func(match string) string {
+			if p.valid != nil && !p.valid(match) {
+				return match
+			}
+			found = true
+			return PIIRedaction
+		})
+		if found {
+			kinds = append(kinds, p.kind)
+		}
+	}
+	return text, kinds
+}
+
+// detectPII returns the kinds of personal data in text.
+func detectPII(text string) []string {
+	_, kinds := redactPII(text)
+	return kinds
+}
+
+// luhnValid reports whether the digits in number pass the Luhn checksum card
+// numbers carry, which rules out most other long numbers.
+func luhnValid(number string) bool {
+	sum, double := 0, false
+	for i := len(number) - 1; i >= 0; i-- {
+		c := number[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// scanForPIIHandler handles the scan_for_pii tool - reports the kinds of
+// personal data in a text without storing anything.
+func (a *App) scanForPIIHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	text, _ := args["text"].(string)
+	if strings.TrimSpace(text) == "" {
+		return mcp.NewToolResultError("text cannot be empty"), nil
+	}
+
+	kinds := detectPII(text)
+	if len(kinds) == 0 {
+		return mcp.NewToolResultText("No personal data found."), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Personal data found: %s.", strings.Join(kinds, ", "))), nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// playgroundEmbedder returns the embedder selected by the provider argument,
+// defaulting to the one used for stored memories.
+func (a *App) playgroundEmbedder(args map[string]any) (string, chromem.EmbeddingFunc, error) {
+	provider, _ := args["provider"].(string)
+	if provider = strings.TrimSpace(provider); provider == "" {
+		provider = a.embeddingProvider
+	}
+	embed, ok := a.embedders[provider]
+	if !ok {
+		available := make([]string, 0, len(a.embedders))
+		for name := range a.embedders {
+			available = append(available, name)
+		}
+		sort.Strings(available)
+		return "", nil, fmt.Errorf("embedding provider %q is not available (available: %s)", provider, strings.Join(available, ", "))
+	}
+	return provider, embed, nil
+}
+
+// playgroundInput prepares a text for embedding as a document or as a search
+// query and describes the task type or prefix the provider applies.
+func playgroundInput(provider, text string, asQuery bool) (string, string) {
+	if !asQuery {
+		if provider == "gemini" {
+			return text, "document (task type " + TaskTypeDocument + ")"
+		}
+		return text, "document (no prefix)"
+	}
+	switch provider {
+	case "gemini":
+		return QueryTaskPrefix + text, "query (task type " + TaskTypeQuery + ")"
+	case "ollama":
+		return QueryTaskPrefix + text, "query (" + QueryTaskPrefix + " prefix stripped, embedded as-is)"
+	}
+	return QueryTaskPrefix + text, "query (sent with literal " + QueryTaskPrefix + " prefix)"
+}
+
+// embeddedPair is two texts embedded by the playground for comparison.
+type embeddedPair struct {
+	provider     string
+	taskA, taskB string // How each text was embedded, see playgroundInput
+	vecA, vecB   []float32
+}
+
+// similarity returns the cosine similarity of the two embeddings.
+func (p *embeddedPair) similarity() float64 {
+	return vecmath.Cosine(p.vecA, p.vecB)
+}
+
+// embedPair embeds the text_a and text_b arguments with the embedder selected
+// by args, text_a as a search query if asQuery. On failure it returns a
+// message for the client.
+func (a *App) embedPair(ctx context.Context, args map[string]any, asQuery bool) (*embeddedPair, string) {
+	textA, _ := args["text_a"].(string)
+	textB, _ := args["text_b"].(string)
+	if strings.TrimSpace(textA) == "" || strings.TrimSpace(textB) == "" {
+		return nil, "text_a and text_b cannot be empty"
+	}
+
+	provider, embed, err := a.playgroundEmbedder(args)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	pair := &embeddedPair{provider: provider}
+	var inputA, inputB string
+	inputA, pair.taskA = playgroundInput(provider, textA, asQuery)
+	inputB, pair.taskB = playgroundInput(provider, textB, false)
+
+	if pair.vecA, err = embed(ctx, inputA); err != nil {
+		return nil, fmt.Sprintf("Embedding text_a failed: %v", err)
+	}
+	if pair.vecB, err = embed(ctx, inputB); err != nil {
+		return nil, fmt.Sprintf("Embedding text_b failed: %v", err)
+	}
+	if len(pair.vecA) != len(pair.vecB) {
+		return nil, fmt.Sprintf("Dimension mismatch: text_a has %d components, text_b has %d", len(pair.vecA), len(pair.vecB))
+	}
+	return pair, ""
+}
+
+// embedCompareHandler handles the embed_compare tool - embeds two texts
+// without storing them and reports how similar the embedder considers them.
+func (a *App) embedCompareHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	queryA, _ := args["a_as_query"].(bool)
+
+	pair, msg := a.embedPair(ctx, args, queryA)
+	if msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Provider: %s\n", pair.provider))
+	sb.WriteString(fmt.Sprintf("Cosine similarity: %.4f\n", pair.similarity()))
+	sb.WriteString(fmt.Sprintf("Dimension: %d\n", len(pair.vecA)))
+	sb.WriteString(fmt.Sprintf("text_a: %s, norm %.4f, normalized: %v\n", pair.taskA, vecmath.Norm(pair.vecA), vecmath.IsUnit(pair.vecA)))
+	sb.WriteString(fmt.Sprintf("text_b: %s, norm %.4f, normalized: %v\n", pair.taskB, vecmath.Norm(pair.vecB), vecmath.IsUnit(pair.vecB)))
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// similarityLabel describes a similarity score using thresholds.
+func similarityLabel(score float64, thresholds SimilarityThresholds) string {
+	switch {
+	case score >= thresholds.VerySimilar:
+		return "Very similar"
+	case score >= thresholds.SomewhatSimilar:
+		return "Somewhat similar"
+	default:
+		return "Unrelated"
+	}
+}
+
+// compareTextsHandler handles the compare_texts tool - embeds two texts as
+// documents like embed_compare and labels their similarity with the
+// configured thresholds.
+func (a *App) compareTextsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+
+	pair, msg := a.embedPair(ctx, args, false)
+	if msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	score := pair.similarity()
+	thresholds := a.settings().SimilarityThresholds
+	return mcp.NewToolResultText(fmt.Sprintf("Similarity: %.4f (%s)\nThresholds: very similar >= %.2f, somewhat similar >= %.2f",
+		score, similarityLabel(score, thresholds), thresholds.VerySimilar, thresholds.SomewhatSimilar)), nil
+}
+
+// embedInspectHandler handles the embed_inspect tool - embeds one text without
+// storing it and shows the leading components and norm of the vector.
+func (a *App) embedInspectHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	text, _ := args["text"].(string)
+	asQuery, _ := args["as_query"].(bool)
+
+	if strings.TrimSpace(text) == "" {
+		return mcp.NewToolResultError("Text cannot be empty"), nil
+	}
+
+	n := DefaultInspectComponents
+	if v, ok := args["components"].(float64); ok {
+		n = max(1, min(int(v), MaxInspectComponents))
+	}
+
+	provider, embed, err := a.playgroundEmbedder(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	input, task := playgroundInput(provider, text, asQuery)
+	vec, err := embed(ctx, input)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Embedding failed: %v", err)), nil
+	}
+	n = min(n, len(vec))
+
+	components := make([]string, n)
+	for i, x := range vec[:n] {
+		components[i] = fmt.Sprintf("%.5f", x)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Provider: %s\n", provider))
+	sb.WriteString(fmt.Sprintf("Embedded as: %s\n", task))
+	sb.WriteString(fmt.Sprintf("Dimension: %d\n", len(vec)))
+	sb.WriteString(fmt.Sprintf("Norm: %.4f (normalized: %v)\n", vecmath.Norm(vec), vecmath.IsUnit(vec)))
+	sb.WriteString(fmt.Sprintf("First %d components: [%s]\n", n, strings.Join(components, ", ")))
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
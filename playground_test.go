@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+)
+
+// scaledEmbedding is testEmbedding scaled by the text length, so its vectors
+// are not unit length and their dot product differs from their cosine.
+func scaledEmbedding(ctx context.Context, text string) ([]float32, error) {
+	vec, err := testEmbedding(ctx, text)
+	for i := range vec {
+		vec[i] *= float32(len(text))
+	}
+	return vec, err
+}
+
+// scoreIn returns the first number following label in text.
+func scoreIn(t *testing.T, text, label string) float64 {
+	t.Helper()
+	m := regexp.MustCompile(regexp.QuoteMeta(label) + ` (-?[0-9.]+)`).FindStringSubmatch(text)
+	if m == nil {
+		t.Fatalf("no %q in %q", label, text)
+	}
+	score, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return score
+}
+
+// compare_texts and embed_compare report the same cosine similarity, even for
+// an embedder that does not normalize its vectors.
+func TestCompareTextsMatchesEmbedCompare(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.embedders["scaled"] = scaledEmbedding
+	ta.embeddingProvider = "scaled"
+	args := map[string]any{"text_a": "the cat sat on the mat", "text_b": "a cat on a mat"}
+
+	compared, isErr := call(t, ta.compareTextsHandler, args)
+	if isErr {
+		t.Fatalf("compare_texts: %s", compared)
+	}
+	embedded, isErr := call(t, ta.embedCompareHandler, args)
+	if isErr {
+		t.Fatalf("embed_compare: %s", embedded)
+	}
+
+	a, _ := scaledEmbedding(context.Background(), "the cat sat on the mat")
+	b, _ := scaledEmbedding(context.Background(), "a cat on a mat")
+	want := vecmath.Cosine(a, b)
+	for name, got := range map[string]float64{
+		"compare_texts": scoreIn(t, compared, "Similarity:"),
+		"embed_compare": scoreIn(t, embedded, "Cosine similarity:"),
+	} {
+		if math.Abs(got-want) > 1e-4 {
+			t.Errorf("%s similarity = %.4f, want %.4f", name, got, want)
+		}
+	}
+}
+
+func TestCompareTextsLabels(t *testing.T) {
+	ta := newTestApp(t, nil)
+	for _, tc := range []struct {
+		textA, textB, label string
+	}{
+		{"deploy the service on friday", "deploy the service on friday", "Very similar"},
+		{"alpha beta gamma delta", "alpha beta epsilon zeta", "Somewhat similar"},
+		{"alpha beta gamma delta", "one two three four", "Unrelated"},
+	} {
+		text, isErr := call(t, ta.compareTextsHandler, map[string]any{"text_a": tc.textA, "text_b": tc.textB})
+		if isErr || !strings.Contains(text, "("+tc.label+")") {
+			t.Errorf("compare_texts(%q, %q) = %q, want label %s", tc.textA, tc.textB, text, tc.label)
+		}
+	}
+
+	if text, isErr := call(t, ta.compareTextsHandler, map[string]any{"text_a": " ", "text_b": "x"}); !isErr {
+		t.Errorf("compare_texts with an empty text succeeded: %s", text)
+	}
+}
+
+func TestSimilarityThresholdsMustBeOrdered(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		config string
+		valid  bool
+	}{
+		{"defaults", `{}`, true},
+		{"ordered", `{"similarity_thresholds": {"very_similar": 0.9, "somewhat_similar": 0.6}}`, true},
+		{"equal", `{"similarity_thresholds": {"very_similar": 0.7, "somewhat_similar": 0.7}}`, false},
+		{"reversed", `{"similarity_thresholds": {"very_similar": 0.4, "somewhat_similar": 0.6}}`, false},
+		{"above the default very_similar", `{"similarity_thresholds": {"somewhat_similar": 0.85}}`, false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+			if err := os.MkdirAll(filepath.Join(home, ".brainmcp"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(home, ".brainmcp", "config.json"), []byte(tc.config), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, err := LoadConfig(nil)
+			if tc.valid && err != nil {
+				t.Errorf("LoadConfig: %v", err)
+			}
+			if !tc.valid && (err == nil || !strings.Contains(err.Error(), "very_similar")) {
+				t.Errorf("LoadConfig err = %v, want a very_similar error", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// progressReporter reports the progress of a long-running tool call. When the
+// client sent a progress token with the request, each step is also sent as an
+// MCP progress notification so it can be shown while the call runs.
+type progressReporter struct {
+	app   *App
+	ctx   context.Context
+	token mcp.ProgressToken
+	total int
+	verb  string // e.g. "Imported"
+}
+
+// newProgress creates a reporter for a request processing total items.
+func (a *App) newProgress(ctx context.Context, request mcp.CallToolRequest, total int, verb string) *progressReporter {
+	p := &progressReporter{app: a, ctx: ctx, total: total, verb: verb}
+	if request.Params.Meta != nil {
+		p.token = request.Params.Meta.ProgressToken
+	}
+	return p
+}
+
+// report records that done of the total items are finished. A nil reporter
+// does nothing.
+func (p *progressReporter) report(done int) {
+	if p == nil {
+		return
+	}
+	message := fmt.Sprintf("%s %d/%d memories", p.verb, done, p.total)
+	p.app.logf(p.ctx, "%s", message)
+	p.notify(done, message)
+}
+
+// notify sends a progress notification if the client asked for them. The
+// total is left out when it is not known.
+func (p *progressReporter) notify(progress int, message string) {
+	if p.token == nil {
+		return
+	}
+	srv := server.ServerFromContext(p.ctx)
+	if srv == nil {
+		return
+	}
+	params := map[string]any{
+		"progressToken": p.token,
+		"progress":      progress,
+		"message":       message,
+	}
+	if p.total > 0 {
+		params["total"] = p.total
+	}
+	if err := srv.SendNotificationToClient(p.ctx, "notifications/progress", params); err != nil {
+		p.app.logf(p.ctx, "Warning: Failed to send progress notification: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// askPromptData is what the ask_brain prompt template can refer to.
+type askPromptData struct {
+	Memories string // One "- Memory [id]: content" line per retrieved memory
+	Question string
+	Citation string // Instruction to cite memories inline, empty when sources are turned off
+	History  string // Earlier exchanges of the conversation, empty without conversation_id
+}
+
+// parseAskPrompt parses an ask_brain prompt template, using the default
+// prompt if text is empty. The template is executed once with empty data so
+// references to unknown fields are reported here rather than on first use.
+func parseAskPrompt(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = DefaultAskPromptTemplate
+	}
+	tmpl, err := template.New("system_prompt").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if err := tmpl.Execute(io.Discard, askPromptData{}); err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// promptLabel describes a system_prompt for logs without printing it.
+func promptLabel(text string) string {
+	if strings.TrimSpace(text) == "" {
+		return "default"
+	}
+	return fmt.Sprintf("custom (%d characters)", len([]rune(text)))
+}
+
+// readPromptFile reads and checks the prompt template given with -system-prompt-file.
+func readPromptFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read system prompt file: %w", err)
+	}
+	if _, err := parseAskPrompt(string(data)); err != nil {
+		return "", fmt.Errorf("invalid system prompt in %s: %w", path, err)
+	}
+	return string(data), nil
+}
+
+// askPrompt renders the ask_brain prompt with the configured template.
+func (a *App) askPrompt(data askPromptData) (string, error) {
+	tmpl, err := parseAskPrompt(a.settings().SystemPrompt)
+	if err != nil {
+		return "", fmt.Errorf("invalid system_prompt: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("invalid system_prompt: %w", err)
+	}
+	return sb.String(), nil
+}
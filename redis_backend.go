@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/philippgille/chromem-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// redisIndexName matches the index names the Redis backend accepts. The name
+// doubles as the key prefix, so it must not contain glob characters.
+var redisIndexName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// redisScanCount is the number of keys requested per SCAN call.
+const redisScanCount = 500
+
+// RedisVectorStore implements VectorBackend on Redis with RediSearch. Each
+// document is a hash under "<index_name>:<id>" holding its content, metadata
+// as JSON and its embedding as a FLOAT32 blob. Metadata filters are indexed
+// through a TAG field of hashed key/value pairs.
+type RedisVectorStore struct {
+	client    *redis.Client
+	index     string
+	embFunc   chromem.EmbeddingFunc
+	batchEmbf BatchEmbeddingFunc
+	logger    *log.Logger
+	vectorDim int
+}
+
+// NewRedisVectorStore connects to Redis and creates the search index if it
+// does not exist.
+func NewRedisVectorStore(ctx context.Context, addr, password string, db int, indexName string, vectorDim int, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, logger *log.Logger) (*RedisVectorStore, error) {
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+	if !redisIndexName.MatchString(indexName) {
+		return nil, fmt.Errorf("invalid redis index name %q", indexName)
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr, Password: password, DB: db})
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	rvs := &RedisVectorStore{
+		client:    client,
+		index:     indexName,
+		embFunc:   embFunc,
+		batchEmbf: batchEmbf,
+		logger:    logger,
+		vectorDim: vectorDim,
+	}
+	if err := rvs.createIndex(ctx); err != nil {
+		client.Close()
+		return nil, err
+	}
+
+	logger.Printf("Connected to Redis at %s (index: %s, vector_dimension: %d)", addr, indexName, vectorDim)
+	return rvs, nil
+}
+
+// createIndex creates the search index over the store's hashes unless it
+// already exists.
+func (rvs *RedisVectorStore) createIndex(ctx context.Context) error {
+	err := rvs.client.FTCreate(ctx, rvs.index,
+		&redis.FTCreateOptions{OnHash: true, Prefix: []any{rvs.index + ":"}},
+		&redis.FieldSchema{FieldName: "meta", FieldType: redis.SearchFieldTypeTag, Separator: ","},
+		&redis.FieldSchema{FieldName: "embedding", FieldType: redis.SearchFieldTypeVector, VectorArgs: &redis.FTVectorArgs{
+			HNSWOptions: &redis.FTHNSWOptions{Type: "FLOAT32", Dim: rvs.vectorDim, DistanceMetric: "COSINE"},
+		}},
+	).Err()
+	if err != nil && !strings.Contains(err.Error(), "Index already exists") {
+		return fmt.Errorf("failed to create Redis index %s: %w", rvs.index, err)
+	}
+	if err == nil {
+		rvs.logger.Printf("Created Redis search index: %s", rvs.index)
+	}
+	return nil
+}
+
+// key returns the hash key of the document id.
+func (rvs *RedisVectorStore) key(id string) string {
+	return rvs.index + ":" + id
+}
+
+// AddDocuments stores each document as a hash in a single pipeline.
+func (rvs *RedisVectorStore) AddDocuments(ctx context.Context, documents []chromem.Document, concurrency int) error {
+	if len(documents) == 0 {
+		return nil
+	}
+
+	documents, err := embedMissing(ctx, rvs, documents)
+	if err != nil {
+		return fmt.Errorf("batch embedding failed: %w", err)
+	}
+
+	pipe := rvs.client.TxPipeline()
+	for _, doc := range documents {
+		if len(doc.Embedding) != rvs.vectorDim {
+			return fmt.Errorf("document %q has a %d-dimensional embedding, the index stores %d dimensions", doc.ID, len(doc.Embedding), rvs.vectorDim)
+		}
+		metadata, err := metadataJSON(doc.Metadata)
+		if err != nil {
+			return fmt.Errorf("failed to marshal metadata of %q: %w", doc.ID, err)
+		}
+		// Replace the whole hash so fields of an earlier version do not linger
+		pipe.Del(ctx, rvs.key(doc.ID))
+		pipe.HSet(ctx, rvs.key(doc.ID),
+			"id", doc.ID,
+			"content", doc.Content,
+			"metadata", string(metadata),
+			"meta", strings.Join(redisMetadataTags(doc.Metadata), ","),
+			"embedding", float32Blob(doc.Embedding),
+		)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to store documents in Redis: %w", err)
+	}
+
+	rvs.logger.Printf("Added %d documents to Redis", len(documents))
+	return nil
+}
+
+// AddDocument stores a single document.
+func (rvs *RedisVectorStore) AddDocument(ctx context.Context, document chromem.Document) error {
+	return rvs.AddDocuments(ctx, []chromem.Document{document}, 1)
+}
+
+// Query embeds the query text and delegates to QueryEmbedding.
+func (rvs *RedisVectorStore) Query(ctx context.Context, queryText string, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	embedding, err := rvs.embFunc(ctx, queryText)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	return rvs.QueryEmbedding(ctx, embedding, nResults, where, whereDocument)
+}
+
+// QueryEmbedding runs a KNN search with FT.SEARCH, prefiltered on the
+// metadata tags. Similarity is 1 minus the cosine distance Redis returns, the
+// cosine similarity the local store reports.
+func (rvs *RedisVectorStore) QueryEmbedding(ctx context.Context, queryEmbedding []float32, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	if err := vecmath.CheckFinite(queryEmbedding); err != nil {
+		return nil, fmt.Errorf("invalid query embedding: %w", err)
+	}
+	if nResults <= 0 {
+		return nil, nil
+	}
+
+	filter := "*"
+	if len(where) > 0 {
+		var conditions []string
+		for _, tag := range redisMetadataTags(where) {
+			conditions = append(conditions, "@meta:{"+tag+"}")
+		}
+		filter = "(" + strings.Join(conditions, " ") + ")"
+	}
+	res, err := rvs.client.FTSearchWithArgs(ctx, rvs.index, filter+"=>[KNN $k @embedding $vec AS vector_distance]", &redis.FTSearchOptions{
+		Params:         map[string]any{"k": nResults, "vec": float32Blob(queryEmbedding)},
+		SortBy:         []redis.FTSearchSortBy{{FieldName: "vector_distance", Asc: true}},
+		Limit:          nResults,
+		DialectVersion: 2,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Redis: %w", err)
+	}
+
+	results := make([]chromem.Result, 0, len(res.Docs))
+	for _, hit := range res.Docs {
+		doc, err := decodeRedisDocument(hit.Fields)
+		// The metadata JSON is authoritative; re-check the filter against it
+		if err != nil || !matchesWhere(doc.Metadata, where) {
+			continue
+		}
+		var distance float64
+		fmt.Sscanf(hit.Fields["vector_distance"], "%g", &distance)
+		results = append(results, chromem.Result{
+			ID:         doc.ID,
+			Metadata:   doc.Metadata,
+			Embedding:  doc.Embedding,
+			Content:    doc.Content,
+			Similarity: float32(1 - distance),
+		})
+	}
+	return results, nil
+}
+
+// ListByTag filters the enumerated documents on their tags. The metadata
+// index holds whole key/value pairs, not the single tags within them.
+func (rvs *RedisVectorStore) ListByTag(ctx context.Context, tag string) ([]chromem.Document, error) {
+	docs, err := rvs.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return documentsWithTag(docs, tag), nil
+}
+
+// GetByID retrieves a document by ID.
+func (rvs *RedisVectorStore) GetByID(ctx context.Context, id string) (chromem.Document, error) {
+	fields, err := rvs.client.HGetAll(ctx, rvs.key(id)).Result()
+	if err != nil {
+		return chromem.Document{}, fmt.Errorf("failed to get document %q: %w", id, err)
+	}
+	if len(fields) == 0 {
+		return chromem.Document{}, fmt.Errorf("document %q not found", id)
+	}
+	return decodeRedisDocument(fields)
+}
+
+// UpdateMetadata replaces the metadata fields of a document's hash, leaving
+// its content and embedding untouched.
+func (rvs *RedisVectorStore) UpdateMetadata(ctx context.Context, id string, metadata map[string]string) error {
+	data, err := metadataJSON(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata of %q: %w", id, err)
+	}
+	n, err := rvs.client.Exists(ctx, rvs.key(id)).Result()
+	if err != nil {
+		return fmt.Errorf("failed to get document %q: %w", id, err)
+	}
+	if n == 0 {
+		return fmt.Errorf("document %q not found", id)
+	}
+	err = rvs.client.HSet(ctx, rvs.key(id),
+		"metadata", string(data),
+		"meta", strings.Join(redisMetadataTags(metadata), ","),
+	).Err()
+	if err != nil {
+		return fmt.Errorf("failed to update metadata of %q: %w", id, err)
+	}
+	return nil
+}
+
+// Delete removes the documents with the given IDs, or, without IDs, those
+// matching where. Without IDs or a filter nothing is deleted.
+func (rvs *RedisVectorStore) Delete(ctx context.Context, where, whereDocument map[string]string, ids ...string) error {
+	if len(ids) == 0 {
+		if len(where) == 0 {
+			return nil
+		}
+		docs, err := rvs.ListDocuments(ctx, where, 0, 0)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			ids = append(ids, doc.ID)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = rvs.key(id)
+	}
+	if err := rvs.client.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to delete documents from Redis: %w", err)
+	}
+	rvs.logger.Printf("Deleted %d documents from Redis", len(ids))
+	return nil
+}
+
+// ClearAll drops the index with its documents and recreates it.
+func (rvs *RedisVectorStore) ClearAll(ctx context.Context) error {
+	if err := rvs.client.FTDropIndexWithArgs(ctx, rvs.index, &redis.FTDropIndexOptions{DeleteDocs: true}).Err(); err != nil {
+		return fmt.Errorf("failed to drop Redis index %s: %w", rvs.index, err)
+	}
+	if err := rvs.createIndex(ctx); err != nil {
+		return err
+	}
+	rvs.logger.Printf("Cleared all documents from Redis index %s", rvs.index)
+	return nil
+}
+
+// Count returns the number of documents in the index, from FT.INFO.
+func (rvs *RedisVectorStore) Count() int {
+	info, err := rvs.client.FTInfo(context.Background(), rvs.index).Result()
+	if err != nil {
+		rvs.logger.Printf("Warning: Failed to get Redis index info: %v", err)
+		return 0
+	}
+	return info.NumDocs
+}
+
+// Close closes the Redis connection.
+func (rvs *RedisVectorStore) Close() error {
+	return rvs.client.Close()
+}
+
+// SaveToDisk is a no-op for Redis since persistence is configured on the server.
+func (rvs *RedisVectorStore) SaveToDisk() error {
+	return nil
+}
+
+// BatchEmbed generates embeddings for multiple texts.
+func (rvs *RedisVectorStore) BatchEmbed(ctx context.Context, texts []string) ([][]float32, error) {
+	if rvs.batchEmbf != nil {
+		return rvs.batchEmbf(ctx, texts)
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		emb, err := rvs.embFunc(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		embeddings[i] = emb
+	}
+	return embeddings, nil
+}
+
+// ListDocuments scans the store's keys and reads their hashes in pipelined
+// batches. FT.SEARCH is not used since it caps how far results can be paged.
+func (rvs *RedisVectorStore) ListDocuments(ctx context.Context, where map[string]string, offset, limit int) ([]chromem.Document, error) {
+	var docs []chromem.Document
+	var cursor uint64
+	for {
+		keys, next, err := rvs.client.Scan(ctx, cursor, rvs.index+":*", redisScanCount).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan Redis keys: %w", err)
+		}
+
+		pipe := rvs.client.Pipeline()
+		cmds := make([]*redis.MapStringStringCmd, len(keys))
+		for i, key := range keys {
+			cmds[i] = pipe.HGetAll(ctx, key)
+		}
+		if len(keys) > 0 {
+			if _, err := pipe.Exec(ctx); err != nil {
+				return nil, fmt.Errorf("failed to read Redis documents: %w", err)
+			}
+		}
+		for _, cmd := range cmds {
+			doc, err := decodeRedisDocument(cmd.Val())
+			if err != nil || !matchesWhere(doc.Metadata, where) {
+				continue
+			}
+			docs = append(docs, doc)
+		}
+
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return paginateDocuments(docs, offset, limit), nil
+}
+
+// MutationStamp returns 0 since a shared Redis instance can be modified by other clients.
+func (rvs *RedisVectorStore) MutationStamp() uint64 {
+	return 0
+}
+
+// decodeRedisDocument builds a document from its hash fields.
+func decodeRedisDocument(fields map[string]string) (chromem.Document, error) {
+	id, ok := fields["id"]
+	if !ok {
+		return chromem.Document{}, fmt.Errorf("hash has no id field")
+	}
+	doc := chromem.Document{ID: id, Content: fields["content"]}
+	if err := json.Unmarshal([]byte(fields["metadata"]), &doc.Metadata); err != nil {
+		return chromem.Document{}, fmt.Errorf("failed to decode metadata of %q: %w", id, err)
+	}
+	embedding, err := float32sFromBlob(fields["embedding"])
+	if err != nil {
+		return chromem.Document{}, fmt.Errorf("failed to decode embedding of %q: %w", id, err)
+	}
+	doc.Embedding = embedding
+	return doc, nil
+}
+
+// redisMetadataTags returns the TAG values indexing metadata: one hash per
+// key/value pair, so filters need no escaping of RediSearch syntax. Matches
+// are re-checked against the metadata JSON, which rules out collisions.
+func redisMetadataTags(metadata map[string]string) []string {
+	tags := make([]string, 0, len(metadata))
+	for k, v := range metadata {
+		h := fnv.New64a()
+		h.Write([]byte(k + "\x00" + v))
+		tags = append(tags, fmt.Sprintf("%016x", h.Sum64()))
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// float32Blob encodes a vector as the little-endian FLOAT32 blob RediSearch expects.
+func float32Blob(v []float32) string {
+	buf := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(f))
+	}
+	return string(buf)
+}
+
+// float32sFromBlob decodes a FLOAT32 blob written by float32Blob.
+func float32sFromBlob(blob string) ([]float32, error) {
+	if len(blob)%4 != 0 {
+		return nil, fmt.Errorf("blob length %d is not a multiple of 4", len(blob))
+	}
+	v := make([]float32, len(blob)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32([]byte(blob[4*i : 4*i+4])))
+	}
+	return v, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// Relations are stored on the memory that declares them, as comma-separated
+// memory IDs under the relation's metadata key.
+const (
+	RelationSupersedes = "supersedes" // The memory replaces the listed, older memories
+	RelationPartOf     = "part_of"    // The memory is a part of the listed memories
+)
+
+// parseRelations reads remember's supersedes and part_of arguments into
+// metadata values. A memory cannot be related to itself.
+func parseRelations(id string, args map[string]any) (map[string]string, error) {
+	relations := make(map[string]string)
+	for _, relation := range []string{RelationSupersedes, RelationPartOf} {
+		value, _ := args[relation].(string)
+		ids := splitList(value)
+		for _, target := range ids {
+			if target == id {
+				return nil, fmt.Errorf("memory '%s' cannot list itself in %s", id, relation)
+			}
+		}
+		if len(ids) > 0 {
+			relations[relation] = mergeList("", strings.Join(ids, ","))
+		}
+	}
+	return relations, nil
+}
+
+// expandRelations follows the relations of the retrieved memories one hop.
+// A memory that another memory supersedes is replaced by its successor, since
+// the answer usually lives in the newer memory; the memories a result is part
+// of are appended, at most budget of them. It returns the new results and, for
+// every memory brought in by a relation, a note naming the relation and the
+// result it was reached from. Memories in the trash, past their expiry or in
+// contexts the client may not use are never brought in.
+func (a *App) expandRelations(ctx context.Context, results []chromem.Result, budget int) ([]chromem.Result, map[string]string, error) {
+	docs, err := a.vectorStore.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return results, nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	now := a.clock()
+	byID := make(map[string]chromem.Document, len(docs))
+	successors := make(map[string]string) // Superseded ID -> ID of the memory superseding it
+	for _, doc := range a.accessibleDocuments(ctx, docs) {
+		if isSoftDeleted(doc.Metadata) || isExpired(doc.Metadata, now) {
+			continue
+		}
+		byID[doc.ID] = doc
+		for _, old := range splitList(doc.Metadata[RelationSupersedes]) {
+			if _, ok := successors[old]; !ok {
+				successors[old] = doc.ID
+			}
+		}
+	}
+
+	present := make(map[string]bool, len(results))
+	for _, res := range results {
+		present[res.ID] = true
+	}
+	relatedResult := func(id string, from chromem.Result) chromem.Result {
+		doc := byID[id]
+		return chromem.Result{ID: doc.ID, Metadata: doc.Metadata, Embedding: doc.Embedding, Content: doc.Content, Similarity: from.Similarity}
+	}
+
+	via := make(map[string]string)
+	expanded := make([]chromem.Result, 0, len(results)+budget)
+	for _, res := range results {
+		successor, ok := successors[res.ID]
+		if !ok {
+			expanded = append(expanded, res)
+			continue
+		}
+		// The successor takes the predecessor's place unless it was retrieved itself
+		if !present[successor] {
+			expanded = append(expanded, relatedResult(successor, res))
+			present[successor] = true
+			via[successor] = fmt.Sprintf("via %s from [%s]", RelationSupersedes, res.ID)
+		}
+	}
+
+	kept := len(expanded)
+	for _, res := range expanded[:kept] {
+		for _, parent := range splitList(res.Metadata[RelationPartOf]) {
+			if budget <= 0 {
+				return expanded, via, nil
+			}
+			if _, ok := byID[parent]; !ok || present[parent] {
+				continue
+			}
+			expanded = append(expanded, relatedResult(parent, res))
+			present[parent] = true
+			via[parent] = fmt.Sprintf("via %s from [%s]", RelationPartOf, res.ID)
+			budget--
+		}
+	}
+	return expanded, via, nil
+}
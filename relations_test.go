@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// newRelationGraphApp returns a testApp holding a small relation graph:
+// rate-v2 supersedes rate-v1 and is part of handbook, and quota-note is part of
+// handbook too. Only rate-v1 shares words with the question askRate asks; its
+// successor and the handbook can only be reached through the relations.
+func newRelationGraphApp(t *testing.T, configure func(cfg *Config)) (*testApp, *fakeLLM) {
+	t.Helper()
+	ta := newTestApp(t, configure)
+	ta.remember(t, "handbook", "operations handbook for the service", nil)
+	ta.remember(t, "rate-v1", "the api rate limit is 100 requests", nil)
+	ta.remember(t, "rate-v2", "raised to 500 for paid plans", map[string]any{"supersedes": "rate-v1", "part_of": "handbook"})
+	ta.remember(t, "quota-note", "storage quota is 10 GB", map[string]any{"part_of": "handbook"})
+	ta.remember(t, "lunch", "lunch is served at noon", nil)
+	llm := newFakeLLM("The limit is 500 [rate-v2].")
+	ta.llm = llm
+	return ta, llm
+}
+
+// askRate asks about the rate limit with one retrieved memory and returns the
+// answer and the prompt the LLM was given.
+func askRate(t *testing.T, ta *testApp, llm *fakeLLM, extra map[string]any) (string, string) {
+	t.Helper()
+	args := map[string]any{"question": "what is the api rate limit", "max_results": 1.0, "include_sources": true}
+	for k, v := range extra {
+		args[k] = v
+	}
+	text, isErr := call(t, ta.askBrainHandler, args)
+	if isErr {
+		t.Fatalf("ask_brain: %s", text)
+	}
+	prompts := llm.Prompts()
+	return text, prompts[len(prompts)-1]
+}
+
+func TestAskBrainFollowsSupersedes(t *testing.T) {
+	ta, llm := newRelationGraphApp(t, nil)
+
+	// Without expansion only the predecessor the question matches is used
+	text, prompt := askRate(t, ta, llm, nil)
+	if !strings.Contains(prompt, "Memory [rate-v1]: the api rate limit is 100 requests") || strings.Contains(prompt, "raised to 500") {
+		t.Fatalf("prompt without expansion:\n%s", prompt)
+	}
+	if strings.Contains(text, "via ") {
+		t.Errorf("sources without expansion carry relation notes:\n%s", text)
+	}
+
+	text, prompt = askRate(t, ta, llm, map[string]any{"expand_relations": true})
+	if !strings.Contains(prompt, "Memory [rate-v2] (via supersedes from [rate-v1]): raised to 500 for paid plans") {
+		t.Errorf("the successor did not reach the prompt:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "100 requests") {
+		t.Errorf("the superseded memory was not replaced:\n%s", prompt)
+	}
+	if !strings.Contains(prompt, "Memory [handbook] (via part_of from [rate-v2]): operations handbook") {
+		t.Errorf("the successor's parent was not added:\n%s", prompt)
+	}
+	for _, unrelated := range []string{"quota-note", "lunch"} {
+		if strings.Contains(prompt, "["+unrelated+"]") {
+			t.Errorf("%s is not one hop from a result but reached the prompt:\n%s", unrelated, prompt)
+		}
+	}
+	for _, want := range []string{"- [rate-v2] (Sim: ", ", via supersedes from [rate-v1], cited)", ", via part_of from [rate-v2])"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("sources do not contain %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestExpandRelationsConfigDefault(t *testing.T) {
+	ta, llm := newRelationGraphApp(t, func(cfg *Config) { cfg.ExpandRelations = true })
+	if _, prompt := askRate(t, ta, llm, nil); !strings.Contains(prompt, "raised to 500") {
+		t.Errorf("expand_relations in the config did not expand:\n%s", prompt)
+	}
+	if _, prompt := askRate(t, ta, llm, map[string]any{"expand_relations": false}); strings.Contains(prompt, "raised to 500") {
+		t.Errorf("expand_relations false did not override the config:\n%s", prompt)
+	}
+}
+
+func TestExpandRelationsSkipsTrashedSuccessor(t *testing.T) {
+	ta, llm := newRelationGraphApp(t, func(cfg *Config) { cfg.SoftDelete = true })
+	if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "rate-v2"}); isErr {
+		t.Fatalf("delete_memory: %s", text)
+	}
+
+	_, prompt := askRate(t, ta, llm, map[string]any{"expand_relations": true})
+	if !strings.Contains(prompt, "Memory [rate-v1]: the api rate limit") || strings.Contains(prompt, "raised to 500") || strings.Contains(prompt, "[handbook]") {
+		t.Errorf("a trashed successor replaced its predecessor:\n%s", prompt)
+	}
+}
+
+func TestParseRelationsRejectsSelfReference(t *testing.T) {
+	ta := newTestApp(t, nil)
+	text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "loop", "content": "points at itself", "part_of": "other, loop"})
+	if !isErr || !strings.Contains(text, "memory 'loop' cannot list itself in part_of") {
+		t.Errorf("remember with a self relation = %q", text)
+	}
+
+	relations, err := parseRelations("m", map[string]any{"supersedes": "a, b,a", "part_of": ""})
+	if err != nil || relations[RelationSupersedes] != "a,b" || len(relations) != 1 {
+		t.Errorf("parseRelations = %v, %v; want supersedes a,b only", relations, err)
+	}
+}
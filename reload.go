@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Settings are the configuration values that can change while the server
+// runs. Handlers read them through a.settings(), which returns an immutable
+// snapshot, so a request sees either the old or the new settings of a reload
+// but never a mix.
+type Settings struct {
+	CiteSources            bool
+	SoftDelete             bool
+	AutoContext            bool
+	PIIDetection           bool
+	PIIReject              bool
+	DefaultSearchResults   int
+	MaxInlineResponseBytes int
+	SimilarityThresholds   SimilarityThresholds
+	NearDuplicateThreshold float64
+	ExpandRelations        bool
+	UseMMR                 bool
+	MMRLambda              float64
+	Rerank                 bool
+	RerankCandidates       int
+	ChunkSize              int
+	ChunkOverlap           int
+	SystemPrompt           string
+	MaxConversationTurns   int
+	MaxMemoriesPerContext  int // 0 means unlimited
+	LLMTemperature         float64
+	LLMMaxOutputTokens     int
+	LLMTopP                float64
+}
+
+// settingOverrides holds settings given as command line flags, which take
+// precedence over config.json on startup and on every reload.
+type settingOverrides struct {
+	citeSources          bool   // -cite-sources
+	defaultSearchResults int    // -default-search-results, 0 if not given
+	systemPrompt         string // Contents of -system-prompt-file, "" if not given
+	embeddingDimension   int    // -embedding-dim, 0 if not given
+	useMMR               bool   // -use-mmr
+	maxPerContext        *int   // -max-per-context, nil if not given
+}
+
+// applyStartupOverrides applies the flags that override startup-only
+// settings to cfg, so a reload does not report them as changed.
+func applyStartupOverrides(cfg *Config, overrides settingOverrides) {
+	if overrides.embeddingDimension > 0 {
+		cfg.Gemini.EmbeddingDimension = overrides.embeddingDimension
+	}
+}
+
+// newSettings builds the runtime settings from cfg and the command line overrides.
+func newSettings(cfg *Config, overrides settingOverrides) *Settings {
+	searchResults := cfg.DefaultSearchResults
+	if overrides.defaultSearchResults > 0 {
+		searchResults = overrides.defaultSearchResults
+	}
+	systemPrompt := cfg.SystemPrompt
+	if overrides.systemPrompt != "" {
+		systemPrompt = overrides.systemPrompt
+	}
+	maxPerContext := cfg.MaxMemoriesPerContext
+	if overrides.maxPerContext != nil {
+		maxPerContext = *overrides.maxPerContext
+	}
+	return &Settings{
+		CiteSources:            overrides.citeSources || cfg.CiteSourcesEnabled(),
+		SoftDelete:             cfg.SoftDelete,
+		AutoContext:            cfg.AutoContext,
+		PIIDetection:           cfg.PIIDetection,
+		PIIReject:              cfg.PIIReject,
+		DefaultSearchResults:   max(1, min(searchResults, MaxSearchResultsCap)),
+		MaxInlineResponseBytes: cfg.MaxInlineResponseBytes,
+		SimilarityThresholds:   cfg.SimilarityThresholds,
+		NearDuplicateThreshold: cfg.NearDuplicateThreshold,
+		ExpandRelations:        cfg.ExpandRelations,
+		UseMMR:                 overrides.useMMR || cfg.UseMMR,
+		MMRLambda:              min(cfg.MMRLambda, 1),
+		Rerank:                 cfg.AskBrain.Rerank,
+		RerankCandidates:       min(cfg.AskBrain.RerankCandidates, MaxSearchResultsCap),
+		ChunkSize:              cfg.ChunkSize,
+		ChunkOverlap:           cfg.ChunkOverlap,
+		SystemPrompt:           systemPrompt,
+		MaxConversationTurns:   cfg.MaxConversationTurns,
+		MaxMemoriesPerContext:  max(0, maxPerContext),
+		LLMTemperature:         valueOr(cfg.Gemini.LLMTemperature, DefaultLLMTemperature),
+		LLMMaxOutputTokens:     cfg.Gemini.LLMMaxOutputTokens,
+		LLMTopP:                valueOr(cfg.Gemini.LLMTopP, DefaultLLMTopP),
+	}
+}
+
+// valueOr returns *v, or def if v is nil.
+func valueOr[T any](v *T, def T) T {
+	if v == nil {
+		return def
+	}
+	return *v
+}
+
+// settings returns the current settings snapshot. Callers should load it once
+// per request and keep using that snapshot.
+func (a *App) settings() *Settings {
+	if s := a.currentSettings.Load(); s != nil {
+		return s
+	}
+	return newSettings(DefaultConfig(), a.overrides)
+}
+
+// configChange is one setting that differs between two configurations.
+type configChange struct {
+	Key      string
+	Old, New any
+}
+
+func (c configChange) String() string {
+	return fmt.Sprintf("%s: %v -> %v", c.Key, c.Old, c.New)
+}
+
+// reloadableChanges lists the changed settings that reloadConfig applies in place.
+func reloadableChanges(old, cfg *Config) []configChange {
+	var changes []configChange
+	add := func(key string, o, n any) {
+		if !reflect.DeepEqual(o, n) {
+			changes = append(changes, configChange{key, o, n})
+		}
+	}
+	add("cite_sources", old.CiteSourcesEnabled(), cfg.CiteSourcesEnabled())
+	add("soft_delete", old.SoftDelete, cfg.SoftDelete)
+	add("auto_context", old.AutoContext, cfg.AutoContext)
+	add("pii_detection", old.PIIDetection, cfg.PIIDetection)
+	add("pii_reject", old.PIIReject, cfg.PIIReject)
+	add("default_search_results", old.DefaultSearchResults, cfg.DefaultSearchResults)
+	add("max_inline_response_bytes", old.MaxInlineResponseBytes, cfg.MaxInlineResponseBytes)
+	add("similarity_thresholds.very_similar", old.SimilarityThresholds.VerySimilar, cfg.SimilarityThresholds.VerySimilar)
+	add("similarity_thresholds.somewhat_similar", old.SimilarityThresholds.SomewhatSimilar, cfg.SimilarityThresholds.SomewhatSimilar)
+	add("near_duplicate_threshold", old.NearDuplicateThreshold, cfg.NearDuplicateThreshold)
+	add("expand_relations", old.ExpandRelations, cfg.ExpandRelations)
+	add("use_mmr", old.UseMMR, cfg.UseMMR)
+	add("mmr_lambda", old.MMRLambda, cfg.MMRLambda)
+	add("ask_brain.rerank", old.AskBrain.Rerank, cfg.AskBrain.Rerank)
+	add("ask_brain.rerank_candidates", old.AskBrain.RerankCandidates, cfg.AskBrain.RerankCandidates)
+	add("chunk_size", old.ChunkSize, cfg.ChunkSize)
+	add("chunk_overlap", old.ChunkOverlap, cfg.ChunkOverlap)
+	add("max_conversation_turns", old.MaxConversationTurns, cfg.MaxConversationTurns)
+	add("max_memories_per_context", old.MaxMemoriesPerContext, cfg.MaxMemoriesPerContext)
+	add("gemini.llm_temperature", valueOr(old.Gemini.LLMTemperature, DefaultLLMTemperature), valueOr(cfg.Gemini.LLMTemperature, DefaultLLMTemperature))
+	add("gemini.llm_max_output_tokens", old.Gemini.LLMMaxOutputTokens, cfg.Gemini.LLMMaxOutputTokens)
+	add("gemini.llm_top_p", valueOr(old.Gemini.LLMTopP, DefaultLLMTopP), valueOr(cfg.Gemini.LLMTopP, DefaultLLMTopP))
+	if old.SystemPrompt != cfg.SystemPrompt {
+		changes = append(changes, configChange{"system_prompt", promptLabel(old.SystemPrompt), promptLabel(cfg.SystemPrompt)})
+	}
+	return changes
+}
+
+// restartRequiredChanges lists the changed settings that are only read on
+// startup: the providers and their models, the vector backend, the timezone
+// the activity log is bucketed in, the encryption key, the backup and expiry
+// schedules, the S3 bucket, the conversation ttl, the embedding cache size,
+// the metrics port, the HTTP address, the OpenTelemetry endpoint and the
+// audit log path. Values are not included, since they may hold API keys.
+func restartRequiredChanges(old, cfg *Config) []string {
+	var keys []string
+	add := func(key string, o, n any) {
+		if !reflect.DeepEqual(o, n) {
+			keys = append(keys, key)
+		}
+	}
+	add("embedding_provider", old.EmbeddingProvider, cfg.EmbeddingProvider)
+	add("qdrant", old.Qdrant, cfg.Qdrant)
+	add("pgvector", old.Pgvector, cfg.Pgvector)
+	add("redis", old.Redis, cfg.Redis)
+	add("gemini", geminiStartupSettings(old.Gemini), geminiStartupSettings(cfg.Gemini))
+	add("lmstudio", old.LMStudio, cfg.LMStudio)
+	add("ollama", old.Ollama, cfg.Ollama)
+	add("llm_provider", old.LLMProvider, cfg.LLMProvider)
+	add("openai_compat", old.OpenAICompat, cfg.OpenAICompat)
+	add("timezone", old.Timezone, cfg.Timezone)
+	add("storage", old.Storage, cfg.Storage)
+	add("backup", old.Backup, cfg.Backup)
+	add("s3", old.S3, cfg.S3)
+	add("metrics_port", old.MetricsPort, cfg.MetricsPort)
+	add("http_port", old.HTTPPort, cfg.HTTPPort)
+	add("http_host", old.HTTPHost, cfg.HTTPHost)
+	add("otel_endpoint", old.OtelEndpoint, cfg.OtelEndpoint)
+	add("audit_log_path", old.AuditLogPath, cfg.AuditLogPath)
+	add("expiry_interval", old.ExpiryInterval, cfg.ExpiryInterval)
+	add("conversation_ttl", old.ConversationTTL, cfg.ConversationTTL)
+	add("cache_max_entries", old.CacheMaxEntries, cfg.CacheMaxEntries)
+	return keys
+}
+
+// geminiStartupSettings returns g without the sampling settings of ask_brain
+// answers, which are reloadable.
+func geminiStartupSettings(g GeminiConfig) GeminiConfig {
+	g.LLMTemperature, g.LLMMaxOutputTokens, g.LLMTopP = nil, 0, nil
+	return g
+}
+
+// keepStartupSettings copies the settings that require a restart from old to
+// cfg, so they keep being reported until the server is restarted.
+func keepStartupSettings(cfg, old *Config) {
+	cfg.EmbeddingProvider = old.EmbeddingProvider
+	cfg.Qdrant = old.Qdrant
+	cfg.Pgvector = old.Pgvector
+	cfg.Redis = old.Redis
+	sampling := cfg.Gemini
+	cfg.Gemini = old.Gemini
+	cfg.Gemini.LLMTemperature, cfg.Gemini.LLMMaxOutputTokens, cfg.Gemini.LLMTopP = sampling.LLMTemperature, sampling.LLMMaxOutputTokens, sampling.LLMTopP
+	cfg.LMStudio = old.LMStudio
+	cfg.Ollama = old.Ollama
+	cfg.LLMProvider = old.LLMProvider
+	cfg.OpenAICompat = old.OpenAICompat
+	cfg.Timezone = old.Timezone
+	cfg.Backup = old.Backup
+	cfg.S3 = old.S3
+	cfg.MetricsPort = old.MetricsPort
+	cfg.OtelEndpoint = old.OtelEndpoint
+	cfg.AuditLogPath = old.AuditLogPath
+	cfg.ExpiryInterval = old.ExpiryInterval
+	cfg.ConversationTTL = old.ConversationTTL
+	cfg.CacheMaxEntries = old.CacheMaxEntries
+}
+
+// reloadResult describes the outcome of a configuration reload.
+type reloadResult struct {
+	Applied  []configChange
+	Rejected []string // Changed settings that need a restart
+}
+
+// reloadConfig re-reads config.json, applies the settings that can change at
+// runtime and reports those that need a restart. The applied diff is logged
+// and written to the audit log.
+func (a *App) reloadConfig(ctx context.Context) (*reloadResult, error) {
+	cfg, err := LoadConfig(a.logger)
+	if err != nil {
+		return nil, err
+	}
+
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	applyStartupOverrides(cfg, a.overrides)
+	old := a.config
+	if old == nil {
+		old = DefaultConfig()
+	}
+	result := &reloadResult{
+		Applied:  reloadableChanges(old, cfg),
+		Rejected: restartRequiredChanges(old, cfg),
+	}
+	keepStartupSettings(cfg, old)
+	a.config = cfg
+	a.currentSettings.Store(newSettings(cfg, a.overrides))
+
+	applied := make([]string, len(result.Applied))
+	for i, c := range result.Applied {
+		applied[i] = c.String()
+	}
+	details := fmt.Sprintf("applied: %s", strings.Join(applied, ", "))
+	if len(applied) == 0 {
+		details = "applied: none"
+	}
+	if len(result.Rejected) > 0 {
+		details += fmt.Sprintf("; restart required: %s", strings.Join(result.Rejected, ", "))
+	}
+	a.logf(ctx, "Reloaded config (%s)", details)
+	a.recordAudit(ctx, AuditEntry{Tool: "reload_config", ClientID: a.clientIDFrom(ctx), Status: "ok", Details: details})
+	return result, nil
+}
+
+// reloadConfigHandler handles the reload_config tool.
+func (a *App) reloadConfigHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	result, err := a.reloadConfig(ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Reload failed, keeping the current settings: %v", err)), nil
+	}
+
+	var sb strings.Builder
+	if len(result.Applied) == 0 {
+		sb.WriteString("Config reloaded, no reloadable settings changed.\n")
+	} else {
+		sb.WriteString("Config reloaded. Applied:\n")
+		for _, c := range result.Applied {
+			sb.WriteString(fmt.Sprintf("- %s\n", c))
+		}
+	}
+	if len(result.Rejected) > 0 {
+		sb.WriteString("\nNot applied, these settings only take effect after a restart:\n")
+		for _, key := range result.Rejected {
+			sb.WriteString(fmt.Sprintf("- %s\n", key))
+		}
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
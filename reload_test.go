@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestReloadLLMSamplingSettings(t *testing.T) {
+	old := DefaultConfig()
+	cfg := DefaultConfig()
+	temperature, topP := 0.2, 0.5
+	cfg.Gemini.LLMTemperature = &temperature
+	cfg.Gemini.LLMMaxOutputTokens = 256
+	cfg.Gemini.LLMTopP = &topP
+
+	var applied []string
+	for _, c := range reloadableChanges(old, cfg) {
+		applied = append(applied, c.Key)
+	}
+	want := []string{"gemini.llm_temperature", "gemini.llm_max_output_tokens", "gemini.llm_top_p"}
+	if !slices.Equal(applied, want) {
+		t.Errorf("reloadableChanges = %v, want %v", applied, want)
+	}
+	if rejected := restartRequiredChanges(old, cfg); len(rejected) != 0 {
+		t.Errorf("restartRequiredChanges = %v, want none", rejected)
+	}
+
+	cfg.Gemini.LLMModel = "another-model"
+	if rejected := restartRequiredChanges(old, cfg); !slices.Equal(rejected, []string{"gemini"}) {
+		t.Errorf("restartRequiredChanges after a model change = %v, want [gemini]", rejected)
+	}
+
+	keepStartupSettings(cfg, old)
+	if cfg.Gemini.LLMModel != old.Gemini.LLMModel {
+		t.Errorf("keepStartupSettings kept the new model %q", cfg.Gemini.LLMModel)
+	}
+	settings := newSettings(cfg, settingOverrides{})
+	if settings.LLMTemperature != 0.2 || settings.LLMMaxOutputTokens != 256 || settings.LLMTopP != 0.5 {
+		t.Errorf("settings after reload = temperature %g, max tokens %d, top_p %g; want 0.2, 256, 0.5",
+			settings.LLMTemperature, settings.LLMMaxOutputTokens, settings.LLMTopP)
+	}
+}
+
+// writeConfig writes cfg as the config.json LoadConfig reads, under a
+// temporary home directory.
+func writeConfig(t *testing.T, cfg *Config) {
+	t.Helper()
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(os.Getenv("HOME"), ".brainmcp"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(os.Getenv("HOME"), ".brainmcp", "config.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadedThresholdAppliesToNextSearch(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ta := newTestApp(t, nil)
+	ta.remember(t, "apples", "apples are red and crunchy", nil)
+	ta.remember(t, "apples-again", "apples are red and crunchy and sweet", nil)
+	for i := range 4 {
+		ta.remember(t, fmt.Sprintf("other-%d", i), fmt.Sprintf("unrelated note number %d about taxes", i), nil)
+	}
+
+	pair := func() bool {
+		text, isErr := call(t, ta.findDuplicatesHandler, nil)
+		if isErr {
+			t.Fatalf("find_duplicates: %s", text)
+		}
+		return strings.Contains(text, "apples")
+	}
+	results := func() int {
+		text, isErr := call(t, ta.searchHandler, map[string]any{"query": "apples"})
+		if isErr {
+			t.Fatalf("search_memory: %s", text)
+		}
+		return len(advancedResultID.FindAllString(text, -1))
+	}
+	if pair() || results() != DefaultSearchResults {
+		t.Fatalf("before the reload: pair found %v, %d results", pair(), results())
+	}
+
+	cfg := *ta.config
+	cfg.NearDuplicateThreshold = 0.5
+	cfg.DefaultSearchResults = 2
+	cfg.Timezone = "Asia/Tokyo"
+	writeConfig(t, &cfg)
+	text, isErr := call(t, ta.reloadConfigHandler, nil)
+	if isErr {
+		t.Fatalf("reload_config: %s", text)
+	}
+	for _, want := range []string{"- near_duplicate_threshold: 0.95 -> 0.5", "- default_search_results: 5 -> 2", "only take effect after a restart:\n- timezone"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("reload_config does not report %q:\n%s", want, text)
+		}
+	}
+
+	// The very next calls see the new settings, without a restart
+	if !pair() {
+		t.Error("find_duplicates ignores the reloaded near_duplicate_threshold")
+	}
+	if n := results(); n != 2 {
+		t.Errorf("search_memory returned %d results, want the reloaded default 2", n)
+	}
+	if ta.config.Timezone != DefaultConfig().Timezone {
+		t.Errorf("the timezone was applied without a restart: %q", ta.config.Timezone)
+	}
+
+	// Flipping back applies as well
+	cfg.NearDuplicateThreshold = DefaultNearDuplicateThreshold
+	writeConfig(t, &cfg)
+	call(t, ta.reloadConfigHandler, nil)
+	if pair() {
+		t.Error("find_duplicates still uses the previous threshold")
+	}
+}
+
+// Run with -race: searches running while the config is reloaded use either
+// the old or the new default result count, never anything else.
+func TestReloadDuringSearches(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	ta := newTestApp(t, nil)
+	for i := range 8 {
+		ta.remember(t, fmt.Sprintf("m%d", i), fmt.Sprintf("memory number %d", i), nil)
+	}
+	cfg := *ta.config
+	cfg.DefaultSearchResults = 3
+	writeConfig(t, &cfg)
+
+	var wg sync.WaitGroup
+	counts := make(chan int, 40)
+	for range 40 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			text, _ := call(t, ta.searchHandler, map[string]any{"query": "memory"})
+			counts <- len(advancedResultID.FindAllString(text, -1))
+		}()
+	}
+	if text, isErr := call(t, ta.reloadConfigHandler, nil); isErr {
+		t.Errorf("reload_config: %s", text)
+	}
+	wg.Wait()
+	close(counts)
+	for n := range counts {
+		if n != DefaultSearchResults && n != 3 {
+			t.Errorf("a search returned %d results, want %d or 3", n, DefaultSearchResults)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// rerankSchema is the structured reply requested from the LLM when reranking:
+// a relevance score for every candidate memory.
+var rerankSchema = &AnswerSchema{
+	Type: "object",
+	Properties: map[string]*AnswerSchema{
+		"scores": {
+			Type: "array",
+			Items: &AnswerSchema{
+				Type: "object",
+				Properties: map[string]*AnswerSchema{
+					"id":    {Type: "string", Description: "Memory ID as shown in brackets"},
+					"score": {Type: "number", Description: "Relevance from 0 (irrelevant) to 10 (answers the question)"},
+				},
+				Required: []string{"id", "score"},
+			},
+		},
+	},
+	Required: []string{"scores"},
+}
+
+// rerankPrompt asks the LLM to score every candidate's relevance to the question.
+func rerankPrompt(question string, candidates []chromem.Result) string {
+	var sb strings.Builder
+	sb.WriteString("Rate how relevant each memory below is to answering the question, from 0 (irrelevant) to 10 (directly answers it). Score every memory, using the IDs shown in brackets.\n\n")
+	sb.WriteString("Question: " + question + "\n\nMemories:\n")
+	for _, res := range candidates {
+		sb.WriteString(fmt.Sprintf("- [%s]: %s\n", res.ID, res.Content))
+	}
+	sb.WriteString(fmt.Sprintf("\nRespond ONLY with a JSON object conforming to this JSON schema:\n%s", rerankSchema))
+	return sb.String()
+}
+
+// parseRerankScores reads the LLM's scores for the candidates. Scores for IDs
+// that are not candidates are ignored; a reply that scores none of them is an
+// error.
+func parseRerankScores(raw string, candidates []chromem.Result) (map[string]float64, error) {
+	value, violations := rerankSchema.ValidateAnswer(raw)
+	if len(violations) > 0 {
+		return nil, fmt.Errorf("reply does not match the schema: %s", strings.Join(violations, "; "))
+	}
+
+	known := make(map[string]bool, len(candidates))
+	for _, res := range candidates {
+		known[res.ID] = true
+	}
+	scores := make(map[string]float64)
+	for _, item := range value.(map[string]any)["scores"].([]any) {
+		entry := item.(map[string]any)
+		id := strings.Trim(strings.TrimSpace(entry["id"].(string)), "[]")
+		if known[id] {
+			scores[id] = entry["score"].(float64)
+		}
+	}
+	if len(scores) == 0 {
+		return nil, fmt.Errorf("reply scores none of the candidates")
+	}
+	return scores, nil
+}
+
+// rerank asks the LLM to score the candidates' relevance to the question in a
+// single structured call and returns the keep best, ordered by score and then
+// by their retrieval order. Candidates the reply leaves out rank last. If the
+// call or its reply fails, the first keep candidates are returned unchanged
+// along with the error.
+func (a *App) rerank(ctx context.Context, question string, candidates []chromem.Result, keep int) ([]chromem.Result, error) {
+	if len(candidates) <= 1 {
+		return candidates, nil
+	}
+	fallback := candidates[:min(keep, len(candidates))]
+
+	var usage TokenUsage
+	raw, err := a.generate(ctx, rerankPrompt(question, candidates), &GenerateOptions{Schema: rerankSchema, Usage: &usage})
+	if err != nil {
+		return fallback, fmt.Errorf("rerank call failed: %w", err)
+	}
+	a.logf(ctx, "Reranked %d candidates (tokens: %d prompt, %d output)", len(candidates), usage.PromptTokens, usage.OutputTokens)
+
+	scores, err := parseRerankScores(raw, candidates)
+	if err != nil {
+		return fallback, err
+	}
+
+	ranked := make([]chromem.Result, len(candidates))
+	copy(ranked, candidates)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		si, iok := scores[ranked[i].ID]
+		sj, jok := scores[ranked[j].ID]
+		if iok != jok {
+			return iok
+		}
+		return si > sj
+	})
+	return ranked[:min(keep, len(ranked))], nil
+}
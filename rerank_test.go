@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// newRerankApp returns a testApp on a fake backend that retrieves plan-1 to
+// plan-5 in that order for rerankQuestion, reranking 5 candidates when
+// enabled. Its LLM answers rerank prompts with rerankReply and every other
+// prompt with a fixed answer.
+func newRerankApp(t *testing.T, enabled bool, rerankReply func() (string, error)) (*testApp, *fakeLLM) {
+	t.Helper()
+	vectors := syntheticEmbedder{rerankQuestion: angled(1, 1)}
+	contents := map[string]string{
+		"plan-1": "the free plan has one seat",
+		"plan-2": "the starter plan has three seats",
+		"plan-3": "plans renew every month",
+		"plan-4": "the team plan has twenty seats",
+		"plan-5": "teams over twenty seats need enterprise",
+	}
+	for i, id := range []string{"plan-1", "plan-2", "plan-3", "plan-4", "plan-5"} {
+		vectors[contents[id]] = angled(0.9-0.1*float64(i), i+2)
+	}
+	ta := newSyntheticApp(t, func(cfg *Config) {
+		cfg.AskBrain.Rerank = enabled
+		cfg.AskBrain.RerankCandidates = 5
+	}, vectors)
+	for id, content := range contents {
+		ta.remember(t, id, content, nil)
+	}
+
+	llm := &fakeLLM{reply: func(prompt string) (string, error) {
+		if strings.HasPrefix(prompt, "Rate how relevant") {
+			return rerankReply()
+		}
+		return "Take the team plan.", nil
+	}}
+	ta.llm = llm
+	return ta, llm
+}
+
+const rerankQuestion = "which plan fits a team of fifteen"
+
+// cannedScores rates plan-4 and plan-5 above the memories retrieved before them.
+func cannedScores() (string, error) {
+	return `{"scores": [{"id": "plan-1", "score": 1}, {"id": "[plan-2]", "score": 2}, {"id": "plan-3", "score": 0},
+		{"id": "plan-4", "score": 9}, {"id": "plan-5", "score": 7}, {"id": "plan-9", "score": 10}]}`, nil
+}
+
+// promptMemories returns the IDs of the memories in an answer prompt, in order.
+func promptMemories(prompt string) []string {
+	var ids []string
+	for _, line := range strings.Split(prompt, "\n") {
+		if id, ok := strings.CutPrefix(line, "- Memory ["); ok {
+			ids = append(ids, id[:strings.Index(id, "]")])
+		}
+	}
+	return ids
+}
+
+func TestAskBrainRerank(t *testing.T) {
+	ta, llm := newRerankApp(t, true, cannedScores)
+	text, isErr := call(t, ta.askBrainHandler, map[string]any{"question": rerankQuestion, "max_results": 2.0})
+	if isErr || !strings.Contains(text, "Take the team plan.") {
+		t.Fatalf("ask_brain = %q", text)
+	}
+
+	prompts := llm.Prompts()
+	if len(prompts) != 2 {
+		t.Fatalf("the LLM got %d prompts, want the rerank call and the answer", len(prompts))
+	}
+	for _, id := range []string{"plan-1", "plan-2", "plan-3", "plan-4", "plan-5"} {
+		if !strings.Contains(prompts[0], "- ["+id+"]: ") {
+			t.Errorf("the rerank prompt does not list candidate %s", id)
+		}
+	}
+	if got, want := promptMemories(prompts[1]), []string{"plan-4", "plan-5"}; !slices.Equal(got, want) {
+		t.Errorf("answer prompt holds %v, want the two best scored %v", got, want)
+	}
+
+	// rerank false overrides the config
+	call(t, ta.askBrainHandler, map[string]any{"question": rerankQuestion, "max_results": 2.0, "rerank": false})
+	prompts = llm.Prompts()
+	if len(prompts) != 3 || !slices.Equal(promptMemories(prompts[2]), []string{"plan-1", "plan-2"}) {
+		t.Errorf("rerank false was not applied: %d calls, answer prompt holds %v", len(prompts), promptMemories(prompts[len(prompts)-1]))
+	}
+}
+
+func TestAskBrainRerankArgument(t *testing.T) {
+	ta, llm := newRerankApp(t, false, cannedScores)
+	call(t, ta.askBrainHandler, map[string]any{"question": rerankQuestion, "max_results": 2.0})
+	if n := len(llm.Prompts()); n != 1 {
+		t.Errorf("reranking disabled in the config made %d calls, want 1", n)
+	}
+	call(t, ta.askBrainHandler, map[string]any{"question": rerankQuestion, "max_results": 2.0, "rerank": true})
+	prompts := llm.Prompts()
+	if len(prompts) != 3 || !slices.Equal(promptMemories(prompts[2]), []string{"plan-4", "plan-5"}) {
+		t.Errorf("rerank true was not applied: %d calls, answer prompt holds %v", len(prompts), promptMemories(prompts[len(prompts)-1]))
+	}
+}
+
+// A failed rerank call or an unusable reply falls back to retrieval order.
+func TestAskBrainRerankFallback(t *testing.T) {
+	for name, reply := range map[string]func() (string, error){
+		"call fails":   func() (string, error) { return "", errors.New("quota exceeded") },
+		"not json":     func() (string, error) { return "plan-4 is best", nil },
+		"wrong schema": func() (string, error) { return `{"ranking": ["plan-4"]}`, nil },
+		"unknown ids":  func() (string, error) { return `{"scores": [{"id": "plan-9", "score": 10}]}`, nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			ta, llm := newRerankApp(t, true, reply)
+			text, isErr := call(t, ta.askBrainHandler, map[string]any{"question": rerankQuestion, "max_results": 2.0})
+			if isErr || !strings.Contains(text, "Take the team plan.") {
+				t.Fatalf("ask_brain = %q, want an answer despite the failed rerank", text)
+			}
+			prompts := llm.Prompts()
+			if got := promptMemories(prompts[len(prompts)-1]); !slices.Equal(got, []string{"plan-1", "plan-2"}) {
+				t.Errorf("answer prompt holds %v, want retrieval order plan-1, plan-2", got)
+			}
+		})
+	}
+}
+
+func TestParseRerankScores(t *testing.T) {
+	candidates := []chromem.Result{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+	scores, err := parseRerankScores(`{"scores": [{"id": " [a] ", "score": 3}, {"id": "z", "score": 9}, {"id": "c", "score": 5.5}]}`, candidates)
+	if err != nil || len(scores) != 2 || scores["a"] != 3 || scores["c"] != 5.5 {
+		t.Errorf("parseRerankScores = %v, %v; want a 3 and c 5.5", scores, err)
+	}
+	if _, err := parseRerankScores(`{"scores": [{"id": "a"}]}`, candidates); err == nil {
+		t.Error("an entry without a score was accepted")
+	}
+	if _, err := parseRerankScores(`{"scores": []}`, candidates); err == nil {
+		t.Error("a reply scoring nothing was accepted")
+	}
+}
+
+// Candidates the reply leaves out rank after the scored ones, in retrieval order.
+func TestRerankUnscoredLast(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.llm = newFakeLLM(`{"scores": [{"id": "c", "score": 4}]}`)
+	ranked, err := ta.rerank(t.Context(), "q", []chromem.Result{{ID: "a"}, {ID: "b"}, {ID: "c"}}, 2)
+	var ids []string
+	for _, res := range ranked {
+		ids = append(ids, res.ID)
+	}
+	if err != nil || !slices.Equal(ids, []string{"c", "a"}) {
+		t.Errorf("rerank = %v, %v; want c, a", ids, err)
+	}
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/philippgille/chromem-go"
+)
+
+const (
+	// MemoryResourceScheme starts the URI of every memory resource.
+	MemoryResourceScheme = "memory://"
+	// MemoryListURI is the resource listing every memory's ID and URI.
+	MemoryListURI = MemoryResourceScheme + "list"
+)
+
+// memoryResourceURI returns the resource URI of a memory.
+func memoryResourceURI(id string) string {
+	return MemoryResourceScheme + url.PathEscape(id)
+}
+
+// memoryResourceID returns the memory ID in a memory resource URI.
+func memoryResourceID(uri string) (string, error) {
+	rest, ok := strings.CutPrefix(uri, MemoryResourceScheme)
+	if !ok || rest == "" {
+		return "", fmt.Errorf("invalid memory URI %q", uri)
+	}
+	return url.PathUnescape(rest)
+}
+
+// isResourceMemory reports whether a document is exposed as a resource:
+// whole memories that are not in the trash. Chunks are read through their
+// parent.
+func isResourceMemory(metadata map[string]string) bool {
+	return !isChunk(metadata) && !isSoftDeleted(metadata)
+}
+
+// resourceFingerprint hashes what a memory resource shows, so that writes
+// that only update access statistics do not notify clients.
+func resourceFingerprint(doc chromem.Document) uint64 {
+	keys := make([]string, 0, len(doc.Metadata))
+	for k := range doc.Metadata {
+		if k != "access_count" && k != "last_accessed_at" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	h := fnv.New64a()
+	h.Write([]byte(doc.Content))
+	for _, k := range keys {
+		h.Write([]byte{0})
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write([]byte(doc.Metadata[k]))
+	}
+	return h.Sum64()
+}
+
+// MemoryResources exposes memories as MCP resources: one memory://<id>
+// resource per memory plus memory://list. It follows the mutations that go
+// through IndexedVectorStore, registering and removing resources as memories
+// come and go and sending resources/updated when one changes. A nil
+// MemoryResources, as in the interactive CLI, ignores mutations.
+type MemoryResources struct {
+	mu      sync.Mutex
+	server  *server.MCPServer
+	handler server.ResourceHandlerFunc
+	ids     map[string]uint64 // Memory ID -> fingerprint of the registered resource
+}
+
+// NewMemoryResources registers the memory resources on s, reading them with
+// handler, and the memory://{id} template for memories by ID.
+func NewMemoryResources(s *server.MCPServer, handler server.ResourceHandlerFunc) *MemoryResources {
+	s.AddResource(mcp.NewResource(MemoryListURI, "Memory list",
+		mcp.WithResourceDescription("IDs and resource URIs of all memories"),
+		mcp.WithMIMEType("application/json"),
+	), handler)
+	s.AddResourceTemplate(mcp.NewResourceTemplate(MemoryResourceScheme+"{id}", "Memory",
+		mcp.WithTemplateDescription("A memory's content and metadata by ID"),
+		mcp.WithTemplateMIMEType("application/json"),
+	), server.ResourceTemplateHandlerFunc(handler))
+	return &MemoryResources{server: s, handler: handler, ids: make(map[string]uint64)}
+}
+
+// Sync registers a resource for every memory in backend and removes those
+// of memories that no longer exist.
+func (mr *MemoryResources) Sync(ctx context.Context, backend VectorBackend) error {
+	if mr == nil {
+		return nil
+	}
+	docs, err := backend.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	seen := make(map[string]bool, len(docs))
+	var stale []string
+	for _, doc := range docs {
+		if isResourceMemory(doc.Metadata) {
+			seen[doc.ID] = true
+		}
+	}
+	for id := range mr.ids {
+		if !seen[id] {
+			stale = append(stale, id)
+		}
+	}
+	mr.removeLocked(stale)
+	mr.addLocked(docs)
+	return nil
+}
+
+// Add registers or updates the resources of stored documents. Documents
+// moved to the trash are removed.
+func (mr *MemoryResources) Add(documents []chromem.Document) {
+	if mr == nil {
+		return
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	var trashed []string
+	for _, doc := range documents {
+		if isSoftDeleted(doc.Metadata) {
+			trashed = append(trashed, doc.ID)
+		}
+	}
+	mr.removeLocked(trashed)
+	mr.addLocked(documents)
+}
+
+// Remove drops the resources of deleted memories.
+func (mr *MemoryResources) Remove(ids []string) {
+	if mr == nil {
+		return
+	}
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	mr.removeLocked(ids)
+}
+
+// IDs returns the IDs of the memories registered as resources, sorted.
+func (mr *MemoryResources) IDs() []string {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+	ids := make([]string, 0, len(mr.ids))
+	for id := range mr.ids {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// addLocked registers new memories in one batch, which sends a single
+// list_changed notification, and sends resources/updated for changed ones.
+func (mr *MemoryResources) addLocked(documents []chromem.Document) {
+	var added []server.ServerResource
+	for _, doc := range documents {
+		if !isResourceMemory(doc.Metadata) {
+			continue
+		}
+		fingerprint := resourceFingerprint(doc)
+		previous, ok := mr.ids[doc.ID]
+		mr.ids[doc.ID] = fingerprint
+		if ok {
+			if previous != fingerprint {
+				mr.notifyUpdated(memoryResourceURI(doc.ID))
+			}
+			continue
+		}
+		added = append(added, server.ServerResource{
+			Resource: mcp.NewResource(memoryResourceURI(doc.ID), doc.ID, mcp.WithMIMEType("application/json")),
+			Handler:  mr.handler,
+		})
+	}
+	if len(added) > 0 {
+		mr.server.AddResources(added...)
+		mr.notifyUpdated(MemoryListURI)
+	}
+}
+
+// removeLocked drops the resources of ids that are registered.
+func (mr *MemoryResources) removeLocked(ids []string) {
+	var uris []string
+	for _, id := range ids {
+		if _, ok := mr.ids[id]; ok {
+			delete(mr.ids, id)
+			uris = append(uris, memoryResourceURI(id))
+		}
+	}
+	if len(uris) > 0 {
+		mr.server.DeleteResources(uris...)
+		mr.notifyUpdated(MemoryListURI)
+	}
+}
+
+// notifyUpdated tells clients that the resource at uri changed.
+func (mr *MemoryResources) notifyUpdated(uri string) {
+	mr.server.SendNotificationToAllClients(mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+}
+
+// memoryResource is the JSON a memory resource serves.
+type memoryResource struct {
+	ID       string            `json:"id"`
+	Content  string            `json:"content"`
+	Context  string            `json:"context"`
+	Tags     []string          `json:"tags,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// memoryListEntry is an entry of the memory://list resource.
+type memoryListEntry struct {
+	ID  string `json:"id"`
+	URI string `json:"uri"`
+}
+
+// readMemoryResource serves memory://list and memory://<id>. Memories in the
+// trash or in contexts the client may not use cannot be read.
+func (a *App) readMemoryResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri := request.Params.URI
+	var body any
+	if uri == MemoryListURI {
+		entries := []memoryListEntry{}
+		hides := a.hidesContexts(ctx)
+		for _, id := range a.resources.IDs() {
+			if hides {
+				if doc, err := a.vectorStore.GetByID(ctx, id); err != nil || a.memoryAccessDenied(ctx, doc) != "" {
+					continue
+				}
+			}
+			entries = append(entries, memoryListEntry{ID: id, URI: memoryResourceURI(id)})
+		}
+		body = entries
+	} else {
+		id, err := memoryResourceID(uri)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := a.vectorStore.GetByID(ctx, id)
+		if err != nil || !isResourceMemory(doc.Metadata) {
+			return nil, fmt.Errorf("memory '%s': %w", id, server.ErrResourceNotFound)
+		}
+		if msg := a.memoryAccessDenied(ctx, doc); msg != "" {
+			return nil, errors.New(msg)
+		}
+		memory := memoryResource{ID: doc.ID, Content: doc.Content, Context: doc.Metadata["context"], Tags: ParseTags(doc.Metadata["tags"]), Metadata: map[string]string{}}
+		if memory.Context == "" {
+			memory.Context = DefaultContextID
+		}
+		for k, v := range doc.Metadata {
+			if k != "context" && k != "tags" && k != "chunk_count" && v != "" {
+				memory.Metadata[k] = v
+			}
+		}
+		body = memory
+	}
+
+	data, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []mcp.ResourceContents{mcp.TextResourceContents{URI: uri, MIMEType: "application/json", Text: string(data)}}, nil
+}
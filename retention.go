@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// RetentionResult summarizes what one retention pass did to a context.
+type RetentionResult struct {
+	ContextID string
+	Action    string
+	Expired   []string // Evicted because they exceeded max_age
+	Overflow  []string // Evicted because the context exceeded max_memories
+	Err       error
+}
+
+// parseRetentionAge parses a max_age value. Besides Go durations ("12h") it
+// accepts whole days ("7d") and weeks ("2w").
+func parseRetentionAge(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if n, ok := strings.CutSuffix(value, "d"); ok {
+		days, err := strconv.Atoi(n)
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid max_age %q", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	if n, ok := strings.CutSuffix(value, "w"); ok {
+		weeks, err := strconv.Atoi(n)
+		if err != nil || weeks <= 0 {
+			return 0, fmt.Errorf("invalid max_age %q", value)
+		}
+		return time.Duration(weeks) * 7 * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid max_age %q (use e.g. 7d, 2w or 12h)", value)
+	}
+	return d, nil
+}
+
+// Validate checks that the policy limits something and uses a known action.
+func (p *RetentionPolicy) Validate() error {
+	if p.MaxAge == "" && p.MaxMemories <= 0 {
+		return fmt.Errorf("retention policy needs max_age or max_memories")
+	}
+	if p.MaxAge != "" {
+		if _, err := parseRetentionAge(p.MaxAge); err != nil {
+			return err
+		}
+	}
+	if p.MaxMemories < 0 {
+		return fmt.Errorf("max_memories cannot be negative")
+	}
+	switch p.Action {
+	case RetentionDelete, RetentionArchive, RetentionSoftDelete:
+		return nil
+	}
+	return fmt.Errorf("unknown retention action %q (use %s, %s or %s)", p.Action, RetentionDelete, RetentionArchive, RetentionSoftDelete)
+}
+
+// String renders the policy for list_contexts.
+func (p *RetentionPolicy) String() string {
+	var limits []string
+	if p.MaxAge != "" {
+		limits = append(limits, "max age "+p.MaxAge)
+	}
+	if p.MaxMemories > 0 {
+		limits = append(limits, fmt.Sprintf("max %d memories", p.MaxMemories))
+	}
+	return fmt.Sprintf("%s, then %s", strings.Join(limits, ", "), p.Action)
+}
+
+// lastActivity returns the later of a memory's created_at and last_accessed_at
+// metadata, or the zero time if neither is recorded.
+func lastActivity(metadata map[string]string) time.Time {
+	var latest time.Time
+	for _, key := range []string{"created_at", "last_accessed_at"} {
+		if t, _, err := parseStoredTime(metadata[key]); err == nil && t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+// isPinned reports whether a memory is exempt from retention.
+func isPinned(metadata map[string]string) bool {
+	return metadata["pinned"] == "true"
+}
+
+// isSoftDeleted reports whether a memory was soft-deleted and should be hidden.
+func isSoftDeleted(metadata map[string]string) bool {
+	return metadata["deleted_at"] != ""
+}
+
+// visibleResults drops soft-deleted memories, and memories past their expiry
+// at now that the sweep has not removed yet, from query results.
+func visibleResults(results []chromem.Result, now time.Time) []chromem.Result {
+	visible := results[:0]
+	for _, res := range results {
+		if !isSoftDeleted(res.Metadata) && !isExpired(res.Metadata, now) {
+			visible = append(visible, res)
+		}
+	}
+	return visible
+}
+
+// selectEvictions returns the memories a policy evicts at time now. Pinned and
+// already soft-deleted memories are never selected. Memories without
+// timestamps predate created_at tracking, so they count as oldest for
+// max_memories but are never considered expired.
+func selectEvictions(policy RetentionPolicy, docs []chromem.Document, now time.Time) (expired, overflow []chromem.Document) {
+	var live, candidates []chromem.Document
+	for _, doc := range docs {
+		// Chunks are evicted with their parent
+		if isSoftDeleted(doc.Metadata) || isChunk(doc.Metadata) {
+			continue
+		}
+		live = append(live, doc)
+		if !isPinned(doc.Metadata) {
+			candidates = append(candidates, doc)
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return lastActivity(candidates[i].Metadata).Before(lastActivity(candidates[j].Metadata))
+	})
+
+	if maxAge, err := parseRetentionAge(policy.MaxAge); err == nil && policy.MaxAge != "" {
+		kept := candidates[:0:0]
+		for _, doc := range candidates {
+			if t := lastActivity(doc.Metadata); !t.IsZero() && now.Sub(t) > maxAge {
+				expired = append(expired, doc)
+			} else {
+				kept = append(kept, doc)
+			}
+		}
+		candidates = kept
+	}
+
+	if policy.MaxMemories > 0 {
+		excess := len(live) - len(expired) - policy.MaxMemories
+		if excess > len(candidates) {
+			excess = len(candidates)
+		}
+		if excess > 0 {
+			overflow = candidates[:excess]
+		}
+	}
+	return expired, overflow
+}
+
+// enforceRetention applies every context's retention policy and returns what was evicted.
+func (a *App) enforceRetention(ctx context.Context, now time.Time) []RetentionResult {
+	policies := a.ctx.RetentionPolicies()
+	ids := make([]string, 0, len(policies))
+	for id := range policies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var results []RetentionResult
+	for _, contextID := range ids {
+		policy := policies[contextID]
+		result := RetentionResult{ContextID: contextID, Action: policy.Action}
+
+		docs, err := a.vectorStore.ListDocuments(ctx, map[string]string{"context": contextID}, 0, 0)
+		if err != nil {
+			result.Err = fmt.Errorf("failed to list memories: %w", err)
+			results = append(results, result)
+			continue
+		}
+
+		expired, overflow := selectEvictions(policy, docs, now)
+		evicted := append(append([]chromem.Document{}, expired...), overflow...)
+		if len(evicted) > 0 {
+			result.Err = a.evictMemories(ctx, contextID, policy.Action, evicted, now)
+		}
+		for _, doc := range expired {
+			result.Expired = append(result.Expired, doc.ID)
+		}
+		for _, doc := range overflow {
+			result.Overflow = append(result.Overflow, doc.ID)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// evictMemories applies a retention action to docs.
+func (a *App) evictMemories(ctx context.Context, contextID, action string, docs []chromem.Document, now time.Time) error {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+
+	switch action {
+	case RetentionArchive:
+		if err := a.archiveMemories(contextID, docs, now); err != nil {
+			return err
+		}
+		fallthrough
+	case RetentionDelete:
+		if err := a.vectorStore.Delete(ctx, nil, nil, ids...); err != nil {
+			return fmt.Errorf("failed to delete memories: %w", err)
+		}
+	case RetentionSoftDelete:
+		// Embeddings are kept so the documents are stored without re-embedding
+		updated := make([]chromem.Document, len(docs))
+		for i, doc := range docs {
+			metadata := make(map[string]string, len(doc.Metadata)+1)
+			for k, v := range doc.Metadata {
+				metadata[k] = v
+			}
+			metadata["deleted_at"] = now.UTC().Format(time.RFC3339)
+			doc.Metadata = metadata
+			updated[i] = doc
+		}
+		if err := a.vectorStore.AddDocuments(ctx, updated, 1); err != nil {
+			return fmt.Errorf("failed to soft-delete memories: %w", err)
+		}
+	default:
+		return fmt.Errorf("unknown retention action %q", action)
+	}
+	for _, doc := range docs {
+		a.deleteChunksOf(ctx, doc, action == RetentionSoftDelete)
+	}
+
+	for range docs {
+		if err := a.ctx.DecrementMemoryCount(contextID); err != nil {
+			a.logf(ctx, "Warning: Failed to update context count: %v", err)
+			break
+		}
+	}
+	a.activity.Record(contextID, ActivityCounts{Deleted: len(docs)})
+	return nil
+}
+
+// archiveMemories appends docs as JSON lines to archive/<context>.jsonl in
+// the data directory, encrypted like the other data files when a key is set.
+func (a *App) archiveMemories(contextID string, docs []chromem.Document, now time.Time) error {
+	dir := filepath.Join(a.dataDir, "archive")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, doc := range docs {
+		record := struct {
+			ID         string            `json:"id"`
+			Content    string            `json:"content"`
+			Metadata   map[string]string `json:"metadata"`
+			ArchivedAt string            `json:"archived_at"`
+		}{doc.ID, doc.Content, doc.Metadata, now.UTC().Format(time.RFC3339)}
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to archive memory %q: %w", doc.ID, err)
+		}
+	}
+	if err := a.cipher.AppendFile(filepath.Join(dir, archiveFileName(contextID)), buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+// archiveFileName maps a context ID to a safe file name.
+func archiveFileName(contextID string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || r == '.' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.TrimLeft(contextID, ".")) + ".jsonl"
+}
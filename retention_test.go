@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// backdate moves the created_at of a stored memory age into the past.
+func (ta *testApp) backdate(t *testing.T, id string, age time.Duration) {
+	t.Helper()
+	doc, err := ta.vectorStore.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID %s: %v", id, err)
+	}
+	doc.Metadata["created_at"] = time.Now().Add(-age).UTC().Format(time.RFC3339)
+	if err := ta.vectorStore.UpdateMetadata(context.Background(), id, doc.Metadata); err != nil {
+		t.Fatalf("UpdateMetadata %s: %v", id, err)
+	}
+}
+
+// newRetentionApp returns a testApp with an audit log and a "scratch" context
+// holding two expired memories, an expired pinned one and a fresh one, plus an
+// expired memory in the default context, which has no policy.
+func newRetentionApp(t *testing.T, action string) *testApp {
+	t.Helper()
+	ta := newTestApp(t, nil)
+	ta.withAuditLog(t)
+
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "scratch", "name": "Scratch"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.switchContext(t, "scratch")
+	day := 24 * time.Hour
+	for id, age := range map[string]time.Duration{"old-1": 10 * day, "old-2": 9 * day, "pinned-old": 30 * day, "fresh": 0} {
+		ta.remember(t, id, "scratch note "+id, nil)
+		if age > 0 {
+			ta.backdate(t, id, age)
+		}
+	}
+	if text, isErr := call(t, ta.pinMemoryHandler, map[string]any{"memory_id": "pinned-old"}); isErr {
+		t.Fatalf("pin_memory: %s", text)
+	}
+	ta.switchContext(t, DefaultContextID)
+	ta.remember(t, "default-old", "an old default memory", nil)
+	ta.backdate(t, "default-old", 30*day)
+
+	text, isErr := call(t, ta.setContextRetentionHandler, map[string]any{"context_id": "scratch", "max_age": "7d", "action": action})
+	if isErr {
+		t.Fatalf("set_context_retention: %s", text)
+	}
+	return ta
+}
+
+// auditedRetention returns the memory IDs of the retention entries in the audit log.
+func auditedRetention(t *testing.T, ta *testApp) []string {
+	t.Helper()
+	var ids []string
+	for _, entry := range auditEntries(t, ta, "retention") {
+		if entry.ContextID != "scratch" || entry.Status != "ok" || entry.ClientID != ta.clientID {
+			t.Errorf("retention audit entry = %+v", entry)
+		}
+		ids = append(ids, entry.MemoryIDs...)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestRetentionActions(t *testing.T) {
+	for _, action := range []string{RetentionDelete, RetentionArchive, RetentionSoftDelete} {
+		t.Run(action, func(t *testing.T) {
+			ta := newRetentionApp(t, action)
+			ta.runMaintenance(context.Background())
+
+			if got := auditedRetention(t, ta); !slices.Equal(got, []string{"old-1", "old-2"}) {
+				t.Errorf("audited evictions = %v, want old-1 and old-2", got)
+			}
+			scratch, _ := ta.ctx.GetContext("scratch")
+			if scratch.MemoryCount != 2 {
+				t.Errorf("scratch holds %d memories, want 2", scratch.MemoryCount)
+			}
+			for _, id := range []string{"pinned-old", "fresh", "default-old"} {
+				if doc, err := ta.vectorStore.GetByID(context.Background(), id); err != nil || isSoftDeleted(doc.Metadata) {
+					t.Errorf("%s was evicted", id)
+				}
+			}
+
+			for _, id := range []string{"old-1", "old-2"} {
+				doc, err := ta.vectorStore.GetByID(context.Background(), id)
+				if action == RetentionSoftDelete {
+					if err != nil || !isSoftDeleted(doc.Metadata) {
+						t.Errorf("%s was not soft-deleted (err %v)", id, err)
+					}
+				} else if err == nil {
+					t.Errorf("%s is still stored", id)
+				}
+			}
+			text, _ := call(t, ta.searchHandler, map[string]any{"query": "scratch note old", "context_id": "scratch"})
+			if strings.Contains(text, "old-1") || strings.Contains(text, "old-2") {
+				t.Errorf("search_memory still finds evicted memories:\n%s", text)
+			}
+
+			data, err := os.ReadFile(filepath.Join(ta.dataDir, "archive", "scratch.jsonl"))
+			if action != RetentionArchive {
+				if err == nil {
+					t.Errorf("%s wrote an archive", action)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("no archive: %v", err)
+			}
+			var archived []string
+			for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+				var record struct{ ID, Content string }
+				if err := json.Unmarshal([]byte(line), &record); err != nil {
+					t.Fatal(err)
+				}
+				if record.Content != "scratch note "+record.ID {
+					t.Errorf("archived content of %s = %q", record.ID, record.Content)
+				}
+				archived = append(archived, record.ID)
+			}
+			slices.Sort(archived)
+			if !slices.Equal(archived, []string{"old-1", "old-2"}) {
+				t.Errorf("archived %v", archived)
+			}
+		})
+	}
+}
+
+func TestSelectEvictionsMaxMemories(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	doc := func(id string, daysAgo int, extra ...string) chromem.Document {
+		metadata := map[string]string{"created_at": now.AddDate(0, 0, -daysAgo).Format(time.RFC3339)}
+		for i := 0; i+1 < len(extra); i += 2 {
+			metadata[extra[i]] = extra[i+1]
+		}
+		return chromem.Document{ID: id, Metadata: metadata}
+	}
+	docs := []chromem.Document{
+		doc("d3", 3),
+		doc("pinned", 50, "pinned", "true"),
+		doc("d1", 1),
+		doc("recently-read", 40, "last_accessed_at", now.Add(-time.Hour).Format(time.RFC3339)),
+		doc("d5", 5),
+		doc("trashed", 60, "deleted_at", now.Format(time.RFC3339)),
+		doc("chunk", 60, "parent_id", "d5", "chunk_index", "1"),
+		{ID: "untimed", Metadata: map[string]string{}},
+	}
+
+	expired, overflow := selectEvictions(RetentionPolicy{MaxMemories: 3, Action: RetentionDelete}, docs, now)
+	if len(expired) != 0 {
+		t.Errorf("expired = %v without max_age", expired)
+	}
+	// Six live memories for three slots: untimed counts as oldest, pinned is exempt
+	var ids []string
+	for _, d := range overflow {
+		ids = append(ids, d.ID)
+	}
+	if want := []string{"untimed", "d5", "d3"}; !slices.Equal(ids, want) {
+		t.Errorf("overflow = %v, want %v", ids, want)
+	}
+
+	expired, overflow = selectEvictions(RetentionPolicy{MaxAge: "2d", MaxMemories: 2, Action: RetentionDelete}, docs, now)
+	ids = nil
+	for _, d := range append(expired, overflow...) {
+		ids = append(ids, d.ID)
+	}
+	if want := []string{"d5", "d3", "untimed", "d1"}; !slices.Equal(ids, want) {
+		t.Errorf("evicted = %v, want the expired d5 and d3, then the two oldest left over the limit", ids)
+	}
+}
+
+func TestRetentionPolicyValidation(t *testing.T) {
+	for _, tc := range []struct {
+		policy RetentionPolicy
+		valid  bool
+	}{
+		{RetentionPolicy{MaxAge: "7d", Action: RetentionDelete}, true},
+		{RetentionPolicy{MaxAge: "2w", Action: RetentionArchive}, true},
+		{RetentionPolicy{MaxAge: "36h", MaxMemories: 10, Action: RetentionSoftDelete}, true},
+		{RetentionPolicy{MaxMemories: 10, Action: RetentionDelete}, true},
+		{RetentionPolicy{Action: RetentionDelete}, false},
+		{RetentionPolicy{MaxAge: "0d", Action: RetentionDelete}, false},
+		{RetentionPolicy{MaxAge: "a week", Action: RetentionDelete}, false},
+		{RetentionPolicy{MaxAge: "7d", Action: "shred"}, false},
+	} {
+		if err := tc.policy.Validate(); (err == nil) != tc.valid {
+			t.Errorf("Validate(%+v) = %v, want valid %v", tc.policy, err, tc.valid)
+		}
+	}
+}
+
+func TestListContextsShowsRetention(t *testing.T) {
+	ta := newRetentionApp(t, RetentionArchive)
+	text, _ := call(t, ta.listContextsHandler, nil)
+	if !strings.Contains(text, "Retention: max age 7d, then archive") {
+		t.Errorf("list_contexts does not show the scratch policy:\n%s", text)
+	}
+	if n := strings.Count(text, "Retention:"); n != 1 {
+		t.Errorf("list_contexts shows %d policies, want only the one set on scratch", n)
+	}
+
+	if text, isErr := call(t, ta.setContextRetentionHandler, map[string]any{"context_id": "scratch", "clear": true}); isErr {
+		t.Fatalf("clear: %s", text)
+	}
+	ta.runMaintenance(context.Background())
+	if _, err := ta.vectorStore.GetByID(context.Background(), "old-1"); err != nil {
+		t.Error("memories were evicted after the policy was cleared")
+	}
+}
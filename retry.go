@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/genai"
+)
+
+// RetryPolicy controls how calls to embedding providers are retried.
+type RetryPolicy struct {
+	MaxRetries     int           // Retries after the first attempt (0 disables retrying)
+	InitialBackoff time.Duration // Delay before the first retry, doubled on each further retry
+	MaxBackoff     time.Duration // Upper bound for a single delay
+}
+
+// DefaultRetryPolicy returns the policy used when no retry settings are configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxRetries:     DefaultMaxRetries,
+		InitialBackoff: DefaultInitialBackoff,
+		MaxBackoff:     MaxRetryBackoff,
+	}
+}
+
+// HTTPStatusError is returned by HTTP-based providers for non-2xx responses.
+type HTTPStatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // Parsed Retry-After header, zero when absent
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("unexpected status code: %d: %s", e.StatusCode, e.Body)
+	}
+	return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+}
+
+// withRetry calls fn until it succeeds, fails with a permanent error, or the
+// policy's retries are exhausted. Transient failures wait with exponential
+// backoff and jitter, or for the server-provided delay when one is given.
+func withRetry(ctx context.Context, policy RetryPolicy, fn func() error) error {
+	backoff := policy.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		retryable, retryAfter := classifyError(err)
+		if !retryable || attempt >= policy.MaxRetries {
+			traceLog(ctx, "provider", "Provider call failed (attempt %d, retryable: %t): %v", attempt+1, retryable, err)
+			if retryable && attempt > 0 {
+				return fmt.Errorf("giving up after %d attempts: %w", attempt+1, err)
+			}
+			return err
+		}
+
+		delay := retryAfter
+		if delay <= 0 {
+			delay = jitter(backoff)
+			backoff *= 2
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+		if policy.MaxBackoff > 0 && delay > policy.MaxBackoff {
+			delay = policy.MaxBackoff
+		}
+
+		traceLog(ctx, "provider", "Provider call failed, retrying in %v (attempt %d of %d): %v", delay, attempt+1, policy.MaxRetries+1, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// jitter returns a random duration in [d/2, d) to spread out concurrent retries.
+func jitter(d time.Duration) time.Duration {
+	if d <= 1 {
+		return d
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)))
+}
+
+// classifyError reports whether err is worth retrying and, if the server said
+// so, how long to wait. Invalid credentials, unknown models and malformed
+// requests are permanent; rate limits, server errors and network failures are not.
+func classifyError(err error) (bool, time.Duration) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false, 0
+	}
+
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return retryableStatus(apiErr.Code), geminiRetryDelay(apiErr)
+	}
+	var apiErrPtr *genai.APIError
+	if errors.As(err, &apiErrPtr) {
+		return retryableStatus(apiErrPtr.Code), geminiRetryDelay(*apiErrPtr)
+	}
+
+	var statusErr *HTTPStatusError
+	if errors.As(err, &statusErr) {
+		return retryableStatus(statusErr.StatusCode), statusErr.RetryAfter
+	}
+
+	// Anything else is a transport-level failure (connection refused, reset, EOF)
+	return true, 0
+}
+
+// retryableStatus reports whether an HTTP status code indicates a transient failure.
+func retryableStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests:
+		return true
+	}
+	return code >= 500
+}
+
+// geminiRetryDelay extracts the delay from a google.rpc.RetryInfo detail, if present.
+func geminiRetryDelay(apiErr genai.APIError) time.Duration {
+	for _, detail := range apiErr.Details {
+		typ, _ := detail["@type"].(string)
+		if !strings.HasSuffix(typ, "google.rpc.RetryInfo") {
+			continue
+		}
+		if delay, ok := detail["retryDelay"].(string); ok {
+			if d, err := time.ParseDuration(delay); err == nil {
+				return d
+			}
+		}
+	}
+	return 0
+}
+
+// parseRetryAfter parses a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fastRetries retries quickly so tests do not wait for real backoff delays.
+var fastRetries = RetryPolicy{MaxRetries: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func TestGeminiRetriesRateLimits(t *testing.T) {
+	fake, client := newFakeGemini(t)
+	fake.failures = []int{http.StatusTooManyRequests, http.StatusTooManyRequests}
+
+	embeddings, err := batchEmbedGemini(t.Context(), client, "text-embedding-004", testDimension, []string{"retry me"}, fastRetries)
+	if err != nil {
+		t.Fatalf("batchEmbedGemini: %v", err)
+	}
+	if len(embeddings) != 1 || len(embeddings[0]) != testDimension {
+		t.Fatalf("got %d embeddings", len(embeddings))
+	}
+	if got := fake.Attempts(); got != 3 {
+		t.Errorf("made %d requests, want 3 (two rate limited, one success)", got)
+	}
+}
+
+func TestGeminiGivesUpAfterMaxRetries(t *testing.T) {
+	fake, client := newFakeGemini(t)
+	fake.failures = []int{503, 503, 503, 503, 503}
+
+	_, err := batchEmbedGemini(t.Context(), client, "text-embedding-004", testDimension, []string{"never works"}, fastRetries)
+	if err == nil || !strings.Contains(err.Error(), "giving up after 4 attempts") {
+		t.Fatalf("err = %v, want giving up after 4 attempts", err)
+	}
+	if got := fake.Attempts(); got != 4 {
+		t.Errorf("made %d requests, want 4", got)
+	}
+}
+
+func TestGeminiPermanentErrorIsNotRetried(t *testing.T) {
+	fake, client := newFakeGemini(t)
+	fake.failures = []int{http.StatusBadRequest}
+
+	if _, err := batchEmbedGemini(t.Context(), client, "no-such-model", testDimension, []string{"text"}, fastRetries); err == nil {
+		t.Fatal("batchEmbedGemini succeeded")
+	}
+	if got := fake.Attempts(); got != 1 {
+		t.Errorf("made %d requests for a permanent error, want 1", got)
+	}
+}
+
+func TestLMStudioRetriesRateLimits(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) <= 2 {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "slow down", http.StatusTooManyRequests)
+			return
+		}
+		vec, _ := testEmbedding(r.Context(), "text")
+		json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"embedding": vec}}})
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	embeddings, err := batchEmbedLMStudio(t.Context(), srv.URL, "model", []string{"text"}, fastRetries)
+	if err != nil {
+		t.Fatalf("batchEmbedLMStudio: %v", err)
+	}
+	if len(embeddings) != 1 || attempts.Load() != 3 {
+		t.Errorf("got %d embeddings after %d requests, want 1 after 3", len(embeddings), attempts.Load())
+	}
+	// Retry-After is honored but capped by MaxBackoff
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retries took %v, longer than MaxBackoff allows", elapsed)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		retryable bool
+		after     time.Duration
+	}{
+		{"rate limited", &HTTPStatusError{StatusCode: 429, RetryAfter: 2 * time.Second}, true, 2 * time.Second},
+		{"server error", &HTTPStatusError{StatusCode: 502}, true, 0},
+		{"timeout status", &HTTPStatusError{StatusCode: 408}, true, 0},
+		{"bad request", &HTTPStatusError{StatusCode: 400}, false, 0},
+		{"unauthorized", &HTTPStatusError{StatusCode: 401}, false, 0},
+		{"canceled", context.Canceled, false, 0},
+		{"network", errors.New("connection reset by peer"), true, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			retryable, after := classifyError(tc.err)
+			if retryable != tc.retryable || after != tc.after {
+				t.Errorf("classifyError = %v, %v; want %v, %v", retryable, after, tc.retryable, tc.after)
+			}
+		})
+	}
+
+	if got := parseRetryAfter("3"); got != 3*time.Second {
+		t.Errorf("parseRetryAfter(3) = %v", got)
+	}
+	if got := parseRetryAfter(""); got != 0 {
+		t.Errorf("parseRetryAfter(\"\") = %v", got)
+	}
+}
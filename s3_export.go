@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// s3ExportPrefix starts the file name of every export uploaded to S3.
+const s3ExportPrefix = "brain_export_"
+
+// newS3Client creates a client for cfg's bucket. Without an access key the
+// AWS default credential chain (environment, shared config, instance role)
+// is used. A custom endpoint is addressed path-style, as MinIO and R2 expect.
+func newS3Client(ctx context.Context, cfg S3Config) (*s3.Client, error) {
+	opts := []func(*awsconfig.LoadOptions) error{awsconfig.WithRegion(cfg.Region)}
+	if cfg.AccessKeyID != "" {
+		opts = append(opts, awsconfig.WithCredentialsProvider(
+			credentials.NewStaticCredentialsProvider(cfg.AccessKeyID, cfg.SecretAccessKey, "")))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	return s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		// S3-compatible services often return no checksum; don't log each one
+		o.DisableLogOutputChecksumValidationSkipped = true
+		if cfg.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(cfg.EndpointURL)
+			o.UsePathStyle = true
+		}
+	}), nil
+}
+
+// s3ExportKey returns the object key for an export made at t under prefix.
+func s3ExportKey(prefix string, t time.Time) string {
+	name := s3ExportPrefix + t.UTC().Format("20060102T150405Z") + ".json.gz"
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		return path.Join(prefix, name)
+	}
+	return name
+}
+
+// gzipExport encodes an export as gzipped JSON in the format export_memories
+// writes.
+func gzipExport(export *ExportData) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipExport returns data uncompressed if it is gzipped and as it is
+// otherwise, so plain JSON exports can be imported from S3 too.
+func gunzipExport(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0x1f || data[1] != 0x8b {
+		return data, nil
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+	return io.ReadAll(zr)
+}
+
+// parseS3Location reads an object given as a key in bucket or as an
+// s3://bucket/key URL.
+func parseS3Location(bucket, location string) (string, string, error) {
+	location = strings.TrimSpace(location)
+	if rest, ok := strings.CutPrefix(location, "s3://"); ok {
+		b, key, _ := strings.Cut(rest, "/")
+		if b == "" || key == "" {
+			return "", "", fmt.Errorf("invalid S3 URL %q: expected s3://bucket/key", location)
+		}
+		return b, key, nil
+	}
+	return bucket, strings.TrimPrefix(location, "/"), nil
+}
+
+// latestS3Export returns the key of the newest export under prefix. Export
+// keys end in a UTC timestamp, so the newest sorts last.
+func latestS3Export(ctx context.Context, client *s3.Client, bucket, prefix string) (string, error) {
+	listPrefix := s3ExportPrefix
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		listPrefix = prefix + "/" + s3ExportPrefix
+	}
+	var latest string
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(listPrefix)})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return "", err
+		}
+		for _, obj := range page.Contents {
+			if key := aws.ToString(obj.Key); key > latest {
+				latest = key
+			}
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no exports found under s3://%s/%s", bucket, listPrefix)
+	}
+	return latest, nil
+}
+
+// exportToS3Handler handles the export_to_s3 tool - uploads a gzipped JSON
+// export to the configured bucket and returns its URL.
+func (a *App) exportToS3Handler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if a.s3.Bucket == "" {
+		return mcp.NewToolResultError("S3 is not configured: set s3.bucket in config.json"), nil
+	}
+	args, _ := request.Params.Arguments.(map[string]any)
+	includeVersions, _ := args["include_versions"].(bool)
+	var memoryIDs []string
+	if ids, ok := args["memory_ids"].([]any); ok {
+		for _, id := range ids {
+			if idStr, ok := id.(string); ok {
+				memoryIDs = append(memoryIDs, idStr)
+			}
+		}
+	}
+
+	export, err := a.buildExport(ctx, memoryIDs, includeVersions)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to export memories: %v", err)), nil
+	}
+	body, err := gzipExport(export)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to encode export: %v", err)), nil
+	}
+
+	client, err := newS3Client(ctx, a.s3)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	key := s3ExportKey(a.s3.Prefix, export.ExportedAt)
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(a.s3.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/gzip"),
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to upload export: %v", err)), nil
+	}
+
+	url := fmt.Sprintf("s3://%s/%s", a.s3.Bucket, key)
+	return mcp.NewToolResultText(fmt.Sprintf("Exported %d memories to %s (%d bytes).", len(export.Memories), url, len(body))), nil
+}
+
+// importFromS3Handler handles the import_from_s3 tool - downloads an export
+// from S3, the newest under the configured prefix unless key is given, and
+// imports it like import_memories.
+func (a *App) importFromS3Handler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	location, _ := args["key"].(string)
+	if a.s3.Bucket == "" && !strings.HasPrefix(strings.TrimSpace(location), "s3://") {
+		return mcp.NewToolResultError("S3 is not configured: set s3.bucket in config.json or pass an s3:// URL as key"), nil
+	}
+
+	client, err := newS3Client(ctx, a.s3)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	bucket, key := a.s3.Bucket, ""
+	if strings.TrimSpace(location) != "" {
+		if bucket, key, err = parseS3Location(a.s3.Bucket, location); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	} else if key, err = latestS3Export(ctx, client, bucket, a.s3.Prefix); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to find an export: %v", err)), nil
+	}
+
+	obj, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to download s3://%s/%s: %v", bucket, key, err)), nil
+	}
+	defer obj.Body.Close()
+	data, err := io.ReadAll(obj.Body)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to download s3://%s/%s: %v", bucket, key, err)), nil
+	}
+	if data, err = gunzipExport(data); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to decompress s3://%s/%s: %v", bucket, key, err)), nil
+	}
+	return a.importExport(ctx, request, args, data)
+}
@@ -3,124 +3,146 @@ package main
 import (
 	"context"
 	"fmt"
+	"slices"
+	"sort"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"github.com/philippgille/chromem-go"
 )
 
 // SearchFilterEngine handles advanced searching with multiple filters.
+// Memory content, context and tags come from the vector store, so memories
+// without version history are found too; the version manager adds version
+// numbers, authors and update times where available.
 type SearchFilterEngine struct {
-	collection interface{} // chromem.Collection
+	store      VectorBackend
 	versionMgr *MemoryVersionManager
 	ctxMgr     *ContextManager
+	now        func() time.Time // Clock for memory expiry, time.Now if nil; tests replace it
 }
 
 // NewSearchFilterEngine creates a new search filter engine.
-func NewSearchFilterEngine(versionMgr *MemoryVersionManager, ctxMgr *ContextManager) *SearchFilterEngine {
+func NewSearchFilterEngine(store VectorBackend, versionMgr *MemoryVersionManager, ctxMgr *ContextManager) *SearchFilterEngine {
 	return &SearchFilterEngine{
+		store:      store,
 		versionMgr: versionMgr,
 		ctxMgr:     ctxMgr,
 	}
 }
 
-// FilterMemories filters memories based on search criteria.
-func (s *SearchFilterEngine) FilterMemories(filter SearchFilter) []SearchResult {
-	results := []SearchResult{}
-
-	// Get all memories from version manager
-	allHistories := s.versionMgr.GetAllHistories()
+// FilterMemories returns the memories matching the filter's context, tags,
+// creation date range and author, most recently updated first. The query is
+// not used here; see searchAdvancedHandler.
+func (s *SearchFilterEngine) FilterMemories(ctx context.Context, filter SearchFilter) ([]SearchResult, error) {
+	var where map[string]string
+	if filter.ContextID != "" {
+		where = map[string]string{"context": filter.ContextID}
+	}
+	docs, err := s.store.ListDocuments(ctx, where, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+	histories := s.versionMgr.GetAllHistories()
 
-	for memoryID, history := range allHistories {
-		// Apply context filter
-		if filter.ContextID != "" && history.Context != filter.ContextID {
+	now := time.Now()
+	if s.now != nil {
+		now = s.now()
+	}
+	results := []SearchResult{}
+	for _, doc := range docs {
+		if isSoftDeleted(doc.Metadata) || isExpired(doc.Metadata, now) {
 			continue
 		}
+		result := searchResultFromDocument(doc, histories[doc.ID])
 
 		// Apply date range filter
-		if !filter.StartDate.IsZero() && history.CreatedAt.Before(filter.StartDate) {
+		if !filter.StartDate.IsZero() && result.CreatedAt.Before(filter.StartDate) {
 			continue
 		}
-		if !filter.EndDate.IsZero() && history.UpdatedAt.After(filter.EndDate) {
+		if !filter.EndDate.IsZero() && !result.CreatedAt.Before(filter.EndDate) {
 			continue
 		}
 
 		// Apply client ID filter
-		if filter.CreatedBy != "" && len(history.Versions) > 0 {
-			if history.Versions[0].CreatedBy != filter.CreatedBy {
-				continue
-			}
+		if filter.CreatedBy != "" && result.CreatedBy != filter.CreatedBy {
+			continue
 		}
 
 		// Apply tag filter
-		if len(filter.Tags) > 0 {
-			if filter.TagFilterMode == "all" {
-				// Must have ALL tags (AND logic)
-				hasAllTags := true
-				for _, filterTag := range filter.Tags {
-					hasTag := false
-					for _, memTag := range history.Tags {
-						if strings.EqualFold(memTag, filterTag) {
-							hasTag = true
-							break
-						}
-					}
-					if !hasTag {
-						hasAllTags = false
-						break
-					}
-				}
-				if !hasAllTags {
-					continue
-				}
-			} else {
-				// Must have ANY tag (OR logic)
-				hasAnyTag := false
-				for _, filterTag := range filter.Tags {
-					for _, memTag := range history.Tags {
-						if strings.EqualFold(memTag, filterTag) {
-							hasAnyTag = true
-							break
-						}
-					}
-					if hasAnyTag {
-						break
-					}
-				}
-				if !hasAnyTag {
-					continue
-				}
-			}
+		if len(filter.Tags) > 0 && !matchesTags(result.Tags, filter.Tags, filter.TagFilterMode == "all") {
+			continue
 		}
 
-		// Get current version content
-		if len(history.Versions) > 0 {
-			currentVersion := history.Versions[len(history.Versions)-1]
-
-			result := SearchResult{
-				ID:            memoryID,
-				Content:       currentVersion.Content,
-				Similarity:    1.0, // Base similarity for filtered results
-				Context:       history.Context,
-				Tags:          history.Tags,
-				CurrentVersion: history.CurrentVersion,
-				CreatedAt:     history.CreatedAt,
-				UpdatedAt:     history.UpdatedAt,
-				Metadata:      history.Metadata,
-			}
-
-			results = append(results, result)
-		}
+		results = append(results, result)
 	}
 
+	sort.SliceStable(results, func(i, j int) bool {
+		if !results[i].UpdatedAt.Equal(results[j].UpdatedAt) {
+			return results[i].UpdatedAt.After(results[j].UpdatedAt)
+		}
+		return results[i].ID < results[j].ID
+	})
+
 	// Apply max results limit
 	if filter.MaxResults > 0 && len(results) > filter.MaxResults {
 		results = results[:filter.MaxResults]
 	}
 
-	return results
+	return results, nil
+}
+
+// searchResultFromDocument builds a search result from a stored memory and its
+// history, if it has one. Memories without created_at sort as oldest.
+func searchResultFromDocument(doc chromem.Document, history *MemoryWithHistory) SearchResult {
+	contextID := doc.Metadata["context"]
+	if contextID == "" {
+		contextID = DefaultContextID
+	}
+	created, _, _ := parseStoredTime(doc.Metadata["created_at"])
+
+	result := SearchResult{
+		ID:         doc.ID,
+		Content:    doc.Content,
+		Similarity: 1.0, // Base similarity for filtered results
+		Context:    contextID,
+		Tags:       ParseTags(doc.Metadata["tags"]),
+		CreatedAt:  created,
+		UpdatedAt:  created,
+		CreatedBy:  doc.Metadata["client"],
+		Metadata:   doc.Metadata,
+	}
+	if history != nil && len(history.Versions) > 0 {
+		result.CurrentVersion = history.CurrentVersion
+		result.CreatedBy = history.Versions[0].CreatedBy
+		if result.CreatedAt.IsZero() {
+			result.CreatedAt = history.CreatedAt
+		}
+		result.UpdatedAt = history.UpdatedAt
+	}
+	return result
+}
+
+// matchesTags reports whether memTags contain all (matchAll) or any of the
+// wanted tags, ignoring case.
+func matchesTags(memTags, wanted []string, matchAll bool) bool {
+	for _, filterTag := range wanted {
+		hasTag := slices.ContainsFunc(memTags, func(memTag string) bool {
+			return strings.EqualFold(memTag, filterTag)
+		})
+		if hasTag && !matchAll {
+			return true
+		}
+		if !hasTag && matchAll {
+			return false
+		}
+	}
+	return matchAll
 }
 
 // SearchByContextAndTags performs a combined search.
-func (s *SearchFilterEngine) SearchByContextAndTags(ctx context.Context, contextID string, tags []string, tagMode string) []SearchResult {
+func (s *SearchFilterEngine) SearchByContextAndTags(ctx context.Context, contextID string, tags []string, tagMode string) ([]SearchResult, error) {
 	filter := SearchFilter{
 		ContextID:     contextID,
 		Tags:          tags,
@@ -128,43 +150,43 @@ func (s *SearchFilterEngine) SearchByContextAndTags(ctx context.Context, context
 		MaxResults:    50,
 	}
 
-	return s.FilterMemories(filter)
+	return s.FilterMemories(ctx, filter)
 }
 
 // SearchByDateRange performs a date-based search.
-func (s *SearchFilterEngine) SearchByDateRange(startDate, endDate time.Time) []SearchResult {
+func (s *SearchFilterEngine) SearchByDateRange(ctx context.Context, startDate, endDate time.Time) ([]SearchResult, error) {
 	filter := SearchFilter{
 		StartDate:  startDate,
 		EndDate:    endDate,
 		MaxResults: 100,
 	}
 
-	return s.FilterMemories(filter)
+	return s.FilterMemories(ctx, filter)
 }
 
 // SearchByContext performs a context-specific search.
-func (s *SearchFilterEngine) SearchByContext(contextID string, maxResults int) []SearchResult {
+func (s *SearchFilterEngine) SearchByContext(ctx context.Context, contextID string, maxResults int) ([]SearchResult, error) {
 	filter := SearchFilter{
 		ContextID:  contextID,
 		MaxResults: maxResults,
 	}
 
-	return s.FilterMemories(filter)
+	return s.FilterMemories(ctx, filter)
 }
 
 // GetMemoriesByTag returns all memories with a specific tag.
-func (s *SearchFilterEngine) GetMemoriesByTag(tagName string) []SearchResult {
+func (s *SearchFilterEngine) GetMemoriesByTag(ctx context.Context, tagName string) ([]SearchResult, error) {
 	filter := SearchFilter{
 		Tags:          []string{tagName},
 		TagFilterMode: "any",
 		MaxResults:    100,
 	}
 
-	return s.FilterMemories(filter)
+	return s.FilterMemories(ctx, filter)
 }
 
 // GetMemoriesByMultipleTags returns memories matching multiple tags.
-func (s *SearchFilterEngine) GetMemoriesByMultipleTags(tags []string, matchAll bool) []SearchResult {
+func (s *SearchFilterEngine) GetMemoriesByMultipleTags(ctx context.Context, tags []string, matchAll bool) ([]SearchResult, error) {
 	mode := "any"
 	if matchAll {
 		mode = "all"
@@ -176,29 +198,39 @@ func (s *SearchFilterEngine) GetMemoriesByMultipleTags(tags []string, matchAll b
 		MaxResults:    100,
 	}
 
-	return s.FilterMemories(filter)
+	return s.FilterMemories(ctx, filter)
 }
 
-// GetContextStats returns statistics for a context.
-func (s *SearchFilterEngine) GetContextStats(contextID string) map[string]interface{} {
-	memories := s.SearchByContext(contextID, 10000)
+// GetContextStats returns statistics for a context, computed from the
+// memories in the vector store. Trashed memories are not counted.
+func (s *SearchFilterEngine) GetContextStats(ctx context.Context, contextID string) (map[string]interface{}, error) {
+	memories, err := s.SearchByContext(ctx, contextID, 0)
+	if err != nil {
+		return nil, err
+	}
 
 	stats := map[string]interface{}{
-		"context_id":        contextID,
-		"memory_count":      len(memories),
-		"unique_tags":       []string{},
-		"oldest_memory":     nil,
-		"newest_memory":     nil,
-		"total_characters":  0,
+		"context_id":            contextID,
+		"memory_count":          len(memories),
+		"unique_tags":           []string{},
+		"tag_counts":            map[string]int{},
+		"oldest_memory":         nil,
+		"newest_memory":         nil,
+		"most_recent_memory_id": "",
+		"total_characters":      0,
+		"total_bytes":           0,
+		"average_length":        0.0,
 	}
 
-	tagMap := make(map[string]bool)
+	tagCounts := make(map[string]int)
 	var oldestTime, newestTime time.Time
+	var mostRecent string
+	totalChars, totalBytes := 0, 0
 
 	for _, mem := range memories {
-		// Collect unique tags
+		// Count tag usage
 		for _, tag := range mem.Tags {
-			tagMap[tag] = true
+			tagCounts[tag]++
 		}
 
 		// Track dates
@@ -207,10 +239,12 @@ func (s *SearchFilterEngine) GetContextStats(contextID string) map[string]interf
 		}
 		if newestTime.IsZero() || mem.UpdatedAt.After(newestTime) {
 			newestTime = mem.UpdatedAt
+			mostRecent = mem.ID
 		}
 
-		// Count characters
-		stats["total_characters"] = stats["total_characters"].(int) + len(mem.Content)
+		// Count characters and bytes
+		totalChars += utf8.RuneCountInString(mem.Content)
+		totalBytes += len(mem.Content)
 	}
 
 	if !oldestTime.IsZero() {
@@ -219,14 +253,25 @@ func (s *SearchFilterEngine) GetContextStats(contextID string) map[string]interf
 	if !newestTime.IsZero() {
 		stats["newest_memory"] = newestTime
 	}
+	if mostRecent == "" && len(memories) > 0 {
+		mostRecent = memories[0].ID
+	}
+	stats["most_recent_memory_id"] = mostRecent
+	stats["total_characters"] = totalChars
+	stats["total_bytes"] = totalBytes
+	if len(memories) > 0 {
+		stats["average_length"] = float64(totalChars) / float64(len(memories))
+	}
 
-	uniqueTags := make([]string, 0, len(tagMap))
-	for tag := range tagMap {
+	uniqueTags := make([]string, 0, len(tagCounts))
+	for tag := range tagCounts {
 		uniqueTags = append(uniqueTags, tag)
 	}
+	sort.Strings(uniqueTags)
 	stats["unique_tags"] = uniqueTags
+	stats["tag_counts"] = tagCounts
 
-	return stats
+	return stats, nil
 }
 
 // ValidateFilter checks if a filter is valid.
@@ -248,5 +293,9 @@ func (s *SearchFilterEngine) ValidateFilter(filter SearchFilter) error {
 		return fmt.Errorf("tag_filter_mode must be 'all' or 'any'")
 	}
 
+	if filter.Mode != "" && filter.Mode != SearchModeSemantic && filter.Mode != SearchModeKeyword && filter.Mode != SearchModeHybrid {
+		return fmt.Errorf("mode must be '%s', '%s' or '%s'", SearchModeSemantic, SearchModeKeyword, SearchModeHybrid)
+	}
+
 	return nil
 }
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// newFilterApp returns a testApp with memories told apart by context, tags,
+// creation date and author:
+//
+//	a-old   default  go, backend  2025-01-10  alice
+//	a-new   default  go           2025-03-10  alice
+//	b-work  work     backend      2025-03-12  bob
+//	legacy  work     go, legacy   2025-02-01  carol (no version history)
+func newFilterApp(t *testing.T) *testApp {
+	t.Helper()
+	ta := newTestApp(t, nil)
+	alice := WithClientID(context.Background(), "alice")
+	bob := WithClientID(context.Background(), "bob")
+	if text, isErr := callAs(t, bob, ta.createContextHandler, map[string]any{"id": "work", "name": "Work"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	if text, isErr := callAs(t, bob, ta.switchContextHandler, map[string]any{"context_id": "work"}); isErr {
+		t.Fatalf("switch_context: %s", text)
+	}
+
+	for _, m := range []struct {
+		ctx                  context.Context
+		id, content, created string
+		tags                 []any
+	}{
+		{alice, "a-old", "database migrations in go", "2025-01-10T12:00:00Z", []any{"go", "backend"}},
+		{alice, "a-new", "go http handlers", "2025-03-10T12:00:00Z", []any{"go"}},
+		{bob, "b-work", "kubernetes deployment of the backend", "2025-03-12T12:00:00Z", []any{"backend"}},
+	} {
+		if text, isErr := callAs(t, m.ctx, ta.rememberHandler, map[string]any{"id": m.id, "content": m.content, "tags": m.tags}); isErr {
+			t.Fatalf("remember %s: %s", m.id, text)
+		}
+		ta.setCreatedAt(t, m.id, m.created)
+	}
+
+	// Stored by an older version, or another tool, without version history
+	content := "legacy go database driver"
+	embedding, _ := testEmbedding(context.Background(), content)
+	err := ta.vectorStore.AddDocument(context.Background(), chromem.Document{ID: "legacy", Content: content, Embedding: embedding, Metadata: map[string]string{
+		"context": "work", "tags": "go,legacy", "client": "carol", "created_at": "2025-02-01T12:00:00Z",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ta
+}
+
+var advancedResultID = regexp.MustCompile(`(?m)^\[([^\]]+)\] \(`)
+
+// advancedIDs returns the IDs search_advanced listed, in order.
+func advancedIDs(text string) []string {
+	var ids []string
+	for _, m := range advancedResultID.FindAllStringSubmatch(text, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+func TestSearchAdvancedFilterCombinations(t *testing.T) {
+	ta := newFilterApp(t)
+	for _, tc := range []struct {
+		name string
+		args map[string]any
+		want []string
+	}{
+		{"no filters", map[string]any{}, []string{"a-new", "a-old", "b-work", "legacy"}},
+		{"context", map[string]any{"context_id": "work"}, []string{"b-work", "legacy"}},
+		{"any tag", map[string]any{"tags": []any{"go"}}, []string{"a-new", "a-old", "legacy"}},
+		{"tags any", map[string]any{"tags": []any{"legacy", "backend"}}, []string{"a-old", "b-work", "legacy"}},
+		{"tags all", map[string]any{"tags": []any{"go", "backend"}, "tag_filter_mode": "all"}, []string{"a-old"}},
+		{"tag case", map[string]any{"tags": []any{"GO"}, "tag_filter_mode": "all"}, []string{"a-new", "a-old", "legacy"}},
+		{"date range", map[string]any{"created_after": "2025-02-01", "created_before": "2025-03-10"}, []string{"a-new", "legacy"}},
+		{"created after", map[string]any{"created_after": "2025-03-11"}, []string{"b-work"}},
+		{"author", map[string]any{"created_by": "alice"}, []string{"a-new", "a-old"}},
+		{"author without history", map[string]any{"created_by": "carol"}, []string{"legacy"}},
+		{"context and tag", map[string]any{"context_id": "work", "tags": []any{"go"}}, []string{"legacy"}},
+		{"context, author and tag", map[string]any{"context_id": "work", "created_by": "bob", "tags": []any{"backend"}}, []string{"b-work"}},
+		{"tag, author and date", map[string]any{"tags": []any{"go"}, "created_by": "alice", "created_after": "2025-03-01"}, []string{"a-new"}},
+		{"query and tag", map[string]any{"query": "database", "tags": []any{"go"}}, []string{"a-new", "a-old", "legacy"}},
+		{"query and context", map[string]any{"query": "database", "context_id": "work"}, []string{"b-work", "legacy"}},
+		{"query, context and author", map[string]any{"query": "go", "context_id": DefaultContextID, "created_by": "alice", "created_before": "2025-02-01"}, []string{"a-old"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			text, isErr := call(t, ta.searchAdvancedHandler, tc.args)
+			if isErr {
+				t.Fatalf("search_advanced: %s", text)
+			}
+			got := advancedIDs(text)
+			slices.Sort(got)
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("found %v, want %v:\n%s", got, tc.want, text)
+			}
+			// Similarity is only shown when there is a query to be similar to
+			if _, hasQuery := tc.args["query"]; strings.Contains(text, "Sim: ") != hasQuery {
+				t.Errorf("similarity shown = %v, want %v:\n%s", !hasQuery, hasQuery, text)
+			}
+		})
+	}
+}
+
+func TestSearchAdvancedEmptyResult(t *testing.T) {
+	ta := newFilterApp(t)
+	for _, args := range []map[string]any{
+		{"context_id": "work", "created_by": "alice"},
+		{"tags": []any{"go", "kubernetes"}, "tag_filter_mode": "all"},
+		{"created_after": "2026-01-01"},
+		{"query": "database", "created_by": "nobody"},
+	} {
+		text, isErr := call(t, ta.searchAdvancedHandler, args)
+		if isErr || text != "No memories match the search filters." {
+			t.Errorf("search_advanced %v = %q", args, text)
+		}
+	}
+
+	// An empty store is not an error either
+	empty := newTestApp(t, nil)
+	if text, isErr := call(t, empty.searchAdvancedHandler, map[string]any{"query": "anything"}); isErr || text != "No memories match the search filters." {
+		t.Errorf("search_advanced on an empty store = %q", text)
+	}
+}
+
+func TestSearchAdvancedOrderAndLimit(t *testing.T) {
+	ta := newFilterApp(t)
+
+	// With a query the closest memory comes first and the limit applies after filtering
+	text, _ := call(t, ta.searchAdvancedHandler, map[string]any{"query": "go http handlers", "tags": []any{"go"}, "max_results": 2.0})
+	if got := advancedIDs(text); len(got) != 2 || got[0] != "a-new" {
+		t.Errorf("ranked %v, want a-new first and 2 results:\n%s", got, text)
+	}
+
+	// Without one, the most recently updated memory comes first; legacy was never updated since 2025
+	text, _ = call(t, ta.searchAdvancedHandler, map[string]any{"context_id": "work"})
+	if got := advancedIDs(text); !slices.Equal(got, []string{"b-work", "legacy"}) {
+		t.Errorf("listed %v, want b-work then legacy", got)
+	}
+	if !strings.Contains(text, "Context: work, Tags: go, legacy, Created: 2025-02-01") {
+		t.Errorf("legacy is listed without its metadata:\n%s", text)
+	}
+
+	if text, isErr := call(t, ta.searchAdvancedHandler, map[string]any{"tag_filter_mode": "some", "tags": []any{"go"}}); !isErr {
+		t.Errorf("an invalid tag_filter_mode was accepted: %s", text)
+	}
+}
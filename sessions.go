@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Client sessions hold a client's current context and the contexts shared
+// with it. MCP sessions unregister theirs on disconnect, but sessions of
+// clients that crashed, or of share_context targets that never connected,
+// stay in brain_contexts.json until ended with end_session.
+
+// listSessionsHandler handles the list_sessions tool - lists every client
+// session with its current context and last activity, most recent first.
+func (a *App) listSessionsHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	sessions := a.ctx.ListSessions()
+	if len(sessions) == 0 {
+		return mcp.NewToolResultText("No client sessions."), nil
+	}
+
+	caller := a.clientIDFrom(ctx)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Client sessions (%d total):\n\n", len(sessions)))
+	for _, session := range sessions {
+		sb.WriteString(fmt.Sprintf("- [%s]", session.ClientID))
+		switch {
+		case session.ClientID == caller:
+			sb.WriteString(" (you)")
+		case a.clients.Connected(session.ClientID):
+			sb.WriteString(" (connected)")
+		}
+		sb.WriteString("\n")
+		sb.WriteString(fmt.Sprintf("  Current context: %s\n", session.CurrentContext))
+		sb.WriteString(fmt.Sprintf("  Last activity: %s\n", a.formatTime(session.LastActivity)))
+		sb.WriteString("\n")
+	}
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// getSessionHandler handles the get_session tool - shows all details of one
+// client session.
+func (a *App) getSessionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	clientID, _ := args["client_id"].(string)
+
+	clientID = strings.TrimSpace(clientID)
+	if clientID == "" {
+		return mcp.NewToolResultError("Client ID cannot be empty"), nil
+	}
+
+	session, err := a.ctx.GetSession(clientID)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Session not found: %v", err)), nil
+	}
+
+	connected := "no"
+	if clientID == a.clientIDFrom(ctx) || a.clients.Connected(clientID) {
+		connected = "yes"
+	}
+	sharedWith := "none"
+	if len(session.SharedWith) > 0 {
+		sharedWith = strings.Join(session.SharedWith, ", ")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Session '%s':\n", session.ClientID))
+	sb.WriteString(fmt.Sprintf("- Current context: %s\n", session.CurrentContext))
+	sb.WriteString(fmt.Sprintf("- Created: %s\n", a.formatTime(session.CreatedAt)))
+	sb.WriteString(fmt.Sprintf("- Last activity: %s\n", a.formatTime(session.LastActivity)))
+	sb.WriteString(fmt.Sprintf("- Shared with: %s\n", sharedWith))
+	sb.WriteString(fmt.Sprintf("- Connected: %s\n", connected))
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// endSessionHandler handles the end_session tool - removes a client session.
+// A client that is still connected gets a new session in the default context
+// on its next call.
+func (a *App) endSessionHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	clientID, _ := args["client_id"].(string)
+
+	clientID = strings.TrimSpace(clientID)
+	if clientID == "" {
+		return mcp.NewToolResultError("Client ID cannot be empty"), nil
+	}
+
+	if err := a.ctx.UnregisterSession(clientID); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to end session: %v", err)), nil
+	}
+	a.recordAudit(ctx, AuditEntry{Tool: "end_session", ClientID: a.clientIDFrom(ctx), Status: "ok",
+		Details: fmt.Sprintf("ended session of client %q", clientID)})
+
+	message := fmt.Sprintf("Session of client '%s' ended.", clientID)
+	if clientID == a.clientIDFrom(ctx) || a.clients.Connected(clientID) {
+		message += " The client is still connected and starts a new session in the default context on its next call."
+	}
+	return mcp.NewToolResultText(message), nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+var sourceLine = regexp.MustCompile(`(?m)^- \[([^\]]+)\] \(Sim: \d\.\d\d(.*)\)$`)
+
+// sourceIDs returns the IDs in the Sources section of an answer, in order, and
+// which of them are marked as cited.
+func sourceIDs(t *testing.T, answer string) ([]string, map[string]bool) {
+	t.Helper()
+	_, section, ok := strings.Cut(answer, "\n\nSources:\n")
+	if !ok {
+		t.Fatalf("the answer has no Sources section:\n%s", answer)
+	}
+	var ids []string
+	cited := make(map[string]bool)
+	for _, m := range sourceLine.FindAllStringSubmatch(section, -1) {
+		ids = append(ids, m[1])
+		cited[m[1]] = strings.Contains(m[2], "cited")
+	}
+	return ids, cited
+}
+
+// newSourcesApp returns a testApp with four memories about the office and an
+// LLM that cites one of them.
+func newSourcesApp(t *testing.T, configure func(cfg *Config)) (*testApp, *fakeLLM) {
+	t.Helper()
+	ta := newTestApp(t, configure)
+	ta.remember(t, "address", "the office is at 1 Main St", nil)
+	ta.remember(t, "hours", "the office opens at nine", nil)
+	ta.remember(t, "parking", "office parking is behind the building", nil)
+	ta.remember(t, "recipe", "bake the bread for forty minutes", nil)
+	llm := newFakeLLM("It is at 1 Main St [address].")
+	ta.llm = llm
+	return ta, llm
+}
+
+func TestAskBrainSourcesAreTheRetrievedMemories(t *testing.T) {
+	ta, llm := newSourcesApp(t, nil)
+	text, isErr := call(t, ta.askBrainHandler, map[string]any{"question": "where is the office", "max_results": 3.0})
+	if isErr || !strings.HasPrefix(text, "It is at 1 Main St [address].") {
+		t.Fatalf("ask_brain = %q", text)
+	}
+
+	prompt := llm.Prompts()[0]
+	retrieved := promptMemories(prompt)
+	if len(retrieved) != 3 || slices.Contains(retrieved, "recipe") {
+		t.Fatalf("the prompt holds %v, want the three office memories", retrieved)
+	}
+	ids, cited := sourceIDs(t, text)
+	if !slices.Equal(ids, retrieved) {
+		t.Errorf("sources %v, want exactly the memories in the prompt %v", ids, retrieved)
+	}
+	for _, id := range ids {
+		if cited[id] != (id == "address") {
+			t.Errorf("%s marked cited = %v", id, cited[id])
+		}
+	}
+	if !strings.Contains(prompt, "Cite every memory you use inline as [memory-id]") {
+		t.Errorf("the prompt does not ask for inline citations:\n%s", prompt)
+	}
+}
+
+func TestAskBrainIncludeSources(t *testing.T) {
+	ta, llm := newSourcesApp(t, nil)
+	text, _ := call(t, ta.askBrainHandler, map[string]any{"question": "where is the office", "include_sources": false})
+	if strings.Contains(text, "Sources:") || strings.Contains(llm.Prompts()[0], "Cite every memory") {
+		t.Errorf("include_sources false still cites sources:\n%s", text)
+	}
+
+	ta, llm = newSourcesApp(t, func(cfg *Config) { cfg.CiteSources = new(bool) })
+	text, _ = call(t, ta.askBrainHandler, map[string]any{"question": "where is the office"})
+	if strings.Contains(text, "Sources:") {
+		t.Errorf("cite_sources false in the config still cites sources:\n%s", text)
+	}
+	text, _ = call(t, ta.askBrainHandler, map[string]any{"question": "where is the office", "include_sources": true, "max_results": 2.0})
+	if ids, _ := sourceIDs(t, text); !slices.Equal(ids, promptMemories(llm.Prompts()[1])) {
+		t.Errorf("include_sources true lists %v", ids)
+	}
+}
+
+func TestCLIAskPrintsSources(t *testing.T) {
+	ta, _ := newSourcesApp(t, nil)
+	out := ta.runScript(t, "ask where is the office\n")
+	if !strings.Contains(out, "It is at 1 Main St [address].") {
+		t.Fatalf("ask printed:\n%s", out)
+	}
+	if ids, cited := sourceIDs(t, out); len(ids) == 0 || !cited["address"] {
+		t.Errorf("ask printed sources %v:\n%s", ids, out)
+	}
+}
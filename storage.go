@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// CorruptStoreError reports a local database that exists but cannot be read.
+// Unlike a missing key or a dimension mismatch, it is worth recovering from a
+// backup.
+type CorruptStoreError struct {
+	Path string // Database directory, or the encrypted database file
+	Err  error
+}
+
+func (e *CorruptStoreError) Error() string {
+	return fmt.Sprintf("the local database %s cannot be read: %v", e.Path, e.Err)
+}
+
+func (e *CorruptStoreError) Unwrap() error { return e.Err }
+
+// openVectorBackend opens the configured backend. An unreadable local
+// database is set aside as <name>.corrupt.<timestamp> and replaced with its
+// newest copy in backupDir that opens, trying older ones in turn. Without a
+// usable backup startup fails and the database is put back as it was, unless
+// forceFresh starts with an empty brain instead; the unreadable database is
+// never overwritten.
+func openVectorBackend(cfg *Config, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, logger *log.Logger, backupDir string, forceFresh bool) (VectorBackend, error) {
+	backend, err := NewVectorBackend(cfg, embFunc, batchEmbf, logger)
+	var corrupt *CorruptStoreError
+	if !errors.As(err, &corrupt) {
+		return backend, err
+	}
+	logger.Printf("Error: %v", err)
+
+	path := corrupt.Path
+	backups := backupCopies(backupDir, filepath.Base(path))
+	if len(backups) == 0 && !forceFresh {
+		return nil, fmt.Errorf("%w; no backup found in %s. Start with -force-fresh to set it aside and start with an empty brain", err, backupDir)
+	}
+
+	aside := fmt.Sprintf("%s.corrupt.%s", path, time.Now().UTC().Format(backupTimeFormat))
+	for i := 2; ; i++ {
+		if _, err := os.Stat(aside); os.IsNotExist(err) {
+			break
+		}
+		aside = fmt.Sprintf("%s.corrupt.%s-%d", path, time.Now().UTC().Format(backupTimeFormat), i)
+	}
+	if err := os.Rename(path, aside); err != nil {
+		return nil, fmt.Errorf("failed to set aside the unreadable database: %w", err)
+	}
+	logger.Printf("Moved the unreadable database to %s", aside)
+
+	for _, backup := range backups {
+		if err := copyPath(backup, path); err != nil {
+			logger.Printf("Warning: Failed to restore %s: %v", backup, err)
+			os.RemoveAll(path)
+			continue
+		}
+		backend, err := NewVectorBackend(cfg, embFunc, batchEmbf, logger)
+		if err == nil {
+			logger.Printf("Recovered the local database from %s; memories stored after that backup are lost, run verify_integrity to fix context counts", backup)
+			return backend, nil
+		}
+		logger.Printf("Warning: Backup %s cannot be used either: %v", backup, err)
+		os.RemoveAll(path)
+		if !errors.As(err, &corrupt) {
+			break
+		}
+	}
+
+	if forceFresh {
+		logger.Printf("Starting with an empty brain (-force-fresh); the unreadable database is kept as %s", aside)
+		return NewVectorBackend(cfg, embFunc, batchEmbf, logger)
+	}
+	if err := os.Rename(aside, path); err != nil {
+		return nil, fmt.Errorf("no backup could be restored, and putting the database back failed (it is kept as %s): %w", aside, err)
+	}
+	return nil, fmt.Errorf("%w; no backup in %s could be restored. Start with -force-fresh to set it aside and start with an empty brain", corrupt, backupDir)
+}
+
+// backupCopies returns the copies of the file or directory name in the
+// backups in dir, newest first.
+func backupCopies(dir, name string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var copies []string
+	for _, entry := range entries {
+		if _, err := time.Parse(backupTimeFormat, entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name(), name)
+		if _, err := os.Stat(path); err == nil {
+			copies = append(copies, path)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(copies)))
+	return copies
+}
+
+// storageProblems collects the findings of verify_storage by kind, with up
+// to MaxStorageProblemIDs example IDs each.
+type storageProblems struct {
+	order []string
+	ids   map[string][]string
+	count map[string]int
+}
+
+// add records id under the problem kind.
+func (p *storageProblems) add(kind, id string) {
+	if p.ids == nil {
+		p.ids, p.count = make(map[string][]string), make(map[string]int)
+	}
+	if p.count[kind] == 0 {
+		p.order = append(p.order, kind)
+	}
+	p.count[kind]++
+	if len(p.ids[kind]) < MaxStorageProblemIDs {
+		p.ids[kind] = append(p.ids[kind], id)
+	}
+}
+
+// verifyStorageHandler handles the verify_storage tool - checks the stored
+// memories without changing anything: the document count, embedding
+// dimensions and values, and the metadata the server relies on.
+func (a *App) verifyStorageHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	docs, err := a.vectorStore.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list memories: %v", err)), nil
+	}
+
+	var problems storageProblems
+	var sb strings.Builder
+	if count := a.vectorStore.Count(); count != len(docs) {
+		sb.WriteString(fmt.Sprintf("- The backend counts %d documents but lists %d\n", count, len(docs)))
+	}
+
+	// The most common embedding length is taken as the store's dimension
+	dims := make(map[int]int)
+	for _, doc := range docs {
+		if len(doc.Embedding) > 0 {
+			dims[len(doc.Embedding)]++
+		}
+	}
+	dim := 0
+	for d, n := range dims {
+		if n > dims[dim] || (n == dims[dim] && d > dim) {
+			dim = d
+		}
+	}
+
+	ids := make(map[string]chromem.Document, len(docs))
+	chunks := make(map[string]int)
+	for _, doc := range docs {
+		ids[doc.ID] = doc
+		if parent := doc.Metadata["parent_id"]; parent != "" {
+			chunks[parent]++
+		}
+	}
+	contexts := make(map[string]bool)
+	for _, c := range a.ctx.ListContexts() {
+		contexts[c.ID] = true
+	}
+
+	actual := make(map[string]int)
+	for _, doc := range docs {
+		switch {
+		case len(doc.Embedding) == 0:
+			problems.add("missing embedding", doc.ID)
+		case len(doc.Embedding) != dim:
+			problems.add(fmt.Sprintf("embedding dimension other than %d", dim), doc.ID)
+		case vecmath.CheckFinite(doc.Embedding) != nil || !vecmath.IsUnit(doc.Embedding):
+			problems.add("invalid embedding values (run verify_integrity to re-embed)", doc.ID)
+		}
+
+		contextID := doc.Metadata["context"]
+		if contextID == "" {
+			contextID = DefaultContextID
+		}
+		if !contexts[contextID] {
+			problems.add("unknown context", doc.ID)
+		}
+		if value := doc.Metadata["created_at"]; value != "" {
+			if _, _, err := parseStoredTime(value); err != nil {
+				problems.add("unreadable created_at", doc.ID)
+			}
+		}
+		if parent := doc.Metadata["parent_id"]; parent != "" {
+			if _, ok := ids[parent]; !ok {
+				problems.add("chunk without its parent memory", doc.ID)
+			}
+			continue
+		}
+		if isChunkedParent(doc.Metadata) && chunkCount(doc.Metadata) != chunks[doc.ID] {
+			problems.add("chunk_count differs from the stored chunks", doc.ID)
+		}
+		if !isSoftDeleted(doc.Metadata) {
+			actual[contextID]++
+		}
+	}
+
+	for _, kind := range problems.order {
+		example := strings.Join(problems.ids[kind], ", ")
+		if problems.count[kind] > len(problems.ids[kind]) {
+			example += ", ..."
+		}
+		sb.WriteString(fmt.Sprintf("- %d with %s: %s\n", problems.count[kind], kind, example))
+	}
+	for _, c := range a.ctx.ListContexts() {
+		if c.MemoryCount != actual[c.ID] {
+			sb.WriteString(fmt.Sprintf("- Context '%s' records %d memories but holds %d (run verify_integrity)\n", c.ID, c.MemoryCount, actual[c.ID]))
+		}
+	}
+
+	summary := fmt.Sprintf("Checked %d documents", len(docs))
+	if dim > 0 {
+		summary += fmt.Sprintf(" with %d-dimensional embeddings", dim)
+	}
+	if sb.Len() == 0 {
+		return mcp.NewToolResultText(summary + ": no problems found."), nil
+	}
+	return mcp.NewToolResultText(summary + ". Problems:\n" + sb.String()), nil
+}
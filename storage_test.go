@@ -0,0 +1,229 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// startupStore describes how the local database is kept on disk: plain gob
+// files in a directory, or one encrypted export.
+type startupStore struct {
+	key string // encryption key, "" for plain text
+}
+
+// path returns the file or directory holding the database in dataDir.
+func (s startupStore) path(dataDir string) string {
+	if s.key != "" {
+		return encryptedDBPath(filepath.Join(dataDir, DefaultDBPath))
+	}
+	return filepath.Join(dataDir, DefaultDBPath)
+}
+
+// write stores a database with the memories ids in dataDir.
+func (s startupStore) write(t *testing.T, dataDir string, ids ...string) {
+	t.Helper()
+	var cipher *FileCipher
+	if s.key != "" {
+		cipher = NewFileCipher(s.key)
+	}
+	store, err := NewLocalVectorStore(filepath.Join(dataDir, DefaultDBPath), cipher, testEmbedding, nil, 0, nil)
+	if err != nil {
+		t.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	var docs []chromem.Document
+	for _, id := range ids {
+		docs = append(docs, chromem.Document{ID: id, Content: "memory " + id, Metadata: map[string]string{"context": DefaultContextID}})
+	}
+	if err := store.AddDocuments(t.Context(), docs, 1); err != nil {
+		t.Fatalf("AddDocuments: %v", err)
+	}
+	// Plain text databases are written as documents are added
+	if s.key != "" {
+		if err := store.SaveToDisk(); err != nil {
+			t.Fatalf("SaveToDisk: %v", err)
+		}
+	}
+}
+
+// truncate cuts the database in dataDir to half its size, as a crash or a
+// full disk would: the encrypted export, or every document file of a plain
+// text database.
+func (s startupStore) truncate(t *testing.T, dataDir string) {
+	t.Helper()
+	files := []string{s.path(dataDir)}
+	if s.key == "" {
+		files, _ = filepath.Glob(filepath.Join(s.path(dataDir), "*", "*.gob.gz"))
+		files = slices.DeleteFunc(files, func(f string) bool { return filepath.Base(f) == "00000000.gob.gz" })
+	}
+	if len(files) == 0 {
+		t.Fatal("no database files to truncate")
+	}
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Truncate(f, info.Size()/2); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readTree returns the contents of the file or directory at path by
+// relative file name.
+func readTree(t *testing.T, path string) map[string]string {
+	t.Helper()
+	files := map[string]string{}
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		rel, _ := filepath.Rel(path, p)
+		files[rel] = string(data)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return files
+}
+
+// openAtStartup opens the local database in dataDir the way main does.
+func (s startupStore) openAtStartup(t *testing.T, dataDir string, forceFresh bool) (VectorBackend, error) {
+	t.Helper()
+	t.Setenv("BRAINMCP_DATA_DIR", dataDir)
+	cfg := DefaultConfig()
+	cfg.EmbeddingProvider = "ollama"
+	cfg.Storage.EncryptionKey = s.key
+	backend, err := openVectorBackend(cfg, testEmbedding, nil, log.New(io.Discard, "", 0), filepath.Join(dataDir, "backups"), forceFresh)
+	if err == nil {
+		t.Cleanup(func() { backend.Close() })
+	}
+	return backend, err
+}
+
+// backUp copies the database in dataDir into the backup named stamp.
+func (s startupStore) backUp(t *testing.T, dataDir, stamp string) string {
+	t.Helper()
+	dst := filepath.Join(dataDir, "backups", stamp, filepath.Base(s.path(dataDir)))
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := copyPath(s.path(dataDir), dst); err != nil {
+		t.Fatalf("copyPath: %v", err)
+	}
+	return dst
+}
+
+// setAside returns the copies of the database set aside as corrupt.
+func (s startupStore) setAside(dataDir string) []string {
+	aside, _ := filepath.Glob(s.path(dataDir) + ".corrupt.*")
+	return aside
+}
+
+var startupStores = map[string]startupStore{"plain": {}, "encrypted": {key: "startup"}}
+
+func TestStartupRefusesTruncatedDatabase(t *testing.T) {
+	for name, store := range startupStores {
+		t.Run(name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			store.write(t, dataDir, "a", "b", "c")
+			store.truncate(t, dataDir)
+			truncated := readTree(t, store.path(dataDir))
+
+			_, err := store.openAtStartup(t, dataDir, false)
+			if err == nil || !strings.Contains(err.Error(), "cannot be read") || !strings.Contains(err.Error(), "-force-fresh") {
+				t.Fatalf("startup error = %v, want the unreadable database reported with -force-fresh", err)
+			}
+			// Nothing is overwritten or left aside
+			if got := readTree(t, store.path(dataDir)); !maps.Equal(got, truncated) {
+				t.Error("the truncated database changed")
+			}
+			if aside := store.setAside(dataDir); len(aside) != 0 {
+				t.Errorf("copies left aside: %v", aside)
+			}
+		})
+	}
+}
+
+func TestStartupRecoversTruncatedDatabaseFromBackup(t *testing.T) {
+	for name, store := range startupStores {
+		t.Run(name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			store.write(t, dataDir, "a", "b")
+			store.backUp(t, dataDir, "20260101-000000")
+			store.write(t, dataDir, "c")
+			store.truncate(t, dataDir)
+			truncated := readTree(t, store.path(dataDir))
+			// The newest backup was taken after the damage
+			badBackup := store.backUp(t, dataDir, "20260102-000000")
+
+			backend, err := store.openAtStartup(t, dataDir, false)
+			if err != nil {
+				t.Fatalf("startup: %v", err)
+			}
+			docs, err := backend.ListDocuments(t.Context(), nil, 0, 0)
+			if err != nil {
+				t.Fatalf("ListDocuments: %v", err)
+			}
+			var ids []string
+			for _, doc := range docs {
+				ids = append(ids, doc.ID)
+			}
+			slices.Sort(ids)
+			if !slices.Equal(ids, []string{"a", "b"}) {
+				t.Errorf("recovered memories %v, want those of the older backup", ids)
+			}
+
+			aside := store.setAside(dataDir)
+			if len(aside) != 1 || !maps.Equal(readTree(t, aside[0]), truncated) {
+				t.Fatalf("set aside %v, want the truncated database once", aside)
+			}
+			if !maps.Equal(readTree(t, badBackup), truncated) {
+				t.Error("the unusable backup was changed")
+			}
+		})
+	}
+}
+
+func TestStartupForceFresh(t *testing.T) {
+	for name, store := range startupStores {
+		t.Run(name, func(t *testing.T) {
+			dataDir := t.TempDir()
+			store.write(t, dataDir, "a", "b")
+			store.truncate(t, dataDir)
+			truncated := readTree(t, store.path(dataDir))
+			store.backUp(t, dataDir, "20260101-000000")
+
+			// Without -force-fresh an unusable backup is no way out
+			if _, err := store.openAtStartup(t, dataDir, false); err == nil || !strings.Contains(err.Error(), "no backup in") {
+				t.Fatalf("startup with only an unusable backup = %v", err)
+			}
+			if got := readTree(t, store.path(dataDir)); !maps.Equal(got, truncated) || len(store.setAside(dataDir)) != 0 {
+				t.Fatal("the truncated database was not put back")
+			}
+
+			backend, err := store.openAtStartup(t, dataDir, true)
+			if err != nil {
+				t.Fatalf("startup with -force-fresh: %v", err)
+			}
+			if n := backend.Count(); n != 0 {
+				t.Errorf("fresh start holds %d memories", n)
+			}
+			aside := store.setAside(dataDir)
+			if len(aside) != 1 || !maps.Equal(readTree(t, aside[0]), truncated) {
+				t.Errorf("set aside %v, want the truncated database kept", aside)
+			}
+		})
+	}
+}
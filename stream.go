@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// answerStreamKey is the context key of the function that receives a
+// streamed ask_brain answer outside MCP, e.g. in the CLI.
+type answerStreamKey struct{}
+
+// withAnswerStream returns a context in which ask_brain streams its answer
+// to onText as it is generated.
+func withAnswerStream(ctx context.Context, onText func(string)) context.Context {
+	return context.WithValue(ctx, answerStreamKey{}, onText)
+}
+
+// answerStream returns where ask_brain should stream the answer of request:
+// to progress notifications if the client sent a progress token, to the
+// context's answer stream if there is one, and nil to answer in one piece.
+// Each notification carries the next piece of the answer as its message.
+func (a *App) answerStream(ctx context.Context, request mcp.CallToolRequest) func(string) {
+	if onText, ok := ctx.Value(answerStreamKey{}).(func(string)); ok {
+		return onText
+	}
+	progress := a.newProgress(ctx, request, 0, "")
+	if progress.token == nil {
+		return nil
+	}
+	pieces := 0
+	return func(text string) {
+		pieces++
+		progress.notify(pieces, text)
+	}
+}
+
+// generateAnswer runs the ask_brain completion with opts, streaming it to
+// onText when onText is set. Providers that cannot stream deliver the answer as a single
+// piece. If the stream breaks off after part of the answer arrived, that part
+// is returned with a note about the failure instead of an error.
+func (a *App) generateAnswer(ctx context.Context, prompt string, opts *GenerateOptions, onText func(string)) (string, error) {
+	if onText == nil {
+		return a.generate(ctx, prompt, opts)
+	}
+	streamer, ok := a.llm.(StreamingLLM)
+	if !ok {
+		answer, err := a.generate(ctx, prompt, opts)
+		if err == nil {
+			onText(answer)
+		}
+		return answer, err
+	}
+
+	answer, err := streamer.GenerateStream(ctx, prompt, opts, onText)
+	if err != nil && answer != "" && !errors.Is(err, errNoAnswer) {
+		a.logf(ctx, "Warning: Answer stream failed after %d bytes: %v", len(answer), err)
+		note := fmt.Sprintf("\n\n[Answer incomplete: the stream failed: %v]", err)
+		onText(note)
+		return answer + note, nil
+	}
+	return answer, err
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// fakeStreamLLM is a StreamingLLM that streams pieces, failing with err
+// instead of sending the piece at index failAt if failAt is not negative.
+// Generate answers with all the pieces at once.
+type fakeStreamLLM struct {
+	pieces []string
+	failAt int
+	err    error
+
+	mu      sync.Mutex
+	streams int
+}
+
+func newFakeStreamLLM(pieces ...string) *fakeStreamLLM {
+	return &fakeStreamLLM{pieces: pieces, failAt: -1}
+}
+
+func (f *fakeStreamLLM) Generate(context.Context, string, *GenerateOptions) (string, error) {
+	return strings.Join(f.pieces, ""), nil
+}
+
+func (f *fakeStreamLLM) GenerateStream(_ context.Context, _ string, _ *GenerateOptions, onText func(string)) (string, error) {
+	f.mu.Lock()
+	f.streams++
+	f.mu.Unlock()
+	var sb strings.Builder
+	for i, piece := range f.pieces {
+		if i == f.failAt {
+			return sb.String(), f.err
+		}
+		sb.WriteString(piece)
+		onText(piece)
+	}
+	return sb.String(), nil
+}
+
+func (f *fakeStreamLLM) Name() string { return "fake-stream" }
+
+// Streams returns the number of GenerateStream calls so far.
+func (f *fakeStreamLLM) Streams() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.streams
+}
+
+// askStreamed asks ask_brain with its answer streamed to a collector and
+// returns the result and the streamed pieces.
+func askStreamed(t *testing.T, ta *testApp) (string, bool, []string) {
+	t.Helper()
+	var pieces []string
+	ctx := withAnswerStream(t.Context(), func(text string) { pieces = append(pieces, text) })
+	text, isErr := callAs(t, ctx, ta.askBrainHandler, map[string]any{"question": "where is the office", "include_sources": false})
+	return text, isErr, pieces
+}
+
+func TestAskBrainStreamsTheAnswer(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "address", "the office is at 1 Main St", nil)
+	ta.llm = newFakeStreamLLM("It is ", "at 1 ", "Main St.")
+
+	text, isErr, pieces := askStreamed(t, ta)
+	if isErr || text != "It is at 1 Main St." {
+		t.Errorf("ask_brain = %q", text)
+	}
+	if want := []string{"It is ", "at 1 ", "Main St."}; strings.Join(pieces, "|") != strings.Join(want, "|") {
+		t.Errorf("streamed %q, want %q", pieces, want)
+	}
+
+	// A provider that cannot stream delivers the answer as one piece
+	ta.llm = newFakeLLM("It is at 1 Main St.")
+	if _, _, pieces = askStreamed(t, ta); len(pieces) != 1 || pieces[0] != "It is at 1 Main St." {
+		t.Errorf("non-streaming provider streamed %q", pieces)
+	}
+}
+
+func TestAskBrainStreamBreaksOff(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "address", "the office is at 1 Main St", nil)
+	llm := newFakeStreamLLM("It is ", "at 1 ", "Main St.")
+	llm.failAt, llm.err = 2, errors.New("connection reset")
+	ta.llm = llm
+
+	text, isErr, pieces := askStreamed(t, ta)
+	note := "\n\n[Answer incomplete: the stream failed: connection reset]"
+	if isErr || text != "It is at 1 "+note {
+		t.Errorf("ask_brain after a broken stream = %q, want the partial answer and a note", text)
+	}
+	if len(pieces) != 3 || pieces[2] != note {
+		t.Errorf("streamed %q, want the two pieces and the note", pieces)
+	}
+
+	// Failing before any text is an error
+	llm.failAt = 0
+	text, isErr, _ = askStreamed(t, ta)
+	if !isErr || !strings.Contains(text, "LLM synthesis failed: connection reset") {
+		t.Errorf("ask_brain after a stream failing at once = %q, want an error", text)
+	}
+}
+
+// notifyingSession is an initialized MCP client session that keeps the
+// notifications sent to it.
+type notifyingSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *notifyingSession) Initialize()       {}
+func (s *notifyingSession) Initialized() bool { return true }
+func (s *notifyingSession) SessionID() string { return s.id }
+func (s *notifyingSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// callOverMCP sends a tools/call of ask_brain through an MCP server on a
+// notifyingSession, with meta as the request's _meta, and returns the result
+// text and the notifications the session received.
+func callOverMCP(t *testing.T, ta *testApp, meta map[string]any) (string, []mcp.JSONRPCNotification) {
+	t.Helper()
+	srv := server.NewMCPServer("brainmcp-test", "0", server.WithToolCapabilities(false))
+	srv.AddTool(mcp.NewTool("ask_brain"), ta.askBrainHandler)
+	session := &notifyingSession{id: "streaming-session", notifications: make(chan mcp.JSONRPCNotification, 64)}
+
+	params := map[string]any{"name": "ask_brain", "arguments": map[string]any{"question": "where is the office", "include_sources": false}}
+	if meta != nil {
+		params["_meta"] = meta
+	}
+	message, _ := json.Marshal(map[string]any{"jsonrpc": "2.0", "id": 1, "method": "tools/call", "params": params})
+	reply := srv.HandleMessage(srv.WithContext(t.Context(), session), message)
+	response, ok := reply.(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("tools/call replied %#v", reply)
+	}
+	result := response.Result.(*mcp.CallToolResult)
+
+	close(session.notifications)
+	var notifications []mcp.JSONRPCNotification
+	for n := range session.notifications {
+		notifications = append(notifications, n)
+	}
+	return result.Content[0].(mcp.TextContent).Text, notifications
+}
+
+func TestAskBrainStreamsOverProgressNotifications(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "address", "the office is at 1 Main St", nil)
+	llm := newFakeStreamLLM("It is ", "at 1 ", "Main St.")
+	ta.llm = llm
+
+	text, notifications := callOverMCP(t, ta, map[string]any{"progressToken": "answer-1"})
+	if text != "It is at 1 Main St." {
+		t.Errorf("result = %q, want the whole answer too", text)
+	}
+	if len(notifications) != 3 {
+		t.Fatalf("got %d notifications, want one per piece", len(notifications))
+	}
+	for i, n := range notifications {
+		fields := n.Params.AdditionalFields
+		if n.Method != "notifications/progress" || fields["progressToken"] != "answer-1" || fields["progress"] != i+1 || fields["message"] != llm.pieces[i] {
+			t.Errorf("notification %d = %s %v", i, n.Method, fields)
+		}
+		if _, ok := fields["total"]; ok {
+			t.Errorf("notification %d has a total, but the answer length is not known", i)
+		}
+	}
+
+	// Without a progress token the answer comes in one piece, without streaming
+	text, notifications = callOverMCP(t, ta, nil)
+	if text != "It is at 1 Main St." || len(notifications) != 0 || llm.Streams() != 1 {
+		t.Errorf("without a progress token: %q, %d notifications, %d streams", text, len(notifications), llm.Streams())
+	}
+}
+
+func TestCLIAskPrintsTheStreamOnce(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "address", "the office is at 1 Main St", nil)
+	ta.llm = newFakeStreamLLM("It is ", "at 1 Main St ", "[address].")
+
+	out := ta.runScript(t, "ask where is the office\n")
+	if n := strings.Count(out, "It is at 1 Main St [address]."); n != 1 {
+		t.Errorf("the answer was printed %d times:\n%s", n, out)
+	}
+	if !strings.Contains(out, "Sources:\n- [address]") {
+		t.Errorf("ask did not print the sources after the stream:\n%s", out)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Tags are stored in the "tags" metadata value as a JSON array of strings,
+// e.g. ["go","a, b"], so a tag may contain commas. Older versions joined tags
+// with commas; ParseTags still reads that form and migrateTagEncoding
+// rewrites it once on startup.
+
+// ParseTags decodes a tags metadata value, either a JSON array or the older
+// comma-separated form. Tags are trimmed, empty tags are dropped and tags
+// differing only in case are kept once, in their first spelling.
+func ParseTags(value string) []string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	var tags []string
+	if !strings.HasPrefix(value, "[") || json.Unmarshal([]byte(value), &tags) != nil {
+		tags = strings.Split(value, ",")
+	}
+	return normalizeTags(tags)
+}
+
+// EncodeTags returns the tags metadata value for tags, normalized as by
+// ParseTags, or "" when no tags remain.
+func EncodeTags(tags []string) string {
+	tags = normalizeTags(tags)
+	if len(tags) == 0 {
+		return ""
+	}
+	// Marshaling a []string cannot fail
+	encoded, _ := json.Marshal(tags)
+	return string(encoded)
+}
+
+// mergeTags returns the tags metadata value holding the tags of both values.
+func mergeTags(value, add string) string {
+	return EncodeTags(append(ParseTags(value), ParseTags(add)...))
+}
+
+// normalizeTags trims tags and drops empty ones and case-insensitive repeats.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || hasTag(normalized, tag) {
+			continue
+		}
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
+
+// hasTag reports whether tags include tag, ignoring case.
+func hasTag(tags []string, tag string) bool {
+	return matchesTags(tags, []string{tag}, true)
+}
+
+// migrateTagEncoding rewrites tags stored in the comma-separated form as JSON
+// arrays. It runs once per data directory: marker is written when every
+// memory has been rewritten, and its presence skips the migration.
+func migrateTagEncoding(ctx context.Context, backend VectorBackend, marker string, logger *log.Logger) error {
+	if _, err := os.Stat(marker); err == nil {
+		return nil
+	}
+
+	docs, err := backend.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return fmt.Errorf("failed to list memories: %w", err)
+	}
+	migrated := 0
+	var errs []error
+	for _, doc := range docs {
+		value := doc.Metadata["tags"]
+		encoded := EncodeTags(ParseTags(value))
+		if encoded == value {
+			continue
+		}
+		if encoded == "" {
+			delete(doc.Metadata, "tags")
+		} else {
+			doc.Metadata["tags"] = encoded
+		}
+		if err := backend.UpdateMetadata(ctx, doc.ID, doc.Metadata); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", doc.ID, err))
+			continue
+		}
+		migrated++
+	}
+	if migrated > 0 {
+		logger.Printf("Migrated tags of %d memories to the JSON encoding", migrated)
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to migrate tags of %d memories: %w", len(errs), errors.Join(errs...))
+	}
+	return os.WriteFile(marker, []byte("1\n"), 0644)
+}
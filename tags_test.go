@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// checkTagCounts fails the test unless every tag's MemoryCount equals the
+// number of stored memories carrying it, and the counts are those in want.
+func checkTagCounts(t *testing.T, ta *testApp, want map[string]int) {
+	t.Helper()
+	docs, err := ta.vectorStore.ListDocuments(t.Context(), nil, 0, 0)
+	if err != nil {
+		t.Fatalf("ListDocuments: %v", err)
+	}
+	stored := make(map[string]int)
+	for _, doc := range docs {
+		for _, tag := range ParseTags(doc.Metadata["tags"]) {
+			stored[tag]++
+		}
+	}
+	got := make(map[string]int)
+	for _, tag := range ta.ctx.ListTags() {
+		got[tag.Name] = tag.MemoryCount
+		if tag.MemoryCount != stored[tag.Name] {
+			t.Errorf("tag %s counts %d memories, but %d carry it", tag.Name, tag.MemoryCount, stored[tag.Name])
+		}
+	}
+	for name, n := range want {
+		if got[name] != n {
+			t.Errorf("tag %s counts %d memories, want %d (all counts %v)", name, got[name], n, got)
+		}
+	}
+}
+
+func TestRememberTagsAreCounted(t *testing.T) {
+	ta := newTestApp(t, nil)
+	text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "invoice", "content": "invoices go out on the 1st", "tags": []any{"Billing", " ops ", "billing", ""}})
+	if isErr || !strings.HasSuffix(text, " Tags: billing, ops.") {
+		t.Errorf("remember = %q, want it to confirm the normalized tags", text)
+	}
+	checkTagCounts(t, ta, map[string]int{"billing": 1, "ops": 1})
+	if got := ta.history(t, "invoice").Tags; !slices.Equal(got, []string{"billing", "ops"}) {
+		t.Errorf("version history tags = %v", got)
+	}
+
+	// Updating keeps the stored tags and counts the memory once per tag
+	ta.remember(t, "invoice", "invoices go out on the 2nd", map[string]any{"tags": []any{"ops", "finance"}})
+	doc, _ := ta.vectorStore.GetByID(t.Context(), "invoice")
+	if got := ParseTags(doc.Metadata["tags"]); !slices.Equal(got, []string{"billing", "ops", "finance"}) {
+		t.Errorf("tags after the update = %v", got)
+	}
+	if got := ta.history(t, "invoice").Tags; !slices.Equal(got, []string{"billing", "ops", "finance"}) {
+		t.Errorf("version history tags after the update = %v", got)
+	}
+	checkTagCounts(t, ta, map[string]int{"billing": 1, "ops": 1, "finance": 1})
+
+	// A comma-separated string works too
+	ta.remember(t, "payroll", "payroll runs on the 25th", map[string]any{"tags": "Finance,payroll"})
+	text, isErr = call(t, ta.rememberBatchHandler, map[string]any{"memories": []any{
+		map[string]any{"id": "audit", "content": "the audit is in March", "tags": []any{"finance", "ops"}},
+		map[string]any{"id": "lunch", "content": "lunch is at noon"},
+	}})
+	if isErr {
+		t.Fatalf("remember_batch: %s", text)
+	}
+	checkTagCounts(t, ta, map[string]int{"billing": 1, "ops": 2, "finance": 3, "payroll": 1})
+
+	// A failed store counts nothing
+	ta.vectorStore = failingWrites{ta.vectorStore}
+	if text, isErr := call(t, ta.rememberHandler, map[string]any{"id": "rota", "content": "the rota changes weekly", "tags": []any{"ops", "rota"}}); !isErr {
+		t.Fatalf("remember on a failing store = %q", text)
+	}
+	if text, isErr := call(t, ta.rememberBatchHandler, map[string]any{"memories": []any{
+		map[string]any{"id": "audit", "content": "the audit moved to April", "tags": []any{"rota"}},
+	}}); !isErr {
+		t.Fatalf("remember_batch on a failing store = %q", text)
+	}
+	checkTagCounts(t, ta, map[string]int{"billing": 1, "ops": 2, "finance": 3, "payroll": 1, "rota": 0})
+}
+
+func TestRememberTagsInSearchResults(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "deploy", "deploys happen on Friday", map[string]any{"tags": []any{"Ops", "release"}})
+	ta.remember(t, "lunch", "lunch is at noon", nil)
+
+	results, err := ta.searchMemories(t.Context(), SearchModeSemantic, "when are deploys", 2, nil)
+	if err != nil || len(results) == 0 || results[0].ID != "deploy" {
+		t.Fatalf("search = %v, %v; want deploy first", results, err)
+	}
+	if got := ParseTags(results[0].Metadata["tags"]); !slices.Equal(got, []string{"ops", "release"}) {
+		t.Errorf("search result tags = %v, want ops, release", got)
+	}
+
+	text, _ := call(t, ta.searchAdvancedHandler, map[string]any{"query": "when are deploys", "tags": []any{"release"}})
+	if got := advancedIDs(text); !slices.Equal(got, []string{"deploy"}) || !strings.Contains(text, "Tags: ops, release") {
+		t.Errorf("search_advanced by tag:\n%s", text)
+	}
+}
+
+// Changing tags only rewrites metadata: no tool embeds the memory again.
+func TestTagChangesMakeNoEmbeddingCalls(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "deploy", "deploys happen on Friday", nil)
+	ta.remember(t, "rollback", "rollbacks need two approvals", map[string]any{"tags": []any{"ops"}})
+	before, _ := ta.vectorStore.GetByID(t.Context(), "deploy")
+	calls := ta.embedder.Count()
+	if calls == 0 {
+		t.Fatal("remember made no embedding calls the embedder counted")
+	}
+
+	for _, step := range []struct {
+		handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error)
+		args    map[string]any
+	}{
+		{ta.addTagHandler, map[string]any{"memory_id": "deploy", "tag": "release"}},
+		{ta.addTagHandler, map[string]any{"memory_id": "deploy", "tag": "release"}},
+		{ta.removeTagHandler, map[string]any{"memory_id": "deploy", "tag": "release"}},
+		{ta.batchOperationsHandler, map[string]any{"operation": "add_tags", "memories": []any{"deploy", "rollback"}, "tags": []any{"weekly", "ops"}}},
+		{ta.batchOperationsHandler, map[string]any{"operation": "remove_tags", "memories": []any{"rollback"}, "tags": []any{"ops"}}},
+	} {
+		if text, isErr := call(t, step.handler, step.args); isErr {
+			t.Fatalf("%v: %s", step.args, text)
+		}
+	}
+	ta.runScript(t, "tag deploy friday\n")
+
+	if n := ta.embedder.Count() - calls; n != 0 {
+		t.Errorf("tag changes made %d embedding calls, want 0", n)
+	}
+	after, _ := ta.vectorStore.GetByID(t.Context(), "deploy")
+	if !slices.Equal(after.Embedding, before.Embedding) || after.Content != before.Content {
+		t.Error("a tag change replaced the stored embedding or content")
+	}
+	if got := ParseTags(after.Metadata["tags"]); !slices.Equal(got, []string{"weekly", "ops", "friday"}) {
+		t.Errorf("deploy tags = %v, want weekly, ops, friday", got)
+	}
+	checkTagCounts(t, ta, map[string]int{"release": 0, "weekly": 2, "ops": 1, "friday": 1})
+
+	// The memory is still found by meaning
+	results, err := ta.searchMemories(t.Context(), SearchModeSemantic, "when do deploys happen", 1, nil)
+	if err != nil || len(results) != 1 || results[0].ID != "deploy" {
+		t.Errorf("search after tag changes = %v, %v", results, err)
+	}
+}
+
+var taggedID = regexp.MustCompile(`(?m)^\[([^\]]+)\]$`)
+
+// searchByTag calls search_by_tag and returns its result and the IDs it listed.
+func searchByTag(t *testing.T, ta *testApp, args map[string]any) (string, []string) {
+	t.Helper()
+	text, isErr := call(t, ta.searchByTagHandler, args)
+	if isErr {
+		t.Fatalf("search_by_tag %v: %s", args, text)
+	}
+	var ids []string
+	for _, m := range taggedID.FindAllStringSubmatch(text, -1) {
+		ids = append(ids, m[1])
+	}
+	return text, ids
+}
+
+// Tags containing the searched tag, such as "golang" for "go", do not match.
+func TestSearchByTagMatchesWholeTags(t *testing.T) {
+	ta := newTestApp(t, nil)
+	for id, tags := range map[string][]any{
+		"go-1":   {"go"},
+		"go-2":   {"web", "Go"},
+		"golang": {"golang"},
+		"cargo":  {"cargo", "rust"},
+		"gopher": {"go-tips"},
+	} {
+		ta.remember(t, id, "notes on "+id, map[string]any{"tags": tags})
+	}
+	ta.remember(t, "untagged", "go is a programming language", nil)
+	calls := ta.embedder.Count()
+
+	for _, tag := range []string{"go", " GO "} {
+		if _, ids := searchByTag(t, ta, map[string]any{"tag": tag}); !slices.Equal(ids, []string{"go-1", "go-2"}) {
+			t.Errorf("search_by_tag %q lists %v, want go-1, go-2", tag, ids)
+		}
+	}
+	if _, ids := searchByTag(t, ta, map[string]any{"tag": "golang"}); !slices.Equal(ids, []string{"golang"}) {
+		t.Errorf("search_by_tag golang lists %v", ids)
+	}
+	if n := ta.embedder.Count() - calls; n != 0 {
+		t.Errorf("search_by_tag made %d embedding calls, want 0", n)
+	}
+	if text, isErr := call(t, ta.searchByTagHandler, map[string]any{"tag": "g"}); !isErr || !strings.Contains(text, "Tag not found") {
+		t.Errorf("search_by_tag g = %q, want an unknown tag error", text)
+	}
+}
+
+func TestSearchByTagPages(t *testing.T) {
+	ta := newTestApp(t, nil)
+	for i := range 5 {
+		ta.remember(t, fmt.Sprintf("note-%d", i+1), fmt.Sprintf("weekly note %d", i+1), map[string]any{"tags": []any{"weekly"}})
+	}
+
+	text, ids := searchByTag(t, ta, map[string]any{"tag": "weekly", "limit": 2.0})
+	if !slices.Equal(ids, []string{"note-1", "note-2"}) || !strings.HasPrefix(text, "Memories tagged with 'weekly' (1-2 of 5):") || !strings.Contains(text, "call again with offset 2.") {
+		t.Errorf("first page:\n%s", text)
+	}
+	text, ids = searchByTag(t, ta, map[string]any{"tag": "weekly", "limit": 2.0, "offset": 4.0})
+	if !slices.Equal(ids, []string{"note-5"}) || strings.Contains(text, "call again") {
+		t.Errorf("last page:\n%s", text)
+	}
+	if text, _ = searchByTag(t, ta, map[string]any{"tag": "weekly", "offset": 5.0}); text != "5 memories are tagged with 'weekly'; offset 5 is past the last one." {
+		t.Errorf("page past the end = %q", text)
+	}
+	if _, ids = searchByTag(t, ta, map[string]any{"tag": "weekly"}); len(ids) != 5 {
+		t.Errorf("default page lists %d memories, want all 5", len(ids))
+	}
+}
+
+func TestParseTags(t *testing.T) {
+	for _, tc := range []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"  ", nil},
+		{`[]`, nil},
+		{`["go","a, b"]`, []string{"go", "a, b"}},
+		{`[" Go ","go","GO",""]`, []string{"Go"}},
+		{`["café","日本語"]`, []string{"café", "日本語"}},
+		// The comma-separated form of older versions
+		{"go,web", []string{"go", "web"}},
+		{" Go, go ,GO,web", []string{"Go", "web"}},
+		{" , ,", nil},
+		{"café,日本語", []string{"café", "日本語"}},
+		// Not JSON although it starts like it
+		{"[draft", []string{"[draft"}},
+	} {
+		if got := ParseTags(tc.value); !slices.Equal(got, tc.want) {
+			t.Errorf("ParseTags(%q) = %q, want %q", tc.value, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeTags(t *testing.T) {
+	for _, tc := range []struct {
+		tags []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{" ", ""}, ""},
+		{[]string{"go", "web"}, `["go","web"]`},
+		{[]string{"Go", "go ", "web", "GO"}, `["Go","web"]`},
+		{[]string{"a, b", `quote"d`}, `["a, b","quote\"d"]`},
+		{[]string{"Ünïcode", "ünïcode"}, `["Ünïcode"]`},
+	} {
+		got := EncodeTags(tc.tags)
+		if got != tc.want {
+			t.Errorf("EncodeTags(%q) = %s, want %s", tc.tags, got, tc.want)
+		}
+		// Encoding and parsing round-trip to the normalized tags
+		if parsed := ParseTags(got); !slices.Equal(parsed, normalizeTags(tc.tags)) {
+			t.Errorf("ParseTags(EncodeTags(%q)) = %q", tc.tags, parsed)
+		}
+	}
+
+	if got := mergeTags(`["go","a, b"]`, "Go,rust"); got != `["go","a, b","rust"]` {
+		t.Errorf("mergeTags = %s", got)
+	}
+	if got := mergeTags("", ""); got != "" {
+		t.Errorf("mergeTags of nothing = %q", got)
+	}
+	if !hasTag([]string{"a, b", "Café"}, "CAFÉ") || hasTag([]string{"a, b"}, "a") {
+		t.Error("hasTag must match whole tags ignoring case")
+	}
+}
+
+// The fixture in testdata/comma_tags_db is a local store written before tags
+// were JSON-encoded. Its memories carry comma-separated tags with repeats in
+// different case, blanks, unicode and a stray bracket; json already has the
+// new encoding and untagged no tags.
+func TestMigrateTagEncodingFixture(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	if err := os.CopyFS(dir, os.DirFS(filepath.Join("testdata", "comma_tags_db"))); err != nil {
+		t.Fatalf("copy fixture: %v", err)
+	}
+	open := func() *LocalVectorStore {
+		backend, err := NewLocalVectorStore(dir, nil, testEmbedding, nil, testDimension, nil)
+		if err != nil {
+			t.Fatalf("NewLocalVectorStore: %v", err)
+		}
+		return backend
+	}
+	backend := open()
+	before, _ := backend.ListDocuments(t.Context(), nil, 0, 0)
+
+	var logs strings.Builder
+	marker := filepath.Join(t.TempDir(), "tags_migrated")
+	if err := migrateTagEncoding(t.Context(), backend, marker, log.New(&logs, "", 0)); err != nil {
+		t.Fatalf("migrateTagEncoding: %v", err)
+	}
+	if !strings.Contains(logs.String(), "Migrated tags of 5 memories") {
+		t.Errorf("log = %q, want 5 memories migrated", logs.String())
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Errorf("marker not written: %v", err)
+	}
+
+	want := map[string]string{
+		"recipe":     `["baking","bread"]`,
+		"mixed-case": `["Go","web"]`,
+		"blank":      "",
+		"unicode":    `["café","日本語"]`,
+		"bracket":    `["[draft"]`,
+		"json":       `["a, b","json"]`,
+		"untagged":   "",
+	}
+	// The migration is persisted and leaves content and embeddings alone
+	after, _ := open().ListDocuments(t.Context(), nil, 0, 0)
+	if len(after) != len(want) || len(before) != len(want) {
+		t.Fatalf("store holds %d memories before and %d after, want %d", len(before), len(after), len(want))
+	}
+	for i, doc := range after {
+		value, ok := doc.Metadata["tags"]
+		if value != want[doc.ID] || (want[doc.ID] == "") == ok {
+			t.Errorf("%s tags = %q (present %v), want %q", doc.ID, value, ok, want[doc.ID])
+		}
+		if doc.ID != before[i].ID || doc.Content != before[i].Content || !slices.Equal(doc.Embedding, before[i].Embedding) || doc.Metadata["context"] != before[i].Metadata["context"] {
+			t.Errorf("%s changed beyond its tags", doc.ID)
+		}
+	}
+
+	// With the marker present the migration does not run again
+	backend = open()
+	if err := backend.UpdateMetadata(t.Context(), "recipe", map[string]string{"context": "kitchen", "tags": "baking,bread"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrateTagEncoding(t.Context(), backend, marker, log.New(&logs, "", 0)); err != nil {
+		t.Fatalf("second migrateTagEncoding: %v", err)
+	}
+	if doc, _ := backend.GetByID(t.Context(), "recipe"); doc.Metadata["tags"] != "baking,bread" {
+		t.Errorf("the migration ran again despite the marker: %q", doc.Metadata["tags"])
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.39.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// otelTracer creates the OpenTelemetry spans of tool calls and embedding
+// requests. Until setupTelemetry installs an exporter it is a no-op.
+func otelTracer() trace.Tracer {
+	return otel.Tracer("github.com/DatanoiseTV/brainmcp")
+}
+
+// setupTelemetry exports spans to the OTLP gRPC collector at endpoint. An
+// endpoint without a scheme ("localhost:4317") is reached without TLS; use
+// "https://host:4317" for TLS. With an empty endpoint tracing stays disabled
+// and the returned shutdown does nothing.
+func setupTelemetry(ctx context.Context, endpoint string) (shutdown func(context.Context) error, err error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpointURL(endpoint)}
+	if !strings.Contains(endpoint, "://") {
+		opts = []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(endpoint), otlptracegrpc.WithInsecure()}
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(ServerName),
+		semconv.ServiceVersion(ServerVersion),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// telemetryMiddleware wraps every tool call in a span named brainmcp.<tool>,
+// with the memory and context IDs from the arguments and the result status.
+func (a *App) telemetryMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ctx, span := otelTracer().Start(ctx, "brainmcp."+request.Params.Name)
+		defer span.End()
+
+		attrs := []attribute.KeyValue{attribute.String("brainmcp.request_id", RequestIDFrom(ctx))}
+		args, _ := request.Params.Arguments.(map[string]any)
+		for _, key := range []string{"id", "memory_id"} {
+			if id, ok := args[key].(string); ok && id != "" {
+				attrs = append(attrs, attribute.String("brainmcp.memory_id", id))
+				break
+			}
+		}
+		for _, key := range []string{"context_id", "context"} {
+			if id, ok := args[key].(string); ok && id != "" {
+				attrs = append(attrs, attribute.String("brainmcp.context_id", id))
+				break
+			}
+		}
+		span.SetAttributes(attrs...)
+
+		result, err := next(ctx, request)
+		switch {
+		case err != nil:
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			span.SetAttributes(attribute.String("brainmcp.status", "error"))
+		case result != nil && result.IsError:
+			span.SetStatus(codes.Error, resultText(result))
+			span.SetAttributes(attribute.String("brainmcp.status", "error"))
+		default:
+			span.SetAttributes(attribute.String("brainmcp.status", "ok"))
+		}
+		return result, err
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// naiveTimeLayouts are timestamp layouts without a UTC offset. Older versions
+// wrote server-local times in these layouts, and users type them in filters.
+var naiveTimeLayouts = []string{
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+// loadTimezone resolves the configured IANA timezone name. An empty name
+// selects the server's local zone.
+func loadTimezone(name string) (*time.Location, error) {
+	if name = strings.TrimSpace(name); name == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
+
+// parseStoredTime parses a timestamp from memory metadata and returns it in
+// UTC. Timestamps with an offset convert exactly. Timestamps without one were
+// written in the server's local time, so they are interpreted in time.Local
+// and reported as approximate because the original offset is unknown.
+func parseStoredTime(value string) (t time.Time, approximate bool, err error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), false, nil
+	}
+	for _, layout := range naiveTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t.UTC(), true, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid timestamp %q", value)
+}
+
+// parseUserTime parses a time given as a tool argument. RFC 3339 timestamps
+// are used as given; naked dates ("2025-03-30") and times without an offset
+// are interpreted in loc. dateOnly reports a naked date, which callers use to
+// cover the whole day.
+func parseUserTime(value string, loc *time.Location) (t time.Time, dateOnly bool, err error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), false, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, loc); err == nil {
+		return t.UTC(), true, nil
+	}
+	for _, layout := range naiveTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), false, nil
+		}
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q (use YYYY-MM-DD or RFC 3339)", value)
+}
+
+// endOfDay returns the start of the day after t's date in loc. The day is
+// counted in calendar terms, so DST transitions give 23- or 25-hour days.
+func endOfDay(t time.Time, loc *time.Location) time.Time {
+	return t.In(loc).AddDate(0, 0, 1).UTC()
+}
+
+// isTimestampKey reports whether a metadata key holds a timestamp.
+func isTimestampKey(key string) bool {
+	return strings.HasSuffix(key, "_at") || key == "last_accessed"
+}
+
+// formatTime renders t in the configured timezone.
+func (a *App) formatTime(t time.Time) string {
+	return t.In(a.location).Format("2006-01-02 15:04:05 MST")
+}
+
+// formatMetadataValue renders a metadata value for display, converting
+// timestamps to the configured timezone.
+func (a *App) formatMetadataValue(key, value string) string {
+	if !isTimestampKey(key) {
+		return value
+	}
+	t, approximate, err := parseStoredTime(value)
+	if err != nil {
+		return value
+	}
+	if approximate {
+		return a.formatTime(t) + " (approximate: stored without timezone)"
+	}
+	return a.formatTime(t)
+}
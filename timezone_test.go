@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mustLoadLocation loads an IANA zone or skips the test without tzdata.
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone %s unavailable: %v", name, err)
+	}
+	return loc
+}
+
+// setCreatedAt overwrites the stored created_at of a memory.
+func (ta *testApp) setCreatedAt(t *testing.T, id, value string) {
+	t.Helper()
+	doc, err := ta.vectorStore.GetByID(context.Background(), id)
+	if err != nil {
+		t.Fatalf("GetByID %s: %v", id, err)
+	}
+	doc.Metadata["created_at"] = value
+	if err := ta.vectorStore.UpdateMetadata(context.Background(), id, doc.Metadata); err != nil {
+		t.Fatalf("UpdateMetadata %s: %v", id, err)
+	}
+}
+
+func TestTimestampsStoredInUTC(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.location = mustLoadLocation(t, "Asia/Tokyo")
+	ta.remember(t, "note", "stored in UTC", nil)
+
+	doc, err := ta.vectorStore.GetByID(context.Background(), "note")
+	if err != nil {
+		t.Fatal(err)
+	}
+	value := doc.Metadata["created_at"]
+	created, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil || !strings.HasSuffix(value, "Z") {
+		t.Fatalf("created_at = %q, want an RFC 3339 UTC timestamp", value)
+	}
+	if age := time.Since(created); age < 0 || age > time.Minute {
+		t.Errorf("created_at %s is %v off", value, age)
+	}
+}
+
+func TestFormatTimeInConfiguredZone(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.location = mustLoadLocation(t, "America/New_York")
+
+	for _, tc := range []struct {
+		stored, want string
+	}{
+		{"2025-01-15T17:00:00Z", "2025-01-15 12:00:00 EST"},
+		{"2025-07-15T17:00:00Z", "2025-07-15 13:00:00 EDT"},
+		{"2025-07-15T19:00:00+02:00", "2025-07-15 13:00:00 EDT"},
+	} {
+		if got := ta.formatMetadataValue("created_at", tc.stored); got != tc.want {
+			t.Errorf("formatMetadataValue(%s) = %q, want %q", tc.stored, got, tc.want)
+		}
+	}
+	if got := ta.formatMetadataValue("created_at", "2025-07-15T13:00:00"); !strings.HasSuffix(got, "(approximate: stored without timezone)") {
+		t.Errorf("a naive timestamp is not flagged: %q", got)
+	}
+	if got := ta.formatMetadataValue("source", "2025-07-15T17:00:00Z"); got != "2025-07-15T17:00:00Z" {
+		t.Errorf("a non-timestamp key was converted: %q", got)
+	}
+
+	text, _ := call(t, ta.brainStatusHandler, nil)
+	if !strings.Contains(text, "Timezone: America/New_York") {
+		t.Errorf("brain_status does not show the timezone:\n%s", text)
+	}
+}
+
+// A naked date covers the calendar day in the configured zone, which is 23
+// hours long when DST starts and 25 hours when it ends.
+func TestDateFilterAcrossDST(t *testing.T) {
+	berlin := mustLoadLocation(t, "Europe/Berlin")
+
+	start, dateOnly, err := parseUserTime("2025-03-30", berlin)
+	if err != nil || !dateOnly {
+		t.Fatalf("parseUserTime = %v, %v, %v", start, dateOnly, err)
+	}
+	if want := time.Date(2025, 3, 29, 23, 0, 0, 0, time.UTC); !start.Equal(want) {
+		t.Errorf("start of 2025-03-30 in Berlin = %v, want %v", start, want)
+	}
+	if d := endOfDay(start, berlin).Sub(start); d != 23*time.Hour {
+		t.Errorf("2025-03-30 lasts %v in Berlin, want 23h", d)
+	}
+	autumn, _, _ := parseUserTime("2025-10-26", berlin)
+	if d := endOfDay(autumn, berlin).Sub(autumn); d != 25*time.Hour {
+		t.Errorf("2025-10-26 lasts %v in Berlin, want 25h", d)
+	}
+
+	ta := newTestApp(t, nil)
+	ta.location = berlin
+	for id, created := range map[string]string{
+		"saturday-night": "2025-03-29T22:30:00Z", // 23:30 CET on the 29th
+		"sunday-early":   "2025-03-29T23:30:00Z", // 00:30 CET on the 30th
+		"sunday-late":    "2025-03-30T21:30:00Z", // 23:30 CEST on the 30th
+		"monday-early":   "2025-03-30T22:30:00Z", // 00:30 CEST on the 31st
+	} {
+		ta.remember(t, id, "memory "+id, nil)
+		ta.setCreatedAt(t, id, created)
+	}
+
+	text, isErr := call(t, ta.listHandler, map[string]any{"created_after": "2025-03-30", "created_before": "2025-03-30"})
+	if isErr {
+		t.Fatalf("list_memories: %s", text)
+	}
+	for id, want := range map[string]bool{"saturday-night": false, "sunday-early": true, "sunday-late": true, "monday-early": false} {
+		if got := strings.Contains(text, id+":"); got != want {
+			t.Errorf("%s listed = %v, want %v:\n%s", id, got, want, text)
+		}
+	}
+
+	// The same filter in UTC selects a different set
+	ta.location = time.UTC
+	text, _ = call(t, ta.listHandler, map[string]any{"created_after": "2025-03-30", "created_before": "2025-03-30"})
+	if !strings.Contains(text, "sunday-late:") || !strings.Contains(text, "monday-early:") || strings.Contains(text, "sunday-early:") {
+		t.Errorf("UTC filter listed:\n%s", text)
+	}
+}
+
+func TestLoadTimezone(t *testing.T) {
+	if loc, err := loadTimezone(""); err != nil || loc != time.Local {
+		t.Errorf("loadTimezone(\"\") = %v, %v; want the local zone", loc, err)
+	}
+	if _, err := loadTimezone("Mars/Olympus_Mons"); err == nil {
+		t.Error("an unknown zone was accepted")
+	}
+}
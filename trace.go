@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// TraceEvent is one recorded step of a request.
+type TraceEvent struct {
+	Time      time.Time
+	RequestID string
+	Kind      string // "tool", "log", "provider", "audit" or "result"
+	Message   string
+}
+
+// TraceBuffer keeps the most recent trace events in a fixed-size ring.
+// A nil *TraceBuffer records nothing.
+type TraceBuffer struct {
+	mu     sync.Mutex
+	events []TraceEvent
+	next   int
+	full   bool
+}
+
+// NewTraceBuffer creates a ring buffer holding up to size events, or returns
+// nil (tracing disabled) when size is not positive.
+func NewTraceBuffer(size int) *TraceBuffer {
+	if size <= 0 {
+		return nil
+	}
+	return &TraceBuffer{events: make([]TraceEvent, size)}
+}
+
+// Add records an event, overwriting the oldest one when the buffer is full.
+func (tb *TraceBuffer) Add(event TraceEvent) {
+	if tb == nil {
+		return
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.events[tb.next] = event
+	tb.next = (tb.next + 1) % len(tb.events)
+	if tb.next == 0 {
+		tb.full = true
+	}
+}
+
+// Get returns the events recorded for requestID in chronological order.
+func (tb *TraceBuffer) Get(requestID string) []TraceEvent {
+	if tb == nil {
+		return nil
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	start, n := 0, tb.next
+	if tb.full {
+		start, n = tb.next, len(tb.events)
+	}
+	var out []TraceEvent
+	for i := 0; i < n; i++ {
+		event := tb.events[(start+i)%len(tb.events)]
+		if event.RequestID == requestID {
+			out = append(out, event)
+		}
+	}
+	return out
+}
+
+// Tracer writes request-scoped log lines and records them in the trace buffer.
+type Tracer struct {
+	logger *log.Logger
+	buffer *TraceBuffer
+}
+
+// requestScope is what a request's context carries for tracing.
+type requestScope struct {
+	id     string
+	tracer *Tracer
+}
+
+type requestScopeKey struct{}
+
+// newRequestID returns a short random identifier for a request.
+func newRequestID(prefix string) string {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+	}
+	return prefix + "-" + hex.EncodeToString(b)
+}
+
+// WithRequestID attaches a request ID and tracer to ctx.
+func WithRequestID(ctx context.Context, id string, tracer *Tracer) context.Context {
+	return context.WithValue(ctx, requestScopeKey{}, requestScope{id: id, tracer: tracer})
+}
+
+// RequestIDFrom returns the request ID carried by ctx, or "" outside a request.
+func RequestIDFrom(ctx context.Context) string {
+	scope, _ := ctx.Value(requestScopeKey{}).(requestScope)
+	return scope.id
+}
+
+// traceLog writes a log line tagged with the request ID of ctx and records it
+// in the trace buffer. Outside a request it does nothing.
+func traceLog(ctx context.Context, kind, format string, args ...any) {
+	scope, ok := ctx.Value(requestScopeKey{}).(requestScope)
+	if !ok || scope.tracer == nil {
+		return
+	}
+	scope.tracer.record(scope.id, kind, fmt.Sprintf(format, args...))
+}
+
+// record logs and buffers one event.
+func (t *Tracer) record(requestID, kind, message string) {
+	t.logger.Printf("[%s] %s", requestID, message)
+	t.buffer.Add(TraceEvent{Time: time.Now(), RequestID: requestID, Kind: kind, Message: message})
+}
+
+// logf logs a message tagged with the request ID of ctx. Outside a request it
+// falls back to the plain logger.
+func (a *App) logf(ctx context.Context, format string, args ...any) {
+	if RequestIDFrom(ctx) == "" {
+		a.logger.Printf(format, args...)
+		return
+	}
+	traceLog(ctx, "log", format, args...)
+}
+
+// requestIDMiddleware assigns a request ID to every tool call, traces the call
+// and its outcome, and returns the ID in the result's _meta and in error text
+// so users can quote it when reporting problems.
+func (a *App) requestIDMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id := newRequestID("req")
+		ctx = WithRequestID(ctx, id, a.tracer)
+		traceLog(ctx, "tool", "Tool call %s", request.Params.Name)
+
+		start := time.Now()
+		result, err := next(ctx, request)
+		elapsed := time.Since(start).Round(time.Millisecond)
+
+		switch {
+		case err != nil:
+			traceLog(ctx, "result", "Tool %s failed after %v: %v", request.Params.Name, elapsed, err)
+			return nil, fmt.Errorf("%w (request ID: %s)", err, id)
+		case result == nil:
+			traceLog(ctx, "result", "Tool %s returned no result after %v", request.Params.Name, elapsed)
+			return result, nil
+		case result.IsError:
+			traceLog(ctx, "result", "Tool %s returned an error after %v: %s", request.Params.Name, elapsed, resultText(result))
+			result.Content = append(result.Content, mcp.NewTextContent(fmt.Sprintf("Request ID: %s", id)))
+		default:
+			traceLog(ctx, "result", "Tool %s succeeded after %v", request.Params.Name, elapsed)
+		}
+
+		if result.Meta == nil {
+			result.Meta = &mcp.Meta{}
+		}
+		if result.Meta.AdditionalFields == nil {
+			result.Meta.AdditionalFields = map[string]any{}
+		}
+		result.Meta.AdditionalFields["request_id"] = id
+		return result, nil
+	}
+}
+
+// resultText joins the text content of a tool result.
+func resultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			parts = append(parts, text.Text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// getRequestTraceHandler handles the get_request_trace tool - returns everything
+// recorded for a request ID while it is still in the trace buffer.
+func (a *App) getRequestTraceHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	requestID, _ := args["request_id"].(string)
+
+	if requestID = strings.TrimSpace(requestID); requestID == "" {
+		return mcp.NewToolResultError("Request ID cannot be empty"), nil
+	}
+	if a.tracer == nil || a.tracer.buffer == nil {
+		return mcp.NewToolResultError("Request tracing is disabled (start with -trace-buffer > 0)"), nil
+	}
+
+	events := a.tracer.buffer.Get(requestID)
+	if len(events) == 0 {
+		return mcp.NewToolResultError(fmt.Sprintf("No trace recorded for request '%s' (it may have been evicted from the buffer)", requestID)), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Trace for %s (%d events):\n", requestID, len(events)))
+	for _, event := range events {
+		sb.WriteString(fmt.Sprintf("%s [%s] %s\n", event.Time.In(a.location).Format("15:04:05.000"), event.Kind, event.Message))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
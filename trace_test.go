@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent log writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// The request ID flows from the tool call through the failing embedder's
+// retries into the error returned to the client, the log and the trace.
+func TestRequestIDFollowsFailingEmbedderCall(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		http.Error(w, "model crashed", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	ta := newTestApp(t, nil)
+	var logs syncBuffer
+	ta.tracer = &Tracer{logger: log.New(&logs, "", 0), buffer: NewTraceBuffer(DefaultTraceBufferSize)}
+	embed := makeLMStudioEmbedder(srv.URL, "model", fastRetries, nil)
+	backend, err := NewLocalVectorStore(t.TempDir(), nil, embed, nil, testDimension, nil)
+	if err != nil {
+		t.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	ta.vectorStore = NewIndexedVectorStore(backend, ta.keywordIndex, ta.hashIndex)
+
+	var request mcp.CallToolRequest
+	request.Params.Name = "remember"
+	request.Params.Arguments = map[string]any{"id": "doomed", "content": "this will not embed"}
+	result, err := ta.requestIDMiddleware(ta.rememberHandler)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("remember returned error: %v", err)
+	}
+	if !result.IsError {
+		t.Fatalf("remember succeeded with a failing embedder: %s", resultText(result))
+	}
+
+	id, _ := result.Meta.AdditionalFields["request_id"].(string)
+	if !strings.HasPrefix(id, "req-") {
+		t.Fatalf("result _meta request_id = %q", id)
+	}
+	last := result.Content[len(result.Content)-1].(mcp.TextContent).Text
+	if last != "Request ID: "+id {
+		t.Errorf("error payload ends with %q, want the request ID", last)
+	}
+	if attempts.Load() != int32(fastRetries.MaxRetries+1) {
+		t.Errorf("embedder made %d attempts, want %d", attempts.Load(), fastRetries.MaxRetries+1)
+	}
+
+	// Every provider attempt is logged and traced under the ID
+	if n := strings.Count(logs.String(), "["+id+"] Provider call failed"); n != fastRetries.MaxRetries+1 {
+		t.Errorf("log has %d provider lines for %s, want %d:\n%s", n, id, fastRetries.MaxRetries+1, logs.String())
+	}
+	trace, isErr := call(t, ta.getRequestTraceHandler, map[string]any{"request_id": id})
+	if isErr {
+		t.Fatalf("get_request_trace: %s", trace)
+	}
+	for _, want := range []string{"[tool] Tool call remember", "[provider] Provider call failed", "500", "[result] Tool remember returned an error"} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("trace lacks %q:\n%s", want, trace)
+		}
+	}
+
+	// A second call gets its own ID and its own trace
+	result, _ = ta.requestIDMiddleware(ta.rememberHandler)(context.Background(), request)
+	if other := result.Meta.AdditionalFields["request_id"]; other == id {
+		t.Error("two calls share a request ID")
+	}
+	if again, _ := call(t, ta.getRequestTraceHandler, map[string]any{"request_id": id}); again != trace {
+		t.Errorf("the trace of the first call changed:\n%s", again)
+	}
+}
+
+func TestRequestIDInAuditEntries(t *testing.T) {
+	ta := newTestApp(t, nil)
+	var request mcp.CallToolRequest
+	request.Params.Name = "audited"
+	handler := ta.requestIDMiddleware(func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		ta.recordAudit(ctx, AuditEntry{Tool: "test", Status: "ok"})
+		return mcp.NewToolResultText("done"), nil
+	})
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := result.Meta.AdditionalFields["request_id"].(string)
+	events := ta.tracer.buffer.Get(id)
+	var kinds []string
+	for _, event := range events {
+		kinds = append(kinds, event.Kind)
+	}
+	if got := strings.Join(kinds, ","); got != "tool,audit,result" {
+		t.Errorf("trace kinds = %s, want tool,audit,result", got)
+	}
+}
+
+func TestTraceBufferRing(t *testing.T) {
+	tb := NewTraceBuffer(3)
+	for i := range 5 {
+		tb.Add(TraceEvent{RequestID: "r", Message: fmt.Sprint(i)})
+	}
+	tb.Add(TraceEvent{RequestID: "other"})
+	var got []string
+	for _, event := range tb.Get("r") {
+		got = append(got, event.Message)
+	}
+	if strings.Join(got, ",") != "3,4" {
+		t.Errorf("events = %v, want the newest two in order", got)
+	}
+
+	if NewTraceBuffer(0) != nil {
+		t.Error("a zero-size trace buffer is not disabled")
+	}
+	ta := newTestApp(t, nil)
+	ta.tracer.buffer = nil
+	if text, isErr := call(t, ta.getRequestTraceHandler, map[string]any{"request_id": "req-1"}); !isErr || !strings.Contains(text, "disabled") {
+		t.Errorf("get_request_trace without a buffer = %q", text)
+	}
+}
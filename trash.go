@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// The trash is not a separate _trash collection: a VectorBackend holds a
+// single collection, and Qdrant, pgvector and Redis would each need a second
+// one to be created, migrated and kept in step. A trashed memory stays in the
+// collection instead, marked by its deleted_at metadata key, and every read
+// path leaves such memories out (see isSoftDeleted). Moving in and out of the
+// trash is then one metadata write on every backend.
+
+// moveToTrash soft-deletes doc by rewriting it with a deleted_at timestamp.
+// Trashed memories stay in the vector store but are hidden from search and
+// listing; the embedding is kept so restoring does not re-embed the content.
+func (a *App) moveToTrash(ctx context.Context, doc chromem.Document, now time.Time) error {
+	metadata := make(map[string]string, len(doc.Metadata)+1)
+	for k, v := range doc.Metadata {
+		metadata[k] = v
+	}
+	metadata["deleted_at"] = now.UTC().Format(time.RFC3339)
+	doc.Metadata = metadata
+
+	return a.vectorStore.AddDocuments(ctx, []chromem.Document{doc}, 1)
+}
+
+// restoreMemoryHandler handles the restore_memory tool - moves a memory back
+// out of the trash.
+func (a *App) restoreMemoryHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+	id, _ := args["id"].(string)
+
+	if id = strings.TrimSpace(id); id == "" {
+		return mcp.NewToolResultError("Memory ID cannot be empty"), nil
+	}
+
+	doc, err := a.vectorStore.GetByID(ctx, id)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' not found: %v", id, err)), nil
+	}
+	if msg := a.memoryAccessDenied(ctx, doc); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+	if !isSoftDeleted(doc.Metadata) {
+		return mcp.NewToolResultError(fmt.Sprintf("Memory '%s' is not in the trash", id)), nil
+	}
+
+	contextID := doc.Metadata["context"]
+	if contextID == "" {
+		contextID = DefaultContextID
+	}
+	if msg := a.contextCapExceeded(ctx, contextID, id); msg != "" {
+		return mcp.NewToolResultError(msg), nil
+	}
+
+	metadata := make(map[string]string, len(doc.Metadata))
+	for k, v := range doc.Metadata {
+		if k != "deleted_at" {
+			metadata[k] = v
+		}
+	}
+	doc.Metadata = metadata
+	entry := AuditEntry{Tool: "restore_memory", MemoryIDs: []string{id}, ClientID: a.clientIDFrom(ctx), ContextID: contextID, Status: "ok",
+		Details: "restored from the trash"}
+	if err := a.vectorStore.AddDocuments(ctx, []chromem.Document{doc}, 1); err != nil {
+		entry.Status, entry.Details = "error", err.Error()
+		a.recordAudit(ctx, entry)
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to restore memory: %v", err)), nil
+	}
+	if isChunkedParent(metadata) {
+		if err := a.restoreChunks(ctx, id); err != nil {
+			a.logf(ctx, "Warning: Failed to restore chunks of '%s': %v", id, err)
+		}
+	}
+
+	if err := a.ctx.IncrementMemoryCount(contextID); err != nil {
+		a.logf(ctx, "Warning: Failed to update context count: %v", err)
+	}
+	a.activity.Record(contextID, ActivityCounts{Created: 1})
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+	a.recordAudit(ctx, entry)
+
+	return mcp.NewToolResultText(fmt.Sprintf("Memory '%s' restored to context '%s'.", id, contextID)), nil
+}
+
+// listDeletedMemoriesHandler handles the list_deleted_memories tool - lists the
+// trash, most recently deleted first.
+func (a *App) listDeletedMemoriesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, _ := request.Params.Arguments.(map[string]any)
+
+	var where map[string]string
+	contextID, _ := args["context_id"].(string)
+	if contextID = strings.TrimSpace(contextID); contextID != "" {
+		where = map[string]string{"context": contextID}
+	}
+
+	docs, err := a.vectorStore.ListDocuments(ctx, where, 0, 0)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list memories: %v", err)), nil
+	}
+	// Chunks are listed through their parent
+	var trash []chromem.Document
+	for _, doc := range trashedDocuments(a.accessibleDocuments(ctx, docs)) {
+		if !isChunk(doc.Metadata) {
+			trash = append(trash, doc)
+		}
+	}
+	if len(trash) == 0 {
+		return mcp.NewToolResultText("Trash is empty."), nil
+	}
+	sort.SliceStable(trash, func(i, j int) bool {
+		return trash[i].Metadata["deleted_at"] > trash[j].Metadata["deleted_at"]
+	})
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Trash contains %d memories", len(trash)))
+	if a.purgeTrashAfter > 0 {
+		sb.WriteString(fmt.Sprintf(" (purged %s after deletion)", a.purgeTrashAfter))
+	}
+	sb.WriteString(":\n")
+	for _, doc := range trash {
+		deleted := doc.Metadata["deleted_at"]
+		if t, _, err := parseStoredTime(deleted); err == nil {
+			deleted = a.formatTime(t)
+		}
+		snippet := doc.Content
+		if len(snippet) > MaxSnippetLength {
+			snippet = snippet[:MaxSnippetLength-3] + "..."
+		}
+		sb.WriteString(fmt.Sprintf("- %s (deleted %s, context: %s): %s\n", doc.ID, deleted, doc.Metadata["context"], snippet))
+	}
+
+	return mcp.NewToolResultText(sb.String()), nil
+}
+
+// trashedDocuments returns the soft-deleted documents of docs.
+func trashedDocuments(docs []chromem.Document) []chromem.Document {
+	var trash []chromem.Document
+	for _, doc := range docs {
+		if isSoftDeleted(doc.Metadata) {
+			trash = append(trash, doc)
+		}
+	}
+	return trash
+}
+
+// purgeTrash permanently deletes memories that have been in the trash longer
+// than the configured purge delay, together with their version history, and
+// returns their IDs. It does nothing if no delay is configured.
+func (a *App) purgeTrash(ctx context.Context, now time.Time) ([]string, error) {
+	if a.purgeTrashAfter <= 0 {
+		return nil, nil
+	}
+
+	docs, err := a.vectorStore.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	cutoff := now.Add(-a.purgeTrashAfter)
+	var expired []string
+	for _, doc := range trashedDocuments(docs) {
+		if deletedAt, _, err := parseStoredTime(doc.Metadata["deleted_at"]); err == nil && deletedAt.Before(cutoff) {
+			expired = append(expired, doc.ID)
+		}
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	if err := a.vectorStore.Delete(ctx, nil, nil, expired...); err != nil {
+		return nil, fmt.Errorf("failed to purge trash: %w", err)
+	}
+	for _, id := range expired {
+		if _, err := a.versionMgr.GetHistory(id); err == nil {
+			if err := a.versionMgr.DeleteMemoryHistory(id); err != nil {
+				a.logf(ctx, "Warning: Failed to delete version history of '%s': %v", id, err)
+			}
+		}
+	}
+	return expired, nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/philippgille/chromem-go"
+)
+
+// parseExpiry reads the expiry of a memory from remember's ttl ("24h", "7d")
+// or expires_at (RFC 3339) argument. It returns the zero time if neither is
+// given.
+func parseExpiry(ttl, expiresAt string, now time.Time) (time.Time, error) {
+	ttl, expiresAt = strings.TrimSpace(ttl), strings.TrimSpace(expiresAt)
+	switch {
+	case ttl != "" && expiresAt != "":
+		return time.Time{}, fmt.Errorf("give either ttl or expires_at, not both")
+	case ttl != "":
+		d, err := parseRetentionAge(ttl)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid ttl %q (use e.g. 24h, 7d or 2w)", ttl)
+		}
+		return now.Add(d), nil
+	case expiresAt != "":
+		t, err := time.Parse(time.RFC3339, expiresAt)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid expires_at %q: use RFC 3339, e.g. 2026-01-02T15:04:05Z", expiresAt)
+		}
+		if !t.After(now) {
+			return time.Time{}, fmt.Errorf("expires_at %s is in the past", expiresAt)
+		}
+		return t, nil
+	}
+	return time.Time{}, nil
+}
+
+// clock returns the current time of the expiry checks.
+func (a *App) clock() time.Time {
+	if a.now != nil {
+		return a.now()
+	}
+	return time.Now()
+}
+
+// isExpired reports whether a memory's expires_at lies at or before now.
+// Memories without a valid expires_at never expire.
+func isExpired(metadata map[string]string, now time.Time) bool {
+	expiresAt, _, err := parseStoredTime(metadata["expires_at"])
+	return err == nil && !now.Before(expiresAt)
+}
+
+// startExpiry runs the expiry sweep once and then every interval until ctx is
+// cancelled.
+func (a *App) startExpiry(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			sweepCtx := WithRequestID(ctx, newRequestID("expire"), a.tracer)
+			if _, err := a.expireMemories(sweepCtx, a.clock()); err != nil {
+				a.logf(sweepCtx, "Warning: Expiry sweep failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// expireMemories permanently deletes the memories whose expires_at has passed
+// at now, together with their version history, and returns them. Expired
+// memories in the trash are deleted as well. Every sweep that removes
+// something is logged and written to the audit log.
+func (a *App) expireMemories(ctx context.Context, now time.Time) ([]chromem.Document, error) {
+	docs, err := a.vectorStore.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list memories: %w", err)
+	}
+
+	var expired []chromem.Document
+	var ids []string
+	for _, doc := range docs {
+		if isExpired(doc.Metadata, now) {
+			expired = append(expired, doc)
+			ids = append(ids, doc.ID)
+		}
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	if err := a.vectorStore.Delete(ctx, nil, nil, ids...); err != nil {
+		a.recordAudit(ctx, AuditEntry{Tool: "expire_memories", MemoryIDs: ids, Status: "error", Details: err.Error()})
+		return nil, fmt.Errorf("failed to delete expired memories: %w", err)
+	}
+	for _, doc := range expired {
+		if _, err := a.versionMgr.GetHistory(doc.ID); err == nil {
+			if err := a.versionMgr.DeleteMemoryHistory(doc.ID); err != nil {
+				a.logf(ctx, "Warning: Failed to delete version history of '%s': %v", doc.ID, err)
+			}
+		}
+		if !isSoftDeleted(doc.Metadata) {
+			a.uncountDeleted(ctx, doc)
+		}
+	}
+	if err := a.ctx.Save(); err != nil {
+		a.logf(ctx, "Warning: Failed to save context state: %v", err)
+	}
+
+	a.logf(ctx, "Expired %d memories", len(expired))
+	a.recordAudit(ctx, AuditEntry{Tool: "expire_memories", MemoryIDs: ids, Status: "ok", Details: "ttl passed"})
+	return expired, nil
+}
+
+// expireMemoriesHandler handles the expire_memories tool - runs the expiry
+// sweep now and lists what it removed.
+func (a *App) expireMemoriesHandler(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	expired, err := a.expireMemories(ctx, a.clock())
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if len(expired) == 0 {
+		return mcp.NewToolResultText("No expired memories."), nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Removed %d expired memories:\n", len(expired)))
+	for _, doc := range expired {
+		expiresAt, _, _ := parseStoredTime(doc.Metadata["expires_at"])
+		sb.WriteString(fmt.Sprintf("- %s (expired %s, context: %s)\n", doc.ID, a.formatTime(expiresAt), doc.Metadata["context"]))
+	}
+	return mcp.NewToolResultText(sb.String()), nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a clock that only moves when the test advances it.
+type testClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// useClock makes the expiry checks of ta read a testClock starting at start.
+func (ta *testApp) useClock(start time.Time) *testClock {
+	clock := &testClock{now: start}
+	ta.now = clock.Now
+	ta.filterEngine.now = clock.Now
+	return clock
+}
+
+var ttlEpoch = time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+
+func TestParseExpiry(t *testing.T) {
+	for _, tc := range []struct {
+		ttl, expiresAt string
+		want           time.Time
+		wantErr        string
+	}{
+		{"", "", time.Time{}, ""},
+		{"24h", "", ttlEpoch.Add(24 * time.Hour), ""},
+		{" 7d ", "", ttlEpoch.Add(7 * 24 * time.Hour), ""},
+		{"", "2026-01-01T12:00:00+01:00", ttlEpoch.Add(time.Hour), ""},
+		{"24h", "2026-02-01T00:00:00Z", time.Time{}, "either ttl or expires_at"},
+		{"soon", "", time.Time{}, "invalid ttl"},
+		{"", "tomorrow", time.Time{}, "invalid expires_at"},
+		{"", "2026-01-01T10:00:00Z", time.Time{}, "in the past"},
+	} {
+		got, err := parseExpiry(tc.ttl, tc.expiresAt, ttlEpoch)
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("parseExpiry(%q, %q) error = %v, want %q", tc.ttl, tc.expiresAt, err, tc.wantErr)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tc.want) {
+			t.Errorf("parseExpiry(%q, %q) = %v, %v; want %v", tc.ttl, tc.expiresAt, got, err, tc.want)
+		}
+	}
+}
+
+// newTTLApp returns a testApp on a test clock at ttlEpoch holding "password"
+// expiring after a day, "weekly" expiring after a week and "standing" without
+// expiry.
+func newTTLApp(t *testing.T, configure func(cfg *Config)) (*testApp, *testClock) {
+	t.Helper()
+	ta := newTestApp(t, configure)
+	clock := ta.useClock(ttlEpoch)
+	ta.remember(t, "password", "build server password for today is hunter2", map[string]any{"ttl": "24h"})
+	ta.remember(t, "weekly", "build server rota for this week", map[string]any{"expires_at": "2026-01-08T10:00:00Z"})
+	ta.remember(t, "standing", "build server lives in rack four", nil)
+	return ta, clock
+}
+
+func TestExpiredMemoriesHiddenBeforeTheSweep(t *testing.T) {
+	ta, clock := newTTLApp(t, nil)
+	if doc, _ := ta.vectorStore.GetByID(t.Context(), "password"); doc.Metadata["expires_at"] != "2026-01-02T10:00:00Z" {
+		t.Fatalf("expires_at = %q, want a day after the test clock", doc.Metadata["expires_at"])
+	}
+
+	searches := map[string]func() string{
+		"search_memory": func() string {
+			text, _ := call(t, ta.searchHandler, map[string]any{"query": "build server password"})
+			return text
+		},
+		"keyword search": func() string {
+			text, _ := call(t, ta.searchHandler, map[string]any{"query": "password today", "mode": SearchModeKeyword})
+			return text
+		},
+		"search_advanced": func() string {
+			text, _ := call(t, ta.searchAdvancedHandler, map[string]any{"query": "build server password"})
+			return text
+		},
+		"list_memories": func() string {
+			text, _ := call(t, ta.listHandler, nil)
+			return text
+		},
+	}
+	visible := func(wantPassword bool) {
+		t.Helper()
+		for name, search := range searches {
+			text := search()
+			if strings.Contains(text, "hunter2") != wantPassword {
+				t.Errorf("%s shows the password = %v, want %v:\n%s", name, !wantPassword, wantPassword, text)
+			}
+		}
+	}
+
+	clock.Advance(24*time.Hour - time.Second)
+	visible(true)
+
+	// At its expiry the memory disappears from every search, though the sweep has not run
+	clock.Advance(time.Second)
+	visible(false)
+	if _, err := ta.vectorStore.GetByID(t.Context(), "password"); err != nil {
+		t.Fatalf("the memory was deleted without a sweep: %v", err)
+	}
+
+	llm := newFakeLLM("It is in rack four.")
+	ta.llm = llm
+	if text, isErr := call(t, ta.askBrainHandler, map[string]any{"question": "What is the build server password?"}); isErr {
+		t.Fatalf("ask_brain: %s", text)
+	}
+	prompt := llm.Prompts()[0]
+	if strings.Contains(prompt, "hunter2") || !strings.Contains(prompt, "rack four") {
+		t.Errorf("ask_brain prompt:\n%s", prompt)
+	}
+}
+
+func TestExpireMemoriesSweep(t *testing.T) {
+	ta, clock := newTTLApp(t, nil)
+
+	if text, _ := call(t, ta.expireMemoriesHandler, nil); text != "No expired memories." {
+		t.Errorf("expire_memories before any expiry = %q", text)
+	}
+
+	clock.Advance(25 * time.Hour)
+	text, isErr := call(t, ta.expireMemoriesHandler, nil)
+	want := "Removed 1 expired memories:\n- password (expired 2026-01-02 10:00:00 UTC, context: " + DefaultContextID + ")\n"
+	if isErr || text != want {
+		t.Errorf("expire_memories = %q, want %q", text, want)
+	}
+	if _, err := ta.vectorStore.GetByID(t.Context(), "password"); err == nil {
+		t.Error("the expired memory is still stored")
+	}
+	if _, err := ta.versionMgr.GetHistory("password"); err == nil {
+		t.Error("the expired memory kept its history")
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID); got[DefaultContextID] != 2 {
+		t.Errorf("context count = %d, want 2", got[DefaultContextID])
+	}
+
+	clock.Advance(7 * 24 * time.Hour)
+	if text, _ := call(t, ta.expireMemoriesHandler, nil); !strings.Contains(text, "- weekly (") {
+		t.Errorf("expire_memories a week later = %q", text)
+	}
+	if _, err := ta.vectorStore.GetByID(t.Context(), "standing"); err != nil {
+		t.Errorf("a memory without ttl was removed: %v", err)
+	}
+}
+
+// A trashed memory that expires is deleted without being uncounted twice.
+func TestExpireTrashedMemory(t *testing.T) {
+	ta, clock := newTTLApp(t, func(cfg *Config) { cfg.SoftDelete = true })
+	if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "password"}); isErr {
+		t.Fatalf("delete_memory: %s", text)
+	}
+	clock.Advance(25 * time.Hour)
+	if expired, err := ta.expireMemories(t.Context(), clock.Now()); err != nil || len(expired) != 1 {
+		t.Fatalf("expireMemories = %d, %v", len(expired), err)
+	}
+	if got := memoryCounts(t, ta.ctx, DefaultContextID); got[DefaultContextID] != 2 {
+		t.Errorf("context count = %d, want 2", got[DefaultContextID])
+	}
+}
+
+func TestExpirySweepRunsOnStart(t *testing.T) {
+	ta, clock := newTTLApp(t, nil)
+	clock.Advance(25 * time.Hour)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	ta.startExpiry(ctx, time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := ta.vectorStore.GetByID(t.Context(), "password"); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the expiry sweep did not run on start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := ta.vectorStore.GetByID(t.Context(), "weekly"); err != nil {
+		t.Errorf("the sweep removed a memory that has not expired: %v", err)
+	}
+}
@@ -14,6 +14,17 @@ type Context struct {
 	UpdatedAt   time.Time `json:"updated_at"`  // Last update time
 	MemoryCount int       `json:"memory_count"` // Number of memories in this context
 	Tags        []string  `json:"tags"`        // Tags associated with this context
+	Retention   *RetentionPolicy `json:"retention,omitempty"` // Optional retention policy, nil keeps memories forever
+	SharedWith  []string  `json:"shared_with,omitempty"` // Client IDs the context is shared with, the reverse of ClientSession.SharedWith
+	OwnerClientID string  `json:"owner_client_id,omitempty"` // Client that created the context; "" leaves it open to every client
+}
+
+// RetentionPolicy limits how long and how many memories a context keeps.
+// It is enforced by the periodic maintenance sweep.
+type RetentionPolicy struct {
+	MaxAge      string `json:"max_age,omitempty"`      // Maximum age since creation or last access, e.g. "7d" or "12h"
+	MaxMemories int    `json:"max_memories,omitempty"` // Maximum number of memories, oldest are evicted first
+	Action      string `json:"action"`                 // Eviction action: delete, archive or soft-delete
 }
 
 // Tag represents a label for categorizing memories.
@@ -22,6 +33,8 @@ type Tag struct {
 	Description string `json:"description"` // Optional description
 	Color       string `json:"color"`       // Optional hex color for UI
 	MemoryCount int    `json:"memory_count"` // Memories tagged with this
+	Parent      string   `json:"parent,omitempty"`   // Parent tag in the hierarchy, empty for top-level tags
+	Children    []string `json:"children,omitempty"` // Direct child tags, sorted
 }
 
 // ClientSession represents a client connected to the server.
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateContextPersists(t *testing.T) {
+	for name, cipher := range map[string]*FileCipher{"plain": nil, "encrypted": NewFileCipher("update context")} {
+		t.Run(name, func(t *testing.T) {
+			cm := NewContextManager(filepath.Join(t.TempDir(), ContextsDataPath), cipher)
+			if err := cm.CreateContext("proj", "Project", "the old description", ""); err != nil {
+				t.Fatal(err)
+			}
+			c, _ := cm.GetContext("proj")
+			created := c.CreatedAt
+			c.UpdatedAt = created.Add(-time.Hour)
+
+			if err := cm.UpdateContext("proj", "  Project 2026 ", "launch plans"); err != nil {
+				t.Fatalf("UpdateContext: %v", err)
+			}
+			for _, tc := range []struct{ id, name string }{{"proj", " "}, {"missing", "Missing"}} {
+				if err := cm.UpdateContext(tc.id, tc.name, "ignored"); err == nil {
+					t.Errorf("UpdateContext(%q, %q) succeeded", tc.id, tc.name)
+				}
+			}
+
+			reloaded := NewContextManager(cm.dataPath, cipher)
+			got, err := reloaded.GetContext("proj")
+			if err != nil {
+				t.Fatalf("the context did not keep its ID: %v", err)
+			}
+			if got.Name != "Project 2026" || got.Description != "launch plans" {
+				t.Errorf("reloaded context is %q, %q", got.Name, got.Description)
+			}
+			if !got.CreatedAt.Equal(created) || !got.UpdatedAt.After(created.Add(-time.Hour)) {
+				t.Errorf("reloaded CreatedAt %v, UpdatedAt %v", got.CreatedAt, got.UpdatedAt)
+			}
+			if _, err := reloaded.GetContext("missing"); err == nil {
+				t.Error("a failed update created a context")
+			}
+		})
+	}
+}
+
+func TestUpdateContextHandler(t *testing.T) {
+	ta := newTestApp(t, nil)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "proj", "name": "Project", "description": "the old description"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	ta.switchContext(t, "proj")
+	ta.remember(t, "plan", "ship the beta in June", nil)
+
+	for _, tc := range []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"context_id": "proj", "description": "launch plans"}, "Context 'proj' description updated."},
+		{map[string]any{"context_id": "proj", "name": "Launch"}, "Context 'proj' renamed from 'Project' to 'Launch'."},
+		{map[string]any{"context_id": "proj", "name": "Launch", "description": "launch plans"}, "Context 'proj' unchanged."},
+	} {
+		if text, isErr := call(t, ta.updateContextHandler, tc.args); isErr || text != tc.want {
+			t.Errorf("update_context %v = %q, want %q", tc.args, text, tc.want)
+		}
+	}
+	for _, tc := range []struct {
+		args map[string]any
+		want string
+	}{
+		{map[string]any{"context_id": "proj"}, "Provide a new name, a new description or both"},
+		{map[string]any{"context_id": "proj", "name": "  "}, "Context name cannot be empty"},
+		{map[string]any{"context_id": "missing", "name": "Missing"}, "Context not found"},
+		{map[string]any{"name": "Nameless"}, "Context ID cannot be empty"},
+	} {
+		if text, isErr := call(t, ta.updateContextHandler, tc.args); !isErr || !strings.Contains(text, tc.want) {
+			t.Errorf("update_context %v = %q, want %q", tc.args, text, tc.want)
+		}
+	}
+
+	if got := ta.contextOf(t, "plan"); got != "proj" {
+		t.Errorf("the memory is in %q after the rename, want proj", got)
+	}
+	if text, _ := call(t, ta.listContextsHandler, nil); !strings.Contains(text, "- [proj] Launch\n  Description: launch plans\n") {
+		t.Errorf("list_contexts does not show the update:\n%s", text)
+	}
+	reloaded := NewContextManager(ta.ctx.dataPath, nil)
+	if c, err := reloaded.GetContext("proj"); err != nil || c.Name != "Launch" || c.Description != "launch plans" || c.MemoryCount != 1 {
+		t.Errorf("reloaded context = %+v, %v", c, err)
+	}
+}
+
+func TestCLIContextRename(t *testing.T) {
+	ta := newTestApp(t, nil)
+	if text, isErr := call(t, ta.createContextHandler, map[string]any{"id": "proj", "name": "Project", "description": "launch plans"}); isErr {
+		t.Fatalf("create_context: %s", text)
+	}
+	out := ta.runScript(t, "context rename proj Launch Team\ncontext rename missing Gone\n")
+	if !strings.Contains(out, "Context 'proj' renamed from 'Project' to 'Launch Team'.") || !strings.Contains(out, "Context not found") {
+		t.Errorf("CLI output:\n%s", out)
+	}
+	// Renaming leaves the description alone
+	reloaded := NewContextManager(ta.ctx.dataPath, nil)
+	if c, _ := reloaded.GetContext("proj"); c == nil || c.Name != "Launch Team" || c.Description != "launch plans" {
+		t.Errorf("reloaded context = %+v", c)
+	}
+}
@@ -7,8 +7,15 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/DatanoiseTV/brainmcp/internal/vecmath"
+	"github.com/jackc/pgx/v5"
 	"github.com/philippgille/chromem-go"
 	"github.com/qdrant/go-client/qdrant"
 )
@@ -33,6 +40,10 @@ type VectorBackend interface {
 	// GetByID retrieves a document by ID.
 	GetByID(ctx context.Context, id string) (chromem.Document, error)
 
+	// UpdateMetadata replaces the metadata of a stored document, keeping its
+	// content and embedding, so nothing is embedded again.
+	UpdateMetadata(ctx context.Context, id string, metadata map[string]string) error
+
 	// Delete removes documents by IDs.
 	Delete(ctx context.Context, where, whereDocument map[string]string, ids ...string) error
 
@@ -50,53 +61,164 @@ type VectorBackend interface {
 
 	// BatchEmbed generates embeddings for multiple texts at once.
 	BatchEmbed(ctx context.Context, texts []string) ([][]float32, error)
+
+	// ListDocuments enumerates stored documents with their embeddings matching
+	// the metadata filter, ordered by ID. A limit <= 0 returns all documents.
+	// The migrate subcommand copies these embeddings instead of embedding again.
+	ListDocuments(ctx context.Context, where map[string]string, offset, limit int) ([]chromem.Document, error)
+
+	// ListByTag returns the documents whose comma-separated tags include tag
+	// as a whole entry, ignoring case, ordered by ID. Embeddings may be omitted.
+	ListByTag(ctx context.Context, tag string) ([]chromem.Document, error)
+
+	// MutationStamp returns a counter that changes on every mutation and survives
+	// restarts, or 0 if the backend cannot track mutations (e.g. shared remote stores).
+	MutationStamp() uint64
 }
 
 // LocalVectorStore wraps chromem-go as our local backend.
 type LocalVectorStore struct {
 	collection *chromem.Collection
 	db         *chromem.DB
+	dbPath     string
+	cipher     *FileCipher // Keeps the database encrypted in one file when set
+	saveTimer  *time.Timer // Pending export of the encrypted database
 	embFunc    chromem.EmbeddingFunc
 	batchEmbf  BatchEmbeddingFunc
 	logger     *log.Logger
 	mu         sync.RWMutex
+	stamp      uint64            // Persisted mutation counter
+	dim        int               // Embedding dimension used for enumeration probes
+	metadata   map[string]string // Collection metadata, recorded on creation
 }
 
-// NewLocalVectorStore creates a new local vector store using chromem-go.
-func NewLocalVectorStore(dbPath string, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, logger *log.Logger) (*LocalVectorStore, error) {
+// NewLocalVectorStore creates a new local vector store using chromem-go. A
+// dim above 0 is recorded as the collection's embedding_dimension; opening a
+// collection holding memories of another dimension fails, since they could
+// not be compared with new embeddings. With a cipher the database is kept in
+// memory and written encrypted to one file next to dbPath once
+// EncryptedExportDelay has passed without a mutation, and on SaveToDisk and
+// Close, since chromem's per-document files are not encrypted.
+func NewLocalVectorStore(dbPath string, cipher *FileCipher, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, dim int, logger *log.Logger) (*LocalVectorStore, error) {
 	if logger == nil {
 		logger = log.New(io.Discard, "", 0)
 	}
 
-	// Load or create persistent database
-	db, err := chromem.NewPersistentDB(dbPath, true)
+	// Load or create the database
+	db, err := openLocalDB(dbPath, cipher)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create chromem database: %w", err)
+		return nil, err
+	}
+
+	var metadata map[string]string
+	if dim > 0 {
+		metadata = map[string]string{"embedding_dimension": strconv.Itoa(dim)}
 	}
 
 	// Create or get collection
-	collection, err := db.GetOrCreateCollection("memories", nil, embFunc)
+	collection, err := db.GetOrCreateCollection("memories", metadata, embFunc)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create collection: %w", err)
 	}
+	checkDim := dim > 0 && collection.Count() > 0
+	if dim <= 0 {
+		dim = EmbeddingDimension
+	}
 
 	lvs := &LocalVectorStore{
 		collection: collection,
 		db:         db,
+		dbPath:     dbPath,
+		cipher:     cipher,
 		embFunc:    embFunc,
 		batchEmbf:  batchEmbf,
 		logger:     logger,
+		dim:        dim,
+		metadata:   metadata,
+	}
+
+	if data, err := os.ReadFile(lvs.stampPath()); err == nil {
+		fmt.Sscanf(string(data), "%d", &lvs.stamp)
+	}
+
+	// chromem only compares vectors of equal length, so a probe of the
+	// configured size fails if the stored memories have another one
+	if checkDim {
+		if _, err := lvs.queryAll(context.Background(), dim, 1, nil); err != nil && strings.Contains(err.Error(), "same length") {
+			return nil, fmt.Errorf("the local store holds embeddings of another dimension than the configured %d (gemini.embedding_dimension); restore the previous dimension or re-embed the memories", dim)
+		}
 	}
 
 	logger.Printf("Initialized local vector store with chromem-go (file: %s)", dbPath)
 	return lvs, nil
 }
 
+// openLocalDB opens the persistent chromem database in dbPath, or with a
+// cipher the encrypted one next to it. A key that does not match the files on
+// disk is an error rather than an empty database.
+func openLocalDB(dbPath string, cipher *FileCipher) (*chromem.DB, error) {
+	encPath := encryptedDBPath(dbPath)
+	_, statErr := os.Stat(encPath)
+	if cipher == nil {
+		if statErr == nil {
+			return nil, fmt.Errorf("the local database %s is encrypted; set storage.encryption_key or BRAINMCP_ENCRYPTION_KEY", encPath)
+		}
+		db, err := chromem.NewPersistentDB(dbPath, true)
+		if err != nil {
+			return nil, &CorruptStoreError{Path: dbPath, Err: err}
+		}
+		return db, nil
+	}
+
+	if hasPlaintextDB(dbPath) {
+		return nil, fmt.Errorf("the local database %s is not encrypted; run 'brainmcp migrate_encryption' to encrypt it", dbPath)
+	}
+	// The directory keeps holding the mutation stamp
+	if err := os.MkdirAll(dbPath, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+	db := chromem.NewDB()
+	if statErr == nil {
+		if err := cipher.importDB(db, encPath); err != nil {
+			return nil, &CorruptStoreError{Path: encPath, Err: fmt.Errorf("wrong encryption key or corrupted file: %w", err)}
+		}
+	}
+	return db, nil
+}
+
+// exportEncrypted writes the whole database to its encrypted file, through a
+// temporary file so a crash leaves the previous export intact, and cancels a
+// scheduled export. The caller must hold the lock.
+func (lvs *LocalVectorStore) exportEncrypted() error {
+	if lvs.saveTimer != nil {
+		lvs.saveTimer.Stop()
+		lvs.saveTimer = nil
+	}
+	encPath := encryptedDBPath(lvs.dbPath)
+	tmpPath := encPath + ".tmp"
+	if err := lvs.cipher.exportDB(lvs.db, tmpPath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, encPath)
+}
+
 // AddDocuments adds documents to the collection.
+// Documents without an embedding are embedded in bulk before insertion so that
+// chromem does not issue one embedding request per document.
 func (lvs *LocalVectorStore) AddDocuments(ctx context.Context, documents []chromem.Document, concurrency int) error {
+	documents, err := embedMissing(ctx, lvs, documents)
+	if err != nil {
+		return fmt.Errorf("batch embedding failed: %w", err)
+	}
+
 	lvs.mu.Lock()
 	defer lvs.mu.Unlock()
 
+	if len(documents) > 0 && len(documents[0].Embedding) > 0 {
+		lvs.dim = len(documents[0].Embedding)
+	}
+	defer lvs.bumpStamp()
 	return lvs.collection.AddDocuments(ctx, documents, concurrency)
 }
 
@@ -105,6 +227,7 @@ func (lvs *LocalVectorStore) AddDocument(ctx context.Context, document chromem.D
 	lvs.mu.Lock()
 	defer lvs.mu.Unlock()
 
+	defer lvs.bumpStamp()
 	return lvs.collection.AddDocument(ctx, document)
 }
 
@@ -118,6 +241,10 @@ func (lvs *LocalVectorStore) Query(ctx context.Context, queryText string, nResul
 
 // QueryEmbedding searches using a pre-computed embedding vector.
 func (lvs *LocalVectorStore) QueryEmbedding(ctx context.Context, queryEmbedding []float32, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	if err := vecmath.CheckFinite(queryEmbedding); err != nil {
+		return nil, fmt.Errorf("invalid query embedding: %w", err)
+	}
+
 	lvs.mu.RLock()
 	defer lvs.mu.RUnlock()
 
@@ -132,11 +259,27 @@ func (lvs *LocalVectorStore) GetByID(ctx context.Context, id string) (chromem.Do
 	return lvs.collection.GetByID(ctx, id)
 }
 
+// UpdateMetadata re-adds the document with its stored embedding and the new
+// metadata. chromem replaces documents with the same ID in place.
+func (lvs *LocalVectorStore) UpdateMetadata(ctx context.Context, id string, metadata map[string]string) error {
+	lvs.mu.Lock()
+	defer lvs.mu.Unlock()
+
+	doc, err := lvs.collection.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	doc.Metadata = metadata
+	defer lvs.bumpStamp()
+	return lvs.collection.AddDocument(ctx, doc)
+}
+
 // Delete removes documents by IDs.
 func (lvs *LocalVectorStore) Delete(ctx context.Context, where, whereDocument map[string]string, ids ...string) error {
 	lvs.mu.Lock()
 	defer lvs.mu.Unlock()
 
+	defer lvs.bumpStamp()
 	return lvs.collection.Delete(ctx, where, whereDocument, ids...)
 }
 
@@ -151,12 +294,13 @@ func (lvs *LocalVectorStore) ClearAll(ctx context.Context) error {
 		return fmt.Errorf("failed to delete collection: %w", err)
 	}
 
-	col, err := lvs.db.GetOrCreateCollection(collectionName, nil, lvs.embFunc)
+	col, err := lvs.db.GetOrCreateCollection(collectionName, lvs.metadata, lvs.embFunc)
 	if err != nil {
 		return fmt.Errorf("failed to recreate collection: %w", err)
 	}
 
 	lvs.collection = col
+	lvs.bumpStamp()
 	lvs.logger.Printf("Cleared all documents from collection %q", collectionName)
 	return nil
 }
@@ -174,6 +318,12 @@ func (lvs *LocalVectorStore) Close() error {
 	lvs.mu.Lock()
 	defer lvs.mu.Unlock()
 
+	if lvs.db != nil && lvs.cipher != nil {
+		if err := lvs.exportEncrypted(); err != nil {
+			return fmt.Errorf("failed to export database before closing: %w", err)
+		}
+		return nil
+	}
 	if lvs.db != nil {
 		if err := lvs.db.ExportToFile("", true, ""); err != nil {
 			return fmt.Errorf("failed to export database before closing: %w", err)
@@ -188,6 +338,12 @@ func (lvs *LocalVectorStore) SaveToDisk() error {
 	lvs.mu.Lock()
 	defer lvs.mu.Unlock()
 
+	if lvs.db != nil && lvs.cipher != nil {
+		if err := lvs.exportEncrypted(); err != nil {
+			return fmt.Errorf("failed to export database to disk: %w", err)
+		}
+		return nil
+	}
 	if lvs.db != nil {
 		if err := lvs.db.ExportToFile("", true, ""); err != nil {
 			return fmt.Errorf("failed to export database to disk: %w", err)
@@ -212,6 +368,141 @@ func (lvs *LocalVectorStore) BatchEmbed(ctx context.Context, texts []string) ([]
 	return embeddings, nil
 }
 
+// ListDocuments enumerates documents by running an exhaustive query with a
+// probe vector, which avoids embedding anything. If the stored dimension differs
+// from the assumed one, the real dimension is learned from a single embedding call.
+func (lvs *LocalVectorStore) ListDocuments(ctx context.Context, where map[string]string, offset, limit int) ([]chromem.Document, error) {
+	lvs.mu.RLock()
+	count := lvs.collection.Count()
+	dim := lvs.dim
+	lvs.mu.RUnlock()
+	if count == 0 {
+		return nil, nil
+	}
+
+	results, err := lvs.queryAll(ctx, dim, count, where)
+	if err != nil && strings.Contains(err.Error(), "same length") {
+		probe, embErr := lvs.embFunc(ctx, " ")
+		if embErr != nil {
+			return nil, fmt.Errorf("failed to determine embedding dimension: %w", embErr)
+		}
+		lvs.mu.Lock()
+		lvs.dim = len(probe)
+		lvs.mu.Unlock()
+		results, err = lvs.queryAll(ctx, len(probe), count, where)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	docs := make([]chromem.Document, 0, len(results))
+	for _, res := range results {
+		docs = append(docs, chromem.Document{
+			ID:        res.ID,
+			Metadata:  res.Metadata,
+			Embedding: res.Embedding,
+			Content:   res.Content,
+		})
+	}
+	return paginateDocuments(docs, offset, limit), nil
+}
+
+// ListByTag filters the enumerated documents on their tags. Enumeration
+// embeds nothing and stays in memory.
+func (lvs *LocalVectorStore) ListByTag(ctx context.Context, tag string) ([]chromem.Document, error) {
+	docs, err := lvs.ListDocuments(ctx, nil, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	return documentsWithTag(docs, tag), nil
+}
+
+// documentsWithTag returns the docs whose tags include tag, ignoring case.
+// Substrings of a tag do not match: "go" does not match "golang".
+func documentsWithTag(docs []chromem.Document, tag string) []chromem.Document {
+	var tagged []chromem.Document
+	for _, doc := range docs {
+		if hasTag(ParseTags(doc.Metadata["tags"]), tag) {
+			tagged = append(tagged, doc)
+		}
+	}
+	return tagged
+}
+
+// queryAll returns every document matching where using a unit probe vector.
+func (lvs *LocalVectorStore) queryAll(ctx context.Context, dim, count int, where map[string]string) ([]chromem.Result, error) {
+	probe := make([]float32, dim)
+	probe[0] = 1
+
+	lvs.mu.RLock()
+	defer lvs.mu.RUnlock()
+	return lvs.collection.QueryEmbedding(ctx, probe, count, where, nil)
+}
+
+// MutationStamp returns the persisted mutation counter.
+func (lvs *LocalVectorStore) MutationStamp() uint64 {
+	lvs.mu.RLock()
+	defer lvs.mu.RUnlock()
+
+	return lvs.stamp
+}
+
+// stampPath is the sidecar file holding the mutation counter. chromem ignores
+// plain files in its persistence directory.
+func (lvs *LocalVectorStore) stampPath() string {
+	return filepath.Join(lvs.dbPath, "mutation_stamp")
+}
+
+// bumpStamp increments and persists the mutation counter (caller must hold the write lock).
+func (lvs *LocalVectorStore) bumpStamp() {
+	lvs.stamp++
+	if err := os.WriteFile(lvs.stampPath(), []byte(fmt.Sprintf("%d", lvs.stamp)), 0644); err != nil {
+		lvs.logger.Printf("Warning: Failed to persist mutation stamp: %v", err)
+	}
+	// An encrypted database has no per-document files, so it is exported
+	// whole once a burst of mutations is over
+	if lvs.cipher != nil {
+		lvs.scheduleExportLocked()
+	}
+}
+
+// scheduleExportLocked debounces exports of the encrypted database so bursts
+// of mutations cause a single export (caller must hold the write lock).
+func (lvs *LocalVectorStore) scheduleExportLocked() {
+	if lvs.saveTimer != nil {
+		lvs.saveTimer.Stop()
+	}
+	lvs.saveTimer = time.AfterFunc(EncryptedExportDelay, func() {
+		lvs.mu.Lock()
+		defer lvs.mu.Unlock()
+		if err := lvs.exportEncrypted(); err != nil {
+			lvs.logger.Printf("Warning: Failed to write encrypted database: %v", err)
+		}
+	})
+}
+
+// documentIDs returns the IDs of docs in order.
+func documentIDs(docs []chromem.Document) []string {
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		ids[i] = doc.ID
+	}
+	return ids
+}
+
+// paginateDocuments applies offset/limit to an ordered document slice.
+func paginateDocuments(docs []chromem.Document, offset, limit int) []chromem.Document {
+	if offset >= len(docs) {
+		return nil
+	}
+	docs = docs[max(offset, 0):]
+	if limit > 0 && len(docs) > limit {
+		docs = docs[:limit]
+	}
+	return docs
+}
+
 // QdrantVectorStore implements VectorBackend using Qdrant remote service.
 type QdrantVectorStore struct {
 	client    *qdrant.Client
@@ -232,6 +523,11 @@ type DocumentStore struct {
 
 // NewQdrantVectorStore connects to a Qdrant instance and initializes a collection.
 func NewQdrantVectorStore(host string, port int, apiKey string, useTLS bool, vectorDim int, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, logger *log.Logger) (*QdrantVectorStore, error) {
+	return newQdrantVectorStore(host, port, apiKey, useTLS, "brainmcp-memories", vectorDim, embFunc, batchEmbf, logger)
+}
+
+// newQdrantVectorStore connects to a Qdrant instance and initializes the named collection.
+func newQdrantVectorStore(host string, port int, apiKey string, useTLS bool, collName string, vectorDim int, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, logger *log.Logger) (*QdrantVectorStore, error) {
 	if logger == nil {
 		logger = log.New(io.Discard, "", 0)
 	}
@@ -249,7 +545,7 @@ func NewQdrantVectorStore(host string, port int, apiKey string, useTLS bool, vec
 
 	qvs := &QdrantVectorStore{
 		client:    client,
-		collName:  "brainmcp-memories",
+		collName:  collName,
 		embFunc:   embFunc,
 		batchEmbf: batchEmbf,
 		logger:    logger,
@@ -282,6 +578,21 @@ func NewQdrantVectorStore(host string, port int, apiKey string, useTLS bool, vec
 		if err != nil {
 			return nil, fmt.Errorf("failed to create Qdrant collection: %w", err)
 		}
+	} else {
+		// An existing collection keeps its vector size, so a changed
+		// vector_dimension would only fail on the first upsert
+		info, err := client.GetCollectionInfo(context.Background(), qvs.collName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Qdrant collection info: %w", err)
+		}
+		size := info.GetConfig().GetParams().GetVectorsConfig().GetParams().GetSize()
+		if size != 0 && size != qvs.vectorDim {
+			return nil, fmt.Errorf("Qdrant collection %s has %d-dimensional vectors but vector_dimension is %d; use the collection's size or re-embed into a new collection", qvs.collName, size, qvs.vectorDim)
+		}
+	}
+
+	if err := qvs.backfillMetadataPayload(context.Background()); err != nil {
+		logger.Printf("Warning: Failed to backfill Qdrant metadata payload: %v", err)
 	}
 
 	logger.Printf("Connected to Qdrant at %s:%d (collection: %s)", host, port, qvs.collName)
@@ -290,32 +601,24 @@ func NewQdrantVectorStore(host string, port int, apiKey string, useTLS bool, vec
 
 // AddDocuments adds documents to Qdrant.
 func (qvs *QdrantVectorStore) AddDocuments(ctx context.Context, documents []chromem.Document, concurrency int) error {
-	qvs.mu.Lock()
-	defer qvs.mu.Unlock()
-
 	if len(documents) == 0 {
 		return nil
 	}
 
-	// Prepare texts for batch embedding
-	texts := make([]string, len(documents))
-	for i, doc := range documents {
-		texts[i] = doc.Content
-	}
-
-	// Generate embeddings in batch
-	embeddings, err := qvs.BatchEmbed(ctx, texts)
+	// Generate missing embeddings in batch before taking the lock
+	documents, err := embedMissing(ctx, qvs, documents)
 	if err != nil {
 		return fmt.Errorf("batch embedding failed: %w", err)
 	}
 
+	qvs.mu.Lock()
+	defer qvs.mu.Unlock()
+
 	points := make([]*qdrant.PointStruct, len(documents))
 
 	for i, doc := range documents {
-		embedding := embeddings[i]
-
 		// FIX 2: Use qdrant.NewVectors(slice...) instead of struct literal with unknown field.
-		vectors := qdrant.NewVectors(embedding...)
+		vectors := qdrant.NewVectors(doc.Embedding...)
 
 		// FIX 3: Serialize document metadata into the payload map properly.
 		//        Remove the unused `payload` variable.
@@ -333,9 +636,7 @@ func (qvs *QdrantVectorStore) AddDocuments(ctx context.Context, documents []chro
 		points[i] = &qdrant.PointStruct{
 			Id:      qdrant.NewIDNum(hashStringToUint64(doc.ID)),
 			Vectors: vectors,
-			Payload: qdrant.NewValueMap(map[string]any{
-				"payload": string(payloadBytes),
-			}),
+			Payload: qdrant.NewValueMap(indexedPayload(doc.Metadata, string(payloadBytes))),
 		}
 	}
 
@@ -369,6 +670,7 @@ func (qvs *QdrantVectorStore) GetByID(ctx context.Context, id string) (chromem.D
 		CollectionName: qvs.collName,
 		Ids:            []*qdrant.PointId{qdrant.NewIDNum(pointID)},
 		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(true),
 	})
 	if err != nil {
 		return chromem.Document{}, fmt.Errorf("failed to get point from Qdrant: %w", err)
@@ -383,11 +685,15 @@ func (qvs *QdrantVectorStore) GetByID(ctx context.Context, id string) (chromem.D
 		if stringVal, ok := payloadVal.Kind.(*qdrant.Value_StringValue); ok {
 			var docStore DocumentStore
 			if err := json.Unmarshal([]byte(stringVal.StringValue), &docStore); err == nil {
-				return chromem.Document{
+				doc := chromem.Document{
 					ID:       docStore.ID,
 					Content:  docStore.Content,
 					Metadata: docStore.Metadata,
-				}, nil
+				}
+				if vectors := points[0].GetVectors(); vectors != nil && vectors.GetVector() != nil {
+					doc.Embedding = vectors.GetVector().GetData()
+				}
+				return doc, nil
 			}
 		}
 	}
@@ -395,6 +701,34 @@ func (qvs *QdrantVectorStore) GetByID(ctx context.Context, id string) (chromem.D
 	return chromem.Document{}, fmt.Errorf("failed to decode document %q", id)
 }
 
+// UpdateMetadata rewrites the point's payload with SetPayload, leaving its
+// vector untouched.
+func (qvs *QdrantVectorStore) UpdateMetadata(ctx context.Context, id string, metadata map[string]string) error {
+	doc, err := qvs.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	payloadBytes, err := json.Marshal(DocumentStore{ID: doc.ID, Content: doc.Content, Metadata: metadata})
+	if err != nil {
+		return fmt.Errorf("failed to marshal document %q: %w", id, err)
+	}
+
+	qvs.mu.Lock()
+	defer qvs.mu.Unlock()
+
+	wait := true
+	_, err = qvs.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+		CollectionName: qvs.collName,
+		Wait:           &wait,
+		Payload:        qdrant.NewValueMap(indexedPayload(metadata, string(payloadBytes))),
+		PointsSelector: qdrant.NewPointsSelector(qdrant.NewIDNum(hashStringToUint64(id))),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update payload of %q: %w", id, err)
+	}
+	return nil
+}
+
 // Query is not natively supported on QdrantVectorStore without a separate embed call;
 // it embeds the query text first then delegates to QueryEmbedding.
 func (qvs *QdrantVectorStore) Query(ctx context.Context, queryText string, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
@@ -407,6 +741,10 @@ func (qvs *QdrantVectorStore) Query(ctx context.Context, queryText string, nResu
 
 // QueryEmbedding searches Qdrant using a pre-computed embedding vector.
 func (qvs *QdrantVectorStore) QueryEmbedding(ctx context.Context, queryEmbedding []float32, nResults int, where, whereDocument map[string]string) ([]chromem.Result, error) {
+	if err := vecmath.CheckFinite(queryEmbedding); err != nil {
+		return nil, fmt.Errorf("invalid query embedding: %w", err)
+	}
+
 	qvs.mu.RLock()
 	defer qvs.mu.RUnlock()
 
@@ -414,6 +752,7 @@ func (qvs *QdrantVectorStore) QueryEmbedding(ctx context.Context, queryEmbedding
 	result, err := qvs.client.Query(ctx, &qdrant.QueryPoints{
 		CollectionName: qvs.collName,
 		Query:          qdrant.NewQueryDense(queryEmbedding),
+		Filter:         qdrantFilter(where),
 		Limit:          &limit,
 		WithPayload:    qdrant.NewWithPayload(true),
 	})
@@ -423,24 +762,17 @@ func (qvs *QdrantVectorStore) QueryEmbedding(ctx context.Context, queryEmbedding
 
 	results := make([]chromem.Result, 0, len(result))
 	for _, hit := range result {
-		payloadVal, ok := hit.Payload["payload"]
-		if !ok {
-			continue
-		}
-		stringVal, ok := payloadVal.Kind.(*qdrant.Value_StringValue)
-		if !ok {
-			continue
-		}
-		var docStore DocumentStore
-		if err := json.Unmarshal([]byte(stringVal.StringValue), &docStore); err != nil {
+		doc, ok := decodeQdrantPayload(hit.Payload)
+		// The serialized payload is authoritative; re-check the filter against it
+		if !ok || !matchesWhere(doc.Metadata, where) {
 			continue
 		}
 		results = append(results, chromem.Result{
-			ID:         docStore.ID,
-			Metadata:   docStore.Metadata,
+			ID:         doc.ID,
+			Metadata:   doc.Metadata,
 			Embedding:  nil,
-			Content:    docStore.Content,
-			Similarity: 1.0 - hit.Score, // Convert similarity to distance
+			Content:    doc.Content,
+			Similarity: hit.Score, // Cosine similarity, same as the local store
 		})
 	}
 
@@ -454,6 +786,17 @@ func (qvs *QdrantVectorStore) Delete(ctx context.Context, where, whereDocument m
 	defer qvs.mu.Unlock()
 
 	if len(ids) == 0 {
+		if len(where) == 0 {
+			return nil
+		}
+		_, err := qvs.client.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: qvs.collName,
+			Points:         qdrant.NewPointsSelectorFilter(qdrantFilter(where)),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete points from Qdrant: %w", err)
+		}
+		qvs.logger.Printf("Deleted documents matching %v from Qdrant", where)
 		return nil
 	}
 
@@ -547,41 +890,418 @@ func (qvs *QdrantVectorStore) BatchEmbed(ctx context.Context, texts []string) ([
 	return embeddings, nil
 }
 
+// embedMissing returns a copy of documents where every document lacking an
+// embedding has one generated through a single BatchEmbed call.
+func embedMissing(ctx context.Context, backend VectorBackend, documents []chromem.Document) ([]chromem.Document, error) {
+	var texts []string
+	var indices []int
+	for i, doc := range documents {
+		if len(doc.Embedding) == 0 {
+			texts = append(texts, doc.Content)
+			indices = append(indices, i)
+		}
+	}
+	if len(texts) == 0 {
+		return documents, nil
+	}
+
+	embeddings, err := backend.BatchEmbed(ctx, texts)
+	if err != nil {
+		return nil, err
+	}
+	if len(embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(embeddings))
+	}
+
+	embedded := make([]chromem.Document, len(documents))
+	copy(embedded, documents)
+	for j, i := range indices {
+		embedded[i].Embedding = embeddings[j]
+	}
+	return embedded, nil
+}
+
+// ListDocuments scrolls through the collection page by page, decoding payloads and
+// vectors. Metadata filters are applied server-side on the indexed metadata
+// payload and re-checked against the serialized document.
+func (qvs *QdrantVectorStore) ListDocuments(ctx context.Context, where map[string]string, offset, limit int) ([]chromem.Document, error) {
+	qvs.mu.RLock()
+	defer qvs.mu.RUnlock()
+
+	var docs []chromem.Document
+	var next *qdrant.PointId
+	pageSize := uint32(256)
+	for {
+		points, nextOffset, err := qvs.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: qvs.collName,
+			Filter:         qdrantFilter(where),
+			Offset:         next,
+			Limit:          &pageSize,
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scroll Qdrant collection: %w", err)
+		}
+
+		for _, point := range points {
+			doc, ok := decodeQdrantPayload(point.Payload)
+			if !ok || !matchesWhere(doc.Metadata, where) {
+				continue
+			}
+			if vectors := point.GetVectors(); vectors != nil && vectors.GetVector() != nil {
+				doc.Embedding = vectors.GetVector().GetData()
+			}
+			docs = append(docs, doc)
+		}
+
+		if nextOffset == nil || len(points) == 0 {
+			break
+		}
+		next = nextOffset
+	}
+
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return paginateDocuments(docs, offset, limit), nil
+}
+
+// ListByTag scrolls through the points whose tags payload contains tag, so
+// only tagged documents are transferred, without their vectors.
+func (qvs *QdrantVectorStore) ListByTag(ctx context.Context, tag string) ([]chromem.Document, error) {
+	qvs.mu.RLock()
+	defer qvs.mu.RUnlock()
+
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	filter := &qdrant.Filter{Must: []*qdrant.Condition{qdrant.NewMatch("tags", tag)}}
+	var docs []chromem.Document
+	var next *qdrant.PointId
+	pageSize := uint32(256)
+	for {
+		points, nextOffset, err := qvs.client.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: qvs.collName,
+			Filter:         filter,
+			Offset:         next,
+			Limit:          &pageSize,
+			WithPayload:    qdrant.NewWithPayload(true),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to scroll Qdrant collection: %w", err)
+		}
+		for _, point := range points {
+			if doc, ok := decodeQdrantPayload(point.Payload); ok {
+				docs = append(docs, doc)
+			}
+		}
+		if nextOffset == nil || len(points) == 0 {
+			break
+		}
+		next = nextOffset
+	}
+
+	// The serialized document is authoritative; re-check the match against it
+	docs = documentsWithTag(docs, tag)
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+	return docs, nil
+}
+
+// MutationStamp returns 0 since a shared Qdrant collection can be modified by other clients.
+func (qvs *QdrantVectorStore) MutationStamp() uint64 {
+	return 0
+}
+
+// decodeQdrantPayload extracts the serialized DocumentStore from a point payload.
+func decodeQdrantPayload(payload map[string]*qdrant.Value) (chromem.Document, bool) {
+	payloadVal, ok := payload["payload"]
+	if !ok {
+		return chromem.Document{}, false
+	}
+	stringVal, ok := payloadVal.Kind.(*qdrant.Value_StringValue)
+	if !ok {
+		return chromem.Document{}, false
+	}
+	var docStore DocumentStore
+	if err := json.Unmarshal([]byte(stringVal.StringValue), &docStore); err != nil {
+		return chromem.Document{}, false
+	}
+	return chromem.Document{
+		ID:       docStore.ID,
+		Content:  docStore.Content,
+		Metadata: docStore.Metadata,
+	}, true
+}
+
+// metadataPayload converts document metadata into a payload value. Metadata is
+// stored as a nested object next to the serialized document so Qdrant can filter on it.
+func metadataPayload(metadata map[string]string) map[string]any {
+	out := make(map[string]any, len(metadata))
+	for k, v := range metadata {
+		out[k] = v
+	}
+	return out
+}
+
+// qdrantPayloadVersion marks points whose payload has every indexed field
+// written by indexedPayload. Version 2 added tags.
+const qdrantPayloadVersion = 2
+
+// indexedPayload returns the payload of a point: the serialized document, its
+// metadata as a filterable map and its tags as a lowercase keyword list.
+func indexedPayload(metadata map[string]string, serialized string) map[string]any {
+	var tags []any
+	for _, tag := range ParseTags(metadata["tags"]) {
+		tags = append(tags, strings.ToLower(tag))
+	}
+	return map[string]any{
+		"payload":         serialized,
+		"metadata":        metadataPayload(metadata),
+		"tags":            tags,
+		"payload_version": qdrantPayloadVersion,
+	}
+}
+
+// qdrantFilter builds a filter matching every key/value pair in where, or nil when where is empty.
+func qdrantFilter(where map[string]string) *qdrant.Filter {
+	if len(where) == 0 {
+		return nil
+	}
+	conditions := make([]*qdrant.Condition, 0, len(where))
+	for k, v := range where {
+		conditions = append(conditions, qdrant.NewMatch("metadata."+k, v))
+	}
+	return &qdrant.Filter{Must: conditions}
+}
+
+// backfillMetadataPayload adds the filterable metadata and tags payload to
+// points written before qdrantPayloadVersion, so metadata and tag filters
+// don't silently skip older memories.
+func (qvs *QdrantVectorStore) backfillMetadataPayload(ctx context.Context) error {
+	filter := &qdrant.Filter{MustNot: []*qdrant.Condition{qdrant.NewMatchInt("payload_version", qdrantPayloadVersion)}}
+	pageSize := uint32(256)
+	updated := 0
+	for {
+		points, err := qvs.client.Scroll(ctx, &qdrant.ScrollPoints{
+			CollectionName: qvs.collName,
+			Filter:         filter,
+			Limit:          &pageSize,
+			WithPayload:    qdrant.NewWithPayload(true),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to scroll Qdrant collection: %w", err)
+		}
+		if len(points) == 0 {
+			break
+		}
+
+		progressed := false
+		for _, point := range points {
+			doc, ok := decodeQdrantPayload(point.Payload)
+			if !ok {
+				continue
+			}
+			serialized := point.Payload["payload"].GetStringValue()
+			wait := true
+			_, err := qvs.client.SetPayload(ctx, &qdrant.SetPayloadPoints{
+				CollectionName: qvs.collName,
+				Wait:           &wait,
+				Payload:        qdrant.NewValueMap(indexedPayload(doc.Metadata, serialized)),
+				PointsSelector: qdrant.NewPointsSelector(point.Id),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to update payload of %q: %w", doc.ID, err)
+			}
+			updated++
+			progressed = true
+		}
+		// Points that cannot be backfilled stay in the filter; stop instead of looping on them
+		if !progressed || len(points) < int(pageSize) {
+			break
+		}
+	}
+
+	if updated > 0 {
+		qvs.logger.Printf("Backfilled metadata and tags payload for %d Qdrant points", updated)
+	}
+	return nil
+}
+
+// matchesWhere reports whether metadata contains every key/value pair in where.
+func matchesWhere(metadata, where map[string]string) bool {
+	for k, v := range where {
+		if metadata[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// backendSelection is the vector backend a configuration selects, with the
+// defaults NewVectorBackend applies filled in.
+type backendSelection struct {
+	Name      string // local, qdrant, pgvector or redis
+	Dimension int    // Vector size; 0 lets the local store take the embedder's
+	Port      int    // Qdrant gRPC port
+	Table     string // pgvector table
+	Index     string // Redis search index
+	Path      string // Local database path
+}
+
+// selectBackend returns the vector backend cfg configures. Configuring more
+// than one remote backend is an error; without one the local store is used.
+func selectBackend(cfg *Config) (backendSelection, error) {
+	if cfg == nil {
+		cfg = DefaultConfig()
+	}
+	sel := backendSelection{Name: "local"}
+	var configured []string
+	if cfg.Qdrant.Host != "" {
+		configured = append(configured, "qdrant.host")
+		sel.Name = "qdrant"
+	}
+	if cfg.Pgvector.DSN != "" {
+		configured = append(configured, "pgvector.dsn")
+		sel.Name = "pgvector"
+	}
+	if cfg.Redis.Addr != "" {
+		configured = append(configured, "redis.addr")
+		sel.Name = "redis"
+	}
+	if len(configured) > 1 {
+		return sel, fmt.Errorf("%s are configured; choose one vector backend", strings.Join(configured, " and "))
+	}
+
+	switch sel.Name {
+	case "qdrant":
+		sel.Port = cfg.Qdrant.Port
+		if sel.Port == 0 {
+			sel.Port = 6334 // Default Qdrant gRPC port
+		}
+		sel.Dimension = cfg.Qdrant.VectorDimension
+	case "pgvector":
+		sel.Table = cfg.Pgvector.TableName
+		if sel.Table == "" {
+			sel.Table = "memories"
+		}
+		sel.Dimension = cfg.Pgvector.VectorDimension
+	case "redis":
+		sel.Index = cfg.Redis.IndexName
+		if sel.Index == "" {
+			sel.Index = "brainmcp-memories"
+		}
+		sel.Dimension = cfg.Redis.VectorDimension
+	default:
+		dataDir, err := localDataDir()
+		if err != nil {
+			return sel, err
+		}
+		sel.Path = dataDir + "/brain_memory.bin"
+		// Only Gemini embeddings have a configured size; local models decide their own
+		if cfg.EmbeddingProvider == "gemini" {
+			sel.Dimension = cfg.Gemini.EmbeddingDimension
+		}
+		return sel, nil
+	}
+	if sel.Dimension == 0 {
+		sel.Dimension = 768
+	}
+	return sel, nil
+}
+
 // NewVectorBackend factory function that returns the appropriate backend based on configuration.
+// The selected backend must pass the conformance suite first.
 func NewVectorBackend(cfg *Config, embFunc chromem.EmbeddingFunc, batchEmbf BatchEmbeddingFunc, logger *log.Logger) (VectorBackend, error) {
 	if logger == nil {
 		logger = log.New(io.Discard, "", 0)
 	}
 
-	// Check for Qdrant configuration
-	if cfg != nil && cfg.Qdrant.Host != "" {
-		qdrantHost := cfg.Qdrant.Host
-		qdrantPort := cfg.Qdrant.Port
-		if qdrantPort == 0 {
-			qdrantPort = 6334 // Default Qdrant gRPC port
-		}
-		qdrantAPIKey := cfg.Qdrant.APIKey
-		useTLS := cfg.Qdrant.UseTLS
-		vectorDim := cfg.Qdrant.VectorDimension
-		if vectorDim == 0 {
-			vectorDim = 768
+	sel, err := selectBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg != nil {
+		if err := checkGeminiDimension(cfg, sel.Name); err != nil {
+			return nil, err
 		}
+	}
+
+	// The local store is registered as chromem
+	name := sel.Name
+	if name == "local" {
+		name = "chromem"
+	}
+	if err := vectorBackends.verify(context.Background(), name, logger); err != nil {
+		return nil, err
+	}
 
-		logger.Printf("Attempting to use Qdrant backend: %s:%d", qdrantHost, qdrantPort)
-		return NewQdrantVectorStore(qdrantHost, qdrantPort, qdrantAPIKey, useTLS, vectorDim, embFunc, batchEmbf, logger)
+	switch sel.Name {
+	case "qdrant":
+		logger.Printf("Attempting to use Qdrant backend: %s:%d", cfg.Qdrant.Host, sel.Port)
+		return NewQdrantVectorStore(cfg.Qdrant.Host, sel.Port, cfg.Qdrant.APIKey, cfg.Qdrant.UseTLS, sel.Dimension, embFunc, batchEmbf, logger)
+	case "pgvector":
+		logger.Printf("Attempting to use pgvector backend (table: %s)", sel.Table)
+		return NewPgvectorVectorStore(context.Background(), cfg.Pgvector.DSN, sel.Table, sel.Dimension, embFunc, batchEmbf, logger)
+	case "redis":
+		logger.Printf("Attempting to use Redis backend: %s (index: %s)", cfg.Redis.Addr, sel.Index)
+		return NewRedisVectorStore(context.Background(), cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB, sel.Index, sel.Dimension, embFunc, batchEmbf, logger)
 	}
 
 	// Use local chromem-go backend as default
-	dataDir := os.Getenv("BRAINMCP_DATA_DIR")
-	if dataDir == "" {
-		home, err := os.UserHomeDir()
+	return NewLocalVectorStore(sel.Path, NewFileCipher(encryptionPassphrase(cfg)), embFunc, batchEmbf, sel.Dimension, logger)
+}
+
+// storeLocation returns the vector backend cfg selects and where it keeps the
+// memories. Credentials in the PostgreSQL DSN and Redis password are left out.
+func storeLocation(cfg *Config) (backend, location string) {
+	sel, err := selectBackend(cfg)
+	if err != nil {
+		return sel.Name, fmt.Sprintf("unknown (%v)", err)
+	}
+	switch sel.Name {
+	case "qdrant":
+		return sel.Name, fmt.Sprintf("%s:%d (collection: brainmcp-memories)", cfg.Qdrant.Host, sel.Port)
+	case "pgvector":
+		pgCfg, err := pgx.ParseConfig(cfg.Pgvector.DSN)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get home directory: %w", err)
+			return sel.Name, fmt.Sprintf("table %s", sel.Table)
 		}
-		dataDir = home + "/.brainmcp"
+		return sel.Name, fmt.Sprintf("%s:%d/%s (table: %s)", pgCfg.Host, pgCfg.Port, pgCfg.Database, sel.Table)
+	case "redis":
+		return sel.Name, fmt.Sprintf("%s db %d (index: %s)", cfg.Redis.Addr, cfg.Redis.DB, sel.Index)
+	}
+	return sel.Name, sel.Path
+}
+
+// localDataDir returns the directory of the local backend's database,
+// BRAINMCP_DATA_DIR or ~/.brainmcp.
+func localDataDir() (string, error) {
+	if dataDir := os.Getenv("BRAINMCP_DATA_DIR"); dataDir != "" {
+		return dataDir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %w", err)
 	}
+	return home + "/.brainmcp", nil
+}
 
-	return NewLocalVectorStore(dataDir+"/brain_memory.bin", embFunc, batchEmbf, logger)
+// checkGeminiDimension verifies gemini.embedding_dimension and, when Gemini
+// embeds the memories, that the remote backend's vector_dimension matches it.
+func checkGeminiDimension(cfg *Config, backend string) error {
+	dim := cfg.Gemini.EmbeddingDimension
+	if dim < 0 || dim > MaxGeminiEmbeddingDimension {
+		return fmt.Errorf("gemini.embedding_dimension must be between 1 and %d, got %d", MaxGeminiEmbeddingDimension, dim)
+	}
+	if cfg.EmbeddingProvider != "gemini" || dim == 0 {
+		return nil
+	}
+	vectorDim := map[string]int{
+		"qdrant":   cfg.Qdrant.VectorDimension,
+		"pgvector": cfg.Pgvector.VectorDimension,
+		"redis":    cfg.Redis.VectorDimension,
+	}
+	if size, ok := vectorDim[backend]; ok && size != 0 && size != dim {
+		return fmt.Errorf("%s.vector_dimension is %d but Gemini embeddings have %d dimensions (gemini.embedding_dimension); set both to the same size", backend, size, dim)
+	}
+	return nil
 }
 
 // hashStringToUint64 converts a string ID to uint64 for Qdrant point IDs.
@@ -7,6 +7,8 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -18,12 +20,13 @@ type MemoryVersionManager struct {
 	mu        sync.RWMutex
 	versionDB map[string]*MemoryWithHistory
 	filePath  string
+	cipher    *FileCipher // Encrypts the history file when set
 	logger    *log.Logger
 }
 
 // NewMemoryVersionManager creates a new version manager with JSON-based storage.
 // Pass a logger to enable activity logging, or nil to disable logging.
-func NewMemoryVersionManager(dirPath string, logger *log.Logger) (*MemoryVersionManager, error) {
+func NewMemoryVersionManager(dirPath string, cipher *FileCipher, logger *log.Logger) (*MemoryVersionManager, error) {
 	// Ensure directory exists
 	if err := os.MkdirAll(dirPath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create version directory: %w", err)
@@ -38,6 +41,7 @@ func NewMemoryVersionManager(dirPath string, logger *log.Logger) (*MemoryVersion
 	mvm := &MemoryVersionManager{
 		versionDB: make(map[string]*MemoryWithHistory),
 		filePath:  filePath,
+		cipher:    cipher,
 		logger:    logger,
 	}
 
@@ -54,7 +58,7 @@ func NewMemoryVersionManager(dirPath string, logger *log.Logger) (*MemoryVersion
 
 // load reads version history from disk (internal, not thread-safe caller must lock).
 func (m *MemoryVersionManager) load() error {
-	data, err := os.ReadFile(m.filePath)
+	data, err := m.cipher.ReadFile(m.filePath)
 	if err != nil {
 		return err
 	}
@@ -75,7 +79,7 @@ func (m *MemoryVersionManager) save() error {
 
 	// Write to temporary file first, then rename (atomic)
 	tmpPath := m.filePath + ".tmp"
-	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+	if err := m.cipher.WriteFile(tmpPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write version file: %w", err)
 	}
 
@@ -110,8 +114,8 @@ func (m *MemoryVersionManager) AddVersion(memoryID, content, clientID, changeNot
 			Versions:       []MemoryVersion{},
 			Context:        context,
 			Tags:           tags,
-			CreatedAt:      time.Now(),
-			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now().UTC(),
+			UpdatedAt:      time.Now().UTC(),
 			Metadata:       make(map[string]string),
 		}
 		m.logger.Printf("Creating new version history for memory %q", memoryID)
@@ -121,16 +125,19 @@ func (m *MemoryVersionManager) AddVersion(memoryID, content, clientID, changeNot
 	newVersion := MemoryVersion{
 		VersionNumber: len(history.Versions) + 1,
 		Content:       content,
-		CreatedAt:     time.Now(),
+		CreatedAt:     time.Now().UTC(),
 		CreatedBy:     clientID,
 		ChangeNote:    changeNote,
 	}
 
 	history.Versions = append(history.Versions, newVersion)
 	history.CurrentVersion = newVersion.VersionNumber
-	history.UpdatedAt = time.Now()
+	history.UpdatedAt = time.Now().UTC()
 	history.Context = context
 	history.Tags = tags
+	if pruned := pruneHistory(history, MaxVersionsPerMemory); pruned > 0 {
+		m.logger.Printf("Pruned %d old versions of memory %q", pruned, memoryID)
+	}
 
 	m.versionDB[memoryID] = history
 	m.logger.Printf("Added version %d to memory %q (client: %s)", newVersion.VersionNumber, memoryID, clientID)
@@ -157,6 +164,83 @@ func (m *MemoryVersionManager) GetVersion(memoryID string, versionNumber int) (*
 	return &history.Versions[versionNumber-1], nil
 }
 
+// LatestVersion returns a copy of the newest version of a memory, if it has any.
+func (m *MemoryVersionManager) LatestVersion(memoryID string) (MemoryVersion, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	history, exists := m.versionDB[memoryID]
+	if !exists || len(history.Versions) == 0 {
+		return MemoryVersion{}, false
+	}
+	return history.Versions[len(history.Versions)-1], true
+}
+
+// PruneVersions keeps only the newest keep versions of a memory and returns
+// the number of versions removed.
+func (m *MemoryVersionManager) PruneVersions(memoryID string, keep int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	history, exists := m.versionDB[memoryID]
+	if !exists {
+		return 0, fmt.Errorf("memory %q not found", memoryID)
+	}
+
+	pruned := pruneHistory(history, keep)
+	if pruned == 0 {
+		return 0, nil
+	}
+	m.logger.Printf("Pruned %d old versions of memory %q", pruned, memoryID)
+	return pruned, m.save()
+}
+
+// PruneAllVersions keeps only the newest keep versions of every memory and
+// returns the number of memories pruned and versions removed.
+func (m *MemoryVersionManager) PruneAllVersions(keep int) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	memories, removed := 0, 0
+	for _, history := range m.versionDB {
+		if pruned := pruneHistory(history, keep); pruned > 0 {
+			memories++
+			removed += pruned
+		}
+	}
+	if removed == 0 {
+		return 0, 0, nil
+	}
+	m.logger.Printf("Pruned %d old versions across %d memories", removed, memories)
+	return memories, removed, m.save()
+}
+
+// pruneHistory drops all but the newest keep versions and renumbers the rest
+// from 1, since version numbers are positions in the history. The running
+// total of removed versions is kept in the pruned_versions metadata key.
+func pruneHistory(history *MemoryWithHistory, keep int) int {
+	keep = max(keep, 1)
+	pruned := len(history.Versions) - keep
+	if pruned <= 0 {
+		return 0
+	}
+
+	history.Versions = slices.Clone(history.Versions[pruned:])
+	for i := range history.Versions {
+		history.Versions[i].VersionNumber = i + 1
+	}
+	if history.CurrentVersion -= pruned; history.CurrentVersion < 1 {
+		history.CurrentVersion = len(history.Versions)
+	}
+
+	if history.Metadata == nil {
+		history.Metadata = make(map[string]string)
+	}
+	total, _ := strconv.Atoi(history.Metadata["pruned_versions"])
+	history.Metadata["pruned_versions"] = strconv.Itoa(total + pruned)
+	return pruned
+}
+
 // GetHistory returns the full history of a memory.
 func (m *MemoryVersionManager) GetHistory(memoryID string) (*MemoryWithHistory, error) {
 	m.mu.RLock()
@@ -179,10 +263,10 @@ func (m *MemoryVersionManager) ExportMemories(memoryIDs []string, includeVersion
 
 	memories := []MemoryWithHistory{}
 	export := &ExportData{
-		ExportedAt: time.Now(),
+		ExportedAt: time.Now().UTC(),
 		ExportedBy: "system",
 		Memories:   memories,
-		Version:    "1.0",
+		Version:    ExportFormatVersion,
 	}
 
 	for id, history := range m.versionDB {
@@ -200,19 +284,41 @@ func (m *MemoryVersionManager) ExportMemories(memoryIDs []string, includeVersion
 			}
 		}
 
-		// Make a copy
-		copyHistory := *history
-		// Strip versions if not requested
-		if !includeVersions && len(copyHistory.Versions) > 0 {
-			lastVersion := copyHistory.Versions[len(copyHistory.Versions)-1]
-			copyHistory.Versions = []MemoryVersion{lastVersion}
-		}
-		export.Memories = append(export.Memories, copyHistory)
+		export.Memories = append(export.Memories, copyHistory(history, includeVersions))
 	}
 
 	return export
 }
 
+// ExportMemory returns a copy of one memory's history for export, reporting
+// whether the memory has any history.
+func (m *MemoryVersionManager) ExportMemory(memoryID string, includeVersions bool) (MemoryWithHistory, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	history, exists := m.versionDB[memoryID]
+	if !exists || len(history.Versions) == 0 {
+		return MemoryWithHistory{}, false
+	}
+	return copyHistory(history, includeVersions), true
+}
+
+// copyHistory copies a history so it can be used without the lock, keeping
+// only the latest version unless includeVersions is set.
+func copyHistory(history *MemoryWithHistory, includeVersions bool) MemoryWithHistory {
+	copied := *history
+	copied.Versions = append([]MemoryVersion(nil), history.Versions...)
+	copied.Tags = append([]string(nil), history.Tags...)
+	copied.Metadata = make(map[string]string, len(history.Metadata))
+	for k, v := range history.Metadata {
+		copied.Metadata[k] = v
+	}
+	if !includeVersions && len(copied.Versions) > 0 {
+		copied.Versions = copied.Versions[len(copied.Versions)-1:]
+	}
+	return copied
+}
+
 // ImportMemories imports memories from export data.
 func (m *MemoryVersionManager) ImportMemories(export *ExportData) error {
 	m.mu.Lock()
@@ -225,6 +331,191 @@ func (m *MemoryVersionManager) ImportMemories(export *ExportData) error {
 	return m.save()
 }
 
+// CurrentContent returns the content of the version marked as current,
+// falling back to the newest version when CurrentVersion is out of range.
+func (h *MemoryWithHistory) CurrentContent() string {
+	if len(h.Versions) == 0 {
+		return ""
+	}
+	if h.CurrentVersion >= 1 && h.CurrentVersion <= len(h.Versions) {
+		return h.Versions[h.CurrentVersion-1].Content
+	}
+	return h.Versions[len(h.Versions)-1].Content
+}
+
+// sameVersion reports whether two versions describe the same edit.
+// Version numbers are positional, so identity is based on content, author and time.
+func sameVersion(a, b MemoryVersion) bool {
+	return a.Content == b.Content && a.CreatedBy == b.CreatedBy && a.CreatedAt.Equal(b.CreatedAt)
+}
+
+// classifyHistory compares an incoming history against the local one and
+// returns the import class and the length of their common prefix.
+func classifyHistory(local, incoming *MemoryWithHistory) (string, int) {
+	if local == nil {
+		return ImportClassNew, 0
+	}
+
+	common := 0
+	for common < len(local.Versions) && common < len(incoming.Versions) &&
+		sameVersion(local.Versions[common], incoming.Versions[common]) {
+		common++
+	}
+
+	switch {
+	case common == len(local.Versions) && common == len(incoming.Versions):
+		return ImportClassIdentical, common
+	case common == len(local.Versions):
+		return ImportClassFastForward, common
+	case common == len(incoming.Versions):
+		return ImportClassStale, common
+	default:
+		return ImportClassConflict, common
+	}
+}
+
+// PreviewImport classifies every memory in an export against local history
+// without modifying anything.
+func (m *MemoryVersionManager) PreviewImport(export *ExportData) *ImportPreview {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	preview := &ImportPreview{Counts: make(map[string]int), Items: []ImportClassification{}}
+	for i := range export.Memories {
+		incoming := &export.Memories[i]
+		local := m.versionDB[incoming.ID]
+		class, common := classifyHistory(local, incoming)
+
+		item := ImportClassification{
+			ID:               incoming.ID,
+			Class:            class,
+			IncomingVersions: len(incoming.Versions),
+			CommonVersions:   common,
+		}
+		if local != nil {
+			item.LocalVersions = len(local.Versions)
+		}
+		preview.Counts[class]++
+		preview.Items = append(preview.Items, item)
+	}
+
+	return preview
+}
+
+// CommitImport applies an export using the strategy chosen for each class and
+// returns the per-memory outcome plus the IDs whose current content changed
+// (and therefore need to be written to the vector store).
+func (m *MemoryVersionManager) CommitImport(export *ExportData, strategy ImportStrategy) (*ImportPreview, []string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &ImportPreview{Counts: make(map[string]int), Items: []ImportClassification{}}
+	var changed []string
+
+	for i := range export.Memories {
+		incoming := export.Memories[i]
+		local := m.versionDB[incoming.ID]
+		class, common := classifyHistory(local, &incoming)
+
+		item := ImportClassification{
+			ID:               incoming.ID,
+			Class:            class,
+			IncomingVersions: len(incoming.Versions),
+			CommonVersions:   common,
+			Action:           "unchanged",
+		}
+		if local != nil {
+			item.LocalVersions = len(local.Versions)
+		}
+
+		switch class {
+		case ImportClassNew:
+			if strategy.New == "skip" {
+				item.Action = "skipped"
+				break
+			}
+			m.versionDB[incoming.ID] = &incoming
+			item.Action = "imported"
+			changed = append(changed, incoming.ID)
+
+		case ImportClassFastForward:
+			if strategy.FastForward == "skip" {
+				item.Action = "skipped"
+				break
+			}
+			local.Versions = append(local.Versions, incoming.Versions[common:]...)
+			local.CurrentVersion = incoming.CurrentVersion
+			local.Context = incoming.Context
+			local.Tags = incoming.Tags
+			local.UpdatedAt = incoming.UpdatedAt
+			item.Action = "fast_forwarded"
+			changed = append(changed, incoming.ID)
+
+		case ImportClassConflict:
+			switch strategy.Conflict {
+			case MergeKeepIncoming:
+				m.versionDB[incoming.ID] = &incoming
+				item.Action = "replaced_with_incoming"
+				changed = append(changed, incoming.ID)
+			case MergeAppend:
+				if mergeHistories(local, &incoming, common) {
+					changed = append(changed, incoming.ID)
+				}
+				item.Action = "merged"
+			default:
+				item.Action = "kept_local"
+			}
+		}
+
+		result.Counts[class]++
+		result.Items = append(result.Items, item)
+	}
+
+	if len(changed) > 0 {
+		if err := m.save(); err != nil {
+			return result, nil, err
+		}
+	}
+
+	m.logger.Printf("Import committed: %d memories changed", len(changed))
+	return result, changed, nil
+}
+
+// mergeHistories appends the incoming versions that diverged after the common
+// prefix to the local history, annotating their change notes. The version with
+// the newest timestamp becomes current. It reports whether the current content changed.
+func mergeHistories(local, incoming *MemoryWithHistory, common int) bool {
+	before := local.CurrentContent()
+
+	newest := local.Versions[len(local.Versions)-1]
+	current := len(local.Versions)
+	for _, v := range incoming.Versions[common:] {
+		originalNumber := v.VersionNumber
+		v.VersionNumber = len(local.Versions) + 1
+		note := fmt.Sprintf("Merged from import (incoming version %d)", originalNumber)
+		if v.ChangeNote != "" {
+			note += ": " + v.ChangeNote
+		}
+		v.ChangeNote = note
+		local.Versions = append(local.Versions, v)
+
+		if v.CreatedAt.After(newest.CreatedAt) {
+			newest = v
+			current = v.VersionNumber
+		}
+	}
+
+	local.CurrentVersion = current
+	local.UpdatedAt = time.Now().UTC()
+	for _, tag := range incoming.Tags {
+		if !slices.Contains(local.Tags, tag) {
+			local.Tags = append(local.Tags, tag)
+		}
+	}
+
+	return local.CurrentContent() != before
+}
+
 
 // DeleteMemoryHistory removes all version history for a memory.
 func (m *MemoryVersionManager) DeleteMemoryHistory(memoryID string) error {
@@ -283,14 +574,14 @@ func (m *MemoryVersionManager) BatchCreateMemories(memories []struct {
 			CurrentVersion: 1,
 			Context:        mem.Context,
 			Tags:           mem.Tags,
-			CreatedAt:      time.Now(),
-			UpdatedAt:      time.Now(),
+			CreatedAt:      time.Now().UTC(),
+			UpdatedAt:      time.Now().UTC(),
 			Metadata:       make(map[string]string),
 			Versions: []MemoryVersion{
 				{
 					VersionNumber: 1,
 					Content:       mem.Content,
-					CreatedAt:     time.Now(),
+					CreatedAt:     time.Now().UTC(),
 					CreatedBy:     mem.ClientID,
 					ChangeNote:    "Batch import",
 				},
@@ -387,7 +678,7 @@ func (m *MemoryVersionManager) BatchAddTags(memoryIDs []string, tags []string) (
 				history.Tags = append(history.Tags, tag)
 			}
 		}
-		history.UpdatedAt = time.Now()
+		history.UpdatedAt = time.Now().UTC()
 		result.Successful++
 	}
 
@@ -442,7 +733,7 @@ func (m *MemoryVersionManager) BatchRemoveTags(memoryIDs []string, tags []string
 			}
 		}
 		history.Tags = newTags
-		history.UpdatedAt = time.Now()
+		history.UpdatedAt = time.Now().UTC()
 		result.Successful++
 	}
 
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/philippgille/chromem-go"
+)
+
+// history returns the version history of id, failing the test if there is none.
+func (ta *testApp) history(t *testing.T, id string) *MemoryWithHistory {
+	t.Helper()
+	history, err := ta.versionMgr.GetHistory(id)
+	if err != nil {
+		t.Fatalf("GetHistory %s: %v", id, err)
+	}
+	return history
+}
+
+func TestRememberUpdateHistory(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "plan", "Launch on Monday", map[string]any{"tags": []any{"launch"}})
+	ta.remember(t, "plan", "Launch on Tuesday", map[string]any{"tags": []any{"launch", "moved"}, "change_note": "Monday is a holiday"})
+	// Storing the same content again records nothing
+	ta.remember(t, "plan", "Launch on Tuesday", nil)
+
+	history := ta.history(t, "plan")
+	if got := contents(*history); !slices.Equal(got, []string{"Launch on Monday", "Launch on Tuesday"}) {
+		t.Fatalf("versions = %q", got)
+	}
+	if history.CurrentVersion != 2 || history.Context != DefaultContextID || !slices.Equal(history.Tags, []string{"launch", "moved"}) {
+		t.Errorf("history = current %d, context %s, tags %v", history.CurrentVersion, history.Context, history.Tags)
+	}
+	for i, want := range []string{"Created", "Monday is a holiday"} {
+		v := history.Versions[i]
+		if v.ChangeNote != want || v.CreatedBy != ta.clientID || v.VersionNumber != i+1 {
+			t.Errorf("version %d = %+v, want note %q by %s", i+1, v, want, ta.clientID)
+		}
+	}
+
+	text, isErr := call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "plan"})
+	if isErr {
+		t.Fatalf("get_memory_history: %s", text)
+	}
+	for _, want := range []string{"(2 versions, current: 2", "Version 1 -", "Version 2 (current)", "Monday is a holiday", "Launch on Monday", "Launch on Tuesday"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("history lacks %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestRememberBatchRecordsVersions(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "b", "bravo before", nil)
+	if text, isErr := call(t, ta.rememberBatchHandler, map[string]any{"memories": []any{
+		map[string]any{"id": "a", "content": "alpha"},
+		map[string]any{"id": "b", "content": "bravo after"},
+	}}); isErr {
+		t.Fatalf("remember_batch: %s", text)
+	}
+	if n := len(ta.history(t, "a").Versions); n != 1 {
+		t.Errorf("a has %d versions, want 1", n)
+	}
+	if got := contents(*ta.history(t, "b")); !slices.Equal(got, []string{"bravo before", "bravo after"}) {
+		t.Errorf("b versions = %q", got)
+	}
+}
+
+func TestDeleteRemovesHistory(t *testing.T) {
+	ta := newTestApp(t, nil)
+	ta.remember(t, "gone", "soon deleted", nil)
+	if text, isErr := call(t, ta.deleteHandler, map[string]any{"id": "gone"}); isErr {
+		t.Fatalf("delete_memory: %s", text)
+	}
+	if _, err := ta.versionMgr.GetHistory("gone"); err == nil {
+		t.Error("history survived the delete")
+	}
+	if text, isErr := call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "gone"}); !isErr || !strings.Contains(text, "not found") {
+		t.Errorf("get_memory_history after delete = %q", text)
+	}
+}
+
+// togglingEmbedder is a countingEmbedder that fails while failing is set.
+type togglingEmbedder struct {
+	countingEmbedder
+	failing atomic.Bool
+}
+
+func (e *togglingEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	if e.failing.Load() {
+		return nil, errors.New("embedding provider unavailable")
+	}
+	return e.countingEmbedder.Embed(ctx, text)
+}
+
+func (e *togglingEmbedder) BatchEmbed(ctx context.Context, texts []string) ([][]float32, error) {
+	if e.failing.Load() {
+		return nil, errors.New("embedding provider unavailable")
+	}
+	return e.countingEmbedder.BatchEmbed(ctx, texts)
+}
+
+// newRestoreApp returns a testApp holding "deploy" in two versions, the second
+// current, on an embedder that can be made to fail.
+func newRestoreApp(t *testing.T) (*testApp, *togglingEmbedder) {
+	t.Helper()
+	ta := newTestApp(t, nil)
+	embedder := &togglingEmbedder{}
+	backend, err := NewLocalVectorStore(t.TempDir(), nil, embedder.Embed, embedder.BatchEmbed, testDimension, nil)
+	if err != nil {
+		t.Fatalf("NewLocalVectorStore: %v", err)
+	}
+	ta.vectorStore = NewIndexedVectorStore(backend, ta.keywordIndex, ta.hashIndex)
+
+	ta.remember(t, "deploy", "deploy with kubernetes helm charts", map[string]any{"tags": []any{"ops"}})
+	ta.remember(t, "deploy", "deploy with docker compose files", map[string]any{"tags": []any{"ops"}})
+	return ta, embedder
+}
+
+func TestRestoreVersionIsSearchable(t *testing.T) {
+	ta, _ := newRestoreApp(t)
+	search := func() string {
+		text, isErr := call(t, ta.searchHandler, map[string]any{"query": "kubernetes helm charts"})
+		if isErr {
+			t.Fatalf("search_memory: %s", text)
+		}
+		return text
+	}
+	if strings.Contains(search(), "kubernetes") {
+		t.Fatal("the replaced content is still found before restoring")
+	}
+
+	text, isErr := call(t, ta.restoreVersionHandler, map[string]any{"memory_id": "deploy", "version_number": 1.0, "restore_reason": "compose was a mistake"})
+	if isErr {
+		t.Fatalf("restore_version: %s", text)
+	}
+	if got := search(); !strings.Contains(got, "deploy with kubernetes helm charts") {
+		t.Errorf("search does not find the restored content:\n%s", got)
+	}
+
+	doc, err := ta.vectorStore.GetByID(t.Context(), "deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Content != "deploy with kubernetes helm charts" || doc.Metadata["context"] != DefaultContextID || !slices.Equal(ParseTags(doc.Metadata["tags"]), []string{"ops"}) {
+		t.Errorf("restored document = %q with %v", doc.Content, doc.Metadata)
+	}
+	history := ta.history(t, "deploy")
+	if len(history.Versions) != 3 || history.CurrentVersion != 3 {
+		t.Fatalf("history has %d versions, current %d; want 3, 3", len(history.Versions), history.CurrentVersion)
+	}
+	if note := history.Versions[2].ChangeNote; note != "Restored from version 1: compose was a mistake" {
+		t.Errorf("change note = %q", note)
+	}
+	if c, _ := ta.ctx.GetContext(DefaultContextID); c.MemoryCount != 1 {
+		t.Errorf("MemoryCount = %d after restoring in place, want 1", c.MemoryCount)
+	}
+}
+
+func TestRestoreVersionErrors(t *testing.T) {
+	ta, embedder := newRestoreApp(t)
+	for _, tc := range []struct {
+		name string
+		args map[string]any
+		want string
+	}{
+		{"no history", map[string]any{"memory_id": "unknown", "version_number": 1.0}, "has no version history"},
+		{"out of range", map[string]any{"memory_id": "deploy", "version_number": 3.0}, "Version 3 is out of range: memory 'deploy' has versions 1-2"},
+		{"zero", map[string]any{"memory_id": "deploy", "version_number": 0.0}, "out of range"},
+		{"fraction", map[string]any{"memory_id": "deploy", "version_number": 1.5}, "must be an integer"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			text, isErr := call(t, ta.restoreVersionHandler, tc.args)
+			if !isErr || !strings.Contains(text, tc.want) {
+				t.Errorf("restore_version = %q, want an error containing %q", text, tc.want)
+			}
+		})
+	}
+
+	// A failed embedding changes neither the memory nor its history
+	embedder.failing.Store(true)
+	text, isErr := call(t, ta.restoreVersionHandler, map[string]any{"memory_id": "deploy", "version_number": 1.0})
+	if !isErr || !strings.Contains(text, "Failed to embed") {
+		t.Errorf("restore_version with a failing embedder = %q", text)
+	}
+	if n := len(ta.history(t, "deploy").Versions); n != 2 {
+		t.Errorf("history has %d versions after a failed restore, want 2", n)
+	}
+	if doc, err := ta.vectorStore.GetByID(t.Context(), "deploy"); err != nil || doc.Content != "deploy with docker compose files" {
+		t.Errorf("current content after a failed restore = %q, %v", doc.Content, err)
+	}
+}
+
+func TestCLIRestore(t *testing.T) {
+	ta, _ := newRestoreApp(t)
+	out := captureStdout(t, func() {
+		ta.runCLI(t.Context(), strings.NewReader("restore deploy 1\nrestore deploy x\n"))
+	})
+	if !strings.Contains(out, "Restored") || !strings.Contains(out, "Usage: restore <id> <version>") {
+		t.Errorf("CLI output:\n%s", out)
+	}
+	if doc, _ := ta.vectorStore.GetByID(t.Context(), "deploy"); doc.Content != "deploy with kubernetes helm charts" {
+		t.Errorf("content after CLI restore = %q", doc.Content)
+	}
+}
+
+func TestMemoryHistoryWithoutVersions(t *testing.T) {
+	ta := newTestApp(t, nil)
+	// Stored directly, as memories were before versions were recorded
+	if err := ta.vectorStore.AddDocument(t.Context(), chromem.Document{ID: "legacy", Content: "from an old release", Metadata: map[string]string{"context": DefaultContextID}}); err != nil {
+		t.Fatal(err)
+	}
+	text, isErr := call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "legacy"})
+	if isErr || !strings.Contains(text, "has no version history") {
+		t.Errorf("history of an unversioned memory = %q", text)
+	}
+	if text, isErr := call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "missing"}); !isErr || !strings.Contains(text, "not found") {
+		t.Errorf("history of a missing memory = %q", text)
+	}
+}
+
+func TestMemoryHistorySingleVersion(t *testing.T) {
+	ta := newTestApp(t, nil)
+	long := strings.Repeat("long content ", 20) + "THE END"
+	ta.remember(t, "single", long, nil)
+
+	text, isErr := call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "single"})
+	if isErr {
+		t.Fatalf("get_memory_history: %s", text)
+	}
+	if !strings.Contains(text, "(1 versions, current: 1") || !strings.Contains(text, "Version 1 (current) - ") || !strings.Contains(text, "by "+ta.clientID+": Created") {
+		t.Errorf("history:\n%s", text)
+	}
+	if strings.Contains(text, "THE END") || !strings.Contains(text, "...") || strings.Contains(text, "Showing the latest") {
+		t.Errorf("the preview is not truncated:\n%s", text)
+	}
+
+	text, _ = call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "single", "include_content": true})
+	if !strings.Contains(text, long) {
+		t.Errorf("include_content does not show the full content:\n%s", text)
+	}
+}
+
+func TestMemoryHistoryManyVersions(t *testing.T) {
+	ta := newTestApp(t, nil)
+	const versions = MaxHistoryVersionsShown + 2
+	for i := 1; i <= versions; i++ {
+		ta.remember(t, "busy", fmt.Sprintf("revision number %d", i), nil)
+	}
+
+	text, isErr := call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "busy"})
+	if isErr {
+		t.Fatalf("get_memory_history: %s", text)
+	}
+	if !strings.Contains(text, fmt.Sprintf("(%d versions, current: %d", versions, versions)) {
+		t.Errorf("header does not count every version:\n%s", text)
+	}
+	if !strings.Contains(text, "Showing the latest 10 versions; 2 older versions can be fetched with the version argument") {
+		t.Errorf("no truncation note:\n%s", text)
+	}
+	if n := strings.Count(text, "\nVersion "); n != MaxHistoryVersionsShown {
+		t.Errorf("shows %d versions, want %d", n, MaxHistoryVersionsShown)
+	}
+	if strings.Contains(text, "revision number 2\n") || !strings.Contains(text, "revision number 3\n") || !strings.Contains(text, fmt.Sprintf("Version %d (current)", versions)) {
+		t.Errorf("does not show the latest versions:\n%s", text)
+	}
+
+	text, _ = call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "busy", "version": 1.0})
+	if !strings.Contains(text, "Version 1 - ") || !strings.Contains(text, "revision number 1\n") || strings.Count(text, "\nVersion ") != 1 {
+		t.Errorf("version 1:\n%s", text)
+	}
+	text, isErr = call(t, ta.getMemoryHistoryHandler, map[string]any{"memory_id": "busy", "version": 13.0})
+	if !isErr || !strings.Contains(text, "has versions 1-12") {
+		t.Errorf("version 13 = %q", text)
+	}
+}